@@ -0,0 +1,204 @@
+package kerberos
+
+import (
+	"fmt"
+)
+
+// Encryption type numbers
+// Src: https://www.iana.org/assignments/kerberos-parameters/kerberos-parameters.xhtml
+const (
+	ETYPE_AES128_CTS_HMAC_SHA1_96    int32 = 17
+	ETYPE_AES256_CTS_HMAC_SHA1_96    int32 = 18
+	ETYPE_AES128_CTS_HMAC_SHA256_128 int32 = 19
+	ETYPE_AES256_CTS_HMAC_SHA384_192 int32 = 20
+	ETYPE_RC4_HMAC                   int32 = 23
+)
+
+// Checksum type numbers
+// Src: https://www.iana.org/assignments/kerberos-parameters/kerberos-parameters.xhtml
+const (
+	CKSUMTYPE_HMAC_SHA1_96_AES128    int32 = 15
+	CKSUMTYPE_HMAC_SHA1_96_AES256    int32 = 16
+	CKSUMTYPE_HMAC_SHA256_128_AES128 int32 = 19
+	CKSUMTYPE_HMAC_SHA384_192_AES256 int32 = 20
+	CKSUMTYPE_HMAC_MD5               int32 = -138
+)
+
+// Key usage numbers
+// Src: https://www.rfc-editor.org/rfc/rfc4120#section-7.5.1
+const (
+	KEY_USAGE_AS_REQ_PA_ENC_TIMESTAMP         uint32 = 1
+	KEY_USAGE_KDC_REP_TICKET                  uint32 = 2
+	KEY_USAGE_AS_REP_ENCPART                  uint32 = 3
+	KEY_USAGE_TGS_REQ_AUTH_DATA_SESSION_KEY   uint32 = 4
+	KEY_USAGE_TGS_REQ_AUTH_DATA_SUBKEY        uint32 = 5
+	KEY_USAGE_TGS_REQ_PA_TGS_REQ_AP_REQ_CKSUM uint32 = 6
+	KEY_USAGE_TGS_REQ_PA_TGS_REQ_AP_REQ_AUTH  uint32 = 7
+	KEY_USAGE_TGS_REP_ENCPART_SESSION_KEY     uint32 = 8
+	KEY_USAGE_TGS_REP_ENCPART_SUBKEY          uint32 = 9
+	KEY_USAGE_AP_REQ_AUTH_CKSUM               uint32 = 10
+	KEY_USAGE_AP_REQ_AUTH                     uint32 = 11
+	KEY_USAGE_AP_REP_ENCPART                  uint32 = 12
+	KEY_USAGE_KRB_PRIV_ENCPART                uint32 = 13
+	KEY_USAGE_KRB_CRED_ENCPART                uint32 = 14
+	KEY_USAGE_KRB_SAFE_CKSUM                  uint32 = 15
+	KEY_USAGE_KERB_NON_KERB_SALT              uint32 = 16
+	KEY_USAGE_KERB_NON_KERB_CKSUM_SALT        uint32 = 17
+	KEY_USAGE_GSSAPI_ACCEPTOR_SEAL            uint32 = 22
+	KEY_USAGE_GSSAPI_ACCEPTOR_SIGN            uint32 = 23
+	KEY_USAGE_GSSAPI_INITIATOR_SEAL           uint32 = 24
+	KEY_USAGE_GSSAPI_INITIATOR_SIGN           uint32 = 25
+	KEY_USAGE_PA_S4U_X509_USER_REQUEST        uint32 = 26
+	KEY_USAGE_PA_S4U_X509_USER_REPLY          uint32 = 27
+)
+
+// EncryptionType is the interface implemented by the Kerberos cryptosystems specified in
+// RFC 3961 and its profiles (RFC 3962, RFC 4757, RFC 8009).
+type EncryptionType interface {
+	// GetETypeID returns the encryption type number
+	GetETypeID() int32
+	// GetChecksumTypeID returns the number of the keyed checksum associated with the encryption type
+	GetChecksumTypeID() int32
+	// GetKeyByteSize returns the size of the keys in bytes
+	GetKeyByteSize() int
+	// GetName returns the name of the encryption type
+	GetName() string
+	// StringToKey derives a key from a password, a salt and optional string-to-key parameters
+	StringToKey(password string, salt string, params []byte) ([]byte, error)
+	// Encrypt encrypts the plaintext for the given key usage
+	Encrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error)
+	// Decrypt decrypts and verifies the ciphertext for the given key usage
+	Decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error)
+	// Checksum computes the keyed checksum of the data for the given key usage
+	Checksum(key []byte, usage uint32, data []byte) ([]byte, error)
+}
+
+// GetEncryptionType returns the EncryptionType implementing the given encryption type number.
+//
+// Parameters:
+// - etype: An int32 representing the encryption type number.
+//
+// Returns:
+// - The EncryptionType implementing the encryption type.
+// - An error if the encryption type is not supported.
+func GetEncryptionType(etype int32) (EncryptionType, error) {
+	switch etype {
+	case ETYPE_AES128_CTS_HMAC_SHA1_96:
+		return AES128CTSHMACSHA196{}, nil
+	case ETYPE_AES256_CTS_HMAC_SHA1_96:
+		return AES256CTSHMACSHA196{}, nil
+	case ETYPE_AES128_CTS_HMAC_SHA256_128:
+		return AES128CTSHMACSHA256128{}, nil
+	case ETYPE_AES256_CTS_HMAC_SHA384_192:
+		return AES256CTSHMACSHA384192{}, nil
+	case ETYPE_RC4_HMAC:
+		return RC4HMAC{}, nil
+	}
+	return nil, fmt.Errorf("unsupported encryption type %d", etype)
+}
+
+// GetEncryptionTypeByChecksumType returns the EncryptionType whose keyed checksum is the given checksum type.
+//
+// Parameters:
+// - cksumtype: An int32 representing the checksum type number.
+//
+// Returns:
+// - The EncryptionType associated with the checksum type.
+// - An error if the checksum type is not supported.
+func GetEncryptionTypeByChecksumType(cksumtype int32) (EncryptionType, error) {
+	switch cksumtype {
+	case CKSUMTYPE_HMAC_SHA1_96_AES128:
+		return AES128CTSHMACSHA196{}, nil
+	case CKSUMTYPE_HMAC_SHA1_96_AES256:
+		return AES256CTSHMACSHA196{}, nil
+	case CKSUMTYPE_HMAC_SHA256_128_AES128:
+		return AES128CTSHMACSHA256128{}, nil
+	case CKSUMTYPE_HMAC_SHA384_192_AES256:
+		return AES256CTSHMACSHA384192{}, nil
+	case CKSUMTYPE_HMAC_MD5:
+		return RC4HMAC{}, nil
+	}
+	return nil, fmt.Errorf("unsupported checksum type %d", cksumtype)
+}
+
+// Key represents a Kerberos key along with its encryption type.
+type Key struct {
+	EType int32
+	Value []byte
+}
+
+// NewKeyFromPassword derives a Kerberos key of the given encryption type from a password and a salt,
+// using the default string-to-key parameters.
+//
+// Parameters:
+// - etype: An int32 representing the encryption type of the key.
+// - password: A string representing the password.
+// - salt: A string representing the salt (see UserSalt and MachineSalt). It is ignored for RC4-HMAC.
+//
+// Returns:
+// - A pointer to a Key object.
+// - An error if the encryption type is not supported.
+func NewKeyFromPassword(etype int32, password string, salt string) (*Key, error) {
+	e, err := GetEncryptionType(etype)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := e.StringToKey(password, salt, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{EType: etype, Value: value}, nil
+}
+
+// Encrypt encrypts the plaintext with the key for the given key usage.
+//
+// Parameters:
+// - usage: A uint32 representing the key usage number.
+// - plaintext: A byte slice containing the data to encrypt.
+//
+// Returns:
+// - A byte slice containing the ciphertext.
+// - An error if the encryption fails.
+func (k *Key) Encrypt(usage uint32, plaintext []byte) ([]byte, error) {
+	e, err := GetEncryptionType(k.EType)
+	if err != nil {
+		return nil, err
+	}
+	return e.Encrypt(k.Value, usage, plaintext)
+}
+
+// Decrypt decrypts and verifies the ciphertext with the key for the given key usage.
+//
+// Parameters:
+// - usage: A uint32 representing the key usage number.
+// - ciphertext: A byte slice containing the data to decrypt.
+//
+// Returns:
+// - A byte slice containing the plaintext.
+// - An error if the decryption or the integrity check fails.
+func (k *Key) Decrypt(usage uint32, ciphertext []byte) ([]byte, error) {
+	e, err := GetEncryptionType(k.EType)
+	if err != nil {
+		return nil, err
+	}
+	return e.Decrypt(k.Value, usage, ciphertext)
+}
+
+// Checksum computes the keyed checksum of the data with the key for the given key usage.
+//
+// Parameters:
+// - usage: A uint32 representing the key usage number.
+// - data: A byte slice containing the data to checksum.
+//
+// Returns:
+// - A byte slice containing the checksum.
+// - An error if the computation fails.
+func (k *Key) Checksum(usage uint32, data []byte) ([]byte, error) {
+	e, err := GetEncryptionType(k.EType)
+	if err != nil {
+		return nil, err
+	}
+	return e.Checksum(k.Value, usage, data)
+}
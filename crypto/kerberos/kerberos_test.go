@@ -0,0 +1,267 @@
+package kerberos
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex string %q: %v", s, err)
+	}
+	return data
+}
+
+func TestNFold(t *testing.T) {
+	tests := []struct {
+		input    string
+		bits     int
+		expected string
+	}{
+		{"012345", 64, "be072631276b1955"},
+		{"password", 56, "78a07b6caf85fa"},
+		{"Rough Consensus, and Running Code", 64, "bb6ed30870b7f0e0"},
+		{"kerberos", 64, "6b65726265726f73"},
+		{"kerberos", 168, "8372c236344e5f1550cd0747e15d62ca7a5a3bcea4"},
+	}
+
+	for _, test := range tests {
+		result := hex.EncodeToString(NFold([]byte(test.input), test.bits/8))
+		if result != test.expected {
+			t.Errorf("NFold(%q, %d) = %s; expected %s", test.input, test.bits, result, test.expected)
+		}
+	}
+}
+
+func TestStringToKey(t *testing.T) {
+	tests := []struct {
+		etype    int32
+		password string
+		salt     string
+		params   []byte
+		expected string
+	}{
+		// RFC 3962 appendix B
+		{ETYPE_AES128_CTS_HMAC_SHA1_96, "password", "ATHENA.MIT.EDUraeburn", []byte{0, 0, 0, 1}, "42263c6e89f4fc28b8df68ee09799f15"},
+		{ETYPE_AES256_CTS_HMAC_SHA1_96, "password", "ATHENA.MIT.EDUraeburn", []byte{0, 0, 0, 1}, "fe697b52bc0d3ce14432ba036a92e65bbb52280990a2fa27883998d72af30161"},
+		{ETYPE_AES128_CTS_HMAC_SHA1_96, "password", "ATHENA.MIT.EDUraeburn", []byte{0, 0, 0x04, 0xb0}, "4c01cd46d632d01e6dbe230a01ed642a"},
+		{ETYPE_AES256_CTS_HMAC_SHA1_96, "password", "ATHENA.MIT.EDUraeburn", []byte{0, 0, 0x04, 0xb0}, "55a6ac740ad17b4846941051e1e8b0a7548d93b0ab30a8bc3ff16280382b8c2a"},
+		// RFC 8009 appendix A
+		{ETYPE_AES128_CTS_HMAC_SHA256_128, "password", "\x10\xdf\x9d\xd7\x83\xe5\xbc\x8a\xce\xa1\x73\x0e\x74\x35\x5f\x61ATHENA.MIT.EDUraeburn", nil, "089bca48b105ea6ea77ca5d2f39dc5e7"},
+		{ETYPE_AES256_CTS_HMAC_SHA384_192, "password", "\x10\xdf\x9d\xd7\x83\xe5\xbc\x8a\xce\xa1\x73\x0e\x74\x35\x5f\x61ATHENA.MIT.EDUraeburn", nil, "45bd806dbf6a833a9cffc1c94589a222367a79bc21c413718906e9f578a78467"},
+		// RC4-HMAC keys are NT hashes
+		{ETYPE_RC4_HMAC, "", "ignored", nil, "31d6cfe0d16ae931b73c59d7e0c089c0"},
+	}
+
+	for _, test := range tests {
+		encType, err := GetEncryptionType(test.etype)
+		if err != nil {
+			t.Fatalf("GetEncryptionType(%d) returned an error: %v", test.etype, err)
+		}
+		key, err := encType.StringToKey(test.password, test.salt, test.params)
+		if err != nil {
+			t.Fatalf("%s.StringToKey(%q) returned an error: %v", encType.GetName(), test.password, err)
+		}
+		if hex.EncodeToString(key) != test.expected {
+			t.Errorf("%s.StringToKey(%q) = %x; expected %s", encType.GetName(), test.password, key, test.expected)
+		}
+	}
+}
+
+func TestEncryptCTSVectors(t *testing.T) {
+	// RFC 3962 appendix B, AES-128 in CBC mode with ciphertext stealing and a zero IV
+	key := mustDecodeHex(t, "636869636b656e207465726979616b69")
+	iv := make([]byte, 16)
+	tests := []struct {
+		plaintext  string
+		ciphertext string
+	}{
+		{"4920776f756c64206c696b652074686520", "c6353568f2bf8cb4d8a580362da7ff7f97"},
+		{"4920776f756c64206c696b65207468652047656e6572616c20476175277320", "fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5"},
+		{"4920776f756c64206c696b65207468652047656e6572616c2047617527732043", "39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584"},
+		{"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c", "97687268d6ecccc0c07b25e25ecfe584b3fffd940c16a18c1b5549d2f838029e39312523a78662d5be7fcbcc98ebf5"},
+		{"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c20", "97687268d6ecccc0c07b25e25ecfe5849dad8bbb96c4cdc03bc103e1a194bbd839312523a78662d5be7fcbcc98ebf5a8"},
+		{"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c20616e6420776f6e746f6e20736f75702e", "97687268d6ecccc0c07b25e25ecfe58439312523a78662d5be7fcbcc98ebf5a84807efe836ee89a526730dbc2f7bc8409dad8bbb96c4cdc03bc103e1a194bbd8"},
+	}
+
+	for _, test := range tests {
+		ciphertext, err := EncryptCTS(key, iv, mustDecodeHex(t, test.plaintext))
+		if err != nil {
+			t.Fatalf("EncryptCTS(%s) returned an error: %v", test.plaintext, err)
+		}
+		if hex.EncodeToString(ciphertext) != test.ciphertext {
+			t.Errorf("EncryptCTS(%s) = %x; expected %s", test.plaintext, ciphertext, test.ciphertext)
+		}
+
+		plaintext, err := DecryptCTS(key, iv, mustDecodeHex(t, test.ciphertext))
+		if err != nil {
+			t.Fatalf("DecryptCTS(%s) returned an error: %v", test.ciphertext, err)
+		}
+		if hex.EncodeToString(plaintext) != test.plaintext {
+			t.Errorf("DecryptCTS(%s) = %x; expected %s", test.ciphertext, plaintext, test.plaintext)
+		}
+	}
+}
+
+func TestAESSHA2Vectors(t *testing.T) {
+	// RFC 8009 appendix A, with the key usage 2
+	tests := []struct {
+		profile    aesSHA2Profile
+		key        string
+		plaintext  string
+		confounder string
+		ciphertext string
+	}{
+		{aes128SHA256Profile, "3705d96080c17728a0e800eab6e0d23c", "", "7e5895eaf2672435bad817f545a37148", "ef85fb890bb8472f4dab20394dca781dad877eda39d50c870c0d5a0a8e48c718"},
+		{aes128SHA256Profile, "3705d96080c17728a0e800eab6e0d23c", "000102030405", "7bca285e2fd4130fb55b1a5c83bc5b24", "84d7f30754ed987bab0bf3506beb09cfb55402cef7e6877ce99e247e52d16ed4421dfdf8976c"},
+		{aes128SHA256Profile, "3705d96080c17728a0e800eab6e0d23c", "000102030405060708090a0b0c0d0e0f", "56ab21713ff62c0a1457200f6fa9948f", "3517d640f50ddc8ad3628722b3569d2ae07493fa8263254080ea65c1008e8fc295fb4852e7d83e1e7c48c37eebe6b0d3"},
+		{aes128SHA256Profile, "3705d96080c17728a0e800eab6e0d23c", "000102030405060708090a0b0c0d0e0f1011121314", "a7a4e29a4728ce10664fb64e49ad3fac", "720f73b18d9859cd6ccb4346115cd336c70f58edc0c4437c5573544c31c813bce1e6d072c186b39a413c2f92ca9b8334a287ffcbfc"},
+		{aes256SHA384Profile, "6d404d37faf79f9df0d33568d320669800eb4836472ea8a026d16b7182460c52", "", "f764e9fa15c276478b2c7d0c4e5f58e4", "41f53fa5bfe7026d91faf9be959195a058707273a96a40f0a01960621ac612748b9bbfbe7eb4ce3c"},
+		{aes256SHA384Profile, "6d404d37faf79f9df0d33568d320669800eb4836472ea8a026d16b7182460c52", "000102030405", "b80d3251c1f6471494256ffe712d0b9a", "4ed7b37c2bcac8f74f23c1cf07e62bc7b75fb3f637b9f559c7f664f69eab7b6092237526ea0d1f61cb20d69d10f2"},
+		{aes256SHA384Profile, "6d404d37faf79f9df0d33568d320669800eb4836472ea8a026d16b7182460c52", "000102030405060708090a0b0c0d0e0f", "53bf8a0d105265d4e276428624ce5e63", "bc47ffec7998eb91e8115cf8d19dac4bbbe2e163e87dd37f49beca92027764f68cf51f14d798c2273f35df574d1f932e40c4ff255b36a266"},
+		{aes256SHA384Profile, "6d404d37faf79f9df0d33568d320669800eb4836472ea8a026d16b7182460c52", "000102030405060708090a0b0c0d0e0f1011121314", "763e65367e864f02f55153c7e3b58af1", "40013e2df58e8751957d2878bcd2d6fe101ccfd556cb1eae79db3c3ee86429f2b2a602ac86fef6ecb647d6295fae077a1feb517508d2c16b4192e01f62"},
+	}
+
+	for _, test := range tests {
+		key := mustDecodeHex(t, test.key)
+		ciphertext, err := test.profile.encrypt(key, 2, mustDecodeHex(t, test.plaintext), mustDecodeHex(t, test.confounder))
+		if err != nil {
+			t.Fatalf("%s: encrypt(%s) returned an error: %v", test.profile.name, test.plaintext, err)
+		}
+		if hex.EncodeToString(ciphertext) != test.ciphertext {
+			t.Errorf("%s: encrypt(%s) = %x; expected %s", test.profile.name, test.plaintext, ciphertext, test.ciphertext)
+		}
+
+		plaintext, err := test.profile.decrypt(key, 2, mustDecodeHex(t, test.ciphertext))
+		if err != nil {
+			t.Fatalf("%s: decrypt(%s) returned an error: %v", test.profile.name, test.ciphertext, err)
+		}
+		if hex.EncodeToString(plaintext) != test.plaintext {
+			t.Errorf("%s: decrypt(%s) = %x; expected %s", test.profile.name, test.ciphertext, plaintext, test.plaintext)
+		}
+	}
+
+	checksums := []struct {
+		encType  EncryptionType
+		key      string
+		expected string
+	}{
+		{AES128CTSHMACSHA256128{}, "3705d96080c17728a0e800eab6e0d23c", "d78367186643d67b411cba9139fc1dee"},
+		{AES256CTSHMACSHA384192{}, "6d404d37faf79f9df0d33568d320669800eb4836472ea8a026d16b7182460c52", "45ee791567eefca37f4ac1e0222de80d43c3bfa06699672a"},
+	}
+	for _, test := range checksums {
+		checksum, err := test.encType.Checksum(mustDecodeHex(t, test.key), 2, mustDecodeHex(t, "000102030405060708090a0b0c0d0e0f1011121314"))
+		if err != nil {
+			t.Fatalf("%s: Checksum returned an error: %v", test.encType.GetName(), err)
+		}
+		if hex.EncodeToString(checksum) != test.expected {
+			t.Errorf("%s: Checksum() = %x; expected %s", test.encType.GetName(), checksum, test.expected)
+		}
+	}
+}
+
+func TestRC4HMACVectors(t *testing.T) {
+	// RFC 4757 does not publish test vectors, these known answers were computed from the construction
+	// of its sections 4 and 5 with an independent HMAC-MD5 and RC4 implementation
+	key := mustDecodeHex(t, "68f263db3fce15d031c9eab02d67107a")
+	confounder := mustDecodeHex(t, "37245e73a45fbf72")
+	tests := []struct {
+		usage      uint32
+		plaintext  string
+		ciphertext string
+	}{
+		{KEY_USAGE_KDC_REP_TICKET, "", "79810424e49d1fe2e886d9120dd06caba6304fa40dbe007d"},
+		// The AS-REP key usage is encrypted with the message type 8
+		{KEY_USAGE_AS_REP_ENCPART, "9 bytesss", "5d5b3daaa52f1b4f12ce7ce60f907fb3db811462303766050694188734f3fd4763"},
+		{KEY_USAGE_TGS_REP_ENCPART_SESSION_KEY, "30 bytes bytes bytes bytes byt", "5cd209ae59045f4370e23c5bcf4249add7c38fd17bde44e3a55ce53abdeecee60a1a8a720f355ff62b16385d344e0f374463758b0448"},
+	}
+
+	for _, test := range tests {
+		ciphertext, err := rc4HMACEncrypt(key, test.usage, []byte(test.plaintext), confounder)
+		if err != nil {
+			t.Fatalf("rc4HMACEncrypt(%q) returned an error: %v", test.plaintext, err)
+		}
+		if hex.EncodeToString(ciphertext) != test.ciphertext {
+			t.Errorf("rc4HMACEncrypt(%q) = %x; expected %s", test.plaintext, ciphertext, test.ciphertext)
+		}
+
+		plaintext, err := RC4HMAC{}.Decrypt(key, test.usage, mustDecodeHex(t, test.ciphertext))
+		if err != nil {
+			t.Fatalf("Decrypt(%s) returned an error: %v", test.ciphertext, err)
+		}
+		if string(plaintext) != test.plaintext {
+			t.Errorf("Decrypt(%s) = %q; expected %q", test.ciphertext, plaintext, test.plaintext)
+		}
+	}
+
+	checksum, err := RC4HMAC{}.Checksum(key, 17, []byte("fourteen bytes"))
+	if err != nil {
+		t.Fatalf("Checksum returned an error: %v", err)
+	}
+	if hex.EncodeToString(checksum) != "da41837029370cfbf46c609b871b4cff" {
+		t.Errorf("Checksum() = %x; expected da41837029370cfbf46c609b871b4cff", checksum)
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	etypes := []int32{
+		ETYPE_AES128_CTS_HMAC_SHA1_96,
+		ETYPE_AES256_CTS_HMAC_SHA1_96,
+		ETYPE_AES128_CTS_HMAC_SHA256_128,
+		ETYPE_AES256_CTS_HMAC_SHA384_192,
+		ETYPE_RC4_HMAC,
+	}
+	plaintexts := [][]byte{
+		{},
+		[]byte("short"),
+		[]byte("exactly 16 bytes"),
+		bytes.Repeat([]byte("Manticore"), 11),
+	}
+
+	for _, etype := range etypes {
+		key, err := NewKeyFromPassword(etype, "Password123!", UserSalt("lab.local", "Administrator"))
+		if err != nil {
+			t.Fatalf("NewKeyFromPassword(%d) returned an error: %v", etype, err)
+		}
+
+		for _, plaintext := range plaintexts {
+			ciphertext, err := key.Encrypt(KEY_USAGE_AS_REP_ENCPART, plaintext)
+			if err != nil {
+				t.Fatalf("etype %d: Encrypt returned an error: %v", etype, err)
+			}
+			decrypted, err := key.Decrypt(KEY_USAGE_AS_REP_ENCPART, ciphertext)
+			if err != nil {
+				t.Fatalf("etype %d: Decrypt returned an error: %v", etype, err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("etype %d: Decrypt(Encrypt(%q)) = %q", etype, plaintext, decrypted)
+			}
+
+			// Decrypting with the wrong key usage must fail the integrity check
+			if _, err := key.Decrypt(KEY_USAGE_KDC_REP_TICKET, ciphertext); err == nil {
+				t.Errorf("etype %d: Decrypt with a wrong key usage did not fail", etype)
+			}
+		}
+	}
+}
+
+func TestSalt(t *testing.T) {
+	tests := []struct {
+		realm          string
+		samAccountName string
+		expected       string
+	}{
+		{"lab.local", "Administrator", "LAB.LOCALAdministrator"},
+		{"LAB.LOCAL", "DC01$", "LAB.LOCALhostdc01.lab.local"},
+	}
+
+	for _, test := range tests {
+		result := Salt(test.realm, test.samAccountName)
+		if result != test.expected {
+			t.Errorf("Salt(%q, %q) = %q; expected %q", test.realm, test.samAccountName, result, test.expected)
+		}
+	}
+}
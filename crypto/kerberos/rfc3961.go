@@ -0,0 +1,246 @@
+package kerberos
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc3961
+
+// Key derivation constants suffixes appended to the key usage number
+const (
+	derivationChecksum   byte = 0x99
+	derivationEncryption byte = 0xAA
+	derivationIntegrity  byte = 0x55
+)
+
+// NFold implements the n-fold operation of RFC 3961 section 5.1, which stretches or shrinks
+// an input to a given number of bytes.
+//
+// Parameters:
+// - input: A byte slice containing the data to fold.
+// - n: An integer representing the size of the output in bytes.
+//
+// Returns:
+// - A byte slice of n bytes containing the folded data.
+func NFold(input []byte, n int) []byte {
+	inBits := len(input) * 8
+	outBits := n * 8
+
+	// The input is replicated until its length is the least common multiple of both lengths,
+	// each copy being rotated 13 bits to the right from the previous one
+	lcm := inBits * outBits / gcd(inBits, outBits)
+	buffer := make([]byte, 0, lcm/8)
+	for i := 0; i < lcm/inBits; i++ {
+		buffer = append(buffer, rotateRight(input, 13*i)...)
+	}
+
+	// The chunks of n bytes are added together using one's-complement addition
+	result := make([]byte, n)
+	for i := 0; i < len(buffer); i += n {
+		result = onesComplementAdd(result, buffer[i:i+n])
+	}
+
+	return result
+}
+
+// DeriveRandom implements the DR function of RFC 3961 section 5.1 for AES based encryption
+// types, using AES in ECB mode as the block cipher.
+//
+// Parameters:
+// - key: A byte slice containing the base key.
+// - constant: A byte slice containing the well-known constant.
+// - size: An integer representing the size of the output in bytes.
+//
+// Returns:
+// - A byte slice of size bytes of pseudo random data.
+// - An error if the key is not a valid AES key.
+func DeriveRandom(key, constant []byte, size int) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	input := NFold(constant, aes.BlockSize)
+	output := make([]byte, 0, size+aes.BlockSize)
+	for len(output) < size {
+		encrypted := make([]byte, aes.BlockSize)
+		block.Encrypt(encrypted, input)
+		output = append(output, encrypted...)
+		input = encrypted
+	}
+
+	return output[:size], nil
+}
+
+// DeriveKey implements the DK function of RFC 3961 section 5.1 for AES based encryption types,
+// for which the random-to-key function is the identity.
+//
+// Parameters:
+// - key: A byte slice containing the base key.
+// - constant: A byte slice containing the well-known constant.
+//
+// Returns:
+// - A byte slice containing the derived key, of the same size as the base key.
+// - An error if the key is not a valid AES key.
+func DeriveKey(key, constant []byte) ([]byte, error) {
+	return DeriveRandom(key, constant, len(key))
+}
+
+// usageConstant returns the 5 bytes constant used to derive the keys of a given key usage.
+func usageConstant(usage uint32, derivation byte) []byte {
+	constant := make([]byte, 5)
+	binary.BigEndian.PutUint32(constant, usage)
+	constant[4] = derivation
+	return constant
+}
+
+// EncryptCTS encrypts the data with AES in CBC mode with ciphertext stealing, as specified
+// in RFC 3962 section 5. The last two blocks are always swapped, even when the data is a
+// multiple of the block size.
+//
+// Parameters:
+// - key: A byte slice containing the AES key.
+// - iv: A byte slice containing the initialization vector.
+// - plaintext: A byte slice containing at least one block of data to encrypt.
+//
+// Returns:
+// - A byte slice of the same size as the plaintext containing the ciphertext.
+// - An error if the key is invalid or the plaintext is shorter than a block.
+func EncryptCTS(key, iv, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < aes.BlockSize {
+		return nil, fmt.Errorf("plaintext must be at least %d bytes long", aes.BlockSize)
+	}
+
+	padded := make([]byte, (len(plaintext)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+	copy(padded, plaintext)
+
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	if len(ciphertext) == aes.BlockSize {
+		return ciphertext, nil
+	}
+
+	// Swap the last two blocks and truncate to the size of the plaintext
+	n := len(ciphertext)
+	swapped := make([]byte, 0, n)
+	swapped = append(swapped, ciphertext[:n-2*aes.BlockSize]...)
+	swapped = append(swapped, ciphertext[n-aes.BlockSize:]...)
+	swapped = append(swapped, ciphertext[n-2*aes.BlockSize:n-aes.BlockSize]...)
+
+	return swapped[:len(plaintext)], nil
+}
+
+// DecryptCTS decrypts data encrypted with AES in CBC mode with ciphertext stealing, as
+// specified in RFC 3962 section 5.
+//
+// Parameters:
+// - key: A byte slice containing the AES key.
+// - iv: A byte slice containing the initialization vector.
+// - ciphertext: A byte slice containing at least one block of data to decrypt.
+//
+// Returns:
+// - A byte slice of the same size as the ciphertext containing the plaintext.
+// - An error if the key is invalid or the ciphertext is shorter than a block.
+func DecryptCTS(key, iv, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aes.BlockSize {
+		return nil, fmt.Errorf("ciphertext must be at least %d bytes long", aes.BlockSize)
+	}
+
+	if len(ciphertext) == aes.BlockSize {
+		plaintext := make([]byte, aes.BlockSize)
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+		return plaintext, nil
+	}
+
+	nblocks := (len(ciphertext) + aes.BlockSize - 1) / aes.BlockSize
+	lastSize := len(ciphertext) - (nblocks-1)*aes.BlockSize
+	prefixSize := (nblocks - 2) * aes.BlockSize
+
+	// Decrypt the first n-2 blocks in CBC mode
+	plaintext := make([]byte, 0, len(ciphertext))
+	previous := iv
+	if prefixSize > 0 {
+		prefix := make([]byte, prefixSize)
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(prefix, ciphertext[:prefixSize])
+		plaintext = append(plaintext, prefix...)
+		previous = ciphertext[prefixSize-aes.BlockSize : prefixSize]
+	}
+
+	// The second to last block holds the encryption of the padded last plaintext block
+	decryptedLast := make([]byte, aes.BlockSize)
+	block.Decrypt(decryptedLast, ciphertext[prefixSize:prefixSize+aes.BlockSize])
+
+	// Rebuild the stolen ciphertext block from the truncated last block
+	stolen := make([]byte, aes.BlockSize)
+	copy(stolen, ciphertext[prefixSize+aes.BlockSize:])
+	copy(stolen[lastSize:], decryptedLast[lastSize:])
+
+	secondToLast := make([]byte, aes.BlockSize)
+	block.Decrypt(secondToLast, stolen)
+	for i := range secondToLast {
+		secondToLast[i] ^= previous[i]
+	}
+	plaintext = append(plaintext, secondToLast...)
+
+	for i := 0; i < lastSize; i++ {
+		plaintext = append(plaintext, decryptedLast[i]^stolen[i])
+	}
+
+	return plaintext, nil
+}
+
+// ErrIntegrityCheckFailed is returned when the integrity checksum of a ciphertext does not match.
+var ErrIntegrityCheckFailed = errors.New("integrity check failed, the key is probably wrong")
+
+// rotateRight rotates a bit string to the right by the given number of bits.
+func rotateRight(input []byte, bits int) []byte {
+	size := len(input) * 8
+	output := make([]byte, len(input))
+	for i := 0; i < size; i++ {
+		source := ((i-bits)%size + size) % size
+		if input[source/8]&(0x80>>(source%8)) != 0 {
+			output[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return output
+}
+
+// onesComplementAdd adds two big endian numbers of the same size with end-around carry.
+func onesComplementAdd(a, b []byte) []byte {
+	result := make([]byte, len(a))
+	carry := 0
+	for i := len(a) - 1; i >= 0; i-- {
+		sum := int(a[i]) + int(b[i]) + carry
+		result[i] = byte(sum)
+		carry = sum >> 8
+	}
+	for carry != 0 {
+		for i := len(result) - 1; i >= 0 && carry != 0; i-- {
+			sum := int(result[i]) + carry
+			result[i] = byte(sum)
+			carry = sum >> 8
+		}
+	}
+	return result
+}
+
+// gcd returns the greatest common divisor of two integers.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,177 @@
+package kerberos
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc3962
+
+// Default number of PBKDF2 iterations of the AES-CTS-HMAC-SHA1-96 string-to-key function
+const aesSHA1DefaultIterations = 4096
+
+// Size of the truncated HMAC-SHA1 of the AES-CTS-HMAC-SHA1-96 encryption types
+const aesSHA1HMACSize = 12
+
+// AES128CTSHMACSHA196 implements the aes128-cts-hmac-sha1-96 encryption type (17).
+type AES128CTSHMACSHA196 struct{}
+
+// AES256CTSHMACSHA196 implements the aes256-cts-hmac-sha1-96 encryption type (18).
+type AES256CTSHMACSHA196 struct{}
+
+func (AES128CTSHMACSHA196) GetETypeID() int32 { return ETYPE_AES128_CTS_HMAC_SHA1_96 }
+
+func (AES128CTSHMACSHA196) GetChecksumTypeID() int32 { return CKSUMTYPE_HMAC_SHA1_96_AES128 }
+
+func (AES128CTSHMACSHA196) GetKeyByteSize() int { return 16 }
+
+func (AES128CTSHMACSHA196) GetName() string { return "aes128-cts-hmac-sha1-96" }
+
+func (e AES128CTSHMACSHA196) StringToKey(password string, salt string, params []byte) ([]byte, error) {
+	return aesSHA1StringToKey(password, salt, params, e.GetKeyByteSize())
+}
+
+func (AES128CTSHMACSHA196) Encrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	return aesSHA1Encrypt(key, usage, plaintext, nil)
+}
+
+func (AES128CTSHMACSHA196) Decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	return aesSHA1Decrypt(key, usage, ciphertext)
+}
+
+func (AES128CTSHMACSHA196) Checksum(key []byte, usage uint32, data []byte) ([]byte, error) {
+	return aesSHA1Checksum(key, usage, data)
+}
+
+func (AES256CTSHMACSHA196) GetETypeID() int32 { return ETYPE_AES256_CTS_HMAC_SHA1_96 }
+
+func (AES256CTSHMACSHA196) GetChecksumTypeID() int32 { return CKSUMTYPE_HMAC_SHA1_96_AES256 }
+
+func (AES256CTSHMACSHA196) GetKeyByteSize() int { return 32 }
+
+func (AES256CTSHMACSHA196) GetName() string { return "aes256-cts-hmac-sha1-96" }
+
+func (e AES256CTSHMACSHA196) StringToKey(password string, salt string, params []byte) ([]byte, error) {
+	return aesSHA1StringToKey(password, salt, params, e.GetKeyByteSize())
+}
+
+func (AES256CTSHMACSHA196) Encrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	return aesSHA1Encrypt(key, usage, plaintext, nil)
+}
+
+func (AES256CTSHMACSHA196) Decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	return aesSHA1Decrypt(key, usage, ciphertext)
+}
+
+func (AES256CTSHMACSHA196) Checksum(key []byte, usage uint32, data []byte) ([]byte, error) {
+	return aesSHA1Checksum(key, usage, data)
+}
+
+// aesSHA1StringToKey derives a key from a password as specified in RFC 3962 section 4:
+// tkey = random2key(PBKDF2(password, salt, iterations, keysize)), key = DK(tkey, "kerberos")
+func aesSHA1StringToKey(password string, salt string, params []byte, keySize int) ([]byte, error) {
+	iterations, err := iterationsFromParams(params, aesSHA1DefaultIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	tkey, err := pbkdf2.Key(sha1.New, password, []byte(salt), iterations, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return DeriveKey(tkey, []byte("kerberos"))
+}
+
+// aesSHA1Encrypt encrypts the plaintext following the simplified profile of RFC 3961 section 5.3.
+// If confounder is nil, a random one is generated.
+func aesSHA1Encrypt(key []byte, usage uint32, plaintext []byte, confounder []byte) ([]byte, error) {
+	ke, err := DeriveKey(key, usageConstant(usage, derivationEncryption))
+	if err != nil {
+		return nil, err
+	}
+	ki, err := DeriveKey(key, usageConstant(usage, derivationIntegrity))
+	if err != nil {
+		return nil, err
+	}
+
+	if confounder == nil {
+		confounder = make([]byte, aes.BlockSize)
+		if _, err := rand.Read(confounder); err != nil {
+			return nil, err
+		}
+	}
+	data := append(append([]byte{}, confounder...), plaintext...)
+
+	ciphertext, err := EncryptCTS(ke, make([]byte, aes.BlockSize), data)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha1.New, ki)
+	mac.Write(data)
+
+	return append(ciphertext, mac.Sum(nil)[:aesSHA1HMACSize]...), nil
+}
+
+// aesSHA1Decrypt decrypts and verifies a ciphertext produced by aesSHA1Encrypt.
+func aesSHA1Decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize+aesSHA1HMACSize {
+		return nil, fmt.Errorf("ciphertext is too short (%d bytes)", len(ciphertext))
+	}
+
+	ke, err := DeriveKey(key, usageConstant(usage, derivationEncryption))
+	if err != nil {
+		return nil, err
+	}
+	ki, err := DeriveKey(key, usageConstant(usage, derivationIntegrity))
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := ciphertext[:len(ciphertext)-aesSHA1HMACSize]
+	expectedMAC := ciphertext[len(ciphertext)-aesSHA1HMACSize:]
+
+	data, err := DecryptCTS(ke, make([]byte, aes.BlockSize), encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha1.New, ki)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil)[:aesSHA1HMACSize], expectedMAC) {
+		return nil, ErrIntegrityCheckFailed
+	}
+
+	return data[aes.BlockSize:], nil
+}
+
+// aesSHA1Checksum computes the hmac-sha1-96-aes keyed checksum of RFC 3962.
+func aesSHA1Checksum(key []byte, usage uint32, data []byte) ([]byte, error) {
+	kc, err := DeriveKey(key, usageConstant(usage, derivationChecksum))
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha1.New, kc)
+	mac.Write(data)
+
+	return mac.Sum(nil)[:aesSHA1HMACSize], nil
+}
+
+// iterationsFromParams reads the iteration count from string-to-key parameters, which are
+// encoded as a 4 bytes big endian integer.
+func iterationsFromParams(params []byte, defaultIterations int) (int, error) {
+	if len(params) == 0 {
+		return defaultIterations, nil
+	}
+	if len(params) != 4 {
+		return 0, fmt.Errorf("invalid string-to-key parameters length %d", len(params))
+	}
+	return int(binary.BigEndian.Uint32(params)), nil
+}
@@ -0,0 +1,142 @@
+package kerberos
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/crypto/nt"
+	"github.com/TheManticoreProject/Manticore/crypto/rc4"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc4757
+
+// Size of the confounder of the RC4-HMAC encryption type
+const rc4ConfounderSize = 8
+
+// Size of the HMAC-MD5 checksum of the RC4-HMAC encryption type
+const rc4ChecksumSize = 16
+
+// RC4HMAC implements the rc4-hmac encryption type (23), whose key is the NT hash of the password.
+type RC4HMAC struct{}
+
+func (RC4HMAC) GetETypeID() int32 { return ETYPE_RC4_HMAC }
+
+func (RC4HMAC) GetChecksumTypeID() int32 { return CKSUMTYPE_HMAC_MD5 }
+
+func (RC4HMAC) GetKeyByteSize() int { return 16 }
+
+func (RC4HMAC) GetName() string { return "rc4-hmac" }
+
+// StringToKey returns the NT hash of the password. The salt and parameters are not used.
+func (RC4HMAC) StringToKey(password string, salt string, params []byte) ([]byte, error) {
+	ntHash := nt.NTHash(password)
+	return ntHash[:], nil
+}
+
+func (RC4HMAC) Encrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	return rc4HMACEncrypt(key, usage, plaintext, nil)
+}
+
+func (RC4HMAC) Decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < rc4ChecksumSize+rc4ConfounderSize {
+		return nil, fmt.Errorf("ciphertext is too short (%d bytes)", len(ciphertext))
+	}
+
+	k1 := rc4HMACUsageKey(key, usage)
+
+	checksum := ciphertext[:rc4ChecksumSize]
+	k3 := hmacMD5(k1, checksum)
+
+	cipher, err := rc4.NewRC4WithKey(k3)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, len(ciphertext)-rc4ChecksumSize)
+	cipher.XORKeyStream(data, ciphertext[rc4ChecksumSize:])
+
+	if !hmac.Equal(hmacMD5(k1, data), checksum) {
+		return nil, ErrIntegrityCheckFailed
+	}
+
+	return data[rc4ConfounderSize:], nil
+}
+
+// Checksum computes the HMAC-MD5 keyed checksum (-138) of RFC 4757 section 4.
+func (RC4HMAC) Checksum(key []byte, usage uint32, data []byte) ([]byte, error) {
+	ksign := hmacMD5(key, []byte("signaturekey\x00"))
+
+	msUsage := make([]byte, 4)
+	binary.LittleEndian.PutUint32(msUsage, rc4HMACMessageType(usage))
+	digest := md5.New()
+	digest.Write(msUsage)
+	digest.Write(data)
+
+	return hmacMD5(ksign, digest.Sum(nil)), nil
+}
+
+// RC4KeyFromNTHash returns the RC4-HMAC key corresponding to an NT hash, which is the NT hash itself.
+//
+// Parameters:
+// - ntHash: A 16 bytes array containing the NT hash of the password.
+//
+// Returns:
+// - A pointer to a Key object of type rc4-hmac.
+func RC4KeyFromNTHash(ntHash [16]byte) *Key {
+	return &Key{EType: ETYPE_RC4_HMAC, Value: append([]byte{}, ntHash[:]...)}
+}
+
+// rc4HMACEncrypt encrypts the plaintext as specified in RFC 4757 section 5.
+// If confounder is nil, a random one is generated.
+func rc4HMACEncrypt(key []byte, usage uint32, plaintext []byte, confounder []byte) ([]byte, error) {
+	if confounder == nil {
+		confounder = make([]byte, rc4ConfounderSize)
+		if _, err := rand.Read(confounder); err != nil {
+			return nil, err
+		}
+	}
+	data := append(append([]byte{}, confounder...), plaintext...)
+
+	k1 := rc4HMACUsageKey(key, usage)
+	checksum := hmacMD5(k1, data)
+	k3 := hmacMD5(k1, checksum)
+
+	cipher, err := rc4.NewRC4WithKey(k3)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(data))
+	cipher.XORKeyStream(encrypted, data)
+
+	return append(checksum, encrypted...), nil
+}
+
+// rc4HMACUsageKey derives the K1 key of RFC 4757 from the base key and the key usage.
+func rc4HMACUsageKey(key []byte, usage uint32) []byte {
+	msUsage := make([]byte, 4)
+	binary.LittleEndian.PutUint32(msUsage, rc4HMACMessageType(usage))
+	return hmacMD5(key, msUsage)
+}
+
+// rc4HMACMessageType translates a key usage number into the message type used by RC4-HMAC.
+func rc4HMACMessageType(usage uint32) uint32 {
+	switch usage {
+	case KEY_USAGE_AS_REP_ENCPART:
+		// The encrypted part of an AS-REP uses the same message type as the one of a TGS-REP
+		return KEY_USAGE_TGS_REP_ENCPART_SESSION_KEY
+	case KEY_USAGE_TGS_REP_ENCPART_SUBKEY:
+		return KEY_USAGE_TGS_REP_ENCPART_SESSION_KEY
+	case KEY_USAGE_GSSAPI_ACCEPTOR_SIGN:
+		return KEY_USAGE_KRB_PRIV_ENCPART
+	}
+	return usage
+}
+
+// hmacMD5 computes the HMAC-MD5 of the data with the given key.
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
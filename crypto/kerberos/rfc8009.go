@@ -0,0 +1,190 @@
+package kerberos
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc8009
+
+// Default number of PBKDF2 iterations of the AES-CTS-HMAC-SHA2 string-to-key function
+const aesSHA2DefaultIterations = 32768
+
+// AES128CTSHMACSHA256128 implements the aes128-cts-hmac-sha256-128 encryption type (19).
+type AES128CTSHMACSHA256128 struct{}
+
+// AES256CTSHMACSHA384192 implements the aes256-cts-hmac-sha384-192 encryption type (20).
+type AES256CTSHMACSHA384192 struct{}
+
+// aesSHA2Profile holds the parameters that differ between the two encryption types of RFC 8009.
+type aesSHA2Profile struct {
+	name     string
+	hash     func() hash.Hash
+	keySize  int
+	hmacSize int
+}
+
+var aes128SHA256Profile = aesSHA2Profile{name: "aes128-cts-hmac-sha256-128", hash: sha256.New, keySize: 16, hmacSize: 16}
+
+var aes256SHA384Profile = aesSHA2Profile{name: "aes256-cts-hmac-sha384-192", hash: sha512.New384, keySize: 32, hmacSize: 24}
+
+func (AES128CTSHMACSHA256128) GetETypeID() int32 { return ETYPE_AES128_CTS_HMAC_SHA256_128 }
+
+func (AES128CTSHMACSHA256128) GetChecksumTypeID() int32 { return CKSUMTYPE_HMAC_SHA256_128_AES128 }
+
+func (AES128CTSHMACSHA256128) GetKeyByteSize() int { return aes128SHA256Profile.keySize }
+
+func (AES128CTSHMACSHA256128) GetName() string { return aes128SHA256Profile.name }
+
+func (AES128CTSHMACSHA256128) StringToKey(password string, salt string, params []byte) ([]byte, error) {
+	return aes128SHA256Profile.stringToKey(password, salt, params)
+}
+
+func (AES128CTSHMACSHA256128) Encrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	return aes128SHA256Profile.encrypt(key, usage, plaintext, nil)
+}
+
+func (AES128CTSHMACSHA256128) Decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	return aes128SHA256Profile.decrypt(key, usage, ciphertext)
+}
+
+func (AES128CTSHMACSHA256128) Checksum(key []byte, usage uint32, data []byte) ([]byte, error) {
+	return aes128SHA256Profile.checksum(key, usage, data)
+}
+
+func (AES256CTSHMACSHA384192) GetETypeID() int32 { return ETYPE_AES256_CTS_HMAC_SHA384_192 }
+
+func (AES256CTSHMACSHA384192) GetChecksumTypeID() int32 { return CKSUMTYPE_HMAC_SHA384_192_AES256 }
+
+func (AES256CTSHMACSHA384192) GetKeyByteSize() int { return aes256SHA384Profile.keySize }
+
+func (AES256CTSHMACSHA384192) GetName() string { return aes256SHA384Profile.name }
+
+func (AES256CTSHMACSHA384192) StringToKey(password string, salt string, params []byte) ([]byte, error) {
+	return aes256SHA384Profile.stringToKey(password, salt, params)
+}
+
+func (AES256CTSHMACSHA384192) Encrypt(key []byte, usage uint32, plaintext []byte) ([]byte, error) {
+	return aes256SHA384Profile.encrypt(key, usage, plaintext, nil)
+}
+
+func (AES256CTSHMACSHA384192) Decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	return aes256SHA384Profile.decrypt(key, usage, ciphertext)
+}
+
+func (AES256CTSHMACSHA384192) Checksum(key []byte, usage uint32, data []byte) ([]byte, error) {
+	return aes256SHA384Profile.checksum(key, usage, data)
+}
+
+// kdf implements KDF-HMAC-SHA2 of RFC 8009 section 3, which is the KDF in counter mode of
+// NIST SP 800-108 with a single iteration: k-truncate(HMAC(key, 0x00000001 | label | 0x00 | k))
+func (p aesSHA2Profile) kdf(key []byte, label []byte, bits int) []byte {
+	mac := hmac.New(p.hash, key)
+	mac.Write([]byte{0x00, 0x00, 0x00, 0x01})
+	mac.Write(label)
+	mac.Write([]byte{0x00})
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(bits))
+	mac.Write(length)
+	return mac.Sum(nil)[:bits/8]
+}
+
+// stringToKey derives a key from a password as specified in RFC 8009 section 4.
+func (p aesSHA2Profile) stringToKey(password string, salt string, params []byte) ([]byte, error) {
+	iterations, err := iterationsFromParams(params, aesSHA2DefaultIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	// The salt is prefixed with the name of the encryption type
+	saltp := append([]byte(p.name), 0x00)
+	saltp = append(saltp, []byte(salt)...)
+
+	tkey, err := pbkdf2.Key(p.hash, password, saltp, iterations, p.keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.kdf(tkey, []byte("kerberos"), p.keySize*8), nil
+}
+
+// encrypt encrypts the plaintext as specified in RFC 8009 section 5.
+// If confounder is nil, a random one is generated.
+func (p aesSHA2Profile) encrypt(key []byte, usage uint32, plaintext []byte, confounder []byte) ([]byte, error) {
+	if len(key) != p.keySize {
+		return nil, fmt.Errorf("invalid key size %d for %s", len(key), p.name)
+	}
+	ke := p.kdf(key, usageConstant(usage, derivationEncryption), p.keySize*8)
+	ki := p.kdf(key, usageConstant(usage, derivationIntegrity), p.hmacSize*8)
+
+	if confounder == nil {
+		confounder = make([]byte, aes.BlockSize)
+		if _, err := rand.Read(confounder); err != nil {
+			return nil, err
+		}
+	}
+	data := append(append([]byte{}, confounder...), plaintext...)
+
+	iv := make([]byte, aes.BlockSize)
+	ciphertext, err := EncryptCTS(ke, iv, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The HMAC is computed over the IV and the ciphertext
+	mac := hmac.New(p.hash, ki)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+
+	return append(ciphertext, mac.Sum(nil)[:p.hmacSize]...), nil
+}
+
+// decrypt decrypts and verifies a ciphertext as specified in RFC 8009 section 5.
+func (p aesSHA2Profile) decrypt(key []byte, usage uint32, ciphertext []byte) ([]byte, error) {
+	if len(key) != p.keySize {
+		return nil, fmt.Errorf("invalid key size %d for %s", len(key), p.name)
+	}
+	if len(ciphertext) < aes.BlockSize+p.hmacSize {
+		return nil, fmt.Errorf("ciphertext is too short (%d bytes)", len(ciphertext))
+	}
+	ke := p.kdf(key, usageConstant(usage, derivationEncryption), p.keySize*8)
+	ki := p.kdf(key, usageConstant(usage, derivationIntegrity), p.hmacSize*8)
+
+	encrypted := ciphertext[:len(ciphertext)-p.hmacSize]
+	expectedMAC := ciphertext[len(ciphertext)-p.hmacSize:]
+
+	iv := make([]byte, aes.BlockSize)
+	mac := hmac.New(p.hash, ki)
+	mac.Write(iv)
+	mac.Write(encrypted)
+	if !hmac.Equal(mac.Sum(nil)[:p.hmacSize], expectedMAC) {
+		return nil, ErrIntegrityCheckFailed
+	}
+
+	data, err := DecryptCTS(ke, iv, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	return data[aes.BlockSize:], nil
+}
+
+// checksum computes the keyed checksum of RFC 8009 section 5.
+func (p aesSHA2Profile) checksum(key []byte, usage uint32, data []byte) ([]byte, error) {
+	if len(key) != p.keySize {
+		return nil, fmt.Errorf("invalid key size %d for %s", len(key), p.name)
+	}
+	kc := p.kdf(key, usageConstant(usage, derivationChecksum), p.hmacSize*8)
+
+	mac := hmac.New(p.hash, kc)
+	mac.Write(data)
+
+	return mac.Sum(nil)[:p.hmacSize], nil
+}
@@ -0,0 +1,59 @@
+package kerberos
+
+import (
+	"strings"
+)
+
+// UserSalt returns the salt Active Directory uses to derive the AES keys of a user account,
+// which is the uppercase realm followed by the case-sensitive sAMAccountName.
+//
+// Parameters:
+// - realm: A string representing the realm (DNS domain name) of the account.
+// - username: A string representing the sAMAccountName of the account.
+//
+// Returns:
+// - A string containing the salt.
+//
+// Example:
+//
+//	salt := UserSalt("lab.local", "Administrator")
+//	// salt will be "LAB.LOCALAdministrator"
+func UserSalt(realm, username string) string {
+	return strings.ToUpper(realm) + username
+}
+
+// MachineSalt returns the salt Active Directory uses to derive the AES keys of a computer account,
+// which is the uppercase realm followed by "host", the lowercase computer name without its
+// trailing "$", a dot and the lowercase realm.
+//
+// Parameters:
+// - realm: A string representing the realm (DNS domain name) of the account.
+// - computerName: A string representing the sAMAccountName of the computer, with or without its trailing "$".
+//
+// Returns:
+// - A string containing the salt.
+//
+// Example:
+//
+//	salt := MachineSalt("lab.local", "DC01$")
+//	// salt will be "LAB.LOCALhostdc01.lab.local"
+func MachineSalt(realm, computerName string) string {
+	name := strings.ToLower(strings.TrimSuffix(computerName, "$"))
+	return strings.ToUpper(realm) + "host" + name + "." + strings.ToLower(realm)
+}
+
+// Salt returns the Active Directory salt of an account, using the machine account rules if the
+// sAMAccountName ends with "$" and the user account rules otherwise.
+//
+// Parameters:
+// - realm: A string representing the realm (DNS domain name) of the account.
+// - samAccountName: A string representing the sAMAccountName of the account.
+//
+// Returns:
+// - A string containing the salt.
+func Salt(realm, samAccountName string) string {
+	if strings.HasSuffix(samAccountName, "$") {
+		return MachineSalt(realm, samAccountName)
+	}
+	return UserSalt(realm, samAccountName)
+}
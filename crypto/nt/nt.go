@@ -28,3 +28,12 @@ func NTHashHex(password string) string {
 	ntHash := NTHash(password)
 	return strings.ToLower(hex.EncodeToString(ntHash[:]))
 }
+
+// NTHashFromUTF16LE computes the NT hash of a password already encoded in UTF-16 little endian
+// This is required for machine generated passwords, such as the passwords of the group managed service accounts,
+// which are random bytes rather than valid UTF-16 strings
+func NTHashFromUTF16LE(utf16lePassword []byte) [16]byte {
+	hash := md4.New()
+	hash.Write(utf16lePassword)
+	return hash.Sum()
+}
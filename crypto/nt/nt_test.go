@@ -2,6 +2,8 @@ package nt
 
 import (
 	"testing"
+
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
 )
 
 func TestNTHash(t *testing.T) {
@@ -21,3 +23,14 @@ func TestNTHash(t *testing.T) {
 		}
 	}
 }
+
+func TestNTHashFromUTF16LE(t *testing.T) {
+	passwords := []string{"cG9kYWxpcml1cwo", "Sup3rS3cr3t!", ""}
+
+	for _, password := range passwords {
+		result := NTHashFromUTF16LE(utf16.EncodeUTF16LE(password))
+		if result != NTHash(password) {
+			t.Errorf("NTHashFromUTF16LE(%q) = %x; expected %x", password, result, NTHash(password))
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package adcs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/dcerpc"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+)
+
+// issueTestCertificate signs a certificate for the key of a request with a throwaway certificate authority.
+func issueTestCertificate(t *testing.T, request *CertificateRequest) []byte {
+	t.Helper()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "lab-DC01-CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &request.PrivateKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func TestNewCertificateRequest(t *testing.T) {
+	request, err := NewCertificateRequest(&CertificateRequestOptions{
+		CommonName:          "administrator",
+		UPN:                 "administrator@lab.local",
+		DNSNames:            []string{"dc01.lab.local"},
+		SID:                 "S-1-5-21-1-2-3-500",
+		ApplicationPolicies: []string{"1.3.6.1.5.5.7.3.2"},
+		KeySize:             1024,
+	})
+	if err != nil {
+		t.Fatalf("NewCertificateRequest failed: %v", err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(request.Raw)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate request: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Errorf("Invalid signature: %v", err)
+	}
+	if csr.Subject.CommonName != "administrator" {
+		t.Errorf("Expected common name administrator, got %s", csr.Subject.CommonName)
+	}
+	if len(csr.DNSNames) != 1 || csr.DNSNames[0] != "dc01.lab.local" {
+		t.Errorf("Expected DNS name dc01.lab.local, got %v", csr.DNSNames)
+	}
+
+	extensions := map[string][]byte{}
+	for _, extension := range csr.Extensions {
+		extensions[extension.Id.String()] = extension.Value
+	}
+	for _, expected := range []struct {
+		oid   string
+		value string
+	}{
+		{OID_SUBJECT_ALT_NAME, "administrator@lab.local"},
+		{OID_NTDS_CA_SECURITY_EXT, "S-1-5-21-1-2-3-500"},
+	} {
+		value, ok := extensions[expected.oid]
+		if !ok {
+			t.Errorf("Missing extension %s", expected.oid)
+			continue
+		}
+		if !bytes.Contains(value, []byte(expected.value)) {
+			t.Errorf("Extension %s does not hold %s", expected.oid, expected.value)
+		}
+	}
+
+	var policies []struct {
+		PolicyIdentifier asn1.ObjectIdentifier
+	}
+	if _, err := asn1.Unmarshal(extensions[OID_APPLICATION_CERT_POLICIES], &policies); err != nil {
+		t.Fatalf("Failed to parse application policies: %v", err)
+	}
+	if len(policies) != 1 || policies[0].PolicyIdentifier.String() != "1.3.6.1.5.5.7.3.2" {
+		t.Errorf("Unexpected application policies %v", policies)
+	}
+
+	if !strings.HasPrefix(string(request.ToPEM()), "-----BEGIN CERTIFICATE REQUEST-----") {
+		t.Errorf("Unexpected PEM encoding")
+	}
+
+	if _, err := NewCertificateRequest(&CertificateRequestOptions{SID: "not a SID", KeySize: 1024}); err == nil {
+		t.Errorf("Expected an error for an invalid SID")
+	}
+}
+
+func TestCertServerRequest(t *testing.T) {
+	request, err := NewCertificateRequest(&CertificateRequestOptions{UPN: "administrator@lab.local", KeySize: 1024})
+	if err != nil {
+		t.Fatalf("NewCertificateRequest failed: %v", err)
+	}
+
+	stub := marshalCertServerRequest(CR_IN_PKCS10, "lab-DC01-CA", 0, formatRequestAttributes("User", nil), request.Raw)
+	if !bytes.Contains(stub, request.Raw) {
+		t.Errorf("Request stub does not hold the certificate request")
+	}
+	if !bytes.Contains(stub, utf16.EncodeUTF16LE("CertificateTemplate:User")) {
+		t.Errorf("Request stub does not hold the certificate template")
+	}
+
+	certificate := issueTestCertificate(t, request)
+	message := append(utf16.EncodeUTF16LE("Issued"), 0, 0)
+
+	w := dcerpc.NewNDRWriter()
+	w.WriteUint32(42)
+	w.WriteUint32(CR_DISP_ISSUED)
+	for _, blob := range [][]byte{nil, certificate, message} {
+		w.WriteUint32(uint32(len(blob)))
+		if len(blob) == 0 {
+			w.WriteNullPointer()
+			continue
+		}
+		w.WritePointer()
+		w.WriteConformantBytes(blob)
+	}
+	w.WriteUint32(0)
+
+	result, err := parseCertServerResponse(w.Bytes(), request.PrivateKey)
+	if err != nil {
+		t.Fatalf("parseCertServerResponse failed: %v", err)
+	}
+	if result.RequestID != 42 || !result.IsIssued() || result.DispositionMessage != "Issued" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if result.Certificate == nil || !bytes.Equal(result.Certificate.ExportCertificateDER(), certificate) {
+		t.Errorf("Issued certificate was not returned")
+	}
+}
+
+func TestWebEnrollmentClient(t *testing.T) {
+	request, err := NewCertificateRequest(&CertificateRequestOptions{KeySize: 1024})
+	if err != nil {
+		t.Fatalf("NewCertificateRequest failed: %v", err)
+	}
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issueTestCertificate(t, request)})
+
+	answers := map[string]string{
+		"Issued":  `<a href="certnew.cer?ReqID=7&amp;Enc=b64">Download certificate</a>`,
+		"Pending": `<P>Certificate Pending</P> Your Request Id is 8.`,
+		"Denied":  `<P>Certificate Request Denied</P> Your Request Id is 9. The disposition message is "Denied by Policy Module  0x80094012".`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/certsrv/certfnsh.asp":
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("Mode") != "newreq" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			template := strings.TrimPrefix(r.PostForm.Get("CertAttrib"), "CertificateTemplate:")
+			fmt.Fprint(w, answers[template])
+		case "/certsrv/certnew.cer":
+			w.Write(certificate)
+		}
+	}))
+	defer server.Close()
+
+	client := &WebEnrollmentClient{BaseURL: server.URL + "/certsrv", httpClient: server.Client()}
+
+	result, err := client.RequestCertificate(request, "Issued", nil)
+	if err != nil {
+		t.Fatalf("RequestCertificate failed: %v", err)
+	}
+	if result.RequestID != 7 || !result.IsIssued() || result.Certificate == nil {
+		t.Errorf("Expected issued certificate, got %+v", result)
+	}
+
+	result, err = client.RequestCertificate(request, "Pending", nil)
+	if err != nil {
+		t.Fatalf("RequestCertificate failed: %v", err)
+	}
+	if result.RequestID != 8 || !result.IsPending() {
+		t.Errorf("Expected pending request, got %+v", result)
+	}
+
+	result, err = client.RequestCertificate(request, "Denied", nil)
+	if err != nil {
+		t.Fatalf("RequestCertificate failed: %v", err)
+	}
+	if result.RequestID != 9 || result.Disposition != 0x80094012 || result.Certificate != nil {
+		t.Errorf("Expected denied request, got %+v", result)
+	}
+
+	if _, err := client.RequestCertificate(request, "Unknown", nil); err == nil {
+		t.Errorf("Expected an error for an unrecognized answer")
+	}
+}
+
+func TestWebEnrollmentClientTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	creds, err := credentials.NewCredentials("LAB", "alice", "Password123!", "")
+	if err != nil {
+		t.Fatalf("NewCredentials failed: %v", err)
+	}
+	client, err := NewWebEnrollmentClient(strings.TrimPrefix(server.URL, "https://"), true, creds)
+	if err != nil {
+		t.Fatalf("NewWebEnrollmentClient failed: %v", err)
+	}
+	trustedCAs := x509.NewCertPool()
+	trustedCAs.AddCert(server.Certificate())
+
+	tests := []struct {
+		name      string
+		tlsConfig *tls.Config
+		success   bool
+	}{
+		{name: "Default", tlsConfig: nil, success: true},
+		{name: "UntrustedCertificate", tlsConfig: &tls.Config{RootCAs: x509.NewCertPool()}, success: false},
+		{name: "TrustedCertificate", tlsConfig: &tls.Config{RootCAs: trustedCAs}, success: true},
+		{name: "WrongServerName", tlsConfig: &tls.Config{RootCAs: trustedCAs, ServerName: "ca01.lab.local"}, success: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.SetTLSConfig(tt.tlsConfig)
+			_, err := client.send(http.MethodGet, "/", nil)
+			if (err == nil) != tt.success {
+				t.Errorf("Expected success %t, got %v", tt.success, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package adcs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OIDs of the names and extensions of the certificates issued by Active Directory Certificate Services
+// Src: [MS-WCCE] 2.2.2.7 Certificate Extensions
+const (
+	// Other name of the subject alternative name holding a user principal name
+	OID_NT_PRINCIPAL_NAME = "1.3.6.1.4.1.311.20.2.3"
+	// Extension holding the SID of the account the certificate is issued for (szOID_NTDS_CA_SECURITY_EXT)
+	OID_NTDS_CA_SECURITY_EXT = "1.3.6.1.4.1.311.25.2"
+	// Other name of the szOID_NTDS_CA_SECURITY_EXT extension holding the SID
+	OID_NTDS_OBJECTSID = "1.3.6.1.4.1.311.25.2.1"
+	// Extension holding the application policies of the certificate (szOID_APPLICATION_CERT_POLICIES)
+	OID_APPLICATION_CERT_POLICIES = "1.3.6.1.4.1.311.21.10"
+	// Subject alternative name extension
+	OID_SUBJECT_ALT_NAME = "2.5.29.17"
+)
+
+// Size in bits of the RSA keys generated for the certificate signing requests
+const DEFAULT_KEY_SIZE = 2048
+
+// CertificateRequestOptions holds the content of a certificate signing request.
+//
+// Fields:
+// - CommonName: The common name of the subject, which may be empty as the certificate authority usually builds the
+// subject from the directory.
+// - UPN: The user principal name put in the subject alternative name, such as "administrator@lab.local".
+// - DNSNames: The DNS names put in the subject alternative name, such as "dc01.lab.local".
+// - SID: The SID put in the szOID_NTDS_CA_SECURITY_EXT extension, such as "S-1-5-21-...-500".
+// - ApplicationPolicies: The OIDs put in the application policies extension, such as the Client Authentication
+// extended key usage "1.3.6.1.5.5.7.3.2".
+// - PrivateKey: The RSA private key of the request. If nil, a key of KeySize bits is generated.
+// - KeySize: The size in bits of the generated key, DEFAULT_KEY_SIZE if zero.
+type CertificateRequestOptions struct {
+	CommonName          string
+	UPN                 string
+	DNSNames            []string
+	SID                 string
+	ApplicationPolicies []string
+	PrivateKey          *rsa.PrivateKey
+	KeySize             int
+}
+
+// CertificateRequest is a PKCS#10 certificate signing request and its private key. The private key must be kept
+// to use the certificate issued for the request, even when the request is pending.
+//
+// Fields:
+// - Raw: The DER encoded certificate signing request.
+// - PrivateKey: The RSA private key of the request.
+type CertificateRequest struct {
+	Raw        []byte
+	PrivateKey *rsa.PrivateKey
+}
+
+// NewCertificateRequest creates a certificate signing request, signed with SHA-256.
+//
+// Parameters:
+// - options: A pointer to the CertificateRequestOptions holding the content of the request.
+//
+// Returns:
+// - A pointer to the CertificateRequest.
+// - An error if the key cannot be generated, if an OID is invalid or if the request cannot be signed.
+//
+// Example:
+//
+//	request, err := adcs.NewCertificateRequest(&adcs.CertificateRequestOptions{
+//	    UPN: "administrator@lab.local",
+//	    SID: "S-1-5-21-1-2-3-500",
+//	})
+func NewCertificateRequest(options *CertificateRequestOptions) (*CertificateRequest, error) {
+	privateKey := options.PrivateKey
+	if privateKey == nil {
+		keySize := options.KeySize
+		if keySize == 0 {
+			keySize = DEFAULT_KEY_SIZE
+		}
+		var err error
+		privateKey, err = rsa.GenerateKey(rand.Reader, keySize)
+		if err != nil {
+			return nil, fmt.Errorf("error generating RSA key: %w", err)
+		}
+	}
+
+	extensions := []pkix.Extension{}
+	if len(options.UPN) != 0 || len(options.DNSNames) != 0 {
+		value, err := marshalSubjectAltName(options.UPN, options.DNSNames)
+		if err != nil {
+			return nil, err
+		}
+		extension, err := newExtension(OID_SUBJECT_ALT_NAME, value)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, extension)
+	}
+	if len(options.SID) != 0 {
+		value, err := marshalSecurityExtension(options.SID)
+		if err != nil {
+			return nil, err
+		}
+		extension, err := newExtension(OID_NTDS_CA_SECURITY_EXT, value)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, extension)
+	}
+	if len(options.ApplicationPolicies) != 0 {
+		value, err := marshalApplicationPolicies(options.ApplicationPolicies)
+		if err != nil {
+			return nil, err
+		}
+		extension, err := newExtension(OID_APPLICATION_CERT_POLICIES, value)
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, extension)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:            pkix.Name{CommonName: options.CommonName},
+		SignatureAlgorithm: x509.SHA256WithRSA,
+		ExtraExtensions:    extensions,
+	}
+	raw, err := x509.CreateCertificateRequest(rand.Reader, template, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating certificate signing request: %w", err)
+	}
+
+	return &CertificateRequest{Raw: raw, PrivateKey: privateKey}, nil
+}
+
+// ToPEM encodes the certificate signing request in a PEM block, as submitted to the web enrollment.
+//
+// Returns:
+// - A byte slice containing the "CERTIFICATE REQUEST" PEM block.
+func (request *CertificateRequest) ToPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: request.Raw})
+}
+
+// marshalSubjectAltName encodes the GeneralNames of a subject alternative name extension.
+func marshalSubjectAltName(upn string, dnsNames []string) ([]byte, error) {
+	names := []asn1.RawValue{}
+	if len(upn) != 0 {
+		value, err := asn1.MarshalWithParams(upn, "utf8")
+		if err != nil {
+			return nil, fmt.Errorf("error encoding UPN: %w", err)
+		}
+		name, err := marshalOtherName(OID_NT_PRINCIPAL_NAME, value)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	for _, dnsName := range dnsNames {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte(dnsName)})
+	}
+	return asn1.Marshal(names)
+}
+
+// marshalSecurityExtension encodes the GeneralNames of the szOID_NTDS_CA_SECURITY_EXT extension, holding the SID
+// as an octet string of its string form.
+func marshalSecurityExtension(SID string) ([]byte, error) {
+	if !strings.HasPrefix(strings.ToUpper(SID), "S-1-") {
+		return nil, fmt.Errorf("invalid SID %s", SID)
+	}
+	value, err := asn1.Marshal([]byte(SID))
+	if err != nil {
+		return nil, fmt.Errorf("error encoding SID: %w", err)
+	}
+	name, err := marshalOtherName(OID_NTDS_OBJECTSID, value)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal([]asn1.RawValue{name})
+}
+
+// marshalApplicationPolicies encodes the application policies extension, a sequence of PolicyInformation holding
+// only their identifiers.
+func marshalApplicationPolicies(policies []string) ([]byte, error) {
+	type policyInformation struct {
+		PolicyIdentifier asn1.ObjectIdentifier
+	}
+	values := []policyInformation{}
+	for _, policy := range policies {
+		oid, err := parseOID(policy)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, policyInformation{PolicyIdentifier: oid})
+	}
+	return asn1.Marshal(values)
+}
+
+// marshalOtherName encodes an otherName GeneralName, whose value is explicitly tagged.
+func marshalOtherName(typeID string, value []byte) (asn1.RawValue, error) {
+	oid, err := parseOID(typeID)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	oidBytes, err := asn1.Marshal(oid)
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("error encoding OID %s: %w", typeID, err)
+	}
+	explicitValue, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: value})
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("error encoding other name: %w", err)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: append(oidBytes, explicitValue...)}, nil
+}
+
+// newExtension creates an extension identified by an OID in its dotted form.
+func newExtension(oid string, value []byte) (pkix.Extension, error) {
+	id, err := parseOID(oid)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: id, Value: value}, nil
+}
+
+// parseOID parses an OID in its dotted form.
+func parseOID(value string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %s", value)
+	}
+	oid := asn1.ObjectIdentifier{}
+	for _, part := range parts {
+		arc, err := strconv.Atoi(part)
+		if err != nil || arc < 0 {
+			return nil, fmt.Errorf("invalid OID %s", value)
+		}
+		oid = append(oid, arc)
+	}
+	return oid, nil
+}
@@ -0,0 +1,80 @@
+package adcs
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/windows/keycredential/crypto"
+)
+
+// Dispositions of the certificate requests
+// Src: [MS-ICPR] 3.2.4.1.1 CertServerRequest (Opnum 0)
+const (
+	CR_DISP_INCOMPLETE         uint32 = 0
+	CR_DISP_ERROR              uint32 = 1
+	CR_DISP_DENIED             uint32 = 2
+	CR_DISP_ISSUED             uint32 = 3
+	CR_DISP_ISSUED_OUT_OF_BAND uint32 = 4
+	CR_DISP_UNDER_SUBMISSION   uint32 = 5
+	CR_DISP_REVOKED            uint32 = 6
+)
+
+// EnrollmentResult is the answer of a certificate authority to a certificate request or to the retrieval of a
+// pending request.
+//
+// Fields:
+// - RequestID: The identifier of the request in the database of the certificate authority, used to retrieve the
+// certificate once a pending request is approved.
+// - Disposition: The disposition of the request, one of the CR_DISP_* constants, or an HRESULT error code such as
+// 0x80094012 (CERTSRV_E_TEMPLATE_DENIED) when the request failed.
+// - DispositionMessage: The message of the certificate authority describing the disposition.
+// - Certificate: The issued certificate along with the private key of the request, nil unless the certificate
+// was issued.
+type EnrollmentResult struct {
+	RequestID          uint32
+	Disposition        uint32
+	DispositionMessage string
+	Certificate        *crypto.X509Certificate
+}
+
+// IsIssued checks if the certificate was issued.
+//
+// Returns:
+// - True if the disposition is CR_DISP_ISSUED or CR_DISP_ISSUED_OUT_OF_BAND, false otherwise.
+func (result *EnrollmentResult) IsIssued() bool {
+	return result.Disposition == CR_DISP_ISSUED || result.Disposition == CR_DISP_ISSUED_OUT_OF_BAND
+}
+
+// IsPending checks if the request awaits the approval of a certificate manager.
+//
+// Returns:
+// - True if the disposition is CR_DISP_UNDER_SUBMISSION, false otherwise.
+func (result *EnrollmentResult) IsPending() bool {
+	return result.Disposition == CR_DISP_UNDER_SUBMISSION
+}
+
+// setCertificate parses the DER encoded certificate issued for a request and pairs it with the private key of
+// the request.
+func (result *EnrollmentResult) setCertificate(der []byte, privateKey *rsa.PrivateKey) error {
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("error parsing issued certificate: %w", err)
+	}
+	if privateKey == nil {
+		return fmt.Errorf("private key of the request is required to use the issued certificate")
+	}
+	result.Certificate, err = crypto.NewX509CertificateFromKeyPair(certificate, privateKey)
+	return err
+}
+
+// formatRequestAttributes joins the attributes of a request, one "name:value" pair per line, after the
+// CertificateTemplate attribute.
+func formatRequestAttributes(template string, attributes []string) string {
+	lines := []string{}
+	if len(template) != 0 {
+		lines = append(lines, "CertificateTemplate:"+template)
+	}
+	return strings.Join(append(lines, attributes...), "\n")
+}
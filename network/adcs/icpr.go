@@ -0,0 +1,191 @@
+package adcs
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/network/dcerpc"
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+)
+
+// ICPRInterface is the ICertPassage interface of the certificate authorities, registered on a dynamic TCP port.
+// Src: [MS-ICPR] 1.9 Standards Assignments
+var ICPRInterface = dcerpc.SyntaxID{UUID: "91ae6020-9e3c-11cf-8d7c-00aa00c091be", MajorVersion: 0}
+
+// Operation number of CertServerRequest
+const opnumCertServerRequest = 0
+
+// Flags of CertServerRequest, submitting a PKCS#10 request in binary form
+const CR_IN_PKCS10 uint32 = 0x00000100
+
+// ICPRClient requests certificates to a certificate authority with the CertServerRequest method of MS-ICPR, over
+// an RPC connection authenticated with NTLM and encrypted, as required by the certificate authorities.
+type ICPRClient struct {
+	// CertificateAuthority is the name of the certificate authority, such as "lab-DC01-CA"
+	CertificateAuthority string
+
+	rpcClient *dcerpc.Client
+}
+
+// NewICPRClient connects to the ICertPassage interface of a certificate authority, on the TCP port given by the
+// endpoint mapper of the server.
+//
+// Parameters:
+// - host: A string representing the hostname or the IP address of the server hosting the certificate authority.
+// - certificateAuthority: A string representing the name of the certificate authority.
+// - creds: A pointer to the credentials, holding a password or an NT hash.
+//
+// Returns:
+// - A pointer to the ICPRClient, which must be closed after use.
+// - An error if the endpoint cannot be resolved, if the connection fails or if the authentication fails.
+func NewICPRClient(host string, certificateAuthority string, creds *credentials.Credentials) (*ICPRClient, error) {
+	ntlmClient, err := ntlm.NewClientWithCredentials(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := dcerpc.MapTCPEndpoint(host, ICPRInterface)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving the ICertPassage endpoint: %w", err)
+	}
+	rpcClient, err := dcerpc.Dial(host, port)
+	if err != nil {
+		return nil, err
+	}
+	if err := rpcClient.BindWithNTLM(ICPRInterface, ntlmClient, dcerpc.RPC_C_AUTHN_LEVEL_PKT_PRIVACY); err != nil {
+		rpcClient.Close()
+		return nil, fmt.Errorf("error binding to the ICertPassage interface: %w", err)
+	}
+
+	return &ICPRClient{CertificateAuthority: certificateAuthority, rpcClient: rpcClient}, nil
+}
+
+// Close closes the connection to the certificate authority.
+//
+// Returns:
+// - An error if the connection cannot be closed.
+func (c *ICPRClient) Close() error {
+	return c.rpcClient.Close()
+}
+
+// RequestCertificate submits a certificate signing request to the certificate authority.
+//
+// Parameters:
+// - request: A pointer to the CertificateRequest, whose private key is paired with the issued certificate.
+// - template: A string representing the name of the certificate template.
+// - attributes: A slice of additional "name:value" request attributes, such as "SAN:upn=administrator@lab.local"
+// for the certificate authorities allowing the requester to set the subject alternative name. May be nil.
+//
+// Returns:
+// - A pointer to the EnrollmentResult, holding the certificate if it was issued, or the request ID to retrieve
+// it later if the request is pending.
+// - An error if the call fails.
+func (c *ICPRClient) RequestCertificate(request *CertificateRequest, template string, attributes []string) (*EnrollmentResult, error) {
+	stub := marshalCertServerRequest(CR_IN_PKCS10, c.CertificateAuthority, 0, formatRequestAttributes(template, attributes), request.Raw)
+	return c.certServerRequest(stub, request.PrivateKey)
+}
+
+// RetrieveCertificate retrieves the certificate issued for a pending request, once approved by a certificate
+// manager.
+//
+// Parameters:
+// - requestID: The identifier of the request returned when it was submitted.
+// - privateKey: A pointer to the RSA private key of the request.
+//
+// Returns:
+// - A pointer to the EnrollmentResult, holding the certificate if it was issued.
+// - An error if the call fails.
+func (c *ICPRClient) RetrieveCertificate(requestID uint32, privateKey *rsa.PrivateKey) (*EnrollmentResult, error) {
+	stub := marshalCertServerRequest(0, c.CertificateAuthority, requestID, "", nil)
+	return c.certServerRequest(stub, privateKey)
+}
+
+// certServerRequest calls CertServerRequest and decodes its result.
+func (c *ICPRClient) certServerRequest(stub []byte, privateKey *rsa.PrivateKey) (*EnrollmentResult, error) {
+	response, err := c.rpcClient.Call(opnumCertServerRequest, stub)
+	if err != nil {
+		return nil, fmt.Errorf("error calling CertServerRequest: %w", err)
+	}
+	return parseCertServerResponse(response, privateKey)
+}
+
+// marshalCertServerRequest encodes the input parameters of CertServerRequest. The attributes are sent as a
+// null-terminated UTF-16 string.
+func marshalCertServerRequest(flags uint32, authority string, requestID uint32, attributes string, request []byte) []byte {
+	w := dcerpc.NewNDRWriter()
+	w.WriteUint32(flags)
+	w.WritePointer()
+	w.WriteWideString(authority)
+	w.WriteUint32(requestID)
+
+	encodedAttributes := []byte{}
+	if len(attributes) != 0 {
+		encodedAttributes = append(utf16.EncodeUTF16LE(attributes), 0, 0)
+	}
+	for _, blob := range [][]byte{encodedAttributes, request} {
+		// CERTTRANSBLOB, whose bytes are deferred right after it
+		w.WriteUint32(uint32(len(blob)))
+		if len(blob) == 0 {
+			w.WriteNullPointer()
+			continue
+		}
+		w.WritePointer()
+		w.WriteConformantBytes(blob)
+	}
+	return w.Bytes()
+}
+
+// parseCertServerResponse decodes the output parameters of CertServerRequest.
+func parseCertServerResponse(response []byte, privateKey *rsa.PrivateKey) (*EnrollmentResult, error) {
+	r := dcerpc.NewNDRReader(response)
+	result := &EnrollmentResult{}
+
+	var err error
+	if result.RequestID, err = r.ReadUint32(); err != nil {
+		return nil, err
+	}
+	if result.Disposition, err = r.ReadUint32(); err != nil {
+		return nil, err
+	}
+
+	// Certificate chain, issued certificate and disposition message
+	blobs := make([][]byte, 3)
+	for i := range blobs {
+		length, err := r.ReadUint32()
+		if err != nil {
+			return nil, err
+		}
+		pointer, err := r.ReadUint32()
+		if err != nil {
+			return nil, err
+		}
+		if pointer == 0 {
+			continue
+		}
+		if blobs[i], err = r.ReadConformantBytes(); err != nil {
+			return nil, err
+		}
+		if len(blobs[i]) != int(length) {
+			return nil, fmt.Errorf("CERTTRANSBLOB of %d bytes holds %d bytes", length, len(blobs[i]))
+		}
+	}
+
+	status, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	if status != 0 {
+		return nil, fmt.Errorf("CertServerRequest failed with status 0x%08x", status)
+	}
+
+	result.DispositionMessage = strings.TrimRight(utf16.DecodeUTF16LE(blobs[2][:len(blobs[2])&^1]), "\x00\r\n")
+	if result.IsIssued() && len(blobs[1]) != 0 {
+		if err := result.setCertificate(blobs[1], privateKey); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
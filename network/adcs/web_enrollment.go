@@ -0,0 +1,343 @@
+package adcs
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm"
+	nettls "github.com/TheManticoreProject/Manticore/network/tls"
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// Timeout of the HTTP requests to the web enrollment
+const DEFAULT_HTTP_TIMEOUT = 30 * time.Second
+
+// Patterns of the pages of the web enrollment
+var (
+	webEnrollmentIssuedPattern      = regexp.MustCompile(`certnew\.cer\?ReqID=([0-9]+)`)
+	webEnrollmentRequestIDPattern   = regexp.MustCompile(`Your Request Id is ([0-9]+)`)
+	webEnrollmentDispositionPattern = regexp.MustCompile(`The disposition message is "([^"]*)"`)
+	webEnrollmentErrorCodePattern   = regexp.MustCompile(`(0x[0-9a-fA-F]{8})`)
+)
+
+// httpDoer sends HTTP requests, such as an http.Client or a spnego.Client.
+type httpDoer interface {
+	Do(request *http.Request) (*http.Response, error)
+}
+
+// WebEnrollmentClient requests certificates to a certificate authority through its web enrollment pages
+// (certsrv), authenticating with NTLM or Kerberos.
+type WebEnrollmentClient struct {
+	// BaseURL is the URL of the web enrollment, such as "https://ca01.lab.local/certsrv"
+	BaseURL string
+
+	httpClient httpDoer
+	transport  *http.Transport
+}
+
+// NewWebEnrollmentClient creates a client of the web enrollment of a server, authenticating with NTLM. Over
+// HTTPS, the authentication is bound to the TLS connection for the servers enforcing Extended Protection for
+// Authentication.
+//
+// Parameters:
+// - host: A string representing the hostname or the IP address of the server hosting the web enrollment.
+// - useTLS: A boolean indicating whether to use HTTPS. The certificate of the server is not verified, unless a
+// TLS configuration is set with SetTLSConfig.
+// - creds: A pointer to the credentials, holding a password or an NT hash.
+//
+// Returns:
+// - A pointer to the WebEnrollmentClient.
+// - An error if the credentials can be used with neither a password nor an NT hash.
+func NewWebEnrollmentClient(host string, useTLS bool, creds *credentials.Credentials) (*WebEnrollmentClient, error) {
+	if _, err := ntlm.NewClientWithCredentials(creds); err != nil {
+		return nil, err
+	}
+
+	transport := newWebEnrollmentTransport()
+	httpClient := &http.Client{
+		Transport: &ntlmTransport{transport: transport, credentials: creds},
+		Timeout:   DEFAULT_HTTP_TIMEOUT,
+	}
+	return &WebEnrollmentClient{BaseURL: webEnrollmentBaseURL(host, useTLS), httpClient: httpClient, transport: transport}, nil
+}
+
+// NewWebEnrollmentClientWithKerberos creates a client of the web enrollment of a server, authenticating with
+// Kerberos for the HTTP/<host> service with SPNEGO.
+//
+// Parameters:
+// - host: A string representing the fully qualified domain name of the server hosting the web enrollment.
+// - useTLS: A boolean indicating whether to use HTTPS. The certificate of the server is not verified, unless a
+// TLS configuration is set with SetTLSConfig.
+// - creds: A pointer to the credentials, holding a password.
+// - krb5Conf: The Kerberos configuration. If nil, the KDCs of the realm of the credentials are discovered with DNS.
+//
+// Returns:
+// - A pointer to the WebEnrollmentClient.
+// - An error if the Kerberos configuration cannot be built.
+func NewWebEnrollmentClientWithKerberos(host string, useTLS bool, creds *credentials.Credentials, krb5Conf *config.Config) (*WebEnrollmentClient, error) {
+	realm := strings.ToUpper(creds.GetDomain())
+	if krb5Conf == nil {
+		var err error
+		krb5Conf, err = kerberos.NewRealmConfig(realm).WithDNSDiscovery(true).Build()
+		if err != nil {
+			return nil, fmt.Errorf("error building Kerberos configuration: %w", err)
+		}
+	}
+
+	kerberosClient := krb5client.NewWithPassword(creds.GetUsername(), realm, creds.GetPassword(), krb5Conf, krb5client.DisablePAFXFAST(true))
+	transport := newWebEnrollmentTransport()
+	httpClient := &http.Client{Transport: transport, Timeout: DEFAULT_HTTP_TIMEOUT}
+
+	return &WebEnrollmentClient{
+		BaseURL:    webEnrollmentBaseURL(host, useTLS),
+		httpClient: spnego.NewClient(kerberosClient, httpClient, "HTTP/"+host),
+		transport:  transport,
+	}, nil
+}
+
+// SetTLSConfig sets the TLS configuration of the HTTPS connections to the web enrollment.
+//
+// Parameters:
+// - tlsConfig: The TLS configuration, such as one built with ldap.NewTLSConfig to verify the certificate of the
+// server. If nil, the certificate of the server is not verified.
+//
+// Note:
+// The configuration is used by the connections opened after the call. When its ServerName is empty, the
+// certificate of the server is verified against the host of BaseURL.
+func (c *WebEnrollmentClient) SetTLSConfig(tlsConfig *tls.Config) {
+	c.transport.CloseIdleConnections()
+	if tlsConfig == nil {
+		c.transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		return
+	}
+	c.transport.TLSClientConfig = tlsConfig.Clone()
+}
+
+// RequestCertificate submits a certificate signing request to the web enrollment, then downloads the issued
+// certificate.
+//
+// Parameters:
+// - request: A pointer to the CertificateRequest, whose private key is paired with the issued certificate.
+// - template: A string representing the name of the certificate template.
+// - attributes: A slice of additional "name:value" request attributes, such as "SAN:upn=administrator@lab.local".
+// May be nil.
+//
+// Returns:
+// - A pointer to the EnrollmentResult, holding the certificate if it was issued, or the request ID to retrieve
+// it later if the request is pending.
+// - An error if the HTTP requests fail or if the answer of the web enrollment is not recognized.
+func (c *WebEnrollmentClient) RequestCertificate(request *CertificateRequest, template string, attributes []string) (*EnrollmentResult, error) {
+	form := url.Values{
+		"Mode":             {"newreq"},
+		"CertRequest":      {string(request.ToPEM())},
+		"CertAttrib":       {formatRequestAttributes(template, attributes)},
+		"TargetStoreFlags": {"0"},
+		"SaveCert":         {"yes"},
+		"ThumbPrint":       {""},
+	}
+	body, err := c.send(http.MethodPost, "/certfnsh.asp", []byte(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	if match := webEnrollmentIssuedPattern.FindSubmatch(body); match != nil {
+		requestID, _ := strconv.ParseUint(string(match[1]), 10, 32)
+		return c.RetrieveCertificate(uint32(requestID), request.PrivateKey)
+	}
+	return parseWebEnrollmentDisposition(body)
+}
+
+// RetrieveCertificate downloads the certificate issued for a request, once approved by a certificate manager.
+//
+// Parameters:
+// - requestID: The identifier of the request returned when it was submitted.
+// - privateKey: A pointer to the RSA private key of the request.
+//
+// Returns:
+// - A pointer to the EnrollmentResult, holding the certificate if it was issued.
+// - An error if the HTTP request fails or if the answer of the web enrollment is not recognized.
+func (c *WebEnrollmentClient) RetrieveCertificate(requestID uint32, privateKey *rsa.PrivateKey) (*EnrollmentResult, error) {
+	body, err := c.send(http.MethodGet, fmt.Sprintf("/certnew.cer?ReqID=%d&Enc=b64", requestID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil || block.Type != "CERTIFICATE" {
+		result, err := parseWebEnrollmentDisposition(body)
+		if err != nil {
+			return nil, err
+		}
+		result.RequestID = requestID
+		return result, nil
+	}
+
+	result := &EnrollmentResult{RequestID: requestID, Disposition: CR_DISP_ISSUED, DispositionMessage: "Issued"}
+	if err := result.setCertificate(block.Bytes, privateKey); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// send sends a request to a page of the web enrollment and returns the body of the answer.
+func (c *WebEnrollmentClient) send(method string, path string, body []byte) ([]byte, error) {
+	request, err := http.NewRequest(method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating HTTP request: %w", err)
+	}
+	if method == http.MethodPost {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error sending HTTP request to %s: %w", request.URL, err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HTTP response: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("web enrollment answered %s to %s", response.Status, request.URL)
+	}
+	return data, nil
+}
+
+// parseWebEnrollmentDisposition decodes the page of the web enrollment describing a request which was not issued.
+func parseWebEnrollmentDisposition(body []byte) (*EnrollmentResult, error) {
+	result := &EnrollmentResult{}
+	if match := webEnrollmentRequestIDPattern.FindSubmatch(body); match != nil {
+		requestID, _ := strconv.ParseUint(string(match[1]), 10, 32)
+		result.RequestID = uint32(requestID)
+	}
+	if match := webEnrollmentDispositionPattern.FindSubmatch(body); match != nil {
+		result.DispositionMessage = strings.TrimSpace(string(match[1]))
+	}
+
+	switch {
+	case bytes.Contains(body, []byte("Certificate Pending")):
+		result.Disposition = CR_DISP_UNDER_SUBMISSION
+	case bytes.Contains(body, []byte("Certificate Request Denied")) || bytes.Contains(body, []byte("was denied")):
+		result.Disposition = CR_DISP_DENIED
+		if match := webEnrollmentErrorCodePattern.FindSubmatch(body); match != nil {
+			errorCode, _ := strconv.ParseUint(string(match[1][2:]), 16, 32)
+			result.Disposition = uint32(errorCode)
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized answer of the web enrollment")
+	}
+	return result, nil
+}
+
+// webEnrollmentBaseURL returns the URL of the web enrollment of a server.
+func webEnrollmentBaseURL(host string, useTLS bool) string {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/certsrv", scheme, host)
+}
+
+// newWebEnrollmentTransport creates the transport of the web enrollment clients. A single connection is kept
+// alive per server, as NTLM authenticates the connection rather than the requests.
+func newWebEnrollmentTransport() *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		MaxConnsPerHost: 1,
+		// The authentication is not supported over HTTP/2 by IIS
+		TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},
+	}
+}
+
+// ntlmTransport is an http.RoundTripper authenticating the requests with NTLM, through the challenge-response
+// exchange of the "WWW-Authenticate: NTLM" scheme.
+type ntlmTransport struct {
+	transport   http.RoundTripper
+	credentials *credentials.Credentials
+}
+
+// RoundTrip sends a request with an NTLM NEGOTIATE message, and sends it again with the AUTHENTICATE message
+// answering the CHALLENGE message of the server over the same connection.
+func (t *ntlmTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body := []byte{}
+	if request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(request.Body); err != nil {
+			return nil, err
+		}
+		request.Body.Close()
+	}
+
+	ntlmClient, err := ntlm.NewClientWithCredentials(t.credentials)
+	if err != nil {
+		return nil, err
+	}
+	negotiateMessage, err := ntlmClient.Negotiate()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := t.transport.RoundTrip(cloneRequestWithAuthorization(request, body, negotiateMessage))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusUnauthorized {
+		return response, nil
+	}
+
+	var challengeMessage []byte
+	for _, value := range response.Header.Values("WWW-Authenticate") {
+		if strings.HasPrefix(value, "NTLM ") {
+			challengeMessage, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "NTLM "))
+			if err != nil {
+				response.Body.Close()
+				return nil, fmt.Errorf("invalid NTLM CHALLENGE message: %w", err)
+			}
+		}
+	}
+	if challengeMessage == nil {
+		return response, nil
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	if response.TLS != nil {
+		applicationData, err := nettls.GetTLSServerEndPoint(*response.TLS)
+		if err != nil {
+			return nil, err
+		}
+		ntlmClient.ChannelBindings = nettls.GetChannelBindingsHash(applicationData)
+	}
+	authenticateMessage, err := ntlmClient.Authenticate(challengeMessage)
+	if err != nil {
+		return nil, fmt.Errorf("error creating NTLM AUTHENTICATE message: %w", err)
+	}
+
+	return t.transport.RoundTrip(cloneRequestWithAuthorization(request, body, authenticateMessage))
+}
+
+// cloneRequestWithAuthorization clones a request with its body and an NTLM message in its Authorization header.
+func cloneRequestWithAuthorization(request *http.Request, body []byte, message []byte) *http.Request {
+	clone := request.Clone(request.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.ContentLength = int64(len(body))
+	clone.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(message))
+	return clone
+}
@@ -0,0 +1,384 @@
+package dcerpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm"
+)
+
+// Timeout of the TCP connections to the RPC servers
+const DEFAULT_DIAL_TIMEOUT = 10 * time.Second
+
+// Identifier of the security context of the authenticated bindings
+const defaultAuthContextID uint32 = 79231
+
+// Size of the body of the request and response PDUs before the stub data
+const requestHeaderSize = 8
+
+// FaultError is the error returned when the server answers a call with a fault PDU.
+//
+// Fields:
+// - Status: The status code of the fault, such as 0x00000005 (access denied) or 0x1c010002 (nca_s_op_rng_error).
+type FaultError struct {
+	Status uint32
+}
+
+// Error returns the status code of the fault.
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("RPC fault with status 0x%08x", e.Status)
+}
+
+// Client is a connection-oriented DCE/RPC client bound to one interface, over a TCP connection (ncacn_ip_tcp).
+// Once bound with NTLM, the calls are signed or sealed according to the authentication level.
+type Client struct {
+	conn        net.Conn
+	callID      uint32
+	contextID   uint16
+	maxXmitFrag uint16
+
+	ntlmClient *ntlm.Client
+	authLevel  uint8
+}
+
+// Dial connects to an RPC server over TCP.
+//
+// Parameters:
+// - host: A string representing the hostname or the IP address of the server.
+// - port: An integer representing the TCP port of the server, such as 135 for the endpoint mapper.
+//
+// Returns:
+// - A pointer to the Client, which must be bound to an interface before calling it.
+// - An error if the connection fails.
+func Dial(host string, port int) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), DEFAULT_DIAL_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s:%d: %w", host, port, err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates an RPC client over an established connection.
+//
+// Parameters:
+// - conn: The connection to the RPC server.
+//
+// Returns:
+// - A pointer to the Client, which must be bound to an interface before calling it.
+func NewClient(conn net.Conn) *Client {
+	return &Client{
+		conn:        conn,
+		maxXmitFrag: DEFAULT_MAX_FRAGMENT_SIZE,
+	}
+}
+
+// Close closes the connection to the RPC server.
+//
+// Returns:
+// - An error if the connection cannot be closed.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Bind binds the client to an interface without authentication.
+//
+// Parameters:
+// - abstractSyntax: The SyntaxID of the interface.
+//
+// Returns:
+// - An error if the bind fails or if the server rejects the interface.
+func (c *Client) Bind(abstractSyntax SyntaxID) error {
+	body, err := marshalBind(c.contextID, abstractSyntax)
+	if err != nil {
+		return err
+	}
+
+	c.callID++
+	if err := c.writePDU(PTYPE_BIND, PFC_FIRST_FRAG|PFC_LAST_FRAG, body, nil, nil); err != nil {
+		return err
+	}
+
+	_, ackBody, _, err := c.readBindAck()
+	if err != nil {
+		return err
+	}
+	c.maxXmitFrag, err = parseBindAck(ackBody)
+	return err
+}
+
+// BindWithNTLM binds the client to an interface with an NTLM authentication, completed by an AUTH3 PDU.
+//
+// Parameters:
+// - abstractSyntax: The SyntaxID of the interface.
+// - ntlmClient: A pointer to the NTLM client holding the credentials, which then protects the calls.
+// - authLevel: The authentication level of the calls, RPC_C_AUTHN_LEVEL_PKT_INTEGRITY to sign them or
+// RPC_C_AUTHN_LEVEL_PKT_PRIVACY to sign and encrypt them.
+//
+// Returns:
+// - An error if the bind or the authentication fails, or if the server rejects the interface.
+func (c *Client) BindWithNTLM(abstractSyntax SyntaxID, ntlmClient *ntlm.Client, authLevel uint8) error {
+	if authLevel != RPC_C_AUTHN_LEVEL_PKT_INTEGRITY && authLevel != RPC_C_AUTHN_LEVEL_PKT_PRIVACY {
+		return fmt.Errorf("unsupported authentication level %d", authLevel)
+	}
+
+	body, err := marshalBind(c.contextID, abstractSyntax)
+	if err != nil {
+		return err
+	}
+	negotiateMessage, err := ntlmClient.Negotiate()
+	if err != nil {
+		return fmt.Errorf("error creating NTLM NEGOTIATE message: %w", err)
+	}
+	trailer := &SecTrailer{AuthType: RPC_C_AUTHN_WINNT, AuthLevel: authLevel, AuthContextID: defaultAuthContextID}
+
+	c.callID++
+	if err := c.writePDU(PTYPE_BIND, PFC_FIRST_FRAG|PFC_LAST_FRAG, body, trailer, negotiateMessage); err != nil {
+		return err
+	}
+
+	_, ackBody, challengeMessage, err := c.readBindAck()
+	if err != nil {
+		return err
+	}
+	if c.maxXmitFrag, err = parseBindAck(ackBody); err != nil {
+		return err
+	}
+	if len(challengeMessage) == 0 {
+		return fmt.Errorf("bind_ack holds no NTLM CHALLENGE message")
+	}
+
+	authenticateMessage, err := ntlmClient.Authenticate(challengeMessage)
+	if err != nil {
+		return fmt.Errorf("error creating NTLM AUTHENTICATE message: %w", err)
+	}
+	if err := c.writePDU(PTYPE_AUTH3, PFC_FIRST_FRAG|PFC_LAST_FRAG, make([]byte, 4), trailer, authenticateMessage); err != nil {
+		return err
+	}
+
+	c.ntlmClient = ntlmClient
+	c.authLevel = authLevel
+	return nil
+}
+
+// Call invokes an operation of the bound interface. The stub data is split in fragments if it does not fit in
+// one, and the fragments of the response are reassembled.
+//
+// Parameters:
+// - opnum: The number of the operation.
+// - stub: A byte slice containing the NDR encoded input parameters.
+//
+// Returns:
+// - A byte slice containing the NDR encoded output parameters.
+// - A *FaultError if the server answers with a fault, or another error if the exchange fails.
+func (c *Client) Call(opnum uint16, stub []byte) ([]byte, error) {
+	c.callID++
+
+	maxStubSize := int(c.maxXmitFrag) - PDU_HEADER_SIZE - requestHeaderSize
+	if c.ntlmClient != nil {
+		maxStubSize -= SEC_TRAILER_SIZE + NTLM_VERIFIER_LEN + 15
+		maxStubSize -= maxStubSize % 16
+	}
+
+	offset := 0
+	for {
+		end := min(offset+maxStubSize, len(stub))
+		flags := uint8(0)
+		if offset == 0 {
+			flags |= PFC_FIRST_FRAG
+		}
+		if end == len(stub) {
+			flags |= PFC_LAST_FRAG
+		}
+
+		body := binary.LittleEndian.AppendUint32(nil, uint32(len(stub)-offset))
+		body = binary.LittleEndian.AppendUint16(body, c.contextID)
+		body = binary.LittleEndian.AppendUint16(body, opnum)
+		if err := c.writeProtectedPDU(PTYPE_REQUEST, flags, body, stub[offset:end]); err != nil {
+			return nil, err
+		}
+
+		offset = end
+		if flags&PFC_LAST_FRAG != 0 {
+			break
+		}
+	}
+
+	response := []byte{}
+	for {
+		header, pdu, err := c.readPDU()
+		if err != nil {
+			return nil, err
+		}
+		if header.CallID != c.callID {
+			return nil, fmt.Errorf("unexpected call ID %d, expected %d", header.CallID, c.callID)
+		}
+
+		switch header.Type {
+		case PTYPE_FAULT:
+			if len(pdu) < PDU_HEADER_SIZE+requestHeaderSize+4 {
+				return nil, fmt.Errorf("fault PDU is too short")
+			}
+			return nil, &FaultError{Status: binary.LittleEndian.Uint32(pdu[PDU_HEADER_SIZE+requestHeaderSize:])}
+		case PTYPE_RESPONSE:
+			fragment, err := c.unprotectPDU(header, pdu)
+			if err != nil {
+				return nil, err
+			}
+			response = append(response, fragment...)
+			if header.Flags&PFC_LAST_FRAG != 0 {
+				return response, nil
+			}
+		default:
+			return nil, fmt.Errorf("unexpected PDU type %d", header.Type)
+		}
+	}
+}
+
+// writePDU sends a PDU, with a sec_trailer and an authentication value if trailer is not nil.
+func (c *Client) writePDU(pduType uint8, flags uint8, body []byte, trailer *SecTrailer, authValue []byte) error {
+	header := &Header{Type: pduType, Flags: flags, CallID: c.callID}
+	data := body
+	if trailer != nil {
+		data = append(append(append([]byte{}, body...), trailer.Marshal()...), authValue...)
+		header.AuthLength = uint16(len(authValue))
+	}
+	header.FragLength = uint16(PDU_HEADER_SIZE + len(data))
+
+	if _, err := c.conn.Write(append(header.Marshal(), data...)); err != nil {
+		return fmt.Errorf("error sending PDU: %w", err)
+	}
+	return nil
+}
+
+// writeProtectedPDU sends a request PDU, signing it or sealing its stub data according to the authentication level.
+func (c *Client) writeProtectedPDU(pduType uint8, flags uint8, body []byte, stub []byte) error {
+	if c.ntlmClient == nil {
+		return c.writePDU(pduType, flags, append(append([]byte{}, body...), stub...), nil, nil)
+	}
+
+	// The sec_trailer is aligned on 16 bytes from the start of the PDU, the padding being sealed with the stub data
+	padLength := (16 - (PDU_HEADER_SIZE+len(body)+len(stub))%16) % 16
+	trailer := &SecTrailer{AuthType: RPC_C_AUTHN_WINNT, AuthLevel: c.authLevel, AuthPadLength: uint8(padLength), AuthContextID: defaultAuthContextID}
+
+	header := &Header{Type: pduType, Flags: flags, CallID: c.callID, AuthLength: NTLM_VERIFIER_LEN}
+	header.FragLength = uint16(PDU_HEADER_SIZE + len(body) + len(stub) + padLength + SEC_TRAILER_SIZE + NTLM_VERIFIER_LEN)
+
+	pdu := header.Marshal()
+	pdu = append(pdu, body...)
+	pdu = append(pdu, stub...)
+	pdu = append(pdu, make([]byte, padLength)...)
+	pdu = append(pdu, trailer.Marshal()...)
+
+	var signature []byte
+	var err error
+	if c.authLevel == RPC_C_AUTHN_LEVEL_PKT_PRIVACY {
+		signature, err = c.ntlmClient.SealRange(pdu, PDU_HEADER_SIZE+len(body), len(stub)+padLength)
+	} else {
+		signature, err = c.ntlmClient.Sign(pdu)
+	}
+	if err != nil {
+		return fmt.Errorf("error protecting PDU: %w", err)
+	}
+
+	if _, err := c.conn.Write(append(pdu, signature...)); err != nil {
+		return fmt.Errorf("error sending PDU: %w", err)
+	}
+	return nil
+}
+
+// unprotectPDU returns the stub data of a response PDU, after checking its signature or unsealing it according
+// to the authentication level.
+func (c *Client) unprotectPDU(header *Header, pdu []byte) ([]byte, error) {
+	stubOffset := PDU_HEADER_SIZE + requestHeaderSize
+	if c.ntlmClient == nil || header.AuthLength == 0 {
+		if c.ntlmClient != nil {
+			return nil, fmt.Errorf("response PDU is not authenticated")
+		}
+		if len(pdu) < stubOffset {
+			return nil, fmt.Errorf("response PDU is too short")
+		}
+		return pdu[stubOffset:], nil
+	}
+
+	trailerOffset := len(pdu) - int(header.AuthLength) - SEC_TRAILER_SIZE
+	if trailerOffset < stubOffset || header.AuthLength != NTLM_VERIFIER_LEN {
+		return nil, fmt.Errorf("invalid authentication verifier of %d bytes", header.AuthLength)
+	}
+	trailer := &SecTrailer{}
+	if err := trailer.Unmarshal(pdu[trailerOffset:]); err != nil {
+		return nil, err
+	}
+	if int(trailer.AuthPadLength) > trailerOffset-stubOffset {
+		return nil, fmt.Errorf("invalid padding of %d bytes", trailer.AuthPadLength)
+	}
+
+	signed := pdu[:len(pdu)-NTLM_VERIFIER_LEN]
+	signature := pdu[len(pdu)-NTLM_VERIFIER_LEN:]
+	var err error
+	if c.authLevel == RPC_C_AUTHN_LEVEL_PKT_PRIVACY {
+		err = c.ntlmClient.UnsealRange(signed, stubOffset, trailerOffset-stubOffset, signature)
+	} else {
+		err = c.ntlmClient.Verify(signed, signature)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error checking response PDU: %w", err)
+	}
+
+	return pdu[stubOffset : trailerOffset-int(trailer.AuthPadLength)], nil
+}
+
+// readPDU reads a whole fragment from the connection.
+func (c *Client) readPDU() (*Header, []byte, error) {
+	pdu := make([]byte, PDU_HEADER_SIZE)
+	if _, err := io.ReadFull(c.conn, pdu); err != nil {
+		return nil, nil, fmt.Errorf("error reading PDU header: %w", err)
+	}
+	header := &Header{}
+	if err := header.Unmarshal(pdu); err != nil {
+		return nil, nil, err
+	}
+	if header.FragLength < PDU_HEADER_SIZE {
+		return nil, nil, fmt.Errorf("invalid fragment length %d", header.FragLength)
+	}
+
+	pdu = append(pdu, make([]byte, int(header.FragLength)-PDU_HEADER_SIZE)...)
+	if _, err := io.ReadFull(c.conn, pdu[PDU_HEADER_SIZE:]); err != nil {
+		return nil, nil, fmt.Errorf("error reading PDU: %w", err)
+	}
+	return header, pdu, nil
+}
+
+// readBindAck reads the answer to a bind PDU, returning its body and its authentication value.
+func (c *Client) readBindAck() (*Header, []byte, []byte, error) {
+	header, pdu, err := c.readPDU()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	switch header.Type {
+	case PTYPE_BIND_ACK:
+	case PTYPE_BIND_NAK:
+		reason := uint16(0)
+		if len(pdu) >= PDU_HEADER_SIZE+2 {
+			reason = binary.LittleEndian.Uint16(pdu[PDU_HEADER_SIZE:])
+		}
+		return nil, nil, nil, fmt.Errorf("bind rejected by the server (reason %d)", reason)
+	default:
+		return nil, nil, nil, fmt.Errorf("unexpected PDU type %d in answer to bind", header.Type)
+	}
+
+	body := pdu[PDU_HEADER_SIZE:]
+	var authValue []byte
+	if header.AuthLength != 0 {
+		authOffset := len(pdu) - int(header.AuthLength)
+		if authOffset-SEC_TRAILER_SIZE < PDU_HEADER_SIZE {
+			return nil, nil, nil, fmt.Errorf("invalid authentication value of %d bytes", header.AuthLength)
+		}
+		authValue = pdu[authOffset:]
+		body = pdu[PDU_HEADER_SIZE : authOffset-SEC_TRAILER_SIZE]
+	}
+	return header, body, authValue, nil
+}
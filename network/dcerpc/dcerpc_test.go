@@ -0,0 +1,137 @@
+package dcerpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+// serveOneCall answers a bind and a call on a connection, the response being fragmented in two PDUs.
+func serveOneCall(t *testing.T, conn net.Conn, response []byte, fault uint32) {
+	t.Helper()
+	client := NewClient(conn)
+
+	header, pdu, err := client.readPDU()
+	if err != nil || header.Type != PTYPE_BIND {
+		t.Errorf("Expected a bind PDU, got %v, %v", header, err)
+		return
+	}
+	ack := []byte{0xb8, 0x10, 0xb8, 0x10, 1, 0, 0, 0, 4, 0, '1', '3', '5', 0}
+	ack = append(ack, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0)
+	ack = append(ack, make([]byte, 20)...)
+	client.callID = header.CallID
+	if err := client.writePDU(PTYPE_BIND_ACK, PFC_FIRST_FRAG|PFC_LAST_FRAG, ack, nil, nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	stub := []byte{}
+	for {
+		header, pdu, err = client.readPDU()
+		if err != nil || header.Type != PTYPE_REQUEST {
+			t.Errorf("Expected a request PDU, got %v, %v", header, err)
+			return
+		}
+		stub = append(stub, pdu[PDU_HEADER_SIZE+requestHeaderSize:]...)
+		if header.Flags&PFC_LAST_FRAG != 0 {
+			break
+		}
+	}
+	client.callID = header.CallID
+	if !bytes.Equal(stub, bytes.Repeat([]byte{0xaa}, 10000)) {
+		t.Errorf("Unexpected reassembled stub of %d bytes", len(stub))
+	}
+
+	if fault != 0 {
+		body := append(make([]byte, requestHeaderSize), binary.LittleEndian.AppendUint32(nil, fault)...)
+		client.writePDU(PTYPE_FAULT, PFC_FIRST_FRAG|PFC_LAST_FRAG, body, nil, nil)
+		return
+	}
+	client.writePDU(PTYPE_RESPONSE, PFC_FIRST_FRAG, append(make([]byte, requestHeaderSize), response[:3]...), nil, nil)
+	client.writePDU(PTYPE_RESPONSE, PFC_LAST_FRAG, append(make([]byte, requestHeaderSize), response[3:]...), nil, nil)
+}
+
+func TestClientBindAndCall(t *testing.T) {
+	for _, fault := range []uint32{0, 0x1c010002} {
+		clientConn, serverConn := net.Pipe()
+		go serveOneCall(t, serverConn, []byte("response stub"), fault)
+
+		client := NewClient(clientConn)
+		if err := client.Bind(EPMInterface); err != nil {
+			t.Fatalf("Bind() error = %v", err)
+		}
+		if client.maxXmitFrag != 4280 {
+			t.Errorf("Expected a maximum fragment size of 4280, got %d", client.maxXmitFrag)
+		}
+
+		response, err := client.Call(opnumEptMap, bytes.Repeat([]byte{0xaa}, 10000))
+		var faultError *FaultError
+		if fault != 0 {
+			if !errors.As(err, &faultError) || faultError.Status != fault {
+				t.Errorf("Expected a fault 0x%08x, got %v", fault, err)
+			}
+		} else if err != nil || string(response) != "response stub" {
+			t.Errorf("Call() = %q, %v", response, err)
+		}
+		client.Close()
+	}
+}
+
+func TestTCPTower(t *testing.T) {
+	tower, err := marshalTCPTower(SyntaxID{UUID: "91ae6020-9e3c-11cf-8d7c-00aa00c091be"})
+	if err != nil {
+		t.Fatalf("marshalTCPTower() error = %v", err)
+	}
+	if len(tower) != 75 || binary.LittleEndian.Uint16(tower) != 5 {
+		t.Fatalf("Unexpected tower %x", tower)
+	}
+	if _, err := parseTCPTowerPort(tower); err != nil {
+		t.Errorf("parseTCPTowerPort() error = %v", err)
+	}
+
+	// Tower returned with port 49668
+	binary.BigEndian.PutUint16(tower[64:], 49668)
+	w := NewNDRWriter()
+	w.WriteBytes(make([]byte, 20))
+	w.WriteUint32(1)
+	w.WriteUint32(1)
+	w.WriteUint32(0)
+	w.WriteUint32(1)
+	w.WritePointer()
+	w.WriteUint32(uint32(len(tower)))
+	w.WriteConformantBytes(tower)
+	w.Align(4)
+	w.WriteUint32(0)
+
+	towers, status, err := parseEptMapResponse(w.Bytes())
+	if err != nil || status != 0 || len(towers) != 1 {
+		t.Fatalf("parseEptMapResponse() = %d towers, 0x%08x, %v", len(towers), status, err)
+	}
+	if port, err := parseTCPTowerPort(towers[0]); err != nil || port != 49668 {
+		t.Errorf("parseTCPTowerPort() = %d, %v; want 49668", port, err)
+	}
+}
+
+func TestNDRWriterAndReader(t *testing.T) {
+	w := NewNDRWriter()
+	w.WriteBytes([]byte{1})
+	w.WriteUint32(0x11223344)
+	w.WriteWideString("CA")
+	w.WriteUint16(7)
+
+	expected := []byte{1, 0, 0, 0, 0x44, 0x33, 0x22, 0x11, 3, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 'C', 0, 'A', 0, 0, 0, 7, 0}
+	if !bytes.Equal(w.Bytes(), expected) {
+		t.Errorf("Unexpected NDR data %x", w.Bytes())
+	}
+
+	r := NewNDRReader(expected)
+	r.ReadBytes(1)
+	if value, err := r.ReadUint32(); err != nil || value != 0x11223344 {
+		t.Errorf("ReadUint32() = %x, %v", value, err)
+	}
+	if _, err := r.ReadBytes(100); err == nil {
+		t.Error("Expected an error when reading past the end of the data")
+	}
+}
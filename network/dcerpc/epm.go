@@ -0,0 +1,207 @@
+package dcerpc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// TCP port of the endpoint mapper
+const EPM_PORT = 135
+
+// EPMInterface is the interface of the endpoint mapper, resolving the dynamic endpoints of the other interfaces.
+// Src: https://pubs.opengroup.org/onlinepubs/9629399/apdxo.htm
+var EPMInterface = SyntaxID{UUID: "e1af8308-5d1f-11c9-91a4-08002b14a0fa", MajorVersion: 3}
+
+// Operation number of ept_map
+const opnumEptMap = 3
+
+// Protocol identifiers of the floors of a tower
+const (
+	towerProtocolUUID  = 0x0d
+	towerProtocolNCACN = 0x0b
+	towerProtocolTCP   = 0x07
+	towerProtocolIP    = 0x09
+)
+
+// Status codes of ept_map
+const (
+	eptMapStatusSuccess       uint32 = 0
+	eptMapStatusNotRegistered uint32 = 0x16c9a0d6
+)
+
+// MapTCPEndpoint asks the endpoint mapper of a server for the TCP port of an interface (ncacn_ip_tcp).
+//
+// Parameters:
+// - host: A string representing the hostname or the IP address of the server.
+// - abstractSyntax: The SyntaxID of the interface.
+//
+// Returns:
+// - The TCP port on which the interface is registered.
+// - An error if the endpoint mapper cannot be reached or if the interface is not registered.
+func MapTCPEndpoint(host string, abstractSyntax SyntaxID) (int, error) {
+	client, err := Dial(host, EPM_PORT)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	if err := client.Bind(EPMInterface); err != nil {
+		return 0, fmt.Errorf("error binding to the endpoint mapper: %w", err)
+	}
+
+	tower, err := marshalTCPTower(abstractSyntax)
+	if err != nil {
+		return 0, err
+	}
+
+	w := NewNDRWriter()
+	// No object UUID
+	w.WriteNullPointer()
+	w.WritePointer()
+	w.WriteUint32(uint32(len(tower)))
+	w.WriteUint32(uint32(len(tower)))
+	w.WriteBytes(tower)
+	w.Align(4)
+	// Empty entry handle
+	w.WriteBytes(make([]byte, 20))
+	// One tower at most
+	w.WriteUint32(1)
+
+	response, err := client.Call(opnumEptMap, w.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("error calling ept_map: %w", err)
+	}
+
+	towers, status, err := parseEptMapResponse(response)
+	if err != nil {
+		return 0, err
+	}
+	if status == eptMapStatusNotRegistered || (status == eptMapStatusSuccess && len(towers) == 0) {
+		return 0, fmt.Errorf("interface %s v%d.%d is not registered", abstractSyntax.UUID, abstractSyntax.MajorVersion, abstractSyntax.MinorVersion)
+	}
+	if status != eptMapStatusSuccess {
+		return 0, fmt.Errorf("ept_map failed with status 0x%08x", status)
+	}
+
+	for _, tower := range towers {
+		if port, err := parseTCPTowerPort(tower); err == nil {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no TCP endpoint returned for interface %s", abstractSyntax.UUID)
+}
+
+// marshalTCPTower encodes the tower of an interface over ncacn_ip_tcp, with unspecified port and address.
+func marshalTCPTower(abstractSyntax SyntaxID) ([]byte, error) {
+	abstract, err := abstractSyntax.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	transfer, err := NDRTransferSyntax.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	floors := [][2][]byte{
+		// The UUID and major version are on the left-hand side, the minor version on the right-hand side
+		{append([]byte{towerProtocolUUID}, abstract[:18]...), abstract[18:20]},
+		{append([]byte{towerProtocolUUID}, transfer[:18]...), transfer[18:20]},
+		{{towerProtocolNCACN}, {0, 0}},
+		{{towerProtocolTCP}, {0, 0}},
+		{{towerProtocolIP}, {0, 0, 0, 0}},
+	}
+
+	data := binary.LittleEndian.AppendUint16(nil, uint16(len(floors)))
+	for _, floor := range floors {
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(floor[0])))
+		data = append(data, floor[0]...)
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(floor[1])))
+		data = append(data, floor[1]...)
+	}
+	return data, nil
+}
+
+// parseEptMapResponse decodes the towers and the status returned by ept_map.
+func parseEptMapResponse(response []byte) ([][]byte, uint32, error) {
+	r := NewNDRReader(response)
+	if _, err := r.ReadBytes(20); err != nil {
+		return nil, 0, err
+	}
+	numTowers, err := r.ReadUint32()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Conformant varying array of pointers to the towers
+	if _, err := r.ReadUint32(); err != nil {
+		return nil, 0, err
+	}
+	if _, err := r.ReadUint32(); err != nil {
+		return nil, 0, err
+	}
+	actualCount, err := r.ReadUint32()
+	if err != nil {
+		return nil, 0, err
+	}
+	if actualCount > numTowers {
+		return nil, 0, fmt.Errorf("ept_map returned %d towers out of %d", actualCount, numTowers)
+	}
+
+	referentIDs := make([]uint32, actualCount)
+	for i := range referentIDs {
+		if referentIDs[i], err = r.ReadUint32(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	towers := [][]byte{}
+	for _, referentID := range referentIDs {
+		if referentID == 0 {
+			continue
+		}
+		if _, err := r.ReadUint32(); err != nil {
+			return nil, 0, err
+		}
+		tower, err := r.ReadConformantBytes()
+		if err != nil {
+			return nil, 0, err
+		}
+		towers = append(towers, tower)
+	}
+
+	status, err := r.ReadUint32()
+	if err != nil {
+		return nil, 0, err
+	}
+	return towers, status, nil
+}
+
+// parseTCPTowerPort returns the TCP port of the TCP floor of a tower.
+func parseTCPTowerPort(tower []byte) (int, error) {
+	if len(tower) < 2 {
+		return 0, fmt.Errorf("tower is too short")
+	}
+	floorCount := int(binary.LittleEndian.Uint16(tower))
+	offset := 2
+	for i := 0; i < floorCount; i++ {
+		if len(tower) < offset+2 {
+			return 0, fmt.Errorf("tower is too short")
+		}
+		lhsLength := int(binary.LittleEndian.Uint16(tower[offset:]))
+		lhsOffset := offset + 2
+		if len(tower) < lhsOffset+lhsLength+2 {
+			return 0, fmt.Errorf("tower is too short")
+		}
+		rhsLength := int(binary.LittleEndian.Uint16(tower[lhsOffset+lhsLength:]))
+		rhsOffset := lhsOffset + lhsLength + 2
+		if len(tower) < rhsOffset+rhsLength {
+			return 0, fmt.Errorf("tower is too short")
+		}
+
+		if lhsLength == 1 && tower[lhsOffset] == towerProtocolTCP && rhsLength == 2 {
+			return int(binary.BigEndian.Uint16(tower[rhsOffset:])), nil
+		}
+		offset = rhsOffset + rhsLength
+	}
+	return 0, fmt.Errorf("tower has no TCP floor")
+}
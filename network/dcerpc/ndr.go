@@ -0,0 +1,190 @@
+package dcerpc
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+)
+
+// NDRWriter marshals the stub data of a call with the NDR transfer syntax, in little-endian.
+// Src: https://pubs.opengroup.org/onlinepubs/9629399/chap14.htm
+type NDRWriter struct {
+	data       []byte
+	referentID uint32
+}
+
+// NewNDRWriter creates an empty NDRWriter.
+//
+// Returns:
+// - A pointer to the NDRWriter.
+func NewNDRWriter() *NDRWriter {
+	return &NDRWriter{data: []byte{}}
+}
+
+// Bytes returns the marshalled stub data.
+//
+// Returns:
+// - A byte slice containing the stub data.
+func (w *NDRWriter) Bytes() []byte {
+	return w.data
+}
+
+// Align pads the stub data with zeros to a multiple of a size.
+//
+// Parameters:
+// - size: The alignment, such as 4 for a 32-bit integer.
+func (w *NDRWriter) Align(size int) {
+	for len(w.data)%size != 0 {
+		w.data = append(w.data, 0)
+	}
+}
+
+// WriteUint16 writes an aligned 16-bit integer.
+//
+// Parameters:
+// - value: The integer to write.
+func (w *NDRWriter) WriteUint16(value uint16) {
+	w.Align(2)
+	w.data = binary.LittleEndian.AppendUint16(w.data, value)
+}
+
+// WriteUint32 writes an aligned 32-bit integer.
+//
+// Parameters:
+// - value: The integer to write.
+func (w *NDRWriter) WriteUint32(value uint32) {
+	w.Align(4)
+	w.data = binary.LittleEndian.AppendUint32(w.data, value)
+}
+
+// WriteBytes writes raw bytes, without alignment.
+//
+// Parameters:
+// - value: A byte slice containing the bytes to write.
+func (w *NDRWriter) WriteBytes(value []byte) {
+	w.data = append(w.data, value...)
+}
+
+// WritePointer writes the referent ID of a non-null unique or full pointer, whose referent must be written later.
+//
+// Returns:
+// - The referent ID written.
+func (w *NDRWriter) WritePointer() uint32 {
+	w.referentID += 4
+	w.WriteUint32(0x00020000 + w.referentID)
+	return 0x00020000 + w.referentID
+}
+
+// WriteNullPointer writes a null unique or full pointer.
+func (w *NDRWriter) WriteNullPointer() {
+	w.WriteUint32(0)
+}
+
+// WriteConformantBytes writes a conformant array of bytes, preceded by its maximum count.
+//
+// Parameters:
+// - value: A byte slice containing the elements of the array.
+func (w *NDRWriter) WriteConformantBytes(value []byte) {
+	w.WriteUint32(uint32(len(value)))
+	w.WriteBytes(value)
+}
+
+// WriteWideString writes a null-terminated conformant varying string of UTF-16 characters, as marshalled for a
+// [string] wchar_t* parameter.
+//
+// Parameters:
+// - value: The string to write, without its null terminator.
+func (w *NDRWriter) WriteWideString(value string) {
+	encoded := append(utf16.EncodeUTF16LE(value), 0, 0)
+	count := uint32(len(encoded) / 2)
+	w.WriteUint32(count)
+	w.WriteUint32(0)
+	w.WriteUint32(count)
+	w.WriteBytes(encoded)
+}
+
+// NDRReader unmarshals the stub data of a response with the NDR transfer syntax, in little-endian.
+type NDRReader struct {
+	data   []byte
+	offset int
+}
+
+// NewNDRReader creates an NDRReader reading stub data from its start.
+//
+// Parameters:
+// - data: A byte slice containing the stub data.
+//
+// Returns:
+// - A pointer to the NDRReader.
+func NewNDRReader(data []byte) *NDRReader {
+	return &NDRReader{data: data}
+}
+
+// Align skips the padding up to a multiple of a size.
+//
+// Parameters:
+// - size: The alignment, such as 4 for a 32-bit integer.
+func (r *NDRReader) Align(size int) {
+	if remainder := r.offset % size; remainder != 0 {
+		r.offset += size - remainder
+	}
+}
+
+// ReadUint16 reads an aligned 16-bit integer.
+//
+// Returns:
+// - The integer read.
+// - An error if the stub data is too short.
+func (r *NDRReader) ReadUint16() (uint16, error) {
+	r.Align(2)
+	data, err := r.ReadBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(data), nil
+}
+
+// ReadUint32 reads an aligned 32-bit integer.
+//
+// Returns:
+// - The integer read.
+// - An error if the stub data is too short.
+func (r *NDRReader) ReadUint32() (uint32, error) {
+	r.Align(4)
+	data, err := r.ReadBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(data), nil
+}
+
+// ReadBytes reads raw bytes, without alignment.
+//
+// Parameters:
+// - length: The number of bytes to read.
+//
+// Returns:
+// - A byte slice containing the bytes read.
+// - An error if the stub data is too short.
+func (r *NDRReader) ReadBytes(length int) ([]byte, error) {
+	if length < 0 || r.offset+length > len(r.data) {
+		return nil, fmt.Errorf("NDR data is too short: %d bytes needed at offset %d, %d available", length, r.offset, len(r.data))
+	}
+	data := r.data[r.offset : r.offset+length]
+	r.offset += length
+	return data, nil
+}
+
+// ReadConformantBytes reads a conformant array of bytes, preceded by its maximum count.
+//
+// Returns:
+// - A byte slice containing the elements of the array.
+// - An error if the stub data is too short.
+func (r *NDRReader) ReadConformantBytes() ([]byte, error) {
+	count, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadBytes(int(count))
+}
@@ -0,0 +1,231 @@
+package dcerpc
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+)
+
+// Types of the connection-oriented PDUs
+// Src: https://pubs.opengroup.org/onlinepubs/9629399/chap12.htm
+const (
+	PTYPE_REQUEST  uint8 = 0
+	PTYPE_RESPONSE uint8 = 2
+	PTYPE_FAULT    uint8 = 3
+	PTYPE_BIND     uint8 = 11
+	PTYPE_BIND_ACK uint8 = 12
+	PTYPE_BIND_NAK uint8 = 13
+	PTYPE_AUTH3    uint8 = 16
+)
+
+// Flags of the connection-oriented PDUs
+const (
+	PFC_FIRST_FRAG uint8 = 0x01
+	PFC_LAST_FRAG  uint8 = 0x02
+)
+
+// Authentication services of the sec_trailer
+// Src: [MS-RPCE] 2.2.1.1.7 Security Providers
+const (
+	RPC_C_AUTHN_NONE  uint8 = 0
+	RPC_C_AUTHN_WINNT uint8 = 10
+)
+
+// Authentication levels of the sec_trailer
+// Src: [MS-RPCE] 2.2.1.1.8 Authentication Levels
+const (
+	RPC_C_AUTHN_LEVEL_NONE          uint8 = 1
+	RPC_C_AUTHN_LEVEL_CONNECT       uint8 = 2
+	RPC_C_AUTHN_LEVEL_PKT_INTEGRITY uint8 = 5
+	RPC_C_AUTHN_LEVEL_PKT_PRIVACY   uint8 = 6
+)
+
+// Sizes of the common header of the PDUs, of the sec_trailer and of the NTLM verifier
+const (
+	PDU_HEADER_SIZE   = 16
+	SEC_TRAILER_SIZE  = 8
+	NTLM_VERIFIER_LEN = 16
+)
+
+// Maximum size of the fragments sent and received, as negotiated by Windows
+const DEFAULT_MAX_FRAGMENT_SIZE = 4280
+
+// UUID of the NDR transfer syntax, version 2.0
+const NDR_TRANSFER_SYNTAX_UUID = "8a885d04-1ceb-11c9-9fe8-08002b104860"
+
+// SyntaxID identifies an RPC interface, or a transfer syntax, by its UUID and version.
+//
+// Fields:
+// - UUID: The UUID of the interface, such as "e1af8308-5d1f-11c9-91a4-08002b14a0fa".
+// - MajorVersion: The major version of the interface.
+// - MinorVersion: The minor version of the interface.
+type SyntaxID struct {
+	UUID         string
+	MajorVersion uint16
+	MinorVersion uint16
+}
+
+// NDRTransferSyntax is the transfer syntax of the stub data marshalled by this package.
+var NDRTransferSyntax = SyntaxID{UUID: NDR_TRANSFER_SYNTAX_UUID, MajorVersion: 2}
+
+// Marshal encodes the syntax identifier as a p_syntax_id_t.
+//
+// Returns:
+// - A byte slice containing the 20 bytes of the UUID and of the version.
+// - An error if the UUID is invalid.
+func (s SyntaxID) Marshal() ([]byte, error) {
+	uuid, err := guid.FromString(s.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interface UUID %s: %w", s.UUID, err)
+	}
+	data := uuid.ToBytes()
+	data = binary.LittleEndian.AppendUint16(data, s.MajorVersion)
+	return binary.LittleEndian.AppendUint16(data, s.MinorVersion), nil
+}
+
+// Header is the common header of the connection-oriented PDUs, assuming the little-endian ASCII IEEE data
+// representation used by Windows.
+//
+// Fields:
+// - Type: The type of the PDU, one of the PTYPE_* constants.
+// - Flags: The PFC_* flags of the PDU.
+// - FragLength: The length of the whole fragment, header and authentication verifier included.
+// - AuthLength: The length of the authentication value following the sec_trailer.
+// - CallID: The identifier of the call the fragment belongs to.
+type Header struct {
+	Type       uint8
+	Flags      uint8
+	FragLength uint16
+	AuthLength uint16
+	CallID     uint32
+}
+
+// Marshal encodes the header.
+//
+// Returns:
+// - A byte slice containing the 16 bytes of the header.
+func (h *Header) Marshal() []byte {
+	// Version 5.0, little-endian integers, ASCII characters and IEEE floating points
+	data := []byte{5, 0, h.Type, h.Flags, 0x10, 0, 0, 0}
+	data = binary.LittleEndian.AppendUint16(data, h.FragLength)
+	data = binary.LittleEndian.AppendUint16(data, h.AuthLength)
+	return binary.LittleEndian.AppendUint32(data, h.CallID)
+}
+
+// Unmarshal decodes the header of a PDU.
+//
+// Parameters:
+// - data: A byte slice containing at least the 16 bytes of the header.
+//
+// Returns:
+// - An error if the data is too short, if the version is not 5.0 or if the data representation is not little-endian.
+func (h *Header) Unmarshal(data []byte) error {
+	if len(data) < PDU_HEADER_SIZE {
+		return fmt.Errorf("PDU header must be %d bytes, got %d", PDU_HEADER_SIZE, len(data))
+	}
+	if data[0] != 5 || data[1] != 0 {
+		return fmt.Errorf("unsupported RPC version %d.%d", data[0], data[1])
+	}
+	if data[4]&0xf0 != 0x10 {
+		return fmt.Errorf("unsupported data representation %x", data[4:8])
+	}
+	h.Type = data[2]
+	h.Flags = data[3]
+	h.FragLength = binary.LittleEndian.Uint16(data[8:10])
+	h.AuthLength = binary.LittleEndian.Uint16(data[10:12])
+	h.CallID = binary.LittleEndian.Uint32(data[12:16])
+	return nil
+}
+
+// SecTrailer is the sec_trailer preceding the authentication value of an authenticated PDU.
+// Src: [MS-RPCE] 2.2.2.11 sec_trailer Structure
+//
+// Fields:
+// - AuthType: The authentication service, one of the RPC_C_AUTHN_* constants.
+// - AuthLevel: The authentication level, one of the RPC_C_AUTHN_LEVEL_* constants.
+// - AuthPadLength: The number of padding bytes appended to the stub data to align the sec_trailer.
+// - AuthContextID: The identifier of the security context.
+type SecTrailer struct {
+	AuthType      uint8
+	AuthLevel     uint8
+	AuthPadLength uint8
+	AuthContextID uint32
+}
+
+// Marshal encodes the sec_trailer.
+//
+// Returns:
+// - A byte slice containing the 8 bytes of the sec_trailer.
+func (s *SecTrailer) Marshal() []byte {
+	data := []byte{s.AuthType, s.AuthLevel, s.AuthPadLength, 0}
+	return binary.LittleEndian.AppendUint32(data, s.AuthContextID)
+}
+
+// Unmarshal decodes a sec_trailer.
+//
+// Parameters:
+// - data: A byte slice containing at least the 8 bytes of the sec_trailer.
+//
+// Returns:
+// - An error if the data is too short.
+func (s *SecTrailer) Unmarshal(data []byte) error {
+	if len(data) < SEC_TRAILER_SIZE {
+		return fmt.Errorf("sec_trailer must be %d bytes, got %d", SEC_TRAILER_SIZE, len(data))
+	}
+	s.AuthType = data[0]
+	s.AuthLevel = data[1]
+	s.AuthPadLength = data[2]
+	s.AuthContextID = binary.LittleEndian.Uint32(data[4:8])
+	return nil
+}
+
+// marshalBind encodes the body of a bind PDU presenting one context with the NDR transfer syntax.
+func marshalBind(contextID uint16, abstractSyntax SyntaxID) ([]byte, error) {
+	abstract, err := abstractSyntax.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	transfer, err := NDRTransferSyntax.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	data := binary.LittleEndian.AppendUint16(nil, DEFAULT_MAX_FRAGMENT_SIZE)
+	data = binary.LittleEndian.AppendUint16(data, DEFAULT_MAX_FRAGMENT_SIZE)
+	// New association group
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	// One presentation context, with one transfer syntax
+	data = append(data, 1, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint16(data, contextID)
+	data = append(data, 1, 0)
+	data = append(data, abstract...)
+	return append(data, transfer...), nil
+}
+
+// parseBindAck decodes the body of a bind_ack PDU, returning the maximum size of the fragments accepted by the
+// server, or an error if the presentation context was rejected.
+func parseBindAck(body []byte) (uint16, error) {
+	if len(body) < 10 {
+		return 0, fmt.Errorf("bind_ack is too short")
+	}
+	maxRecvFrag := binary.LittleEndian.Uint16(body[2:4])
+
+	// The secondary address is padded to 4 bytes from the start of the PDU
+	secondaryAddressLength := int(binary.LittleEndian.Uint16(body[8:10]))
+	offset := 10 + secondaryAddressLength
+	offset += (4 - (PDU_HEADER_SIZE+offset)%4) % 4
+	if len(body) < offset+8 {
+		return 0, fmt.Errorf("bind_ack is too short")
+	}
+	if body[offset] == 0 {
+		return 0, fmt.Errorf("bind_ack holds no result")
+	}
+
+	result := binary.LittleEndian.Uint16(body[offset+4 : offset+6])
+	reason := binary.LittleEndian.Uint16(body[offset+6 : offset+8])
+	if result != 0 {
+		return 0, fmt.Errorf("presentation context rejected (result %d, reason %d)", result, reason)
+	}
+	return maxRecvFrag, nil
+}
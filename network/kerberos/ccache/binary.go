@@ -0,0 +1,226 @@
+package ccache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// reader reads the big-endian fields of a credential cache, keeping the first error encountered.
+type reader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+// readBytes reads a number of bytes.
+func (r *reader) readBytes(length int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if length < 0 || r.offset+length > len(r.data) {
+		r.err = fmt.Errorf("unexpected end of data at offset %d reading %d bytes", r.offset, length)
+		return nil
+	}
+	value := r.data[r.offset : r.offset+length]
+	r.offset += length
+	return value
+}
+
+// readUint8 reads an 8-bit integer.
+func (r *reader) readUint8() uint8 {
+	if data := r.readBytes(1); data != nil {
+		return data[0]
+	}
+	return 0
+}
+
+// readUint16 reads a 16-bit integer.
+func (r *reader) readUint16() uint16 {
+	if data := r.readBytes(2); data != nil {
+		return binary.BigEndian.Uint16(data)
+	}
+	return 0
+}
+
+// readUint32 reads a 32-bit integer.
+func (r *reader) readUint32() uint32 {
+	if data := r.readBytes(4); data != nil {
+		return binary.BigEndian.Uint32(data)
+	}
+	return 0
+}
+
+// readData reads a byte string prefixed by its 32-bit length.
+func (r *reader) readData() []byte {
+	length := r.readUint32()
+	if length > uint32(len(r.data)) {
+		r.err = fmt.Errorf("length %d at offset %d exceeds the size of the data", length, r.offset-4)
+		return nil
+	}
+	data := r.readBytes(int(length))
+	if len(data) == 0 {
+		return nil
+	}
+	return append([]byte{}, data...)
+}
+
+// readCount reads the number of elements of a list, each taking at least minSize bytes.
+func (r *reader) readCount(minSize int) int {
+	count := r.readUint32()
+	if uint64(count)*uint64(minSize) > uint64(len(r.data)-r.offset) {
+		r.err = fmt.Errorf("count %d at offset %d exceeds the size of the data", count, r.offset-4)
+		return 0
+	}
+	return int(count)
+}
+
+// readTime reads a timestamp in seconds since the epoch. The zero timestamp is read as the zero time.
+func (r *reader) readTime() time.Time {
+	seconds := r.readUint32()
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(seconds), 0).UTC()
+}
+
+// readPrincipal reads a principal: its name type, its number of components, its realm and its components.
+func (r *reader) readPrincipal() Principal {
+	principal := Principal{}
+	principal.Name.NameType = int32(r.readUint32())
+	count := r.readCount(4)
+	principal.Realm = string(r.readData())
+	principal.Name.NameString = make([]string, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		principal.Name.NameString = append(principal.Name.NameString, string(r.readData()))
+	}
+	return principal
+}
+
+// readCredential reads a credential in the layout of a version of the format.
+func (r *reader) readCredential(version uint8) *Credential {
+	credential := &Credential{}
+	credential.Client = r.readPrincipal()
+	credential.Server = r.readPrincipal()
+
+	credential.Key.KeyType = int32(r.readUint16())
+	if version == CCACHE_VERSION_3 {
+		// The encryption type is repeated in version 3
+		credential.Key.KeyType = int32(r.readUint16())
+	}
+	credential.Key.KeyValue = r.readData()
+
+	credential.AuthTime = r.readTime()
+	credential.StartTime = r.readTime()
+	credential.EndTime = r.readTime()
+	credential.RenewTill = r.readTime()
+	credential.IsSKey = r.readUint8() != 0
+
+	// The ticket flags are stored as the 32 bits of the KerberosFlags bit string
+	credential.Flags = types.NewKrbFlags()
+	if data := r.readBytes(4); data != nil {
+		copy(credential.Flags.Bytes, data)
+	}
+
+	count := r.readCount(6)
+	for i := 0; i < count && r.err == nil; i++ {
+		addressType := int32(r.readUint16())
+		credential.Addresses = append(credential.Addresses, types.HostAddress{AddrType: addressType, Address: r.readData()})
+	}
+	count = r.readCount(6)
+	for i := 0; i < count && r.err == nil; i++ {
+		adType := int32(r.readUint16())
+		credential.AuthData = append(credential.AuthData, types.AuthorizationDataEntry{ADType: adType, ADData: r.readData()})
+	}
+
+	credential.Ticket = r.readData()
+	credential.SecondTicket = r.readData()
+	return credential
+}
+
+// writer writes the big-endian fields of a credential cache.
+type writer struct {
+	data []byte
+}
+
+// writeUint8 writes an 8-bit integer.
+func (w *writer) writeUint8(value uint8) {
+	w.data = append(w.data, value)
+}
+
+// writeUint16 writes a 16-bit integer.
+func (w *writer) writeUint16(value uint16) {
+	w.data = binary.BigEndian.AppendUint16(w.data, value)
+}
+
+// writeUint32 writes a 32-bit integer.
+func (w *writer) writeUint32(value uint32) {
+	w.data = binary.BigEndian.AppendUint32(w.data, value)
+}
+
+// writeData writes a byte string prefixed by its 32-bit length.
+func (w *writer) writeData(data []byte) {
+	w.writeUint32(uint32(len(data)))
+	w.data = append(w.data, data...)
+}
+
+// writeTime writes a timestamp in seconds since the epoch. The zero time is written as the zero timestamp.
+func (w *writer) writeTime(t time.Time) {
+	if t.IsZero() {
+		w.writeUint32(0)
+		return
+	}
+	w.writeUint32(uint32(t.Unix()))
+}
+
+// writePrincipal writes a principal: its name type, its number of components, its realm and its components.
+func (w *writer) writePrincipal(principal Principal) {
+	w.writeUint32(uint32(principal.Name.NameType))
+	w.writeUint32(uint32(len(principal.Name.NameString)))
+	w.writeData([]byte(principal.Realm))
+	for _, component := range principal.Name.NameString {
+		w.writeData([]byte(component))
+	}
+}
+
+// writeCredential writes a credential in the layout of a version of the format.
+func (w *writer) writeCredential(credential *Credential, version uint8) {
+	w.writePrincipal(credential.Client)
+	w.writePrincipal(credential.Server)
+
+	w.writeUint16(uint16(credential.Key.KeyType))
+	if version == CCACHE_VERSION_3 {
+		w.writeUint16(uint16(credential.Key.KeyType))
+	}
+	w.writeData(credential.Key.KeyValue)
+
+	w.writeTime(credential.AuthTime)
+	w.writeTime(credential.StartTime)
+	w.writeTime(credential.EndTime)
+	w.writeTime(credential.RenewTill)
+	if credential.IsSKey {
+		w.writeUint8(1)
+	} else {
+		w.writeUint8(0)
+	}
+
+	flags := make([]byte, 4)
+	copy(flags, credential.Flags.Bytes)
+	w.data = append(w.data, flags...)
+
+	w.writeUint32(uint32(len(credential.Addresses)))
+	for _, address := range credential.Addresses {
+		w.writeUint16(uint16(address.AddrType))
+		w.writeData(address.Address)
+	}
+	w.writeUint32(uint32(len(credential.AuthData)))
+	for _, entry := range credential.AuthData {
+		w.writeUint16(uint16(entry.ADType))
+		w.writeData(entry.ADData)
+	}
+
+	w.writeData(credential.Ticket)
+	w.writeData(credential.SecondTicket)
+}
@@ -0,0 +1,243 @@
+// Package ccache reads and writes Kerberos credentials in the MIT credential cache format used by
+// the Linux tools (krb5, impacket) and in the KRB-CRED format of the .kirbi files used by the Windows
+// tools (mimikatz, Rubeus), and converts between them.
+//
+// Src: https://web.mit.edu/kerberos/krb5-latest/doc/formats/ccache_file_format.html
+package ccache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Versions of the credential cache format. Versions 1 and 2 use the native byte order and are not supported.
+const (
+	CCACHE_VERSION_3 uint8 = 3
+	CCACHE_VERSION_4 uint8 = 4
+)
+
+// Tag of the header field holding the time offset of the KDC, in version 4
+const CCACHE_HEADER_TAG_KDC_TIME_OFFSET uint16 = 1
+
+// Realm of the configuration entries stored by MIT krb5 as credentials
+const configurationRealm = "X-CACHECONF:"
+
+// Principal represents a principal name along with its realm.
+type Principal struct {
+	Realm string
+	Name  types.PrincipalName
+}
+
+// String returns the principal in the "name@REALM" format.
+//
+// Returns:
+// - A string representing the principal.
+func (p Principal) String() string {
+	return fmt.Sprintf("%s@%s", strings.Join(p.Name.NameString, "/"), p.Realm)
+}
+
+// HeaderField represents a field of the header of a version 4 credential cache.
+type HeaderField struct {
+	Tag   uint16
+	Value []byte
+}
+
+// CCache represents an MIT credential cache.
+type CCache struct {
+	Version          uint8
+	HeaderFields     []HeaderField
+	DefaultPrincipal Principal
+	Credentials      []*Credential
+}
+
+// NewCCache creates an empty version 4 credential cache.
+//
+// Parameters:
+// - defaultPrincipal: The Principal the credentials of the cache belong to.
+//
+// Returns:
+// - A pointer to the CCache.
+func NewCCache(defaultPrincipal Principal) *CCache {
+	return &CCache{
+		Version:          CCACHE_VERSION_4,
+		DefaultPrincipal: defaultPrincipal,
+	}
+}
+
+// NewCCacheFromFile reads a credential cache or a .kirbi file, whose format is detected from its content.
+//
+// Parameters:
+// - pathToFile: A string representing the path to the file.
+//
+// Returns:
+// - A pointer to the CCache holding the credentials of the file.
+// - An error if the file cannot be read or parsed.
+func NewCCacheFromFile(pathToFile string) (*CCache, error) {
+	data, err := os.ReadFile(pathToFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", pathToFile, err)
+	}
+
+	c := &CCache{}
+	if IsKRBCred(data) {
+		err = c.FromKRBCred(data)
+	} else {
+		err = c.FromBytes(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", pathToFile, err)
+	}
+	return c, nil
+}
+
+// FromBytes parses a credential cache of version 3 or 4.
+//
+// Parameters:
+// - data: A byte slice containing the credential cache.
+//
+// Returns:
+// - An error if the credential cache is malformed or of an unsupported version, otherwise nil.
+func (c *CCache) FromBytes(data []byte) error {
+	r := &reader{data: data}
+
+	if magic := r.readUint8(); magic != 5 {
+		return fmt.Errorf("invalid credential cache: first byte is 0x%02x instead of 0x05", magic)
+	}
+	c.Version = r.readUint8()
+	if c.Version != CCACHE_VERSION_3 && c.Version != CCACHE_VERSION_4 {
+		return fmt.Errorf("unsupported credential cache version %d", c.Version)
+	}
+
+	c.HeaderFields = nil
+	if c.Version == CCACHE_VERSION_4 {
+		header := &reader{data: r.readBytes(int(r.readUint16()))}
+		for header.err == nil && header.offset < len(header.data) {
+			tag := header.readUint16()
+			value := header.readBytes(int(header.readUint16()))
+			c.HeaderFields = append(c.HeaderFields, HeaderField{Tag: tag, Value: value})
+		}
+		if header.err != nil {
+			return fmt.Errorf("invalid credential cache header: %w", header.err)
+		}
+	}
+
+	c.DefaultPrincipal = r.readPrincipal()
+	if r.err != nil {
+		return fmt.Errorf("invalid default principal: %w", r.err)
+	}
+
+	c.Credentials = nil
+	for r.offset < len(r.data) {
+		credential := r.readCredential(c.Version)
+		if r.err != nil {
+			return fmt.Errorf("invalid credential %d: %w", len(c.Credentials), r.err)
+		}
+		c.Credentials = append(c.Credentials, credential)
+	}
+
+	return nil
+}
+
+// ToBytes encodes the credential cache.
+//
+// Returns:
+// - A byte slice containing the credential cache.
+// - An error if the version of the credential cache is not supported.
+func (c *CCache) ToBytes() ([]byte, error) {
+	if c.Version != CCACHE_VERSION_3 && c.Version != CCACHE_VERSION_4 {
+		return nil, fmt.Errorf("unsupported credential cache version %d", c.Version)
+	}
+
+	w := &writer{}
+	w.writeUint8(5)
+	w.writeUint8(c.Version)
+
+	if c.Version == CCACHE_VERSION_4 {
+		header := &writer{}
+		for _, field := range c.HeaderFields {
+			header.writeUint16(field.Tag)
+			header.writeUint16(uint16(len(field.Value)))
+			header.data = append(header.data, field.Value...)
+		}
+		w.writeUint16(uint16(len(header.data)))
+		w.data = append(w.data, header.data...)
+	}
+
+	w.writePrincipal(c.DefaultPrincipal)
+	for _, credential := range c.Credentials {
+		w.writeCredential(credential, c.Version)
+	}
+
+	return w.data, nil
+}
+
+// WriteCCacheFile writes the credential cache to a file, readable with KRB5CCNAME.
+//
+// Parameters:
+// - pathToFile: A string representing the path to the file.
+//
+// Returns:
+// - An error if the credential cache cannot be encoded or written.
+func (c *CCache) WriteCCacheFile(pathToFile string) error {
+	data, err := c.ToBytes()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(pathToFile, data, 0600); err != nil {
+		return fmt.Errorf("error writing %s: %w", pathToFile, err)
+	}
+	return nil
+}
+
+// GetKDCTimeOffset returns the offset between the clock of the KDC and the local clock, stored in the header
+// of version 4 credential caches.
+//
+// Returns:
+// - A time.Duration representing the offset, or 0 if the credential cache has none.
+func (c *CCache) GetKDCTimeOffset() time.Duration {
+	for _, field := range c.HeaderFields {
+		if field.Tag == CCACHE_HEADER_TAG_KDC_TIME_OFFSET && len(field.Value) == 8 {
+			seconds := int32(binary.BigEndian.Uint32(field.Value[0:4]))
+			microseconds := int32(binary.BigEndian.Uint32(field.Value[4:8]))
+			return time.Duration(seconds)*time.Second + time.Duration(microseconds)*time.Microsecond
+		}
+	}
+	return 0
+}
+
+// GetCredential returns the first credential of the cache for a server principal.
+//
+// Parameters:
+// - server: A string representing the server principal in the "service/host" format, with or without its realm,
+// compared case-insensitively.
+//
+// Returns:
+// - A pointer to the Credential, or nil if the cache has no credential for this server.
+func (c *CCache) GetCredential(server string) *Credential {
+	for _, credential := range c.Credentials {
+		name := strings.Join(credential.Server.Name.NameString, "/")
+		if strings.EqualFold(name, server) || strings.EqualFold(credential.Server.String(), server) {
+			return credential
+		}
+	}
+	return nil
+}
+
+// AddCredential adds a credential to the cache, replacing the credential of the same client and server if any.
+//
+// Parameters:
+// - credential: A pointer to the Credential to add.
+func (c *CCache) AddCredential(credential *Credential) {
+	for i, existing := range c.Credentials {
+		if strings.EqualFold(existing.Client.String(), credential.Client.String()) && strings.EqualFold(existing.Server.String(), credential.Server.String()) {
+			c.Credentials[i] = credential
+			return
+		}
+	}
+	c.Credentials = append(c.Credentials, credential)
+}
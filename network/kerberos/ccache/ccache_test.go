@@ -0,0 +1,207 @@
+package ccache
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos/kdctest"
+
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// newTestCredential requests a TGT from a test KDC and returns it as a credential, along with the krbtgt key.
+func newTestCredential(t *testing.T) (*Credential, *krbcrypto.Key) {
+	t.Helper()
+
+	server, err := kdctest.NewServer("LAB.LOCAL")
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	user, err := server.AddUser("alice", "Password123!")
+	if err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	user.RequirePreauth = false
+
+	asRep, err := kerberos.RequestASRepWithoutPreAuth(server.Addr(), "LAB.LOCAL", "alice", []int32{krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96})
+	if err != nil {
+		t.Fatalf("RequestASRepWithoutPreAuth failed: %v", err)
+	}
+	key := user.Keys[krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96]
+	plaintext, err := key.Decrypt(krbcrypto.KEY_USAGE_AS_REP_ENCPART, asRep.EncPart.Cipher)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	var encPart messages.EncKDCRepPart
+	if err := encPart.Unmarshal(plaintext); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	credential, err := NewCredential(asRep.CRealm, asRep.CName, asRep.Ticket, encPart)
+	if err != nil {
+		t.Fatalf("NewCredential failed: %v", err)
+	}
+	return credential, server.GetKrbtgtKey(krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96)
+}
+
+func TestCCacheRoundTrip(t *testing.T) {
+	credential, _ := newTestCredential(t)
+
+	for _, version := range []uint8{CCACHE_VERSION_3, CCACHE_VERSION_4} {
+		c := NewCCache(credential.Client)
+		c.Version = version
+		if version == CCACHE_VERSION_4 {
+			c.HeaderFields = []HeaderField{{Tag: CCACHE_HEADER_TAG_KDC_TIME_OFFSET, Value: []byte{0xff, 0xff, 0xff, 0xc4, 0, 0, 0, 0}}}
+		}
+		c.AddCredential(credential)
+
+		data, err := c.ToBytes()
+		if err != nil {
+			t.Fatalf("ToBytes failed for version %d: %v", version, err)
+		}
+		if data[0] != 5 || data[1] != version {
+			t.Errorf("Expected header 05 %02x, got %x", version, data[0:2])
+		}
+
+		parsed := &CCache{}
+		if err := parsed.FromBytes(data); err != nil {
+			t.Fatalf("FromBytes failed for version %d: %v", version, err)
+		}
+		if !reflect.DeepEqual(parsed, c) {
+			t.Errorf("Round trip mismatch for version %d:\n got %+v\nwant %+v", version, parsed, c)
+		}
+
+		again, err := parsed.ToBytes()
+		if err != nil {
+			t.Fatalf("ToBytes failed for version %d: %v", version, err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("Encoding mismatch for version %d", version)
+		}
+	}
+}
+
+func TestCCacheKDCTimeOffset(t *testing.T) {
+	c := NewCCache(Principal{})
+	c.HeaderFields = []HeaderField{{Tag: CCACHE_HEADER_TAG_KDC_TIME_OFFSET, Value: []byte{0xff, 0xff, 0xff, 0xc4, 0, 0, 0, 0}}}
+	if offset := c.GetKDCTimeOffset(); offset.Seconds() != -60 {
+		t.Errorf("Expected an offset of -60s, got %s", offset)
+	}
+}
+
+func TestCCacheFromBytesInvalid(t *testing.T) {
+	credential, _ := newTestCredential(t)
+	c := NewCCache(credential.Client)
+	c.AddCredential(credential)
+	data, err := c.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Empty", data: []byte{}},
+		{name: "BadMagic", data: append([]byte{0x06}, data[1:]...)},
+		{name: "Version2", data: append([]byte{0x05, 0x02}, data[2:]...)},
+		{name: "Truncated", data: data[:len(data)-10]},
+		{name: "HugeLength", data: append(append([]byte{}, data[:len(data)-4]...), 0xff, 0xff, 0xff, 0xff)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&CCache{}).FromBytes(tt.data); err == nil {
+				t.Error("Expected FromBytes to fail")
+			}
+		})
+	}
+}
+
+func TestKRBCredConversion(t *testing.T) {
+	credential, _ := newTestCredential(t)
+	c := NewCCache(credential.Client)
+	c.AddCredential(credential)
+	c.AddCredential(&Credential{
+		Client: credential.Client,
+		Server: Principal{Realm: configurationRealm, Name: types.PrincipalName{NameString: []string{"krb5_ccache_conf_data", "pa_type"}}},
+		Ticket: []byte("2"),
+	})
+
+	kirbi, err := c.ToKRBCred()
+	if err != nil {
+		t.Fatalf("ToKRBCred failed: %v", err)
+	}
+	if !IsKRBCred(kirbi) {
+		t.Fatalf("Expected a KRB-CRED message, got %x", kirbi[:4])
+	}
+
+	var message messages.KRBCred
+	if err := message.Unmarshal(kirbi); err != nil {
+		t.Fatalf("KRB-CRED is not readable by gokrb5: %v", err)
+	}
+
+	converted := &CCache{}
+	if err := converted.FromKRBCred(kirbi); err != nil {
+		t.Fatalf("FromKRBCred failed: %v", err)
+	}
+	if len(converted.Credentials) != 1 {
+		t.Fatalf("Expected 1 credential without the configuration entry, got %d", len(converted.Credentials))
+	}
+	if !reflect.DeepEqual(converted.Credentials[0], credential) {
+		t.Errorf("Conversion mismatch:\n got %+v\nwant %+v", converted.Credentials[0], credential)
+	}
+	if !reflect.DeepEqual(converted.DefaultPrincipal, credential.Client) {
+		t.Errorf("Expected default principal %s, got %s", credential.Client.String(), converted.DefaultPrincipal.String())
+	}
+
+	if err := (&CCache{}).FromKRBCred(kirbi[:len(kirbi)-1]); err == nil {
+		t.Error("Expected FromKRBCred to fail on truncated data")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	credential, _ := newTestCredential(t)
+	c := NewCCache(credential.Client)
+	c.AddCredential(credential)
+
+	directory := t.TempDir()
+	ccachePath := filepath.Join(directory, "alice.ccache")
+	kirbiPath := filepath.Join(directory, "alice.kirbi")
+	if err := c.WriteCCacheFile(ccachePath); err != nil {
+		t.Fatalf("WriteCCacheFile failed: %v", err)
+	}
+	if err := c.WriteKirbiFile(kirbiPath); err != nil {
+		t.Fatalf("WriteKirbiFile failed: %v", err)
+	}
+
+	for _, path := range []string{ccachePath, kirbiPath} {
+		loaded, err := NewCCacheFromFile(path)
+		if err != nil {
+			t.Fatalf("NewCCacheFromFile failed for %s: %v", path, err)
+		}
+		tgt := loaded.GetCredential("krbtgt/LAB.LOCAL")
+		if tgt == nil {
+			t.Fatalf("Expected a TGT in %s", path)
+		}
+		if !reflect.DeepEqual(tgt, credential) {
+			t.Errorf("Credential mismatch for %s:\n got %+v\nwant %+v", path, tgt, credential)
+		}
+	}
+}
+
+func TestGetTicketFlagNames(t *testing.T) {
+	flags := types.NewKrbFlags()
+	flags.Bytes = []byte{0x40, 0xe1, 0x00, 0x00}
+
+	expected := []string{"forwardable", "renewable", "initial", "pre_authent", "enc_pa_rep"}
+	if names := GetTicketFlagNames(flags); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,90 @@
+package ccache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Credential represents a ticket along with its session key and the information the KDC returned
+// about it in the encrypted part of its reply.
+type Credential struct {
+	Client       Principal
+	Server       Principal
+	Key          types.EncryptionKey
+	AuthTime     time.Time
+	StartTime    time.Time
+	EndTime      time.Time
+	RenewTill    time.Time
+	IsSKey       bool
+	Flags        asn1.BitString
+	Addresses    []types.HostAddress
+	AuthData     []types.AuthorizationDataEntry
+	Ticket       []byte
+	SecondTicket []byte
+}
+
+// NewCredential creates a credential from a ticket and the decrypted part of the AS-REP or TGS-REP it
+// was issued in.
+//
+// Parameters:
+// - crealm: A string representing the realm of the client.
+// - cname: The principal name of the client.
+// - ticket: The ticket issued by the KDC.
+// - encPart: The decrypted part of the reply of the KDC, holding the session key and the times of the ticket.
+//
+// Returns:
+// - A pointer to the Credential.
+// - An error if the ticket cannot be marshalled.
+func NewCredential(crealm string, cname types.PrincipalName, ticket messages.Ticket, encPart messages.EncKDCRepPart) (*Credential, error) {
+	ticketBytes, err := ticket.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling ticket: %w", err)
+	}
+
+	return &Credential{
+		Client:    Principal{Realm: crealm, Name: cname},
+		Server:    Principal{Realm: encPart.SRealm, Name: encPart.SName},
+		Key:       encPart.Key,
+		AuthTime:  encPart.AuthTime,
+		StartTime: encPart.StartTime,
+		EndTime:   encPart.EndTime,
+		RenewTill: encPart.RenewTill,
+		Flags:     encPart.Flags,
+		Addresses: encPart.CAddr,
+		Ticket:    ticketBytes,
+	}, nil
+}
+
+// GetTicket unmarshals the ticket of the credential.
+//
+// Returns:
+// - The messages.Ticket of the credential.
+// - An error if the ticket is malformed.
+func (c *Credential) GetTicket() (messages.Ticket, error) {
+	var ticket messages.Ticket
+	if err := ticket.Unmarshal(c.Ticket); err != nil {
+		return ticket, fmt.Errorf("error unmarshalling ticket: %w", err)
+	}
+	return ticket, nil
+}
+
+// IsConfigurationEntry checks whether the credential is a configuration entry stored by MIT krb5 in the
+// cache, which does not hold a ticket.
+//
+// Returns:
+// - A boolean indicating whether the credential is a configuration entry.
+func (c *Credential) IsConfigurationEntry() bool {
+	return c.Server.Realm == configurationRealm
+}
+
+// IsExpired checks whether the ticket of the credential has expired.
+//
+// Returns:
+// - A boolean indicating whether the end time of the ticket has passed.
+func (c *Credential) IsExpired() bool {
+	return !c.EndTime.IsZero() && time.Now().After(c.EndTime)
+}
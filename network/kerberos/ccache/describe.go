@@ -0,0 +1,190 @@
+package ccache
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos/pac"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Names of the ticket flags, indexed by bit number
+// Src: https://www.rfc-editor.org/rfc/rfc4120#section-5.3
+var TicketFlagNames = map[int]string{
+	0:  "reserved",
+	1:  "forwardable",
+	2:  "forwarded",
+	3:  "proxiable",
+	4:  "proxy",
+	5:  "may_postdate",
+	6:  "postdated",
+	7:  "invalid",
+	8:  "renewable",
+	9:  "initial",
+	10: "pre_authent",
+	11: "hw_authent",
+	12: "transited_policy_checked",
+	13: "ok_as_delegate",
+	15: "enc_pa_rep",
+	16: "anonymous",
+}
+
+// GetTicketFlagNames returns the names of the flags set in ticket flags.
+//
+// Parameters:
+// - flags: The asn1.BitString holding the ticket flags.
+//
+// Returns:
+// - A slice of strings containing the names of the flags set, in bit order.
+func GetTicketFlagNames(flags asn1.BitString) []string {
+	names := []string{}
+	for bit := 0; bit < flags.BitLength; bit++ {
+		if flags.At(bit) == 0 {
+			continue
+		}
+		if name, ok := TicketFlagNames[bit]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, fmt.Sprintf("bit_%d", bit))
+		}
+	}
+	return names
+}
+
+// Describe prints a detailed description of the credential cache and of its credentials.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (c *CCache) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<CCache structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mVersion\x1b[0m: %d\n", indentPrompt, c.Version)
+	if offset := c.GetKDCTimeOffset(); offset != 0 {
+		fmt.Printf("%s │ \x1b[93mKDC time offset\x1b[0m: %s\n", indentPrompt, offset)
+	}
+	fmt.Printf("%s │ \x1b[93mDefault principal\x1b[0m: %s\n", indentPrompt, c.DefaultPrincipal.String())
+	fmt.Printf("%s │ \x1b[93mCredentials\x1b[0m: %d\n", indentPrompt, len(c.Credentials))
+	for _, credential := range c.Credentials {
+		credential.Describe(indent + 1)
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
+
+// Describe prints a detailed description of the credential.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (c *Credential) Describe(indent int) {
+	c.DescribeWithKey(indent, nil)
+}
+
+// DescribeWithKey prints a detailed description of the credential and, if the key of the service is given,
+// of the decrypted part of its ticket and of its PAC.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+// - serviceKey: A pointer to the long-term key of the service the ticket is for (the krbtgt key for a TGT), or nil.
+func (c *Credential) DescribeWithKey(indent int, serviceKey *krbcrypto.Key) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<Credential structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mClient\x1b[0m: %s\n", indentPrompt, c.Client.String())
+	fmt.Printf("%s │ \x1b[93mServer\x1b[0m: %s\n", indentPrompt, c.Server.String())
+	if c.IsConfigurationEntry() {
+		fmt.Printf("%s │ \x1b[93mConfiguration value\x1b[0m: %s\n", indentPrompt, string(c.Ticket))
+		fmt.Printf("%s └───\n", indentPrompt)
+		return
+	}
+	fmt.Printf("%s │ \x1b[93mSession key\x1b[0m: %s (%s)\n", indentPrompt, hex.EncodeToString(c.Key.KeyValue), getEncryptionTypeName(c.Key.KeyType))
+	describeFlags(indentPrompt, c.Flags)
+	describeTimes(indentPrompt, c.AuthTime, c.StartTime, c.EndTime, c.RenewTill)
+
+	ticket, err := c.GetTicket()
+	if err != nil {
+		fmt.Printf("%s │ \x1b[93mTicket\x1b[0m: \x1b[91m%s\x1b[0m\n", indentPrompt, err)
+		fmt.Printf("%s └───\n", indentPrompt)
+		return
+	}
+	fmt.Printf("%s │ \x1b[93mTicket encryption type\x1b[0m: %s\n", indentPrompt, getEncryptionTypeName(ticket.EncPart.EType))
+	fmt.Printf("%s │ \x1b[93mTicket key version number\x1b[0m: %d\n", indentPrompt, ticket.EncPart.KVNO)
+	fmt.Printf("%s │ \x1b[93mTicket size\x1b[0m: %d bytes\n", indentPrompt, len(c.Ticket))
+
+	if serviceKey != nil {
+		describeEncTicketPart(indent+1, ticket, serviceKey)
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
+
+// describeEncTicketPart decrypts and prints the encrypted part of a ticket and its PAC.
+func describeEncTicketPart(indent int, ticket messages.Ticket, serviceKey *krbcrypto.Key) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<EncTicketPart structure>\n", indentPrompt)
+	if ticket.EncPart.EType != serviceKey.EType {
+		fmt.Printf("%s │ \x1b[91mTicket is encrypted with %s but the key is of type %s\x1b[0m\n", indentPrompt, getEncryptionTypeName(ticket.EncPart.EType), getEncryptionTypeName(serviceKey.EType))
+		fmt.Printf("%s └───\n", indentPrompt)
+		return
+	}
+	if err := ticket.Decrypt(types.EncryptionKey{KeyType: serviceKey.EType, KeyValue: serviceKey.Value}); err != nil {
+		fmt.Printf("%s │ \x1b[91mError decrypting the ticket: %s\x1b[0m\n", indentPrompt, err)
+		fmt.Printf("%s └───\n", indentPrompt)
+		return
+	}
+	decrypted := ticket.DecryptedEncPart
+
+	fmt.Printf("%s │ \x1b[93mClient\x1b[0m: %s@%s\n", indentPrompt, strings.Join(decrypted.CName.NameString, "/"), decrypted.CRealm)
+	fmt.Printf("%s │ \x1b[93mSession key\x1b[0m: %s (%s)\n", indentPrompt, hex.EncodeToString(decrypted.Key.KeyValue), getEncryptionTypeName(decrypted.Key.KeyType))
+	describeFlags(indentPrompt, decrypted.Flags)
+	describeTimes(indentPrompt, decrypted.AuthTime, decrypted.StartTime, decrypted.EndTime, decrypted.RenewTill)
+	if len(decrypted.Transited.Contents) != 0 {
+		fmt.Printf("%s │ \x1b[93mTransited\x1b[0m: %s\n", indentPrompt, string(decrypted.Transited.Contents))
+	}
+	if p, err := pac.FromAuthorizationData(decrypted.AuthorizationData); err == nil {
+		p.Describe(indent + 1)
+	} else {
+		fmt.Printf("%s │ \x1b[93mPAC\x1b[0m: None\n", indentPrompt)
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
+
+// describeFlags prints ticket flags with their names.
+func describeFlags(indentPrompt string, flags asn1.BitString) {
+	value := uint32(0)
+	for i, b := range flags.Bytes {
+		if i < 4 {
+			value |= uint32(b) << (24 - 8*i)
+		}
+	}
+	fmt.Printf("%s │ \x1b[93mFlags\x1b[0m: %s (0x%08x)\n", indentPrompt, strings.Join(GetTicketFlagNames(flags), ", "), value)
+}
+
+// describeTimes prints the times of a ticket.
+func describeTimes(indentPrompt string, authTime, startTime, endTime, renewTill time.Time) {
+	fmt.Printf("%s │ \x1b[93mAuth time (UTC)\x1b[0m: %s\n", indentPrompt, formatTime(authTime))
+	fmt.Printf("%s │ \x1b[93mStart time (UTC)\x1b[0m: %s\n", indentPrompt, formatTime(startTime))
+	fmt.Printf("%s │ \x1b[93mEnd time (UTC)\x1b[0m: %s\n", indentPrompt, formatTime(endTime))
+	fmt.Printf("%s │ \x1b[93mRenew till (UTC)\x1b[0m: %s\n", indentPrompt, formatTime(renewTill))
+}
+
+// formatTime formats a time of a ticket, which may be absent.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "None"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// getEncryptionTypeName returns the name of an encryption type along with its number.
+func getEncryptionTypeName(etype int32) string {
+	if encryptionType, err := krbcrypto.GetEncryptionType(etype); err == nil {
+		return fmt.Sprintf("%s (%d)", encryptionType.GetName(), etype)
+	}
+	return fmt.Sprintf("unknown (%d)", etype)
+}
@@ -0,0 +1,242 @@
+package ccache
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/iana"
+	"github.com/jcmturner/gokrb5/v8/iana/asnAppTag"
+	"github.com/jcmturner/gokrb5/v8/iana/msgtype"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc4120#section-5.8
+
+// krbCred represents the KRB-CRED message, with the explicitly tagged sequence of tickets kept in its DER encoding.
+type krbCred struct {
+	PVNO    int                 `asn1:"explicit,tag:0"`
+	MsgType int                 `asn1:"explicit,tag:1"`
+	Tickets asn1.RawValue       `asn1:"explicit,tag:2"`
+	EncPart types.EncryptedData `asn1:"explicit,tag:3"`
+}
+
+// encKrbCredPart represents the EncKrbCredPart structure of a KRB-CRED message.
+type encKrbCredPart struct {
+	TicketInfo []krbCredInfo     `asn1:"explicit,tag:0"`
+	Nonce      int               `asn1:"optional,explicit,tag:1"`
+	Timestamp  time.Time         `asn1:"generalized,optional,explicit,tag:2"`
+	Usec       int               `asn1:"optional,explicit,tag:3"`
+	SAddress   types.HostAddress `asn1:"optional,explicit,tag:4"`
+	RAddress   types.HostAddress `asn1:"optional,explicit,tag:5"`
+}
+
+// krbCredInfo represents the KrbCredInfo structure describing a ticket of a KRB-CRED message.
+type krbCredInfo struct {
+	Key       types.EncryptionKey `asn1:"explicit,tag:0"`
+	PRealm    string              `asn1:"generalstring,optional,explicit,tag:1"`
+	PName     types.PrincipalName `asn1:"optional,explicit,tag:2"`
+	Flags     asn1.BitString      `asn1:"optional,explicit,tag:3"`
+	AuthTime  time.Time           `asn1:"generalized,optional,explicit,tag:4"`
+	StartTime time.Time           `asn1:"generalized,optional,explicit,tag:5"`
+	EndTime   time.Time           `asn1:"generalized,optional,explicit,tag:6"`
+	RenewTill time.Time           `asn1:"generalized,optional,explicit,tag:7"`
+	SRealm    string              `asn1:"generalstring,optional,explicit,tag:8"`
+	SName     types.PrincipalName `asn1:"optional,explicit,tag:9"`
+	CAddr     types.HostAddresses `asn1:"optional,explicit,tag:10"`
+}
+
+// IsKRBCred checks whether data starts like a KRB-CRED message rather than like a credential cache.
+//
+// Parameters:
+// - data: A byte slice containing the content of a credential file.
+//
+// Returns:
+// - A boolean indicating whether the data is a KRB-CRED message, as found in .kirbi files.
+func IsKRBCred(data []byte) bool {
+	// Application tag 22, constructed
+	return len(data) != 0 && data[0] == 0x76
+}
+
+// FromKRBCred replaces the credentials of the cache with the ones of a KRB-CRED message whose encrypted
+// part is not encrypted, as in the .kirbi files and the tickets exported by Windows tools.
+//
+// Parameters:
+// - data: A byte slice containing the DER encoded KRB-CRED message.
+//
+// Returns:
+// - An error if the message is malformed or if its encrypted part is encrypted, otherwise nil.
+func (c *CCache) FromKRBCred(data []byte) error {
+	return c.FromEncryptedKRBCred(data, nil)
+}
+
+// FromEncryptedKRBCred replaces the credentials of the cache with the ones of a KRB-CRED message, decrypting
+// its encrypted part with a key, as for the forwarded tickets sent in the authenticators of AP-REQ messages.
+//
+// Parameters:
+// - data: A byte slice containing the DER encoded KRB-CRED message.
+// - key: A pointer to the key the encrypted part is encrypted with (usually the session key of the service
+// ticket of the AP-REQ), or nil if the encrypted part is not encrypted.
+//
+// Returns:
+// - An error if the message is malformed or cannot be decrypted, otherwise nil.
+func (c *CCache) FromEncryptedKRBCred(data []byte, key *krbcrypto.Key) error {
+	var message krbCred
+	if _, err := asn1.UnmarshalWithParams(data, &message, fmt.Sprintf("application,explicit,tag:%d", asnAppTag.KRBCred)); err != nil {
+		return fmt.Errorf("error unmarshalling KRB-CRED: %w", err)
+	}
+	if message.MsgType != msgtype.KRB_CRED {
+		return fmt.Errorf("message type %d is not KRB-CRED", message.MsgType)
+	}
+
+	plaintext := message.EncPart.Cipher
+	if message.EncPart.EType != 0 {
+		if key == nil {
+			return fmt.Errorf("encrypted part of the KRB-CRED is encrypted with encryption type %d, a key is required", message.EncPart.EType)
+		}
+		var err error
+		if plaintext, err = key.Decrypt(krbcrypto.KEY_USAGE_KRB_CRED_ENCPART, message.EncPart.Cipher); err != nil {
+			return fmt.Errorf("error decrypting the encrypted part of the KRB-CRED: %w", err)
+		}
+	}
+
+	var encPart encKrbCredPart
+	if _, err := asn1.UnmarshalWithParams(plaintext, &encPart, fmt.Sprintf("application,explicit,tag:%d", asnAppTag.EncKrbCredPart)); err != nil {
+		return fmt.Errorf("error unmarshalling the encrypted part of the KRB-CRED: %w", err)
+	}
+
+	// The RawValue holds the explicit tag, around the sequence of tickets
+	var ticketsSequence asn1.RawValue
+	if _, err := asn1.Unmarshal(message.Tickets.Bytes, &ticketsSequence); err != nil {
+		return fmt.Errorf("error unmarshalling the tickets of the KRB-CRED: %w", err)
+	}
+
+	// The tickets are split without being unmarshalled, to keep their exact encoding
+	tickets := [][]byte{}
+	for rest := ticketsSequence.Bytes; len(rest) != 0; {
+		var ticket asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &ticket); err != nil {
+			return fmt.Errorf("error unmarshalling the tickets of the KRB-CRED: %w", err)
+		}
+		tickets = append(tickets, ticket.FullBytes)
+	}
+	if len(encPart.TicketInfo) != len(tickets) {
+		return fmt.Errorf("KRB-CRED has %d tickets but %d ticket informations", len(tickets), len(encPart.TicketInfo))
+	}
+
+	c.Version = CCACHE_VERSION_4
+	c.HeaderFields = nil
+	c.Credentials = nil
+	for i, info := range encPart.TicketInfo {
+		c.Credentials = append(c.Credentials, &Credential{
+			Client:    Principal{Realm: info.PRealm, Name: info.PName},
+			Server:    Principal{Realm: info.SRealm, Name: info.SName},
+			Key:       info.Key,
+			AuthTime:  info.AuthTime,
+			StartTime: info.StartTime,
+			EndTime:   info.EndTime,
+			RenewTill: info.RenewTill,
+			Flags:     normalizeFlags(info.Flags),
+			Addresses: info.CAddr,
+			Ticket:    tickets[i],
+		})
+	}
+	if len(c.Credentials) != 0 {
+		c.DefaultPrincipal = c.Credentials[0].Client
+	}
+
+	return nil
+}
+
+// ToKRBCred encodes the credentials of the cache in a KRB-CRED message with an unencrypted encrypted part,
+// as in the .kirbi files. The configuration entries of the cache are skipped.
+//
+// Returns:
+// - A byte slice containing the DER encoded KRB-CRED message.
+// - An error if the cache holds no ticket or if the message cannot be marshalled.
+func (c *CCache) ToKRBCred() ([]byte, error) {
+	tickets := []byte{}
+	encPart := encKrbCredPart{}
+
+	for _, credential := range c.Credentials {
+		if credential.IsConfigurationEntry() {
+			continue
+		}
+
+		var ticket asn1.RawValue
+		if rest, err := asn1.Unmarshal(credential.Ticket, &ticket); err != nil || len(rest) != 0 {
+			return nil, fmt.Errorf("invalid ticket for %s", credential.Server.String())
+		}
+		tickets = append(tickets, credential.Ticket...)
+
+		encPart.TicketInfo = append(encPart.TicketInfo, krbCredInfo{
+			Key:       credential.Key,
+			PRealm:    credential.Client.Realm,
+			PName:     credential.Client.Name,
+			Flags:     normalizeFlags(credential.Flags),
+			AuthTime:  credential.AuthTime,
+			StartTime: credential.StartTime,
+			EndTime:   credential.EndTime,
+			RenewTill: credential.RenewTill,
+			SRealm:    credential.Server.Realm,
+			SName:     credential.Server.Name,
+			CAddr:     credential.Addresses,
+		})
+	}
+	if len(encPart.TicketInfo) == 0 {
+		return nil, fmt.Errorf("credential cache holds no ticket")
+	}
+
+	encPartBytes, err := asn1.Marshal(encPart)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling the encrypted part of the KRB-CRED: %w", err)
+	}
+	// The explicit tag of a RawValue is neither added when marshalling nor removed when unmarshalling
+	ticketsSequence, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: tickets})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling the tickets of the KRB-CRED: %w", err)
+	}
+	message := krbCred{
+		PVNO:    iana.PVNO,
+		MsgType: msgtype.KRB_CRED,
+		Tickets: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: ticketsSequence},
+		EncPart: types.EncryptedData{EType: 0, Cipher: asn1tools.AddASNAppTag(encPartBytes, asnAppTag.EncKrbCredPart)},
+	}
+
+	data, err := asn1.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling KRB-CRED: %w", err)
+	}
+	return asn1tools.AddASNAppTag(data, asnAppTag.KRBCred), nil
+}
+
+// WriteKirbiFile writes the credentials of the cache to a .kirbi file.
+//
+// Parameters:
+// - pathToFile: A string representing the path to the file.
+//
+// Returns:
+// - An error if the credentials cannot be encoded or written.
+func (c *CCache) WriteKirbiFile(pathToFile string) error {
+	data, err := c.ToKRBCred()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(pathToFile, data, 0600); err != nil {
+		return fmt.Errorf("error writing %s: %w", pathToFile, err)
+	}
+	return nil
+}
+
+// normalizeFlags returns the ticket flags as a 32-bit bit string, which is how they are stored in credential
+// caches and how Windows encodes them.
+func normalizeFlags(flags asn1.BitString) asn1.BitString {
+	normalized := asn1.BitString{Bytes: make([]byte, 4), BitLength: 32}
+	copy(normalized.Bytes, flags.Bytes)
+	return normalized
+}
@@ -0,0 +1,229 @@
+package kdctest
+
+import (
+	"fmt"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos/pac"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/iana"
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/iana/msgtype"
+	"github.com/jcmturner/gokrb5/v8/iana/patype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// paPACRequest represents the KERB-PA-PAC-REQUEST structure, by which a client asks for a ticket with or without PAC.
+type paPACRequest struct {
+	IncludePAC bool `asn1:"explicit,tag:0"`
+}
+
+// handleASReq processes an AS-REQ message and returns an AS-REP or a KRB-ERROR message.
+func (s *Server) handleASReq(request []byte, faults Faults) []byte {
+	var asReq messages.ASReq
+	if err := asReq.Unmarshal(request); err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, types.PrincipalName{}, fmt.Sprintf("error unmarshalling AS-REQ: %s", err), nil)
+	}
+	cname := asReq.ReqBody.CName
+	now := s.now(faults)
+
+	client := s.lookupPrincipal(cname)
+	if client == nil {
+		return s.newKRBError(errorcode.KDC_ERR_C_PRINCIPAL_UNKNOWN, cname, "client not found", nil)
+	}
+	service := s.lookupPrincipal(asReq.ReqBody.SName)
+	if service == nil {
+		return s.newKRBError(errorcode.KDC_ERR_S_PRINCIPAL_UNKNOWN, cname, "service not found", nil)
+	}
+
+	replyKey := selectKey(client, asReq.ReqBody.EType, faults)
+	if replyKey == nil {
+		return s.newKRBError(errorcode.KDC_ERR_ETYPE_NOSUPP, cname, "no key of the requested encryption types", nil)
+	}
+
+	// Encrypted timestamp preauthentication
+	preauthenticated := false
+	if client.RequirePreauth || faults.PreauthRequired {
+		encryptedTimestamp := getPAData(asReq.PAData, patype.PA_ENC_TIMESTAMP)
+		if encryptedTimestamp == nil || faults.PreauthRequired {
+			return s.newKRBError(errorcode.KDC_ERR_PREAUTH_REQUIRED, cname, "preauthentication required", s.newPreauthEData(client, replyKey))
+		}
+		if code, text := s.checkEncryptedTimestamp(client, encryptedTimestamp.PADataValue, now); code != errorcode.KDC_ERR_NONE {
+			return s.newKRBError(code, cname, text, nil)
+		}
+		preauthenticated = true
+	}
+
+	sessionKey, err := newSessionKey(asReq.ReqBody.EType, faults)
+	if err != nil {
+		return s.newKRBError(errorcode.KDC_ERR_ETYPE_NOSUPP, cname, err.Error(), nil)
+	}
+	ticketKey := selectKey(service, ticketEncryptionTypes, faults)
+	if ticketKey == nil {
+		return s.newKRBError(errorcode.KDC_ERR_ETYPE_NOSUPP, cname, "no key of the service for the requested encryption type", nil)
+	}
+
+	// Ticket flags and times
+	ticketFlags := types.NewKrbFlags()
+	types.SetFlag(&ticketFlags, flags.Initial)
+	if preauthenticated {
+		types.SetFlag(&ticketFlags, flags.PreAuthent)
+	}
+	times := newTicketTimes(now, now, asReq.ReqBody, time.Time{}, time.Time{})
+	copyRequestedFlags(&ticketFlags, asReq.ReqBody.KDCOptions, nil, times)
+
+	// PAC, included unless the client explicitly asks for a ticket without PAC
+	var authorizationData types.AuthorizationData
+	includePAC, pacAttributes := getPACRequest(asReq.PAData)
+	if includePAC {
+		p := s.newPAC(client, now, pacAttributes)
+		if _, err := p.Sign(ticketKey, s.getKrbtgtSignatureKey(faults)); err != nil {
+			return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, fmt.Sprintf("error signing PAC: %s", err), nil)
+		}
+		if authorizationData, err = p.ToAuthorizationData(); err != nil {
+			return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+		}
+	}
+
+	ticket, err := newTicket(s.Realm, asReq.ReqBody.SName, messages.EncTicketPart{
+		Flags:             ticketFlags,
+		Key:               sessionKey,
+		CRealm:            s.Realm,
+		CName:             cname,
+		AuthTime:          times.authTime,
+		StartTime:         times.startTime,
+		EndTime:           times.endTime,
+		RenewTill:         times.renewTill,
+		AuthorizationData: authorizationData,
+	}, ticketKey, service.KVNO)
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+
+	encPart := messages.EncKDCRepPart{
+		Key:       sessionKey,
+		LastReqs:  []messages.LastReq{{LRType: 0, LRValue: now}},
+		Nonce:     asReq.ReqBody.Nonce,
+		Flags:     ticketFlags,
+		AuthTime:  times.authTime,
+		StartTime: times.startTime,
+		EndTime:   times.endTime,
+		RenewTill: times.renewTill,
+		SRealm:    s.Realm,
+		SName:     asReq.ReqBody.SName,
+	}
+	encPartBytes, err := encPart.Marshal()
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+	encryptedEncPart, err := encrypt(replyKey, krbcrypto.KEY_USAGE_AS_REP_ENCPART, encPartBytes, client.KVNO)
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+
+	etypeInfo2, err := newETypeInfo2(client, replyKey)
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+
+	asRep := messages.ASRep{
+		KDCRepFields: messages.KDCRepFields{
+			PVNO:    iana.PVNO,
+			MsgType: msgtype.KRB_AS_REP,
+			PAData:  types.PADataSequence{etypeInfo2},
+			CRealm:  s.Realm,
+			CName:   cname,
+			Ticket:  ticket,
+			EncPart: encryptedEncPart,
+		},
+	}
+	reply, err := asRep.Marshal()
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+
+	return reply
+}
+
+// checkEncryptedTimestamp decrypts and checks a PA-ENC-TIMESTAMP, and returns the error code to reply
+// with if it is not valid.
+func (s *Server) checkEncryptedTimestamp(client *Principal, value []byte, now time.Time) (int32, string) {
+	var encryptedData types.EncryptedData
+	if err := encryptedData.Unmarshal(value); err != nil {
+		return errorcode.KDC_ERR_PREAUTH_FAILED, "invalid encrypted timestamp"
+	}
+	key, ok := client.Keys[encryptedData.EType]
+	if !ok {
+		return errorcode.KDC_ERR_ETYPE_NOSUPP, "no key of the encryption type of the timestamp"
+	}
+
+	plaintext, err := key.Decrypt(krbcrypto.KEY_USAGE_AS_REQ_PA_ENC_TIMESTAMP, encryptedData.Cipher)
+	if err != nil {
+		return errorcode.KDC_ERR_PREAUTH_FAILED, "invalid encrypted timestamp"
+	}
+	var timestamp types.PAEncTSEnc
+	if _, err := asn1.Unmarshal(plaintext, &timestamp); err != nil {
+		return errorcode.KDC_ERR_PREAUTH_FAILED, "invalid encrypted timestamp"
+	}
+
+	if !isWithinClockSkew(timestamp.PATimestamp, now) {
+		return errorcode.KRB_AP_ERR_SKEW, "clock skew too great"
+	}
+	return errorcode.KDC_ERR_NONE, ""
+}
+
+// newPreauthEData builds the METHOD-DATA sent with KDC_ERR_PREAUTH_REQUIRED, which tells the
+// client the encryption type and salt of its key.
+func (s *Server) newPreauthEData(client *Principal, key *krbcrypto.Key) []byte {
+	etypeInfo2, err := newETypeInfo2(client, key)
+	if err != nil {
+		return nil
+	}
+	methodData := types.PADataSequence{
+		{PADataType: patype.PA_ENC_TIMESTAMP, PADataValue: []byte{}},
+		etypeInfo2,
+	}
+	data, err := asn1.Marshal(methodData)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// newETypeInfo2 builds a PA-ETYPE-INFO2 padata for a key of a principal.
+func newETypeInfo2(principal *Principal, key *krbcrypto.Key) (types.PAData, error) {
+	info := types.ETypeInfo2{{EType: key.EType, Salt: principal.Salt}}
+	data, err := asn1.Marshal(info)
+	if err != nil {
+		return types.PAData{}, fmt.Errorf("error marshalling PA-ETYPE-INFO2: %w", err)
+	}
+	return types.PAData{PADataType: patype.PA_ETYPE_INFO2, PADataValue: data}, nil
+}
+
+// getPACRequest returns whether a PAC must be included in the ticket, and the attributes of the PAC
+// telling whether the client requested it.
+func getPACRequest(paData types.PADataSequence) (bool, uint32) {
+	paPACRequestData := getPAData(paData, patype.PA_PAC_REQUEST)
+	if paPACRequestData == nil {
+		return true, pac.PAC_WAS_GIVEN_IMPLICITLY
+	}
+	request := paPACRequest{}
+	if _, err := asn1.Unmarshal(paPACRequestData.PADataValue, &request); err != nil {
+		return true, pac.PAC_WAS_GIVEN_IMPLICITLY
+	}
+	return request.IncludePAC, pac.PAC_WAS_REQUESTED
+}
+
+// getPAData returns the first padata of a type, or nil if there is none.
+func getPAData(paData types.PADataSequence, paDataType int32) *types.PAData {
+	for i := range paData {
+		if paData[i].PADataType == paDataType {
+			return &paData[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package kdctest_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos/kdctest"
+	"github.com/TheManticoreProject/Manticore/network/kerberos/pac"
+
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/messages"
+)
+
+const testRealm = "LAB.LOCAL"
+
+// newTestServer starts a KDC with a user and a service account, stopped at the end of the test.
+func newTestServer(t *testing.T) *kdctest.Server {
+	t.Helper()
+
+	server, err := kdctest.NewServer(testRealm)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	if _, err := server.AddUser("alice", "Password123!"); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	service, err := server.AddUser("svc_sql", "Summer2024!")
+	if err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	service.SPNs = []string{"MSSQLSvc/sql.lab.local:1433"}
+
+	return server
+}
+
+// login authenticates a user with the gokrb5 client against the KDC.
+func login(t *testing.T, server *kdctest.Server, username, password string) (*krb5client.Client, error) {
+	t.Helper()
+
+	krb5Conf, err := server.Config()
+	if err != nil {
+		t.Fatalf("Config failed: %v", err)
+	}
+	client := krb5client.NewWithPassword(username, testRealm, password, krb5Conf, krb5client.DisablePAFXFAST(true))
+	t.Cleanup(client.Destroy)
+
+	return client, client.Login()
+}
+
+// getErrorCode returns the code of the KRB-ERROR wrapped in an error, or -1 if there is none.
+func getErrorCode(err error) int32 {
+	var krbError messages.KRBError
+	if errors.As(err, &krbError) {
+		return krbError.ErrorCode
+	}
+	// The gokrb5 client does not always wrap the KRB-ERROR, only its description
+	for _, code := range []int32{errorcode.KDC_ERR_PREAUTH_REQUIRED, errorcode.KRB_AP_ERR_SKEW, errorcode.KDC_ERR_C_PRINCIPAL_UNKNOWN, errorcode.KDC_ERR_PREAUTH_FAILED} {
+		if err != nil && strings.Contains(err.Error(), errorcode.Lookup(code)) {
+			return code
+		}
+	}
+	return -1
+}
+
+func TestLoginAndServiceTicketWithPAC(t *testing.T) {
+	server := newTestServer(t)
+
+	client, err := login(t, server, "alice", "Password123!")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	ticket, _, err := client.GetServiceTicket("MSSQLSvc/sql.lab.local:1433")
+	if err != nil {
+		t.Fatalf("GetServiceTicket failed: %v", err)
+	}
+	if ticket.EncPart.EType != krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96 {
+		t.Errorf("Expected ticket encryption type %d, got %d", krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, ticket.EncPart.EType)
+	}
+
+	service := server.GetPrincipal("MSSQLSvc/sql.lab.local:1433")
+	serviceKey := service.Keys[ticket.EncPart.EType]
+	p, err := pac.FromTicket(ticket, serviceKey)
+	if err != nil {
+		t.Fatalf("FromTicket failed: %v", err)
+	}
+	if err := p.VerifyServerSignature(serviceKey); err != nil {
+		t.Errorf("VerifyServerSignature failed: %v", err)
+	}
+	if err := p.VerifyKDCSignature(server.GetKrbtgtKey(krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96)); err != nil {
+		t.Errorf("VerifyKDCSignature failed: %v", err)
+	}
+
+	alice := server.GetPrincipal("alice")
+	if p.LogonInfo == nil || p.LogonInfo.EffectiveName != "alice" {
+		t.Fatalf("Expected a logon info for alice, got %+v", p.LogonInfo)
+	}
+	if p.LogonInfo.UserId != alice.RID || p.LogonInfo.PrimaryGroupId != 513 {
+		t.Errorf("Expected RID %d and primary group 513, got %d and %d", alice.RID, p.LogonInfo.UserId, p.LogonInfo.PrimaryGroupId)
+	}
+	if p.LogonInfo.LogonDomainId != server.DomainSID {
+		t.Errorf("Expected domain SID %s, got %s", server.DomainSID, p.LogonInfo.LogonDomainId)
+	}
+	if p.UPNDNSInfo == nil || p.UPNDNSInfo.SID != alice.GetSID(server.DomainSID) {
+		t.Errorf("Expected UPN_DNS_INFO with SID %s, got %+v", alice.GetSID(server.DomainSID), p.UPNDNSInfo)
+	}
+}
+
+func TestTrustedRealm(t *testing.T) {
+	const partnerRealm = "PARTNER.LOCAL"
+
+	server := newTestServer(t)
+	partner, err := kdctest.NewServer(partnerRealm)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	t.Cleanup(partner.Close)
+	if err := server.AddTrust(partnerRealm, "TrustPassword123!"); err != nil {
+		t.Fatalf("AddTrust failed: %v", err)
+	}
+	if err := partner.AddTrust(testRealm, "TrustPassword123!"); err != nil {
+		t.Fatalf("AddTrust failed: %v", err)
+	}
+	service, err := partner.AddUser("svc_sql", "Winter2024!")
+	if err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	// The port of the first SPN prevents gokrb5 from mapping its host to the partner realm, so the ticket
+	// is only obtained by following the referral of the KDC of the realm of the user
+	service.SPNs = []string{"MSSQLSvc/sql.partner.local:1433", "cifs/sql.partner.local"}
+
+	newClient := func(t *testing.T, realmConfig *kerberos.RealmConfig) *krb5client.Client {
+		krb5Conf, err := realmConfig.WithKDCs(testRealm, server.Addr()).WithKDCs(partnerRealm, partner.Addr()).Build()
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		client := krb5client.NewWithPassword("alice", testRealm, "Password123!", krb5Conf, krb5client.DisablePAFXFAST(true))
+		t.Cleanup(client.Destroy)
+		if err := client.Login(); err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		return client
+	}
+
+	t.Run("WithTrustedRealm", func(t *testing.T) {
+		client := newClient(t, kerberos.NewRealmConfig(testRealm).WithTrustedRealm(partnerRealm))
+
+		for _, spn := range service.SPNs {
+			ticket, _, err := client.GetServiceTicket(spn)
+			if err != nil {
+				t.Fatalf("GetServiceTicket of %s failed: %v", spn, err)
+			}
+			if ticket.Realm != partnerRealm {
+				t.Errorf("Expected a ticket of %s for %s, got %s", partnerRealm, spn, ticket.Realm)
+			}
+
+			// The PAC issued by the realm of the user is carried into the service ticket
+			p, err := pac.FromTicket(ticket, service.Keys[ticket.EncPart.EType])
+			if err != nil {
+				t.Fatalf("FromTicket of %s failed: %v", spn, err)
+			}
+			if p.LogonInfo == nil || p.LogonInfo.EffectiveName != "alice" || p.LogonInfo.LogonDomainId != server.DomainSID {
+				t.Errorf("Expected a logon info for alice of %s, got %+v", server.DomainSID, p.LogonInfo)
+			}
+		}
+	})
+
+	t.Run("WithoutNameCanonicalize", func(t *testing.T) {
+		client := newClient(t, kerberos.NewRealmConfig(testRealm))
+
+		if _, _, err := client.GetServiceTicket(service.SPNs[0]); !strings.Contains(fmt.Sprint(err), errorcode.Lookup(errorcode.KDC_ERR_S_PRINCIPAL_UNKNOWN)) {
+			t.Errorf("Expected KDC_ERR_S_PRINCIPAL_UNKNOWN without referral, got %v", err)
+		}
+	})
+}
+
+func TestUnknownPrincipal(t *testing.T) {
+	server := newTestServer(t)
+
+	_, err := login(t, server, "bob", "Password123!")
+	if code := getErrorCode(err); code != errorcode.KDC_ERR_C_PRINCIPAL_UNKNOWN {
+		t.Errorf("Expected KDC_ERR_C_PRINCIPAL_UNKNOWN, got %v", err)
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	server := newTestServer(t)
+
+	_, err := login(t, server, "alice", "WrongPassword")
+	if code := getErrorCode(err); code != errorcode.KDC_ERR_PREAUTH_FAILED {
+		t.Errorf("Expected KDC_ERR_PREAUTH_FAILED, got %v", err)
+	}
+}
+
+func TestASREPRoast(t *testing.T) {
+	server := newTestServer(t)
+
+	if _, err := kerberos.ASREPRoast(server.Addr(), testRealm, "alice", krbcrypto.ETYPE_RC4_HMAC); err == nil {
+		t.Fatal("Expected ASREPRoast to fail for a user requiring preauthentication")
+	}
+
+	server.GetPrincipal("alice").RequirePreauth = false
+	hash, err := kerberos.ASREPRoast(server.Addr(), testRealm, "alice", krbcrypto.ETYPE_RC4_HMAC)
+	if err != nil {
+		t.Fatalf("ASREPRoast failed: %v", err)
+	}
+	if hash.EType != krbcrypto.ETYPE_RC4_HMAC {
+		t.Errorf("Expected encryption type %d, got %d", krbcrypto.ETYPE_RC4_HMAC, hash.EType)
+	}
+}
+
+func TestKerberoastWithRC4(t *testing.T) {
+	server := newTestServer(t)
+
+	krb5Conf, err := kerberos.NewRealmConfig(testRealm).
+		WithKDCs(testRealm, server.Addr()).
+		WithEncryptionTypes(kerberos.ENCTYPES_USE_TGS, krbcrypto.ETYPE_RC4_HMAC).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	client := krb5client.NewWithPassword("alice", testRealm, "Password123!", krb5Conf, krb5client.DisablePAFXFAST(true))
+	defer client.Destroy()
+	if err := client.Login(); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	hash, err := kerberos.Kerberoast(client, "svc_sql", "MSSQLSvc/sql.lab.local:1433")
+	if err != nil {
+		t.Fatalf("Kerberoast failed: %v", err)
+	}
+	if hash.EType != krbcrypto.ETYPE_RC4_HMAC {
+		t.Errorf("Expected encryption type %d, got %d", krbcrypto.ETYPE_RC4_HMAC, hash.EType)
+	}
+}
+
+func TestKerberoastWithKeytab(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, etype := range []int32{krbcrypto.ETYPE_RC4_HMAC, krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96} {
+		// Authenticate with the key of alice only, as when passing the hash or the AES key of an account
+		alice := server.GetPrincipal("alice")
+		kt, err := kerberos.NewKeytabFromKeys("alice", testRealm, uint32(alice.KVNO), alice.Keys[etype])
+		if err != nil {
+			t.Fatalf("NewKeytabFromKeys failed: %v", err)
+		}
+		krb5Conf, err := kerberos.NewRealmConfig(testRealm).
+			WithKDCs(testRealm, server.Addr()).
+			WithEncryptionTypes(kerberos.ENCTYPES_USE_AS, etype).
+			Build()
+		if err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		client := krb5client.NewWithKeytab("alice", testRealm, kt, krb5Conf, krb5client.DisablePAFXFAST(true))
+		defer client.Destroy()
+		if err := client.Login(); err != nil {
+			t.Fatalf("Login with the %d key failed: %v", etype, err)
+		}
+
+		if _, err := kerberos.Kerberoast(client, "svc_sql", "MSSQLSvc/sql.lab.local:1433"); err != nil {
+			t.Errorf("Kerberoast with the %d key failed: %v", etype, err)
+		}
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		faults   kdctest.Faults
+		expected int32
+	}{
+		{
+			name:     "PreauthRequired",
+			faults:   kdctest.Faults{PreauthRequired: true},
+			expected: errorcode.KDC_ERR_PREAUTH_REQUIRED,
+		},
+		{
+			name:     "ClockSkew",
+			faults:   kdctest.Faults{ClockSkew: 10 * time.Minute},
+			expected: errorcode.KRB_AP_ERR_SKEW,
+		},
+		{
+			name:     "ErrorCode",
+			faults:   kdctest.Faults{ErrorCode: errorcode.KDC_ERR_C_PRINCIPAL_UNKNOWN},
+			expected: errorcode.KDC_ERR_C_PRINCIPAL_UNKNOWN,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			server.SetFaults(tt.faults)
+
+			_, err := login(t, server, "alice", "Password123!")
+			if err == nil {
+				t.Fatal("Expected Login to fail")
+			}
+			if code := getErrorCode(err); code != tt.expected {
+				t.Errorf("Expected error code %d (%s), got %v", tt.expected, errorcode.Lookup(tt.expected), err)
+			}
+
+			server.SetFaults(kdctest.Faults{})
+			if _, err := login(t, server, "alice", "Password123!"); err != nil {
+				t.Errorf("Login failed after clearing the faults: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptionTypeFault(t *testing.T) {
+	server := newTestServer(t)
+
+	// The reply, the session keys and the tickets use the forced encryption type
+	server.SetFaults(kdctest.Faults{EncryptionType: krbcrypto.ETYPE_RC4_HMAC})
+	client, err := login(t, server, "alice", "Password123!")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	ticket, sessionKey, err := client.GetServiceTicket("MSSQLSvc/sql.lab.local:1433")
+	if err != nil {
+		t.Fatalf("GetServiceTicket failed: %v", err)
+	}
+	if ticket.EncPart.EType != krbcrypto.ETYPE_RC4_HMAC || sessionKey.KeyType != krbcrypto.ETYPE_RC4_HMAC {
+		t.Errorf("Expected ticket and session key of type %d, got %d and %d", krbcrypto.ETYPE_RC4_HMAC, ticket.EncPart.EType, sessionKey.KeyType)
+	}
+
+	// An encryption type the user has no key of is rejected
+	server.SetFaults(kdctest.Faults{EncryptionType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA384_192})
+	_, err = login(t, server, "alice", "Password123!")
+	if !strings.Contains(fmt.Sprint(err), errorcode.Lookup(errorcode.KDC_ERR_ETYPE_NOSUPP)) {
+		t.Errorf("Expected KDC_ERR_ETYPE_NOSUPP, got %v", err)
+	}
+}
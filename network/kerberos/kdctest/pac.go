@@ -0,0 +1,85 @@
+package kdctest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/kerberos/pac"
+	"github.com/TheManticoreProject/Manticore/windows/ms_dtyp/common/data_structures"
+)
+
+// Attributes of the groups of the PACs issued by the KDC
+const groupAttributes = pac.SE_GROUP_MANDATORY | pac.SE_GROUP_ENABLED_BY_DEFAULT | pac.SE_GROUP_ENABLED
+
+// SID added by Active Directory to the PACs of the users authenticated by the KDC (AUTHENTICATION_AUTHORITY_ASSERTED_IDENTITY)
+const authenticationAuthorityAssertedIdentitySID = "S-1-18-1"
+
+// Account types of the KERB_VALIDATION_INFO structure
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-samr/b10cfda1-f24f-441b-8f43-80cb93e786ec
+const (
+	userNormalAccount           uint32 = 0x00000010
+	userWorkstationTrustAccount uint32 = 0x00000080
+)
+
+// newPAC builds the unsigned PAC of a principal, like the one Active Directory puts in the TGTs.
+func (s *Server) newPAC(client *Principal, authTime time.Time, attributes uint32) *pac.PAC {
+	never := data_structures.FILETIME{DwLowDateTime: 0xffffffff, DwHighDateTime: 0x7fffffff}
+	logonTime := *data_structures.NewFILETIMEFromTime(authTime)
+	netbiosName := strings.ToUpper(strings.Split(s.Realm, ".")[0])
+	userSID := client.GetSID(s.DomainSID)
+
+	groupIds := []pac.GroupMembership{{RelativeId: client.PrimaryGroupID, Attributes: groupAttributes}}
+	for _, rid := range client.GroupRIDs {
+		groupIds = append(groupIds, pac.GroupMembership{RelativeId: rid, Attributes: groupAttributes})
+	}
+
+	extraSids := []pac.SIDAndAttributes{{SID: authenticationAuthorityAssertedIdentitySID, Attributes: groupAttributes}}
+	for _, sid := range client.ExtraSIDs {
+		extraSids = append(extraSids, pac.SIDAndAttributes{SID: sid, Attributes: groupAttributes})
+	}
+
+	accountType := userNormalAccount
+	if strings.HasSuffix(client.Name, "$") {
+		accountType = userWorkstationTrustAccount
+	}
+
+	return &pac.PAC{
+		LogonInfo: &pac.KerbValidationInfo{
+			LogonTime:          logonTime,
+			LogoffTime:         never,
+			KickOffTime:        never,
+			PasswordLastSet:    logonTime,
+			PasswordCanChange:  logonTime,
+			PasswordMustChange: never,
+			EffectiveName:      client.Name,
+			UserId:             client.RID,
+			PrimaryGroupId:     client.PrimaryGroupID,
+			GroupIds:           groupIds,
+			UserFlags:          pac.LOGON_EXTRA_SIDS,
+			LogonServer:        "KDCTEST",
+			LogonDomainName:    netbiosName,
+			LogonDomainId:      s.DomainSID,
+			UserAccountControl: accountType,
+			ExtraSids:          extraSids,
+		},
+		ClientInfo: &pac.ClientInfo{
+			ClientId: logonTime,
+			Name:     client.Name,
+		},
+		UPNDNSInfo: &pac.UPNDNSInfo{
+			UPN:           fmt.Sprintf("%s@%s", client.Name, strings.ToLower(s.Realm)),
+			DNSDomainName: s.Realm,
+			Flags:         pac.UPN_DNS_INFO_FLAG_UPN_CONSTRUCTED | pac.UPN_DNS_INFO_FLAG_EXTENDED,
+			SamName:       client.Name,
+			SID:           userSID,
+		},
+		AttributesInfo: &pac.AttributesInfo{
+			FlagsLength: 2,
+			Flags:       attributes,
+		},
+		Requestor: &pac.Requestor{
+			SID: userSID,
+		},
+	}
+}
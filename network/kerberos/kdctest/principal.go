@@ -0,0 +1,239 @@
+package kdctest
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Encryption types of the keys of the principals when none are given, in order of preference
+var defaultEncryptionTypes = []int32{
+	krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96,
+	krbcrypto.ETYPE_AES128_CTS_HMAC_SHA1_96,
+	krbcrypto.ETYPE_RC4_HMAC,
+}
+
+// Order of preference of the encryption types of the tickets, when the client does not negotiate them
+var ticketEncryptionTypes = []int32{
+	krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96,
+	krbcrypto.ETYPE_AES128_CTS_HMAC_SHA1_96,
+	krbcrypto.ETYPE_AES256_CTS_HMAC_SHA384_192,
+	krbcrypto.ETYPE_AES128_CTS_HMAC_SHA256_128,
+	krbcrypto.ETYPE_RC4_HMAC,
+}
+
+// Principal is an account of the realm of the KDC.
+//
+// The fields of a principal must not be modified while the KDC is processing requests.
+type Principal struct {
+	// Name is the sAMAccountName of the account, ending with "$" for computer accounts
+	Name string
+	// SPNs are the service principal names of the account, in the "service/host" format
+	SPNs []string
+	// Keys are the long-term keys of the account, indexed by encryption type
+	Keys map[int32]*krbcrypto.Key
+	// KVNO is the version number of the keys
+	KVNO int
+	// Salt is the salt used to derive the keys from the password, sent to the clients in PA-ETYPE-INFO2
+	Salt string
+
+	// RequirePreauth is false for the accounts having DONT_REQ_PREAUTH set in their userAccountControl
+	RequirePreauth bool
+
+	// RID is the relative identifier of the account in the domain
+	RID uint32
+	// PrimaryGroupID is the RID of the primary group of the account
+	PrimaryGroupID uint32
+	// GroupRIDs are the RIDs of the domain groups the account is a member of, besides its primary group
+	GroupRIDs []uint32
+	// ExtraSIDs are SIDs added to the extra SIDs of the PAC, such as SIDs of other domains
+	ExtraSIDs []string
+}
+
+// AddUser adds a user account to the realm, with keys derived from its password.
+//
+// Parameters:
+// - username: A string representing the sAMAccountName of the user.
+// - password: A string representing the password of the user.
+// - etypes: A variable number of int32 representing the encryption types of the keys to derive. If none are
+// given, AES256, AES128 and RC4 keys are derived.
+//
+// Returns:
+// - A pointer to the Principal, which requires preauthentication and is a member of Domain Users.
+// - An error if a key cannot be derived.
+func (s *Server) AddUser(username, password string, etypes ...int32) (*Principal, error) {
+	principal, err := newPrincipalWithPassword(s.Realm, username, password, etypes)
+	if err != nil {
+		return nil, err
+	}
+	principal.PrimaryGroupID = 513
+
+	s.AddPrincipal(principal)
+	return principal, nil
+}
+
+// AddComputer adds a computer account to the realm, with keys derived from its password and
+// the host SPNs of the computer.
+//
+// Parameters:
+// - name: A string representing the sAMAccountName of the computer, with or without its trailing "$".
+// - password: A string representing the password of the computer.
+// - etypes: A variable number of int32 representing the encryption types of the keys to derive. If none are
+// given, AES256, AES128 and RC4 keys are derived.
+//
+// Returns:
+// - A pointer to the Principal, which requires preauthentication and is a member of Domain Computers.
+// - An error if a key cannot be derived.
+func (s *Server) AddComputer(name, password string, etypes ...int32) (*Principal, error) {
+	name = strings.TrimSuffix(name, "$") + "$"
+	principal, err := newPrincipalWithPassword(s.Realm, name, password, etypes)
+	if err != nil {
+		return nil, err
+	}
+	principal.PrimaryGroupID = 515
+
+	hostname := strings.ToLower(strings.TrimSuffix(name, "$"))
+	principal.SPNs = []string{
+		"host/" + hostname,
+		"host/" + hostname + "." + strings.ToLower(s.Realm),
+	}
+
+	s.AddPrincipal(principal)
+	return principal, nil
+}
+
+// AddPrincipal adds an account to the realm. A RID is allocated to the account if it has none.
+//
+// Parameters:
+// - principal: A pointer to the Principal to add.
+func (s *Server) AddPrincipal(principal *Principal) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if principal.RID == 0 {
+		principal.RID = s.nextRID
+		s.nextRID++
+	}
+	if principal.Keys == nil {
+		principal.Keys = make(map[int32]*krbcrypto.Key)
+	}
+	s.principals = append(s.principals, principal)
+}
+
+// GetPrincipal returns an account of the realm from its sAMAccountName or one of its SPNs.
+//
+// Parameters:
+// - name: A string representing the sAMAccountName or the SPN of the account, compared case-insensitively.
+//
+// Returns:
+// - A pointer to the Principal, or nil if the realm has no such account.
+func (s *Server) GetPrincipal(name string) *Principal {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Enterprise names and names qualified by the realm are looked up without their realm
+	if index := strings.LastIndex(name, "@"); index != -1 {
+		name = name[:index]
+	}
+
+	for _, principal := range s.principals {
+		if strings.EqualFold(principal.Name, name) {
+			return principal
+		}
+		for _, spn := range principal.SPNs {
+			if strings.EqualFold(spn, name) {
+				return principal
+			}
+		}
+	}
+	return nil
+}
+
+// GetKrbtgtKey returns a key of the krbtgt account, which encrypts the TGTs and signs the PACs.
+//
+// Parameters:
+// - etype: An int32 representing the encryption type of the key.
+//
+// Returns:
+// - A pointer to the krbcrypto.Key, or nil if the krbtgt account has no key of this type.
+func (s *Server) GetKrbtgtKey(etype int32) *krbcrypto.Key {
+	return s.krbtgt.Keys[etype]
+}
+
+// GetSID returns the SID of the account.
+//
+// Parameters:
+// - domainSID: A string representing the SID of the domain of the account.
+//
+// Returns:
+// - A string containing the SID of the account.
+func (p *Principal) GetSID(domainSID string) string {
+	return fmt.Sprintf("%s-%d", domainSID, p.RID)
+}
+
+// lookupPrincipal returns the account designated by a principal name.
+func (s *Server) lookupPrincipal(name types.PrincipalName) *Principal {
+	if len(name.NameString) == 0 {
+		return nil
+	}
+	if name.NameType == nametype.KRB_NT_ENTERPRISE {
+		return s.GetPrincipal(name.NameString[0])
+	}
+	return s.GetPrincipal(strings.Join(name.NameString, "/"))
+}
+
+// selectKey returns the first key of a principal among the given encryption types, or of the
+// encryption type forced by the faults.
+func selectKey(principal *Principal, etypes []int32, faults Faults) *krbcrypto.Key {
+	if faults.EncryptionType != 0 {
+		return principal.Keys[faults.EncryptionType]
+	}
+	for _, etype := range etypes {
+		if key, ok := principal.Keys[etype]; ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// newPrincipalWithPassword creates a principal requiring preauthentication, with keys derived from a password.
+func newPrincipalWithPassword(realm, name, password string, etypes []int32) (*Principal, error) {
+	if len(etypes) == 0 {
+		etypes = defaultEncryptionTypes
+	}
+
+	principal := &Principal{
+		Name:           name,
+		Keys:           make(map[int32]*krbcrypto.Key),
+		KVNO:           1,
+		Salt:           krbcrypto.Salt(realm, name),
+		RequirePreauth: true,
+	}
+	for _, etype := range etypes {
+		key, err := krbcrypto.NewKeyFromPassword(etype, password, principal.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("error deriving the key of %s: %w", name, err)
+		}
+		principal.Keys[etype] = key
+	}
+
+	return principal, nil
+}
+
+// newRandomKey generates a random key of an encryption type.
+func newRandomKey(etype int32) (*krbcrypto.Key, error) {
+	encryptionType, err := krbcrypto.GetEncryptionType(etype)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, encryptionType.GetKeyByteSize())
+	if _, err := rand.Read(value); err != nil {
+		return nil, fmt.Errorf("error generating a key: %w", err)
+	}
+	return &krbcrypto.Key{EType: etype, Value: value}, nil
+}
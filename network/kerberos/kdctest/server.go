@@ -0,0 +1,282 @@
+// Package kdctest provides an in-process KDC for the integration tests of Kerberos clients.
+//
+// The KDC listens on a local TCP port and implements the AS and TGS exchanges of RFC 4120 for a
+// single realm, with encrypted timestamp preauthentication and PACs signed like Active Directory
+// does. Its behaviour can be altered with Faults to test how clients handle errors.
+//
+// PKINIT, FAST, S4U, user-to-user and cross-realm requests are not supported.
+package kdctest
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos"
+
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Maximum difference between the clock of a client and the clock of the KDC
+const MaxClockSkew = time.Duration(5) * time.Minute
+
+// Maximum size of a message accepted by the KDC
+const maxMessageSize = 1 << 20
+
+// Faults alters the behaviour of the KDC to test how clients handle errors.
+type Faults struct {
+	// ClockSkew is added to the clock of the KDC. A skew of more than MaxClockSkew makes it reject
+	// preauthentication timestamps and authenticators with KRB_AP_ERR_SKEW.
+	ClockSkew time.Duration
+
+	// PreauthRequired makes the KDC answer KDC_ERR_PREAUTH_REQUIRED to every AS-REQ, even preauthenticated ones.
+	PreauthRequired bool
+
+	// EncryptionType, if not 0, is used for the encrypted parts of the replies, the session keys and
+	// the tickets instead of the encryption types negotiated with the client.
+	EncryptionType int32
+
+	// ErrorCode, if not 0, is returned to every request.
+	ErrorCode int32
+}
+
+// Server is an in-process KDC serving a single realm, which may trust other realms.
+type Server struct {
+	// Realm is the name of the realm, in uppercase
+	Realm string
+	// DomainSID is the SID of the domain, used to build the SIDs of the principals in the PACs
+	DomainSID string
+
+	listener  net.Listener
+	waitGroup sync.WaitGroup
+
+	mutex       sync.Mutex
+	principals  []*Principal
+	krbtgt      *Principal
+	trusts      []*trust
+	nextRID     uint32
+	faults      Faults
+	connections map[net.Conn]struct{}
+}
+
+// NewServer creates a KDC for a realm and starts listening on a random TCP port of the loopback interface.
+//
+// Parameters:
+// - realm: A string representing the name of the realm.
+//
+// Returns:
+// - A pointer to the running Server, to be stopped with Close.
+// - An error if the KDC cannot listen.
+func NewServer(realm string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error listening: %w", err)
+	}
+
+	subAuthorities := make([]byte, 12)
+	if _, err := rand.Read(subAuthorities); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error generating the domain SID: %w", err)
+	}
+
+	s := &Server{
+		Realm: strings.ToUpper(realm),
+		DomainSID: fmt.Sprintf("S-1-5-21-%d-%d-%d",
+			binary.LittleEndian.Uint32(subAuthorities[0:4]),
+			binary.LittleEndian.Uint32(subAuthorities[4:8]),
+			binary.LittleEndian.Uint32(subAuthorities[8:12]),
+		),
+		listener:    listener,
+		nextRID:     1104,
+		connections: make(map[net.Conn]struct{}),
+	}
+
+	// The krbtgt account has random keys, like in Active Directory
+	s.krbtgt = &Principal{
+		Name:           "krbtgt",
+		SPNs:           []string{"krbtgt/" + s.Realm},
+		Keys:           make(map[int32]*krbcrypto.Key),
+		KVNO:           2,
+		RequirePreauth: true,
+		RID:            502,
+		PrimaryGroupID: 513,
+	}
+	for _, etype := range defaultEncryptionTypes {
+		key, err := newRandomKey(etype)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		s.krbtgt.Keys[etype] = key
+	}
+	s.principals = append(s.principals, s.krbtgt)
+
+	s.waitGroup.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the KDC listens on.
+//
+// Returns:
+// - A string representing the address of the KDC in the "host:port" format.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Config returns a Kerberos configuration using the KDC for its realm.
+//
+// Returns:
+// - A pointer to a gokrb5 config.Config object, usable by gokrb5 clients and by the LDAP session.
+// - An error if the configuration cannot be built.
+func (s *Server) Config() (*config.Config, error) {
+	return kerberos.NewRealmConfig(s.Realm).WithKDCs(s.Realm, s.Addr()).Build()
+}
+
+// SetFaults replaces the faults injected in the replies of the KDC.
+//
+// Parameters:
+// - faults: The Faults to inject. The zero value restores the normal behaviour.
+func (s *Server) SetFaults(faults Faults) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.faults = faults
+}
+
+// Close stops the KDC and closes the open connections.
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mutex.Lock()
+	for conn := range s.connections {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.waitGroup.Wait()
+}
+
+// serve accepts the connections until the listener is closed.
+func (s *Server) serve() {
+	defer s.waitGroup.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		s.connections[conn] = struct{}{}
+		s.mutex.Unlock()
+
+		s.waitGroup.Add(1)
+		go s.serveConnection(conn)
+	}
+}
+
+// serveConnection answers the length-prefixed messages received on a connection until it is closed.
+func (s *Server) serveConnection(conn net.Conn) {
+	defer s.waitGroup.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.connections, conn)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size > maxMessageSize {
+			return
+		}
+		request := make([]byte, size)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+
+		reply := s.Handle(request)
+		binary.BigEndian.PutUint32(header, uint32(len(reply)))
+		if _, err := conn.Write(append(header, reply...)); err != nil {
+			return
+		}
+	}
+}
+
+// Handle processes a Kerberos message and returns the reply of the KDC.
+//
+// Parameters:
+// - request: A byte slice containing a DER encoded AS-REQ or TGS-REQ message.
+//
+// Returns:
+// - A byte slice containing the DER encoded AS-REP, TGS-REP or KRB-ERROR message.
+func (s *Server) Handle(request []byte) []byte {
+	faults := s.getFaults()
+
+	if len(request) == 0 {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, types.PrincipalName{}, "empty request", nil)
+	}
+	if faults.ErrorCode != 0 {
+		return s.newKRBError(faults.ErrorCode, types.PrincipalName{}, "injected error", nil)
+	}
+
+	// Application tags of the AS-REQ and TGS-REQ messages
+	switch request[0] {
+	case 0x6a:
+		return s.handleASReq(request, faults)
+	case 0x6c:
+		return s.handleTGSReq(request, faults)
+	}
+
+	return s.newKRBError(errorcode.KRB_AP_ERR_MSG_TYPE, types.PrincipalName{}, "unsupported message type", nil)
+}
+
+// getFaults returns the faults currently injected.
+func (s *Server) getFaults() Faults {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.faults
+}
+
+// now returns the current time of the KDC, which includes the injected clock skew.
+func (s *Server) now(faults Faults) time.Time {
+	return time.Now().UTC().Add(faults.ClockSkew).Truncate(time.Second)
+}
+
+// krbtgtName returns the name of the ticket-granting service of the realm.
+func (s *Server) krbtgtName() types.PrincipalName {
+	return types.PrincipalName{NameType: nametype.KRB_NT_SRV_INST, NameString: []string{"krbtgt", s.Realm}}
+}
+
+// newKRBError builds a marshalled KRB-ERROR message.
+func (s *Server) newKRBError(code int32, cname types.PrincipalName, text string, eData []byte) []byte {
+	krbError := messages.NewKRBError(s.krbtgtName(), s.Realm, code, text)
+	krbError.STime = s.now(s.getFaults())
+	krbError.Susec = 0
+	if len(cname.NameString) != 0 {
+		krbError.CRealm = s.Realm
+		krbError.CName = cname
+	}
+	krbError.EData = eData
+
+	data, err := krbError.Marshal()
+	if err != nil {
+		return nil
+	}
+	return data
+}
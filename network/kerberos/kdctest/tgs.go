@@ -0,0 +1,196 @@
+package kdctest
+
+import (
+	"fmt"
+	"strings"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos/pac"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/iana"
+	"github.com/jcmturner/gokrb5/v8/iana/asnAppTag"
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/iana/msgtype"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/iana/patype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// handleTGSReq processes a TGS-REQ message and returns a TGS-REP or a KRB-ERROR message.
+func (s *Server) handleTGSReq(request []byte, faults Faults) []byte {
+	var tgsReq messages.TGSReq
+	if err := tgsReq.Unmarshal(request); err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, types.PrincipalName{}, fmt.Sprintf("error unmarshalling TGS-REQ: %s", err), nil)
+	}
+	now := s.now(faults)
+
+	// The TGT and the authenticator are in the AP-REQ of the PA-TGS-REQ padata
+	paTGSReq := getPAData(tgsReq.PAData, patype.PA_TGS_REQ)
+	if paTGSReq == nil {
+		return s.newKRBError(errorcode.KDC_ERR_PADATA_TYPE_NOSUPP, types.PrincipalName{}, "no PA-TGS-REQ padata", nil)
+	}
+	var apReq messages.APReq
+	if err := apReq.Unmarshal(paTGSReq.PADataValue); err != nil {
+		return s.newKRBError(errorcode.KRB_AP_ERR_MSG_TYPE, types.PrincipalName{}, "invalid AP-REQ", nil)
+	}
+
+	tgt := apReq.Ticket
+	if len(tgt.SName.NameString) != 2 || !strings.EqualFold(tgt.SName.NameString[0], "krbtgt") || !strings.EqualFold(tgt.SName.NameString[1], s.Realm) {
+		return s.newKRBError(errorcode.KRB_AP_ERR_NOT_US, types.PrincipalName{}, "ticket is not a TGT of the realm", nil)
+	}
+	// The referral TGTs issued by the KDC of a trusted realm are encrypted with the incoming inter-realm key
+	ticketGrantingService := s.krbtgt
+	if !strings.EqualFold(tgt.Realm, s.Realm) {
+		issuer := s.getTrust(tgt.Realm)
+		if issuer == nil {
+			return s.newKRBError(errorcode.KRB_AP_ERR_NOT_US, types.PrincipalName{}, "TGT issued by an untrusted realm", nil)
+		}
+		ticketGrantingService = issuer.incoming
+	}
+	krbtgtKey, ok := ticketGrantingService.Keys[tgt.EncPart.EType]
+	if !ok {
+		return s.newKRBError(errorcode.KDC_ERR_ETYPE_NOSUPP, types.PrincipalName{}, "no krbtgt key of the encryption type of the TGT", nil)
+	}
+	if err := tgt.Decrypt(types.EncryptionKey{KeyType: krbtgtKey.EType, KeyValue: krbtgtKey.Value}); err != nil {
+		return s.newKRBError(errorcode.KRB_AP_ERR_BAD_INTEGRITY, types.PrincipalName{}, "error decrypting TGT", nil)
+	}
+	tgtPart := tgt.DecryptedEncPart
+	cname := tgtPart.CName
+
+	if now.After(tgtPart.EndTime) {
+		return s.newKRBError(errorcode.KRB_AP_ERR_TKT_EXPIRED, cname, "TGT expired", nil)
+	}
+	if err := apReq.DecryptAuthenticator(tgtPart.Key); err != nil {
+		return s.newKRBError(errorcode.KRB_AP_ERR_BAD_INTEGRITY, cname, "error decrypting authenticator", nil)
+	}
+	if !isWithinClockSkew(apReq.Authenticator.CTime, now) {
+		return s.newKRBError(errorcode.KRB_AP_ERR_SKEW, cname, "clock skew too great", nil)
+	}
+
+	sname := tgsReq.ReqBody.SName
+	service := s.lookupPrincipal(sname)
+	if service == nil && types.IsFlagSet(&tgsReq.ReqBody.KDCOptions, flags.Canonicalize) {
+		// The services of a trusted realm are referred to its KDC with a TGT of its ticket-granting service
+		if referral := s.getReferralTrust(sname); referral != nil {
+			service = referral.outgoing
+			sname = types.PrincipalName{NameType: nametype.KRB_NT_SRV_INST, NameString: []string{"krbtgt", referral.realm}}
+		}
+	}
+	if service == nil {
+		return s.newKRBError(errorcode.KDC_ERR_S_PRINCIPAL_UNKNOWN, cname, "service not found", nil)
+	}
+	ticketKey := selectKey(service, tgsReq.ReqBody.EType, faults)
+	if ticketKey == nil {
+		return s.newKRBError(errorcode.KDC_ERR_ETYPE_NOSUPP, cname, "no key of the service for the requested encryption types", nil)
+	}
+	sessionKey, err := newSessionKey(tgsReq.ReqBody.EType, faults)
+	if err != nil {
+		return s.newKRBError(errorcode.KDC_ERR_ETYPE_NOSUPP, cname, err.Error(), nil)
+	}
+
+	// Ticket flags and times, bounded by the ones of the TGT
+	ticketFlags := types.NewKrbFlags()
+	if types.IsFlagSet(&tgtPart.Flags, flags.PreAuthent) {
+		types.SetFlag(&ticketFlags, flags.PreAuthent)
+	}
+	times := newTicketTimes(tgtPart.AuthTime, now, tgsReq.ReqBody, tgtPart.EndTime, tgtPart.RenewTill)
+	copyRequestedFlags(&ticketFlags, tgsReq.ReqBody.KDCOptions, &tgtPart.Flags, times)
+
+	// The PAC of the TGT is copied into the service ticket and signed again with the key of the service
+	var authorizationData types.AuthorizationData
+	if p, err := pac.FromAuthorizationData(tgtPart.AuthorizationData); err == nil {
+		// The KDC signature of a PAC issued by a trusted realm is made with a key of the trusted realm, only
+		// its server signature made with the inter-realm key can be verified
+		verifyErr := p.VerifyServerSignature(krbtgtKey)
+		if ticketGrantingService == s.krbtgt {
+			verifyErr = p.VerifyKDCSignature(s.getKrbtgtKeyForSignature(p))
+		}
+		if verifyErr != nil {
+			return s.newKRBError(errorcode.KRB_AP_ERR_MODIFIED, cname, "invalid PAC signature", nil)
+		}
+		if _, err := p.Sign(ticketKey, s.getKrbtgtSignatureKey(faults)); err != nil {
+			return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, fmt.Sprintf("error signing PAC: %s", err), nil)
+		}
+		if authorizationData, err = p.ToAuthorizationData(); err != nil {
+			return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+		}
+	}
+
+	ticket, err := newTicket(s.Realm, sname, messages.EncTicketPart{
+		Flags:             ticketFlags,
+		Key:               sessionKey,
+		CRealm:            tgtPart.CRealm,
+		CName:             cname,
+		AuthTime:          times.authTime,
+		StartTime:         times.startTime,
+		EndTime:           times.endTime,
+		RenewTill:         times.renewTill,
+		AuthorizationData: authorizationData,
+	}, ticketKey, service.KVNO)
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+
+	encPart := messages.EncKDCRepPart{
+		Key:       sessionKey,
+		LastReqs:  []messages.LastReq{{LRType: 0, LRValue: now}},
+		Nonce:     tgsReq.ReqBody.Nonce,
+		Flags:     ticketFlags,
+		AuthTime:  times.authTime,
+		StartTime: times.startTime,
+		EndTime:   times.endTime,
+		RenewTill: times.renewTill,
+		SRealm:    s.Realm,
+		SName:     sname,
+	}
+	encPartBytes, err := asn1.Marshal(encPart)
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+	encPartBytes = asn1tools.AddASNAppTag(encPartBytes, asnAppTag.EncTGSRepPart)
+
+	// The reply is encrypted with the subkey of the authenticator if there is one, or with the session key of the TGT
+	replyKey := &krbcrypto.Key{EType: tgtPart.Key.KeyType, Value: tgtPart.Key.KeyValue}
+	usage := krbcrypto.KEY_USAGE_TGS_REP_ENCPART_SESSION_KEY
+	if len(apReq.Authenticator.SubKey.KeyValue) != 0 {
+		replyKey = &krbcrypto.Key{EType: apReq.Authenticator.SubKey.KeyType, Value: apReq.Authenticator.SubKey.KeyValue}
+		usage = krbcrypto.KEY_USAGE_TGS_REP_ENCPART_SUBKEY
+	}
+	encryptedEncPart, err := encrypt(replyKey, usage, encPartBytes, 0)
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+
+	tgsRep := messages.TGSRep{
+		KDCRepFields: messages.KDCRepFields{
+			PVNO:    iana.PVNO,
+			MsgType: msgtype.KRB_TGS_REP,
+			CRealm:  tgtPart.CRealm,
+			CName:   cname,
+			Ticket:  ticket,
+			EncPart: encryptedEncPart,
+		},
+	}
+	reply, err := tgsRep.Marshal()
+	if err != nil {
+		return s.newKRBError(errorcode.KRB_ERR_GENERIC, cname, err.Error(), nil)
+	}
+
+	return reply
+}
+
+// getKrbtgtKeyForSignature returns the key of the krbtgt account matching the type of the KDC signature of a PAC.
+func (s *Server) getKrbtgtKeyForSignature(p *pac.PAC) *krbcrypto.Key {
+	if p.KDCSignature != nil {
+		if encryptionType, err := krbcrypto.GetEncryptionTypeByChecksumType(p.KDCSignature.SignatureType); err == nil {
+			if key, ok := s.krbtgt.Keys[encryptionType.GetETypeID()]; ok {
+				return key
+			}
+		}
+	}
+	return s.getKrbtgtSignatureKey(Faults{})
+}
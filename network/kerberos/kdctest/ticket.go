@@ -0,0 +1,127 @@
+package kdctest
+
+import (
+	"fmt"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/iana"
+	"github.com/jcmturner/gokrb5/v8/iana/asnAppTag"
+	"github.com/jcmturner/gokrb5/v8/iana/flags"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Lifetimes of the tickets issued by the KDC, which are the defaults of Active Directory
+const (
+	MaxTicketLifetime = time.Duration(10) * time.Hour
+	MaxRenewLifetime  = time.Duration(7*24) * time.Hour
+)
+
+// ticketTimes holds the times of a ticket.
+type ticketTimes struct {
+	authTime  time.Time
+	startTime time.Time
+	endTime   time.Time
+	renewTill time.Time
+}
+
+// newTicketTimes computes the times of a ticket from the times requested by the client, bounded by the
+// lifetimes of the KDC and by the end and renew times of the ticket the request is based on, if not zero.
+func newTicketTimes(authTime, now time.Time, reqBody messages.KDCReqBody, maxEndTime, maxRenewTill time.Time) ticketTimes {
+	times := ticketTimes{authTime: authTime, startTime: now, endTime: now.Add(MaxTicketLifetime)}
+	if !reqBody.Till.IsZero() && reqBody.Till.Before(times.endTime) {
+		times.endTime = reqBody.Till.UTC()
+	}
+	if !maxEndTime.IsZero() && maxEndTime.Before(times.endTime) {
+		times.endTime = maxEndTime
+	}
+
+	if types.IsFlagSet(&reqBody.KDCOptions, flags.Renewable) && !reqBody.RTime.IsZero() {
+		times.renewTill = now.Add(MaxRenewLifetime)
+		if reqBody.RTime.Before(times.renewTill) {
+			times.renewTill = reqBody.RTime.UTC()
+		}
+		if !maxRenewTill.IsZero() && maxRenewTill.Before(times.renewTill) {
+			times.renewTill = maxRenewTill
+		}
+	}
+
+	return times
+}
+
+// copyRequestedFlags sets the forwardable, proxiable and renewable flags of a ticket from the options
+// of the request. If allowed is not nil, only the flags it has set are copied.
+func copyRequestedFlags(ticketFlags *asn1.BitString, options asn1.BitString, allowed *asn1.BitString, times ticketTimes) {
+	for _, flag := range []int{flags.Forwardable, flags.Proxiable} {
+		if types.IsFlagSet(&options, flag) && (allowed == nil || types.IsFlagSet(allowed, flag)) {
+			types.SetFlag(ticketFlags, flag)
+		}
+	}
+	if !times.renewTill.IsZero() {
+		types.SetFlag(ticketFlags, flags.Renewable)
+	}
+}
+
+// newTicket encrypts the encrypted part of a ticket with the key of the service it is issued for.
+func newTicket(realm string, sname types.PrincipalName, encTicketPart messages.EncTicketPart, key *krbcrypto.Key, kvno int) (messages.Ticket, error) {
+	data, err := asn1.Marshal(encTicketPart)
+	if err != nil {
+		return messages.Ticket{}, fmt.Errorf("error marshalling the encrypted part of the ticket: %w", err)
+	}
+	data = asn1tools.AddASNAppTag(data, asnAppTag.EncTicketPart)
+
+	encPart, err := encrypt(key, krbcrypto.KEY_USAGE_KDC_REP_TICKET, data, kvno)
+	if err != nil {
+		return messages.Ticket{}, err
+	}
+
+	return messages.Ticket{
+		TktVNO:  iana.PVNO,
+		Realm:   realm,
+		SName:   sname,
+		EncPart: encPart,
+	}, nil
+}
+
+// encrypt encrypts data with a key and returns it as an EncryptedData structure.
+func encrypt(key *krbcrypto.Key, usage uint32, plaintext []byte, kvno int) (types.EncryptedData, error) {
+	ciphertext, err := key.Encrypt(usage, plaintext)
+	if err != nil {
+		return types.EncryptedData{}, fmt.Errorf("error encrypting with key usage %d: %w", usage, err)
+	}
+	return types.EncryptedData{EType: key.EType, KVNO: kvno, Cipher: ciphertext}, nil
+}
+
+// newSessionKey generates a session key of the first encryption type requested by the client that
+// the KDC supports, or of the encryption type forced by the faults.
+func newSessionKey(etypes []int32, faults Faults) (types.EncryptionKey, error) {
+	if faults.EncryptionType != 0 {
+		etypes = []int32{faults.EncryptionType}
+	}
+	for _, etype := range etypes {
+		key, err := newRandomKey(etype)
+		if err != nil {
+			continue
+		}
+		return types.EncryptionKey{KeyType: key.EType, KeyValue: key.Value}, nil
+	}
+	return types.EncryptionKey{}, fmt.Errorf("none of the requested encryption types %v is supported", etypes)
+}
+
+// getKrbtgtSignatureKey returns the key of the krbtgt account used for the KDC signatures of the PACs.
+func (s *Server) getKrbtgtSignatureKey(faults Faults) *krbcrypto.Key {
+	if key := selectKey(s.krbtgt, ticketEncryptionTypes, faults); key != nil {
+		return key
+	}
+	return selectKey(s.krbtgt, ticketEncryptionTypes, Faults{})
+}
+
+// isWithinClockSkew checks whether the time of a client is within MaxClockSkew of the time of the KDC.
+func isWithinClockSkew(clientTime, now time.Time) bool {
+	difference := now.Sub(clientTime)
+	return difference <= MaxClockSkew && difference >= -MaxClockSkew
+}
@@ -0,0 +1,89 @@
+package kdctest
+
+import (
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// trust is a two-way trust of the realm of the KDC with another realm.
+type trust struct {
+	// realm is the name of the trusted realm, in uppercase
+	realm string
+	// outgoing is the inter-realm principal krbtgt/TRUSTED@REALM, whose keys encrypt the referral TGTs
+	// issued for the trusted realm
+	outgoing *Principal
+	// incoming is the inter-realm principal krbtgt/REALM@TRUSTED, whose keys decrypt the referral TGTs
+	// issued by the KDC of the trusted realm
+	incoming *Principal
+}
+
+// AddTrust adds a two-way trust with another realm. The KDC then issues referral TGTs for the services of
+// the trusted realm, and accepts the referral TGTs issued by the KDC of the trusted realm.
+//
+// Parameters:
+// - realm: A string representing the name of the trusted realm.
+// - password: A string representing the password of the trust, from which the inter-realm keys are derived.
+// The same password must be given to the KDC of the trusted realm.
+// - etypes: A variable number of int32 representing the encryption types of the inter-realm keys. If none
+// are given, AES256, AES128 and RC4 keys are derived.
+//
+// Returns:
+// - An error if a key cannot be derived.
+//
+// Note:
+// Like Active Directory KDCs, the KDC only refers the clients setting the name-canonicalize option to the
+// trusted realm, for the SPNs whose host is in the DNS domain of the same name as the trusted realm.
+func (s *Server) AddTrust(realm, password string, etypes ...int32) error {
+	realm = strings.ToUpper(realm)
+
+	outgoing, err := newPrincipalWithPassword(s.Realm, "krbtgt/"+realm, password, etypes)
+	if err != nil {
+		return err
+	}
+	incoming, err := newPrincipalWithPassword(realm, "krbtgt/"+s.Realm, password, etypes)
+	if err != nil {
+		return err
+	}
+
+	// The outgoing principal answers the TGS-REQ of the clients asking for a TGT of the trusted realm
+	s.AddPrincipal(outgoing)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.trusts = append(s.trusts, &trust{realm: realm, outgoing: outgoing, incoming: incoming})
+	return nil
+}
+
+// getTrust returns the trust with a realm, or nil if the realm is not trusted.
+func (s *Server) getTrust(realm string) *trust {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.trusts {
+		if strings.EqualFold(t.realm, realm) {
+			return t
+		}
+	}
+	return nil
+}
+
+// getReferralTrust returns the trust with the realm a service belongs to, from the host of its SPN,
+// or nil if the service is not in a trusted realm.
+func (s *Server) getReferralTrust(sname types.PrincipalName) *trust {
+	if len(sname.NameString) < 2 {
+		return nil
+	}
+	host, _, _ := strings.Cut(strings.ToLower(sname.NameString[1]), ":")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, t := range s.trusts {
+		domain := strings.ToLower(t.realm)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return t
+		}
+	}
+	return nil
+}
@@ -3,7 +3,6 @@ package kerberos
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/jcmturner/gokrb5/v8/config"
 )
@@ -16,8 +15,29 @@ import (
 //
 // Returns:
 // - A string representing the service principal name for LDAP authentication.
-// - A pointer to the Kerberos configuration.
+// - A pointer to the Kerberos configuration, which is empty when it cannot be built, such as when the realm is
+// empty. Use NewKerberosConfig to get the error instead.
 func KerberosInit(fqdnLDAPHost, fqndRealm string) (string, *config.Config) {
+	servicePrincipalName, krb5Conf, err := NewKerberosConfig(fqdnLDAPHost, fqndRealm)
+	if err != nil {
+		// gokrb5 dereferences the configuration, an empty one makes it fail with an error rather than panic
+		return servicePrincipalName, config.New()
+	}
+	return servicePrincipalName, krb5Conf
+}
+
+// NewKerberosConfig builds the Kerberos configuration and service principal name for LDAP authentication, in
+// which the KDC, the kpasswd server and the admin server of the realm are the LDAP server.
+//
+// Parameters:
+// - fqdnLDAPHost: A string representing the fully qualified domain name of the LDAP server.
+// - fqndRealm: A string representing the fully qualified domain name of the realm.
+//
+// Returns:
+// - A string representing the service principal name for LDAP authentication.
+// - A pointer to the Kerberos configuration.
+// - An error if the configuration cannot be built, such as when the realm is empty.
+func NewKerberosConfig(fqdnLDAPHost, fqndRealm string) (string, *config.Config, error) {
 	servicePrincipalName := fmt.Sprintf("ldap/%s", fqdnLDAPHost)
 
 	fqndRealm = strings.ToUpper(fqndRealm)
@@ -30,39 +50,14 @@ func KerberosInit(fqdnLDAPHost, fqndRealm string) (string, *config.Config) {
 	// | 2024/10/08 15:36:16 error querying AD: LDAP Result Code 1 "Operations Error": 000004DC: LdapErr: DSID-0C090A5C,
 	// | comment: In order to perform this operation a successful bind must be completed on the connection., data 0, v4563
 
-	krb5Conf := config.New()
-	// LibDefaults
-	krb5Conf.LibDefaults.AllowWeakCrypto = false
-	krb5Conf.LibDefaults.DefaultRealm = fqndRealm
-	krb5Conf.LibDefaults.DNSLookupRealm = false
-	krb5Conf.LibDefaults.DNSLookupKDC = false
-	krb5Conf.LibDefaults.TicketLifetime = time.Duration(24) * time.Hour
-	krb5Conf.LibDefaults.RenewLifetime = time.Duration(24*7) * time.Hour
-	krb5Conf.LibDefaults.Forwardable = true
-	krb5Conf.LibDefaults.Proxiable = true
-	krb5Conf.LibDefaults.RDNS = false
-	krb5Conf.LibDefaults.UDPPreferenceLimit = 1 // Force use of tcp
-	krb5Conf.LibDefaults.DefaultTGSEnctypes = []string{"aes256-cts-hmac-sha1-96", "aes128-cts-hmac-sha1-96", "arcfour-hmac-md5"}
-	krb5Conf.LibDefaults.DefaultTktEnctypes = []string{"aes256-cts-hmac-sha1-96", "aes128-cts-hmac-sha1-96", "arcfour-hmac-md5"}
-	krb5Conf.LibDefaults.PermittedEnctypes = []string{"aes256-cts-hmac-sha1-96", "aes128-cts-hmac-sha1-96", "arcfour-hmac-md5"}
-	krb5Conf.LibDefaults.PermittedEnctypeIDs = []int32{18, 17, 23}
-	krb5Conf.LibDefaults.DefaultTGSEnctypeIDs = []int32{18, 17, 23}
-	krb5Conf.LibDefaults.DefaultTktEnctypeIDs = []int32{18, 17, 23}
-	krb5Conf.LibDefaults.PreferredPreauthTypes = []int{18, 17, 23}
+	krb5Conf, err := NewRealmConfig(fqndRealm).
+		WithKDCs(fqndRealm, fqdnLDAPHost).
+		WithKPasswdServers(fqndRealm, fqdnLDAPHost).
+		WithAdminServers(fqndRealm, fqdnLDAPHost).
+		Build()
+	if err != nil {
+		return servicePrincipalName, nil, fmt.Errorf("error building the Kerberos configuration: %w", err)
+	}
 
-	// Realms
-	krb5Conf.Realms = append(krb5Conf.Realms, config.Realm{
-		Realm:         fqndRealm,
-		AdminServer:   []string{fqdnLDAPHost},
-		DefaultDomain: fqndRealm,
-		KDC:           []string{fmt.Sprintf("%s:88", fqdnLDAPHost)},
-		KPasswdServer: []string{fmt.Sprintf("%s:464", fqdnLDAPHost)},
-		MasterKDC:     []string{fqdnLDAPHost},
-	})
-
-	// Domain Realm
-	krb5Conf.DomainRealm[strings.ToLower(fqndRealm)] = fqndRealm
-	krb5Conf.DomainRealm[fmt.Sprintf(".%s", strings.ToLower(fqndRealm))] = fqndRealm
-
-	return servicePrincipalName, krb5Conf
+	return servicePrincipalName, krb5Conf, nil
 }
@@ -0,0 +1,66 @@
+package kerberos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+// Version of the keytab file format, in which the integers are in network byte order
+// Src: https://web.mit.edu/kerberos/krb5-devel/doc/formats/keytab_file_format.html
+const keytabVersion uint16 = 0x0502
+
+// NewKeytabFromKeys creates a keytab holding the long-term keys of a principal, to authenticate with gokrb5
+// without knowing its password, such as with an NT hash used as RC4-HMAC key or with AES keys.
+//
+// Parameters:
+// - username: A string representing the name of the principal.
+// - realm: A string representing the realm of the principal.
+// - kvno: A uint32 representing the version number of the keys, which gokrb5 checks against the one of the
+// AS-REP. In Active Directory, it is the msDS-KeyVersionNumber attribute of the account.
+// - keys: A variable number of pointers to the keys of the principal, at most one for each encryption type.
+//
+// Returns:
+// - A pointer to the keytab.
+// - An error if no key is given or if the keytab cannot be built.
+func NewKeytabFromKeys(username, realm string, kvno uint32, keys ...*krbcrypto.Key) (*keytab.Keytab, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+	realm = strings.ToUpper(realm)
+	timestamp := uint32(time.Now().Unix())
+
+	data := binary.BigEndian.AppendUint16(nil, keytabVersion)
+	for _, key := range keys {
+		entry := binary.BigEndian.AppendUint16(nil, 1)
+		entry = appendCountedString(entry, []byte(realm))
+		entry = appendCountedString(entry, []byte(username))
+		entry = binary.BigEndian.AppendUint32(entry, uint32(nametype.KRB_NT_PRINCIPAL))
+		entry = binary.BigEndian.AppendUint32(entry, timestamp)
+		// 8-bit key version number, superseded by the 32-bit one which follows the key
+		entry = append(entry, uint8(kvno))
+		entry = binary.BigEndian.AppendUint16(entry, uint16(key.EType))
+		entry = appendCountedString(entry, key.Value)
+		entry = binary.BigEndian.AppendUint32(entry, kvno)
+
+		data = binary.BigEndian.AppendUint32(data, uint32(len(entry)))
+		data = append(data, entry...)
+	}
+
+	kt := keytab.New()
+	if err := kt.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("error building keytab: %w", err)
+	}
+	return kt, nil
+}
+
+// appendCountedString appends a counted_octet_string of the keytab file format, prefixed by its 16-bit length.
+func appendCountedString(data []byte, value []byte) []byte {
+	data = binary.BigEndian.AppendUint16(data, uint16(len(value)))
+	return append(data, value...)
+}
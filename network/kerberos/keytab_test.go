@@ -0,0 +1,35 @@
+package kerberos
+
+import (
+	"bytes"
+	"testing"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+func TestNewKeytabFromKeys(t *testing.T) {
+	rc4Key := &krbcrypto.Key{EType: krbcrypto.ETYPE_RC4_HMAC, Value: bytes.Repeat([]byte{0x11}, 16)}
+	aesKey := &krbcrypto.Key{EType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, Value: bytes.Repeat([]byte{0x22}, 32)}
+
+	kt, err := NewKeytabFromKeys("alice", "lab.local", 2, rc4Key, aesKey)
+	if err != nil {
+		t.Fatalf("NewKeytabFromKeys returned an error: %v", err)
+	}
+
+	principalName := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, "alice")
+	for _, expected := range []*krbcrypto.Key{rc4Key, aesKey} {
+		key, kvno, err := kt.GetEncryptionKey(principalName, "LAB.LOCAL", 2, expected.EType)
+		if err != nil {
+			t.Fatalf("GetEncryptionKey returned an error for etype %d: %v", expected.EType, err)
+		}
+		if !bytes.Equal(key.KeyValue, expected.Value) || kvno != 2 {
+			t.Errorf("unexpected key %x of version %d for etype %d", key.KeyValue, kvno, expected.EType)
+		}
+	}
+
+	if _, err := NewKeytabFromKeys("alice", "lab.local", 2); err == nil {
+		t.Errorf("expected an error without keys")
+	}
+}
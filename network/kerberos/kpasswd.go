@@ -0,0 +1,470 @@
+package kerberos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/windows/ms_samr"
+
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/kadmin"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc3244
+
+// Protocol version numbers of the kpasswd messages
+const (
+	// KPASSWD_VERSION_CHANGE is the version number of the replies, and of the original change password requests of RFC 3244
+	KPASSWD_VERSION_CHANGE uint16 = 0x0001
+	// KPASSWD_VERSION_SET is the version number of the set password requests of RFC 3244
+	KPASSWD_VERSION_SET uint16 = 0xff80
+)
+
+// Result codes of the kpasswd protocol
+const (
+	KRB5_KPASSWD_SUCCESS             uint16 = 0
+	KRB5_KPASSWD_MALFORMED           uint16 = 1
+	KRB5_KPASSWD_HARDERROR           uint16 = 2
+	KRB5_KPASSWD_AUTHERROR           uint16 = 3
+	KRB5_KPASSWD_SOFTERROR           uint16 = 4
+	KRB5_KPASSWD_ACCESSDENIED        uint16 = 5
+	KRB5_KPASSWD_BAD_VERSION         uint16 = 6
+	KRB5_KPASSWD_INITIAL_FLAG_NEEDED uint16 = 7
+)
+
+// KPasswdResultCodeNames maps the result codes of the kpasswd protocol to their names.
+var KPasswdResultCodeNames = map[uint16]string{
+	KRB5_KPASSWD_SUCCESS:             "KRB5_KPASSWD_SUCCESS",
+	KRB5_KPASSWD_MALFORMED:           "KRB5_KPASSWD_MALFORMED",
+	KRB5_KPASSWD_HARDERROR:           "KRB5_KPASSWD_HARDERROR",
+	KRB5_KPASSWD_AUTHERROR:           "KRB5_KPASSWD_AUTHERROR",
+	KRB5_KPASSWD_SOFTERROR:           "KRB5_KPASSWD_SOFTERROR",
+	KRB5_KPASSWD_ACCESSDENIED:        "KRB5_KPASSWD_ACCESSDENIED",
+	KRB5_KPASSWD_BAD_VERSION:         "KRB5_KPASSWD_BAD_VERSION",
+	KRB5_KPASSWD_INITIAL_FLAG_NEEDED: "KRB5_KPASSWD_INITIAL_FLAG_NEEDED",
+}
+
+// KPasswdResultCodeDescriptions maps the result codes of the kpasswd protocol to their descriptions.
+var KPasswdResultCodeDescriptions = map[uint16]string{
+	KRB5_KPASSWD_SUCCESS:             "Password changed successfully",
+	KRB5_KPASSWD_MALFORMED:           "Request fails basic integrity checks",
+	KRB5_KPASSWD_HARDERROR:           "The server encountered an error",
+	KRB5_KPASSWD_AUTHERROR:           "Authentication failed",
+	KRB5_KPASSWD_SOFTERROR:           "The password does not meet the password policy",
+	KRB5_KPASSWD_ACCESSDENIED:        "The client is not authorized to set the password of the target principal",
+	KRB5_KPASSWD_BAD_VERSION:         "Protocol version is not supported",
+	KRB5_KPASSWD_INITIAL_FLAG_NEEDED: "The ticket must be obtained from an AS exchange",
+}
+
+// Size of the password policy sent by Active Directory in the result string of a KRB5_KPASSWD_SOFTERROR
+const kpasswdPolicySize = 30
+
+// KPasswdPolicy represents the password policy sent by Active Directory in the result string of a
+// KRB5_KPASSWD_SOFTERROR, when the new password does not meet it.
+type KPasswdPolicy struct {
+	MinPasswordLength     uint32
+	PasswordHistoryLength uint32
+	PasswordProperties    ms_samr.PasswordProperties
+	MaxPasswordAge        time.Duration
+	MinPasswordAge        time.Duration
+}
+
+// KPasswdError represents a kpasswd reply whose result code is not KRB5_KPASSWD_SUCCESS.
+type KPasswdError struct {
+	// ResultCode is the result code of the reply
+	ResultCode uint16
+	// ResultString is the raw result string of the reply, either an UTF-8 message or a binary password policy
+	ResultString []byte
+}
+
+// GetPasswordPolicy decodes the password policy sent by Active Directory in the result string.
+//
+// Returns:
+// - A pointer to a KPasswdPolicy object, or nil if the result string does not contain a password policy.
+func (e KPasswdError) GetPasswordPolicy() *KPasswdPolicy {
+	if len(e.ResultString) != kpasswdPolicySize || binary.BigEndian.Uint16(e.ResultString[0:2]) != 0 {
+		return nil
+	}
+
+	// Ages are expressed in intervals of 100 nanoseconds
+	return &KPasswdPolicy{
+		MinPasswordLength:     binary.BigEndian.Uint32(e.ResultString[2:6]),
+		PasswordHistoryLength: binary.BigEndian.Uint32(e.ResultString[6:10]),
+		PasswordProperties:    ms_samr.PasswordProperties(binary.BigEndian.Uint32(e.ResultString[10:14])),
+		MaxPasswordAge:        time.Duration(binary.BigEndian.Uint64(e.ResultString[14:22])) * 100,
+		MinPasswordAge:        time.Duration(binary.BigEndian.Uint64(e.ResultString[22:30])) * 100,
+	}
+}
+
+// Error returns the name and description of the result code, followed by the result string.
+func (e KPasswdError) Error() string {
+	name, ok := KPasswdResultCodeNames[e.ResultCode]
+	if !ok {
+		name = fmt.Sprintf("unknown result code %d", e.ResultCode)
+	}
+	message := fmt.Sprintf("kpasswd error %s", name)
+	if description, ok := KPasswdResultCodeDescriptions[e.ResultCode]; ok {
+		message += ": " + description
+	}
+
+	if policy := e.GetPasswordPolicy(); policy != nil {
+		message += fmt.Sprintf(
+			" (minimum length %d, history length %d, complexity required %t, minimum age %s)",
+			policy.MinPasswordLength,
+			policy.PasswordHistoryLength,
+			policy.PasswordProperties&ms_samr.PASSWORD_PROPERTY_DOMAIN_PASSWORD_COMPLEX != 0,
+			policy.MinPasswordAge,
+		)
+	} else if resultString := strings.TrimSpace(string(e.ResultString)); len(resultString) != 0 {
+		message += fmt.Sprintf(" (%s)", resultString)
+	}
+
+	return message
+}
+
+// NewKPasswdRequest creates a kpasswd request, changing the password of the client of the ticket or setting the
+// password of a target principal.
+//
+// Parameters:
+// - ticket: The ticket to the kadmin/changepw service, or a TGT when setting the password of a target principal.
+// - sessionKey: The session key of the ticket.
+// - cname: The principal name of the client of the ticket.
+// - crealm: A string representing the realm of the client of the ticket.
+// - newPassword: A string representing the new password.
+// - targetName: The principal name of the target, or an empty principal name to change the password of the client.
+// - targetRealm: A string representing the realm of the target.
+// - senderAddress: The IP address of the client, sent as the sender address of the KRB-PRIV message.
+//
+// Returns:
+// - A byte slice containing the kpasswd request.
+// - The subkey of the authenticator, which encrypts the KRB-PRIV message of the reply.
+// - An error if the request cannot be built.
+func NewKPasswdRequest(ticket messages.Ticket, sessionKey types.EncryptionKey, cname types.PrincipalName, crealm, newPassword string, targetName types.PrincipalName, targetRealm string, senderAddress net.IP) ([]byte, types.EncryptionKey, error) {
+	changePasswdData := kadmin.ChangePasswdData{NewPasswd: []byte(newPassword)}
+	if len(targetName.NameString) != 0 {
+		changePasswdData.TargName = targetName
+		changePasswdData.TargRealm = strings.ToUpper(targetRealm)
+	}
+	userData, err := changePasswdData.Marshal()
+	if err != nil {
+		return nil, types.EncryptionKey{}, fmt.Errorf("error marshalling ChangePasswdData: %w", err)
+	}
+
+	// The KRB-PRIV message is encrypted with a subkey of the authenticator
+	authenticator, err := types.NewAuthenticator(strings.ToUpper(crealm), cname)
+	if err != nil {
+		return nil, types.EncryptionKey{}, fmt.Errorf("error building authenticator: %w", err)
+	}
+	keySize := len(sessionKey.KeyValue)
+	err = authenticator.GenerateSeqNumberAndSubKey(sessionKey.KeyType, keySize)
+	if err != nil {
+		return nil, types.EncryptionKey{}, fmt.Errorf("error generating subkey: %w", err)
+	}
+	apReq, err := messages.NewAPReq(ticket, sessionKey, authenticator)
+	if err != nil {
+		return nil, types.EncryptionKey{}, fmt.Errorf("error building AP-REQ: %w", err)
+	}
+	apReqBytes, err := apReq.Marshal()
+	if err != nil {
+		return nil, types.EncryptionKey{}, fmt.Errorf("error marshalling AP-REQ: %w", err)
+	}
+
+	krbPriv := messages.NewKRBPriv(messages.EncKrbPrivPart{
+		UserData:       userData,
+		Timestamp:      authenticator.CTime,
+		Usec:           authenticator.Cusec,
+		SequenceNumber: authenticator.SeqNumber,
+		SAddress:       types.HostAddressFromNetIP(senderAddress),
+	})
+	err = krbPriv.EncryptEncPart(authenticator.SubKey)
+	if err != nil {
+		return nil, types.EncryptionKey{}, fmt.Errorf("error encrypting KRB-PRIV: %w", err)
+	}
+	krbPrivBytes, err := krbPriv.Marshal()
+	if err != nil {
+		return nil, types.EncryptionKey{}, fmt.Errorf("error marshalling KRB-PRIV: %w", err)
+	}
+
+	// message length | version | AP-REQ length | AP-REQ | KRB-PRIV
+	messageLength := 6 + len(apReqBytes) + len(krbPrivBytes)
+	if messageLength > 0xffff {
+		return nil, types.EncryptionKey{}, fmt.Errorf("kpasswd request is too long (%d bytes)", messageLength)
+	}
+	request := make([]byte, 6, messageLength)
+	binary.BigEndian.PutUint16(request[0:2], uint16(messageLength))
+	binary.BigEndian.PutUint16(request[2:4], KPASSWD_VERSION_SET)
+	binary.BigEndian.PutUint16(request[4:6], uint16(len(apReqBytes)))
+	request = append(request, apReqBytes...)
+	request = append(request, krbPrivBytes...)
+
+	return request, authenticator.SubKey, nil
+}
+
+// ParseKPasswdReply parses a kpasswd reply and checks its result code.
+//
+// Parameters:
+// - reply: A byte slice containing the kpasswd reply.
+// - subKey: The subkey of the authenticator of the request.
+//
+// Returns:
+// - nil if the result code is KRB5_KPASSWD_SUCCESS.
+// - A KPasswdError if the result code is not KRB5_KPASSWD_SUCCESS.
+// - An error if the reply is malformed or cannot be decrypted.
+func ParseKPasswdReply(reply []byte, subKey types.EncryptionKey) error {
+	if len(reply) < 6 {
+		return fmt.Errorf("kpasswd reply is too short (%d bytes)", len(reply))
+	}
+	messageLength := int(binary.BigEndian.Uint16(reply[0:2]))
+	if messageLength > len(reply) || messageLength < 6 {
+		return fmt.Errorf("kpasswd reply has an invalid length %d", messageLength)
+	}
+	version := binary.BigEndian.Uint16(reply[2:4])
+	apRepLength := int(binary.BigEndian.Uint16(reply[4:6]))
+	if 6+apRepLength > messageLength {
+		return fmt.Errorf("kpasswd reply has an invalid AP-REP length %d", apRepLength)
+	}
+
+	var userData []byte
+	if apRepLength == 0 {
+		// Errors are sent in the e-data of a KRB-ERROR
+		var krbError messages.KRBError
+		err := krbError.Unmarshal(reply[6:messageLength])
+		if err != nil {
+			return fmt.Errorf("error unmarshalling KRB-ERROR of kpasswd reply: %w", err)
+		}
+		if len(krbError.EData) < 2 {
+			return krbError
+		}
+		userData = krbError.EData
+	} else {
+		if version != KPASSWD_VERSION_CHANGE && version != KPASSWD_VERSION_SET {
+			return fmt.Errorf("kpasswd reply has an unsupported version 0x%04x", version)
+		}
+		var krbPriv messages.KRBPriv
+		err := krbPriv.Unmarshal(reply[6+apRepLength : messageLength])
+		if err != nil {
+			return fmt.Errorf("error unmarshalling KRB-PRIV of kpasswd reply: %w", err)
+		}
+		err = krbPriv.DecryptEncPart(subKey)
+		if err != nil {
+			return fmt.Errorf("error decrypting KRB-PRIV of kpasswd reply: %w", err)
+		}
+		userData = krbPriv.DecryptedEncPart.UserData
+	}
+
+	if len(userData) < 2 {
+		return fmt.Errorf("kpasswd reply has no result code")
+	}
+	resultCode := binary.BigEndian.Uint16(userData[0:2])
+	if resultCode != KRB5_KPASSWD_SUCCESS {
+		return KPasswdError{ResultCode: resultCode, ResultString: userData[2:]}
+	}
+
+	return nil
+}
+
+// SendKPasswd sends a kpasswd request to a kpasswd server and checks the result code of its reply.
+//
+// Parameters:
+// - kpasswdAddress: A string representing the address of the kpasswd server in the "host:port" format.
+// - ticket: The ticket to the kadmin/changepw service, or a TGT when setting the password of a target principal.
+// - sessionKey: The session key of the ticket.
+// - cname: The principal name of the client of the ticket.
+// - crealm: A string representing the realm of the client of the ticket.
+// - newPassword: A string representing the new password.
+// - targetName: The principal name of the target, or an empty principal name to change the password of the client.
+// - targetRealm: A string representing the realm of the target.
+//
+// Returns:
+// - nil if the password was changed.
+// - A KPasswdError if the server refused the change.
+// - An error if the exchange fails.
+func SendKPasswd(kpasswdAddress string, ticket messages.Ticket, sessionKey types.EncryptionKey, cname types.PrincipalName, crealm, newPassword string, targetName types.PrincipalName, targetRealm string) error {
+	conn, err := dialKDC(kpasswdAddress)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var senderAddress net.IP
+	if localAddress, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		senderAddress = localAddress.IP
+	}
+
+	request, subKey, err := NewKPasswdRequest(ticket, sessionKey, cname, crealm, newPassword, targetName, targetRealm, senderAddress)
+	if err != nil {
+		return err
+	}
+
+	reply, err := exchangeWithKDC(conn, kpasswdAddress, request)
+	if err != nil {
+		return err
+	}
+
+	return ParseKPasswdReply(reply, subKey)
+}
+
+// ChangePassword changes the password of a user with the kpasswd protocol, knowing its current password.
+//
+// Parameters:
+// - kdcHost: A string representing the hostname or IP address of the KDC, also serving kpasswd on port 464.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user.
+// - oldPassword: A string representing the current password of the user.
+// - newPassword: A string representing the new password of the user.
+//
+// Returns:
+// - nil if the password was changed.
+// - A KPasswdError if the server refused the change.
+// - An error if the authentication or the exchange fails.
+func ChangePassword(kdcHost, realm, username, oldPassword, newPassword string) error {
+	_, krb5Conf, err := NewKerberosConfig(kdcHost, realm)
+	if err != nil {
+		return err
+	}
+	return ChangePasswordWithConfig(krb5Conf, realm, username, oldPassword, newPassword)
+}
+
+// ChangePasswordWithConfig changes the password of a user with the kpasswd protocol, knowing its current password.
+// The KDC and the kpasswd server are read from the Kerberos configuration.
+//
+// Parameters:
+// - krb5Conf: A pointer to the Kerberos configuration, such as built by a RealmConfig. The kpasswd server is the
+// first kpasswd_server of the realm, or else its first admin_server on port 464.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user.
+// - oldPassword: A string representing the current password of the user.
+// - newPassword: A string representing the new password of the user.
+//
+// Returns:
+// - nil if the password was changed.
+// - A KPasswdError if the server refused the change.
+// - An error if no kpasswd server is configured, or if the authentication or the exchange fails.
+func ChangePasswordWithConfig(krb5Conf *config.Config, realm, username, oldPassword, newPassword string) error {
+	realm = strings.ToUpper(realm)
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{username}}
+
+	kpasswdAddress, err := getKPasswdAddress(krb5Conf, realm)
+	if err != nil {
+		return err
+	}
+	asRep, err := requestTicketWithPassword(krb5Conf, realm, username, oldPassword, true)
+	if err != nil {
+		return err
+	}
+
+	return SendKPasswd(kpasswdAddress, asRep.Ticket, asRep.DecryptedEncPart.Key, cname, realm, newPassword, types.PrincipalName{}, "")
+}
+
+// SetPassword sets the password of a target principal with the kpasswd protocol, authenticating as a user
+// allowed to reset its password.
+//
+// Parameters:
+// - kdcHost: A string representing the hostname or IP address of the KDC, also serving kpasswd on port 464.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user.
+// - password: A string representing the password of the user.
+// - targetUsername: A string representing the name of the target principal.
+// - targetRealm: A string representing the realm of the target principal.
+// - newPassword: A string representing the new password of the target principal.
+//
+// Returns:
+// - nil if the password was set.
+// - A KPasswdError if the server refused the change.
+// - An error if the authentication or the exchange fails.
+func SetPassword(kdcHost, realm, username, password, targetUsername, targetRealm, newPassword string) error {
+	_, krb5Conf, err := NewKerberosConfig(kdcHost, realm)
+	if err != nil {
+		return err
+	}
+	return SetPasswordWithConfig(krb5Conf, realm, username, password, targetUsername, targetRealm, newPassword)
+}
+
+// SetPasswordWithConfig sets the password of a target principal with the kpasswd protocol, authenticating as a
+// user allowed to reset its password. The KDC and the kpasswd server are read from the Kerberos configuration.
+//
+// Parameters:
+// - krb5Conf: A pointer to the Kerberos configuration, such as built by a RealmConfig. The kpasswd server is the
+// first kpasswd_server of the realm of the user, or else its first admin_server on port 464.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user.
+// - password: A string representing the password of the user.
+// - targetUsername: A string representing the name of the target principal.
+// - targetRealm: A string representing the realm of the target principal.
+// - newPassword: A string representing the new password of the target principal.
+//
+// Returns:
+// - nil if the password was set.
+// - A KPasswdError if the server refused the change.
+// - An error if no kpasswd server is configured, or if the authentication or the exchange fails.
+func SetPasswordWithConfig(krb5Conf *config.Config, realm, username, password, targetUsername, targetRealm, newPassword string) error {
+	realm = strings.ToUpper(realm)
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{username}}
+	targetName := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{targetUsername}}
+
+	kpasswdAddress, err := getKPasswdAddress(krb5Conf, realm)
+	if err != nil {
+		return err
+	}
+	asRep, err := requestTicketWithPassword(krb5Conf, realm, username, password, false)
+	if err != nil {
+		return err
+	}
+
+	return SendKPasswd(kpasswdAddress, asRep.Ticket, asRep.DecryptedEncPart.Key, cname, realm, newPassword, targetName, targetRealm)
+}
+
+// getKPasswdAddress returns the address of the kpasswd server of a realm in the Kerberos configuration: its first
+// kpasswd_server, or else its first admin_server on port 464.
+func getKPasswdAddress(krb5Conf *config.Config, realm string) (string, error) {
+	for _, r := range krb5Conf.Realms {
+		if !strings.EqualFold(r.Realm, realm) {
+			continue
+		}
+		if len(r.KPasswdServer) != 0 {
+			return withDefaultPort(r.KPasswdServer[0], 464), nil
+		}
+		if len(r.AdminServer) != 0 {
+			// The admin server may be configured with the port of kadmin
+			host := r.AdminServer[0]
+			if hostname, _, err := net.SplitHostPort(host); err == nil {
+				host = hostname
+			}
+			return withDefaultPort(host, 464), nil
+		}
+	}
+	return "", fmt.Errorf("no kpasswd server or admin server configured for realm %s", realm)
+}
+
+// requestTicketWithPassword performs an AS exchange with the password of a user, for a TGT or for a ticket
+// to the kadmin/changepw service.
+func requestTicketWithPassword(krb5Conf *config.Config, realm, username, password string, forChangePassword bool) (messages.ASRep, error) {
+	kerberosClient := krb5client.NewWithPassword(username, realm, password, krb5Conf, krb5client.DisablePAFXFAST(true))
+	defer kerberosClient.Destroy()
+
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{username}}
+	var asReq messages.ASReq
+	var err error
+	if forChangePassword {
+		asReq, err = messages.NewASReqForChgPasswd(realm, krb5Conf, cname)
+	} else {
+		asReq, err = messages.NewASReqForTGT(realm, krb5Conf, cname)
+	}
+	if err != nil {
+		return messages.ASRep{}, fmt.Errorf("error building AS-REQ: %w", err)
+	}
+
+	asRep, err := kerberosClient.ASExchange(realm, asReq, 0)
+	if err != nil {
+		return messages.ASRep{}, fmt.Errorf("error requesting a ticket for %s: %w", username, err)
+	}
+
+	return asRep, nil
+}
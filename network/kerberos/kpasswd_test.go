@@ -0,0 +1,186 @@
+package kerberos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/kadmin"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// newTestKPasswdReply builds a kpasswd reply holding a KRB-PRIV message with the given user data.
+func newTestKPasswdReply(t *testing.T, subKey types.EncryptionKey, userData []byte) []byte {
+	t.Helper()
+	krbPriv := messages.NewKRBPriv(messages.EncKrbPrivPart{UserData: userData, Timestamp: time.Now().UTC()})
+	if err := krbPriv.EncryptEncPart(subKey); err != nil {
+		t.Fatalf("error encrypting KRB-PRIV: %v", err)
+	}
+	krbPrivBytes, _ := krbPriv.Marshal()
+
+	// The AP-REP is not checked by ParseKPasswdReply
+	apRep := []byte{0x6f, 0x00}
+	reply := make([]byte, 6)
+	binary.BigEndian.PutUint16(reply[0:2], uint16(6+len(apRep)+len(krbPrivBytes)))
+	binary.BigEndian.PutUint16(reply[2:4], KPASSWD_VERSION_CHANGE)
+	binary.BigEndian.PutUint16(reply[4:6], uint16(len(apRep)))
+	return append(append(reply, apRep...), krbPrivBytes...)
+}
+
+func TestNewKPasswdRequest(t *testing.T) {
+	tgt, sessionKey := newTestTGT()
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{"admin"}}
+	targetName := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{"victim"}}
+
+	tests := []struct {
+		name       string
+		targetName types.PrincipalName
+	}{
+		{"ChangePassword", types.PrincipalName{}},
+		{"SetPassword", targetName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, subKey, err := NewKPasswdRequest(tgt, sessionKey, cname, "lab.local", "NewP@ssw0rd", tt.targetName, "lab.local", net.ParseIP("192.168.56.1"))
+			if err != nil {
+				t.Fatalf("NewKPasswdRequest() error = %v", err)
+			}
+
+			if int(binary.BigEndian.Uint16(request[0:2])) != len(request) {
+				t.Errorf("message length = %d, want %d", binary.BigEndian.Uint16(request[0:2]), len(request))
+			}
+			if binary.BigEndian.Uint16(request[2:4]) != KPASSWD_VERSION_SET {
+				t.Errorf("version = 0x%04x, want 0x%04x", binary.BigEndian.Uint16(request[2:4]), KPASSWD_VERSION_SET)
+			}
+			apReqLength := int(binary.BigEndian.Uint16(request[4:6]))
+
+			var krbPriv messages.KRBPriv
+			if err := krbPriv.Unmarshal(request[6+apReqLength:]); err != nil {
+				t.Fatalf("error unmarshalling KRB-PRIV: %v", err)
+			}
+			if err := krbPriv.DecryptEncPart(subKey); err != nil {
+				t.Fatalf("error decrypting KRB-PRIV: %v", err)
+			}
+			var changePasswdData kadmin.ChangePasswdData
+			if _, err := asn1.Unmarshal(krbPriv.DecryptedEncPart.UserData, &changePasswdData); err != nil {
+				t.Fatalf("error unmarshalling ChangePasswdData: %v", err)
+			}
+
+			if string(changePasswdData.NewPasswd) != "NewP@ssw0rd" {
+				t.Errorf("NewPasswd = %q", changePasswdData.NewPasswd)
+			}
+			if !changePasswdData.TargName.Equal(tt.targetName) {
+				t.Errorf("TargName = %v, want %v", changePasswdData.TargName, tt.targetName)
+			}
+			if len(tt.targetName.NameString) != 0 && changePasswdData.TargRealm != "LAB.LOCAL" {
+				t.Errorf("TargRealm = %q, want LAB.LOCAL", changePasswdData.TargRealm)
+			}
+		})
+	}
+}
+
+func TestParseKPasswdReply(t *testing.T) {
+	_, subKey := newTestTGT()
+
+	// Password policy: minimum length 7, history 24, complexity, maximum age 42 days, minimum age 1 day
+	policy := []byte{0x00, 0x00}
+	policy = binary.BigEndian.AppendUint32(policy, 7)
+	policy = binary.BigEndian.AppendUint32(policy, 24)
+	policy = binary.BigEndian.AppendUint32(policy, 1)
+	policy = binary.BigEndian.AppendUint64(policy, uint64(42*24*time.Hour/100))
+	policy = binary.BigEndian.AppendUint64(policy, uint64(24*time.Hour/100))
+
+	tests := []struct {
+		name         string
+		userData     []byte
+		expectedCode uint16
+		expectedText string
+	}{
+		{"Success", []byte{0x00, 0x00}, KRB5_KPASSWD_SUCCESS, ""},
+		{"AccessDenied", append([]byte{0x00, 0x05}, []byte("Access denied")...), KRB5_KPASSWD_ACCESSDENIED, "(Access denied)"},
+		{"PasswordPolicy", append([]byte{0x00, 0x04}, policy...), KRB5_KPASSWD_SOFTERROR, "minimum length 7, history length 24, complexity required true, minimum age 24h0m0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ParseKPasswdReply(newTestKPasswdReply(t, subKey, tt.userData), subKey)
+			if tt.expectedCode == KRB5_KPASSWD_SUCCESS {
+				if err != nil {
+					t.Fatalf("ParseKPasswdReply() error = %v", err)
+				}
+				return
+			}
+
+			var kpasswdError KPasswdError
+			if !errors.As(err, &kpasswdError) {
+				t.Fatalf("ParseKPasswdReply() error = %v, want a KPasswdError", err)
+			}
+			if kpasswdError.ResultCode != tt.expectedCode {
+				t.Errorf("ResultCode = %d, want %d", kpasswdError.ResultCode, tt.expectedCode)
+			}
+			if !strings.Contains(kpasswdError.Error(), tt.expectedText) {
+				t.Errorf("Error() = %q, want it to contain %q", kpasswdError.Error(), tt.expectedText)
+			}
+		})
+	}
+
+	// Errors of the kpasswd server are sent in the e-data of a KRB-ERROR
+	krbError := messages.NewKRBError(types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{"kadmin", "changepw"}}, "LAB.LOCAL", 0, "")
+	krbError.EData = []byte{0x00, 0x01}
+	krbErrorBytes, _ := krbError.Marshal()
+	reply := make([]byte, 6)
+	binary.BigEndian.PutUint16(reply[0:2], uint16(6+len(krbErrorBytes)))
+	binary.BigEndian.PutUint16(reply[2:4], KPASSWD_VERSION_CHANGE)
+	reply = append(reply, krbErrorBytes...)
+
+	var kpasswdError KPasswdError
+	if err := ParseKPasswdReply(reply, subKey); !errors.As(err, &kpasswdError) || kpasswdError.ResultCode != KRB5_KPASSWD_MALFORMED {
+		t.Errorf("ParseKPasswdReply() error = %v, want KRB5_KPASSWD_MALFORMED", err)
+	}
+
+	if err := ParseKPasswdReply(bytes.Repeat([]byte{0x00}, 3), subKey); err == nil {
+		t.Errorf("ParseKPasswdReply() with a truncated reply should fail")
+	}
+}
+
+func TestGetKPasswdAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *RealmConfig
+		expected string
+	}{
+		{"KPasswdServer", NewRealmConfig("lab.local").WithKDCs("LAB.LOCAL", "dc01.lab.local").WithKPasswdServers("LAB.LOCAL", "dc02.lab.local:1464").WithAdminServers("LAB.LOCAL", "dc03.lab.local"), "dc02.lab.local:1464"},
+		{"AdminServer", NewRealmConfig("lab.local").WithKDCs("LAB.LOCAL", "dc01.lab.local").WithAdminServers("LAB.LOCAL", "dc03.lab.local:749"), "dc03.lab.local:464"},
+		{"NoServer", NewRealmConfig("lab.local").WithKDCs("LAB.LOCAL", "dc01.lab.local"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			krb5Conf, err := tt.config.Build()
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			address, err := getKPasswdAddress(krb5Conf, "lab.local")
+			if len(tt.expected) == 0 {
+				if err == nil {
+					t.Errorf("getKPasswdAddress() = %s, want an error", address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getKPasswdAddress() error = %v", err)
+			}
+			if address != tt.expected {
+				t.Errorf("getKPasswdAddress() = %s, want %s", address, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package pac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Flags of the PAC_ATTRIBUTES_INFO structure
+const (
+	PAC_WAS_REQUESTED        uint32 = 0x00000001
+	PAC_WAS_GIVEN_IMPLICITLY uint32 = 0x00000002
+)
+
+// AttributesInfo represents the PAC_ATTRIBUTES_INFO structure, which tells whether the PAC was
+// requested by the client or given implicitly.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/7c81a6cd-9e0c-4dbd-98cc-e1c2b3a3a5d7
+type AttributesInfo struct {
+	// FlagsLength is the number of bits of the flags
+	FlagsLength uint32
+	Flags       uint32
+}
+
+// FromBytes parses a PAC_ATTRIBUTES_INFO structure.
+//
+// Parameters:
+// - data: A byte slice containing the PAC attributes buffer.
+//
+// Returns:
+// - An error if the buffer is malformed, otherwise nil.
+func (a *AttributesInfo) FromBytes(data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("PAC attributes buffer is too short (%d bytes)", len(data))
+	}
+	a.FlagsLength = binary.LittleEndian.Uint32(data[0:4])
+	a.Flags = binary.LittleEndian.Uint32(data[4:8])
+	return nil
+}
+
+// ToBytes encodes the PAC_ATTRIBUTES_INFO structure.
+//
+// Returns:
+// - A byte slice containing the PAC attributes buffer.
+func (a *AttributesInfo) ToBytes() []byte {
+	data := binary.LittleEndian.AppendUint32(nil, a.FlagsLength)
+	return binary.LittleEndian.AppendUint32(data, a.Flags)
+}
+
+// Describe prints a detailed description of the AttributesInfo structure.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (a *AttributesInfo) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	flags := []string{}
+	if a.Flags&PAC_WAS_REQUESTED != 0 {
+		flags = append(flags, "PAC_WAS_REQUESTED")
+	}
+	if a.Flags&PAC_WAS_GIVEN_IMPLICITLY != 0 {
+		flags = append(flags, "PAC_WAS_GIVEN_IMPLICITLY")
+	}
+
+	fmt.Printf("%s<PAC_ATTRIBUTES_INFO structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mFlags\x1b[0m: 0x%08x (%s)\n", indentPrompt, a.Flags, strings.Join(flags, "|"))
+	fmt.Printf("%s └───\n", indentPrompt)
+}
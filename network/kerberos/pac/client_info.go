@@ -0,0 +1,64 @@
+package pac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/ms_dtyp/common/data_structures"
+)
+
+// ClientInfo represents the PAC_CLIENT_INFO structure, which holds the client name and the
+// authentication time of the ticket.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/e465cb27-4bc1-4173-8be0-b5fd64dc9ff7
+type ClientInfo struct {
+	ClientId data_structures.FILETIME
+	Name     string
+}
+
+// FromBytes parses a PAC_CLIENT_INFO structure.
+//
+// Parameters:
+// - data: A byte slice containing the client information buffer.
+//
+// Returns:
+// - An error if the buffer is malformed, otherwise nil.
+func (c *ClientInfo) FromBytes(data []byte) error {
+	if len(data) < 10 {
+		return fmt.Errorf("client information buffer is too short (%d bytes)", len(data))
+	}
+	if _, err := c.ClientId.Unmarshal(data[0:8]); err != nil {
+		return err
+	}
+	nameLength := int(binary.LittleEndian.Uint16(data[8:10]))
+	if 10+nameLength > len(data) {
+		return fmt.Errorf("client name length %d exceeds the buffer size", nameLength)
+	}
+	c.Name = utf16.DecodeUTF16LE(data[10 : 10+nameLength])
+	return nil
+}
+
+// ToBytes encodes the PAC_CLIENT_INFO structure.
+//
+// Returns:
+// - A byte slice containing the client information buffer.
+func (c *ClientInfo) ToBytes() []byte {
+	name := utf16.EncodeUTF16LE(c.Name)
+	data, _ := c.ClientId.Marshal()
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(name)))
+	return append(data, name...)
+}
+
+// Describe prints a detailed description of the ClientInfo structure.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (c *ClientInfo) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<PAC_CLIENT_INFO structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mClientId (UTC)\x1b[0m: %s\n", indentPrompt, c.ClientId.String())
+	fmt.Printf("%s │ \x1b[93mName\x1b[0m: %s\n", indentPrompt, c.Name)
+	fmt.Printf("%s └───\n", indentPrompt)
+}
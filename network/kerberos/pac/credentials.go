@@ -0,0 +1,248 @@
+package pac
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+)
+
+// Flags of the NTLM_SUPPLEMENTAL_CREDENTIAL structure
+const (
+	NTLM_SUPPLEMENTAL_CREDENTIAL_LM_OWF uint32 = 0x00000001
+	NTLM_SUPPLEMENTAL_CREDENTIAL_NT_OWF uint32 = 0x00000002
+)
+
+// CredentialInfo represents the PAC_CREDENTIAL_INFO structure, whose serialized data is encrypted
+// with the reply key of the AS exchange. It is only present when the client used PKINIT.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/cc919d0c-f2eb-4f21-b487-080c486d85fe
+type CredentialInfo struct {
+	Version        uint32
+	EncryptionType int32
+	SerializedData []byte
+}
+
+// CredentialData represents the PAC_CREDENTIAL_DATA structure, once decrypted.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/4927158e-c9d5-493d-a3f6-1826b88d22ba
+type CredentialData struct {
+	Credentials []SupplementalCredential
+}
+
+// SupplementalCredential represents the SECPKG_SUPPLEMENTAL_CRED structure, holding the credentials
+// of a security package.
+type SupplementalCredential struct {
+	PackageName string
+	Credentials []byte
+}
+
+// NTLMSupplementalCredential represents the NTLM_SUPPLEMENTAL_CREDENTIAL structure, which holds the
+// LM and NT hashes of the user.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/39f588d6-21e3-4ab1-8a9e-5c1d2d7c5a8a
+type NTLMSupplementalCredential struct {
+	Version uint32
+	Flags   uint32
+	LMHash  [16]byte
+	NTHash  [16]byte
+}
+
+// FromBytes parses a PAC_CREDENTIAL_INFO structure.
+//
+// Parameters:
+// - data: A byte slice containing the credentials information buffer.
+//
+// Returns:
+// - An error if the buffer is malformed, otherwise nil.
+func (c *CredentialInfo) FromBytes(data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("credentials information buffer is too short (%d bytes)", len(data))
+	}
+	c.Version = binary.LittleEndian.Uint32(data[0:4])
+	c.EncryptionType = int32(binary.LittleEndian.Uint32(data[4:8]))
+	c.SerializedData = append([]byte{}, data[8:]...)
+	return nil
+}
+
+// Decrypt decrypts the serialized data of the PAC_CREDENTIAL_INFO structure and parses the
+// PAC_CREDENTIAL_DATA structure it contains.
+//
+// Parameters:
+// - asReplyKey: The key used to encrypt the AS-REP, which for PKINIT is derived from the Diffie-Hellman
+// exchange or from the key sent by the KDC.
+//
+// Returns:
+// - A pointer to the decrypted CredentialData.
+// - An error if the key does not match the encryption type or if the decryption or parsing fails.
+func (c *CredentialInfo) Decrypt(asReplyKey *krbcrypto.Key) (*CredentialData, error) {
+	if asReplyKey.EType != c.EncryptionType {
+		return nil, fmt.Errorf("credentials are encrypted with encryption type %d, but the key is of type %d", c.EncryptionType, asReplyKey.EType)
+	}
+
+	plaintext, err := asReplyKey.Decrypt(krbcrypto.KEY_USAGE_KERB_NON_KERB_SALT, c.SerializedData)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting PAC credentials: %w", err)
+	}
+
+	credentialData := &CredentialData{}
+	if err := credentialData.FromBytes(plaintext); err != nil {
+		return nil, fmt.Errorf("error parsing PAC credentials: %w", err)
+	}
+
+	return credentialData, nil
+}
+
+// Describe prints a detailed description of the CredentialInfo structure.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (c *CredentialInfo) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<PAC_CREDENTIAL_INFO structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mVersion\x1b[0m: %d\n", indentPrompt, c.Version)
+	fmt.Printf("%s │ \x1b[93mEncryptionType\x1b[0m: %d\n", indentPrompt, c.EncryptionType)
+	fmt.Printf("%s │ \x1b[93mSerializedData\x1b[0m: %d encrypted bytes\n", indentPrompt, len(c.SerializedData))
+	fmt.Printf("%s └───\n", indentPrompt)
+}
+
+// FromBytes parses the NDR encoded PAC_CREDENTIAL_DATA structure.
+//
+// Parameters:
+// - data: A byte slice containing the decrypted serialized data of a PAC_CREDENTIAL_INFO structure.
+//
+// Returns:
+// - An error if the structure is malformed, otherwise nil.
+func (c *CredentialData) FromBytes(data []byte) error {
+	r, err := newNDRTypeSerializationReader(data)
+	if err != nil {
+		return err
+	}
+
+	// Top level pointer to the structure
+	if _, err := r.readPointer(); err != nil {
+		return err
+	}
+
+	// The maximum count of the conformant array is placed before the structure
+	maxCount, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+	count, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+	if maxCount != count {
+		return fmt.Errorf("credential count %d does not match the conformant array size %d", count, maxCount)
+	}
+
+	names := make([]ndrUnicodeString, count)
+	pointers := make([]uint32, count)
+	for i := range names {
+		if names[i], err = r.readUnicodeStringHeader(); err != nil {
+			return err
+		}
+		// CredentialSize, the size of the array is read again as its conformance
+		if _, err := r.readUint32(); err != nil {
+			return err
+		}
+		if pointers[i], err = r.readPointer(); err != nil {
+			return err
+		}
+	}
+
+	c.Credentials = make([]SupplementalCredential, count)
+	for i := range c.Credentials {
+		if c.Credentials[i].PackageName, err = r.readUnicodeStringBuffer(names[i]); err != nil {
+			return err
+		}
+		if pointers[i] == 0 {
+			continue
+		}
+		size, err := r.readUint32()
+		if err != nil {
+			return err
+		}
+		credentials, err := r.read(int(size))
+		if err != nil {
+			return err
+		}
+		c.Credentials[i].Credentials = append([]byte{}, credentials...)
+	}
+
+	return nil
+}
+
+// GetNTLMCredential returns the credentials of the NTLM security package.
+//
+// Returns:
+// - A pointer to the NTLMSupplementalCredential structure.
+// - An error if the credentials do not contain an NTLM package or if it is malformed.
+func (c *CredentialData) GetNTLMCredential() (*NTLMSupplementalCredential, error) {
+	for _, credential := range c.Credentials {
+		if !strings.EqualFold(credential.PackageName, "NTLM") {
+			continue
+		}
+		ntlmCredential := &NTLMSupplementalCredential{}
+		if err := ntlmCredential.FromBytes(credential.Credentials); err != nil {
+			return nil, err
+		}
+		return ntlmCredential, nil
+	}
+	return nil, fmt.Errorf("PAC credentials do not contain an NTLM package")
+}
+
+// FromBytes parses a NTLM_SUPPLEMENTAL_CREDENTIAL structure.
+//
+// Parameters:
+// - data: A byte slice containing the credentials of the NTLM security package.
+//
+// Returns:
+// - An error if the buffer is malformed, otherwise nil.
+func (n *NTLMSupplementalCredential) FromBytes(data []byte) error {
+	if len(data) < 40 {
+		return fmt.Errorf("NTLM supplemental credential is too short (%d bytes)", len(data))
+	}
+	n.Version = binary.LittleEndian.Uint32(data[0:4])
+	n.Flags = binary.LittleEndian.Uint32(data[4:8])
+	copy(n.LMHash[:], data[8:24])
+	copy(n.NTHash[:], data[24:40])
+	return nil
+}
+
+// GetNTHashHex returns the NT hash as an hexadecimal string, or an empty string if it is not present.
+func (n *NTLMSupplementalCredential) GetNTHashHex() string {
+	if n.Flags&NTLM_SUPPLEMENTAL_CREDENTIAL_NT_OWF == 0 {
+		return ""
+	}
+	return hex.EncodeToString(n.NTHash[:])
+}
+
+// GetLMHashHex returns the LM hash as an hexadecimal string, or an empty string if it is not present.
+func (n *NTLMSupplementalCredential) GetLMHashHex() string {
+	if n.Flags&NTLM_SUPPLEMENTAL_CREDENTIAL_LM_OWF == 0 {
+		return ""
+	}
+	return hex.EncodeToString(n.LMHash[:])
+}
+
+// GetNTLMCredential decrypts the credentials information buffer of the PAC and returns the NTLM
+// credentials it holds, which is how the NT hash of a user is recovered after a PKINIT authentication.
+//
+// Parameters:
+// - asReplyKey: The key used to encrypt the AS-REP.
+//
+// Returns:
+// - A pointer to the NTLMSupplementalCredential structure.
+// - An error if the PAC has no credentials information buffer or if it cannot be decrypted.
+func (p *PAC) GetNTLMCredential(asReplyKey *krbcrypto.Key) (*NTLMSupplementalCredential, error) {
+	if p.CredentialsInfo == nil {
+		return nil, fmt.Errorf("PAC does not contain a credentials information buffer")
+	}
+	credentialData, err := p.CredentialsInfo.Decrypt(asReplyKey)
+	if err != nil {
+		return nil, err
+	}
+	return credentialData.GetNTLMCredential()
+}
@@ -0,0 +1,514 @@
+package pac
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/ms_dtyp/common/data_structures"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/69e86ccc-85e3-41b9-b514-7d969cd0ed73
+
+// UserFlags of the KERB_VALIDATION_INFO structure
+const (
+	LOGON_EXTRA_SIDS      uint32 = 0x00000020
+	LOGON_RESOURCE_GROUPS uint32 = 0x00000200
+)
+
+// Attributes of the groups and SIDs of the KERB_VALIDATION_INFO structure
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/311aab27-ebdf-47f7-b939-13dc99b15341
+const (
+	SE_GROUP_MANDATORY          uint32 = 0x00000001
+	SE_GROUP_ENABLED_BY_DEFAULT uint32 = 0x00000002
+	SE_GROUP_ENABLED            uint32 = 0x00000004
+	SE_GROUP_OWNER              uint32 = 0x00000008
+	SE_GROUP_RESOURCE           uint32 = 0x20000000
+)
+
+// GroupMembership represents a GROUP_MEMBERSHIP structure, a group RID relative to a domain SID.
+type GroupMembership struct {
+	RelativeId uint32
+	Attributes uint32
+}
+
+// SIDAndAttributes represents a KERB_SID_AND_ATTRIBUTES structure.
+type SIDAndAttributes struct {
+	SID        string
+	Attributes uint32
+}
+
+// KerbValidationInfo represents the KERB_VALIDATION_INFO structure of the logon information
+// buffer, which holds the user information and group memberships.
+type KerbValidationInfo struct {
+	LogonTime          data_structures.FILETIME
+	LogoffTime         data_structures.FILETIME
+	KickOffTime        data_structures.FILETIME
+	PasswordLastSet    data_structures.FILETIME
+	PasswordCanChange  data_structures.FILETIME
+	PasswordMustChange data_structures.FILETIME
+
+	EffectiveName      string
+	FullName           string
+	LogonScript        string
+	ProfilePath        string
+	HomeDirectory      string
+	HomeDirectoryDrive string
+
+	LogonCount       uint16
+	BadPasswordCount uint16
+
+	UserId         uint32
+	PrimaryGroupId uint32
+	GroupIds       []GroupMembership
+
+	UserFlags      uint32
+	UserSessionKey []byte
+
+	LogonServer     string
+	LogonDomainName string
+	LogonDomainId   string
+
+	UserAccountControl uint32
+	SubAuthStatus      uint32
+
+	LastSuccessfulILogon data_structures.FILETIME
+	LastFailedILogon     data_structures.FILETIME
+	FailedILogonCount    uint32
+
+	ExtraSids []SIDAndAttributes
+
+	ResourceGroupDomainSid string
+	ResourceGroupIds       []GroupMembership
+}
+
+// FromBytes parses the NDR encoded KERB_VALIDATION_INFO structure of a logon information buffer.
+//
+// Parameters:
+// - data: A byte slice containing the logon information buffer.
+//
+// Returns:
+// - An error if the buffer is malformed, otherwise nil.
+func (k *KerbValidationInfo) FromBytes(data []byte) error {
+	r, err := newNDRTypeSerializationReader(data)
+	if err != nil {
+		return err
+	}
+
+	// Top level pointer to the structure
+	if _, err := r.readPointer(); err != nil {
+		return err
+	}
+
+	// Inline part of the structure, the pointed data is deferred after it
+	times := []*data_structures.FILETIME{&k.LogonTime, &k.LogoffTime, &k.KickOffTime, &k.PasswordLastSet, &k.PasswordCanChange, &k.PasswordMustChange}
+	for _, ft := range times {
+		if *ft, err = r.readFILETIME(); err != nil {
+			return err
+		}
+	}
+
+	names := make([]ndrUnicodeString, 6)
+	for i := range names {
+		if names[i], err = r.readUnicodeStringHeader(); err != nil {
+			return err
+		}
+	}
+
+	if k.LogonCount, err = r.readUint16(); err != nil {
+		return err
+	}
+	if k.BadPasswordCount, err = r.readUint16(); err != nil {
+		return err
+	}
+	if k.UserId, err = r.readUint32(); err != nil {
+		return err
+	}
+	if k.PrimaryGroupId, err = r.readUint32(); err != nil {
+		return err
+	}
+	groupCount, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+	groupIdsPointer, err := r.readPointer()
+	if err != nil {
+		return err
+	}
+	if k.UserFlags, err = r.readUint32(); err != nil {
+		return err
+	}
+	userSessionKey, err := r.read(16)
+	if err != nil {
+		return err
+	}
+	k.UserSessionKey = append([]byte{}, userSessionKey...)
+
+	logonServer, err := r.readUnicodeStringHeader()
+	if err != nil {
+		return err
+	}
+	logonDomainName, err := r.readUnicodeStringHeader()
+	if err != nil {
+		return err
+	}
+	logonDomainIdPointer, err := r.readPointer()
+	if err != nil {
+		return err
+	}
+
+	// Reserved1
+	if _, err := r.read(8); err != nil {
+		return err
+	}
+	if k.UserAccountControl, err = r.readUint32(); err != nil {
+		return err
+	}
+	if k.SubAuthStatus, err = r.readUint32(); err != nil {
+		return err
+	}
+	if k.LastSuccessfulILogon, err = r.readFILETIME(); err != nil {
+		return err
+	}
+	if k.LastFailedILogon, err = r.readFILETIME(); err != nil {
+		return err
+	}
+	if k.FailedILogonCount, err = r.readUint32(); err != nil {
+		return err
+	}
+	// Reserved3
+	if _, err := r.readUint32(); err != nil {
+		return err
+	}
+	sidCount, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+	extraSidsPointer, err := r.readPointer()
+	if err != nil {
+		return err
+	}
+	resourceGroupDomainSidPointer, err := r.readPointer()
+	if err != nil {
+		return err
+	}
+	resourceGroupCount, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+	resourceGroupIdsPointer, err := r.readPointer()
+	if err != nil {
+		return err
+	}
+
+	// Deferred pointers, in the order they appear in the structure
+	values := []*string{&k.EffectiveName, &k.FullName, &k.LogonScript, &k.ProfilePath, &k.HomeDirectory, &k.HomeDirectoryDrive}
+	for i, value := range values {
+		if *value, err = r.readUnicodeStringBuffer(names[i]); err != nil {
+			return err
+		}
+	}
+
+	if groupIdsPointer != 0 {
+		if k.GroupIds, err = readGroupMemberships(r, groupCount); err != nil {
+			return err
+		}
+	}
+
+	if k.LogonServer, err = r.readUnicodeStringBuffer(logonServer); err != nil {
+		return err
+	}
+	if k.LogonDomainName, err = r.readUnicodeStringBuffer(logonDomainName); err != nil {
+		return err
+	}
+
+	if logonDomainIdPointer != 0 {
+		if k.LogonDomainId, err = readSIDString(r); err != nil {
+			return err
+		}
+	}
+
+	if extraSidsPointer != 0 {
+		if k.ExtraSids, err = readSIDAndAttributes(r, sidCount); err != nil {
+			return err
+		}
+	}
+
+	if resourceGroupDomainSidPointer != 0 {
+		if k.ResourceGroupDomainSid, err = readSIDString(r); err != nil {
+			return err
+		}
+	}
+
+	if resourceGroupIdsPointer != 0 {
+		if k.ResourceGroupIds, err = readGroupMemberships(r, resourceGroupCount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ToBytes encodes the KERB_VALIDATION_INFO structure into a logon information buffer, with the NDR
+// type serialization headers.
+//
+// Returns:
+// - A byte slice containing the logon information buffer.
+// - An error if one of the SIDs of the structure is not valid.
+func (k *KerbValidationInfo) ToBytes() ([]byte, error) {
+	w := &ndrWriter{}
+
+	// Top level pointer to the structure
+	w.writePointer(false)
+
+	// Inline part of the structure, the pointed data is deferred after it
+	for _, ft := range []data_structures.FILETIME{k.LogonTime, k.LogoffTime, k.KickOffTime, k.PasswordLastSet, k.PasswordCanChange, k.PasswordMustChange} {
+		w.writeFILETIME(ft)
+	}
+
+	names := []string{k.EffectiveName, k.FullName, k.LogonScript, k.ProfilePath, k.HomeDirectory, k.HomeDirectoryDrive}
+	for _, name := range names {
+		w.writeUnicodeStringHeader(name)
+	}
+
+	w.writeUint16(k.LogonCount)
+	w.writeUint16(k.BadPasswordCount)
+	w.writeUint32(k.UserId)
+	w.writeUint32(k.PrimaryGroupId)
+	w.writeUint32(uint32(len(k.GroupIds)))
+	w.writePointer(len(k.GroupIds) == 0)
+	w.writeUint32(k.UserFlags)
+	userSessionKey := make([]byte, 16)
+	copy(userSessionKey, k.UserSessionKey)
+	w.write(userSessionKey)
+
+	w.writeUnicodeStringHeader(k.LogonServer)
+	w.writeUnicodeStringHeader(k.LogonDomainName)
+	w.writePointer(len(k.LogonDomainId) == 0)
+
+	// Reserved1
+	w.write(make([]byte, 8))
+	w.writeUint32(k.UserAccountControl)
+	w.writeUint32(k.SubAuthStatus)
+	w.writeFILETIME(k.LastSuccessfulILogon)
+	w.writeFILETIME(k.LastFailedILogon)
+	w.writeUint32(k.FailedILogonCount)
+	// Reserved3
+	w.writeUint32(0)
+	w.writeUint32(uint32(len(k.ExtraSids)))
+	w.writePointer(len(k.ExtraSids) == 0)
+	w.writePointer(len(k.ResourceGroupDomainSid) == 0)
+	w.writeUint32(uint32(len(k.ResourceGroupIds)))
+	w.writePointer(len(k.ResourceGroupIds) == 0)
+
+	// Deferred pointers, in the order they appear in the structure
+	for _, name := range names {
+		w.writeUnicodeStringBuffer(name)
+	}
+
+	writeGroupMemberships(w, k.GroupIds)
+
+	w.writeUnicodeStringBuffer(k.LogonServer)
+	w.writeUnicodeStringBuffer(k.LogonDomainName)
+
+	if len(k.LogonDomainId) != 0 {
+		if err := writeSIDString(w, k.LogonDomainId); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(k.ExtraSids) != 0 {
+		w.writeUint32(uint32(len(k.ExtraSids)))
+		for _, extraSid := range k.ExtraSids {
+			w.writePointer(len(extraSid.SID) == 0)
+			w.writeUint32(extraSid.Attributes)
+		}
+		for _, extraSid := range k.ExtraSids {
+			if len(extraSid.SID) == 0 {
+				continue
+			}
+			if err := writeSIDString(w, extraSid.SID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(k.ResourceGroupDomainSid) != 0 {
+		if err := writeSIDString(w, k.ResourceGroupDomainSid); err != nil {
+			return nil, err
+		}
+	}
+
+	writeGroupMemberships(w, k.ResourceGroupIds)
+
+	return w.typeSerializationBytes(), nil
+}
+
+// writeGroupMemberships writes a conformant array of GROUP_MEMBERSHIP structures, if it is not empty.
+func writeGroupMemberships(w *ndrWriter, groups []GroupMembership) {
+	if len(groups) == 0 {
+		return
+	}
+	w.writeUint32(uint32(len(groups)))
+	for _, group := range groups {
+		w.writeUint32(group.RelativeId)
+		w.writeUint32(group.Attributes)
+	}
+}
+
+// writeSIDString writes a SID given in its string form as a conformant RPC_SID structure.
+func writeSIDString(w *ndrWriter, SID string) error {
+	parsedSID, err := sid.FromString(SID)
+	if err != nil {
+		return err
+	}
+	return w.writeSID(parsedSID.ToBytes())
+}
+
+// readSIDString reads a conformant RPC_SID structure and returns it in its string form.
+func readSIDString(r *ndrReader) (string, error) {
+	data, err := r.readSID()
+	if err != nil {
+		return "", err
+	}
+	parsedSID, err := sid.FromBytes(data)
+	if err != nil {
+		return "", err
+	}
+	return parsedSID.String(), nil
+}
+
+// readGroupMemberships reads a conformant array of GROUP_MEMBERSHIP structures.
+func readGroupMemberships(r *ndrReader, count uint32) ([]GroupMembership, error) {
+	maxCount, err := r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if maxCount != count {
+		return nil, fmt.Errorf("group count %d does not match the conformant array size %d", count, maxCount)
+	}
+
+	groups := make([]GroupMembership, 0, count)
+	for i := uint32(0); i < count; i++ {
+		group := GroupMembership{}
+		if group.RelativeId, err = r.readUint32(); err != nil {
+			return nil, err
+		}
+		if group.Attributes, err = r.readUint32(); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// readSIDAndAttributes reads a conformant array of KERB_SID_AND_ATTRIBUTES structures, whose SIDs
+// are deferred after the array.
+func readSIDAndAttributes(r *ndrReader, count uint32) ([]SIDAndAttributes, error) {
+	maxCount, err := r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if maxCount != count {
+		return nil, fmt.Errorf("SID count %d does not match the conformant array size %d", count, maxCount)
+	}
+
+	pointers := make([]uint32, count)
+	sids := make([]SIDAndAttributes, count)
+	for i := range sids {
+		if pointers[i], err = r.readPointer(); err != nil {
+			return nil, err
+		}
+		if sids[i].Attributes, err = r.readUint32(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range sids {
+		if pointers[i] == 0 {
+			continue
+		}
+		var err error
+		if sids[i].SID, err = readSIDString(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return sids, nil
+}
+
+// GetUserSID returns the SID of the user, built from the logon domain SID and the user RID.
+//
+// Returns:
+// - A string containing the SID of the user.
+func (k *KerbValidationInfo) GetUserSID() string {
+	return fmt.Sprintf("%s-%d", k.LogonDomainId, k.UserId)
+}
+
+// GetGroupSIDs returns the SIDs of all the groups the user is a member of, including the extra
+// SIDs and the resource groups.
+//
+// Returns:
+// - A slice of strings containing the SIDs of the groups.
+func (k *KerbValidationInfo) GetGroupSIDs() []string {
+	sids := make([]string, 0, len(k.GroupIds)+len(k.ExtraSids)+len(k.ResourceGroupIds))
+	for _, group := range k.GroupIds {
+		sids = append(sids, fmt.Sprintf("%s-%d", k.LogonDomainId, group.RelativeId))
+	}
+	for _, extraSid := range k.ExtraSids {
+		sids = append(sids, extraSid.SID)
+	}
+	for _, group := range k.ResourceGroupIds {
+		sids = append(sids, fmt.Sprintf("%s-%d", k.ResourceGroupDomainSid, group.RelativeId))
+	}
+	return sids
+}
+
+// Describe prints a detailed description of the KerbValidationInfo structure.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (k *KerbValidationInfo) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<KERB_VALIDATION_INFO structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mLogonTime (UTC)\x1b[0m: %s\n", indentPrompt, k.LogonTime.String())
+	fmt.Printf("%s │ \x1b[93mPasswordLastSet (UTC)\x1b[0m: %s\n", indentPrompt, k.PasswordLastSet.String())
+	fmt.Printf("%s │ \x1b[93mEffectiveName\x1b[0m: %s\n", indentPrompt, k.EffectiveName)
+	fmt.Printf("%s │ \x1b[93mFullName\x1b[0m: %s\n", indentPrompt, k.FullName)
+	fmt.Printf("%s │ \x1b[93mLogonCount\x1b[0m: %d\n", indentPrompt, k.LogonCount)
+	fmt.Printf("%s │ \x1b[93mBadPasswordCount\x1b[0m: %d\n", indentPrompt, k.BadPasswordCount)
+	fmt.Printf("%s │ \x1b[93mUserSID\x1b[0m: %s\n", indentPrompt, k.GetUserSID())
+	fmt.Printf("%s │ \x1b[93mPrimaryGroupId\x1b[0m: %s\n", indentPrompt, describeRID(k.PrimaryGroupId))
+	fmt.Printf("%s │ \x1b[93mGroupIds\x1b[0m: %d\n", indentPrompt, len(k.GroupIds))
+	for _, group := range k.GroupIds {
+		fmt.Printf("%s │  - %s (attributes 0x%08x)\n", indentPrompt, describeRID(group.RelativeId), group.Attributes)
+	}
+	fmt.Printf("%s │ \x1b[93mUserFlags\x1b[0m: 0x%08x\n", indentPrompt, k.UserFlags)
+	fmt.Printf("%s │ \x1b[93mLogonServer\x1b[0m: %s\n", indentPrompt, k.LogonServer)
+	fmt.Printf("%s │ \x1b[93mLogonDomainName\x1b[0m: %s\n", indentPrompt, k.LogonDomainName)
+	fmt.Printf("%s │ \x1b[93mLogonDomainId\x1b[0m: %s\n", indentPrompt, k.LogonDomainId)
+	fmt.Printf("%s │ \x1b[93mUserAccountControl\x1b[0m: 0x%08x\n", indentPrompt, k.UserAccountControl)
+	fmt.Printf("%s │ \x1b[93mExtraSids\x1b[0m: %d\n", indentPrompt, len(k.ExtraSids))
+	for _, extraSid := range k.ExtraSids {
+		fmt.Printf("%s │  - %s (attributes 0x%08x)\n", indentPrompt, extraSid.SID, extraSid.Attributes)
+	}
+	if k.ResourceGroupDomainSid != "" {
+		fmt.Printf("%s │ \x1b[93mResourceGroupDomainSid\x1b[0m: %s\n", indentPrompt, k.ResourceGroupDomainSid)
+		for _, group := range k.ResourceGroupIds {
+			fmt.Printf("%s │  - %d (attributes 0x%08x)\n", indentPrompt, group.RelativeId, group.Attributes)
+		}
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
+
+// describeRID returns a RID followed by the name of the group or account when it is a predefined domain RID.
+func describeRID(rid uint32) string {
+	name := ldap_attributes.GetDomainRIDName(int(rid))
+	if name == "" {
+		return fmt.Sprintf("%d", rid)
+	}
+	return fmt.Sprintf("%d (%s)", rid, name)
+}
@@ -0,0 +1,247 @@
+package pac
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/ms_dtyp/common/data_structures"
+)
+
+// Size of the common and private headers of the NDR type serialization version 1
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-rpce/9a1d0f97-eac0-49ab-a197-f1a581c2d6a0
+const ndrTypeSerializationHeaderSize = 16
+
+// ndrReader reads NDR encoded little endian data, keeping track of the current offset
+// so that primitive types can be aligned on their natural boundary.
+type ndrReader struct {
+	data   []byte
+	offset int
+}
+
+// newNDRTypeSerializationReader creates a reader over a buffer encoded with the type serialization
+// version 1 of MS-RPCE, checking and skipping its common and private headers.
+func newNDRTypeSerializationReader(data []byte) (*ndrReader, error) {
+	if len(data) < ndrTypeSerializationHeaderSize {
+		return nil, fmt.Errorf("buffer is too short to contain the NDR type serialization headers (%d bytes)", len(data))
+	}
+	// Common header: version 1, little endian, header length 8, filler 0xcccccccc
+	if data[0] != 0x01 || data[1] != 0x10 {
+		return nil, fmt.Errorf("unsupported NDR type serialization version 0x%02x or endianness 0x%02x", data[0], data[1])
+	}
+	objectBufferLength := binary.LittleEndian.Uint32(data[8:12])
+	if int(objectBufferLength) > len(data)-ndrTypeSerializationHeaderSize {
+		return nil, fmt.Errorf("NDR object buffer length %d exceeds the buffer size", objectBufferLength)
+	}
+	return &ndrReader{data: data[ndrTypeSerializationHeaderSize : ndrTypeSerializationHeaderSize+int(objectBufferLength)]}, nil
+}
+
+// align moves the offset to the next multiple of n.
+func (r *ndrReader) align(n int) {
+	if remainder := r.offset % n; remainder != 0 {
+		r.offset += n - remainder
+	}
+}
+
+// read returns the next size bytes of the buffer.
+func (r *ndrReader) read(size int) ([]byte, error) {
+	if size < 0 || r.offset+size > len(r.data) {
+		return nil, fmt.Errorf("NDR buffer is too short to read %d bytes at offset %d", size, r.offset)
+	}
+	value := r.data[r.offset : r.offset+size]
+	r.offset += size
+	return value, nil
+}
+
+func (r *ndrReader) readUint16() (uint16, error) {
+	r.align(2)
+	value, err := r.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(value), nil
+}
+
+func (r *ndrReader) readUint32() (uint32, error) {
+	r.align(4)
+	value, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+// readFILETIME reads a FILETIME structure, which is made of two unsigned longs.
+func (r *ndrReader) readFILETIME() (data_structures.FILETIME, error) {
+	ft := data_structures.FILETIME{}
+	r.align(4)
+	value, err := r.read(8)
+	if err != nil {
+		return ft, err
+	}
+	_, err = ft.Unmarshal(value)
+	return ft, err
+}
+
+// readPointer reads the referent identifier of a pointer. A null pointer has a referent identifier of 0.
+func (r *ndrReader) readPointer() (uint32, error) {
+	return r.readUint32()
+}
+
+// readUnicodeStringHeader reads the inline part of a RPC_UNICODE_STRING, whose buffer is deferred.
+func (r *ndrReader) readUnicodeStringHeader() (ndrUnicodeString, error) {
+	s := ndrUnicodeString{}
+	var err error
+	if s.Length, err = r.readUint16(); err != nil {
+		return s, err
+	}
+	if s.MaximumLength, err = r.readUint16(); err != nil {
+		return s, err
+	}
+	if s.Pointer, err = r.readPointer(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// readUnicodeStringBuffer reads the deferred buffer of a RPC_UNICODE_STRING, which is a conformant
+// and varying array of WCHAR.
+func (r *ndrReader) readUnicodeStringBuffer(s ndrUnicodeString) (string, error) {
+	if s.Pointer == 0 {
+		return "", nil
+	}
+	// Maximum count, offset and actual count
+	if _, err := r.readUint32(); err != nil {
+		return "", err
+	}
+	if _, err := r.readUint32(); err != nil {
+		return "", err
+	}
+	actualCount, err := r.readUint32()
+	if err != nil {
+		return "", err
+	}
+	buffer, err := r.read(int(actualCount) * 2)
+	if err != nil {
+		return "", err
+	}
+	return utf16.DecodeUTF16LE(buffer), nil
+}
+
+// readSID reads a conformant RPC_SID structure and returns it in its binary form.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/5cb97814-a1c2-4215-b7dc-76d1f4bfad01
+func (r *ndrReader) readSID() ([]byte, error) {
+	// The conformant array of sub authorities has its maximum count placed before the structure
+	maxCount, err := r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	header, err := r.read(8)
+	if err != nil {
+		return nil, err
+	}
+	if uint32(header[1]) != maxCount {
+		return nil, fmt.Errorf("SID sub authority count %d does not match the conformant array size %d", header[1], maxCount)
+	}
+	subAuthorities, err := r.read(int(maxCount) * 4)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, header...), subAuthorities...), nil
+}
+
+// ndrUnicodeString holds the inline part of a RPC_UNICODE_STRING.
+type ndrUnicodeString struct {
+	Length        uint16
+	MaximumLength uint16
+	Pointer       uint32
+}
+
+// ndrWriter writes NDR encoded little endian data, aligning primitive types on their natural boundary
+// and numbering the referent identifiers of the embedded pointers.
+type ndrWriter struct {
+	data       []byte
+	referentId uint32
+}
+
+// align pads the buffer with zeros up to the next multiple of n.
+func (w *ndrWriter) align(n int) {
+	for len(w.data)%n != 0 {
+		w.data = append(w.data, 0x00)
+	}
+}
+
+// write appends raw bytes to the buffer.
+func (w *ndrWriter) write(value []byte) {
+	w.data = append(w.data, value...)
+}
+
+func (w *ndrWriter) writeUint16(value uint16) {
+	w.align(2)
+	w.data = binary.LittleEndian.AppendUint16(w.data, value)
+}
+
+func (w *ndrWriter) writeUint32(value uint32) {
+	w.align(4)
+	w.data = binary.LittleEndian.AppendUint32(w.data, value)
+}
+
+// writeFILETIME writes a FILETIME structure, which is made of two unsigned longs.
+func (w *ndrWriter) writeFILETIME(ft data_structures.FILETIME) {
+	w.writeUint32(ft.DwLowDateTime)
+	w.writeUint32(ft.DwHighDateTime)
+}
+
+// writePointer writes the referent identifier of an embedded pointer, or 0 for a null pointer.
+func (w *ndrWriter) writePointer(isNull bool) {
+	if isNull {
+		w.writeUint32(0)
+		return
+	}
+	// Referent identifiers conventionally start at 0x00020000 and are incremented by 4
+	w.referentId += 4
+	w.writeUint32(0x00020000 + w.referentId - 4)
+}
+
+// writeUnicodeStringHeader writes the inline part of a RPC_UNICODE_STRING, whose buffer is deferred.
+func (w *ndrWriter) writeUnicodeStringHeader(value string) {
+	length := uint16(len(utf16.EncodeUTF16LE(value)))
+	w.writeUint16(length)
+	w.writeUint16(length)
+	// Like Windows, empty strings are written with a non-null pointer to an empty buffer
+	w.writePointer(false)
+}
+
+// writeUnicodeStringBuffer writes the deferred buffer of a RPC_UNICODE_STRING, which is a conformant
+// and varying array of WCHAR.
+func (w *ndrWriter) writeUnicodeStringBuffer(value string) {
+	buffer := utf16.EncodeUTF16LE(value)
+	// Maximum count, offset and actual count
+	w.writeUint32(uint32(len(buffer) / 2))
+	w.writeUint32(0)
+	w.writeUint32(uint32(len(buffer) / 2))
+	w.write(buffer)
+}
+
+// writeSID writes a conformant RPC_SID structure from its binary form.
+func (w *ndrWriter) writeSID(sid []byte) error {
+	if len(sid) < 8 || len(sid) != 8+int(sid[1])*4 {
+		return fmt.Errorf("invalid binary SID of %d bytes", len(sid))
+	}
+	// The maximum count of the conformant array of sub authorities is placed before the structure
+	w.writeUint32(uint32(sid[1]))
+	w.write(sid)
+	return nil
+}
+
+// typeSerializationBytes returns the buffer prefixed with the common and private headers of the
+// type serialization version 1 of MS-RPCE, padded to a multiple of 8 bytes.
+func (w *ndrWriter) typeSerializationBytes() []byte {
+	w.align(8)
+	// Common header: version 1, little endian, header length 8, filler 0xcccccccc
+	data := []byte{0x01, 0x10, 0x08, 0x00, 0xcc, 0xcc, 0xcc, 0xcc}
+	// Private header: object buffer length and filler
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(w.data)))
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	return append(data, w.data...)
+}
@@ -0,0 +1,340 @@
+package pac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/166d8064-c863-41e1-9c23-edaaa5f36962
+
+// PAC_INFO_BUFFER types
+const (
+	PAC_TYPE_LOGON_INFO             uint32 = 0x00000001
+	PAC_TYPE_CREDENTIALS_INFO       uint32 = 0x00000002
+	PAC_TYPE_SERVER_CHECKSUM        uint32 = 0x00000006
+	PAC_TYPE_PRIVSVR_CHECKSUM       uint32 = 0x00000007
+	PAC_TYPE_CLIENT_INFO            uint32 = 0x0000000A
+	PAC_TYPE_CONSTRAINED_DELEGATION uint32 = 0x0000000B
+	PAC_TYPE_UPN_DNS_INFO           uint32 = 0x0000000C
+	PAC_TYPE_CLIENT_CLAIMS_INFO     uint32 = 0x0000000D
+	PAC_TYPE_DEVICE_INFO            uint32 = 0x0000000E
+	PAC_TYPE_DEVICE_CLAIMS_INFO     uint32 = 0x0000000F
+	PAC_TYPE_TICKET_CHECKSUM        uint32 = 0x00000010
+	PAC_TYPE_ATTRIBUTES_INFO        uint32 = 0x00000011
+	PAC_TYPE_REQUESTOR              uint32 = 0x00000012
+	PAC_TYPE_FULL_PAC_CHECKSUM      uint32 = 0x00000013
+)
+
+var PACBufferTypeNames = map[uint32]string{
+	PAC_TYPE_LOGON_INFO:             "Logon Information",
+	PAC_TYPE_CREDENTIALS_INFO:       "Credentials Information",
+	PAC_TYPE_SERVER_CHECKSUM:        "Server Signature",
+	PAC_TYPE_PRIVSVR_CHECKSUM:       "KDC Signature",
+	PAC_TYPE_CLIENT_INFO:            "Client Name and Ticket Information",
+	PAC_TYPE_CONSTRAINED_DELEGATION: "Constrained Delegation Information",
+	PAC_TYPE_UPN_DNS_INFO:           "UPN and DNS Information",
+	PAC_TYPE_CLIENT_CLAIMS_INFO:     "Client Claims Information",
+	PAC_TYPE_DEVICE_INFO:            "Device Information",
+	PAC_TYPE_DEVICE_CLAIMS_INFO:     "Device Claims Information",
+	PAC_TYPE_TICKET_CHECKSUM:        "Ticket Signature",
+	PAC_TYPE_ATTRIBUTES_INFO:        "PAC Attributes",
+	PAC_TYPE_REQUESTOR:              "PAC Requestor",
+	PAC_TYPE_FULL_PAC_CHECKSUM:      "Extended KDC Signature",
+}
+
+// Size of the PACTYPE header and of each PAC_INFO_BUFFER
+const (
+	pacHeaderSize     = 8
+	pacInfoBufferSize = 16
+)
+
+// PACInfoBuffer represents a PAC_INFO_BUFFER, which locates one of the buffers of the PAC.
+type PACInfoBuffer struct {
+	Type   uint32
+	Size   uint32
+	Offset uint64
+
+	// Data holds the content of the buffer
+	Data []byte
+}
+
+// PAC represents a Privilege Attribute Certificate (PACTYPE structure) and its decoded buffers.
+//
+// Buffers whose type is not known are kept in Buffers only. Buffers which are not present in
+// the PAC are left to nil.
+type PAC struct {
+	Version uint32
+	Buffers []PACInfoBuffer
+
+	LogonInfo       *KerbValidationInfo
+	CredentialsInfo *CredentialInfo
+	ServerSignature *SignatureData
+	KDCSignature    *SignatureData
+	ClientInfo      *ClientInfo
+	UPNDNSInfo      *UPNDNSInfo
+	TicketSignature *SignatureData
+	FullSignature   *SignatureData
+	AttributesInfo  *AttributesInfo
+	Requestor       *Requestor
+
+	// RawBytes holds the marshalled PAC, needed to verify its signatures
+	RawBytes []byte
+}
+
+// FromBytes parses a marshalled PAC and decodes all the buffers it knows about.
+//
+// Parameters:
+// - data: A byte slice containing the PACTYPE structure, as found in an AD-WIN2K-PAC authorization data element.
+//
+// Returns:
+// - An error if the PAC or one of its known buffers is malformed, otherwise nil.
+func (p *PAC) FromBytes(data []byte) error {
+	if len(data) < pacHeaderSize {
+		return fmt.Errorf("PAC is too short (%d bytes)", len(data))
+	}
+	p.RawBytes = append([]byte{}, data...)
+
+	count := binary.LittleEndian.Uint32(data[0:4])
+	p.Version = binary.LittleEndian.Uint32(data[4:8])
+	if p.Version != 0 {
+		return fmt.Errorf("unsupported PAC version %d", p.Version)
+	}
+	if uint64(count)*pacInfoBufferSize > uint64(len(data)-pacHeaderSize) {
+		return fmt.Errorf("PAC announces %d buffers but is only %d bytes long", count, len(data))
+	}
+
+	p.Buffers = make([]PACInfoBuffer, 0, count)
+	for i := 0; i < int(count); i++ {
+		header := data[pacHeaderSize+i*pacInfoBufferSize:]
+		buffer := PACInfoBuffer{
+			Type:   binary.LittleEndian.Uint32(header[0:4]),
+			Size:   binary.LittleEndian.Uint32(header[4:8]),
+			Offset: binary.LittleEndian.Uint64(header[8:16]),
+		}
+		if buffer.Offset > uint64(len(data)) || uint64(buffer.Size) > uint64(len(data))-buffer.Offset {
+			return fmt.Errorf("PAC buffer of type 0x%x at offset %d exceeds the PAC size", buffer.Type, buffer.Offset)
+		}
+		buffer.Data = data[buffer.Offset : buffer.Offset+uint64(buffer.Size)]
+		p.Buffers = append(p.Buffers, buffer)
+
+		if err := p.decodeBuffer(buffer); err != nil {
+			return fmt.Errorf("error parsing PAC buffer %s: %w", PACBufferTypeNames[buffer.Type], err)
+		}
+	}
+
+	return nil
+}
+
+// decodeBuffer decodes a buffer of the PAC into the corresponding field.
+func (p *PAC) decodeBuffer(buffer PACInfoBuffer) error {
+	var err error
+	switch buffer.Type {
+	case PAC_TYPE_LOGON_INFO:
+		p.LogonInfo = &KerbValidationInfo{}
+		err = p.LogonInfo.FromBytes(buffer.Data)
+	case PAC_TYPE_CREDENTIALS_INFO:
+		p.CredentialsInfo = &CredentialInfo{}
+		err = p.CredentialsInfo.FromBytes(buffer.Data)
+	case PAC_TYPE_SERVER_CHECKSUM:
+		p.ServerSignature = &SignatureData{}
+		err = p.ServerSignature.FromBytes(buffer.Data)
+	case PAC_TYPE_PRIVSVR_CHECKSUM:
+		p.KDCSignature = &SignatureData{}
+		err = p.KDCSignature.FromBytes(buffer.Data)
+	case PAC_TYPE_TICKET_CHECKSUM:
+		p.TicketSignature = &SignatureData{}
+		err = p.TicketSignature.FromBytes(buffer.Data)
+	case PAC_TYPE_FULL_PAC_CHECKSUM:
+		p.FullSignature = &SignatureData{}
+		err = p.FullSignature.FromBytes(buffer.Data)
+	case PAC_TYPE_CLIENT_INFO:
+		p.ClientInfo = &ClientInfo{}
+		err = p.ClientInfo.FromBytes(buffer.Data)
+	case PAC_TYPE_UPN_DNS_INFO:
+		p.UPNDNSInfo = &UPNDNSInfo{}
+		err = p.UPNDNSInfo.FromBytes(buffer.Data)
+	case PAC_TYPE_ATTRIBUTES_INFO:
+		p.AttributesInfo = &AttributesInfo{}
+		err = p.AttributesInfo.FromBytes(buffer.Data)
+	case PAC_TYPE_REQUESTOR:
+		p.Requestor = &Requestor{}
+		err = p.Requestor.FromBytes(buffer.Data)
+	}
+	return err
+}
+
+// ToBytes encodes the PAC into a PACTYPE structure, and updates its Buffers and RawBytes.
+//
+// Returns:
+// - A byte slice containing the PACTYPE structure.
+// - An error if one of the decoded buffers cannot be encoded.
+//
+// Note:
+// The buffers already present in Buffers keep their order and are re-encoded from their decoded field,
+// or kept as they are when the package does not encode their type. The decoded fields which have no
+// buffer yet are appended in the order of the PAC_TYPE_* constants. Each buffer is aligned on 8 bytes.
+func (p *PAC) ToBytes() ([]byte, error) {
+	buffers := []PACInfoBuffer{}
+	present := map[uint32]bool{}
+	for _, buffer := range p.Buffers {
+		data, ok, err := p.encodeBuffer(buffer.Type)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding PAC buffer %s: %w", PACBufferTypeNames[buffer.Type], err)
+		}
+		if !ok {
+			data = buffer.Data
+		}
+		buffers = append(buffers, PACInfoBuffer{Type: buffer.Type, Data: data})
+		present[buffer.Type] = true
+	}
+	for _, bufferType := range pacBufferTypesOrder {
+		if present[bufferType] {
+			continue
+		}
+		data, ok, err := p.encodeBuffer(bufferType)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding PAC buffer %s: %w", PACBufferTypeNames[bufferType], err)
+		}
+		if ok {
+			buffers = append(buffers, PACInfoBuffer{Type: bufferType, Data: data})
+		}
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(buffers)))
+	data = binary.LittleEndian.AppendUint32(data, p.Version)
+	offset := uint64(pacHeaderSize + len(buffers)*pacInfoBufferSize)
+	for i := range buffers {
+		offset = (offset + 7) &^ 7
+		buffers[i].Size = uint32(len(buffers[i].Data))
+		buffers[i].Offset = offset
+		data = binary.LittleEndian.AppendUint32(data, buffers[i].Type)
+		data = binary.LittleEndian.AppendUint32(data, buffers[i].Size)
+		data = binary.LittleEndian.AppendUint64(data, buffers[i].Offset)
+		offset += uint64(buffers[i].Size)
+	}
+	for i := range buffers {
+		for uint64(len(data)) < buffers[i].Offset {
+			data = append(data, 0x00)
+		}
+		data = append(data, buffers[i].Data...)
+		buffers[i].Data = data[buffers[i].Offset : buffers[i].Offset+uint64(buffers[i].Size)]
+	}
+	for len(data)%8 != 0 {
+		data = append(data, 0x00)
+	}
+
+	p.Buffers = buffers
+	p.RawBytes = data
+
+	return data, nil
+}
+
+// Order in which the decoded buffers are appended to a PAC
+var pacBufferTypesOrder = []uint32{
+	PAC_TYPE_LOGON_INFO,
+	PAC_TYPE_CLIENT_INFO,
+	PAC_TYPE_UPN_DNS_INFO,
+	PAC_TYPE_ATTRIBUTES_INFO,
+	PAC_TYPE_REQUESTOR,
+	PAC_TYPE_SERVER_CHECKSUM,
+	PAC_TYPE_PRIVSVR_CHECKSUM,
+	PAC_TYPE_TICKET_CHECKSUM,
+	PAC_TYPE_FULL_PAC_CHECKSUM,
+}
+
+// encodeBuffer encodes the decoded field corresponding to a buffer type. It returns false if the
+// field is not set or if the package does not encode this type of buffer.
+func (p *PAC) encodeBuffer(bufferType uint32) ([]byte, bool, error) {
+	var data []byte
+	var err error
+	switch {
+	case bufferType == PAC_TYPE_LOGON_INFO && p.LogonInfo != nil:
+		data, err = p.LogonInfo.ToBytes()
+	case bufferType == PAC_TYPE_CLIENT_INFO && p.ClientInfo != nil:
+		data = p.ClientInfo.ToBytes()
+	case bufferType == PAC_TYPE_UPN_DNS_INFO && p.UPNDNSInfo != nil:
+		data, err = p.UPNDNSInfo.ToBytes()
+	case bufferType == PAC_TYPE_ATTRIBUTES_INFO && p.AttributesInfo != nil:
+		data = p.AttributesInfo.ToBytes()
+	case bufferType == PAC_TYPE_REQUESTOR && p.Requestor != nil:
+		data, err = p.Requestor.ToBytes()
+	case bufferType == PAC_TYPE_SERVER_CHECKSUM && p.ServerSignature != nil:
+		data = p.ServerSignature.ToBytes()
+	case bufferType == PAC_TYPE_PRIVSVR_CHECKSUM && p.KDCSignature != nil:
+		data = p.KDCSignature.ToBytes()
+	case bufferType == PAC_TYPE_TICKET_CHECKSUM && p.TicketSignature != nil:
+		data = p.TicketSignature.ToBytes()
+	case bufferType == PAC_TYPE_FULL_PAC_CHECKSUM && p.FullSignature != nil:
+		data = p.FullSignature.ToBytes()
+	default:
+		return nil, false, nil
+	}
+	return data, err == nil, err
+}
+
+// GetBuffer returns the first buffer of the given type.
+//
+// Parameters:
+// - bufferType: The type of the PAC_INFO_BUFFER to look for, one of the PAC_TYPE_* constants.
+//
+// Returns:
+// - A pointer to the PACInfoBuffer, or nil if the PAC does not contain a buffer of this type.
+func (p *PAC) GetBuffer(bufferType uint32) *PACInfoBuffer {
+	for i := range p.Buffers {
+		if p.Buffers[i].Type == bufferType {
+			return &p.Buffers[i]
+		}
+	}
+	return nil
+}
+
+// Describe prints a detailed description of the PAC and of its decoded buffers.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (p *PAC) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<PAC structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mVersion\x1b[0m: %d\n", indentPrompt, p.Version)
+	fmt.Printf("%s │ \x1b[93mBuffers\x1b[0m: %d\n", indentPrompt, len(p.Buffers))
+	for _, buffer := range p.Buffers {
+		name, ok := PACBufferTypeNames[buffer.Type]
+		if !ok {
+			name = "Unknown"
+		}
+		fmt.Printf("%s │  - %s (type 0x%x, %d bytes at offset %d)\n", indentPrompt, name, buffer.Type, buffer.Size, buffer.Offset)
+	}
+	if p.LogonInfo != nil {
+		p.LogonInfo.Describe(indent + 1)
+	}
+	if p.ClientInfo != nil {
+		p.ClientInfo.Describe(indent + 1)
+	}
+	if p.UPNDNSInfo != nil {
+		p.UPNDNSInfo.Describe(indent + 1)
+	}
+	if p.AttributesInfo != nil {
+		p.AttributesInfo.Describe(indent + 1)
+	}
+	if p.Requestor != nil {
+		p.Requestor.Describe(indent + 1)
+	}
+	if p.CredentialsInfo != nil {
+		p.CredentialsInfo.Describe(indent + 1)
+	}
+	if p.ServerSignature != nil {
+		p.ServerSignature.Describe("Server Signature", indent+1)
+	}
+	if p.KDCSignature != nil {
+		p.KDCSignature.Describe("KDC Signature", indent+1)
+	}
+	if p.TicketSignature != nil {
+		p.TicketSignature.Describe("Ticket Signature", indent+1)
+	}
+	if p.FullSignature != nil {
+		p.FullSignature.Describe("Extended KDC Signature", indent+1)
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
@@ -0,0 +1,264 @@
+package pac
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+)
+
+// PAC of a ticket for the sysHTTP service in the TEST.GOKRB5 realm, from the gokrb5 test vectors
+const testPAC = "0500000000000000010000002802000058000000000000000a0000001c00000080020000000000000c00000058000000a0020000000000000600000010000000f8020000000000000700000014000000080300000000000001100800cccccccc180200000000000000000200058e4fdd80c6d201ffffffffffffff7fffffffffffffff7fcc27969c39c6d201cce7ffc602c7d201ffffffffffffff7f12001200040002001600160008000200000000000c000200000000001000020000000000140002000000000018000200d80000005104000001020000050000001c000200200000000000000000000000000000000000000008000a002000020008000a00240002002800020000000000000000001002000000000000000000000000000000000000000000000000000000000000020000002c00020000000000000000000000000009000000000000000900000074006500730074007500730065007200310000000b000000000000000b000000540065007300740031002000550073006500720031000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000050000000102000007000000540400000700000055040000070000005b040000070000005c0400000700000005000000000000000400000041004400440043000500000000000000040000005400450053005400040000000104000000000005150000004c86cebca07160e63fdce8870200000030000200070000203400020007000020050000000105000000000005150000004c86cebca07160e63fdce8875a040000050000000105000000000005150000004c86cebca07160e63fdce8875704000000000000808dd1dc80c6d2011200740065007300740075007300650072003100000000002a001000160040000000000000000000740065007300740075007300650072003100400074006500730074002e0067006f006b0072006200350000000000000054004500530054002e0047004f004b005200420035000000100000001e251d98d552be7df384f55076ffffff340be28b48765d0519ee9346cf53d82200000000"
+
+// AES256 key of the sysHTTP service, which signed testPAC
+const testPACServiceKey = "43763702868978d1b6d91a36704b987e27e517250055bdfc40b8a6b3848d9aae"
+
+// KERB_VALIDATION_INFO example of MS-PAC section 4
+const testKerbValidationInfo = "01100800cccccccca00400000000000000000200d186660f656ac601ffffffffffffff7fffffffffffffff7f17d439fe784ac6011794a328424bc601175424977a81c60108000800040002002400240008000200120012000c0002000000000010000200000000001400020000000000180002005410000097792c00010200001a0000001c000200200000000000000000000000000000000000000016001800200002000a000c002400020028000200000000000000000010000000000000000000000000000000000000000000000000000000000000000d0000002c0002000000000000000000000000000400000000000000040000006c007a00680075001200000000000000120000004c0069007100690061006e00670028004c006100720072007900290020005a00680075000900000000000000090000006e0074006400730032002e0062006100740000000000000000000000000000000000000000000000000000000000000000000000000000001a00000061c433000700000009c32d00070000005eb4320007000000010200000700000097b92c00070000002bf1320007000000ce30330007000000a72e2e00070000002af132000700000098b92c000700000062c4330007000000940133000700000076c4330007000000aefe2d000700000032d22c00070000001608320007000000425b2e00070000005fb4320007000000ca9c35000700000085442d0007000000c2f0320007000000e9ea310007000000ed8e2e0007000000b6eb310007000000ab2e2e0007000000720e2e00070000000c000000000000000b0000004e0054004400450056002d00440043002d003000350000000600000000000000050000004e0054004400450056000000040000000104000000000005150000005951b81766725d2564633b0b0d0000003000020007000000340002000700002038000200070000203c000200070000204000020007000020440002000700002048000200070000204c000200070000205000020007000020540002000700002058000200070000205c00020007000020600002000700002005000000010500000000000515000000b9301b2eb7414c6c8c3b351501020000050000000105000000000005150000005951b81766725d2564633b0b74542f00050000000105000000000005150000005951b81766725d2564633b0be8383200050000000105000000000005150000005951b81766725d2564633b0bcd383200050000000105000000000005150000005951b81766725d2564633b0b5db43200050000000105000000000005150000005951b81766725d2564633b0b41163500050000000105000000000005150000005951b81766725d2564633b0be8ea3100050000000105000000000005150000005951b81766725d2564633b0bc1193200050000000105000000000005150000005951b81766725d2564633b0b29f13200050000000105000000000005150000005951b81766725d2564633b0b0f5f2e00050000000105000000000005150000005951b81766725d2564633b0b2f5b2e00050000000105000000000005150000005951b81766725d2564633b0bef8f3100050000000105000000000005150000005951b81766725d2564633b0b075f2e0000000000"
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex string: %v", err)
+	}
+	return data
+}
+
+func TestKerbValidationInfoFromBytes(t *testing.T) {
+	k := &KerbValidationInfo{}
+	if err := k.FromBytes(mustDecodeHex(t, testKerbValidationInfo)); err != nil {
+		t.Fatalf("FromBytes returned an error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		result   interface{}
+		expected interface{}
+	}{
+		{"EffectiveName", k.EffectiveName, "lzhu"},
+		{"FullName", k.FullName, "Liqiang(Larry) Zhu"},
+		{"LogonScript", k.LogonScript, "ntds2.bat"},
+		{"LogonCount", k.LogonCount, uint16(4180)},
+		{"UserId", k.UserId, uint32(2914711)},
+		{"PrimaryGroupId", k.PrimaryGroupId, uint32(513)},
+		{"GroupIds", len(k.GroupIds), 26},
+		{"UserFlags", k.UserFlags, LOGON_EXTRA_SIDS},
+		{"LogonServer", k.LogonServer, "NTDEV-DC-05"},
+		{"LogonDomainName", k.LogonDomainName, "NTDEV"},
+		{"LogonDomainId", k.LogonDomainId, "S-1-5-21-397955417-626881126-188441444"},
+		{"UserSID", k.GetUserSID(), "S-1-5-21-397955417-626881126-188441444-2914711"},
+		{"UserAccountControl", k.UserAccountControl, uint32(16)},
+		{"ExtraSids", len(k.ExtraSids), 13},
+		{"ExtraSids[0]", k.ExtraSids[0].SID, "S-1-5-21-773533881-1816936887-355810188-513"},
+		{"ExtraSids[1].Attributes", k.ExtraSids[1].Attributes, SE_GROUP_RESOURCE | SE_GROUP_ENABLED | SE_GROUP_ENABLED_BY_DEFAULT | SE_GROUP_MANDATORY},
+		{"GroupSIDs", len(k.GetGroupSIDs()), 39},
+	}
+
+	for _, test := range tests {
+		if test.result != test.expected {
+			t.Errorf("%s = %v; expected %v", test.name, test.result, test.expected)
+		}
+	}
+}
+
+func TestPACFromBytes(t *testing.T) {
+	p := &PAC{}
+	if err := p.FromBytes(mustDecodeHex(t, testPAC)); err != nil {
+		t.Fatalf("FromBytes returned an error: %v", err)
+	}
+
+	if p.LogonInfo == nil || p.ClientInfo == nil || p.UPNDNSInfo == nil || p.ServerSignature == nil || p.KDCSignature == nil {
+		t.Fatalf("FromBytes did not decode all the buffers of the PAC")
+	}
+
+	tests := []struct {
+		name     string
+		result   interface{}
+		expected interface{}
+	}{
+		{"Buffers", len(p.Buffers), 5},
+		{"LogonInfo.EffectiveName", p.LogonInfo.EffectiveName, "testuser1"},
+		{"LogonInfo.UserSID", p.LogonInfo.GetUserSID(), "S-1-5-21-3167651404-3865080224-2280184895-1105"},
+		{"ClientInfo.Name", p.ClientInfo.Name, "testuser1"},
+		{"UPNDNSInfo.UPN", p.UPNDNSInfo.UPN, "testuser1@test.gokrb5"},
+		{"UPNDNSInfo.DNSDomainName", p.UPNDNSInfo.DNSDomainName, "TEST.GOKRB5"},
+		{"ServerSignature.SignatureType", p.ServerSignature.SignatureType, krbcrypto.CKSUMTYPE_HMAC_SHA1_96_AES256},
+		{"KDCSignature.SignatureType", p.KDCSignature.SignatureType, krbcrypto.CKSUMTYPE_HMAC_MD5},
+	}
+
+	for _, test := range tests {
+		if test.result != test.expected {
+			t.Errorf("%s = %v; expected %v", test.name, test.result, test.expected)
+		}
+	}
+}
+
+func TestPACVerifyServerSignature(t *testing.T) {
+	key := &krbcrypto.Key{EType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, Value: mustDecodeHex(t, testPACServiceKey)}
+
+	p := &PAC{}
+	if err := p.FromBytes(mustDecodeHex(t, testPAC)); err != nil {
+		t.Fatalf("FromBytes returned an error: %v", err)
+	}
+	if err := p.VerifyServerSignature(key); err != nil {
+		t.Errorf("VerifyServerSignature returned an error: %v", err)
+	}
+
+	// Tampering with the logon information must invalidate the signature
+	tampered := mustDecodeHex(t, testPAC)
+	tampered[p.GetBuffer(PAC_TYPE_LOGON_INFO).Offset+100] ^= 0xff
+	if err := p.FromBytes(tampered); err != nil {
+		t.Fatalf("FromBytes returned an error: %v", err)
+	}
+	if err := p.VerifyServerSignature(key); err == nil {
+		t.Errorf("VerifyServerSignature did not fail on a tampered PAC")
+	}
+}
+
+func TestPACFromBytesOverflowingBuffer(t *testing.T) {
+	data := mustDecodeHex(t, testPAC)
+	// Offset of the first PAC_INFO_BUFFER set so that adding its size wraps around
+	binary.LittleEndian.PutUint64(data[16:24], 0xffffffffffffff00)
+
+	p := &PAC{}
+	if err := p.FromBytes(data); err == nil {
+		t.Errorf("FromBytes did not fail on a buffer offset overflowing with its size")
+	}
+}
+
+func TestPACVerifyServerSignatureTruncatedBuffer(t *testing.T) {
+	key := &krbcrypto.Key{EType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, Value: mustDecodeHex(t, testPACServiceKey)}
+
+	p := &PAC{}
+	if err := p.FromBytes(mustDecodeHex(t, testPAC)); err != nil {
+		t.Fatalf("FromBytes returned an error: %v", err)
+	}
+	buffer := p.GetBuffer(PAC_TYPE_PRIVSVR_CHECKSUM)
+	buffer.Data = buffer.Data[:2]
+
+	if err := p.VerifyServerSignature(key); err == nil {
+		t.Errorf("VerifyServerSignature did not fail on a truncated signature buffer")
+	}
+}
+
+func TestCredentialInfoDecrypt(t *testing.T) {
+	ntHash := mustDecodeHex(t, "31d6cfe0d16ae931b73c59d7e0c089c0")
+
+	// PAC_CREDENTIAL_DATA holding a single NTLM_SUPPLEMENTAL_CREDENTIAL with an NT hash
+	credentialData := mustDecodeHex(t, "01100800cccccccc5c00000000000000"+
+		"00000200"+"01000000"+"01000000"+"0800080004000200"+"28000000"+"08000200"+
+		"04000000"+"00000000"+"04000000"+"4e0054004c004d00"+
+		"28000000"+"0000000002000000"+"00000000000000000000000000000000")
+	credentialData = append(credentialData, ntHash...)
+
+	key, err := krbcrypto.NewKeyFromPassword(krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, "Password123!", "LAB.LOCALuser")
+	if err != nil {
+		t.Fatalf("NewKeyFromPassword returned an error: %v", err)
+	}
+	encrypted, err := key.Encrypt(krbcrypto.KEY_USAGE_KERB_NON_KERB_SALT, credentialData)
+	if err != nil {
+		t.Fatalf("Encrypt returned an error: %v", err)
+	}
+
+	credentialInfo := &CredentialInfo{EncryptionType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, SerializedData: encrypted}
+	p := &PAC{CredentialsInfo: credentialInfo}
+	ntlmCredential, err := p.GetNTLMCredential(key)
+	if err != nil {
+		t.Fatalf("GetNTLMCredential returned an error: %v", err)
+	}
+	if !bytes.Equal(ntlmCredential.NTHash[:], ntHash) {
+		t.Errorf("NTHash = %x; expected %x", ntlmCredential.NTHash, ntHash)
+	}
+	if ntlmCredential.GetLMHashHex() != "" {
+		t.Errorf("GetLMHashHex() = %q; expected an empty string", ntlmCredential.GetLMHashHex())
+	}
+}
+
+func TestKerbValidationInfoToBytes(t *testing.T) {
+	k := &KerbValidationInfo{}
+	if err := k.FromBytes(mustDecodeHex(t, testKerbValidationInfo)); err != nil {
+		t.Fatalf("FromBytes returned an error: %v", err)
+	}
+
+	data, err := k.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes returned an error: %v", err)
+	}
+
+	decoded := &KerbValidationInfo{}
+	if err := decoded.FromBytes(data); err != nil {
+		t.Fatalf("FromBytes of the encoded structure returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, k) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", decoded, k)
+	}
+}
+
+func TestPACSign(t *testing.T) {
+	serviceKey := &krbcrypto.Key{EType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, Value: mustDecodeHex(t, testPACServiceKey)}
+	kdcKey := &krbcrypto.Key{EType: krbcrypto.ETYPE_RC4_HMAC, Value: bytes.Repeat([]byte{0x41}, 16)}
+
+	p := &PAC{
+		LogonInfo: &KerbValidationInfo{
+			EffectiveName:   "alice",
+			UserId:          1104,
+			PrimaryGroupId:  513,
+			GroupIds:        []GroupMembership{{RelativeId: 513, Attributes: SE_GROUP_MANDATORY | SE_GROUP_ENABLED_BY_DEFAULT | SE_GROUP_ENABLED}},
+			LogonDomainName: "LAB",
+			LogonDomainId:   "S-1-5-21-1-2-3",
+			ExtraSids:       []SIDAndAttributes{{SID: "S-1-18-1", Attributes: SE_GROUP_MANDATORY}},
+		},
+		ClientInfo:     &ClientInfo{Name: "alice"},
+		UPNDNSInfo:     &UPNDNSInfo{UPN: "alice@lab.local", DNSDomainName: "LAB.LOCAL", Flags: UPN_DNS_INFO_FLAG_EXTENDED, SamName: "alice", SID: "S-1-5-21-1-2-3-1104"},
+		AttributesInfo: &AttributesInfo{FlagsLength: 2, Flags: PAC_WAS_GIVEN_IMPLICITLY},
+		Requestor:      &Requestor{SID: "S-1-5-21-1-2-3-1104"},
+	}
+
+	data, err := p.Sign(serviceKey, kdcKey)
+	if err != nil {
+		t.Fatalf("Sign returned an error: %v", err)
+	}
+
+	authorizationData, err := p.ToAuthorizationData()
+	if err != nil {
+		t.Fatalf("ToAuthorizationData returned an error: %v", err)
+	}
+	parsed, err := FromAuthorizationData(authorizationData)
+	if err != nil {
+		t.Fatalf("FromAuthorizationData returned an error: %v", err)
+	}
+	if !bytes.Equal(parsed.RawBytes, data) {
+		t.Fatalf("authorization data does not contain the signed PAC")
+	}
+
+	if err := parsed.VerifyServerSignature(serviceKey); err != nil {
+		t.Errorf("VerifyServerSignature returned an error: %v", err)
+	}
+	if err := parsed.VerifyKDCSignature(kdcKey); err != nil {
+		t.Errorf("VerifyKDCSignature returned an error: %v", err)
+	}
+	if parsed.LogonInfo.GetUserSID() != "S-1-5-21-1-2-3-1104" || parsed.LogonInfo.ExtraSids[0].SID != "S-1-18-1" {
+		t.Errorf("unexpected logon information %+v", parsed.LogonInfo)
+	}
+	if parsed.UPNDNSInfo.UPN != "alice@lab.local" || parsed.UPNDNSInfo.SamName != "alice" || parsed.UPNDNSInfo.SID != "S-1-5-21-1-2-3-1104" {
+		t.Errorf("unexpected UPN and DNS information %+v", parsed.UPNDNSInfo)
+	}
+	if parsed.ClientInfo.Name != "alice" || parsed.Requestor.SID != "S-1-5-21-1-2-3-1104" || parsed.AttributesInfo.Flags != PAC_WAS_GIVEN_IMPLICITLY {
+		t.Errorf("unexpected PAC buffers %+v %+v %+v", parsed.ClientInfo, parsed.Requestor, parsed.AttributesInfo)
+	}
+
+	// Tampering with the PAC invalidates the server signature
+	tampered := append([]byte{}, data...)
+	tampered[parsed.GetBuffer(PAC_TYPE_CLIENT_INFO).Offset+10] ^= 0xff
+	tamperedPAC := &PAC{}
+	if err := tamperedPAC.FromBytes(tampered); err != nil {
+		t.Fatalf("FromBytes returned an error: %v", err)
+	}
+	if err := tamperedPAC.VerifyServerSignature(serviceKey); err == nil {
+		t.Errorf("expected an invalid server signature")
+	}
+}
@@ -0,0 +1,56 @@
+package pac
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Requestor represents the PAC_REQUESTOR structure, which holds the SID of the client that
+// requested the ticket.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/c38cc307-f3e6-4ed4-8c81-dc550d96223c
+type Requestor struct {
+	SID string
+}
+
+// FromBytes parses a PAC_REQUESTOR structure.
+//
+// Parameters:
+// - data: A byte slice containing the PAC requestor buffer.
+//
+// Returns:
+// - An error if the buffer does not contain a valid SID, otherwise nil.
+func (r *Requestor) FromBytes(data []byte) error {
+	parsedSID, err := sid.FromBytes(data)
+	if err != nil {
+		return fmt.Errorf("PAC requestor buffer does not contain a valid SID: %w", err)
+	}
+	r.SID = parsedSID.String()
+	return nil
+}
+
+// ToBytes encodes the PAC_REQUESTOR structure.
+//
+// Returns:
+// - A byte slice containing the PAC requestor buffer.
+// - An error if the SID is not valid.
+func (r *Requestor) ToBytes() ([]byte, error) {
+	parsedSID, err := sid.FromString(r.SID)
+	if err != nil {
+		return nil, err
+	}
+	return parsedSID.ToBytes(), nil
+}
+
+// Describe prints a detailed description of the Requestor structure.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (r *Requestor) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<PAC_REQUESTOR structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mSID\x1b[0m: %s\n", indentPrompt, r.SID)
+	fmt.Printf("%s └───\n", indentPrompt)
+}
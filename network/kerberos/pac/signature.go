@@ -0,0 +1,233 @@
+package pac
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+)
+
+// SignatureData represents the PAC_SIGNATURE_DATA structure, used by the server, KDC, ticket
+// and extended KDC signatures.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/6e95edd3-af93-41d4-8303-6c7955297315
+type SignatureData struct {
+	SignatureType  int32
+	Signature      []byte
+	RODCIdentifier uint16
+}
+
+// GetSignatureSize returns the size in bytes of the signatures of a given checksum type.
+//
+// Parameters:
+// - signatureType: The checksum type of the signature, one of the CKSUMTYPE_* constants.
+//
+// Returns:
+// - The size of the signature in bytes.
+// - An error if the checksum type is not supported in a PAC.
+func GetSignatureSize(signatureType int32) (int, error) {
+	switch signatureType {
+	case krbcrypto.CKSUMTYPE_HMAC_MD5:
+		return 16, nil
+	case krbcrypto.CKSUMTYPE_HMAC_SHA1_96_AES128, krbcrypto.CKSUMTYPE_HMAC_SHA1_96_AES256:
+		return 12, nil
+	case krbcrypto.CKSUMTYPE_HMAC_SHA256_128_AES128:
+		return 16, nil
+	case krbcrypto.CKSUMTYPE_HMAC_SHA384_192_AES256:
+		return 24, nil
+	}
+	return 0, fmt.Errorf("unsupported PAC signature type %d", signatureType)
+}
+
+// FromBytes parses a PAC_SIGNATURE_DATA structure.
+//
+// Parameters:
+// - data: A byte slice containing the signature buffer.
+//
+// Returns:
+// - An error if the buffer is malformed or the signature type is not supported, otherwise nil.
+func (s *SignatureData) FromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("signature buffer is too short (%d bytes)", len(data))
+	}
+	s.SignatureType = int32(binary.LittleEndian.Uint32(data[0:4]))
+	size, err := GetSignatureSize(s.SignatureType)
+	if err != nil {
+		return err
+	}
+	if len(data) < 4+size {
+		return fmt.Errorf("signature buffer is too short for a signature of type %d (%d bytes)", s.SignatureType, len(data))
+	}
+	s.Signature = append([]byte{}, data[4:4+size]...)
+	if len(data) >= 4+size+2 {
+		s.RODCIdentifier = binary.LittleEndian.Uint16(data[4+size : 4+size+2])
+	}
+	return nil
+}
+
+// ToBytes encodes the PAC_SIGNATURE_DATA structure. The RODC identifier is only written if it is not 0.
+//
+// Returns:
+// - A byte slice containing the signature buffer.
+func (s *SignatureData) ToBytes() []byte {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(s.SignatureType))
+	data = append(data, s.Signature...)
+	if s.RODCIdentifier != 0 {
+		data = binary.LittleEndian.AppendUint16(data, s.RODCIdentifier)
+	}
+	return data
+}
+
+// Describe prints a detailed description of the SignatureData structure.
+//
+// Parameters:
+// - name: A string containing the name of the signature.
+// - indent: An integer value specifying the indentation level for the output.
+func (s *SignatureData) Describe(name string, indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<PAC_SIGNATURE_DATA structure (%s)>\n", indentPrompt, name)
+	fmt.Printf("%s │ \x1b[93mSignatureType\x1b[0m: %d\n", indentPrompt, s.SignatureType)
+	fmt.Printf("%s │ \x1b[93mSignature\x1b[0m: %s\n", indentPrompt, hex.EncodeToString(s.Signature))
+	if s.RODCIdentifier != 0 {
+		fmt.Printf("%s │ \x1b[93mRODCIdentifier\x1b[0m: %d\n", indentPrompt, s.RODCIdentifier)
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
+
+// VerifyServerSignature verifies the server signature of the PAC, which is computed over the
+// whole PAC with the signatures of the server and KDC signature buffers zeroed.
+//
+// Parameters:
+// - key: The long-term key of the service the ticket was issued for.
+//
+// Returns:
+// - An error if the PAC has no server signature or if the signature is invalid, otherwise nil.
+func (p *PAC) VerifyServerSignature(key *krbcrypto.Key) error {
+	if p.ServerSignature == nil {
+		return fmt.Errorf("PAC does not contain a server signature")
+	}
+
+	data := append([]byte{}, p.RawBytes...)
+	for _, bufferType := range []uint32{PAC_TYPE_SERVER_CHECKSUM, PAC_TYPE_PRIVSVR_CHECKSUM} {
+		buffer := p.GetBuffer(bufferType)
+		if buffer == nil {
+			continue
+		}
+		if len(buffer.Data) < 4 {
+			return fmt.Errorf("PAC signature buffer of type 0x%x is too short (%d bytes)", bufferType, len(buffer.Data))
+		}
+		signatureType := int32(binary.LittleEndian.Uint32(buffer.Data[0:4]))
+		size, err := GetSignatureSize(signatureType)
+		if err != nil {
+			return err
+		}
+		if len(buffer.Data) < 4+size {
+			return fmt.Errorf("PAC signature buffer of type 0x%x is too short (%d bytes)", bufferType, len(buffer.Data))
+		}
+		start := int(buffer.Offset) + 4
+		copy(data[start:start+size], make([]byte, size))
+	}
+
+	return verifySignature(p.ServerSignature, key, data)
+}
+
+// VerifyKDCSignature verifies the KDC signature of the PAC, which is computed over the server signature.
+//
+// Parameters:
+// - key: The long-term key of the krbtgt account of the realm.
+//
+// Returns:
+// - An error if the PAC has no server or KDC signature or if the signature is invalid, otherwise nil.
+func (p *PAC) VerifyKDCSignature(key *krbcrypto.Key) error {
+	if p.ServerSignature == nil || p.KDCSignature == nil {
+		return fmt.Errorf("PAC does not contain a server and a KDC signature")
+	}
+	return verifySignature(p.KDCSignature, key, p.ServerSignature.Signature)
+}
+
+// Sign computes the server and KDC signatures of the PAC and encodes it.
+//
+// Parameters:
+// - serverKey: The long-term key of the service the ticket is issued for.
+// - kdcKey: The long-term key of the krbtgt account of the realm.
+//
+// Returns:
+// - A byte slice containing the signed PACTYPE structure, also stored in RawBytes.
+// - An error if the PAC cannot be encoded or if a key is of an unsupported encryption type.
+//
+// Note:
+// The ticket and extended KDC signatures are removed from the PAC, as they are computed over the
+// ticket which is not known here.
+func (p *PAC) Sign(serverKey, kdcKey *krbcrypto.Key) ([]byte, error) {
+	keptBuffers := []PACInfoBuffer{}
+	for _, buffer := range p.Buffers {
+		if buffer.Type != PAC_TYPE_TICKET_CHECKSUM && buffer.Type != PAC_TYPE_FULL_PAC_CHECKSUM {
+			keptBuffers = append(keptBuffers, buffer)
+		}
+	}
+	p.Buffers = keptBuffers
+	p.TicketSignature = nil
+	p.FullSignature = nil
+
+	var err error
+	if p.ServerSignature, err = newEmptySignature(serverKey); err != nil {
+		return nil, err
+	}
+	if p.KDCSignature, err = newEmptySignature(kdcKey); err != nil {
+		return nil, err
+	}
+
+	// The server signature is computed with both signatures zeroed
+	data, err := p.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := serverKey.Checksum(krbcrypto.KEY_USAGE_KERB_NON_KERB_CKSUM_SALT, data)
+	if err != nil {
+		return nil, fmt.Errorf("error computing the server signature: %w", err)
+	}
+	copy(p.ServerSignature.Signature, checksum)
+
+	checksum, err = kdcKey.Checksum(krbcrypto.KEY_USAGE_KERB_NON_KERB_CKSUM_SALT, p.ServerSignature.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("error computing the KDC signature: %w", err)
+	}
+	copy(p.KDCSignature.Signature, checksum)
+
+	return p.ToBytes()
+}
+
+// newEmptySignature returns a zeroed signature of the checksum type of a key.
+func newEmptySignature(key *krbcrypto.Key) (*SignatureData, error) {
+	encType, err := krbcrypto.GetEncryptionType(key.EType)
+	if err != nil {
+		return nil, err
+	}
+	size, err := GetSignatureSize(encType.GetChecksumTypeID())
+	if err != nil {
+		return nil, err
+	}
+	return &SignatureData{SignatureType: encType.GetChecksumTypeID(), Signature: make([]byte, size)}, nil
+}
+
+// verifySignature checks a PAC signature against a keyed checksum of the data.
+func verifySignature(signature *SignatureData, key *krbcrypto.Key, data []byte) error {
+	encType, err := krbcrypto.GetEncryptionTypeByChecksumType(signature.SignatureType)
+	if err != nil {
+		return err
+	}
+	if encType.GetETypeID() != key.EType {
+		return fmt.Errorf("signature type %d does not match the key encryption type %d", signature.SignatureType, key.EType)
+	}
+	checksum, err := encType.Checksum(key.Value, krbcrypto.KEY_USAGE_KERB_NON_KERB_CKSUM_SALT, data)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(checksum[:len(signature.Signature)], signature.Signature) {
+		return fmt.Errorf("PAC signature is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package pac
+
+import (
+	"fmt"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/iana/adtype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// FromAuthorizationData extracts and parses the PAC from the authorization data of a ticket, where
+// it is stored in an AD-WIN2K-PAC element wrapped in an AD-IF-RELEVANT element.
+//
+// Parameters:
+// - authorizationData: The authorization data of the decrypted part of a ticket.
+//
+// Returns:
+// - A pointer to the parsed PAC.
+// - An error if the authorization data does not contain a PAC or if the PAC is malformed.
+func FromAuthorizationData(authorizationData types.AuthorizationData) (*PAC, error) {
+	for _, entry := range authorizationData {
+		if entry.ADType != adtype.ADIfRelevant {
+			continue
+		}
+
+		var relevant types.AuthorizationData
+		if err := relevant.Unmarshal(entry.ADData); err != nil {
+			return nil, fmt.Errorf("error unmarshalling AD-IF-RELEVANT authorization data: %w", err)
+		}
+
+		for _, relevantEntry := range relevant {
+			if relevantEntry.ADType != adtype.ADWin2KPAC {
+				continue
+			}
+			p := &PAC{}
+			if err := p.FromBytes(relevantEntry.ADData); err != nil {
+				return nil, err
+			}
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("authorization data does not contain a PAC")
+}
+
+// ToAuthorizationData wraps the encoded PAC in an AD-WIN2K-PAC element inside an AD-IF-RELEVANT element,
+// as stored in the authorization data of tickets.
+//
+// Returns:
+// - The authorization data containing the PAC, built from RawBytes which is set by ToBytes and Sign.
+// - An error if the PAC has not been encoded or if the authorization data cannot be marshalled.
+func (p *PAC) ToAuthorizationData() (types.AuthorizationData, error) {
+	if len(p.RawBytes) == 0 {
+		return nil, fmt.Errorf("PAC has not been encoded")
+	}
+
+	relevant, err := asn1.Marshal(types.AuthorizationData{{ADType: adtype.ADWin2KPAC, ADData: p.RawBytes}})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling AD-WIN2K-PAC authorization data: %w", err)
+	}
+
+	return types.AuthorizationData{{ADType: adtype.ADIfRelevant, ADData: relevant}}, nil
+}
+
+// FromTicket decrypts a ticket with the key of the service it was issued for and parses its PAC.
+//
+// Parameters:
+// - ticket: The ticket containing the PAC.
+// - serviceKey: The long-term key of the service, of the encryption type of the ticket.
+//
+// Returns:
+// - A pointer to the parsed PAC.
+// - An error if the ticket cannot be decrypted or does not contain a PAC.
+func FromTicket(ticket messages.Ticket, serviceKey *krbcrypto.Key) (*PAC, error) {
+	if ticket.EncPart.EType != serviceKey.EType {
+		return nil, fmt.Errorf("ticket is encrypted with encryption type %d, but the key is of type %d", ticket.EncPart.EType, serviceKey.EType)
+	}
+
+	plaintext, err := serviceKey.Decrypt(krbcrypto.KEY_USAGE_KDC_REP_TICKET, ticket.EncPart.Cipher)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting ticket: %w", err)
+	}
+
+	var encTicketPart messages.EncTicketPart
+	if err := encTicketPart.Unmarshal(plaintext); err != nil {
+		return nil, fmt.Errorf("error unmarshalling the encrypted part of the ticket: %w", err)
+	}
+
+	return FromAuthorizationData(encTicketPart.AuthorizationData)
+}
@@ -0,0 +1,148 @@
+package pac
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Flags of the UPN_DNS_INFO structure
+const (
+	// The user account object does not have the userPrincipalName attribute set
+	UPN_DNS_INFO_FLAG_UPN_CONSTRUCTED uint32 = 0x00000001
+	// The structure is extended with the sAMAccountName and the SID of the user
+	UPN_DNS_INFO_FLAG_EXTENDED uint32 = 0x00000002
+)
+
+// UPNDNSInfo represents the UPN_DNS_INFO structure, which holds the user principal name and the
+// DNS domain name of the client, and in its extended form the sAMAccountName and SID.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-pac/1c0d6e11-6443-4846-b744-f9f810a504eb
+type UPNDNSInfo struct {
+	UPN           string
+	DNSDomainName string
+	Flags         uint32
+
+	SamName string
+	SID     string
+}
+
+// FromBytes parses a UPN_DNS_INFO structure.
+//
+// Parameters:
+// - data: A byte slice containing the UPN and DNS information buffer.
+//
+// Returns:
+// - An error if the buffer is malformed, otherwise nil.
+func (u *UPNDNSInfo) FromBytes(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("UPN and DNS information buffer is too short (%d bytes)", len(data))
+	}
+
+	var err error
+	if u.UPN, err = readCountedUTF16(data, 0); err != nil {
+		return err
+	}
+	if u.DNSDomainName, err = readCountedUTF16(data, 4); err != nil {
+		return err
+	}
+	u.Flags = binary.LittleEndian.Uint32(data[8:12])
+
+	if u.Flags&UPN_DNS_INFO_FLAG_EXTENDED != 0 {
+		if len(data) < 20 {
+			return fmt.Errorf("extended UPN and DNS information buffer is too short (%d bytes)", len(data))
+		}
+		if u.SamName, err = readCountedUTF16(data, 12); err != nil {
+			return err
+		}
+		rawSID, err := readCountedBytes(data, 16)
+		if err != nil {
+			return err
+		}
+		parsedSID, err := sid.FromBytes(rawSID)
+		if err != nil {
+			return fmt.Errorf("error parsing SID: %w", err)
+		}
+		u.SID = parsedSID.String()
+	}
+
+	return nil
+}
+
+// ToBytes encodes the UPN_DNS_INFO structure. The extended fields are written if the
+// UPN_DNS_INFO_FLAG_EXTENDED flag is set.
+//
+// Returns:
+// - A byte slice containing the UPN and DNS information buffer.
+// - An error if the SID of the extended structure is not valid.
+func (u *UPNDNSInfo) ToBytes() ([]byte, error) {
+	fields := [][]byte{utf16.EncodeUTF16LE(u.UPN), utf16.EncodeUTF16LE(u.DNSDomainName)}
+	headerSize := 12
+	if u.Flags&UPN_DNS_INFO_FLAG_EXTENDED != 0 {
+		parsedSID, err := sid.FromString(u.SID)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, utf16.EncodeUTF16LE(u.SamName), parsedSID.ToBytes())
+		headerSize = 20
+	}
+
+	// Position in the header of the length and offset of each field, the flags sit between
+	// the DNS domain name and the sAMAccountName
+	positions := []int{0, 4, 12, 16}
+
+	// The fields follow the header, each of them aligned on 8 bytes like Windows does
+	data := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(data[8:12], u.Flags)
+	for i, field := range fields {
+		for len(data)%8 != 0 {
+			data = append(data, 0x00)
+		}
+		position := positions[i]
+		binary.LittleEndian.PutUint16(data[position:position+2], uint16(len(field)))
+		binary.LittleEndian.PutUint16(data[position+2:position+4], uint16(len(data)))
+		data = append(data, field...)
+	}
+
+	return data, nil
+}
+
+// readCountedBytes reads a field located by a 2 bytes length and a 2 bytes offset relative to the
+// start of the buffer, stored at the given position.
+func readCountedBytes(data []byte, position int) ([]byte, error) {
+	length := int(binary.LittleEndian.Uint16(data[position : position+2]))
+	offset := int(binary.LittleEndian.Uint16(data[position+2 : position+4]))
+	if offset+length > len(data) {
+		return nil, fmt.Errorf("field at offset %d of length %d exceeds the buffer size", offset, length)
+	}
+	return data[offset : offset+length], nil
+}
+
+// readCountedUTF16 reads a UTF-16LE string located by readCountedBytes.
+func readCountedUTF16(data []byte, position int) (string, error) {
+	value, err := readCountedBytes(data, position)
+	if err != nil {
+		return "", err
+	}
+	return utf16.DecodeUTF16LE(value), nil
+}
+
+// Describe prints a detailed description of the UPNDNSInfo structure.
+//
+// Parameters:
+// - indent: An integer value specifying the indentation level for the output.
+func (u *UPNDNSInfo) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<UPN_DNS_INFO structure>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mUPN\x1b[0m: %s\n", indentPrompt, u.UPN)
+	fmt.Printf("%s │ \x1b[93mDNSDomainName\x1b[0m: %s\n", indentPrompt, u.DNSDomainName)
+	fmt.Printf("%s │ \x1b[93mFlags\x1b[0m: 0x%08x\n", indentPrompt, u.Flags)
+	if u.Flags&UPN_DNS_INFO_FLAG_EXTENDED != 0 {
+		fmt.Printf("%s │ \x1b[93mSamName\x1b[0m: %s\n", indentPrompt, u.SamName)
+		fmt.Printf("%s │ \x1b[93mSID\x1b[0m: %s\n", indentPrompt, u.SID)
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
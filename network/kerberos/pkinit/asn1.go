@@ -0,0 +1,97 @@
+package pkinit
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc4556#appendix-A
+
+// Object identifiers used by PKINIT and CMS
+var (
+	OID_PKINIT_AUTH_DATA   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 2, 3, 1}
+	OID_PKINIT_DHKEY_DATA  = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 2, 3, 2}
+	OID_PKINIT_RKEY_DATA   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 2, 3, 3}
+	OID_DH_PUBLIC_NUMBER   = asn1.ObjectIdentifier{1, 2, 840, 10046, 2, 1}
+	OID_CMS_DATA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OID_CMS_SIGNED_DATA    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	OID_CMS_ENVELOPED_DATA = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	OID_CONTENT_TYPE       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	OID_MESSAGE_DIGEST     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	OID_SHA1               = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	OID_SHA256             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	OID_RSA_ENCRYPTION     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	OID_RSAES_OAEP         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	OID_SHA256_WITH_RSA    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	OID_DES_EDE3_CBC       = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	OID_AES128_CBC         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	OID_AES256_CBC         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// paPKASReq represents the PA-PK-AS-REQ structure.
+type paPKASReq struct {
+	SignedAuthPack []byte `asn1:"tag:0"`
+}
+
+// authPack represents the AuthPack structure, signed by the client.
+type authPack struct {
+	PKAuthenticator   pkAuthenticator            `asn1:"explicit,tag:0"`
+	ClientPublicValue subjectPublicKeyInfo       `asn1:"explicit,optional,tag:1"`
+	SupportedCMSTypes []pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:2"`
+	ClientDHNonce     []byte                     `asn1:"explicit,optional,tag:3"`
+}
+
+// pkAuthenticator represents the PKAuthenticator structure, binding the AuthPack to the request.
+type pkAuthenticator struct {
+	Cusec      int       `asn1:"explicit,tag:0"`
+	CTime      time.Time `asn1:"explicit,generalized,tag:1"`
+	Nonce      int       `asn1:"explicit,tag:2"`
+	PAChecksum []byte    `asn1:"explicit,optional,tag:3"`
+}
+
+// subjectPublicKeyInfo represents the SubjectPublicKeyInfo structure of RFC 5280.
+type subjectPublicKeyInfo struct {
+	Algorithm        pkix.AlgorithmIdentifier
+	SubjectPublicKey asn1.BitString
+}
+
+// dhDomainParameters represents the DomainParameters structure of RFC 3279 section 2.3.3.
+type dhDomainParameters struct {
+	P *big.Int
+	G *big.Int
+	Q *big.Int
+}
+
+// dhRepInfo represents the DHRepInfo structure, sent by the KDC in Diffie-Hellman mode.
+type dhRepInfo struct {
+	DHSignedData  []byte `asn1:"tag:0"`
+	ServerDHNonce []byte `asn1:"explicit,optional,tag:1"`
+}
+
+// kdcDHKeyInfo represents the KDCDHKeyInfo structure, signed by the KDC in Diffie-Hellman mode.
+type kdcDHKeyInfo struct {
+	SubjectPublicKey asn1.BitString `asn1:"explicit,tag:0"`
+	Nonce            *big.Int       `asn1:"explicit,tag:1"`
+	DHKeyExpiration  time.Time      `asn1:"explicit,optional,generalized,tag:2"`
+}
+
+// replyKeyPack represents the ReplyKeyPack structure, signed by the KDC and encrypted to the
+// client certificate in public key encryption mode.
+type replyKeyPack struct {
+	ReplyKey   encryptionKey `asn1:"explicit,tag:0"`
+	ASChecksum checksum      `asn1:"explicit,tag:1"`
+}
+
+// encryptionKey represents the EncryptionKey structure of RFC 4120.
+type encryptionKey struct {
+	KeyType  int32  `asn1:"explicit,tag:0"`
+	KeyValue []byte `asn1:"explicit,tag:1"`
+}
+
+// checksum represents the Checksum structure of RFC 4120.
+type checksum struct {
+	CksumType int32  `asn1:"explicit,tag:0"`
+	Checksum  []byte `asn1:"explicit,tag:1"`
+}
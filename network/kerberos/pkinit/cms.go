@@ -0,0 +1,400 @@
+package pkinit
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/crypto/pkcs7"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc5652
+
+// marshalRaw encodes a value from its class, tag and content.
+func marshalRaw(class, tag int, compound bool, content []byte) ([]byte, error) {
+	return asn1.Marshal(asn1.RawValue{Class: class, Tag: tag, IsCompound: compound, Bytes: content})
+}
+
+// marshalSequence encodes the concatenation of already encoded elements as a SEQUENCE.
+func marshalSequence(elements ...[]byte) ([]byte, error) {
+	content := []byte{}
+	for _, element := range elements {
+		content = append(content, element...)
+	}
+	return marshalRaw(asn1.ClassUniversal, asn1.TagSequence, true, content)
+}
+
+// marshalSet encodes the concatenation of already encoded elements as a SET.
+func marshalSet(elements ...[]byte) ([]byte, error) {
+	content := []byte{}
+	for _, element := range elements {
+		content = append(content, element...)
+	}
+	return marshalRaw(asn1.ClassUniversal, asn1.TagSet, true, content)
+}
+
+// parseElements decodes the content of a constructed value into its elements.
+func parseElements(content []byte) ([]asn1.RawValue, error) {
+	elements := []asn1.RawValue{}
+	for len(content) > 0 {
+		var element asn1.RawValue
+		rest, err := asn1.Unmarshal(content, &element)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		content = rest
+	}
+	return elements, nil
+}
+
+// parseContentInfo decodes a CMS ContentInfo structure and checks its content type.
+func parseContentInfo(data []byte, expectedContentType asn1.ObjectIdentifier) ([]asn1.RawValue, error) {
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS ContentInfo: %w", err)
+	}
+	if !contentInfo.ContentType.Equal(expectedContentType) {
+		return nil, fmt.Errorf("unexpected CMS content type %s, expected %s", contentInfo.ContentType, expectedContentType)
+	}
+	if contentInfo.Content.Class != asn1.ClassContextSpecific || contentInfo.Content.Tag != 0 {
+		return nil, fmt.Errorf("CMS ContentInfo has no content")
+	}
+
+	// The content is explicitly tagged [0]
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS content: %w", err)
+	}
+	if content.Class != asn1.ClassUniversal || content.Tag != asn1.TagSequence {
+		return nil, fmt.Errorf("CMS content is not a SEQUENCE")
+	}
+	return parseElements(content.Bytes)
+}
+
+// createSignedData builds a CMS ContentInfo holding a SignedData structure that encapsulates
+// the content and is signed with SHA-256 and the RSA key of the certificate.
+//
+// Parameters:
+// - contentType: The object identifier of the encapsulated content.
+// - content: A byte slice containing the DER encoded content to sign.
+// - certificate: The certificate of the signer, included in the SignedData structure.
+// - key: The RSA private key of the signer.
+//
+// Returns:
+// - A byte slice containing the DER encoded ContentInfo.
+// - An error if the signature or the encoding fails.
+func createSignedData(contentType asn1.ObjectIdentifier, content []byte, certificate *x509.Certificate, key *rsa.PrivateKey) ([]byte, error) {
+	sha256Algorithm, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: OID_SHA256, Parameters: asn1.NullRawValue})
+	if err != nil {
+		return nil, err
+	}
+
+	// Signed attributes: content type and message digest of the content
+	contentTypeValue, err := asn1.Marshal(contentType)
+	if err != nil {
+		return nil, err
+	}
+	contentTypeAttribute, err := marshalAttribute(OID_CONTENT_TYPE, contentTypeValue)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(content)
+	digestValue, err := asn1.Marshal(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	messageDigestAttribute, err := marshalAttribute(OID_MESSAGE_DIGEST, digestValue)
+	if err != nil {
+		return nil, err
+	}
+	signedAttributes := append(append([]byte{}, contentTypeAttribute...), messageDigestAttribute...)
+
+	// The signature is computed over the DER encoding of the attributes as a SET
+	signedAttributesSet, err := marshalSet(signedAttributes)
+	if err != nil {
+		return nil, err
+	}
+	signedAttributesDigest := sha256.Sum256(signedAttributesSet)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, signedAttributesDigest[:])
+	if err != nil {
+		return nil, fmt.Errorf("error signing CMS attributes: %w", err)
+	}
+
+	// SignerInfo
+	version1, _ := asn1.Marshal(1)
+	serialNumber, err := asn1.Marshal(certificate.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	issuerAndSerialNumber, err := marshalSequence(certificate.RawIssuer, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	signedAttributesImplicit, err := marshalRaw(asn1.ClassContextSpecific, 0, true, signedAttributes)
+	if err != nil {
+		return nil, err
+	}
+	signatureAlgorithm, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: OID_RSA_ENCRYPTION, Parameters: asn1.NullRawValue})
+	if err != nil {
+		return nil, err
+	}
+	signatureValue, err := asn1.Marshal(signature)
+	if err != nil {
+		return nil, err
+	}
+	signerInfo, err := marshalSequence(version1, issuerAndSerialNumber, sha256Algorithm, signedAttributesImplicit, signatureAlgorithm, signatureValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// SignedData
+	version3, _ := asn1.Marshal(3)
+	digestAlgorithms, err := marshalSet(sha256Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	contentTypeOID, err := asn1.Marshal(contentType)
+	if err != nil {
+		return nil, err
+	}
+	contentOctetString, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	eContent, err := marshalRaw(asn1.ClassContextSpecific, 0, true, contentOctetString)
+	if err != nil {
+		return nil, err
+	}
+	encapContentInfo, err := marshalSequence(contentTypeOID, eContent)
+	if err != nil {
+		return nil, err
+	}
+	certificates, err := marshalRaw(asn1.ClassContextSpecific, 0, true, certificate.Raw)
+	if err != nil {
+		return nil, err
+	}
+	signerInfos, err := marshalSet(signerInfo)
+	if err != nil {
+		return nil, err
+	}
+	signedData, err := marshalSequence(version3, digestAlgorithms, encapContentInfo, certificates, signerInfos)
+	if err != nil {
+		return nil, err
+	}
+
+	// ContentInfo
+	signedDataOID, err := asn1.Marshal(OID_CMS_SIGNED_DATA)
+	if err != nil {
+		return nil, err
+	}
+	signedDataExplicit, err := marshalRaw(asn1.ClassContextSpecific, 0, true, signedData)
+	if err != nil {
+		return nil, err
+	}
+	return marshalSequence(signedDataOID, signedDataExplicit)
+}
+
+// marshalAttribute encodes a CMS Attribute holding a single value.
+func marshalAttribute(attributeType asn1.ObjectIdentifier, value []byte) ([]byte, error) {
+	attributeTypeOID, err := asn1.Marshal(attributeType)
+	if err != nil {
+		return nil, err
+	}
+	values, err := marshalSet(value)
+	if err != nil {
+		return nil, err
+	}
+	return marshalSequence(attributeTypeOID, values)
+}
+
+// parseSignedData decodes a CMS ContentInfo holding a SignedData structure and returns its
+// encapsulated content.
+//
+// Parameters:
+// - data: A byte slice containing the DER encoded ContentInfo.
+// - expectedContentType: The object identifier the encapsulated content must have.
+//
+// Returns:
+// - A byte slice containing the encapsulated content.
+// - An error if the structure is malformed or the content type does not match.
+//
+// Note:
+// The signature of the SignedData structure is not verified, as the certificate of the KDC
+// cannot be validated without the trusted certificate authorities of the domain.
+func parseSignedData(data []byte, expectedContentType asn1.ObjectIdentifier) ([]byte, error) {
+	elements, err := parseContentInfo(data, OID_CMS_SIGNED_DATA)
+	if err != nil {
+		return nil, err
+	}
+	// version, digestAlgorithms, encapContentInfo, ...
+	if len(elements) < 3 {
+		return nil, fmt.Errorf("CMS SignedData structure is too short")
+	}
+
+	encapContentInfo, err := parseElements(elements[2].Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS EncapsulatedContentInfo: %w", err)
+	}
+	if len(encapContentInfo) < 2 {
+		return nil, fmt.Errorf("CMS SignedData structure has no encapsulated content")
+	}
+
+	var contentType asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(encapContentInfo[0].FullBytes, &contentType); err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS encapsulated content type: %w", err)
+	}
+	if !contentType.Equal(expectedContentType) {
+		return nil, fmt.Errorf("unexpected CMS encapsulated content type %s, expected %s", contentType, expectedContentType)
+	}
+
+	var content []byte
+	if _, err := asn1.Unmarshal(encapContentInfo[1].Bytes, &content); err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS encapsulated content: %w", err)
+	}
+
+	return content, nil
+}
+
+// decryptEnvelopedData decodes a CMS ContentInfo holding an EnvelopedData structure and decrypts
+// its content with the RSA private key of one of its recipients.
+//
+// Parameters:
+// - data: A byte slice containing the DER encoded ContentInfo.
+// - key: The RSA private key of the recipient.
+//
+// Returns:
+// - A byte slice containing the decrypted content.
+// - An error if the structure is malformed or none of the recipients can be decrypted with the key.
+func decryptEnvelopedData(data []byte, key *rsa.PrivateKey) ([]byte, error) {
+	elements, err := parseContentInfo(data, OID_CMS_ENVELOPED_DATA)
+	if err != nil {
+		return nil, err
+	}
+
+	// version, [0] originatorInfo OPTIONAL, recipientInfos, encryptedContentInfo, ...
+	position := 1
+	if position < len(elements) && elements[position].Class == asn1.ClassContextSpecific {
+		position++
+	}
+	if position+1 >= len(elements) {
+		return nil, fmt.Errorf("CMS EnvelopedData structure is too short")
+	}
+
+	// Recover the content encryption key from the KeyTransRecipientInfo structures
+	recipientInfos, err := parseElements(elements[position].Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS RecipientInfos: %w", err)
+	}
+	var contentEncryptionKey []byte
+	for _, recipientInfo := range recipientInfos {
+		if recipientInfo.Tag != asn1.TagSequence || recipientInfo.Class != asn1.ClassUniversal {
+			continue
+		}
+		contentEncryptionKey, err = decryptKeyTransRecipientInfo(recipientInfo, key)
+		if err == nil {
+			break
+		}
+	}
+	if contentEncryptionKey == nil {
+		return nil, fmt.Errorf("none of the CMS recipients could be decrypted with the private key")
+	}
+
+	// EncryptedContentInfo
+	encryptedContentInfo, err := parseElements(elements[position+1].Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS EncryptedContentInfo: %w", err)
+	}
+	if len(encryptedContentInfo) < 3 {
+		return nil, fmt.Errorf("CMS EnvelopedData structure has no encrypted content")
+	}
+	var contentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	if _, err := asn1.Unmarshal(encryptedContentInfo[1].FullBytes, &contentEncryptionAlgorithm); err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS content encryption algorithm: %w", err)
+	}
+
+	// The encrypted content is an implicitly tagged OCTET STRING, which may be split in several chunks
+	encryptedContent := encryptedContentInfo[2].Bytes
+	if encryptedContentInfo[2].IsCompound {
+		chunks, err := parseElements(encryptedContentInfo[2].Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling CMS encrypted content: %w", err)
+		}
+		encryptedContent = []byte{}
+		for _, chunk := range chunks {
+			encryptedContent = append(encryptedContent, chunk.Bytes...)
+		}
+	}
+
+	return decryptContent(contentEncryptionAlgorithm, contentEncryptionKey, encryptedContent)
+}
+
+// decryptKeyTransRecipientInfo decrypts the content encryption key of a KeyTransRecipientInfo structure.
+func decryptKeyTransRecipientInfo(recipientInfo asn1.RawValue, key *rsa.PrivateKey) ([]byte, error) {
+	// version, rid, keyEncryptionAlgorithm, encryptedKey
+	fields, err := parseElements(recipientInfo.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("CMS KeyTransRecipientInfo structure has %d fields", len(fields))
+	}
+	var keyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	if _, err := asn1.Unmarshal(fields[2].FullBytes, &keyEncryptionAlgorithm); err != nil {
+		return nil, err
+	}
+	var encryptedKey []byte
+	if _, err := asn1.Unmarshal(fields[3].FullBytes, &encryptedKey); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case keyEncryptionAlgorithm.Algorithm.Equal(OID_RSA_ENCRYPTION):
+		return rsa.DecryptPKCS1v15(rand.Reader, key, encryptedKey)
+	case keyEncryptionAlgorithm.Algorithm.Equal(OID_RSAES_OAEP):
+		return rsa.DecryptOAEP(sha1.New(), rand.Reader, key, encryptedKey, nil)
+	}
+	return nil, fmt.Errorf("unsupported CMS key encryption algorithm %s", keyEncryptionAlgorithm.Algorithm)
+}
+
+// decryptContent decrypts the content of an EnvelopedData structure with a CBC mode block cipher.
+func decryptContent(algorithm pkix.AlgorithmIdentifier, contentEncryptionKey []byte, encryptedContent []byte) ([]byte, error) {
+	var block cipher.Block
+	var err error
+	switch {
+	case algorithm.Algorithm.Equal(OID_DES_EDE3_CBC):
+		block, err = des.NewTripleDESCipher(contentEncryptionKey)
+	case algorithm.Algorithm.Equal(OID_AES128_CBC), algorithm.Algorithm.Equal(OID_AES256_CBC):
+		block, err = aes.NewCipher(contentEncryptionKey)
+	default:
+		return nil, fmt.Errorf("unsupported CMS content encryption algorithm %s", algorithm.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("error unmarshalling CMS content encryption IV: %w", err)
+	}
+	if len(iv) != block.BlockSize() || len(encryptedContent)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid CMS encrypted content or IV size")
+	}
+
+	content := make([]byte, len(encryptedContent))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(content, encryptedContent)
+
+	return pkcs7.Unpad(content)
+}
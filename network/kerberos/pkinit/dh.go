@@ -0,0 +1,109 @@
+package pkinit
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// Prime of the 1024-bit MODP group 2 of RFC 2409 section 6.2, which is the group Active Directory KDCs accept
+const modpGroup2Prime = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381" +
+	"FFFFFFFFFFFFFFFF"
+
+// Size of the nonces exchanged in Diffie-Hellman mode
+const dhNonceSize = 32
+
+// dhKeyPair holds the parameters and the key pair of the client in Diffie-Hellman mode.
+type dhKeyPair struct {
+	P *big.Int
+	G *big.Int
+	Q *big.Int
+
+	PrivateKey *big.Int
+	PublicKey  *big.Int
+}
+
+// newDHKeyPair generates a Diffie-Hellman key pair in the MODP group 2.
+func newDHKeyPair() (*dhKeyPair, error) {
+	p, ok := new(big.Int).SetString(modpGroup2Prime, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid Diffie-Hellman prime")
+	}
+	kp := &dhKeyPair{
+		P: p,
+		G: big.NewInt(2),
+		// The group is a safe prime group, q = (p - 1) / 2
+		Q: new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1),
+	}
+
+	privateKey, err := rand.Int(rand.Reader, kp.Q)
+	if err != nil {
+		return nil, err
+	}
+	kp.PrivateKey = privateKey.Add(privateKey, big.NewInt(1))
+	kp.PublicKey = new(big.Int).Exp(kp.G, kp.PrivateKey, kp.P)
+
+	return kp, nil
+}
+
+// subjectPublicKeyInfo returns the clientPublicValue of the AuthPack structure.
+func (kp *dhKeyPair) subjectPublicKeyInfo() (subjectPublicKeyInfo, error) {
+	parameters, err := asn1.Marshal(dhDomainParameters{P: kp.P, G: kp.G, Q: kp.Q})
+	if err != nil {
+		return subjectPublicKeyInfo{}, err
+	}
+	publicKey, err := asn1.Marshal(kp.PublicKey)
+	if err != nil {
+		return subjectPublicKeyInfo{}, err
+	}
+
+	spki := subjectPublicKeyInfo{}
+	spki.Algorithm.Algorithm = OID_DH_PUBLIC_NUMBER
+	spki.Algorithm.Parameters = asn1.RawValue{FullBytes: parameters}
+	spki.SubjectPublicKey = asn1.BitString{Bytes: publicKey, BitLength: len(publicKey) * 8}
+
+	return spki, nil
+}
+
+// sharedSecret computes the DHSharedSecret from the public key of the KDC, encoded as a big endian
+// integer of the size of the prime.
+func (kp *dhKeyPair) sharedSecret(serverPublicKey *big.Int) ([]byte, error) {
+	if serverPublicKey.Cmp(big.NewInt(1)) <= 0 || serverPublicKey.Cmp(kp.P) >= 0 {
+		return nil, fmt.Errorf("invalid Diffie-Hellman public key of the KDC")
+	}
+	secret := new(big.Int).Exp(serverPublicKey, kp.PrivateKey, kp.P)
+	return secret.FillBytes(make([]byte, (kp.P.BitLen()+7)/8)), nil
+}
+
+// OctetString2Key implements the octetstring2key function of RFC 4556 section 3.2.3.1, which
+// derives the AS reply key from the Diffie-Hellman shared secret and the nonces:
+// k-truncate(SHA1(0x00 | x) | SHA1(0x01 | x) | ...) with x = DHSharedSecret | n_c | n_k
+//
+// Parameters:
+// - sharedSecret: A byte slice containing the Diffie-Hellman shared secret.
+// - clientNonce: A byte slice containing the clientDHNonce, or nil if none was sent.
+// - serverNonce: A byte slice containing the serverDHNonce, or nil if none was received.
+// - keySize: An integer representing the size of the key to derive in bytes.
+//
+// Returns:
+// - A byte slice of keySize bytes containing the key.
+func OctetString2Key(sharedSecret, clientNonce, serverNonce []byte, keySize int) []byte {
+	x := append(append(append([]byte{}, sharedSecret...), clientNonce...), serverNonce...)
+
+	output := make([]byte, 0, keySize+sha1.Size)
+	for counter := 0; len(output) < keySize; counter++ {
+		digest := sha1.New()
+		digest.Write([]byte{byte(counter)})
+		digest.Write(x)
+		output = digest.Sum(output)
+	}
+
+	// The random-to-key function of the AES and RC4 encryption types is the identity
+	return output[:keySize]
+}
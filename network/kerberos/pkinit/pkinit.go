@@ -0,0 +1,296 @@
+package pkinit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos"
+	"github.com/TheManticoreProject/Manticore/windows/keycredential/crypto"
+
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/iana/patype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Src: https://www.rfc-editor.org/rfc/rfc4556
+
+// PKINIT modes, which define how the AS reply key is established
+const (
+	// The reply key is derived from an ephemeral Diffie-Hellman exchange
+	PKINIT_MODE_DIFFIE_HELLMAN = iota
+	// The reply key is generated by the KDC and encrypted to the certificate of the client
+	PKINIT_MODE_PUBLIC_KEY_ENCRYPTION
+)
+
+// Key usage of the asChecksum of the ReplyKeyPack structure
+const pkinitASChecksumKeyUsage uint32 = 6
+
+// KERB-PA-PAC-REQUEST with include-pac set to TRUE
+var paPACRequestIncludePAC = []byte{0x30, 0x05, 0xa0, 0x03, 0x01, 0x01, 0xff}
+
+// Result holds the outcome of a PKINIT authentication.
+type Result struct {
+	// ASRep is the AS-REP returned by the KDC, holding the TGT
+	ASRep messages.ASRep
+	// EncPart is the decrypted part of the AS-REP
+	EncPart messages.EncKDCRepPart
+	// ReplyKey is the key that encrypted the AS-REP, needed to decrypt the credentials of the PAC
+	ReplyKey *krbcrypto.Key
+	// SessionKey is the session key of the TGT
+	SessionKey *krbcrypto.Key
+}
+
+// RequestTGT authenticates to a KDC with a certificate using PKINIT and returns the TGT.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user the certificate is mapped to.
+// - certificate: A pointer to an X509Certificate holding the certificate and its RSA private key.
+// - mode: An integer representing the PKINIT mode to use, one of the PKINIT_MODE_* constants.
+//
+// Returns:
+// - A pointer to a Result object holding the TGT and the keys of the exchange.
+// - An error if the authentication fails. If the KDC replied with a KRB-ERROR, the error is a messages.KRBError.
+//
+// Note:
+// This works with certificates issued by the certificate authorities of the domain, and with the self-signed
+// certificates of shadow credentials added to the msDS-KeyCredentialLink attribute of the account.
+func RequestTGT(kdcAddress, realm, username string, certificate *crypto.X509Certificate, mode int) (*Result, error) {
+	realm = strings.ToUpper(realm)
+
+	krb5Conf := config.New()
+	krb5Conf.LibDefaults.DefaultRealm = realm
+	krb5Conf.LibDefaults.DefaultTktEnctypeIDs = []int32{krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, krbcrypto.ETYPE_AES128_CTS_HMAC_SHA1_96, krbcrypto.ETYPE_RC4_HMAC}
+	krb5Conf.LibDefaults.NoAddresses = true
+
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{username}}
+	asReq, err := messages.NewASReqForTGT(realm, krb5Conf, cname)
+	if err != nil {
+		return nil, fmt.Errorf("error building AS-REQ: %w", err)
+	}
+
+	// The AuthPack is bound to the request by a checksum of its body
+	body, err := asReq.ReqBody.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling AS-REQ body: %w", err)
+	}
+	bodyChecksum := sha1.Sum(body)
+
+	now := time.Now().UTC()
+	ap := authPack{
+		PKAuthenticator: pkAuthenticator{
+			Cusec:      now.Nanosecond() / 1000,
+			CTime:      now.Truncate(time.Second),
+			Nonce:      asReq.ReqBody.Nonce,
+			PAChecksum: bodyChecksum[:],
+		},
+	}
+
+	var keyPair *dhKeyPair
+	var clientNonce []byte
+	switch mode {
+	case PKINIT_MODE_DIFFIE_HELLMAN:
+		keyPair, err = newDHKeyPair()
+		if err != nil {
+			return nil, fmt.Errorf("error generating Diffie-Hellman key pair: %w", err)
+		}
+		ap.ClientPublicValue, err = keyPair.subjectPublicKeyInfo()
+		if err != nil {
+			return nil, err
+		}
+		clientNonce = make([]byte, dhNonceSize)
+		if _, err := rand.Read(clientNonce); err != nil {
+			return nil, err
+		}
+		ap.ClientDHNonce = clientNonce
+	case PKINIT_MODE_PUBLIC_KEY_ENCRYPTION:
+		// The absence of clientPublicValue selects the public key encryption mode
+	default:
+		return nil, fmt.Errorf("unknown PKINIT mode %d", mode)
+	}
+
+	authPackBytes, err := asn1.Marshal(ap)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling AuthPack: %w", err)
+	}
+	signedAuthPack, err := createSignedData(OID_PKINIT_AUTH_DATA, authPackBytes, certificate.GetCertificate(), certificate.GetRSAPrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	paPKASReqBytes, err := asn1.Marshal(paPKASReq{SignedAuthPack: signedAuthPack})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling PA-PK-AS-REQ: %w", err)
+	}
+
+	asReq.PAData = types.PADataSequence{
+		types.PAData{PADataType: patype.PA_PK_AS_REQ, PADataValue: paPKASReqBytes},
+		types.PAData{PADataType: patype.PA_PAC_REQUEST, PADataValue: paPACRequestIncludePAC},
+	}
+
+	request, err := asReq.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling AS-REQ: %w", err)
+	}
+
+	reply, err := kerberos.SendToKDC(kdcAddress, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	if err := result.ASRep.Unmarshal(reply); err != nil {
+		return nil, fmt.Errorf("error unmarshalling AS-REP: %w", err)
+	}
+
+	var paPKASRep []byte
+	for _, paData := range result.ASRep.PAData {
+		if paData.PADataType == patype.PA_PK_AS_REP {
+			paPKASRep = paData.PADataValue
+		}
+	}
+	if paPKASRep == nil {
+		return nil, fmt.Errorf("AS-REP does not contain a PA-PK-AS-REP")
+	}
+
+	encryptionType, err := krbcrypto.GetEncryptionType(result.ASRep.EncPart.EType)
+	if err != nil {
+		return nil, err
+	}
+
+	var replyKey []byte
+	if mode == PKINIT_MODE_DIFFIE_HELLMAN {
+		replyKey, err = processDHReply(paPKASRep, keyPair, clientNonce, asReq.ReqBody.Nonce, encryptionType.GetKeyByteSize())
+	} else {
+		replyKey, err = processPublicKeyEncryptionReply(paPKASRep, certificate, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.ReplyKey = &krbcrypto.Key{EType: result.ASRep.EncPart.EType, Value: replyKey}
+
+	plaintext, err := result.ReplyKey.Decrypt(krbcrypto.KEY_USAGE_AS_REP_ENCPART, result.ASRep.EncPart.Cipher)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting AS-REP: %w", err)
+	}
+	if err := result.EncPart.Unmarshal(plaintext); err != nil {
+		return nil, fmt.Errorf("error unmarshalling the encrypted part of the AS-REP: %w", err)
+	}
+	if result.EncPart.Nonce != asReq.ReqBody.Nonce {
+		return nil, fmt.Errorf("nonce of the AS-REP does not match the nonce of the AS-REQ")
+	}
+	result.SessionKey = &krbcrypto.Key{EType: result.EncPart.Key.KeyType, Value: result.EncPart.Key.KeyValue}
+
+	return result, nil
+}
+
+// RequestTGTWithPFX authenticates to a KDC with the certificate of a PFX file using PKINIT and returns the TGT.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user the certificate is mapped to.
+// - pathToPFX: A string representing the path to the PFX file holding the certificate and its private key.
+// - password: A string representing the password of the PFX file.
+// - mode: An integer representing the PKINIT mode to use, one of the PKINIT_MODE_* constants.
+//
+// Returns:
+// - A pointer to a Result object holding the TGT and the keys of the exchange.
+// - An error if the PFX file cannot be loaded or the authentication fails.
+func RequestTGTWithPFX(kdcAddress, realm, username, pathToPFX, password string, mode int) (*Result, error) {
+	certificate, err := crypto.LoadX509CertificateFromPFX(pathToPFX, password)
+	if err != nil {
+		return nil, err
+	}
+	return RequestTGT(kdcAddress, realm, username, certificate, mode)
+}
+
+// processDHReply derives the AS reply key from the dhInfo alternative of a PA-PK-AS-REP.
+func processDHReply(paPKASRep []byte, keyPair *dhKeyPair, clientNonce []byte, nonce int, keySize int) ([]byte, error) {
+	var choice asn1.RawValue
+	if _, err := asn1.Unmarshal(paPKASRep, &choice); err != nil {
+		return nil, fmt.Errorf("error unmarshalling PA-PK-AS-REP: %w", err)
+	}
+	if choice.Class != asn1.ClassContextSpecific || choice.Tag != 0 {
+		return nil, fmt.Errorf("KDC did not reply in Diffie-Hellman mode")
+	}
+
+	var repInfo dhRepInfo
+	if _, err := asn1.Unmarshal(choice.Bytes, &repInfo); err != nil {
+		return nil, fmt.Errorf("error unmarshalling DHRepInfo: %w", err)
+	}
+
+	content, err := parseSignedData(repInfo.DHSignedData, OID_PKINIT_DHKEY_DATA)
+	if err != nil {
+		return nil, err
+	}
+	var keyInfo kdcDHKeyInfo
+	if _, err := asn1.Unmarshal(content, &keyInfo); err != nil {
+		return nil, fmt.Errorf("error unmarshalling KDCDHKeyInfo: %w", err)
+	}
+	if keyInfo.Nonce.Cmp(big.NewInt(int64(nonce))) != 0 {
+		return nil, fmt.Errorf("nonce of the KDCDHKeyInfo does not match the nonce of the AS-REQ")
+	}
+
+	serverPublicKey := new(big.Int)
+	if _, err := asn1.Unmarshal(keyInfo.SubjectPublicKey.Bytes, &serverPublicKey); err != nil {
+		return nil, fmt.Errorf("error unmarshalling the Diffie-Hellman public key of the KDC: %w", err)
+	}
+	sharedSecret, err := keyPair.sharedSecret(serverPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The nonces are only part of the key derivation when both were exchanged
+	if repInfo.ServerDHNonce == nil {
+		clientNonce = nil
+	}
+
+	return OctetString2Key(sharedSecret, clientNonce, repInfo.ServerDHNonce, keySize), nil
+}
+
+// processPublicKeyEncryptionReply decrypts the AS reply key from the encKeyPack alternative of a
+// PA-PK-AS-REP and checks that it is bound to the AS-REQ.
+func processPublicKeyEncryptionReply(paPKASRep []byte, certificate *crypto.X509Certificate, asReq []byte) ([]byte, error) {
+	var choice asn1.RawValue
+	if _, err := asn1.Unmarshal(paPKASRep, &choice); err != nil {
+		return nil, fmt.Errorf("error unmarshalling PA-PK-AS-REP: %w", err)
+	}
+	if choice.Class != asn1.ClassContextSpecific || choice.Tag != 1 {
+		return nil, fmt.Errorf("KDC did not reply in public key encryption mode")
+	}
+
+	signedData, err := decryptEnvelopedData(choice.Bytes, certificate.GetRSAPrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	content, err := parseSignedData(signedData, OID_PKINIT_RKEY_DATA)
+	if err != nil {
+		return nil, err
+	}
+	var keyPack replyKeyPack
+	if _, err := asn1.Unmarshal(content, &keyPack); err != nil {
+		return nil, fmt.Errorf("error unmarshalling ReplyKeyPack: %w", err)
+	}
+
+	replyKey := &krbcrypto.Key{EType: keyPack.ReplyKey.KeyType, Value: keyPack.ReplyKey.KeyValue}
+	asChecksum, err := replyKey.Checksum(pkinitASChecksumKeyUsage, asReq)
+	if err != nil {
+		return nil, err
+	}
+	// hmac.Equal rejects checksums of a different length, but accepts two empty ones
+	if len(keyPack.ASChecksum.Checksum) == 0 || !hmac.Equal(asChecksum, keyPack.ASChecksum.Checksum) {
+		return nil, fmt.Errorf("asChecksum of the ReplyKeyPack does not match the AS-REQ")
+	}
+
+	return replyKey.Value, nil
+}
@@ -0,0 +1,239 @@
+package pkinit
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/crypto/pkcs7"
+	"github.com/TheManticoreProject/Manticore/windows/keycredential/crypto"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func newTestCertificate(t *testing.T) *crypto.X509Certificate {
+	t.Helper()
+	certificate, err := crypto.NewX509Certificate("pkinit", 2048, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("NewX509Certificate() error = %v", err)
+	}
+	return certificate
+}
+
+func TestLoadPBES2PFX(t *testing.T) {
+	certificate := newTestCertificate(t)
+
+	// PFX data encrypted with PBES2, PBKDF2 and AES-256-CBC, as exported by current versions of Windows
+	pfxData, err := pkcs12.Modern.Encode(certificate.GetRSAPrivateKey(), certificate.GetCertificate(), nil, "P@ssw0rd")
+	if err != nil {
+		t.Fatalf("pkcs12.Modern.Encode() error = %v", err)
+	}
+
+	imported, err := crypto.NewX509CertificateFromPFXBytes(pfxData, "P@ssw0rd")
+	if err != nil {
+		t.Fatalf("NewX509CertificateFromPFXBytes() error = %v", err)
+	}
+	if !imported.GetRSAPrivateKey().Equal(certificate.GetRSAPrivateKey()) {
+		t.Errorf("imported private key does not match the exported one")
+	}
+	if !bytes.Equal(imported.GetCertificate().Raw, certificate.GetCertificate().Raw) {
+		t.Errorf("imported certificate does not match the exported one")
+	}
+}
+
+func TestOctetString2Key(t *testing.T) {
+	sharedSecret := bytes.Repeat([]byte{0x42}, 128)
+	clientNonce := bytes.Repeat([]byte{0x01}, 32)
+	serverNonce := bytes.Repeat([]byte{0x02}, 32)
+
+	x := append(append(append([]byte{}, sharedSecret...), clientNonce...), serverNonce...)
+	first := sha1.Sum(append([]byte{0x00}, x...))
+	second := sha1.Sum(append([]byte{0x01}, x...))
+	expected := append(first[:], second[:]...)
+
+	tests := []struct {
+		name    string
+		keySize int
+	}{
+		{"AES128", 16},
+		{"AES256", 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := OctetString2Key(sharedSecret, clientNonce, serverNonce, tt.keySize)
+			if !bytes.Equal(key, expected[:tt.keySize]) {
+				t.Errorf("OctetString2Key() = %x, want %x", key, expected[:tt.keySize])
+			}
+		})
+	}
+}
+
+func TestSignedDataRoundTrip(t *testing.T) {
+	certificate := newTestCertificate(t)
+	content := []byte("AuthPack")
+
+	signedData, err := createSignedData(OID_PKINIT_AUTH_DATA, content, certificate.GetCertificate(), certificate.GetRSAPrivateKey())
+	if err != nil {
+		t.Fatalf("createSignedData() error = %v", err)
+	}
+
+	parsed, err := parseSignedData(signedData, OID_PKINIT_AUTH_DATA)
+	if err != nil {
+		t.Fatalf("parseSignedData() error = %v", err)
+	}
+	if !bytes.Equal(parsed, content) {
+		t.Errorf("parseSignedData() = %x, want %x", parsed, content)
+	}
+
+	if _, err := parseSignedData(signedData, OID_PKINIT_DHKEY_DATA); err == nil {
+		t.Errorf("parseSignedData() with the wrong content type should fail")
+	}
+}
+
+func TestProcessDHReply(t *testing.T) {
+	certificate := newTestCertificate(t)
+
+	clientKeyPair, err := newDHKeyPair()
+	if err != nil {
+		t.Fatalf("newDHKeyPair() error = %v", err)
+	}
+	clientNonce := bytes.Repeat([]byte{0x11}, dhNonceSize)
+	serverNonce := bytes.Repeat([]byte{0x22}, dhNonceSize)
+	nonce := 123456789
+
+	// Build the reply of the KDC
+	serverKeyPair, err := newDHKeyPair()
+	if err != nil {
+		t.Fatalf("newDHKeyPair() error = %v", err)
+	}
+	serverPublicKey, _ := asn1.Marshal(serverKeyPair.PublicKey)
+	keyInfo, _ := asn1.Marshal(kdcDHKeyInfo{
+		SubjectPublicKey: asn1.BitString{Bytes: serverPublicKey, BitLength: len(serverPublicKey) * 8},
+		Nonce:            big.NewInt(int64(nonce)),
+	})
+	signedKeyInfo, err := createSignedData(OID_PKINIT_DHKEY_DATA, keyInfo, certificate.GetCertificate(), certificate.GetRSAPrivateKey())
+	if err != nil {
+		t.Fatalf("createSignedData() error = %v", err)
+	}
+	repInfo, _ := asn1.Marshal(dhRepInfo{DHSignedData: signedKeyInfo, ServerDHNonce: serverNonce})
+	paPKASRep, _ := marshalRaw(asn1.ClassContextSpecific, 0, true, repInfo)
+
+	// The KDC derives the key from its own private key
+	secret := new(big.Int).Exp(clientKeyPair.PublicKey, serverKeyPair.PrivateKey, serverKeyPair.P)
+	expected := OctetString2Key(secret.FillBytes(make([]byte, 128)), clientNonce, serverNonce, 32)
+
+	key, err := processDHReply(paPKASRep, clientKeyPair, clientNonce, nonce, 32)
+	if err != nil {
+		t.Fatalf("processDHReply() error = %v", err)
+	}
+	if !bytes.Equal(key, expected) {
+		t.Errorf("processDHReply() = %x, want %x", key, expected)
+	}
+
+	if _, err := processDHReply(paPKASRep, clientKeyPair, clientNonce, nonce+1, 32); err == nil {
+		t.Errorf("processDHReply() with the wrong nonce should fail")
+	}
+}
+
+// newTestEnvelopedData builds a CMS ContentInfo holding an EnvelopedData structure, which
+// encrypts the content with AES256-CBC to the certificate.
+func newTestEnvelopedData(t *testing.T, certificate *crypto.X509Certificate, content []byte) []byte {
+	t.Helper()
+
+	// Encrypt the content with AES256-CBC and the content encryption key to the certificate
+	contentEncryptionKey := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(contentEncryptionKey)
+	rand.Read(iv)
+	padded, _ := pkcs7.Pad(content, aes.BlockSize)
+	block, _ := aes.NewCipher(contentEncryptionKey)
+	encryptedContent := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encryptedContent, padded)
+	encryptedKey, err := rsa.EncryptPKCS1v15(rand.Reader, certificate.GetRSAPublicKey(), contentEncryptionKey)
+	if err != nil {
+		t.Fatalf("EncryptPKCS1v15() error = %v", err)
+	}
+
+	version, _ := asn1.Marshal(0)
+	issuerAndSerialNumber, _ := asn1.Marshal(struct {
+		Issuer       asn1.RawValue
+		SerialNumber *big.Int
+	}{asn1.RawValue{FullBytes: certificate.GetCertificate().RawIssuer}, certificate.GetCertificate().SerialNumber})
+	keyEncryptionAlgorithm, _ := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: OID_RSA_ENCRYPTION, Parameters: asn1.NullRawValue})
+	encryptedKeyBytes, _ := asn1.Marshal(encryptedKey)
+	recipientInfo, _ := marshalSequence(version, issuerAndSerialNumber, keyEncryptionAlgorithm, encryptedKeyBytes)
+	recipientInfos, _ := marshalSet(recipientInfo)
+
+	contentType, _ := asn1.Marshal(OID_CMS_SIGNED_DATA)
+	ivBytes, _ := asn1.Marshal(iv)
+	contentEncryptionAlgorithm, _ := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: OID_AES256_CBC, Parameters: asn1.RawValue{FullBytes: ivBytes}})
+	encryptedContentBytes, _ := marshalRaw(asn1.ClassContextSpecific, 0, false, encryptedContent)
+	encryptedContentInfo, _ := marshalSequence(contentType, contentEncryptionAlgorithm, encryptedContentBytes)
+
+	envelopedData, _ := marshalSequence(version, recipientInfos, encryptedContentInfo)
+	envelopedDataContentType, _ := asn1.Marshal(OID_CMS_ENVELOPED_DATA)
+	explicitContent, _ := marshalRaw(asn1.ClassContextSpecific, 0, true, envelopedData)
+	contentInfo, _ := marshalSequence(envelopedDataContentType, explicitContent)
+	return contentInfo
+}
+
+func TestDecryptEnvelopedData(t *testing.T) {
+	certificate := newTestCertificate(t)
+	content := []byte("ContentInfo of the ReplyKeyPack")
+
+	decrypted, err := decryptEnvelopedData(newTestEnvelopedData(t, certificate, content), certificate.GetRSAPrivateKey())
+	if err != nil {
+		t.Fatalf("decryptEnvelopedData() error = %v", err)
+	}
+	if !bytes.Equal(decrypted, content) {
+		t.Errorf("decryptEnvelopedData() = %q, want %q", decrypted, content)
+	}
+}
+
+func TestProcessPublicKeyEncryptionReply(t *testing.T) {
+	certificate := newTestCertificate(t)
+	asReq := []byte("AS-REQ")
+	replyKey := &krbcrypto.Key{EType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, Value: bytes.Repeat([]byte{0x42}, 32)}
+	asChecksum, err := replyKey.Checksum(pkinitASChecksumKeyUsage, asReq)
+	if err != nil {
+		t.Fatalf("Checksum() error = %v", err)
+	}
+
+	// Build the reply of the KDC, signing the ReplyKeyPack and encrypting it to the certificate
+	newReply := func(checksumValue []byte) []byte {
+		keyPack, _ := asn1.Marshal(replyKeyPack{
+			ReplyKey:   encryptionKey{KeyType: replyKey.EType, KeyValue: replyKey.Value},
+			ASChecksum: checksum{CksumType: krbcrypto.CKSUMTYPE_HMAC_SHA1_96_AES256, Checksum: checksumValue},
+		})
+		signedKeyPack, err := createSignedData(OID_PKINIT_RKEY_DATA, keyPack, certificate.GetCertificate(), certificate.GetRSAPrivateKey())
+		if err != nil {
+			t.Fatalf("createSignedData() error = %v", err)
+		}
+		paPKASRep, _ := marshalRaw(asn1.ClassContextSpecific, 1, true, newTestEnvelopedData(t, certificate, signedKeyPack))
+		return paPKASRep
+	}
+
+	key, err := processPublicKeyEncryptionReply(newReply(asChecksum), certificate, asReq)
+	if err != nil {
+		t.Fatalf("processPublicKeyEncryptionReply() error = %v", err)
+	}
+	if !bytes.Equal(key, replyKey.Value) {
+		t.Errorf("processPublicKeyEncryptionReply() = %x, want %x", key, replyKey.Value)
+	}
+
+	// A truncated or stripped asChecksum does not bind the reply key to the AS-REQ
+	for _, checksumValue := range [][]byte{asChecksum[:4], {}} {
+		if _, err := processPublicKeyEncryptionReply(newReply(checksumValue), certificate, asReq); err == nil {
+			t.Errorf("processPublicKeyEncryptionReply() with the asChecksum %x should fail", checksumValue)
+		}
+	}
+}
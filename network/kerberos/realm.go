@@ -0,0 +1,472 @@
+package kerberos
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+
+	"github.com/jcmturner/gokrb5/v8/config"
+)
+
+// Uses of the encryption types of a Kerberos configuration
+const (
+	// ENCTYPES_USE_AS is the use of the encryption types requested in AS-REQ messages (default_tkt_enctypes)
+	ENCTYPES_USE_AS = iota
+	// ENCTYPES_USE_TGS is the use of the encryption types requested in TGS-REQ messages (default_tgs_enctypes)
+	ENCTYPES_USE_TGS
+	// ENCTYPES_USE_PERMITTED is the use of the encryption types accepted for session keys (permitted_enctypes)
+	ENCTYPES_USE_PERMITTED
+)
+
+// Default encryption types, in order of preference
+var DefaultEncryptionTypes = []int32{
+	krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96,
+	krbcrypto.ETYPE_AES128_CTS_HMAC_SHA1_96,
+	krbcrypto.ETYPE_RC4_HMAC,
+}
+
+// lookupSRV resolves DNS SRV records, it is a variable to be replaced in tests.
+var lookupSRV = net.LookupSRV
+
+// RealmConfig builds the Kerberos configuration of a realm and of its trusted realms.
+//
+// The configuration starts from the defaults used by KerberosInit, or from an existing krb5.conf file, and each
+// With* method overrides a part of it. Errors are kept until Build is called, so that calls can be chained:
+//
+//	krb5Conf, err := kerberos.NewRealmConfig("lab.local").
+//		WithKDCs("lab.local", "dc01.lab.local:88", "dc02.lab.local:88").
+//		WithTrustedRealm("partner.local").
+//		WithDNSDiscovery(true).
+//		WithEncryptionTypes(kerberos.ENCTYPES_USE_TGS, krbcrypto.ETYPE_RC4_HMAC).
+//		Build()
+type RealmConfig struct {
+	config       *config.Config
+	dnsDiscovery bool
+	err          error
+}
+
+// NewRealmConfig creates a RealmConfig for a realm, with the default settings used by KerberosInit.
+//
+// Parameters:
+// - realm: A string representing the name of the realm, which becomes the default realm.
+//
+// Returns:
+// - A pointer to a RealmConfig object.
+func NewRealmConfig(realm string) *RealmConfig {
+	realm = strings.ToUpper(realm)
+
+	krb5Conf := config.New()
+	// LibDefaults
+	krb5Conf.LibDefaults.AllowWeakCrypto = false
+	krb5Conf.LibDefaults.DefaultRealm = realm
+	krb5Conf.LibDefaults.DNSLookupRealm = false
+	krb5Conf.LibDefaults.DNSLookupKDC = false
+	krb5Conf.LibDefaults.TicketLifetime = time.Duration(24) * time.Hour
+	krb5Conf.LibDefaults.RenewLifetime = time.Duration(24*7) * time.Hour
+	krb5Conf.LibDefaults.Forwardable = true
+	krb5Conf.LibDefaults.Proxiable = true
+	krb5Conf.LibDefaults.RDNS = false
+	krb5Conf.LibDefaults.UDPPreferenceLimit = 1 // Force use of tcp
+	krb5Conf.LibDefaults.PreferredPreauthTypes = []int{18, 17, 23}
+
+	r := &RealmConfig{config: krb5Conf}
+	r.WithEncryptionTypes(ENCTYPES_USE_AS, DefaultEncryptionTypes...)
+	r.WithEncryptionTypes(ENCTYPES_USE_TGS, DefaultEncryptionTypes...)
+	r.WithEncryptionTypes(ENCTYPES_USE_PERMITTED, DefaultEncryptionTypes...)
+	r.addRealm(realm)
+
+	return r
+}
+
+// NewRealmConfigFromFile creates a RealmConfig from an existing krb5.conf file.
+//
+// Parameters:
+// - pathToFile: A string representing the path to the krb5.conf file.
+//
+// Returns:
+// - A pointer to a RealmConfig object, whose default realm is the default_realm of the file.
+// - An error if the file cannot be read or parsed.
+func NewRealmConfigFromFile(pathToFile string) (*RealmConfig, error) {
+	krb5Conf, err := config.Load(pathToFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Kerberos configuration %s: %w", pathToFile, err)
+	}
+	return &RealmConfig{config: krb5Conf, dnsDiscovery: krb5Conf.LibDefaults.DNSLookupKDC}, nil
+}
+
+// NewRealmConfigFromString creates a RealmConfig from the content of a krb5.conf file.
+//
+// Parameters:
+// - content: A string containing the krb5.conf configuration.
+//
+// Returns:
+// - A pointer to a RealmConfig object, whose default realm is the default_realm of the configuration.
+// - An error if the configuration cannot be parsed.
+func NewRealmConfigFromString(content string) (*RealmConfig, error) {
+	krb5Conf, err := config.NewFromString(content)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Kerberos configuration: %w", err)
+	}
+	return &RealmConfig{config: krb5Conf, dnsDiscovery: krb5Conf.LibDefaults.DNSLookupKDC}, nil
+}
+
+// WithDefaultRealm sets the default realm of the configuration.
+//
+// Parameters:
+// - realm: A string representing the name of the realm.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+func (r *RealmConfig) WithDefaultRealm(realm string) *RealmConfig {
+	realm = strings.ToUpper(realm)
+	r.config.LibDefaults.DefaultRealm = realm
+	r.addRealm(realm)
+	return r
+}
+
+// WithKDCs sets the KDCs of a realm, replacing the KDCs already configured for it.
+//
+// Parameters:
+// - realm: A string representing the name of the realm.
+// - kdcs: A variable number of strings representing the KDCs in the "host:port" format. The port defaults to 88.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+func (r *RealmConfig) WithKDCs(realm string, kdcs ...string) *RealmConfig {
+	entry := r.addRealm(realm)
+	entry.KDC = []string{}
+	for _, kdc := range kdcs {
+		entry.KDC = append(entry.KDC, withDefaultPort(kdc, 88))
+	}
+	return r
+}
+
+// WithKPasswdServers sets the kpasswd servers of a realm, replacing the servers already configured for it.
+//
+// Parameters:
+// - realm: A string representing the name of the realm.
+// - servers: A variable number of strings representing the kpasswd servers in the "host:port" format. The port defaults to 464.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+func (r *RealmConfig) WithKPasswdServers(realm string, servers ...string) *RealmConfig {
+	entry := r.addRealm(realm)
+	entry.KPasswdServer = []string{}
+	for _, server := range servers {
+		entry.KPasswdServer = append(entry.KPasswdServer, withDefaultPort(server, 464))
+	}
+	return r
+}
+
+// WithAdminServers sets the admin servers of a realm, replacing the servers already configured for it.
+//
+// Parameters:
+// - realm: A string representing the name of the realm.
+// - servers: A variable number of strings representing the hostnames of the admin servers.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+func (r *RealmConfig) WithAdminServers(realm string, servers ...string) *RealmConfig {
+	entry := r.addRealm(realm)
+	entry.AdminServer = append([]string{}, servers...)
+	entry.MasterKDC = append([]string{}, servers...)
+	return r
+}
+
+// WithTrustedRealm adds a trusted realm to the configuration, so that cross-realm referrals to it can be followed.
+//
+// Parameters:
+// - realm: A string representing the name of the trusted realm.
+// - domains: A variable number of strings representing DNS domains mapped to the trusted realm, in addition
+// to the domain of the same name as the realm.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+//
+// Note:
+// The KDCs of the trusted realm are either set with WithKDCs or discovered with WithDNSDiscovery.
+// Referrals are only sent by Active Directory KDCs when the name-canonicalize option is set, which this
+// method enables.
+func (r *RealmConfig) WithTrustedRealm(realm string, domains ...string) *RealmConfig {
+	r.addRealm(realm)
+	r.config.LibDefaults.Canonicalize = true
+	for _, domain := range domains {
+		r.mapDomain(domain, realm)
+	}
+	return r
+}
+
+// WithDNSDiscovery enables or disables the discovery of the KDCs of the realms with DNS SRV records.
+//
+// Parameters:
+// - enabled: A boolean indicating whether to discover KDCs with DNS.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+//
+// Note:
+// When enabled, Build resolves the _kerberos._tcp (then _kerberos._udp) and _kpasswd._tcp records of the realms
+// having no KDC or kpasswd server configured. The KDCs of realms reached through referrals, and unknown when
+// building the configuration, are resolved with DNS when they are first contacted.
+func (r *RealmConfig) WithDNSDiscovery(enabled bool) *RealmConfig {
+	r.dnsDiscovery = enabled
+	r.config.LibDefaults.DNSLookupKDC = enabled
+	return r
+}
+
+// WithUDP allows or forbids the use of UDP to contact the KDCs.
+//
+// Parameters:
+// - enabled: A boolean indicating whether small messages may be sent over UDP. If false, TCP is always used.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+func (r *RealmConfig) WithUDP(enabled bool) *RealmConfig {
+	if enabled {
+		// Default limit of MIT Kerberos
+		r.config.LibDefaults.UDPPreferenceLimit = 1465
+	} else {
+		r.config.LibDefaults.UDPPreferenceLimit = 1
+	}
+	return r
+}
+
+// WithLifetimes sets the lifetime and the renewable lifetime requested for tickets.
+//
+// Parameters:
+// - ticketLifetime: A time.Duration representing the lifetime of tickets.
+// - renewLifetime: A time.Duration representing the renewable lifetime of tickets, or 0 to request non-renewable tickets.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+func (r *RealmConfig) WithLifetimes(ticketLifetime, renewLifetime time.Duration) *RealmConfig {
+	if ticketLifetime <= 0 || renewLifetime < 0 {
+		r.setError(fmt.Errorf("invalid ticket lifetimes %s and %s", ticketLifetime, renewLifetime))
+		return r
+	}
+	r.config.LibDefaults.TicketLifetime = ticketLifetime
+	r.config.LibDefaults.RenewLifetime = renewLifetime
+	return r
+}
+
+// WithForwardable sets whether forwardable and proxiable tickets are requested.
+//
+// Parameters:
+// - forwardable: A boolean indicating whether to request forwardable tickets.
+// - proxiable: A boolean indicating whether to request proxiable tickets.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+func (r *RealmConfig) WithForwardable(forwardable, proxiable bool) *RealmConfig {
+	r.config.LibDefaults.Forwardable = forwardable
+	r.config.LibDefaults.Proxiable = proxiable
+	return r
+}
+
+// WithEncryptionTypes sets the encryption types of a use, in order of preference.
+//
+// Parameters:
+// - use: An integer representing the use of the encryption types, one of the ENCTYPES_USE_* constants.
+// - etypes: A variable number of int32 representing the encryption types, one of the crypto/kerberos ETYPE_* constants.
+//
+// Returns:
+// - The RealmConfig, to chain calls.
+//
+// Note:
+// Requesting only RC4_HMAC for the TGS use is useful against legacy services, and to obtain service
+// tickets that are faster to crack.
+func (r *RealmConfig) WithEncryptionTypes(use int, etypes ...int32) *RealmConfig {
+	if len(etypes) == 0 {
+		r.setError(fmt.Errorf("no encryption type given"))
+		return r
+	}
+
+	names := []string{}
+	for _, etype := range etypes {
+		encryptionType, err := krbcrypto.GetEncryptionType(etype)
+		if err != nil {
+			r.setError(err)
+			return r
+		}
+		names = append(names, encryptionType.GetName())
+	}
+	ids := append([]int32{}, etypes...)
+
+	switch use {
+	case ENCTYPES_USE_AS:
+		r.config.LibDefaults.DefaultTktEnctypes = names
+		r.config.LibDefaults.DefaultTktEnctypeIDs = ids
+	case ENCTYPES_USE_TGS:
+		r.config.LibDefaults.DefaultTGSEnctypes = names
+		r.config.LibDefaults.DefaultTGSEnctypeIDs = ids
+	case ENCTYPES_USE_PERMITTED:
+		r.config.LibDefaults.PermittedEnctypes = names
+		r.config.LibDefaults.PermittedEnctypeIDs = ids
+	default:
+		r.setError(fmt.Errorf("unknown encryption types use %d", use))
+	}
+
+	return r
+}
+
+// Build resolves the KDCs to discover and returns the Kerberos configuration.
+//
+// Returns:
+// - A pointer to a gokrb5 config.Config object, usable by gokrb5 clients and by the LDAP session.
+// - An error if a With* method failed, or if a realm has no KDC and none could be discovered.
+func (r *RealmConfig) Build() (*config.Config, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.config.LibDefaults.DefaultRealm) == 0 {
+		return nil, fmt.Errorf("no default realm configured")
+	}
+
+	for i := range r.config.Realms {
+		realm := &r.config.Realms[i]
+
+		if len(realm.KDC) == 0 && r.dnsDiscovery {
+			kdcs, err := DiscoverKDCs(realm.Realm)
+			if err != nil {
+				return nil, err
+			}
+			realm.KDC = kdcs
+		}
+		if len(realm.KDC) == 0 {
+			return nil, fmt.Errorf("no KDC configured for realm %s", realm.Realm)
+		}
+
+		if len(realm.KPasswdServer) == 0 && r.dnsDiscovery {
+			// The kpasswd servers default to the admin servers, then to the KDCs
+			if servers, err := DiscoverKPasswdServers(realm.Realm); err == nil {
+				realm.KPasswdServer = servers
+			}
+		}
+	}
+
+	return r.config, nil
+}
+
+// GetKDCAddress returns the address of the first KDC of a realm in a Kerberos configuration.
+//
+// Parameters:
+// - krb5Conf: A pointer to the Kerberos configuration, such as built by a RealmConfig.
+// - realm: A string representing the name of the realm, or an empty string for the default realm.
+//
+// Returns:
+// - A string containing the address of the KDC in the "host:port" format. The port defaults to 88.
+// - An error if no KDC is configured for the realm.
+func GetKDCAddress(krb5Conf *config.Config, realm string) (string, error) {
+	if len(realm) == 0 {
+		realm = krb5Conf.LibDefaults.DefaultRealm
+	}
+	for _, r := range krb5Conf.Realms {
+		if strings.EqualFold(r.Realm, realm) && len(r.KDC) != 0 {
+			return withDefaultPort(r.KDC[0], 88), nil
+		}
+	}
+	return "", fmt.Errorf("no KDC configured for realm %s", strings.ToUpper(realm))
+}
+
+// DiscoverKDCs discovers the KDCs of a realm with the _kerberos._tcp and _kerberos._udp DNS SRV records.
+//
+// Parameters:
+// - realm: A string representing the name of the realm.
+//
+// Returns:
+// - A slice of strings containing the KDCs in the "host:port" format, ordered by priority and weight.
+// - An error if no SRV record is found.
+func DiscoverKDCs(realm string) ([]string, error) {
+	for _, protocol := range []string{"tcp", "udp"} {
+		kdcs, err := discoverSRV("kerberos", protocol, realm)
+		if err == nil && len(kdcs) != 0 {
+			return kdcs, nil
+		}
+	}
+	return nil, fmt.Errorf("no KDC SRV records found for realm %s", strings.ToUpper(realm))
+}
+
+// DiscoverKPasswdServers discovers the kpasswd servers of a realm with the _kpasswd._tcp and _kpasswd._udp DNS SRV records.
+//
+// Parameters:
+// - realm: A string representing the name of the realm.
+//
+// Returns:
+// - A slice of strings containing the kpasswd servers in the "host:port" format, ordered by priority and weight.
+// - An error if no SRV record is found.
+func DiscoverKPasswdServers(realm string) ([]string, error) {
+	for _, protocol := range []string{"tcp", "udp"} {
+		servers, err := discoverSRV("kpasswd", protocol, realm)
+		if err == nil && len(servers) != 0 {
+			return servers, nil
+		}
+	}
+	return nil, fmt.Errorf("no kpasswd SRV records found for realm %s", strings.ToUpper(realm))
+}
+
+// discoverSRV resolves the SRV records of a service in the DNS domain of a realm.
+func discoverSRV(service, protocol, realm string) ([]string, error) {
+	// net.LookupSRV sorts the records by priority and randomizes them by weight
+	_, records, err := lookupSRV(service, protocol, strings.ToLower(realm))
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{}
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if len(target) == 0 {
+			// A target of "." means that the service is not available in the domain
+			continue
+		}
+		addresses = append(addresses, net.JoinHostPort(target, fmt.Sprintf("%d", record.Port)))
+	}
+
+	return addresses, nil
+}
+
+// addRealm returns the configuration of a realm, adding it with its domain mappings if it does not exist.
+func (r *RealmConfig) addRealm(realm string) *config.Realm {
+	realm = strings.ToUpper(realm)
+	for i := range r.config.Realms {
+		if r.config.Realms[i].Realm == realm {
+			return &r.config.Realms[i]
+		}
+	}
+
+	r.config.Realms = append(r.config.Realms, config.Realm{
+		Realm:         realm,
+		DefaultDomain: realm,
+	})
+	r.mapDomain(realm, realm)
+
+	return &r.config.Realms[len(r.config.Realms)-1]
+}
+
+// mapDomain maps a DNS domain and its subdomains to a realm.
+func (r *RealmConfig) mapDomain(domain, realm string) {
+	if r.config.DomainRealm == nil {
+		r.config.DomainRealm = make(config.DomainRealm)
+	}
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	r.config.DomainRealm[domain] = strings.ToUpper(realm)
+	r.config.DomainRealm["."+domain] = strings.ToUpper(realm)
+}
+
+// setError keeps the first error encountered while building the configuration.
+func (r *RealmConfig) setError(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// withDefaultPort appends a port to an address if it has none.
+func withDefaultPort(address string, port int) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), fmt.Sprintf("%d", port))
+}
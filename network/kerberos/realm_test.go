@@ -0,0 +1,239 @@
+package kerberos
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+)
+
+// stubLookupSRV replaces the DNS SRV resolver with a static set of records for the duration of a test.
+func stubLookupSRV(t *testing.T, records map[string][]*net.SRV) {
+	t.Helper()
+	original := lookupSRV
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		srvs, ok := records["_"+service+"._"+proto+"."+name]
+		if !ok {
+			return "", nil, errors.New("no such host")
+		}
+		return "", srvs, nil
+	}
+	t.Cleanup(func() { lookupSRV = original })
+}
+
+func TestKerberosInitMatchesRealmConfig(t *testing.T) {
+	servicePrincipalName, krb5Conf := KerberosInit("dc01.lab.local", "lab.local")
+	if servicePrincipalName != "ldap/dc01.lab.local" {
+		t.Errorf("unexpected service principal name %s", servicePrincipalName)
+	}
+
+	if krb5Conf.LibDefaults.DefaultRealm != "LAB.LOCAL" {
+		t.Errorf("unexpected default realm %s", krb5Conf.LibDefaults.DefaultRealm)
+	}
+	if len(krb5Conf.Realms) != 1 {
+		t.Fatalf("expected 1 realm, got %d", len(krb5Conf.Realms))
+	}
+	realm := krb5Conf.Realms[0]
+	if !reflect.DeepEqual(realm.KDC, []string{"dc01.lab.local:88"}) {
+		t.Errorf("unexpected KDCs %v", realm.KDC)
+	}
+	if !reflect.DeepEqual(realm.KPasswdServer, []string{"dc01.lab.local:464"}) {
+		t.Errorf("unexpected kpasswd servers %v", realm.KPasswdServer)
+	}
+	if !reflect.DeepEqual(realm.AdminServer, []string{"dc01.lab.local"}) {
+		t.Errorf("unexpected admin servers %v", realm.AdminServer)
+	}
+	if krb5Conf.DomainRealm["lab.local"] != "LAB.LOCAL" || krb5Conf.DomainRealm[".lab.local"] != "LAB.LOCAL" {
+		t.Errorf("unexpected domain realm mapping %v", krb5Conf.DomainRealm)
+	}
+
+	expectedIDs := []int32{18, 17, 23}
+	if !reflect.DeepEqual(krb5Conf.LibDefaults.DefaultTktEnctypeIDs, expectedIDs) ||
+		!reflect.DeepEqual(krb5Conf.LibDefaults.DefaultTGSEnctypeIDs, expectedIDs) ||
+		!reflect.DeepEqual(krb5Conf.LibDefaults.PermittedEnctypeIDs, expectedIDs) {
+		t.Errorf("unexpected encryption types %+v", krb5Conf.LibDefaults)
+	}
+	if krb5Conf.LibDefaults.UDPPreferenceLimit != 1 || krb5Conf.LibDefaults.DNSLookupKDC {
+		t.Errorf("unexpected transport settings %+v", krb5Conf.LibDefaults)
+	}
+	if krb5Conf.LibDefaults.TicketLifetime != 24*time.Hour || krb5Conf.LibDefaults.RenewLifetime != 7*24*time.Hour {
+		t.Errorf("unexpected lifetimes %s and %s", krb5Conf.LibDefaults.TicketLifetime, krb5Conf.LibDefaults.RenewLifetime)
+	}
+}
+
+func TestNewKerberosConfigEmptyRealm(t *testing.T) {
+	_, krb5Conf, err := NewKerberosConfig("dc01.lab.local", "")
+	if err == nil {
+		t.Errorf("expected an error for an empty realm")
+	}
+	if krb5Conf != nil {
+		t.Errorf("expected no configuration for an empty realm")
+	}
+
+	// KerberosInit keeps returning a configuration, on which gokrb5 fails with an error rather than panicking
+	if _, krb5Conf := KerberosInit("dc01.lab.local", ""); krb5Conf == nil {
+		t.Errorf("expected KerberosInit to return a configuration for an empty realm")
+	}
+}
+
+func TestGetKDCAddress(t *testing.T) {
+	krb5Conf, err := NewRealmConfig("lab.local").
+		WithKDCs("lab.local", "dc01.lab.local", "dc02.lab.local").
+		WithKDCs("partner.local", "dc.partner.local:1088").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	tests := []struct {
+		realm    string
+		expected string
+	}{
+		{"", "dc01.lab.local:88"},
+		{"lab.local", "dc01.lab.local:88"},
+		{"PARTNER.LOCAL", "dc.partner.local:1088"},
+	}
+	for _, tt := range tests {
+		address, err := GetKDCAddress(krb5Conf, tt.realm)
+		if err != nil {
+			t.Fatalf("GetKDCAddress(%q) error = %v", tt.realm, err)
+		}
+		if address != tt.expected {
+			t.Errorf("GetKDCAddress(%q) = %s, want %s", tt.realm, address, tt.expected)
+		}
+	}
+
+	if _, err := GetKDCAddress(krb5Conf, "other.local"); err == nil {
+		t.Errorf("expected an error for a realm without KDC")
+	}
+}
+
+func TestRealmConfigEncryptionTypesPerUse(t *testing.T) {
+	krb5Conf, err := NewRealmConfig("lab.local").
+		WithKDCs("lab.local", "kdc.lab.local").
+		WithEncryptionTypes(ENCTYPES_USE_TGS, krbcrypto.ETYPE_RC4_HMAC).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(krb5Conf.LibDefaults.DefaultTGSEnctypeIDs, []int32{krbcrypto.ETYPE_RC4_HMAC}) {
+		t.Errorf("unexpected TGS encryption types %v", krb5Conf.LibDefaults.DefaultTGSEnctypeIDs)
+	}
+	if !reflect.DeepEqual(krb5Conf.LibDefaults.DefaultTktEnctypeIDs, DefaultEncryptionTypes) {
+		t.Errorf("AS encryption types should not change, got %v", krb5Conf.LibDefaults.DefaultTktEnctypeIDs)
+	}
+	if !reflect.DeepEqual(krb5Conf.Realms[0].KDC, []string{"kdc.lab.local:88"}) {
+		t.Errorf("unexpected KDCs %v", krb5Conf.Realms[0].KDC)
+	}
+
+	_, err = NewRealmConfig("lab.local").
+		WithKDCs("lab.local", "kdc.lab.local").
+		WithEncryptionTypes(ENCTYPES_USE_AS, 1234).
+		Build()
+	if err == nil {
+		t.Errorf("expected an error for an unknown encryption type")
+	}
+}
+
+func TestRealmConfigDNSDiscoveryAndTrustedRealm(t *testing.T) {
+	stubLookupSRV(t, map[string][]*net.SRV{
+		"_kerberos._tcp.lab.local": {
+			{Target: "dc01.lab.local.", Port: 88, Priority: 0, Weight: 100},
+			{Target: "dc02.lab.local.", Port: 88, Priority: 10, Weight: 100},
+		},
+		"_kpasswd._tcp.lab.local": {
+			{Target: "dc01.lab.local.", Port: 464},
+		},
+		"_kerberos._udp.partner.local": {
+			{Target: "dc.partner.local.", Port: 88},
+		},
+	})
+
+	krb5Conf, err := NewRealmConfig("lab.local").
+		WithTrustedRealm("partner.local", "partner-corp.com").
+		WithDNSDiscovery(true).
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if len(krb5Conf.Realms) != 2 {
+		t.Fatalf("expected 2 realms, got %d", len(krb5Conf.Realms))
+	}
+	if !reflect.DeepEqual(krb5Conf.Realms[0].KDC, []string{"dc01.lab.local:88", "dc02.lab.local:88"}) {
+		t.Errorf("unexpected KDCs %v", krb5Conf.Realms[0].KDC)
+	}
+	if !reflect.DeepEqual(krb5Conf.Realms[0].KPasswdServer, []string{"dc01.lab.local:464"}) {
+		t.Errorf("unexpected kpasswd servers %v", krb5Conf.Realms[0].KPasswdServer)
+	}
+	if krb5Conf.Realms[1].Realm != "PARTNER.LOCAL" || !reflect.DeepEqual(krb5Conf.Realms[1].KDC, []string{"dc.partner.local:88"}) {
+		t.Errorf("unexpected trusted realm %+v", krb5Conf.Realms[1])
+	}
+	if krb5Conf.DomainRealm[".partner-corp.com"] != "PARTNER.LOCAL" || krb5Conf.DomainRealm["partner.local"] != "PARTNER.LOCAL" {
+		t.Errorf("unexpected domain realm mapping %v", krb5Conf.DomainRealm)
+	}
+	if !krb5Conf.LibDefaults.Canonicalize || !krb5Conf.LibDefaults.DNSLookupKDC {
+		t.Errorf("referrals and DNS lookups should be enabled")
+	}
+
+	// Without discovery, the trusted realm has no KDC
+	_, err = NewRealmConfig("lab.local").
+		WithKDCs("lab.local", "dc01.lab.local").
+		WithTrustedRealm("partner.local").
+		Build()
+	if err == nil {
+		t.Errorf("expected an error for a realm without KDC")
+	}
+}
+
+func TestNewRealmConfigFromFile(t *testing.T) {
+	content := `[libdefaults]
+  default_realm = LAB.LOCAL
+  dns_lookup_kdc = false
+
+[realms]
+  LAB.LOCAL = {
+    kdc = kdc01.lab.local:88
+    kdc = kdc02.lab.local:88
+  }
+
+[domain_realm]
+  .lab.local = LAB.LOCAL
+`
+	pathToFile := filepath.Join(t.TempDir(), "krb5.conf")
+	if err := os.WriteFile(pathToFile, []byte(content), 0600); err != nil {
+		t.Fatalf("error writing krb5.conf: %v", err)
+	}
+
+	realmConfig, err := NewRealmConfigFromFile(pathToFile)
+	if err != nil {
+		t.Fatalf("NewRealmConfigFromFile failed: %v", err)
+	}
+	krb5Conf, err := realmConfig.WithKDCs("child.lab.local", "kdc.child.lab.local:8888").Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if krb5Conf.LibDefaults.DefaultRealm != "LAB.LOCAL" {
+		t.Errorf("unexpected default realm %s", krb5Conf.LibDefaults.DefaultRealm)
+	}
+	if len(krb5Conf.Realms) != 2 {
+		t.Fatalf("expected 2 realms, got %d", len(krb5Conf.Realms))
+	}
+	if !reflect.DeepEqual(krb5Conf.Realms[0].KDC, []string{"kdc01.lab.local:88", "kdc02.lab.local:88"}) {
+		t.Errorf("unexpected KDCs %v", krb5Conf.Realms[0].KDC)
+	}
+	if !reflect.DeepEqual(krb5Conf.Realms[1].KDC, []string{"kdc.child.lab.local:8888"}) {
+		t.Errorf("unexpected KDCs %v", krb5Conf.Realms[1].KDC)
+	}
+
+	if _, err := NewRealmConfigFromFile(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
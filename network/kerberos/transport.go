@@ -0,0 +1,106 @@
+package kerberos
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/messages"
+)
+
+// Maximum size of a reply accepted from a KDC
+const maxKDCReplySize = 0x1000000
+
+// KDCTimeout is the timeout applied to the connection and to each exchange with a KDC.
+var KDCTimeout = time.Duration(5) * time.Second
+
+// SendToKDC sends a raw Kerberos message to a KDC over TCP and returns the raw reply.
+//
+// Parameters:
+// - kdcAddress: A string representing the address of the KDC in the "host:port" format.
+// - request: A byte slice containing the DER encoded Kerberos message to send.
+//
+// Returns:
+// - A byte slice containing the DER encoded reply of the KDC.
+// - An error if the exchange fails. If the KDC replied with a KRB-ERROR, the error is a messages.KRBError.
+//
+// Note:
+// RFC 4120 section 7.2.2 specifies that each message sent over TCP is preceded by its length,
+// encoded as a 4 bytes big endian integer.
+func SendToKDC(kdcAddress string, request []byte) ([]byte, error) {
+	conn, err := dialKDC(kdcAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reply, err := exchangeWithKDC(conn, kdcAddress, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply, CheckForKRBError(reply)
+}
+
+// dialKDC opens a TCP connection to a KDC, with a deadline of KDCTimeout for the whole exchange.
+func dialKDC(kdcAddress string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", kdcAddress, KDCTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to KDC %s: %w", kdcAddress, err)
+	}
+
+	err = conn.SetDeadline(time.Now().Add(KDCTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error setting deadline on connection to KDC %s: %w", kdcAddress, err)
+	}
+
+	return conn, nil
+}
+
+// exchangeWithKDC sends a length-prefixed message on a connection to a KDC and reads the length-prefixed reply.
+func exchangeWithKDC(conn net.Conn, kdcAddress string, request []byte) ([]byte, error) {
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(request)))
+	_, err := conn.Write(append(header, request...))
+	if err != nil {
+		return nil, fmt.Errorf("error sending to KDC %s: %w", kdcAddress, err)
+	}
+
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return nil, fmt.Errorf("error reading reply size from KDC %s: %w", kdcAddress, err)
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length > maxKDCReplySize {
+		return nil, fmt.Errorf("reply of %d bytes from KDC %s exceeds the maximum size", length, kdcAddress)
+	}
+	reply := make([]byte, length)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return nil, fmt.Errorf("error reading reply from KDC %s: %w", kdcAddress, err)
+	}
+	if len(reply) == 0 {
+		return nil, fmt.Errorf("empty reply from KDC %s", kdcAddress)
+	}
+
+	return reply, nil
+}
+
+// CheckForKRBError checks if the raw reply of a KDC is a KRB-ERROR message.
+//
+// Parameters:
+// - reply: A byte slice containing the DER encoded reply of the KDC.
+//
+// Returns:
+// - A messages.KRBError if the reply is a KRB-ERROR message, otherwise nil.
+func CheckForKRBError(reply []byte) error {
+	var krbError messages.KRBError
+	if err := krbError.Unmarshal(reply); err == nil {
+		return krbError
+	}
+	return nil
+}
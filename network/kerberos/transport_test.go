@@ -0,0 +1,32 @@
+package kerberos
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestExchangeWithKDCOversizedReply(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(server, header); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(server, make([]byte, binary.BigEndian.Uint32(header))); err != nil {
+			return
+		}
+		binary.BigEndian.PutUint32(header, 0xffffffff)
+		server.Write(header)
+	}()
+
+	_, err := exchangeWithKDC(client, "kdc:88", []byte{0x6a, 0x00})
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum size") {
+		t.Errorf("Expected an error for a reply exceeding the maximum size, got %v", err)
+	}
+}
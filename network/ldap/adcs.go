@@ -0,0 +1,285 @@
+package ldap
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/objects"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Misconfigurations of certificate templates flagged by the analysis of Active Directory Certificate Services
+// Src: https://specterops.io/wp-content/uploads/sites/3/2022/06/Certified_Pre-Owned.pdf
+const (
+	// The enrollee supplies the subject of a certificate allowing authentication
+	ESC1 = "ESC1"
+	// The certificates can be used for any purpose
+	ESC2 = "ESC2"
+	// The certificates allow to enroll on behalf of other principals
+	ESC3 = "ESC3"
+	// The principal can modify the template
+	ESC4 = "ESC4"
+	// The certificates allowing authentication lack the security extension holding the SID of the enrollee
+	ESC9 = "ESC9"
+	// The certificates allowing authentication have an issuance policy linked to a group
+	ESC13 = "ESC13"
+)
+
+// CertificateTemplateVulnerability represents a misconfiguration of a certificate template exploitable by a principal.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the certificate template.
+//   - TemplateName: The name of the certificate template.
+//   - Technique: The misconfiguration, one of the ESC* constants.
+//   - CertificateAuthorities: The names of the certificate authorities publishing the template.
+//   - TrusteeSIDs: The SIDs of the principal or of its groups through which the rights needed by the technique are
+//     granted, given the deny ACEs of all the SIDs of the principal.
+//   - LinkedGroup: The distinguished name of the group linked to the issuance policy of the template, for ESC13.
+type CertificateTemplateVulnerability struct {
+	DistinguishedName      string
+	TemplateName           string
+	Technique              string
+	CertificateAuthorities []string
+	TrusteeSIDs            []string
+	LinkedGroup            string
+}
+
+// GetAllCertificateTemplates retrieves the certificate templates stored in the configuration naming context,
+// with the owner, group and DACL of their security descriptors.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the templates and the values are pointers to
+//     CertificateTemplate objects.
+//   - An error if the LDAP query fails.
+func (ldapSession *Session) GetAllCertificateTemplates() (map[string]*objects.CertificateTemplate, error) {
+	flags := security.OWNER_SECURITY_INFORMATION | security.GROUP_SECURITY_INFORMATION | security.DACL_SECURITY_INFORMATION
+	controls := []Control{NewSDFlagsControl(flags)}
+	query := "(objectClass=pKICertificateTemplate)"
+
+	ldapResults, err := ldapSession.QueryWithControls("configurationNamingContext", query, objects.CertificateTemplateAttributes, ScopeWholeSubtree, controls)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	templatesMap := make(map[string]*objects.CertificateTemplate)
+	for _, entry := range ldapResults {
+		template := objects.NewCertificateTemplateFromEntry(ldapSession, entry)
+		templatesMap[template.DistinguishedName] = template
+	}
+
+	return templatesMap, nil
+}
+
+// GetAllEnrollmentServices retrieves the enterprise certificate authorities stored in the configuration naming
+// context, with the owner, group and DACL of their security descriptors.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the pKIEnrollmentService objects and the values are
+//     pointers to EnrollmentService objects.
+//   - An error if the LDAP query fails.
+func (ldapSession *Session) GetAllEnrollmentServices() (map[string]*objects.EnrollmentService, error) {
+	flags := security.OWNER_SECURITY_INFORMATION | security.GROUP_SECURITY_INFORMATION | security.DACL_SECURITY_INFORMATION
+	controls := []Control{NewSDFlagsControl(flags)}
+	query := "(objectClass=pKIEnrollmentService)"
+
+	ldapResults, err := ldapSession.QueryWithControls("configurationNamingContext", query, objects.EnrollmentServiceAttributes, ScopeWholeSubtree, controls)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	enrollmentServicesMap := make(map[string]*objects.EnrollmentService)
+	for _, entry := range ldapResults {
+		enrollmentService := objects.NewEnrollmentServiceFromEntry(ldapSession, entry)
+		enrollmentServicesMap[enrollmentService.DistinguishedName] = enrollmentService
+	}
+
+	return enrollmentServicesMap, nil
+}
+
+// GetIssuancePolicyGroupLinks retrieves the issuance policies linked to a group through the msDS-OIDToGroupLink
+// attribute of their msPKI-Enterprise-Oid object. The holders of a certificate with a linked issuance policy are
+// made members of the group in their Kerberos tickets.
+//
+// Returns:
+//   - A map where the keys are the OIDs of the issuance policies and the values are the distinguished names of
+//     the linked groups.
+//   - An error if the LDAP query fails.
+func (ldapSession *Session) GetIssuancePolicyGroupLinks() (map[string]string, error) {
+	attributes := []string{"msPKI-Cert-Template-OID", "msDS-OIDToGroupLink"}
+	query := "(&(objectClass=msPKI-Enterprise-Oid)(msDS-OIDToGroupLink=*))"
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("configurationNamingContext", query, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	groupLinks := make(map[string]string)
+	for _, entry := range ldapResults {
+		oid := entry.GetAttributeValue("msPKI-Cert-Template-OID")
+		if len(oid) != 0 {
+			groupLinks[oid] = entry.GetAttributeValue("msDS-OIDToGroupLink")
+		}
+	}
+
+	return groupLinks, nil
+}
+
+// FindVulnerableCertificateTemplates retrieves the certificate templates, the enterprise certificate authorities
+// and the issuance policies linked to groups, and flags the misconfigurations of the templates exploitable by a
+// principal.
+//
+// Parameters:
+//   - SIDs: A slice of strings containing the SID of the principal and the SIDs of all the groups it is a
+//     transitive member of, including well-known groups such as Everyone and Authenticated Users.
+//
+// Returns:
+//   - A slice of CertificateTemplateVulnerability, sorted by distinguished name of the template.
+//   - An error if a SID is invalid or if an LDAP query fails.
+//
+// Example:
+//
+//	vulnerabilities, err := ldapSession.FindVulnerableCertificateTemplates([]string{"S-1-5-21-...-1104", "S-1-5-11", "S-1-1-0"})
+//	if err == nil {
+//	    for _, vulnerability := range vulnerabilities {
+//	        fmt.Printf("%s: %s on %v\n", vulnerability.TemplateName, vulnerability.Technique, vulnerability.CertificateAuthorities)
+//	    }
+//	}
+func (ldapSession *Session) FindVulnerableCertificateTemplates(SIDs []string) ([]*CertificateTemplateVulnerability, error) {
+	principalSIDs := make([]*sid.SID, 0, len(SIDs))
+	for _, value := range SIDs {
+		principalSID, err := sid.FromString(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SID %s: %w", value, err)
+		}
+		principalSIDs = append(principalSIDs, principalSID)
+	}
+
+	templates, err := ldapSession.GetAllCertificateTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	enrollmentServicesMap, err := ldapSession.GetAllEnrollmentServices()
+	if err != nil {
+		return nil, err
+	}
+	enrollmentServices := make([]*objects.EnrollmentService, 0, len(enrollmentServicesMap))
+	for _, enrollmentService := range enrollmentServicesMap {
+		enrollmentServices = append(enrollmentServices, enrollmentService)
+	}
+	sort.Slice(enrollmentServices, func(i, j int) bool {
+		return enrollmentServices[i].Name < enrollmentServices[j].Name
+	})
+
+	groupLinks, err := ldapSession.GetIssuancePolicyGroupLinks()
+	if err != nil {
+		return nil, err
+	}
+
+	distinguishedNames := make([]string, 0, len(templates))
+	for distinguishedName := range templates {
+		distinguishedNames = append(distinguishedNames, distinguishedName)
+	}
+	sort.Strings(distinguishedNames)
+
+	vulnerabilities := []*CertificateTemplateVulnerability{}
+	for _, distinguishedName := range distinguishedNames {
+		vulnerabilities = append(vulnerabilities, AnalyzeCertificateTemplate(templates[distinguishedName], enrollmentServices, groupLinks, principalSIDs)...)
+	}
+
+	return vulnerabilities, nil
+}
+
+// AnalyzeCertificateTemplate flags the misconfigurations of a certificate template exploitable by a principal.
+//
+// ESC4 is flagged whenever the principal can modify the template. The other techniques require the template to
+// be published by a certificate authority, the principal to be granted the enrollment, and the requests to be
+// issued without the approval of a certificate manager nor the signature of an enrollment agent.
+//
+// Parameters:
+//   - template: A pointer to the certificate template, read with its security descriptor.
+//   - enrollmentServices: A slice of the enterprise certificate authorities.
+//   - groupLinks: A map of the OIDs of the issuance policies to the distinguished names of their linked groups.
+//   - SIDs: A slice of SIDs of the principal and of all the groups it is a transitive member of.
+//
+// Returns:
+//   - A slice of CertificateTemplateVulnerability, empty if the template is not exploitable by the principal.
+func AnalyzeCertificateTemplate(template *objects.CertificateTemplate, enrollmentServices []*objects.EnrollmentService, groupLinks map[string]string, SIDs []*sid.SID) []*CertificateTemplateVulnerability {
+	vulnerabilities := []*CertificateTemplateVulnerability{}
+	if template.SecurityDescriptor == nil {
+		return vulnerabilities
+	}
+
+	certificateAuthorities := []string{}
+	for _, enrollmentService := range enrollmentServices {
+		if enrollmentService.PublishesCertificateTemplate(template.Name) {
+			certificateAuthorities = append(certificateAuthorities, enrollmentService.Name)
+		}
+	}
+
+	newVulnerability := func(technique string, trusteeSIDs []string) *CertificateTemplateVulnerability {
+		return &CertificateTemplateVulnerability{
+			DistinguishedName:      template.DistinguishedName,
+			TemplateName:           template.Name,
+			Technique:              technique,
+			CertificateAuthorities: certificateAuthorities,
+			TrusteeSIDs:            trusteeSIDs,
+		}
+	}
+
+	enrollable := len(certificateAuthorities) != 0 && template.CanEnroll(SIDs) &&
+		!template.RequiresManagerApproval() && !template.RequiresAuthorizedSignatures()
+	if enrollable {
+		enrollmentSIDs := []string{}
+		for _, trusteeSID := range SIDs {
+			if template.CanEnrollThrough(SIDs, trusteeSID) {
+				enrollmentSIDs = append(enrollmentSIDs, trusteeSID.String())
+			}
+		}
+
+		if template.EnrolleeSuppliesSubject() && template.AllowsAuthentication() {
+			vulnerabilities = append(vulnerabilities, newVulnerability(ESC1, enrollmentSIDs))
+		}
+		if template.AllowsAnyPurpose() {
+			vulnerabilities = append(vulnerabilities, newVulnerability(ESC2, enrollmentSIDs))
+		}
+		if template.IsEnrollmentAgentTemplate() {
+			vulnerabilities = append(vulnerabilities, newVulnerability(ESC3, enrollmentSIDs))
+		}
+		if template.HasNoSecurityExtension() && template.AllowsAuthentication() {
+			vulnerabilities = append(vulnerabilities, newVulnerability(ESC9, enrollmentSIDs))
+		}
+		if template.AllowsAuthentication() {
+			for _, oid := range template.IssuancePolicies {
+				if group, ok := groupLinks[oid]; ok {
+					vulnerability := newVulnerability(ESC13, enrollmentSIDs)
+					vulnerability.LinkedGroup = group
+					vulnerabilities = append(vulnerabilities, vulnerability)
+					break
+				}
+			}
+		}
+	}
+
+	modificationRights := []string{DANGEROUS_RIGHT_GENERIC_ALL, DANGEROUS_RIGHT_GENERIC_WRITE, DANGEROUS_RIGHT_WRITE_DACL, DANGEROUS_RIGHT_WRITE_OWNER}
+	grantsModification := func(rights *security.EffectiveRights) bool {
+		for _, right := range modificationRights {
+			if hasDangerousRight(rights, right) {
+				return true
+			}
+		}
+		return false
+	}
+	if grantsModification(template.SecurityDescriptor.GetEffectiveRights(SIDs)) {
+		trusteeSIDs := []string{}
+		for _, trusteeSID := range SIDs {
+			if grantsModification(template.SecurityDescriptor.GetTrusteeRights(SIDs, trusteeSID)) {
+				trusteeSIDs = append(trusteeSIDs, trusteeSID.String())
+			}
+		}
+		vulnerabilities = append(vulnerabilities, newVulnerability(ESC4, trusteeSIDs))
+	}
+
+	return vulnerabilities
+}
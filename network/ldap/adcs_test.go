@@ -0,0 +1,102 @@
+package ldap_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap"
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/network/ldap/objects"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+func TestAnalyzeCertificateTemplate(t *testing.T) {
+	user, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	domainUsers, _ := sid.FromString("S-1-5-21-1-2-3-513")
+	domainSID, _ := sid.FromString("S-1-5-21-1-2-3")
+
+	enrollmentServices := []*objects.EnrollmentService{
+		{Object: objects.Object{Name: "lab-CA"}, CertificateTemplates: []string{"Template"}},
+	}
+	groupLinks := map[string]string{"1.3.6.1.4.1.311.21.8.1.2": "CN=Admins,DC=lab,DC=local"}
+
+	enroll := "D:(OA;;CR;0e10c968-78fb-11d2-90d4-00c04f79dc55;;DU)"
+	tests := []struct {
+		name     string
+		sddl     string
+		template objects.CertificateTemplate
+		expected []string
+	}{
+		{"Enrollee supplies subject", enroll, objects.CertificateTemplate{SchemaVersion: 2,
+			ExtendedKeyUsages:   []string{ldap_attributes.EKU_CLIENT_AUTHENTICATION},
+			CertificateNameFlag: ldap_attributes.MSPKI_CERTIFICATE_NAME_FLAG_ENROLLEE_SUPPLIES_SUBJECT}, []string{ldap.ESC1}},
+		{"Manager approval", enroll, objects.CertificateTemplate{SchemaVersion: 2,
+			ExtendedKeyUsages:   []string{ldap_attributes.EKU_CLIENT_AUTHENTICATION},
+			CertificateNameFlag: ldap_attributes.MSPKI_CERTIFICATE_NAME_FLAG_ENROLLEE_SUPPLIES_SUBJECT,
+			EnrollmentFlag:      ldap_attributes.MSPKI_ENROLLMENT_FLAG_PEND_ALL_REQUESTS}, []string{}},
+		{"No enrollment right", "D:(A;;RP;;;DU)", objects.CertificateTemplate{SchemaVersion: 1}, []string{}},
+		{"No extended key usage", enroll, objects.CertificateTemplate{SchemaVersion: 1}, []string{ldap.ESC2}},
+		{"Enrollment agent", enroll, objects.CertificateTemplate{SchemaVersion: 1,
+			ExtendedKeyUsages: []string{ldap_attributes.EKU_CERTIFICATE_REQUEST_AGENT}}, []string{ldap.ESC3}},
+		{"No security extension and issuance policy", enroll, objects.CertificateTemplate{SchemaVersion: 2,
+			ExtendedKeyUsages: []string{ldap_attributes.EKU_CLIENT_AUTHENTICATION},
+			EnrollmentFlag:    ldap_attributes.MSPKI_ENROLLMENT_FLAG_NO_SECURITY_EXTENSION,
+			IssuancePolicies:  []string{"1.3.6.1.4.1.311.21.8.1.2"}}, []string{ldap.ESC9, ldap.ESC13}},
+		{"Write owner", "D:(A;;WO;;;S-1-5-21-1-2-3-1104)", objects.CertificateTemplate{SchemaVersion: 2,
+			ExtendedKeyUsages: []string{ldap_attributes.EKU_SERVER_AUTHENTICATION}}, []string{ldap.ESC4}},
+		{"Write owner denied to a group", "D:(D;;WO;;;DU)(A;;WO;;;S-1-5-21-1-2-3-1104)", objects.CertificateTemplate{SchemaVersion: 2,
+			ExtendedKeyUsages: []string{ldap_attributes.EKU_SERVER_AUTHENTICATION}}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd, err := security.ParseSDDL(tt.sddl, domainSID)
+			if err != nil {
+				t.Fatalf("ParseSDDL(%s) error = %v", tt.sddl, err)
+			}
+			template := tt.template
+			template.Name = "Template"
+			template.DistinguishedName = "CN=Template,CN=Certificate Templates,CN=Public Key Services,CN=Services,CN=Configuration,DC=lab,DC=local"
+			template.SecurityDescriptor = sd
+
+			vulnerabilities := ldap.AnalyzeCertificateTemplate(&template, enrollmentServices, groupLinks, []*sid.SID{user, domainUsers})
+
+			techniques := []string{}
+			for _, vulnerability := range vulnerabilities {
+				if !reflect.DeepEqual(vulnerability.CertificateAuthorities, []string{"lab-CA"}) {
+					t.Errorf("Unexpected certificate authorities %v", vulnerability.CertificateAuthorities)
+				}
+				if vulnerability.Technique == ldap.ESC13 && vulnerability.LinkedGroup != "CN=Admins,DC=lab,DC=local" {
+					t.Errorf("Unexpected linked group %s", vulnerability.LinkedGroup)
+				}
+				techniques = append(techniques, vulnerability.Technique)
+			}
+			if !reflect.DeepEqual(techniques, tt.expected) {
+				t.Errorf("AnalyzeCertificateTemplate() = %v; want %v", techniques, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAnalyzeCertificateTemplateTrustees(t *testing.T) {
+	user, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	domainUsers, _ := sid.FromString("S-1-5-21-1-2-3-513")
+	domainSID, _ := sid.FromString("S-1-5-21-1-2-3")
+
+	// The rights granted to the user come after the rights denied to Domain Users, so they are not granted
+	sd, err := security.ParseSDDL("D:(A;;WD;;;DU)(D;;WO;;;DU)(A;;WO;;;S-1-5-21-1-2-3-1104)", domainSID)
+	if err != nil {
+		t.Fatalf("ParseSDDL() error = %v", err)
+	}
+	template := &objects.CertificateTemplate{SchemaVersion: 2, SecurityDescriptor: sd}
+
+	vulnerabilities := ldap.AnalyzeCertificateTemplate(template, nil, nil, []*sid.SID{user, domainUsers})
+	if len(vulnerabilities) != 1 || vulnerabilities[0].Technique != ldap.ESC4 {
+		t.Fatalf("Expected an ESC4 vulnerability, got %v", vulnerabilities)
+	}
+	expected := []string{"S-1-5-21-1-2-3-513"}
+	if !reflect.DeepEqual(vulnerabilities[0].TrusteeSIDs, expected) {
+		t.Errorf("TrusteeSIDs = %v; want %v", vulnerabilities[0].TrusteeSIDs, expected)
+	}
+}
@@ -0,0 +1,420 @@
+package ldap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// Attribute holding the DNS records of a dnsNode object
+const ATTRIBUTE_DNS_RECORD = "dnsRecord"
+
+// Attribute marking a dnsNode object whose records were deleted
+const ATTRIBUTE_DNS_TOMBSTONED = "dNSTombstoned"
+
+// Name of the dnsNode object holding the records of the zone itself, such as its SOA and NS records
+const DNS_ZONE_ROOT_NODE = "@"
+
+// Prefixes of the application partitions storing the AD-integrated DNS zones
+var dnsPartitionPrefixes = []string{"DC=DomainDnsZones,", "DC=ForestDnsZones,"}
+
+// DNSZone is an AD-integrated DNS zone stored in an application partition.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the dnsZone object.
+//   - Name: The name of the zone, such as "lab.local" or "_msdcs.lab.local".
+//   - Partition: The distinguished name of the application partition storing the zone.
+type DNSZone struct {
+	DistinguishedName string
+	Name              string
+	Partition         string
+}
+
+// DNSNode is a dnsNode object of a DNS zone, holding the records of a name.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the dnsNode object.
+//   - Name: The name of the node relative to the zone, such as "dc01", or DNS_ZONE_ROOT_NODE for the zone itself.
+//   - Tombstoned: True if the records of the node were deleted, leaving a DNS_TYPE_ZERO record.
+//   - Records: The parsed records of the node.
+type DNSNode struct {
+	DistinguishedName string
+	Name              string
+	Tombstoned        bool
+	Records           []*ldap_attributes.DNSRecord
+
+	// values holds the raw values of the records, needed to delete them
+	values []string
+}
+
+// dnsNodeAttributes are the attributes read for the dnsNode objects
+var dnsNodeAttributes = []string{"distinguishedName", "name", ATTRIBUTE_DNS_RECORD, ATTRIBUTE_DNS_TOMBSTONED}
+
+// GetAllDNSZones retrieves the AD-integrated DNS zones stored in the DomainDnsZones and ForestDnsZones
+// application partitions listed in the naming contexts of the server.
+//
+// Returns:
+//   - A slice of pointers to the DNSZone objects.
+//   - An error if the naming contexts cannot be retrieved or if an LDAP query fails.
+func (ldapSession *Session) GetAllDNSZones() ([]*DNSZone, error) {
+	namingContexts, err := ldapSession.GetAllNamingContexts()
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []*DNSZone{}
+	for _, namingContext := range namingContexts {
+		if !isDNSPartition(namingContext) {
+			continue
+		}
+
+		ldapResults, err := ldapSession.QuerySingleLevel("CN=MicrosoftDNS,"+namingContext, "(objectClass=dnsZone)", []string{"distinguishedName", "name"})
+		if err != nil {
+			return nil, fmt.Errorf("error querying LDAP: %w", err)
+		}
+		for _, entry := range ldapResults {
+			zones = append(zones, &DNSZone{
+				DistinguishedName: entry.GetAttributeValue("distinguishedName"),
+				Name:              entry.GetAttributeValue("name"),
+				Partition:         namingContext,
+			})
+		}
+	}
+
+	return zones, nil
+}
+
+// GetDNSZone retrieves an AD-integrated DNS zone by its name.
+//
+// Parameters:
+//   - zoneName: A string representing the name of the zone, such as "lab.local".
+//
+// Returns:
+//   - A pointer to the DNSZone.
+//   - An error if no zone has this name or if the zones cannot be retrieved.
+func (ldapSession *Session) GetDNSZone(zoneName string) (*DNSZone, error) {
+	zones, err := ldapSession.GetAllDNSZones()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zone := range zones {
+		if strings.EqualFold(zone.Name, strings.TrimSuffix(zoneName, ".")) {
+			return zone, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no DNS zone found with name %s", zoneName)
+}
+
+// GetDNSNodes retrieves the nodes of a DNS zone with their records, including the tombstoned nodes.
+//
+// Parameters:
+//   - zone: A pointer to the DNSZone.
+//
+// Returns:
+//   - A slice of pointers to the DNSNode objects of the zone.
+//   - An error if the LDAP query fails or if a record cannot be parsed.
+func (ldapSession *Session) GetDNSNodes(zone *DNSZone) ([]*DNSNode, error) {
+	ldapResults, err := ldapSession.QuerySingleLevel(zone.DistinguishedName, "(objectClass=dnsNode)", dnsNodeAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	nodes := []*DNSNode{}
+	for _, entry := range ldapResults {
+		node, err := newDNSNodeFromEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// GetDNSNode retrieves a node of a DNS zone with its records.
+//
+// Parameters:
+//   - zone: A pointer to the DNSZone.
+//   - name: A string representing the name of the node relative to the zone, such as "dc01".
+//
+// Returns:
+//   - A pointer to the DNSNode, or nil if the zone has no node of this name.
+//   - An error if the LDAP query fails or if a record cannot be parsed.
+func (ldapSession *Session) GetDNSNode(zone *DNSZone, name string) (*DNSNode, error) {
+	query := fmt.Sprintf("(&(objectClass=dnsNode)(name=%s))", goldapv3.EscapeFilter(name))
+
+	ldapResults, err := ldapSession.QuerySingleLevel(zone.DistinguishedName, query, dnsNodeAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, nil
+	}
+
+	return newDNSNodeFromEntry(ldapResults[0])
+}
+
+// GetNextDNSSerial computes the serial number of the next update of a zone, from the SOA record of its root node.
+//
+// Parameters:
+//   - zone: A pointer to the DNSZone.
+//
+// Returns:
+//   - The serial number of the SOA record incremented by one, or 1 if the zone has no SOA record.
+//   - An error if the root node cannot be retrieved.
+func (ldapSession *Session) GetNextDNSSerial(zone *DNSZone) (uint32, error) {
+	node, err := ldapSession.GetDNSNode(zone, DNS_ZONE_ROOT_NODE)
+	if err != nil {
+		return 0, err
+	}
+	if node != nil {
+		for _, record := range node.Records {
+			if soa, ok := record.Data.(*ldap_attributes.DNSRecordSOA); ok {
+				return soa.SerialNumber + 1, nil
+			}
+		}
+	}
+	return 1, nil
+}
+
+// AddDNSRecord adds a static record to a node of a DNS zone. The node is created if it does not exist, and
+// revived if it is tombstoned.
+//
+// Parameters:
+//   - zone: A pointer to the DNSZone.
+//   - name: A string representing the name of the node relative to the zone, such as "ws01".
+//   - recordType: The type of the record, one of the ldap_attributes.DNS_TYPE_* constants.
+//   - data: The data of the record, such as &ldap_attributes.DNSRecordAddress{IP: net.ParseIP("192.168.56.10")}.
+//   - TTL: The time to live of the record, in seconds.
+//
+// Returns:
+//   - An error if the record cannot be encoded or if the LDAP operation fails.
+//
+// Example:
+//
+//	zone, err := ldapSession.GetDNSZone("lab.local")
+//	if err == nil {
+//	    err = ldapSession.AddDNSRecord(zone, "ws01", ldap_attributes.DNS_TYPE_A, &ldap_attributes.DNSRecordAddress{IP: net.ParseIP("192.168.56.10")}, 180)
+//	}
+func (ldapSession *Session) AddDNSRecord(zone *DNSZone, name string, recordType uint16, data ldap_attributes.DNSRecordData, TTL uint32) error {
+	value, err := ldapSession.newDNSRecordValue(zone, recordType, data, TTL)
+	if err != nil {
+		return err
+	}
+
+	node, err := ldapSession.GetDNSNode(zone, name)
+	if err != nil {
+		return err
+	}
+
+	if node == nil {
+		addRequest := goldapv3.NewAddRequest(getDNSNodeDistinguishedName(zone, name), nil)
+		addRequest.Attribute("objectClass", []string{"top", "dnsNode"})
+		addRequest.Attribute(ATTRIBUTE_DNS_RECORD, []string{value})
+		addRequest.Attribute(ATTRIBUTE_DNS_TOMBSTONED, []string{"FALSE"})
+
+		if err := ldapSession.connection.Add(addRequest); err != nil {
+			return fmt.Errorf("error adding DNS node %s: %w", name, err)
+		}
+		return nil
+	}
+
+	modifyRequest := NewModifyRequest(node.DistinguishedName)
+	if node.Tombstoned {
+		modifyRequest.Replace(ATTRIBUTE_DNS_RECORD, []string{value})
+		modifyRequest.Replace(ATTRIBUTE_DNS_TOMBSTONED, []string{"FALSE"})
+	} else {
+		modifyRequest.Add(ATTRIBUTE_DNS_RECORD, []string{value})
+	}
+	if err := ldapSession.Modify(modifyRequest); err != nil {
+		return fmt.Errorf("error adding DNS record to %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// ReplaceDNSRecord replaces a record of a node of a DNS zone with a new record of the same type.
+//
+// Parameters:
+//   - zone: A pointer to the DNSZone.
+//   - name: A string representing the name of the node relative to the zone.
+//   - recordType: The type of the records, one of the ldap_attributes.DNS_TYPE_* constants.
+//   - oldData: The data of the record to replace, compared on their encoding.
+//   - newData: The data of the new record.
+//   - TTL: The time to live of the new record, in seconds.
+//
+// Returns:
+//   - An error if the node or the record is not found, if the new record cannot be encoded or if the LDAP modify
+//     operation fails.
+func (ldapSession *Session) ReplaceDNSRecord(zone *DNSZone, name string, recordType uint16, oldData ldap_attributes.DNSRecordData, newData ldap_attributes.DNSRecordData, TTL uint32) error {
+	node, err := ldapSession.getLiveDNSNode(zone, name)
+	if err != nil {
+		return err
+	}
+	oldValue, err := node.findRecordValue(recordType, oldData)
+	if err != nil {
+		return err
+	}
+	newValue, err := ldapSession.newDNSRecordValue(zone, recordType, newData, TTL)
+	if err != nil {
+		return err
+	}
+
+	modifyRequest := NewModifyRequest(node.DistinguishedName)
+	modifyRequest.Delete(ATTRIBUTE_DNS_RECORD, []string{oldValue})
+	modifyRequest.Add(ATTRIBUTE_DNS_RECORD, []string{newValue})
+	if err := ldapSession.Modify(modifyRequest); err != nil {
+		return fmt.Errorf("error replacing DNS record of %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// RemoveDNSRecord removes a record of a node of a DNS zone. The node is tombstoned if it was its last record, as
+// done by the DNS server.
+//
+// Parameters:
+//   - zone: A pointer to the DNSZone.
+//   - name: A string representing the name of the node relative to the zone.
+//   - recordType: The type of the record, one of the ldap_attributes.DNS_TYPE_* constants.
+//   - data: The data of the record to remove, compared on their encoding.
+//
+// Returns:
+//   - An error if the node or the record is not found, or if the LDAP modify operation fails.
+func (ldapSession *Session) RemoveDNSRecord(zone *DNSZone, name string, recordType uint16, data ldap_attributes.DNSRecordData) error {
+	node, err := ldapSession.getLiveDNSNode(zone, name)
+	if err != nil {
+		return err
+	}
+	value, err := node.findRecordValue(recordType, data)
+	if err != nil {
+		return err
+	}
+	if len(node.values) == 1 {
+		return ldapSession.TombstoneDNSNode(zone, name)
+	}
+
+	modifyRequest := NewModifyRequest(node.DistinguishedName)
+	modifyRequest.Delete(ATTRIBUTE_DNS_RECORD, []string{value})
+	if err := ldapSession.Modify(modifyRequest); err != nil {
+		return fmt.Errorf("error removing DNS record of %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// TombstoneDNSNode deletes all the records of a node of a DNS zone, replacing them with a DNS_TYPE_ZERO record
+// and setting dNSTombstoned, as done by the DNS server. The node object is then removed by the server once the
+// tombstone expires.
+//
+// Parameters:
+//   - zone: A pointer to the DNSZone.
+//   - name: A string representing the name of the node relative to the zone.
+//
+// Returns:
+//   - An error if the node is not found or if the LDAP modify operation fails.
+func (ldapSession *Session) TombstoneDNSNode(zone *DNSZone, name string) error {
+	node, err := ldapSession.getLiveDNSNode(zone, name)
+	if err != nil {
+		return err
+	}
+	serial, err := ldapSession.GetNextDNSSerial(zone)
+	if err != nil {
+		return err
+	}
+	value, err := ldap_attributes.NewDNSTombstoneRecord(serial).Marshal()
+	if err != nil {
+		return err
+	}
+
+	modifyRequest := NewModifyRequest(node.DistinguishedName)
+	modifyRequest.Replace(ATTRIBUTE_DNS_RECORD, []string{string(value)})
+	modifyRequest.Replace(ATTRIBUTE_DNS_TOMBSTONED, []string{"TRUE"})
+	if err := ldapSession.Modify(modifyRequest); err != nil {
+		return fmt.Errorf("error tombstoning DNS node %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// getLiveDNSNode retrieves a node of a zone which exists and is not tombstoned.
+func (ldapSession *Session) getLiveDNSNode(zone *DNSZone, name string) (*DNSNode, error) {
+	node, err := ldapSession.GetDNSNode(zone, name)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil || node.Tombstoned {
+		return nil, fmt.Errorf("no DNS node found with name %s in zone %s", name, zone.Name)
+	}
+	return node, nil
+}
+
+// newDNSRecordValue encodes a static record with the next serial number of a zone.
+func (ldapSession *Session) newDNSRecordValue(zone *DNSZone, recordType uint16, data ldap_attributes.DNSRecordData, TTL uint32) (string, error) {
+	serial, err := ldapSession.GetNextDNSSerial(zone)
+	if err != nil {
+		return "", err
+	}
+	value, err := ldap_attributes.NewDNSRecord(recordType, data, serial, TTL).Marshal()
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// findRecordValue returns the raw value of the record of a node having a type and data.
+func (node *DNSNode) findRecordValue(recordType uint16, data ldap_attributes.DNSRecordData) (string, error) {
+	wanted, err := data.Marshal()
+	if err != nil {
+		return "", err
+	}
+	for i, record := range node.Records {
+		if record.Type != recordType || record.Data == nil {
+			continue
+		}
+		if recordData, err := record.Data.Marshal(); err == nil && bytes.Equal(recordData, wanted) {
+			return node.values[i], nil
+		}
+	}
+	return "", fmt.Errorf("no %s record %s found in DNS node %s", ldap_attributes.DNSRecordTypeMap[recordType], data.String(), node.Name)
+}
+
+// newDNSNodeFromEntry parses a dnsNode entry read with dnsNodeAttributes.
+func newDNSNodeFromEntry(entry *goldapv3.Entry) (*DNSNode, error) {
+	node := &DNSNode{
+		DistinguishedName: entry.GetAttributeValue("distinguishedName"),
+		Name:              entry.GetAttributeValue("name"),
+		Tombstoned:        strings.EqualFold(entry.GetAttributeValue(ATTRIBUTE_DNS_TOMBSTONED), "TRUE"),
+		Records:           []*ldap_attributes.DNSRecord{},
+		values:            []string{},
+	}
+	for _, value := range entry.GetRawAttributeValues(ATTRIBUTE_DNS_RECORD) {
+		record, err := ldap_attributes.ParseDNSRecord(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s of %s: %w", ATTRIBUTE_DNS_RECORD, node.DistinguishedName, err)
+		}
+		node.Records = append(node.Records, record)
+		node.values = append(node.values, string(value))
+	}
+	return node, nil
+}
+
+// getDNSNodeDistinguishedName returns the distinguished name of a node of a zone.
+func getDNSNodeDistinguishedName(zone *DNSZone, name string) string {
+	return fmt.Sprintf("DC=%s,%s", goldapv3.EscapeDN(name), zone.DistinguishedName)
+}
+
+// isDNSPartition checks if a naming context is an application partition storing DNS zones.
+func isDNSPartition(namingContext string) bool {
+	for _, prefix := range dnsPartitionPrefixes {
+		if strings.HasPrefix(strings.ToUpper(namingContext), strings.ToUpper(prefix)) {
+			return true
+		}
+	}
+	return false
+}
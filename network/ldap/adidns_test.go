@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+func TestIsDNSPartition(t *testing.T) {
+	testCases := []struct {
+		namingContext string
+		expected      bool
+	}{
+		{namingContext: "DC=DomainDnsZones,DC=lab,DC=local", expected: true},
+		{namingContext: "DC=ForestDnsZones,DC=lab,DC=local", expected: true},
+		{namingContext: "dc=domaindnszones,dc=lab,dc=local", expected: true},
+		{namingContext: "DC=lab,DC=local", expected: false},
+		{namingContext: "CN=Configuration,DC=lab,DC=local", expected: false},
+	}
+
+	for _, tc := range testCases {
+		if result := isDNSPartition(tc.namingContext); result != tc.expected {
+			t.Errorf("isDNSPartition(%q) = %v, want %v", tc.namingContext, result, tc.expected)
+		}
+	}
+}
+
+func TestNewDNSNodeFromEntry(t *testing.T) {
+	recordA, _ := ldap_attributes.NewDNSRecord(ldap_attributes.DNS_TYPE_A, &ldap_attributes.DNSRecordAddress{IP: net.ParseIP("192.168.56.10")}, 5, 180).Marshal()
+	recordTXT, _ := ldap_attributes.NewDNSRecord(ldap_attributes.DNS_TYPE_TXT, &ldap_attributes.DNSRecordTXT{Strings: []string{"test"}}, 5, 180).Marshal()
+
+	entry := goldapv3.NewEntry("DC=ws01,DC=lab.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=lab,DC=local", nil)
+	entry.Attributes = []*goldapv3.EntryAttribute{
+		{Name: "distinguishedName", Values: []string{entry.DN}, ByteValues: [][]byte{[]byte(entry.DN)}},
+		{Name: "name", Values: []string{"ws01"}, ByteValues: [][]byte{[]byte("ws01")}},
+		{Name: ATTRIBUTE_DNS_RECORD, Values: []string{string(recordA), string(recordTXT)}, ByteValues: [][]byte{recordA, recordTXT}},
+		{Name: ATTRIBUTE_DNS_TOMBSTONED, Values: []string{"FALSE"}, ByteValues: [][]byte{[]byte("FALSE")}},
+	}
+
+	node, err := newDNSNodeFromEntry(entry)
+	if err != nil {
+		t.Fatalf("newDNSNodeFromEntry() error = %v", err)
+	}
+	if node.Name != "ws01" || node.Tombstoned || len(node.Records) != 2 {
+		t.Fatalf("newDNSNodeFromEntry() = name %q tombstoned %v with %d records", node.Name, node.Tombstoned, len(node.Records))
+	}
+
+	value, err := node.findRecordValue(ldap_attributes.DNS_TYPE_TXT, &ldap_attributes.DNSRecordTXT{Strings: []string{"test"}})
+	if err != nil {
+		t.Fatalf("findRecordValue() error = %v", err)
+	}
+	if value != string(recordTXT) {
+		t.Errorf("findRecordValue() = %x, want %x", value, recordTXT)
+	}
+	if _, err := node.findRecordValue(ldap_attributes.DNS_TYPE_A, &ldap_attributes.DNSRecordAddress{IP: net.ParseIP("192.168.56.11")}); err == nil {
+		t.Errorf("findRecordValue() error = nil, want an error for a missing record")
+	}
+}
+
+func TestGetDNSNodeDistinguishedName(t *testing.T) {
+	zone := &DNSZone{DistinguishedName: "DC=lab.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=lab,DC=local", Name: "lab.local"}
+
+	expected := "DC=ws01,DC=lab.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=lab,DC=local"
+	if result := getDNSNodeDistinguishedName(zone, "ws01"); result != expected {
+		t.Errorf("getDNSNodeDistinguishedName() = %q, want %q", result, expected)
+	}
+}
@@ -0,0 +1,116 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// GetAttributeSyntax reads the syntax of an attribute from the schema of the server. The syntaxes are cached for
+// the lifetime of the connection, and the cache may be used concurrently.
+//
+// Parameters:
+//   - attribute: The LDAP display name of the attribute, case insensitive. Options such as ";range=0-1499" are
+//     ignored.
+//
+// Returns:
+//   - The attributeSyntax OID of the attribute, one of the ldap_attributes.ATTRIBUTE_SYNTAX_* constants, or an
+//     empty string if the attribute is not in the schema.
+//   - An error if the LDAP query fails.
+func (ldapSession *Session) GetAttributeSyntax(attribute string) (string, error) {
+	if index := strings.Index(attribute, ";"); index != -1 {
+		attribute = attribute[:index]
+	}
+	key := strings.ToLower(attribute)
+	ldapSession.attributeSyntaxesMutex.Lock()
+	syntax, ok := ldapSession.attributeSyntaxes[key]
+	ldapSession.attributeSyntaxesMutex.Unlock()
+	if ok {
+		return syntax, nil
+	}
+
+	query := fmt.Sprintf("(&(objectClass=attributeSchema)(lDAPDisplayName=%s))", goldapv3.EscapeFilter(attribute))
+	ldapResults, err := ldapSession.QuerySingleLevel("schemaNamingContext", query, []string{"attributeSyntax"})
+	if err != nil {
+		return "", fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	if len(ldapResults) != 0 {
+		syntax = ldapResults[0].GetAttributeValue("attributeSyntax")
+	}
+	ldapSession.attributeSyntaxesMutex.Lock()
+	if ldapSession.attributeSyntaxes == nil {
+		ldapSession.attributeSyntaxes = make(map[string]string)
+	}
+	ldapSession.attributeSyntaxes[key] = syntax
+	ldapSession.attributeSyntaxesMutex.Unlock()
+
+	return syntax, nil
+}
+
+// DecodeAttribute decodes the values of an attribute of an entry into typed Go values.
+//
+// The decoder registered for the attribute name in ldap_attributes is used first. Otherwise, the syntax of the
+// attribute is read from the schema of the server and the decoder registered for this syntax is used. The values
+// of the attributes of unknown syntax are returned as strings.
+//
+// Parameters:
+//   - entry: A pointer to the LDAP entry.
+//   - attribute: The name of the attribute, case insensitive.
+//
+// Returns:
+//   - A slice of the decoded values, empty if the entry does not hold the attribute.
+//   - An error if the syntax cannot be read from the schema or if a value cannot be decoded.
+//
+// Example:
+//
+//	values, err := ldapSession.DecodeAttribute(entry, "objectSid")
+//	if err == nil && len(values) != 0 {
+//	    fmt.Println(values[0].(*sid.SID).String())
+//	}
+func (ldapSession *Session) DecodeAttribute(entry *Entry, attribute string) ([]any, error) {
+	for _, entryAttribute := range entry.Attributes {
+		if strings.EqualFold(entryAttribute.Name, attribute) {
+			return ldapSession.decodeEntryAttribute(entryAttribute)
+		}
+	}
+	return []any{}, nil
+}
+
+// DecodeEntry decodes the values of all the attributes of an entry into typed Go values, as done by DecodeAttribute.
+//
+// Parameters:
+//   - entry: A pointer to the LDAP entry.
+//
+// Returns:
+//   - A map of the decoded values, keyed by the attribute names of the entry.
+//   - An error if a syntax cannot be read from the schema or if a value cannot be decoded.
+func (ldapSession *Session) DecodeEntry(entry *Entry) (map[string][]any, error) {
+	decoded := make(map[string][]any)
+	for _, entryAttribute := range entry.Attributes {
+		values, err := ldapSession.decodeEntryAttribute(entryAttribute)
+		if err != nil {
+			return nil, err
+		}
+		decoded[entryAttribute.Name] = values
+	}
+	return decoded, nil
+}
+
+// decodeEntryAttribute decodes the values of an attribute with the decoder of its name, or of its syntax.
+func (ldapSession *Session) decodeEntryAttribute(entryAttribute *goldapv3.EntryAttribute) ([]any, error) {
+	decoder := ldap_attributes.GetAttributeDecoder(entryAttribute.Name)
+	if decoder == nil {
+		syntax, err := ldapSession.GetAttributeSyntax(entryAttribute.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading syntax of attribute %s: %w", entryAttribute.Name, err)
+		}
+		decoder = ldap_attributes.GetSyntaxDecoder(syntax)
+	}
+	if decoder == nil {
+		return ldap_attributes.DecodeAttributeValues(entryAttribute.Name, entryAttribute.ByteValues)
+	}
+	return ldap_attributes.DecodeValues(decoder, entryAttribute.Name, entryAttribute.ByteValues)
+}
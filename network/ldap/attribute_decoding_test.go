@@ -0,0 +1,51 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestDecodeEntry(t *testing.T) {
+	objectSID, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	entry := ldap.NewEntry("CN=alice,CN=Users,DC=lab,DC=local", map[string][]string{
+		"objectSid":              {string(objectSID.ToBytes())},
+		"isCriticalSystemObject": {"TRUE"},
+		"logonCount":             {"12"},
+		"cn":                     {"alice"},
+	})
+
+	// The syntaxes of the attributes without registered decoder are read from the schema cache
+	session := &Session{attributeSyntaxes: map[string]string{
+		"iscriticalsystemobject": ldap_attributes.ATTRIBUTE_SYNTAX_BOOLEAN,
+		"logoncount":             ldap_attributes.ATTRIBUTE_SYNTAX_INTEGER,
+		"cn":                     ldap_attributes.ATTRIBUTE_SYNTAX_UNICODE_STRING,
+	}}
+
+	decoded, err := session.DecodeEntry(entry)
+	if err != nil {
+		t.Fatalf("DecodeEntry error = %v", err)
+	}
+	if value, ok := decoded["objectSid"][0].(*sid.SID); !ok || !value.Equal(objectSID) {
+		t.Errorf("Unexpected objectSid %v", decoded["objectSid"])
+	}
+	if value, ok := decoded["isCriticalSystemObject"][0].(bool); !ok || !value {
+		t.Errorf("Unexpected isCriticalSystemObject %v", decoded["isCriticalSystemObject"])
+	}
+	if value, ok := decoded["logonCount"][0].(int64); !ok || value != 12 {
+		t.Errorf("Unexpected logonCount %v", decoded["logonCount"])
+	}
+	if value, ok := decoded["cn"][0].(string); !ok || value != "alice" {
+		t.Errorf("Unexpected cn %v", decoded["cn"])
+	}
+
+	values, err := session.DecodeAttribute(entry, "LOGONCOUNT")
+	if err != nil || len(values) != 1 {
+		t.Errorf("DecodeAttribute(LOGONCOUNT) = %v, %v", values, err)
+	}
+	if values, err := session.DecodeAttribute(entry, "mail"); err != nil || len(values) != 0 {
+		t.Errorf("DecodeAttribute(mail) = %v, %v", values, err)
+	}
+}
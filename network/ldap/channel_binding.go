@@ -0,0 +1,75 @@
+package ldap
+
+import (
+	"encoding/binary"
+
+	"github.com/go-ldap/ldap/v3/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana/chksumtype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+
+	krb5gssapi "github.com/jcmturner/gokrb5/v8/gssapi"
+)
+
+// channelBindingGSSAPIClient is a GSSAPI client adding channel bindings to the authenticator checksum of its AP-REQ.
+// Src: https://www.rfc-editor.org/rfc/rfc4121#section-4.1.1
+type channelBindingGSSAPIClient struct {
+	*gssapi.Client
+	channelBindingsHash []byte
+}
+
+// InitSecContextWithOptions initiates the security context with an AP-REQ holding the channel bindings, and
+// processes the AP-REP of the server.
+func (c *channelBindingGSSAPIClient) InitSecContextWithOptions(target string, input []byte, APOptions []int) ([]byte, bool, error) {
+	if input != nil {
+		return c.Client.InitSecContextWithOptions(target, input, APOptions)
+	}
+
+	// The underlying client keeps the session key of the service ticket to process the AP-REP
+	if _, _, err := c.Client.InitSecContextWithOptions(target, nil, APOptions); err != nil {
+		return nil, false, err
+	}
+	ticket, sessionKey, err := c.Client.Client.GetServiceTicket(target)
+	if err != nil {
+		return nil, false, err
+	}
+
+	flags := []int{krb5gssapi.ContextFlagInteg, krb5gssapi.ContextFlagConf, krb5gssapi.ContextFlagMutual}
+	token, err := spnego.NewKRB5TokenAPREQ(c.Client.Client, ticket, sessionKey, flags, APOptions)
+	if err != nil {
+		return nil, false, err
+	}
+
+	authenticator, err := types.NewAuthenticator(c.Client.Client.Credentials.Domain(), c.Client.Client.Credentials.CName())
+	if err != nil {
+		return nil, false, err
+	}
+	// Length of the bindings hash, bindings hash and context flags
+	checksum := binary.LittleEndian.AppendUint32(nil, 16)
+	checksum = append(checksum, c.channelBindingsHash...)
+	contextFlags := uint32(0)
+	for _, flag := range flags {
+		contextFlags |= uint32(flag)
+	}
+	checksum = binary.LittleEndian.AppendUint32(checksum, contextFlags)
+	authenticator.Cksum = types.Checksum{CksumType: chksumtype.GSSAPI, Checksum: checksum}
+
+	if token.APReq, err = messages.NewAPReq(ticket, sessionKey, authenticator); err != nil {
+		return nil, false, err
+	}
+	for _, option := range APOptions {
+		types.SetFlag(&token.APReq.APOptions, option)
+	}
+
+	output, err := token.Marshal()
+	if err != nil {
+		return nil, false, err
+	}
+	return output, true, nil
+}
+
+// InitSecContext initiates the security context with an AP-REQ holding the channel bindings.
+func (c *channelBindingGSSAPIClient) InitSecContext(target string, input []byte) ([]byte, bool, error) {
+	return c.InitSecContextWithOptions(target, input, []int{})
+}
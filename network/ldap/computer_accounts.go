@@ -0,0 +1,253 @@
+package ldap
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/network/samr"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// Length of the passwords generated for the computer accounts
+const COMPUTER_PASSWORD_LENGTH = 32
+
+// Characters of the passwords generated for the computer accounts
+const computerPasswordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#%&()*+,-.:;<=>?@[]^_{}~"
+
+// ComputerAccount is a computer account created by AddComputer.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the account.
+//   - SAMAccountName: The sAMAccountName of the account, ending with "$".
+//   - DNSHostName: The dNSHostName of the account, such as "ws01.lab.local".
+//   - Password: The password of the account.
+type ComputerAccount struct {
+	DistinguishedName string
+	SAMAccountName    string
+	DNSHostName       string
+	Password          string
+}
+
+// AddComputer creates an enabled computer account, with its dNSHostName and the HOST and RestrictedKrbHost
+// service principal names set as done when joining a computer to the domain.
+//
+// Setting the password of an account requires an encrypted connection. Over LDAPS or StartTLS, the account is
+// created with an LDAP add operation. Otherwise, it is created through the SAM Remote Protocol of the domain
+// controller, in the default container of the computers, authenticating with the NTLM credentials of the session,
+// before its dNSHostName and service principal names are set over LDAP.
+//
+// Accounts created by users which are not administrators consume the ms-DS-MachineAccountQuota of the domain.
+//
+// Parameters:
+//   - name: A string representing the name of the computer, such as "WS01". A trailing "$" is ignored.
+//   - password: A string representing the password of the account. If empty, a random password of
+//     COMPUTER_PASSWORD_LENGTH characters is generated.
+//   - containerDN: A string representing the distinguished name of the container of the account, only used over
+//     LDAPS or StartTLS. If empty, the account is created in CN=Computers.
+//
+// Returns:
+//   - A pointer to the created ComputerAccount, holding its password.
+//   - An error if the account cannot be created.
+//
+// Example:
+//
+//	computer, err := ldapSession.AddComputer("WS01", "", "")
+//	if err == nil {
+//	    fmt.Printf("Created %s with password %s\n", computer.SAMAccountName, computer.Password)
+//	}
+func (ldapSession *Session) AddComputer(name string, password string, containerDN string) (*ComputerAccount, error) {
+	defaultNamingContext, err := ldapSession.resolveSearchBase("defaultNamingContext")
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSuffix(name, "$")
+	if len(password) == 0 {
+		if password, err = GenerateComputerPassword(); err != nil {
+			return nil, err
+		}
+	}
+	if len(containerDN) == 0 {
+		containerDN = "CN=Computers," + defaultNamingContext
+	}
+
+	computer := &ComputerAccount{
+		DistinguishedName: fmt.Sprintf("CN=%s,%s", goldapv3.EscapeDN(name), containerDN),
+		SAMAccountName:    name + "$",
+		DNSHostName:       strings.ToLower(name + "." + GetDomainFromDistinguishedName(defaultNamingContext)),
+		Password:          password,
+	}
+
+	if ldapSession.useldaps || ldapSession.usestarttls {
+		if err := ldapSession.addComputerWithLDAP(computer); err != nil {
+			return nil, err
+		}
+		return computer, nil
+	}
+
+	if err := ldapSession.addComputerWithSAMR(computer); err != nil {
+		return nil, err
+	}
+	return computer, nil
+}
+
+// DeleteAccount deletes a user or computer account.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the account.
+//
+// Returns:
+//   - An error if the LDAP delete operation fails.
+func (ldapSession *Session) DeleteAccount(distinguishedName string) error {
+	err := ldapSession.connection.Del(goldapv3.NewDelRequest(distinguishedName, nil))
+	if err != nil {
+		return fmt.Errorf("error deleting %s: %w", distinguishedName, err)
+	}
+
+	return nil
+}
+
+// DisableAccount disables a user or computer account, keeping the other flags of its userAccountControl.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the account.
+//
+// Returns:
+//   - An error if the userAccountControl attribute cannot be read or written.
+func (ldapSession *Session) DisableAccount(distinguishedName string) error {
+	return ldapSession.setUserAccountControlFlag(distinguishedName, ldap_attributes.UAF_ACCOUNT_DISABLED, true)
+}
+
+// EnableAccount enables a user or computer account, keeping the other flags of its userAccountControl.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the account.
+//
+// Returns:
+//   - An error if the userAccountControl attribute cannot be read or written.
+func (ldapSession *Session) EnableAccount(distinguishedName string) error {
+	return ldapSession.setUserAccountControlFlag(distinguishedName, ldap_attributes.UAF_ACCOUNT_DISABLED, false)
+}
+
+// GenerateComputerPassword generates a random password of COMPUTER_PASSWORD_LENGTH characters.
+//
+// Returns:
+//   - A string containing the password.
+//   - An error if the random generator fails.
+func GenerateComputerPassword() (string, error) {
+	password := make([]byte, COMPUTER_PASSWORD_LENGTH)
+	for i := range password {
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(computerPasswordCharset))))
+		if err != nil {
+			return "", fmt.Errorf("error generating password: %w", err)
+		}
+		password[i] = computerPasswordCharset[index.Int64()]
+	}
+	return string(password), nil
+}
+
+// EncodeUnicodePwd encodes a password as the value written to the unicodePwd attribute, the UTF-16LE encoding of
+// the password surrounded by double quotes.
+//
+// Parameters:
+//   - password: A string representing the password.
+//
+// Returns:
+//   - A string containing the encoded value.
+func EncodeUnicodePwd(password string) string {
+	return string(utf16.EncodeUTF16LE("\"" + password + "\""))
+}
+
+// addComputerWithLDAP creates a computer account with an LDAP add operation.
+func (ldapSession *Session) addComputerWithLDAP(computer *ComputerAccount) error {
+	addRequest := goldapv3.NewAddRequest(computer.DistinguishedName, nil)
+	addRequest.Attribute("objectClass", []string{"top", "person", "organizationalPerson", "user", "computer"})
+	addRequest.Attribute("sAMAccountName", []string{computer.SAMAccountName})
+	addRequest.Attribute("userAccountControl", []string{strconv.Itoa(int(ldap_attributes.UAF_WORKSTATION_TRUST_ACCOUNT))})
+	addRequest.Attribute("dNSHostName", []string{computer.DNSHostName})
+	addRequest.Attribute("servicePrincipalName", computer.getServicePrincipalNames())
+	addRequest.Attribute("unicodePwd", []string{EncodeUnicodePwd(computer.Password)})
+
+	err := ldapSession.connection.Add(addRequest)
+	if err != nil {
+		return fmt.Errorf("error adding computer %s: %w", computer.SAMAccountName, err)
+	}
+
+	return nil
+}
+
+// addComputerWithSAMR creates a computer account through the SAM Remote Protocol, then sets its dNSHostName and
+// service principal names over LDAP, as allowed to its creator by the validated writes.
+func (ldapSession *Session) addComputerWithSAMR(computer *ComputerAccount) error {
+	samrClient, err := samr.NewClient(ldapSession.host, ldapSession.credentials)
+	if err != nil {
+		return err
+	}
+	defer samrClient.Close()
+
+	if err := samrClient.OpenDomain(""); err != nil {
+		return err
+	}
+	if _, err := samrClient.CreateComputer(computer.SAMAccountName, computer.Password); err != nil {
+		return fmt.Errorf("error adding computer %s: %w", computer.SAMAccountName, err)
+	}
+
+	query := fmt.Sprintf("(sAMAccountName=%s)", goldapv3.EscapeFilter(computer.SAMAccountName))
+	ldapResults, err := ldapSession.QueryWholeSubtree("defaultNamingContext", query, []string{"distinguishedName"})
+	if err != nil {
+		return fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return fmt.Errorf("computer %s was created but is not found in LDAP", computer.SAMAccountName)
+	}
+	computer.DistinguishedName = ldapResults[0].GetAttributeValue("distinguishedName")
+
+	modifyRequest := goldapv3.NewModifyRequest(computer.DistinguishedName, nil)
+	modifyRequest.Replace("dNSHostName", []string{computer.DNSHostName})
+	modifyRequest.Replace("servicePrincipalName", computer.getServicePrincipalNames())
+	if err := ldapSession.connection.Modify(modifyRequest); err != nil {
+		return fmt.Errorf("computer %s was created but its dNSHostName and servicePrincipalName cannot be set: %w", computer.SAMAccountName, err)
+	}
+
+	return nil
+}
+
+// setUserAccountControlFlag sets or clears a flag of the userAccountControl attribute of an account.
+func (ldapSession *Session) setUserAccountControlFlag(distinguishedName string, flag ldap_attributes.UserAccountControl, set bool) error {
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", []string{"userAccountControl"})
+	if err != nil {
+		return fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return fmt.Errorf("no object found with distinguished name %s", distinguishedName)
+	}
+
+	value, err := strconv.ParseUint(ldapResults[0].GetAttributeValue("userAccountControl"), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid userAccountControl of %s: %w", distinguishedName, err)
+	}
+	userAccountControl := ldap_attributes.UserAccountControl(value)
+	if set {
+		userAccountControl |= flag
+	} else {
+		userAccountControl &^= flag
+	}
+
+	return ldapSession.OverwriteAttributeValue(distinguishedName, "userAccountControl", strconv.FormatUint(uint64(userAccountControl), 10))
+}
+
+// getServicePrincipalNames returns the service principal names registered when a computer joins the domain.
+func (computer *ComputerAccount) getServicePrincipalNames() []string {
+	name := strings.TrimSuffix(computer.SAMAccountName, "$")
+	return []string{
+		"HOST/" + name,
+		"HOST/" + computer.DNSHostName,
+		"RestrictedKrbHost/" + name,
+		"RestrictedKrbHost/" + computer.DNSHostName,
+	}
+}
@@ -0,0 +1,45 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+)
+
+func TestEncodeUnicodePwd(t *testing.T) {
+	encoded := EncodeUnicodePwd("Sup3rS3cr3t!")
+	if encoded != string(utf16.EncodeUTF16LE(`"Sup3rS3cr3t!"`)) {
+		t.Errorf("Unexpected encoding %x", encoded)
+	}
+}
+
+func TestGenerateComputerPassword(t *testing.T) {
+	password, err := GenerateComputerPassword()
+	if err != nil {
+		t.Fatalf("GenerateComputerPassword error = %v", err)
+	}
+	if len(password) != COMPUTER_PASSWORD_LENGTH {
+		t.Errorf("Expected %d characters, got %d", COMPUTER_PASSWORD_LENGTH, len(password))
+	}
+	for _, character := range password {
+		if !strings.ContainsRune(computerPasswordCharset, character) {
+			t.Errorf("Unexpected character %q", character)
+		}
+	}
+
+	other, _ := GenerateComputerPassword()
+	if other == password {
+		t.Errorf("Expected different passwords")
+	}
+}
+
+func TestComputerAccountServicePrincipalNames(t *testing.T) {
+	computer := &ComputerAccount{SAMAccountName: "WS01$", DNSHostName: "ws01.lab.local"}
+
+	expected := []string{"HOST/WS01", "HOST/ws01.lab.local", "RestrictedKrbHost/WS01", "RestrictedKrbHost/ws01.lab.local"}
+	servicePrincipalNames := computer.getServicePrincipalNames()
+	if strings.Join(servicePrincipalNames, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, servicePrincipalNames)
+	}
+}
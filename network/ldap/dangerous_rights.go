@@ -0,0 +1,188 @@
+package ldap
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/schema"
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Dangerous rights flagged by the analysis of security descriptors
+const (
+	DANGEROUS_RIGHT_GENERIC_ALL             = "GenericAll"
+	DANGEROUS_RIGHT_GENERIC_WRITE           = "GenericWrite"
+	DANGEROUS_RIGHT_WRITE_DACL              = "WriteDacl"
+	DANGEROUS_RIGHT_WRITE_OWNER             = "WriteOwner"
+	DANGEROUS_RIGHT_ALL_EXTENDED_RIGHTS     = "AllExtendedRights"
+	DANGEROUS_RIGHT_FORCE_CHANGE_PASSWORD   = "ForceChangePassword"
+	DANGEROUS_RIGHT_GET_CHANGES             = "GetChanges"
+	DANGEROUS_RIGHT_GET_CHANGES_ALL         = "GetChangesAll"
+	DANGEROUS_RIGHT_ADD_MEMBER              = "AddMember"
+	DANGEROUS_RIGHT_ADD_SELF                = "AddSelf"
+	DANGEROUS_RIGHT_ADD_KEY_CREDENTIAL_LINK = "AddKeyCredentialLink"
+	DANGEROUS_RIGHT_WRITE_SPN               = "WriteSPN"
+	DANGEROUS_RIGHT_ADD_ALLOWED_TO_ACT      = "AddAllowedToAct"
+)
+
+// DangerousRight represents a dangerous right granted to a principal on an object.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the object.
+//   - Right: The dangerous right, one of the DANGEROUS_RIGHT_* constants.
+//   - TrusteeSIDs: The SIDs of the principal or of its groups through which the right is granted, by the ACEs of
+//     the DACL or by the ownership of the object, given the deny ACEs of all the SIDs of the principal.
+type DangerousRight struct {
+	DistinguishedName string
+	Right             string
+	TrusteeSIDs       []string
+}
+
+// FindDangerousRights retrieves the security descriptors of the objects matching a query and flags the dangerous
+// rights granted on them to a principal.
+//
+// Parameters:
+//   - searchBase: A string representing the base DN of the search. The special values accepted by Query are
+//     also accepted.
+//   - query: A string representing the LDAP search filter, such as "(objectClass=*)".
+//   - SIDs: A slice of strings containing the SID of the principal and the SIDs of all the groups it is a
+//     transitive member of, including well-known groups such as Everyone and Authenticated Users.
+//
+// Returns:
+//   - A slice of DangerousRight, sorted by distinguished name.
+//   - An error if a SID is invalid, if the LDAP query fails or if a security descriptor cannot be parsed.
+func (ldapSession *Session) FindDangerousRights(searchBase string, query string, SIDs []string) ([]*DangerousRight, error) {
+	principalSIDs := make([]*sid.SID, 0, len(SIDs))
+	for _, value := range SIDs {
+		principalSID, err := sid.FromString(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SID %s: %w", value, err)
+		}
+		principalSIDs = append(principalSIDs, principalSID)
+	}
+
+	securityDescriptors, err := ldapSession.GetSecurityDescriptors(searchBase, query)
+	if err != nil {
+		return nil, err
+	}
+
+	distinguishedNames := make([]string, 0, len(securityDescriptors))
+	for distinguishedName := range securityDescriptors {
+		distinguishedNames = append(distinguishedNames, distinguishedName)
+	}
+	sort.Strings(distinguishedNames)
+
+	dangerousRights := []*DangerousRight{}
+	for _, distinguishedName := range distinguishedNames {
+		dangerousRights = append(dangerousRights, AnalyzeSecurityDescriptor(distinguishedName, securityDescriptors[distinguishedName], principalSIDs)...)
+	}
+
+	return dangerousRights, nil
+}
+
+// AnalyzeSecurityDescriptor computes the effective rights of a principal on an object and flags the dangerous ones.
+//
+// Rights implied by a broader right are not flagged: GenericAll hides all the other rights, GenericWrite hides
+// the writes to specific attributes and AllExtendedRights hides the specific extended rights.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the object.
+//   - securityDescriptor: A pointer to the security descriptor of the object.
+//   - SIDs: A slice of SIDs of the principal and of all the groups it is a transitive member of.
+//
+// Returns:
+//   - A slice of DangerousRight granted to the principal on the object, empty if none is granted.
+func AnalyzeSecurityDescriptor(distinguishedName string, securityDescriptor *security.SecurityDescriptor, SIDs []*sid.SID) []*DangerousRight {
+	dangerousRights := []*DangerousRight{}
+
+	for _, right := range getDangerousRights(securityDescriptor.GetEffectiveRights(SIDs)) {
+		trusteeSIDs := []string{}
+		for _, trusteeSID := range SIDs {
+			if hasDangerousRight(securityDescriptor.GetTrusteeRights(SIDs, trusteeSID), right) {
+				trusteeSIDs = append(trusteeSIDs, trusteeSID.String())
+			}
+		}
+
+		dangerousRights = append(dangerousRights, &DangerousRight{
+			DistinguishedName: distinguishedName,
+			Right:             right,
+			TrusteeSIDs:       trusteeSIDs,
+		})
+	}
+
+	return dangerousRights
+}
+
+// getDangerousRights returns the dangerous rights of effective rights, without the rights implied by broader ones.
+func getDangerousRights(rights *security.EffectiveRights) []string {
+	if hasDangerousRight(rights, DANGEROUS_RIGHT_GENERIC_ALL) {
+		return []string{DANGEROUS_RIGHT_GENERIC_ALL}
+	}
+
+	dangerousRights := []string{}
+	for _, right := range []string{DANGEROUS_RIGHT_WRITE_DACL, DANGEROUS_RIGHT_WRITE_OWNER, DANGEROUS_RIGHT_GENERIC_WRITE, DANGEROUS_RIGHT_ALL_EXTENDED_RIGHTS} {
+		if hasDangerousRight(rights, right) {
+			dangerousRights = append(dangerousRights, right)
+		}
+	}
+
+	if !hasDangerousRight(rights, DANGEROUS_RIGHT_GENERIC_WRITE) {
+		for _, right := range []string{DANGEROUS_RIGHT_ADD_MEMBER, DANGEROUS_RIGHT_ADD_SELF, DANGEROUS_RIGHT_ADD_KEY_CREDENTIAL_LINK, DANGEROUS_RIGHT_WRITE_SPN, DANGEROUS_RIGHT_ADD_ALLOWED_TO_ACT} {
+			if hasDangerousRight(rights, right) {
+				dangerousRights = append(dangerousRights, right)
+			}
+		}
+	}
+
+	if !hasDangerousRight(rights, DANGEROUS_RIGHT_ALL_EXTENDED_RIGHTS) {
+		for _, right := range []string{DANGEROUS_RIGHT_FORCE_CHANGE_PASSWORD, DANGEROUS_RIGHT_GET_CHANGES, DANGEROUS_RIGHT_GET_CHANGES_ALL} {
+			if hasDangerousRight(rights, right) {
+				dangerousRights = append(dangerousRights, right)
+			}
+		}
+	}
+
+	return dangerousRights
+}
+
+// hasDangerousRight checks if effective rights grant a dangerous right.
+func hasDangerousRight(rights *security.EffectiveRights, right string) bool {
+	switch right {
+	case DANGEROUS_RIGHT_GENERIC_ALL:
+		return rights.HasRight(security.ADS_RIGHT_GENERIC_ALL, nil)
+	case DANGEROUS_RIGHT_GENERIC_WRITE:
+		return rights.HasRight(security.ADS_RIGHT_DS_WRITE_PROP, nil)
+	case DANGEROUS_RIGHT_WRITE_DACL:
+		return rights.HasRight(security.WRITE_DAC, nil)
+	case DANGEROUS_RIGHT_WRITE_OWNER:
+		return rights.HasRight(security.WRITE_OWNER, nil)
+	case DANGEROUS_RIGHT_ALL_EXTENDED_RIGHTS:
+		return rights.HasRight(security.ADS_RIGHT_DS_CONTROL_ACCESS, nil)
+	case DANGEROUS_RIGHT_FORCE_CHANGE_PASSWORD:
+		return rights.HasRight(security.ADS_RIGHT_DS_CONTROL_ACCESS, schemaGUID(schema.EXTENDED_RIGHT_USER_FORCE_CHANGE_PASSWORD))
+	case DANGEROUS_RIGHT_GET_CHANGES:
+		return rights.HasRight(security.ADS_RIGHT_DS_CONTROL_ACCESS, schemaGUID(schema.EXTENDED_RIGHT_DS_REPLICATION_GET_CHANGES))
+	case DANGEROUS_RIGHT_GET_CHANGES_ALL:
+		return rights.HasRight(security.ADS_RIGHT_DS_CONTROL_ACCESS, schemaGUID(schema.EXTENDED_RIGHT_DS_REPLICATION_GET_CHANGES_ALL))
+	case DANGEROUS_RIGHT_ADD_MEMBER:
+		return rights.HasPropertyRight(security.ADS_RIGHT_DS_WRITE_PROP, schemaGUID(schema.SCHEMA_ATTRIBUTE_MEMBER))
+	case DANGEROUS_RIGHT_ADD_SELF:
+		return rights.HasRight(security.ADS_RIGHT_DS_SELF, schemaGUID(schema.EXTENDED_RIGHT_SELF_MEMBERSHIP))
+	case DANGEROUS_RIGHT_ADD_KEY_CREDENTIAL_LINK:
+		return rights.HasPropertyRight(security.ADS_RIGHT_DS_WRITE_PROP, schemaGUID(schema.SCHEMA_ATTRIBUTE_MS_DS_KEY_CREDENTIAL_LINK))
+	case DANGEROUS_RIGHT_WRITE_SPN:
+		return rights.HasPropertyRight(security.ADS_RIGHT_DS_WRITE_PROP, schemaGUID(schema.SCHEMA_ATTRIBUTE_SERVICE_PRINCIPAL_NAME)) ||
+			rights.HasRight(security.ADS_RIGHT_DS_SELF, schemaGUID(schema.EXTENDED_RIGHT_VALIDATED_SPN))
+	case DANGEROUS_RIGHT_ADD_ALLOWED_TO_ACT:
+		return rights.HasPropertyRight(security.ADS_RIGHT_DS_WRITE_PROP, schemaGUID(schema.SCHEMA_ATTRIBUTE_MS_DS_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY))
+	}
+	return false
+}
+
+// schemaGUID parses a GUID of the schema package.
+func schemaGUID(value string) *guid.GUID {
+	g, _ := guid.FromString(value)
+	return g
+}
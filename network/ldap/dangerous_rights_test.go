@@ -0,0 +1,97 @@
+package ldap_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+func TestAnalyzeSecurityDescriptor(t *testing.T) {
+	user, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	group, _ := sid.FromString("S-1-5-21-1-2-3-1105")
+	domainSID, _ := sid.FromString("S-1-5-21-1-2-3")
+
+	tests := []struct {
+		name     string
+		sddl     string
+		expected []string
+	}{
+		{"No rights", "O:DAD:(A;;GA;;;DA)(A;;RP;;;AU)", []string{}},
+		{"Generic all hides the other rights", "D:(A;;GA;;;S-1-5-21-1-2-3-1105)(A;;CR;;;S-1-5-21-1-2-3-1104)", []string{ldap.DANGEROUS_RIGHT_GENERIC_ALL}},
+		{"Owner", "O:S-1-5-21-1-2-3-1104D:", []string{ldap.DANGEROUS_RIGHT_WRITE_DACL}},
+		{"Write owner and generic write", "D:(A;;WOWPSW;;;S-1-5-21-1-2-3-1104)", []string{ldap.DANGEROUS_RIGHT_WRITE_OWNER, ldap.DANGEROUS_RIGHT_GENERIC_WRITE}},
+		{"DCSync", "D:(OA;;CR;1131f6aa-9c07-11d1-f79f-00c04fc2dcd2;;S-1-5-21-1-2-3-1105)(OA;;CR;1131f6ad-9c07-11d1-f79f-00c04fc2dcd2;;S-1-5-21-1-2-3-1105)",
+			[]string{ldap.DANGEROUS_RIGHT_GET_CHANGES, ldap.DANGEROUS_RIGHT_GET_CHANGES_ALL}},
+		{"All extended rights", "D:(A;;CR;;;S-1-5-21-1-2-3-1104)", []string{ldap.DANGEROUS_RIGHT_ALL_EXTENDED_RIGHTS}},
+		{"Force change password", "D:(OA;;CR;00299570-246d-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1104)", []string{ldap.DANGEROUS_RIGHT_FORCE_CHANGE_PASSWORD}},
+		{"Group membership", "D:(OA;;WP;bc0ac240-79a9-11d0-9020-00c04fc2d4cf;;S-1-5-21-1-2-3-1104)(OA;;SW;bf9679c0-0de6-11d0-a285-00aa003049e2;;S-1-5-21-1-2-3-1104)",
+			[]string{ldap.DANGEROUS_RIGHT_ADD_MEMBER, ldap.DANGEROUS_RIGHT_ADD_SELF}},
+		{"Shadow credentials, SPN and RBCD", "D:(OA;;WP;5b47d60f-6090-40b2-9f37-2a4de88f3063;;S-1-5-21-1-2-3-1104)(OA;;SW;f3a64788-5306-11d1-a9c5-0000f80367c1;;S-1-5-21-1-2-3-1104)(OA;;WP;4c164200-20c0-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1104)",
+			[]string{ldap.DANGEROUS_RIGHT_ADD_KEY_CREDENTIAL_LINK, ldap.DANGEROUS_RIGHT_WRITE_SPN, ldap.DANGEROUS_RIGHT_ADD_ALLOWED_TO_ACT}},
+		{"Denied right", "D:(OD;;CR;00299570-246d-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1105)(OA;;CR;00299570-246d-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1104)", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd, err := security.ParseSDDL(tt.sddl, domainSID)
+			if err != nil {
+				t.Fatalf("ParseSDDL(%s) error = %v", tt.sddl, err)
+			}
+			findings := ldap.AnalyzeSecurityDescriptor("CN=target,DC=example,DC=com", sd, []*sid.SID{user, group})
+
+			rights := []string{}
+			for _, finding := range findings {
+				if finding.DistinguishedName != "CN=target,DC=example,DC=com" {
+					t.Errorf("Unexpected distinguished name %s", finding.DistinguishedName)
+				}
+				rights = append(rights, finding.Right)
+			}
+			if !reflect.DeepEqual(rights, tt.expected) {
+				t.Errorf("AnalyzeSecurityDescriptor() = %v; want %v", rights, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSecurityDescriptorTrustees(t *testing.T) {
+	user, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	group, _ := sid.FromString("S-1-5-21-1-2-3-1105")
+
+	sd, _ := security.ParseSDDL("O:S-1-5-21-1-2-3-1104D:(A;;WD;;;S-1-5-21-1-2-3-1105)", nil)
+	findings := ldap.AnalyzeSecurityDescriptor("CN=target,DC=example,DC=com", sd, []*sid.SID{user, group})
+	if len(findings) != 1 || findings[0].Right != ldap.DANGEROUS_RIGHT_WRITE_DACL {
+		t.Fatalf("Expected a WriteDacl finding, got %v", findings)
+	}
+	expected := []string{"S-1-5-21-1-2-3-1104", "S-1-5-21-1-2-3-1105"}
+	if !reflect.DeepEqual(findings[0].TrusteeSIDs, expected) {
+		t.Errorf("TrusteeSIDs = %v; want %v", findings[0].TrusteeSIDs, expected)
+	}
+
+	// The right granted to the user comes after the right denied to another group of the user, so it is only
+	// granted through the first group
+	otherGroup, _ := sid.FromString("S-1-5-21-1-2-3-1106")
+	sd, _ = security.ParseSDDL("O:S-1-5-21-1-2-3-500D:(A;;WD;;;S-1-5-21-1-2-3-1105)(D;;WD;;;S-1-5-21-1-2-3-1106)(A;;WD;;;S-1-5-21-1-2-3-1104)", nil)
+	findings = ldap.AnalyzeSecurityDescriptor("CN=target,DC=example,DC=com", sd, []*sid.SID{user, group, otherGroup})
+	if len(findings) != 1 || findings[0].Right != ldap.DANGEROUS_RIGHT_WRITE_DACL {
+		t.Fatalf("Expected a WriteDacl finding, got %v", findings)
+	}
+	expected = []string{"S-1-5-21-1-2-3-1105"}
+	if !reflect.DeepEqual(findings[0].TrusteeSIDs, expected) {
+		t.Errorf("TrusteeSIDs = %v; want %v", findings[0].TrusteeSIDs, expected)
+	}
+}
+
+func TestNewSDFlagsControl(t *testing.T) {
+	control := ldap.NewSDFlagsControl(security.OWNER_SECURITY_INFORMATION | security.GROUP_SECURITY_INFORMATION | security.DACL_SECURITY_INFORMATION)
+	if control.GetControlType() != ldap.LDAP_SERVER_SD_FLAGS_OID {
+		t.Errorf("GetControlType() = %s; want %s", control.GetControlType(), ldap.LDAP_SERVER_SD_FLAGS_OID)
+	}
+	packet := control.Encode()
+	value := packet.Children[len(packet.Children)-1].Data.Bytes()
+	if !reflect.DeepEqual(value, []byte{0x30, 0x03, 0x02, 0x01, 0x07}) {
+		t.Errorf("Control value = %x; want 3003020107", value)
+	}
+}
@@ -0,0 +1,217 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Attribute holding the security descriptor of the principals allowed to delegate to an account
+const ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY = "msDS-AllowedToActOnBehalfOfOtherIdentity"
+
+// Access mask granted by Windows to the principals allowed to delegate to an account
+const rbcdAccessMask = security.ADS_RIGHT_GENERIC_ALL
+
+// Owner of the security descriptors written to msDS-AllowedToActOnBehalfOfOtherIdentity
+const rbcdOwnerSID = sid.SID_BUILTIN_ADMINISTRATORS
+
+// GetAllowedToActOnBehalfOfOtherIdentity retrieves the SIDs of the principals allowed to delegate to an account
+// through resource-based constrained delegation.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the account.
+//
+// Returns:
+//   - A slice of strings containing the SIDs of the principals allowed to delegate to the account. The slice is
+//     empty if the msDS-AllowedToActOnBehalfOfOtherIdentity attribute is not set.
+//   - An error if the LDAP query fails or if the security descriptor cannot be parsed.
+func (ldapSession *Session) GetAllowedToActOnBehalfOfOtherIdentity(distinguishedName string) ([]string, error) {
+	attributes := []string{ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY}
+
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("no object found with distinguished name %s", distinguishedName)
+	}
+
+	securityDescriptor := ldapResults[0].GetRawAttributeValue(ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY)
+	if len(securityDescriptor) == 0 {
+		return []string{}, nil
+	}
+
+	return ParseAllowedToActOnBehalfOfOtherIdentity(securityDescriptor)
+}
+
+// SetAllowedToActOnBehalfOfOtherIdentity overwrites the principals allowed to delegate to an account
+// through resource-based constrained delegation.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the account.
+//   - SIDs: A slice of strings containing the SIDs of the principals allowed to delegate to the account.
+//     If the slice is empty, the msDS-AllowedToActOnBehalfOfOtherIdentity attribute is cleared.
+//
+// Returns:
+//   - An error if the security descriptor cannot be built or if the LDAP modify operation fails.
+func (ldapSession *Session) SetAllowedToActOnBehalfOfOtherIdentity(distinguishedName string, SIDs []string) error {
+	if len(SIDs) == 0 {
+		return ldapSession.FlushAttribute(distinguishedName, ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY)
+	}
+
+	securityDescriptor, err := BuildAllowedToActOnBehalfOfOtherIdentity(SIDs)
+	if err != nil {
+		return err
+	}
+
+	return ldapSession.OverwriteAttributeValue(distinguishedName, ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY, string(securityDescriptor))
+}
+
+// AddAllowedToActOnBehalfOfOtherIdentity allows a principal to delegate to an account through resource-based
+// constrained delegation, keeping the principals already allowed.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the account.
+//   - SID: A string representing the SID of the principal to allow.
+//
+// Returns:
+//   - An error if the current principals cannot be read or if the LDAP modify operation fails.
+func (ldapSession *Session) AddAllowedToActOnBehalfOfOtherIdentity(distinguishedName string, SID string) error {
+	SIDs, err := ldapSession.GetAllowedToActOnBehalfOfOtherIdentity(distinguishedName)
+	if err != nil {
+		return err
+	}
+
+	for _, allowedSID := range SIDs {
+		if strings.EqualFold(allowedSID, SID) {
+			return nil
+		}
+	}
+
+	return ldapSession.SetAllowedToActOnBehalfOfOtherIdentity(distinguishedName, append(SIDs, SID))
+}
+
+// RemoveAllowedToActOnBehalfOfOtherIdentity removes a principal from the principals allowed to delegate to an
+// account through resource-based constrained delegation.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the account.
+//   - SID: A string representing the SID of the principal to remove.
+//
+// Returns:
+//   - An error if the current principals cannot be read or if the LDAP modify operation fails.
+func (ldapSession *Session) RemoveAllowedToActOnBehalfOfOtherIdentity(distinguishedName string, SID string) error {
+	SIDs, err := ldapSession.GetAllowedToActOnBehalfOfOtherIdentity(distinguishedName)
+	if err != nil {
+		return err
+	}
+
+	keptSIDs := []string{}
+	for _, allowedSID := range SIDs {
+		if !strings.EqualFold(allowedSID, SID) {
+			keptSIDs = append(keptSIDs, allowedSID)
+		}
+	}
+	if len(keptSIDs) == len(SIDs) {
+		return nil
+	}
+
+	return ldapSession.SetAllowedToActOnBehalfOfOtherIdentity(distinguishedName, keptSIDs)
+}
+
+// GetResourceBasedConstrainedDelegations retrieves all the accounts having the msDS-AllowedToActOnBehalfOfOtherIdentity
+// attribute set, with the principals allowed to delegate to them.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the accounts and the values are slices of strings
+//     containing the SIDs of the principals allowed to delegate to them.
+//   - An error if the LDAP query fails or if a security descriptor cannot be parsed.
+func (ldapSession *Session) GetResourceBasedConstrainedDelegations() (map[string][]string, error) {
+	attributes := []string{"distinguishedName", ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY}
+
+	query := fmt.Sprintf("(%s=*)", ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY)
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("", query, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	delegationsMap := make(map[string][]string)
+	for _, entry := range ldapResults {
+		SIDs, err := ParseAllowedToActOnBehalfOfOtherIdentity(entry.GetRawAttributeValue(ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s of %s: %w", ATTRIBUTE_ALLOWED_TO_ACT_ON_BEHALF_OF_OTHER_IDENTITY, entry.DN, err)
+		}
+		delegationsMap[entry.GetAttributeValue("distinguishedName")] = SIDs
+	}
+
+	return delegationsMap, nil
+}
+
+// BuildAllowedToActOnBehalfOfOtherIdentity builds the self-relative security descriptor stored in the
+// msDS-AllowedToActOnBehalfOfOtherIdentity attribute.
+//
+// Parameters:
+//   - SIDs: A slice of strings containing the SIDs of the principals allowed to delegate to the account.
+//
+// Returns:
+//   - A byte slice containing the security descriptor, owned by BUILTIN\Administrators and holding one
+//     ACCESS_ALLOWED_ACE per principal in its DACL.
+//   - An error if one of the SIDs is not valid.
+func BuildAllowedToActOnBehalfOfOtherIdentity(SIDs []string) ([]byte, error) {
+	securityDescriptor := security.NewSecurityDescriptor()
+
+	owner, err := sid.FromString(rbcdOwnerSID)
+	if err != nil {
+		return nil, err
+	}
+	securityDescriptor.Owner = owner
+
+	dacl := security.NewACL()
+	for _, SID := range SIDs {
+		trustee, err := sid.FromString(SID)
+		if err != nil {
+			return nil, err
+		}
+		dacl.AddACE(&security.ACE{Type: security.ACCESS_ALLOWED_ACE_TYPE, Mask: rbcdAccessMask, SID: trustee})
+	}
+	securityDescriptor.SetDACL(dacl)
+
+	return securityDescriptor.ToBytes()
+}
+
+// ParseAllowedToActOnBehalfOfOtherIdentity parses the self-relative security descriptor stored in the
+// msDS-AllowedToActOnBehalfOfOtherIdentity attribute.
+//
+// Parameters:
+//   - securityDescriptor: A byte slice containing the security descriptor.
+//
+// Returns:
+//   - A slice of strings containing the SIDs of the ACCESS_ALLOWED_ACE entries of the DACL.
+//   - An error if the security descriptor is malformed.
+func ParseAllowedToActOnBehalfOfOtherIdentity(securityDescriptor []byte) ([]string, error) {
+	return parseAllowedSIDs(securityDescriptor)
+}
+
+// parseAllowedSIDs returns the SIDs of the ACCESS_ALLOWED_ACE entries of the DACL of a self-relative security
+// descriptor, as stored in the attributes granting a right to a list of principals.
+func parseAllowedSIDs(securityDescriptor []byte) ([]string, error) {
+	parsedSecurityDescriptor := &security.SecurityDescriptor{}
+	if err := parsedSecurityDescriptor.FromBytes(securityDescriptor); err != nil {
+		return nil, err
+	}
+
+	SIDs := []string{}
+	if parsedSecurityDescriptor.DACL == nil {
+		return SIDs, nil
+	}
+	for _, ace := range parsedSecurityDescriptor.DACL.ACEs {
+		if ace.Type == security.ACCESS_ALLOWED_ACE_TYPE {
+			SIDs = append(SIDs, ace.SID.String())
+		}
+	}
+
+	return SIDs, nil
+}
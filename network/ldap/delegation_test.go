@@ -0,0 +1,84 @@
+package ldap_test
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap"
+)
+
+func TestBuildAllowedToActOnBehalfOfOtherIdentity(t *testing.T) {
+	expected := "010004801400000000000000000000002400000001020000000000052000000020020000" +
+		"02002c000100000000002400ff010f00010500000000000515000000010000000200000003000000" + "50040000"
+
+	securityDescriptor, err := ldap.BuildAllowedToActOnBehalfOfOtherIdentity([]string{"S-1-5-21-1-2-3-1104"})
+	if err != nil {
+		t.Fatalf("BuildAllowedToActOnBehalfOfOtherIdentity() error = %v", err)
+	}
+	if hex.EncodeToString(securityDescriptor) != expected {
+		t.Errorf("BuildAllowedToActOnBehalfOfOtherIdentity() = %x; want %s", securityDescriptor, expected)
+	}
+
+	if _, err := ldap.BuildAllowedToActOnBehalfOfOtherIdentity([]string{"not a SID"}); err == nil {
+		t.Errorf("BuildAllowedToActOnBehalfOfOtherIdentity() with an invalid SID should fail")
+	}
+}
+
+func TestParseAllowedToActOnBehalfOfOtherIdentity(t *testing.T) {
+	tests := []struct {
+		name string
+		SIDs []string
+	}{
+		{"No principal", []string{}},
+		{"One principal", []string{"S-1-5-21-2000478354-688448229-1599263404-1104"}},
+		{"Several principals", []string{"S-1-5-21-2000478354-688448229-1599263404-1104", "S-1-5-21-2000478354-688448229-1599263404-1105", "S-1-5-32-544"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			securityDescriptor, err := ldap.BuildAllowedToActOnBehalfOfOtherIdentity(tt.SIDs)
+			if err != nil {
+				t.Fatalf("BuildAllowedToActOnBehalfOfOtherIdentity() error = %v", err)
+			}
+			result, err := ldap.ParseAllowedToActOnBehalfOfOtherIdentity(securityDescriptor)
+			if err != nil {
+				t.Fatalf("ParseAllowedToActOnBehalfOfOtherIdentity() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.SIDs) {
+				t.Errorf("ParseAllowedToActOnBehalfOfOtherIdentity() = %v; want %v", result, tt.SIDs)
+			}
+		})
+	}
+
+	if _, err := ldap.ParseAllowedToActOnBehalfOfOtherIdentity([]byte{0x01, 0x00, 0x04, 0x80}); err == nil {
+		t.Errorf("ParseAllowedToActOnBehalfOfOtherIdentity() with a truncated security descriptor should fail")
+	}
+}
+
+func TestConvertSIDToBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		SID      string
+		expected string
+	}{
+		{"BUILTIN Administrators", "S-1-5-32-544", "01020000000000052000000020020000"},
+		{"Everyone", "S-1-1-0", "010100000000000100000000"},
+		{"Domain user", "S-1-5-21-1-2-3-1104", "010500000000000515000000010000000200000003000000" + "50040000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ldap.ConvertSIDToBytes(tt.SID)
+			if err != nil {
+				t.Fatalf("ConvertSIDToBytes(%s) error = %v", tt.SID, err)
+			}
+			if hex.EncodeToString(result) != tt.expected {
+				t.Errorf("ConvertSIDToBytes(%s) = %x; want %s", tt.SID, result, tt.expected)
+			}
+			if ldap.ParseSIDFromBytes(result) != tt.SID {
+				t.Errorf("ParseSIDFromBytes(ConvertSIDToBytes(%s)) = %s", tt.SID, ldap.ParseSIDFromBytes(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/crypto/nt"
+)
+
+// Attribute holding the current and previous passwords of a group managed service account, only returned over an
+// encrypted connection to the principals allowed by msDS-GroupMSAMembership
+const ATTRIBUTE_MANAGED_PASSWORD = "msDS-ManagedPassword"
+
+// Attribute holding the security descriptor of the principals allowed to retrieve the password of a group managed
+// service account
+const ATTRIBUTE_GROUP_MSA_MEMBERSHIP = "msDS-GroupMSAMembership"
+
+// Size of the fixed part of a MSDS-MANAGEDPASSWORD_BLOB
+const managedPasswordBlobHeaderSize = 16
+
+// ManagedPassword is the decoded MSDS-MANAGEDPASSWORD_BLOB of a group managed service account.
+// Src: [MS-ADTS] 2.2.19 MSDS-MANAGEDPASSWORD_BLOB
+//
+// Fields:
+//   - CurrentPassword: The current password, as the UTF-16LE bytes of the random password without the null
+//     terminator.
+//   - PreviousPassword: The previous password in the same encoding, nil if the password was never changed.
+//   - QueryPasswordInterval: The time after which the password should be retrieved again.
+//   - UnchangedPasswordInterval: The time during which the password will not change.
+type ManagedPassword struct {
+	CurrentPassword           []byte
+	PreviousPassword          []byte
+	QueryPasswordInterval     time.Duration
+	UnchangedPasswordInterval time.Duration
+}
+
+// GetCurrentNTHash computes the NT hash of the current password.
+//
+// Returns:
+//   - A string containing the hexadecimal NT hash.
+func (managedPassword *ManagedPassword) GetCurrentNTHash() string {
+	ntHash := nt.NTHashFromUTF16LE(managedPassword.CurrentPassword)
+	return hex.EncodeToString(ntHash[:])
+}
+
+// GetPreviousNTHash computes the NT hash of the previous password.
+//
+// Returns:
+//   - A string containing the hexadecimal NT hash, empty if there is no previous password.
+func (managedPassword *ManagedPassword) GetPreviousNTHash() string {
+	if managedPassword.PreviousPassword == nil {
+		return ""
+	}
+	ntHash := nt.NTHashFromUTF16LE(managedPassword.PreviousPassword)
+	return hex.EncodeToString(ntHash[:])
+}
+
+// GetManagedPassword retrieves and decodes the password of a group managed service account.
+//
+// The password is only returned to the principals allowed by the msDS-GroupMSAMembership attribute of the
+// account, over LDAPS or a session protected with SASL sealing.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the group managed service account.
+//
+// Returns:
+//   - A pointer to the decoded ManagedPassword.
+//   - An error if the LDAP query fails, if the password is not readable or if the blob is malformed.
+func (ldapSession *Session) GetManagedPassword(distinguishedName string) (*ManagedPassword, error) {
+	attributes := []string{ATTRIBUTE_MANAGED_PASSWORD}
+
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("no object found with distinguished name %s", distinguishedName)
+	}
+
+	blob := ldapResults[0].GetRawAttributeValue(ATTRIBUTE_MANAGED_PASSWORD)
+	if len(blob) == 0 {
+		return nil, fmt.Errorf("%s of %s is not readable", ATTRIBUTE_MANAGED_PASSWORD, distinguishedName)
+	}
+
+	return ParseManagedPasswordBlob(blob)
+}
+
+// GetAllManagedPasswords retrieves and decodes the passwords of all the group managed service accounts readable
+// by the current session.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the accounts and the values are pointers to their
+//     decoded ManagedPassword. The accounts whose password is not readable are not included.
+//   - An error if the LDAP query fails or if a blob is malformed.
+func (ldapSession *Session) GetAllManagedPasswords() (map[string]*ManagedPassword, error) {
+	attributes := []string{"distinguishedName", ATTRIBUTE_MANAGED_PASSWORD}
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("", "(objectClass=msDS-GroupManagedServiceAccount)", attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	managedPasswordsMap := make(map[string]*ManagedPassword)
+	for _, entry := range ldapResults {
+		blob := entry.GetRawAttributeValue(ATTRIBUTE_MANAGED_PASSWORD)
+		if len(blob) == 0 {
+			continue
+		}
+		managedPassword, err := ParseManagedPasswordBlob(blob)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s of %s: %w", ATTRIBUTE_MANAGED_PASSWORD, entry.DN, err)
+		}
+		managedPasswordsMap[entry.GetAttributeValue("distinguishedName")] = managedPassword
+	}
+
+	return managedPasswordsMap, nil
+}
+
+// GetGroupMSAMembership retrieves the SIDs of the principals allowed to retrieve the password of a group managed
+// service account.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the group managed service account.
+//
+// Returns:
+//   - A slice of strings containing the SIDs of the principals allowed to retrieve the password. The slice is
+//     empty if the msDS-GroupMSAMembership attribute is not set.
+//   - An error if the LDAP query fails or if the security descriptor cannot be parsed.
+func (ldapSession *Session) GetGroupMSAMembership(distinguishedName string) ([]string, error) {
+	attributes := []string{ATTRIBUTE_GROUP_MSA_MEMBERSHIP}
+
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("no object found with distinguished name %s", distinguishedName)
+	}
+
+	securityDescriptor := ldapResults[0].GetRawAttributeValue(ATTRIBUTE_GROUP_MSA_MEMBERSHIP)
+	if len(securityDescriptor) == 0 {
+		return []string{}, nil
+	}
+
+	return parseAllowedSIDs(securityDescriptor)
+}
+
+// GetAllGroupMSAMemberships retrieves all the group managed service accounts, with the principals allowed to
+// retrieve their password.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the accounts and the values are slices of strings
+//     containing the SIDs of the principals allowed to retrieve their password.
+//   - An error if the LDAP query fails or if a security descriptor cannot be parsed.
+func (ldapSession *Session) GetAllGroupMSAMemberships() (map[string][]string, error) {
+	attributes := []string{"distinguishedName", ATTRIBUTE_GROUP_MSA_MEMBERSHIP}
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("", "(objectClass=msDS-GroupManagedServiceAccount)", attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	membershipsMap := make(map[string][]string)
+	for _, entry := range ldapResults {
+		SIDs := []string{}
+		if securityDescriptor := entry.GetRawAttributeValue(ATTRIBUTE_GROUP_MSA_MEMBERSHIP); len(securityDescriptor) != 0 {
+			SIDs, err = parseAllowedSIDs(securityDescriptor)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s of %s: %w", ATTRIBUTE_GROUP_MSA_MEMBERSHIP, entry.DN, err)
+			}
+		}
+		membershipsMap[entry.GetAttributeValue("distinguishedName")] = SIDs
+	}
+
+	return membershipsMap, nil
+}
+
+// ParseManagedPasswordBlob parses the MSDS-MANAGEDPASSWORD_BLOB stored in the msDS-ManagedPassword attribute.
+//
+// Parameters:
+//   - blob: A byte slice containing the MSDS-MANAGEDPASSWORD_BLOB.
+//
+// Returns:
+//   - A pointer to the decoded ManagedPassword.
+//   - An error if the version is not 1 or if an offset is outside of the blob.
+func ParseManagedPasswordBlob(blob []byte) (*ManagedPassword, error) {
+	if len(blob) < managedPasswordBlobHeaderSize {
+		return nil, fmt.Errorf("MSDS-MANAGEDPASSWORD_BLOB must be at least %d bytes, got %d", managedPasswordBlobHeaderSize, len(blob))
+	}
+	if version := binary.LittleEndian.Uint16(blob[0:2]); version != 1 {
+		return nil, fmt.Errorf("unsupported MSDS-MANAGEDPASSWORD_BLOB version %d", version)
+	}
+	length := int(binary.LittleEndian.Uint32(blob[4:8]))
+	if length < managedPasswordBlobHeaderSize || length > len(blob) {
+		return nil, fmt.Errorf("invalid MSDS-MANAGEDPASSWORD_BLOB length %d", length)
+	}
+	blob = blob[:length]
+
+	currentPasswordOffset := int(binary.LittleEndian.Uint16(blob[8:10]))
+	previousPasswordOffset := int(binary.LittleEndian.Uint16(blob[10:12]))
+	queryPasswordIntervalOffset := int(binary.LittleEndian.Uint16(blob[12:14]))
+	unchangedPasswordIntervalOffset := int(binary.LittleEndian.Uint16(blob[14:16]))
+
+	managedPassword := &ManagedPassword{}
+	var err error
+
+	// The passwords are followed by the previous password, if any, and then by the query password interval
+	currentPasswordEnd := queryPasswordIntervalOffset
+	if previousPasswordOffset != 0 {
+		currentPasswordEnd = previousPasswordOffset
+	}
+	managedPassword.CurrentPassword, err = readManagedPassword(blob, currentPasswordOffset, currentPasswordEnd)
+	if err != nil {
+		return nil, fmt.Errorf("error reading current password: %w", err)
+	}
+	if previousPasswordOffset != 0 {
+		managedPassword.PreviousPassword, err = readManagedPassword(blob, previousPasswordOffset, queryPasswordIntervalOffset)
+		if err != nil {
+			return nil, fmt.Errorf("error reading previous password: %w", err)
+		}
+	}
+
+	for _, interval := range []struct {
+		offset int
+		value  *time.Duration
+	}{
+		{queryPasswordIntervalOffset, &managedPassword.QueryPasswordInterval},
+		{unchangedPasswordIntervalOffset, &managedPassword.UnchangedPasswordInterval},
+	} {
+		if interval.offset < managedPasswordBlobHeaderSize || interval.offset+8 > len(blob) {
+			return nil, fmt.Errorf("password interval offset %d is outside of the blob", interval.offset)
+		}
+		*interval.value = time.Duration(binary.LittleEndian.Uint64(blob[interval.offset:interval.offset+8])) * 100
+	}
+
+	return managedPassword, nil
+}
+
+// readManagedPassword reads a null-terminated UTF-16LE password of a MSDS-MANAGEDPASSWORD_BLOB, which ends
+// with its terminator at the offset of the next field. The length is taken from the offsets rather than from
+// the first null character, as the random password may contain 0x0000.
+func readManagedPassword(blob []byte, offset int, nextOffset int) ([]byte, error) {
+	if offset < managedPasswordBlobHeaderSize || nextOffset > len(blob) || offset+2 > nextOffset {
+		return nil, fmt.Errorf("password at offset %d is outside of the blob", offset)
+	}
+	end := nextOffset - 2
+	if blob[end] != 0 || blob[end+1] != 0 {
+		return nil, fmt.Errorf("password at offset %d is not null-terminated", offset)
+	}
+	return append([]byte{}, blob[offset:end]...), nil
+}
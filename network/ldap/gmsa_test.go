@@ -0,0 +1,76 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/crypto/nt"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+)
+
+// buildManagedPasswordBlob builds a MSDS-MANAGEDPASSWORD_BLOB laid out as returned by Windows.
+func buildManagedPasswordBlob(currentPassword []byte, previousPassword []byte) []byte {
+	blob := make([]byte, managedPasswordBlobHeaderSize)
+	binary.LittleEndian.PutUint16(blob[0:2], 1)
+
+	binary.LittleEndian.PutUint16(blob[8:10], uint16(len(blob)))
+	blob = append(append(blob, currentPassword...), 0, 0)
+	if previousPassword != nil {
+		binary.LittleEndian.PutUint16(blob[10:12], uint16(len(blob)))
+		blob = append(append(blob, previousPassword...), 0, 0)
+	}
+	binary.LittleEndian.PutUint16(blob[12:14], uint16(len(blob)))
+	blob = binary.LittleEndian.AppendUint64(blob, uint64(30*24*time.Hour/100))
+	binary.LittleEndian.PutUint16(blob[14:16], uint16(len(blob)))
+	blob = binary.LittleEndian.AppendUint64(blob, uint64(29*24*time.Hour/100))
+
+	binary.LittleEndian.PutUint32(blob[4:8], uint32(len(blob)))
+	return blob
+}
+
+func TestParseManagedPasswordBlob(t *testing.T) {
+	// Random passwords are not valid UTF-16, such as this unpaired surrogate
+	currentPassword := make([]byte, 256)
+	for i := range currentPassword {
+		currentPassword[i] = byte(i%251 + 1)
+	}
+	currentPassword[0], currentPassword[1] = 0x00, 0xd8
+	// and they may contain a null character
+	currentPassword[100], currentPassword[101] = 0x00, 0x00
+	previousPassword := utf16.EncodeUTF16LE("Previous!")
+
+	managedPassword, err := ParseManagedPasswordBlob(buildManagedPasswordBlob(currentPassword, previousPassword))
+	if err != nil {
+		t.Fatalf("ParseManagedPasswordBlob error = %v", err)
+	}
+	if string(managedPassword.CurrentPassword) != string(currentPassword) {
+		t.Errorf("Unexpected current password")
+	}
+	if managedPassword.GetPreviousNTHash() != nt.NTHashHex("Previous!") {
+		t.Errorf("Expected previous NT hash %s, got %s", nt.NTHashHex("Previous!"), managedPassword.GetPreviousNTHash())
+	}
+	if managedPassword.QueryPasswordInterval != 30*24*time.Hour || managedPassword.UnchangedPasswordInterval != 29*24*time.Hour {
+		t.Errorf("Unexpected intervals %v and %v", managedPassword.QueryPasswordInterval, managedPassword.UnchangedPasswordInterval)
+	}
+
+	managedPassword, err = ParseManagedPasswordBlob(buildManagedPasswordBlob(utf16.EncodeUTF16LE("Current!"), nil))
+	if err != nil {
+		t.Fatalf("ParseManagedPasswordBlob error = %v", err)
+	}
+	if managedPassword.GetCurrentNTHash() != nt.NTHashHex("Current!") {
+		t.Errorf("Expected current NT hash %s, got %s", nt.NTHashHex("Current!"), managedPassword.GetCurrentNTHash())
+	}
+	if managedPassword.PreviousPassword != nil || managedPassword.GetPreviousNTHash() != "" {
+		t.Errorf("Expected no previous password")
+	}
+
+	if _, err := ParseManagedPasswordBlob([]byte{2, 0, 0, 0}); err == nil {
+		t.Errorf("Expected an error for a truncated blob")
+	}
+	truncated := buildManagedPasswordBlob(utf16.EncodeUTF16LE("Current!"), nil)
+	binary.LittleEndian.PutUint16(truncated[14:16], uint16(len(truncated)))
+	if _, err := ParseManagedPasswordBlob(truncated); err == nil {
+		t.Errorf("Expected an error for an interval outside of the blob")
+	}
+}
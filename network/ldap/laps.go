@@ -0,0 +1,159 @@
+package ldap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// Attributes of the legacy Microsoft LAPS, holding the clear text password of the local administrator of a
+// computer and its expiration time
+const (
+	ATTRIBUTE_LEGACY_LAPS_PASSWORD        = "ms-Mcs-AdmPwd"
+	ATTRIBUTE_LEGACY_LAPS_EXPIRATION_TIME = "ms-Mcs-AdmPwdExpirationTime"
+)
+
+// Attributes of Windows LAPS, holding the clear text password of the managed account of a computer as a JSON
+// object and its expiration time. The passwords encrypted in msLAPS-EncryptedPassword are not decrypted.
+const (
+	ATTRIBUTE_LAPS_PASSWORD        = "msLAPS-Password"
+	ATTRIBUTE_LAPS_EXPIRATION_TIME = "msLAPS-PasswordExpirationTime"
+)
+
+// LAPSPassword is the password of the local account of a computer managed by LAPS.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the computer.
+//   - Account: The name of the managed account, empty for the legacy LAPS which only manages the built-in
+//     administrator.
+//   - Password: The clear text password.
+//   - UpdateTime: The time the password was set, zero for the legacy LAPS.
+//   - ExpirationTime: The time the password will be changed, zero if unknown.
+type LAPSPassword struct {
+	DistinguishedName string
+	Account           string
+	Password          string
+	UpdateTime        time.Time
+	ExpirationTime    time.Time
+}
+
+// lapsAttributes are the attributes read to decode the passwords of both the legacy LAPS and Windows LAPS
+var lapsAttributes = []string{
+	"distinguishedName",
+	ATTRIBUTE_LEGACY_LAPS_PASSWORD, ATTRIBUTE_LEGACY_LAPS_EXPIRATION_TIME,
+	ATTRIBUTE_LAPS_PASSWORD, ATTRIBUTE_LAPS_EXPIRATION_TIME,
+}
+
+// GetLAPSPasswords retrieves the LAPS passwords of a computer readable by the current session.
+//
+// The legacy LAPS and Windows LAPS may both be deployed on a computer, each managing its own password, so both
+// are returned when readable.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the computer.
+//
+// Returns:
+//   - A slice of pointers to the LAPSPassword of the computer, the Windows LAPS password first. The slice is
+//     empty if no password is readable.
+//   - An error if the LDAP query fails or if the Windows LAPS password cannot be parsed.
+func (ldapSession *Session) GetLAPSPasswords(distinguishedName string) ([]*LAPSPassword, error) {
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", lapsAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("no object found with distinguished name %s", distinguishedName)
+	}
+
+	return parseLAPSEntry(ldapResults[0])
+}
+
+// GetAllLAPSPasswords retrieves the LAPS passwords of all the computers readable by the current session.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the computers and the values are slices of pointers
+//     to their LAPSPassword. The computers whose passwords are not readable are not included.
+//   - An error if the LDAP query fails or if a Windows LAPS password cannot be parsed.
+func (ldapSession *Session) GetAllLAPSPasswords() (map[string][]*LAPSPassword, error) {
+	query := fmt.Sprintf("(&(objectClass=computer)(|(%s=*)(%s=*)))", ATTRIBUTE_LEGACY_LAPS_PASSWORD, ATTRIBUTE_LAPS_PASSWORD)
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("", query, lapsAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	lapsPasswordsMap := make(map[string][]*LAPSPassword)
+	for _, entry := range ldapResults {
+		lapsPasswords, err := parseLAPSEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		if len(lapsPasswords) != 0 {
+			lapsPasswordsMap[entry.GetAttributeValue("distinguishedName")] = lapsPasswords
+		}
+	}
+
+	return lapsPasswordsMap, nil
+}
+
+// ParseLAPSPassword parses the JSON object stored in the msLAPS-Password attribute by Windows LAPS, such as
+// {"n":"Administrator","t":"1d8161b41c41cde","p":"password"}, where t is the hexadecimal FILETIME the password
+// was set.
+//
+// Parameters:
+//   - value: A string containing the JSON object.
+//
+// Returns:
+//   - A pointer to the LAPSPassword, without its distinguished name and expiration time.
+//   - An error if the value is not a valid JSON object or if the update time is malformed.
+func ParseLAPSPassword(value string) (*LAPSPassword, error) {
+	var decoded struct {
+		Account    string `json:"n"`
+		UpdateTime string `json:"t"`
+		Password   string `json:"p"`
+	}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", ATTRIBUTE_LAPS_PASSWORD, err)
+	}
+
+	lapsPassword := &LAPSPassword{Account: decoded.Account, Password: decoded.Password}
+	if len(decoded.UpdateTime) != 0 {
+		fileTime, err := strconv.ParseUint(decoded.UpdateTime, 16, 63)
+		if err != nil {
+			return nil, fmt.Errorf("invalid update time %s: %w", decoded.UpdateTime, err)
+		}
+		lapsPassword.UpdateTime, err = ldap_attributes.ParseFileTime([]byte(strconv.FormatUint(fileTime, 10)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid update time %s: %w", decoded.UpdateTime, err)
+		}
+	}
+
+	return lapsPassword, nil
+}
+
+// parseLAPSEntry decodes the Windows LAPS and legacy LAPS passwords of an entry read with lapsAttributes.
+func parseLAPSEntry(entry *goldapv3.Entry) ([]*LAPSPassword, error) {
+	distinguishedName := entry.GetAttributeValue("distinguishedName")
+
+	lapsPasswords := []*LAPSPassword{}
+	if value := entry.GetAttributeValue(ATTRIBUTE_LAPS_PASSWORD); len(value) != 0 {
+		lapsPassword, err := ParseLAPSPassword(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s of %s: %w", ATTRIBUTE_LAPS_PASSWORD, distinguishedName, err)
+		}
+		lapsPassword.DistinguishedName = distinguishedName
+		lapsPassword.ExpirationTime, _ = ldap_attributes.ParseFileTime(entry.GetRawAttributeValue(ATTRIBUTE_LAPS_EXPIRATION_TIME))
+		lapsPasswords = append(lapsPasswords, lapsPassword)
+	}
+	if value := entry.GetAttributeValue(ATTRIBUTE_LEGACY_LAPS_PASSWORD); len(value) != 0 {
+		lapsPassword := &LAPSPassword{DistinguishedName: distinguishedName, Password: value}
+		lapsPassword.ExpirationTime, _ = ldap_attributes.ParseFileTime(entry.GetRawAttributeValue(ATTRIBUTE_LEGACY_LAPS_EXPIRATION_TIME))
+		lapsPasswords = append(lapsPasswords, lapsPassword)
+	}
+
+	return lapsPasswords, nil
+}
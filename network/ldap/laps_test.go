@@ -0,0 +1,53 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+func TestParseLAPSEntry(t *testing.T) {
+	entry := goldapv3.NewEntry("CN=WS01,CN=Computers,DC=lab,DC=local", map[string][]string{
+		"distinguishedName":                   {"CN=WS01,CN=Computers,DC=lab,DC=local"},
+		ATTRIBUTE_LAPS_PASSWORD:               {`{"n":"lapsadmin","t":"1d8161b41c41cde","p":"W1nd0wsL4PS!"}`},
+		ATTRIBUTE_LAPS_EXPIRATION_TIME:        {"133500000000000000"},
+		ATTRIBUTE_LEGACY_LAPS_PASSWORD:        {"L3gacyL4PS!"},
+		ATTRIBUTE_LEGACY_LAPS_EXPIRATION_TIME: {"133600000000000000"},
+	})
+
+	lapsPasswords, err := parseLAPSEntry(entry)
+	if err != nil {
+		t.Fatalf("parseLAPSEntry error = %v", err)
+	}
+	if len(lapsPasswords) != 2 {
+		t.Fatalf("Expected 2 LAPS passwords, got %d", len(lapsPasswords))
+	}
+
+	windowsLAPS := lapsPasswords[0]
+	if windowsLAPS.Account != "lapsadmin" || windowsLAPS.Password != "W1nd0wsL4PS!" {
+		t.Errorf("Unexpected Windows LAPS password %+v", windowsLAPS)
+	}
+	expectedUpdateTime := time.Date(2022, time.January, 30, 20, 52, 13, 0, time.UTC)
+	if windowsLAPS.UpdateTime.Truncate(time.Second) != expectedUpdateTime {
+		t.Errorf("Expected update time %v, got %v", expectedUpdateTime, windowsLAPS.UpdateTime)
+	}
+	if windowsLAPS.ExpirationTime.IsZero() {
+		t.Errorf("Expected an expiration time")
+	}
+
+	legacyLAPS := lapsPasswords[1]
+	if legacyLAPS.Account != "" || legacyLAPS.Password != "L3gacyL4PS!" || legacyLAPS.DistinguishedName != entry.DN {
+		t.Errorf("Unexpected legacy LAPS password %+v", legacyLAPS)
+	}
+	if !legacyLAPS.ExpirationTime.After(windowsLAPS.ExpirationTime) {
+		t.Errorf("Unexpected legacy LAPS expiration time %v", legacyLAPS.ExpirationTime)
+	}
+
+	if _, err := ParseLAPSPassword(`{"n":"lapsadmin","t":"zz","p":"x"}`); err == nil {
+		t.Errorf("Expected an error for a malformed update time")
+	}
+	if _, err := ParseLAPSPassword("not json"); err == nil {
+		t.Errorf("Expected an error for a malformed value")
+	}
+}
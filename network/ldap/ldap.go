@@ -22,7 +22,8 @@ const (
 // naming contexts, and supported controls.
 //
 // This function performs a base object search with a filter of "(objectClass=*)"
-// to retrieve all attributes of the Root DSE.
+// to retrieve all attributes of the Root DSE. The Root DSE is cached for the lifetime of the
+// connection, use RefreshRootDSE to fetch it again.
 //
 // Returns:
 // - A pointer to an ldap.Entry object representing the Root DSE.
@@ -37,6 +38,19 @@ const (
 //	    fmt.Println("Failed to retrieve Root DSE")
 //	}
 func (ldapSession *Session) GetRootDSE() (*Entry, error) {
+	if ldapSession.rootDSE != nil {
+		return ldapSession.rootDSE, nil
+	}
+	return ldapSession.RefreshRootDSE()
+}
+
+// RefreshRootDSE fetches the Root DSE (Directory Service Entry) from the LDAP server, bypassing
+// and updating the cache used by GetRootDSE.
+//
+// Returns:
+//   - A pointer to an ldap.Entry object representing the Root DSE.
+//   - An error if the search fails or returns no entry.
+func (ldapSession *Session) RefreshRootDSE() (*Entry, error) {
 	// Specify LDAP search parameters
 	// https://pkg.go.dev/gopkg.in/ldap.v3#NewSearchRequest
 	searchRequest := goldapv3.NewSearchRequest(
@@ -66,5 +80,10 @@ func (ldapSession *Session) GetRootDSE() (*Entry, error) {
 		return nil, fmt.Errorf("error searching LDAP: %w", err)
 	}
 
-	return searchResult.Entries[0], nil
+	if len(searchResult.Entries) == 0 {
+		return nil, fmt.Errorf("no Root DSE returned by the LDAP server")
+	}
+
+	ldapSession.rootDSE = searchResult.Entries[0]
+	return ldapSession.rootDSE, nil
 }
@@ -1,29 +1,31 @@
 package ldap_attributes
 
-// Predefined RIDs
+import "github.com/TheManticoreProject/Manticore/windows/sid"
+
+// Predefined RIDs, defined from the catalog of windows/sid
 // Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-samr/565a6584-3061-4ede-a531-f5c53826504b
 const (
-	RID_DOMAIN_USER_KRBTGT                                  = 0x000001F6
-	RID_DOMAIN_USER_ADMIN                                   = 0x000001F4
-	RID_DOMAIN_USER_GUEST                                   = 0x000001F5
-	RID_DOMAIN_GROUP_ADMINS                                 = 0x00000200
-	RID_DOMAIN_GROUP_USERS                                  = 0x00000201
-	RID_DOMAIN_GROUP_GUESTS                                 = 0x00000202
-	RID_DOMAIN_GROUP_COMPUTERS                              = 0x00000203
-	RID_DOMAIN_GROUP_CONTROLLERS                            = 0x00000204
-	RID_DOMAIN_GROUP_CERT_PUBLISHERS                        = 0x00000205
-	RID_DOMAIN_GROUP_ENTERPRISE_READONLY_DOMAIN_CONTROLLERS = 0x000001F2
-	RID_DOMAIN_GROUP_SCHEMA_ADMINS                          = 0x00000206
-	RID_DOMAIN_GROUP_ENTERPRISE_ADMINS                      = 0x00000207
-	RID_DOMAIN_GROUP_POLICY_ADMINS                          = 0x00000208
-	RID_DOMAIN_GROUP_READONLY_CONTROLLERS                   = 0x00000209
-	RID_DOMAIN_GROUP_CLONEABLE_CONTROLLERS                  = 0x0000020A
-	RID_DOMAIN_GROUP_CDC_RESERVED                           = 0x0000020C
-	RID_DOMAIN_GROUP_PROTECTED_USERS                        = 0x0000020D
-	RID_DOMAIN_GROUP_KEY_ADMINS                             = 0x0000020E
-	RID_DOMAIN_GROUP_ENTERPRISE_KEY_ADMINS                  = 0x0000020F
-	RID_DOMAIN_GROUP_DENIED_RODC_PASSWORD_REPLICATION       = 0x0000023C
-	RID_DOMAIN_GROUP_ALIAS_CERTSVC_DCOM_ACCESS              = 0x0000023E
+	RID_DOMAIN_USER_KRBTGT                                  = int(sid.DOMAIN_USER_RID_KRBTGT)
+	RID_DOMAIN_USER_ADMIN                                   = int(sid.DOMAIN_USER_RID_ADMIN)
+	RID_DOMAIN_USER_GUEST                                   = int(sid.DOMAIN_USER_RID_GUEST)
+	RID_DOMAIN_GROUP_ADMINS                                 = int(sid.DOMAIN_GROUP_RID_ADMINS)
+	RID_DOMAIN_GROUP_USERS                                  = int(sid.DOMAIN_GROUP_RID_USERS)
+	RID_DOMAIN_GROUP_GUESTS                                 = int(sid.DOMAIN_GROUP_RID_GUESTS)
+	RID_DOMAIN_GROUP_COMPUTERS                              = int(sid.DOMAIN_GROUP_RID_COMPUTERS)
+	RID_DOMAIN_GROUP_CONTROLLERS                            = int(sid.DOMAIN_GROUP_RID_CONTROLLERS)
+	RID_DOMAIN_GROUP_CERT_PUBLISHERS                        = int(sid.DOMAIN_GROUP_RID_CERT_PUBLISHERS)
+	RID_DOMAIN_GROUP_ENTERPRISE_READONLY_DOMAIN_CONTROLLERS = int(sid.DOMAIN_GROUP_RID_ENTERPRISE_READONLY_CONTROLLERS)
+	RID_DOMAIN_GROUP_SCHEMA_ADMINS                          = int(sid.DOMAIN_GROUP_RID_SCHEMA_ADMINS)
+	RID_DOMAIN_GROUP_ENTERPRISE_ADMINS                      = int(sid.DOMAIN_GROUP_RID_ENTERPRISE_ADMINS)
+	RID_DOMAIN_GROUP_POLICY_ADMINS                          = int(sid.DOMAIN_GROUP_RID_POLICY_CREATOR_OWNERS)
+	RID_DOMAIN_GROUP_READONLY_CONTROLLERS                   = int(sid.DOMAIN_GROUP_RID_READONLY_CONTROLLERS)
+	RID_DOMAIN_GROUP_CLONEABLE_CONTROLLERS                  = int(sid.DOMAIN_GROUP_RID_CLONEABLE_CONTROLLERS)
+	RID_DOMAIN_GROUP_CDC_RESERVED                           = int(sid.DOMAIN_GROUP_RID_CDC_RESERVED)
+	RID_DOMAIN_GROUP_PROTECTED_USERS                        = int(sid.DOMAIN_GROUP_RID_PROTECTED_USERS)
+	RID_DOMAIN_GROUP_KEY_ADMINS                             = int(sid.DOMAIN_GROUP_RID_KEY_ADMINS)
+	RID_DOMAIN_GROUP_ENTERPRISE_KEY_ADMINS                  = int(sid.DOMAIN_GROUP_RID_ENTERPRISE_KEY_ADMINS)
+	RID_DOMAIN_GROUP_DENIED_RODC_PASSWORD_REPLICATION       = int(sid.DOMAIN_ALIAS_RID_DENIED_RODC_REPLICATION)
+	RID_DOMAIN_GROUP_ALIAS_CERTSVC_DCOM_ACCESS              = int(sid.DOMAIN_ALIAS_RID_CERTSVC_DCOM_ACCESS)
 )
 
 var DomainRIDs = []int{
@@ -125,3 +127,17 @@ var LocalRIDs = []int{
 	RID_LOCAL_STORAGE_REPLICA_ADMINS,
 	RID_LOCAL_DEVICE_OWNERS,
 }
+
+// GetDomainRIDName returns the name of a predefined domain RID.
+//
+// Parameters:
+// - rid: An integer representing the relative identifier.
+//
+// Returns:
+// - A string containing the name of the account or group, or an empty string if the RID is not a predefined domain RID.
+func GetDomainRIDName(rid int) string {
+	if rid < 0 {
+		return ""
+	}
+	return sid.WellKnownDomainRIDs[uint32(rid)]
+}
@@ -0,0 +1,22 @@
+package ldap_attributes
+
+import "testing"
+
+func TestGetDomainRIDName(t *testing.T) {
+	tests := []struct {
+		rid      int
+		expected string
+	}{
+		{RID_DOMAIN_USER_KRBTGT, "krbtgt"},
+		{RID_DOMAIN_GROUP_ADMINS, "Domain Admins"},
+		{RID_DOMAIN_GROUP_POLICY_ADMINS, "Group Policy Creator Owners"},
+		{RID_DOMAIN_GROUP_ALIAS_CERTSVC_DCOM_ACCESS, "Certificate Service DCOM Access"},
+		{1104, ""},
+		{-1, ""},
+	}
+	for _, test := range tests {
+		if name := GetDomainRIDName(test.rid); name != test.expected {
+			t.Errorf("GetDomainRIDName(%d) = %q; expected %q", test.rid, name, test.expected)
+		}
+	}
+}
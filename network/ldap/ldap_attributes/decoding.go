@@ -0,0 +1,378 @@
+package ldap_attributes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	keycredentiallink "github.com/TheManticoreProject/Manticore/windows/keycredential"
+	"github.com/TheManticoreProject/Manticore/windows/ms_dtyp/common/data_structures"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// AttributeDecoder decodes a raw value of an attribute into a typed Go value.
+type AttributeDecoder func(value []byte) (any, error)
+
+// attributeSyntax values of the attributeSchema objects
+// Src: https://learn.microsoft.com/en-us/windows/win32/adschema/syntaxes
+const (
+	ATTRIBUTE_SYNTAX_DN                     = "2.5.5.1"
+	ATTRIBUTE_SYNTAX_OID                    = "2.5.5.2"
+	ATTRIBUTE_SYNTAX_CASE_EXACT_STRING      = "2.5.5.3"
+	ATTRIBUTE_SYNTAX_CASE_IGNORE_STRING     = "2.5.5.4"
+	ATTRIBUTE_SYNTAX_PRINTABLE_STRING       = "2.5.5.5"
+	ATTRIBUTE_SYNTAX_NUMERIC_STRING         = "2.5.5.6"
+	ATTRIBUTE_SYNTAX_DN_BINARY              = "2.5.5.7"
+	ATTRIBUTE_SYNTAX_BOOLEAN                = "2.5.5.8"
+	ATTRIBUTE_SYNTAX_INTEGER                = "2.5.5.9"
+	ATTRIBUTE_SYNTAX_OCTET_STRING           = "2.5.5.10"
+	ATTRIBUTE_SYNTAX_TIME                   = "2.5.5.11"
+	ATTRIBUTE_SYNTAX_UNICODE_STRING         = "2.5.5.12"
+	ATTRIBUTE_SYNTAX_PRESENTATION_ADDRESS   = "2.5.5.13"
+	ATTRIBUTE_SYNTAX_DN_STRING              = "2.5.5.14"
+	ATTRIBUTE_SYNTAX_NT_SECURITY_DESCRIPTOR = "2.5.5.15"
+	ATTRIBUTE_SYNTAX_LARGE_INTEGER          = "2.5.5.16"
+	ATTRIBUTE_SYNTAX_SID                    = "2.5.5.17"
+)
+
+var (
+	decodersLock sync.RWMutex
+
+	// attributeDecoders holds the decoders of the attributes, keyed by lowercase LDAP display name
+	attributeDecoders = map[string]AttributeDecoder{}
+
+	// syntaxDecoders holds the decoders of the attribute syntaxes, keyed by attributeSyntax OID
+	syntaxDecoders = map[string]AttributeDecoder{}
+)
+
+func init() {
+	for _, attribute := range []string{"objectSid", "sIDHistory", "securityIdentifier", "tokenGroups", "tokenGroupsGlobalAndUniversal", "tokenGroupsNoGCAcceptable", "mS-DS-CreatorSID"} {
+		RegisterAttributeDecoder(attribute, decodeSID)
+	}
+	for _, attribute := range []string{"objectGUID", "schemaIDGUID", "attributeSecurityGUID", "invocationId", "mS-DS-ConsistencyGuid"} {
+		RegisterAttributeDecoder(attribute, decodeGUID)
+	}
+	for _, attribute := range []string{"whenCreated", "whenChanged", "dSCorePropagationData"} {
+		RegisterAttributeDecoder(attribute, decodeGeneralizedTime)
+	}
+	for _, attribute := range []string{"pwdLastSet", "lastLogon", "lastLogonTimestamp", "lastLogoff", "accountExpires", "badPasswordTime", "lockoutTime", "creationTime", "ms-Mcs-AdmPwdExpirationTime", "msLAPS-PasswordExpirationTime", "msDS-LastSuccessfulInteractiveLogonTime", "msDS-LastFailedInteractiveLogonTime"} {
+		RegisterAttributeDecoder(attribute, decodeFileTime)
+	}
+	for _, attribute := range []string{"maxPwdAge", "minPwdAge", "lockoutDuration", "lockOutObservationWindow", "forceLogoff", "msDS-MaximumPasswordAge", "msDS-MinimumPasswordAge", "msDS-LockoutDuration", "msDS-LockoutObservationWindow"} {
+		RegisterAttributeDecoder(attribute, decodeInterval)
+	}
+	for _, attribute := range []string{"nTSecurityDescriptor", "msDS-AllowedToActOnBehalfOfOtherIdentity", "msDS-GroupMSAMembership", "fRSRootSecurity", "msDFS-LinkSecurityDescriptorv2"} {
+		RegisterAttributeDecoder(attribute, decodeSecurityDescriptor)
+	}
+
+	RegisterAttributeDecoder("userAccountControl", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return UserAccountControl(v), err
+	})
+	RegisterAttributeDecoder("msDS-User-Account-Control-Computed", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return UserAccountControl(v), err
+	})
+	RegisterAttributeDecoder("sAMAccountType", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return SAMAccountType(v), err
+	})
+	RegisterAttributeDecoder("groupType", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return GroupType(v), err
+	})
+	RegisterAttributeDecoder("msDS-SupportedEncryptionTypes", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return SupportedEncryptionTypes(v), err
+	})
+	RegisterAttributeDecoder("pwdProperties", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return PasswordProperties(v), err
+	})
+	RegisterAttributeDecoder("msPKI-Certificate-Name-Flag", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return MSPKICertificateNameFlag(v), err
+	})
+	RegisterAttributeDecoder("msPKI-Enrollment-Flag", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return MSPKIEnrollmentFlag(v), err
+	})
+	RegisterAttributeDecoder("msDS-Behavior-Version", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return DomainFunctionalityLevel(v), err
+	})
+	RegisterAttributeDecoder("trustDirection", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return TrustDirection(v), err
+	})
+	RegisterAttributeDecoder("trustType", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return TrustType(v), err
+	})
+	RegisterAttributeDecoder("trustAttributes", func(value []byte) (any, error) {
+		v, err := parseInteger(value)
+		return TrustAttributes(v), err
+	})
+	RegisterAttributeDecoder("msDS-KeyCredentialLink", decodeKeyCredentialLink)
+	RegisterAttributeDecoder("dnsRecord", decodeDNSRecord)
+
+	for _, syntax := range []string{ATTRIBUTE_SYNTAX_DN, ATTRIBUTE_SYNTAX_OID, ATTRIBUTE_SYNTAX_CASE_EXACT_STRING, ATTRIBUTE_SYNTAX_CASE_IGNORE_STRING, ATTRIBUTE_SYNTAX_PRINTABLE_STRING, ATTRIBUTE_SYNTAX_NUMERIC_STRING, ATTRIBUTE_SYNTAX_UNICODE_STRING, ATTRIBUTE_SYNTAX_PRESENTATION_ADDRESS, ATTRIBUTE_SYNTAX_DN_STRING} {
+		RegisterSyntaxDecoder(syntax, decodeString)
+	}
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_DN_BINARY, decodeDNWithBinary)
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_BOOLEAN, decodeBoolean)
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_INTEGER, decodeInteger)
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_LARGE_INTEGER, decodeInteger)
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_OCTET_STRING, decodeOctetString)
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_TIME, decodeGeneralizedTime)
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_NT_SECURITY_DESCRIPTOR, decodeSecurityDescriptor)
+	RegisterSyntaxDecoder(ATTRIBUTE_SYNTAX_SID, decodeSID)
+}
+
+// RegisterAttributeDecoder registers the decoder of an attribute, replacing the existing one.
+//
+// Parameters:
+//   - attribute: The LDAP display name of the attribute, case insensitive.
+//   - decoder: The decoder of the values of the attribute.
+func RegisterAttributeDecoder(attribute string, decoder AttributeDecoder) {
+	decodersLock.Lock()
+	defer decodersLock.Unlock()
+	attributeDecoders[strings.ToLower(attribute)] = decoder
+}
+
+// RegisterSyntaxDecoder registers the decoder of an attribute syntax, replacing the existing one.
+//
+// Parameters:
+//   - syntax: The attributeSyntax OID, one of the ATTRIBUTE_SYNTAX_* constants.
+//   - decoder: The decoder of the values of the attributes of this syntax.
+func RegisterSyntaxDecoder(syntax string, decoder AttributeDecoder) {
+	decodersLock.Lock()
+	defer decodersLock.Unlock()
+	syntaxDecoders[syntax] = decoder
+}
+
+// GetAttributeDecoder returns the decoder registered for an attribute.
+//
+// Parameters:
+//   - attribute: The name of the attribute, case insensitive. Options such as ";range=0-1499" are ignored.
+//
+// Returns:
+//   - The decoder of the attribute, or nil if none is registered.
+func GetAttributeDecoder(attribute string) AttributeDecoder {
+	if index := strings.Index(attribute, ";"); index != -1 {
+		attribute = attribute[:index]
+	}
+	decodersLock.RLock()
+	defer decodersLock.RUnlock()
+	return attributeDecoders[strings.ToLower(attribute)]
+}
+
+// GetSyntaxDecoder returns the decoder registered for an attribute syntax.
+//
+// Parameters:
+//   - syntax: The attributeSyntax OID.
+//
+// Returns:
+//   - The decoder of the syntax, or nil if none is registered.
+func GetSyntaxDecoder(syntax string) AttributeDecoder {
+	decodersLock.RLock()
+	defer decodersLock.RUnlock()
+	return syntaxDecoders[syntax]
+}
+
+// DecodeAttributeValues decodes the raw values of an attribute with the decoder registered for its name. The
+// values of the attributes without decoder are returned as strings.
+//
+// Parameters:
+//   - attribute: The name of the attribute, case insensitive.
+//   - values: The raw values of the attribute.
+//
+// Returns:
+//   - A slice of the decoded values.
+//   - An error if a value cannot be decoded.
+func DecodeAttributeValues(attribute string, values [][]byte) ([]any, error) {
+	decoder := GetAttributeDecoder(attribute)
+	if decoder == nil {
+		decoder = decodeString
+	}
+	return DecodeValues(decoder, attribute, values)
+}
+
+// DecodeValues decodes raw values with a decoder.
+//
+// Parameters:
+//   - decoder: The decoder of the values.
+//   - attribute: The name of the attribute, used in the errors.
+//   - values: The raw values of the attribute.
+//
+// Returns:
+//   - A slice of the decoded values.
+//   - An error if a value cannot be decoded.
+func DecodeValues(decoder AttributeDecoder, attribute string, values [][]byte) ([]any, error) {
+	decoded := make([]any, 0, len(values))
+	for _, value := range values {
+		v, err := decoder(value)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding attribute %s: %w", attribute, err)
+		}
+		decoded = append(decoded, v)
+	}
+	return decoded, nil
+}
+
+// ParseFileTime parses a number of 100-nanosecond intervals since January 1, 1601, as stored in attributes
+// such as pwdLastSet or lastLogonTimestamp.
+//
+// Parameters:
+//   - value: The decimal representation of the value.
+//
+// Returns:
+//   - The time in UTC, or the zero time for 0 and for the "never" value 0x7FFFFFFFFFFFFFFF.
+//   - An error if the value is not an integer.
+func ParseFileTime(value []byte) (time.Time, error) {
+	v, err := parseInteger(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if v <= 0 || v == math.MaxInt64 {
+		return time.Time{}, nil
+	}
+	fileTime := data_structures.FILETIME{DwLowDateTime: uint32(v), DwHighDateTime: uint32(v >> 32)}
+	return fileTime.GetTime().UTC(), nil
+}
+
+// ParseInterval parses a duration stored as a number of 100-nanosecond intervals, as in attributes such as
+// maxPwdAge whose values are negative.
+//
+// Parameters:
+//   - value: The decimal representation of the value.
+//
+// Returns:
+//   - The absolute duration, or 0 for the "never" value -0x8000000000000000.
+//   - An error if the value is not an integer.
+func ParseInterval(value []byte) (time.Duration, error) {
+	v, err := parseInteger(value)
+	if err != nil {
+		return 0, err
+	}
+	if v == math.MinInt64 {
+		return 0, nil
+	}
+	if v < 0 {
+		v = -v
+	}
+	if v > math.MaxInt64/100 {
+		return time.Duration(math.MaxInt64), nil
+	}
+	return time.Duration(v * 100), nil
+}
+
+// ParseGeneralizedTime parses a generalized time, such as "20240102030405.0Z".
+//
+// Parameters:
+//   - value: The value of the attribute.
+//
+// Returns:
+//   - The time in UTC.
+//   - An error if the value is not a generalized time.
+func ParseGeneralizedTime(value []byte) (time.Time, error) {
+	for _, layout := range []string{"20060102150405.0Z0700", "20060102150405Z0700"} {
+		if parsed, err := time.Parse(layout, string(value)); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid generalized time %q", string(value))
+}
+
+// parseInteger parses the decimal representation of a 32 or 64-bit integer.
+func parseInteger(value []byte) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64)
+}
+
+func decodeString(value []byte) (any, error) {
+	return string(value), nil
+}
+
+func decodeOctetString(value []byte) (any, error) {
+	return append([]byte{}, value...), nil
+}
+
+func decodeInteger(value []byte) (any, error) {
+	return parseInteger(value)
+}
+
+func decodeBoolean(value []byte) (any, error) {
+	switch strings.ToUpper(string(value)) {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	return nil, fmt.Errorf("invalid boolean %q", string(value))
+}
+
+func decodeSID(value []byte) (any, error) {
+	return sid.FromBytes(value)
+}
+
+func decodeGUID(value []byte) (any, error) {
+	if len(value) != 16 {
+		return nil, fmt.Errorf("invalid GUID length %d", len(value))
+	}
+	g := &guid.GUID{}
+	g.FromRawBytes(value)
+	return g, nil
+}
+
+func decodeFileTime(value []byte) (any, error) {
+	return ParseFileTime(value)
+}
+
+func decodeInterval(value []byte) (any, error) {
+	return ParseInterval(value)
+}
+
+func decodeGeneralizedTime(value []byte) (any, error) {
+	return ParseGeneralizedTime(value)
+}
+
+func decodeSecurityDescriptor(value []byte) (any, error) {
+	securityDescriptor := &security.SecurityDescriptor{}
+	if err := securityDescriptor.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return securityDescriptor, nil
+}
+
+func decodeDNWithBinary(value []byte) (any, error) {
+	dnWithBinary := &keycredentiallink.DNWithBinary{}
+	if err := dnWithBinary.Parse(value); err != nil {
+		return nil, err
+	}
+	return dnWithBinary, nil
+}
+
+func decodeKeyCredentialLink(value []byte) (any, error) {
+	dnWithBinary := keycredentiallink.DNWithBinary{}
+	if err := dnWithBinary.Parse(value); err != nil {
+		return nil, err
+	}
+	keyCredential := &keycredentiallink.KeyCredential{}
+	if err := keyCredential.ParseDNWithBinary(dnWithBinary); err != nil {
+		return nil, err
+	}
+	return keyCredential, nil
+}
+
+func decodeDNSRecord(value []byte) (any, error) {
+	record, err := ParseDNSRecord(value)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,89 @@
+package ldap_attributes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+func TestDecodeAttributeValues(t *testing.T) {
+	objectSID, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	securityDescriptor, _ := security.ParseSDDL("O:BAD:(A;;GA;;;S-1-5-21-1-2-3-1104)", nil)
+	securityDescriptorBytes, _ := securityDescriptor.ToBytes()
+
+	tests := []struct {
+		attribute string
+		value     []byte
+		check     func(value any) bool
+	}{
+		{"objectSid", objectSID.ToBytes(), func(value any) bool { return value.(*sid.SID).String() == "S-1-5-21-1-2-3-1104" }},
+		{"sIDHistory", objectSID.ToBytes(), func(value any) bool { return value.(*sid.SID).Equal(objectSID) }},
+		{"objectGUID", []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x34, 0x12, 0x12, 0x34, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc}, func(value any) bool {
+			return value.(*guid.GUID).ToFormatD() == "12345678-1234-1234-1234-123456789abc"
+		}},
+		{"whenCreated", []byte("20240102030405.0Z"), func(value any) bool {
+			return value.(time.Time).Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+		}},
+		{"pwdLastSet", []byte("133485408000000000"), func(value any) bool {
+			return value.(time.Time).Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		}},
+		{"accountExpires", []byte("9223372036854775807"), func(value any) bool { return value.(time.Time).IsZero() }},
+		{"maxPwdAge", []byte("-36288000000000"), func(value any) bool { return value.(time.Duration) == 42*24*time.Hour }},
+		{"maxPwdAge", []byte("-9223372036854775808"), func(value any) bool { return value.(time.Duration) == 0 }},
+		{"UserAccountControl", []byte("66048"), func(value any) bool {
+			return value.(ldap_attributes.UserAccountControl).String() == "DONT_EXPIRE_PASSWORD|NORMAL_ACCOUNT"
+		}},
+		{"groupType", []byte("-2147483646"), func(value any) bool { return value.(ldap_attributes.GroupType).Scope() == "Global" }},
+		{"msDS-SupportedEncryptionTypes", []byte("24"), func(value any) bool {
+			return value.(ldap_attributes.SupportedEncryptionTypes).String() == "AES128_CTS_HMAC_SHA1_96|AES256_CTS_HMAC_SHA1_96"
+		}},
+		{"msPKI-Certificate-Name-Flag", []byte("1"), func(value any) bool {
+			return value.(ldap_attributes.MSPKICertificateNameFlag).String() == "ENROLLEE_SUPPLIES_SUBJECT"
+		}},
+		{"nTSecurityDescriptor", securityDescriptorBytes, func(value any) bool {
+			return value.(*security.SecurityDescriptor).Owner.String() == sid.SID_BUILTIN_ADMINISTRATORS
+		}},
+		{"member;range=0-1499", []byte("CN=Administrator,CN=Users,DC=lab,DC=local"), func(value any) bool {
+			return value.(string) == "CN=Administrator,CN=Users,DC=lab,DC=local"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.attribute, func(t *testing.T) {
+			values, err := ldap_attributes.DecodeAttributeValues(tt.attribute, [][]byte{tt.value})
+			if err != nil {
+				t.Fatalf("DecodeAttributeValues(%s) error = %v", tt.attribute, err)
+			}
+			if len(values) != 1 || !tt.check(values[0]) {
+				t.Errorf("DecodeAttributeValues(%s) = %v", tt.attribute, values)
+			}
+		})
+	}
+
+	for attribute, value := range map[string]string{"objectSid": "\x01", "objectGUID": "short", "pwdLastSet": "never", "whenChanged": "yesterday"} {
+		if _, err := ldap_attributes.DecodeAttributeValues(attribute, [][]byte{[]byte(value)}); err == nil {
+			t.Errorf("DecodeAttributeValues(%s, %q) should fail", attribute, value)
+		}
+	}
+}
+
+func TestRegisterAttributeDecoder(t *testing.T) {
+	ldap_attributes.RegisterAttributeDecoder("x-Test-Attribute", func(value []byte) (any, error) {
+		return len(value), nil
+	})
+
+	values, err := ldap_attributes.DecodeAttributeValues("X-TEST-ATTRIBUTE", [][]byte{[]byte("abc"), []byte("")})
+	if err != nil {
+		t.Fatalf("DecodeAttributeValues error = %v", err)
+	}
+	if len(values) != 2 || values[0].(int) != 3 || values[1].(int) != 0 {
+		t.Errorf("DecodeAttributeValues = %v", values)
+	}
+	if ldap_attributes.GetSyntaxDecoder(ldap_attributes.ATTRIBUTE_SYNTAX_SID) == nil {
+		t.Error("Expected a decoder for the SID syntax")
+	}
+}
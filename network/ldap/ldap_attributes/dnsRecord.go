@@ -0,0 +1,504 @@
+package ldap_attributes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/windows/ms_dtyp/common/data_structures"
+)
+
+// Types of the DNS records stored in the dnsRecord attribute
+// Src: [MS-DNSP] 2.2.2.1.1 DNS_RECORD_TYPE
+const (
+	DNS_TYPE_ZERO  uint16 = 0x0000
+	DNS_TYPE_A     uint16 = 0x0001
+	DNS_TYPE_NS    uint16 = 0x0002
+	DNS_TYPE_CNAME uint16 = 0x0005
+	DNS_TYPE_SOA   uint16 = 0x0006
+	DNS_TYPE_PTR   uint16 = 0x000C
+	DNS_TYPE_TXT   uint16 = 0x0010
+	DNS_TYPE_AAAA  uint16 = 0x001C
+	DNS_TYPE_SRV   uint16 = 0x0021
+)
+
+var DNSRecordTypeMap = map[uint16]string{
+	DNS_TYPE_ZERO:  "ZERO",
+	DNS_TYPE_A:     "A",
+	DNS_TYPE_NS:    "NS",
+	DNS_TYPE_CNAME: "CNAME",
+	DNS_TYPE_SOA:   "SOA",
+	DNS_TYPE_PTR:   "PTR",
+	DNS_TYPE_TXT:   "TXT",
+	DNS_TYPE_AAAA:  "AAAA",
+	DNS_TYPE_SRV:   "SRV",
+}
+
+// Rank of the records of the zones hosted by the DNS server
+// Src: [MS-DNSP] 2.2.2.2.5 DNS_RPC_RECORD
+const DNS_RANK_ZONE uint8 = 0xF0
+
+// Version of the dnsRecord structure
+const DNS_RECORD_VERSION uint8 = 0x05
+
+// Size of the header of the dnsRecord structure, preceding the record data
+const dnsRecordHeaderSize = 24
+
+// Number of hours between January 1, 1601 and January 1, 1970, the epoch of the aging timestamps
+const dnsTimestampUnixEpochHours = 3234576
+
+// DNSRecordData is the data of a DNS record, whose format depends on the type of the record.
+type DNSRecordData interface {
+	// Marshal encodes the data as stored in the dnsRecord attribute.
+	Marshal() ([]byte, error)
+	// String returns the data in the presentation format of the zone files.
+	String() string
+}
+
+// DNSRecord is a value of the dnsRecord attribute of a dnsNode object, holding one DNS record of the node.
+// Src: [MS-DNSP] 2.3.2.2 DNS_RECORD
+//
+// Fields:
+//   - Type: The type of the record, one of the DNS_TYPE_* constants. DNS_TYPE_ZERO marks a tombstoned node.
+//   - Rank: The rank of the record, DNS_RANK_ZONE for the records of the zones hosted by the server.
+//   - Flags: The flags of the record, 0 in the directory.
+//   - Serial: The serial number of the zone when the record was last updated.
+//   - TTL: The time to live of the record, in seconds.
+//   - Timestamp: The time the record was last refreshed, for aging and scavenging. Zero for static records.
+//   - Data: The decoded data of the record.
+type DNSRecord struct {
+	Type      uint16
+	Rank      uint8
+	Flags     uint16
+	Serial    uint32
+	TTL       uint32
+	Timestamp time.Time
+	Data      DNSRecordData
+}
+
+// DNSRecordAddress is the data of A and AAAA records.
+type DNSRecordAddress struct {
+	IP net.IP
+}
+
+// DNSRecordName is the data of NS, CNAME and PTR records.
+type DNSRecordName struct {
+	Name string
+}
+
+// DNSRecordSRV is the data of SRV records.
+type DNSRecordSRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// DNSRecordTXT is the data of TXT records, made of one or more strings.
+type DNSRecordTXT struct {
+	Strings []string
+}
+
+// DNSRecordSOA is the data of the SOA record of a zone.
+type DNSRecordSOA struct {
+	SerialNumber  uint32
+	Refresh       uint32
+	Retry         uint32
+	Expire        uint32
+	MinimumTTL    uint32
+	PrimaryServer string
+	ZoneAdminMail string
+}
+
+// DNSRecordTombstone is the data of the DNS_TYPE_ZERO record of a tombstoned node, holding the time it was
+// tombstoned.
+type DNSRecordTombstone struct {
+	EntombedTime time.Time
+}
+
+// DNSRecordRaw is the data of the records of the types which are not decoded.
+type DNSRecordRaw struct {
+	Bytes []byte
+}
+
+// NewDNSRecord creates a static DNS record of the rank of the zone records.
+//
+// Parameters:
+//   - recordType: The type of the record, one of the DNS_TYPE_* constants.
+//   - data: The data of the record, matching its type.
+//   - serial: The serial number of the zone, usually the serial of its SOA record incremented by one.
+//   - TTL: The time to live of the record, in seconds.
+//
+// Returns:
+//   - A pointer to the DNSRecord.
+func NewDNSRecord(recordType uint16, data DNSRecordData, serial uint32, TTL uint32) *DNSRecord {
+	return &DNSRecord{
+		Type:   recordType,
+		Rank:   DNS_RANK_ZONE,
+		Serial: serial,
+		TTL:    TTL,
+		Data:   data,
+	}
+}
+
+// NewDNSTombstoneRecord creates the DNS_TYPE_ZERO record replacing the records of a tombstoned node.
+//
+// Parameters:
+//   - serial: The serial number of the zone.
+//
+// Returns:
+//   - A pointer to the DNSRecord, entombed at the current time.
+func NewDNSTombstoneRecord(serial uint32) *DNSRecord {
+	return NewDNSRecord(DNS_TYPE_ZERO, &DNSRecordTombstone{EntombedTime: time.Now().UTC()}, serial, 0)
+}
+
+// ParseDNSRecord parses a value of the dnsRecord attribute.
+//
+// Parameters:
+//   - value: A byte slice containing the DNS_RECORD structure.
+//
+// Returns:
+//   - A pointer to the parsed DNSRecord. The data of the types which are not decoded is kept in a DNSRecordRaw.
+//   - An error if the value is truncated or if the data is malformed.
+func ParseDNSRecord(value []byte) (*DNSRecord, error) {
+	if len(value) < dnsRecordHeaderSize {
+		return nil, fmt.Errorf("dnsRecord must be at least %d bytes, got %d", dnsRecordHeaderSize, len(value))
+	}
+	dataLength := int(binary.LittleEndian.Uint16(value[0:2]))
+	if len(value) < dnsRecordHeaderSize+dataLength {
+		return nil, fmt.Errorf("dnsRecord data of %d bytes is truncated to %d bytes", dataLength, len(value)-dnsRecordHeaderSize)
+	}
+	if value[4] != DNS_RECORD_VERSION {
+		return nil, fmt.Errorf("unsupported dnsRecord version %d", value[4])
+	}
+
+	record := &DNSRecord{
+		Type:   binary.LittleEndian.Uint16(value[2:4]),
+		Rank:   value[5],
+		Flags:  binary.LittleEndian.Uint16(value[6:8]),
+		Serial: binary.LittleEndian.Uint32(value[8:12]),
+		// The time to live is the only field of the header in network byte order
+		TTL: binary.BigEndian.Uint32(value[12:16]),
+	}
+	if timestamp := binary.LittleEndian.Uint32(value[20:24]); timestamp != 0 {
+		record.Timestamp = time.Unix((int64(timestamp)-dnsTimestampUnixEpochHours)*3600, 0).UTC()
+	}
+
+	data, err := parseDNSRecordData(record.Type, value[dnsRecordHeaderSize:dnsRecordHeaderSize+dataLength])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s record: %w", record.GetTypeName(), err)
+	}
+	record.Data = data
+
+	return record, nil
+}
+
+// Marshal encodes the DNS record as a value of the dnsRecord attribute.
+//
+// Returns:
+//   - A byte slice containing the DNS_RECORD structure.
+//   - An error if the data of the record cannot be encoded.
+func (record *DNSRecord) Marshal() ([]byte, error) {
+	data := []byte{}
+	if record.Data != nil {
+		var err error
+		if data, err = record.Data.Marshal(); err != nil {
+			return nil, fmt.Errorf("error encoding %s record: %w", record.GetTypeName(), err)
+		}
+	}
+	if len(data) > 0xffff {
+		return nil, fmt.Errorf("%s record data of %d bytes is too long", record.GetTypeName(), len(data))
+	}
+
+	timestamp := uint32(0)
+	if !record.Timestamp.IsZero() {
+		timestamp = uint32(record.Timestamp.Unix()/3600 + dnsTimestampUnixEpochHours)
+	}
+
+	value := binary.LittleEndian.AppendUint16(nil, uint16(len(data)))
+	value = binary.LittleEndian.AppendUint16(value, record.Type)
+	value = append(value, DNS_RECORD_VERSION, record.Rank)
+	value = binary.LittleEndian.AppendUint16(value, record.Flags)
+	value = binary.LittleEndian.AppendUint32(value, record.Serial)
+	value = binary.BigEndian.AppendUint32(value, record.TTL)
+	value = binary.LittleEndian.AppendUint32(value, 0)
+	value = binary.LittleEndian.AppendUint32(value, timestamp)
+	return append(value, data...), nil
+}
+
+// GetTypeName returns the name of the type of the record.
+//
+// Returns:
+//   - A string such as "A" or "SRV", or "TYPE<n>" for the unknown types.
+func (record *DNSRecord) GetTypeName() string {
+	if name, ok := DNSRecordTypeMap[record.Type]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", record.Type)
+}
+
+// IsTombstone checks if the record marks a tombstoned node.
+//
+// Returns:
+//   - True if the record is of type DNS_TYPE_ZERO, false otherwise.
+func (record *DNSRecord) IsTombstone() bool {
+	return record.Type == DNS_TYPE_ZERO
+}
+
+// String returns the type, time to live and data of the record, such as "A 180 192.168.56.10".
+func (record *DNSRecord) String() string {
+	data := ""
+	if record.Data != nil {
+		data = record.Data.String()
+	}
+	return fmt.Sprintf("%s %d %s", record.GetTypeName(), record.TTL, data)
+}
+
+// Marshal encodes the IPv4 address of an A record or the IPv6 address of an AAAA record.
+func (data *DNSRecordAddress) Marshal() ([]byte, error) {
+	if ipv4 := data.IP.To4(); ipv4 != nil {
+		return []byte(ipv4), nil
+	}
+	if ipv6 := data.IP.To16(); ipv6 != nil {
+		return []byte(ipv6), nil
+	}
+	return nil, fmt.Errorf("invalid IP address %v", data.IP)
+}
+
+// String returns the IP address.
+func (data *DNSRecordAddress) String() string {
+	return data.IP.String()
+}
+
+// Marshal encodes the name as a DNS_COUNT_NAME.
+func (data *DNSRecordName) Marshal() ([]byte, error) {
+	return marshalDNSCountName(data.Name)
+}
+
+// String returns the fully qualified name.
+func (data *DNSRecordName) String() string {
+	return data.Name
+}
+
+// Marshal encodes the priority, weight and port in network byte order, followed by the target.
+func (data *DNSRecordSRV) Marshal() ([]byte, error) {
+	target, err := marshalDNSCountName(data.Target)
+	if err != nil {
+		return nil, err
+	}
+	value := binary.BigEndian.AppendUint16(nil, data.Priority)
+	value = binary.BigEndian.AppendUint16(value, data.Weight)
+	value = binary.BigEndian.AppendUint16(value, data.Port)
+	return append(value, target...), nil
+}
+
+// String returns the priority, weight, port and target.
+func (data *DNSRecordSRV) String() string {
+	return fmt.Sprintf("%d %d %d %s", data.Priority, data.Weight, data.Port, data.Target)
+}
+
+// Marshal encodes the strings, each one preceded by its length.
+func (data *DNSRecordTXT) Marshal() ([]byte, error) {
+	value := []byte{}
+	for _, s := range data.Strings {
+		if len(s) > 0xff {
+			return nil, fmt.Errorf("TXT string of %d bytes is too long", len(s))
+		}
+		value = append(append(value, byte(len(s))), s...)
+	}
+	return value, nil
+}
+
+// String returns the quoted strings.
+func (data *DNSRecordTXT) String() string {
+	quoted := []string{}
+	for _, s := range data.Strings {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Marshal encodes the counters in network byte order, followed by the primary server and the mailbox of the
+// administrator of the zone.
+func (data *DNSRecordSOA) Marshal() ([]byte, error) {
+	value := []byte{}
+	for _, counter := range []uint32{data.SerialNumber, data.Refresh, data.Retry, data.Expire, data.MinimumTTL} {
+		value = binary.BigEndian.AppendUint32(value, counter)
+	}
+	for _, name := range []string{data.PrimaryServer, data.ZoneAdminMail} {
+		encoded, err := marshalDNSCountName(name)
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, encoded...)
+	}
+	return value, nil
+}
+
+// String returns the fields of the SOA record in the order of the zone files.
+func (data *DNSRecordSOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", data.PrimaryServer, data.ZoneAdminMail, data.SerialNumber, data.Refresh, data.Retry, data.Expire, data.MinimumTTL)
+}
+
+// Marshal encodes the time the node was tombstoned as a FILETIME.
+func (data *DNSRecordTombstone) Marshal() ([]byte, error) {
+	return data_structures.NewFILETIMEFromTime(data.EntombedTime).Marshal()
+}
+
+// String returns the time the node was tombstoned.
+func (data *DNSRecordTombstone) String() string {
+	return data.EntombedTime.Format(time.RFC3339)
+}
+
+// Marshal returns the raw data.
+func (data *DNSRecordRaw) Marshal() ([]byte, error) {
+	return data.Bytes, nil
+}
+
+// String returns the raw data in hexadecimal.
+func (data *DNSRecordRaw) String() string {
+	return fmt.Sprintf("%x", data.Bytes)
+}
+
+// parseDNSRecordData decodes the data of a record according to its type.
+func parseDNSRecordData(recordType uint16, data []byte) (DNSRecordData, error) {
+	switch recordType {
+	case DNS_TYPE_A:
+		if len(data) != net.IPv4len {
+			return nil, fmt.Errorf("invalid IPv4 address length %d", len(data))
+		}
+		return &DNSRecordAddress{IP: net.IP(append([]byte{}, data...))}, nil
+
+	case DNS_TYPE_AAAA:
+		if len(data) != net.IPv6len {
+			return nil, fmt.Errorf("invalid IPv6 address length %d", len(data))
+		}
+		return &DNSRecordAddress{IP: net.IP(append([]byte{}, data...))}, nil
+
+	case DNS_TYPE_NS, DNS_TYPE_CNAME, DNS_TYPE_PTR:
+		name, _, err := parseDNSCountName(data)
+		if err != nil {
+			return nil, err
+		}
+		return &DNSRecordName{Name: name}, nil
+
+	case DNS_TYPE_SRV:
+		if len(data) < 6 {
+			return nil, fmt.Errorf("SRV data is too short")
+		}
+		target, _, err := parseDNSCountName(data[6:])
+		if err != nil {
+			return nil, err
+		}
+		return &DNSRecordSRV{
+			Priority: binary.BigEndian.Uint16(data[0:2]),
+			Weight:   binary.BigEndian.Uint16(data[2:4]),
+			Port:     binary.BigEndian.Uint16(data[4:6]),
+			Target:   target,
+		}, nil
+
+	case DNS_TYPE_TXT:
+		strs := []string{}
+		for offset := 0; offset < len(data); {
+			length := int(data[offset])
+			if offset+1+length > len(data) {
+				return nil, fmt.Errorf("TXT string is truncated")
+			}
+			strs = append(strs, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+		return &DNSRecordTXT{Strings: strs}, nil
+
+	case DNS_TYPE_SOA:
+		if len(data) < 20 {
+			return nil, fmt.Errorf("SOA data is too short")
+		}
+		soa := &DNSRecordSOA{
+			SerialNumber: binary.BigEndian.Uint32(data[0:4]),
+			Refresh:      binary.BigEndian.Uint32(data[4:8]),
+			Retry:        binary.BigEndian.Uint32(data[8:12]),
+			Expire:       binary.BigEndian.Uint32(data[12:16]),
+			MinimumTTL:   binary.BigEndian.Uint32(data[16:20]),
+		}
+		primaryServer, length, err := parseDNSCountName(data[20:])
+		if err != nil {
+			return nil, err
+		}
+		zoneAdminMail, _, err := parseDNSCountName(data[20+length:])
+		if err != nil {
+			return nil, err
+		}
+		soa.PrimaryServer = primaryServer
+		soa.ZoneAdminMail = zoneAdminMail
+		return soa, nil
+
+	case DNS_TYPE_ZERO:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("invalid tombstone length %d", len(data))
+		}
+		var fileTime data_structures.FILETIME
+		if _, err := fileTime.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return &DNSRecordTombstone{EntombedTime: fileTime.GetTime().UTC()}, nil
+	}
+
+	return &DNSRecordRaw{Bytes: append([]byte{}, data...)}, nil
+}
+
+// marshalDNSCountName encodes a name as a DNS_COUNT_NAME: the length of the labels, the number of labels, then
+// each label preceded by its length and a terminating empty label.
+// Src: [MS-DNSP] 2.2.2.2.2 DNS_COUNT_NAME
+func marshalDNSCountName(name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	labels := []string{}
+	if len(name) != 0 {
+		labels = strings.Split(name, ".")
+	}
+
+	rawName := []byte{}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid label %q in name %s", label, name)
+		}
+		rawName = append(append(rawName, byte(len(label))), label...)
+	}
+	rawName = append(rawName, 0)
+	if len(rawName) > 0xff {
+		return nil, fmt.Errorf("name %s is too long", name)
+	}
+
+	return append([]byte{byte(len(rawName)), byte(len(labels))}, rawName...), nil
+}
+
+// parseDNSCountName decodes a DNS_COUNT_NAME, returning the fully qualified name with its trailing dot and the
+// number of bytes read.
+func parseDNSCountName(data []byte) (string, int, error) {
+	if len(data) < 2 {
+		return "", 0, fmt.Errorf("DNS_COUNT_NAME is too short")
+	}
+	length := int(data[0])
+	labelCount := int(data[1])
+	if len(data) < 2+length {
+		return "", 0, fmt.Errorf("DNS_COUNT_NAME of %d bytes is truncated", length)
+	}
+
+	rawName := data[2 : 2+length]
+	labels := []string{}
+	offset := 0
+	for i := 0; i < labelCount; i++ {
+		if offset >= len(rawName) {
+			return "", 0, fmt.Errorf("DNS_COUNT_NAME label %d is truncated", i)
+		}
+		labelLength := int(rawName[offset])
+		if offset+1+labelLength > len(rawName) {
+			return "", 0, fmt.Errorf("DNS_COUNT_NAME label %d is truncated", i)
+		}
+		labels = append(labels, string(rawName[offset+1:offset+1+labelLength]))
+		offset += 1 + labelLength
+	}
+
+	return strings.Join(labels, ".") + ".", 2 + length, nil
+}
@@ -0,0 +1,110 @@
+package ldap_attributes_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+)
+
+func TestDNSRecordRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name       string
+		recordType uint16
+		data       ldap_attributes.DNSRecordData
+		expected   string
+	}{
+		{name: "A", recordType: ldap_attributes.DNS_TYPE_A, data: &ldap_attributes.DNSRecordAddress{IP: net.ParseIP("192.168.56.10")}, expected: "A 180 192.168.56.10"},
+		{name: "AAAA", recordType: ldap_attributes.DNS_TYPE_AAAA, data: &ldap_attributes.DNSRecordAddress{IP: net.ParseIP("fe80::1")}, expected: "AAAA 180 fe80::1"},
+		{name: "CNAME", recordType: ldap_attributes.DNS_TYPE_CNAME, data: &ldap_attributes.DNSRecordName{Name: "dc01.lab.local."}, expected: "CNAME 180 dc01.lab.local."},
+		{name: "NS", recordType: ldap_attributes.DNS_TYPE_NS, data: &ldap_attributes.DNSRecordName{Name: "dc01.lab.local."}, expected: "NS 180 dc01.lab.local."},
+		{name: "SRV", recordType: ldap_attributes.DNS_TYPE_SRV, data: &ldap_attributes.DNSRecordSRV{Priority: 0, Weight: 100, Port: 389, Target: "dc01.lab.local."}, expected: "SRV 180 0 100 389 dc01.lab.local."},
+		{name: "TXT", recordType: ldap_attributes.DNS_TYPE_TXT, data: &ldap_attributes.DNSRecordTXT{Strings: []string{"v=spf1 -all", "hello"}}, expected: "TXT 180 \"v=spf1 -all\" \"hello\""},
+		{name: "SOA", recordType: ldap_attributes.DNS_TYPE_SOA, data: &ldap_attributes.DNSRecordSOA{SerialNumber: 42, Refresh: 900, Retry: 600, Expire: 86400, MinimumTTL: 3600, PrimaryServer: "dc01.lab.local.", ZoneAdminMail: "hostmaster.lab.local."}, expected: "SOA 180 dc01.lab.local. hostmaster.lab.local. 42 900 600 86400 3600"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := ldap_attributes.NewDNSRecord(tc.recordType, tc.data, 7, 180).Marshal()
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			record, err := ldap_attributes.ParseDNSRecord(value)
+			if err != nil {
+				t.Fatalf("ParseDNSRecord() error = %v", err)
+			}
+			if record.Type != tc.recordType || record.Serial != 7 || record.Rank != ldap_attributes.DNS_RANK_ZONE {
+				t.Errorf("ParseDNSRecord() = type %d serial %d rank %d", record.Type, record.Serial, record.Rank)
+			}
+			if record.String() != tc.expected {
+				t.Errorf("String() = %q, want %q", record.String(), tc.expected)
+			}
+
+			remarshaled, err := record.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if !bytes.Equal(remarshaled, value) {
+				t.Errorf("Marshal() = %x, want %x", remarshaled, value)
+			}
+		})
+	}
+}
+
+func TestDNSRecordEncoding(t *testing.T) {
+	value, err := ldap_attributes.NewDNSRecord(ldap_attributes.DNS_TYPE_CNAME, &ldap_attributes.DNSRecordName{Name: "dc01.lab"}, 1, 0x012c).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	expected := []byte{
+		// DataLength, Type, Version, Rank, Flags, Serial
+		0x0c, 0x00, 0x05, 0x00, 0x05, 0xf0, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+		// TTL in network byte order, Reserved, TimeStamp
+		0x00, 0x00, 0x01, 0x2c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// DNS_COUNT_NAME: Length, LabelCount, labels and terminating empty label
+		0x0a, 0x02, 0x04, 'd', 'c', '0', '1', 0x03, 'l', 'a', 'b', 0x00,
+	}
+	if !bytes.Equal(value, expected) {
+		t.Errorf("Marshal() = %x, want %x", value, expected)
+	}
+}
+
+func TestDNSRecordTombstone(t *testing.T) {
+	value, err := ldap_attributes.NewDNSTombstoneRecord(3).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	record, err := ldap_attributes.ParseDNSRecord(value)
+	if err != nil {
+		t.Fatalf("ParseDNSRecord() error = %v", err)
+	}
+	if !record.IsTombstone() {
+		t.Errorf("IsTombstone() = false, want true")
+	}
+	tombstone, ok := record.Data.(*ldap_attributes.DNSRecordTombstone)
+	if !ok {
+		t.Fatalf("Data is %T, want *DNSRecordTombstone", record.Data)
+	}
+	if time.Since(tombstone.EntombedTime) > time.Minute {
+		t.Errorf("EntombedTime = %v, want the current time", tombstone.EntombedTime)
+	}
+}
+
+func TestParseDNSRecordTruncated(t *testing.T) {
+	value, err := ldap_attributes.NewDNSRecord(ldap_attributes.DNS_TYPE_A, &ldap_attributes.DNSRecordAddress{IP: net.ParseIP("10.0.0.1")}, 1, 180).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if _, err := ldap_attributes.ParseDNSRecord(value[:len(value)-1]); err == nil {
+		t.Errorf("ParseDNSRecord() error = nil, want an error for a truncated record")
+	}
+	if _, err := ldap_attributes.ParseDNSRecord(value[:10]); err == nil {
+		t.Errorf("ParseDNSRecord() error = nil, want an error for a truncated header")
+	}
+}
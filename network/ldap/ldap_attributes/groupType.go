@@ -0,0 +1,64 @@
+package ldap_attributes
+
+import (
+	"sort"
+	"strings"
+)
+
+type GroupType uint32
+
+// groupType
+// Src: https://learn.microsoft.com/en-us/windows/win32/adschema/a-grouptype
+const (
+	GROUP_TYPE_BUILTIN_LOCAL_GROUP GroupType = 0x00000001
+	GROUP_TYPE_ACCOUNT_GROUP       GroupType = 0x00000002
+	GROUP_TYPE_RESOURCE_GROUP      GroupType = 0x00000004
+	GROUP_TYPE_UNIVERSAL_GROUP     GroupType = 0x00000008
+	GROUP_TYPE_APP_BASIC_GROUP     GroupType = 0x00000010
+	GROUP_TYPE_APP_QUERY_GROUP     GroupType = 0x00000020
+	GROUP_TYPE_SECURITY_ENABLED    GroupType = 0x80000000
+)
+
+var GroupTypeMap = map[GroupType]string{
+	GROUP_TYPE_BUILTIN_LOCAL_GROUP: "BUILTIN_LOCAL_GROUP",
+	GROUP_TYPE_ACCOUNT_GROUP:       "ACCOUNT_GROUP",
+	GROUP_TYPE_RESOURCE_GROUP:      "RESOURCE_GROUP",
+	GROUP_TYPE_UNIVERSAL_GROUP:     "UNIVERSAL_GROUP",
+	GROUP_TYPE_APP_BASIC_GROUP:     "APP_BASIC_GROUP",
+	GROUP_TYPE_APP_QUERY_GROUP:     "APP_QUERY_GROUP",
+	GROUP_TYPE_SECURITY_ENABLED:    "SECURITY_ENABLED",
+}
+
+// String returns the names of the flags set in the groupType value, sorted and separated by "|".
+func (groupType GroupType) String() string {
+	flagsString := []string{}
+	for flag, val := range GroupTypeMap {
+		if groupType&flag != 0 {
+			flagsString = append(flagsString, val)
+		}
+	}
+
+	sort.Strings(flagsString)
+
+	return strings.Join(flagsString, "|")
+}
+
+// IsSecurityGroup returns true if the group is a security group, false if it is a distribution group.
+func (groupType GroupType) IsSecurityGroup() bool {
+	return groupType&GROUP_TYPE_SECURITY_ENABLED != 0
+}
+
+// Scope returns the scope of the group: "Global", "DomainLocal", "Universal", "BuiltinLocal" or "" if unknown.
+func (groupType GroupType) Scope() string {
+	switch {
+	case groupType&GROUP_TYPE_BUILTIN_LOCAL_GROUP != 0:
+		return "BuiltinLocal"
+	case groupType&GROUP_TYPE_ACCOUNT_GROUP != 0:
+		return "Global"
+	case groupType&GROUP_TYPE_RESOURCE_GROUP != 0:
+		return "DomainLocal"
+	case groupType&GROUP_TYPE_UNIVERSAL_GROUP != 0:
+		return "Universal"
+	}
+	return ""
+}
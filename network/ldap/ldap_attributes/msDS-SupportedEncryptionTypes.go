@@ -0,0 +1,50 @@
+package ldap_attributes
+
+import (
+	"sort"
+	"strings"
+)
+
+type SupportedEncryptionTypes uint32
+
+// msDS-SupportedEncryptionTypes Attribute
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-kile/6cfc7b50-11ed-4b4d-846d-6f08f0812919
+const (
+	SUPPORTED_ENCRYPTION_TYPE_DES_CBC_CRC                       SupportedEncryptionTypes = 0x00000001
+	SUPPORTED_ENCRYPTION_TYPE_DES_CBC_MD5                       SupportedEncryptionTypes = 0x00000002
+	SUPPORTED_ENCRYPTION_TYPE_RC4_HMAC                          SupportedEncryptionTypes = 0x00000004
+	SUPPORTED_ENCRYPTION_TYPE_AES128_CTS_HMAC_SHA1_96           SupportedEncryptionTypes = 0x00000008
+	SUPPORTED_ENCRYPTION_TYPE_AES256_CTS_HMAC_SHA1_96           SupportedEncryptionTypes = 0x00000010
+	SUPPORTED_ENCRYPTION_TYPE_AES256_CTS_HMAC_SHA1_96_SK        SupportedEncryptionTypes = 0x00000020
+	SUPPORTED_ENCRYPTION_TYPE_FAST_SUPPORTED                    SupportedEncryptionTypes = 0x00010000
+	SUPPORTED_ENCRYPTION_TYPE_COMPOUND_IDENTITY_SUPPORTED       SupportedEncryptionTypes = 0x00020000
+	SUPPORTED_ENCRYPTION_TYPE_CLAIMS_SUPPORTED                  SupportedEncryptionTypes = 0x00040000
+	SUPPORTED_ENCRYPTION_TYPE_RESOURCE_SID_COMPRESSION_DISABLED SupportedEncryptionTypes = 0x00080000
+)
+
+var SupportedEncryptionTypesMap = map[SupportedEncryptionTypes]string{
+	SUPPORTED_ENCRYPTION_TYPE_DES_CBC_CRC:                       "DES_CBC_CRC",
+	SUPPORTED_ENCRYPTION_TYPE_DES_CBC_MD5:                       "DES_CBC_MD5",
+	SUPPORTED_ENCRYPTION_TYPE_RC4_HMAC:                          "RC4_HMAC",
+	SUPPORTED_ENCRYPTION_TYPE_AES128_CTS_HMAC_SHA1_96:           "AES128_CTS_HMAC_SHA1_96",
+	SUPPORTED_ENCRYPTION_TYPE_AES256_CTS_HMAC_SHA1_96:           "AES256_CTS_HMAC_SHA1_96",
+	SUPPORTED_ENCRYPTION_TYPE_AES256_CTS_HMAC_SHA1_96_SK:        "AES256_CTS_HMAC_SHA1_96_SK",
+	SUPPORTED_ENCRYPTION_TYPE_FAST_SUPPORTED:                    "FAST_SUPPORTED",
+	SUPPORTED_ENCRYPTION_TYPE_COMPOUND_IDENTITY_SUPPORTED:       "COMPOUND_IDENTITY_SUPPORTED",
+	SUPPORTED_ENCRYPTION_TYPE_CLAIMS_SUPPORTED:                  "CLAIMS_SUPPORTED",
+	SUPPORTED_ENCRYPTION_TYPE_RESOURCE_SID_COMPRESSION_DISABLED: "RESOURCE_SID_COMPRESSION_DISABLED",
+}
+
+// String returns the names of the flags set in the msDS-SupportedEncryptionTypes value, sorted and separated by "|".
+func (encryptionTypes SupportedEncryptionTypes) String() string {
+	flagsString := []string{}
+	for flag, val := range SupportedEncryptionTypesMap {
+		if encryptionTypes&flag != 0 {
+			flagsString = append(flagsString, val)
+		}
+	}
+
+	sort.Strings(flagsString)
+
+	return strings.Join(flagsString, "|")
+}
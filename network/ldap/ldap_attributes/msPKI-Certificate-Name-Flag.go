@@ -1,5 +1,12 @@
 package ldap_attributes
 
+import (
+	"sort"
+	"strings"
+)
+
+type MSPKICertificateNameFlag uint32
+
 // msPKI-Certificate-Name-Flag Attribute
 // Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-crtd/1192823c-d839-4bc3-9b6b-fa8c53507ae1
 const (
@@ -17,3 +24,33 @@ const (
 	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_REQUIRE_DIRECTORY_PATH         = 0x80000000
 	MSPKI_CERTIFICATE_NAME_FLAG_OLD_CERT_SUPPLIES_SUBJECT_AND_ALT_NAME = 0x00000008
 )
+
+var MSPKICertificateNameFlagMap = map[MSPKICertificateNameFlag]string{
+	MSPKI_CERTIFICATE_NAME_FLAG_ENROLLEE_SUPPLIES_SUBJECT:              "ENROLLEE_SUPPLIES_SUBJECT",
+	MSPKI_CERTIFICATE_NAME_FLAG_ENROLLEE_SUPPLIES_SUBJECT_ALT_NAME:     "ENROLLEE_SUPPLIES_SUBJECT_ALT_NAME",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_ALT_REQUIRE_DOMAIN_DNS:         "SUBJECT_ALT_REQUIRE_DOMAIN_DNS",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_ALT_REQUIRE_SPN:                "SUBJECT_ALT_REQUIRE_SPN",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_ALT_REQUIRE_DIRECTORY_GUID:     "SUBJECT_ALT_REQUIRE_DIRECTORY_GUID",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_ALT_REQUIRE_UPN:                "SUBJECT_ALT_REQUIRE_UPN",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_ALT_REQUIRE_EMAIL:              "SUBJECT_ALT_REQUIRE_EMAIL",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_ALT_REQUIRE_DNS:                "SUBJECT_ALT_REQUIRE_DNS",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_REQUIRE_DNS_AS_CN:              "SUBJECT_REQUIRE_DNS_AS_CN",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_REQUIRE_EMAIL:                  "SUBJECT_REQUIRE_EMAIL",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_REQUIRE_COMMON_NAME:            "SUBJECT_REQUIRE_COMMON_NAME",
+	MSPKI_CERTIFICATE_NAME_FLAG_SUBJECT_REQUIRE_DIRECTORY_PATH:         "SUBJECT_REQUIRE_DIRECTORY_PATH",
+	MSPKI_CERTIFICATE_NAME_FLAG_OLD_CERT_SUPPLIES_SUBJECT_AND_ALT_NAME: "OLD_CERT_SUPPLIES_SUBJECT_AND_ALT_NAME",
+}
+
+// String returns the names of the flags set in the msPKI-Certificate-Name-Flag value, sorted and separated by "|".
+func (flags MSPKICertificateNameFlag) String() string {
+	flagsString := []string{}
+	for flag, val := range MSPKICertificateNameFlagMap {
+		if flags&flag != 0 {
+			flagsString = append(flagsString, val)
+		}
+	}
+
+	sort.Strings(flagsString)
+
+	return strings.Join(flagsString, "|")
+}
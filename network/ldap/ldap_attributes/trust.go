@@ -0,0 +1,106 @@
+package ldap_attributes
+
+import (
+	"sort"
+	"strings"
+)
+
+type TrustDirection uint32
+
+// trustDirection
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/5026a939-44ba-47b2-99cf-386a9e674b04
+const (
+	TRUST_DIRECTION_DISABLED      TrustDirection = 0x00000000
+	TRUST_DIRECTION_INBOUND       TrustDirection = 0x00000001
+	TRUST_DIRECTION_OUTBOUND      TrustDirection = 0x00000002
+	TRUST_DIRECTION_BIDIRECTIONAL TrustDirection = 0x00000003
+)
+
+var TrustDirectionMap = map[TrustDirection]string{
+	TRUST_DIRECTION_DISABLED:      "DISABLED",
+	TRUST_DIRECTION_INBOUND:       "INBOUND",
+	TRUST_DIRECTION_OUTBOUND:      "OUTBOUND",
+	TRUST_DIRECTION_BIDIRECTIONAL: "BIDIRECTIONAL",
+}
+
+func (trustDirection TrustDirection) String() string {
+	if _, ok := TrustDirectionMap[trustDirection]; ok {
+		return TrustDirectionMap[trustDirection]
+	}
+	return ""
+}
+
+type TrustType uint32
+
+// trustType
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/36565693-b5e4-4f37-b0a8-c1b12138e18e
+const (
+	TRUST_TYPE_DOWNLEVEL TrustType = 0x00000001
+	TRUST_TYPE_UPLEVEL   TrustType = 0x00000002
+	TRUST_TYPE_MIT       TrustType = 0x00000003
+	TRUST_TYPE_DCE       TrustType = 0x00000004
+	TRUST_TYPE_AAD       TrustType = 0x00000005
+)
+
+var TrustTypeMap = map[TrustType]string{
+	TRUST_TYPE_DOWNLEVEL: "DOWNLEVEL",
+	TRUST_TYPE_UPLEVEL:   "UPLEVEL",
+	TRUST_TYPE_MIT:       "MIT",
+	TRUST_TYPE_DCE:       "DCE",
+	TRUST_TYPE_AAD:       "AAD",
+}
+
+func (trustType TrustType) String() string {
+	if _, ok := TrustTypeMap[trustType]; ok {
+		return TrustTypeMap[trustType]
+	}
+	return ""
+}
+
+type TrustAttributes uint32
+
+// trustAttributes
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/e9a2d23c-c31e-4a6f-88a0-6646fdb51a3c
+const (
+	TRUST_ATTRIBUTE_NON_TRANSITIVE                           TrustAttributes = 0x00000001
+	TRUST_ATTRIBUTE_UPLEVEL_ONLY                             TrustAttributes = 0x00000002
+	TRUST_ATTRIBUTE_QUARANTINED_DOMAIN                       TrustAttributes = 0x00000004
+	TRUST_ATTRIBUTE_FOREST_TRANSITIVE                        TrustAttributes = 0x00000008
+	TRUST_ATTRIBUTE_CROSS_ORGANIZATION                       TrustAttributes = 0x00000010
+	TRUST_ATTRIBUTE_WITHIN_FOREST                            TrustAttributes = 0x00000020
+	TRUST_ATTRIBUTE_TREAT_AS_EXTERNAL                        TrustAttributes = 0x00000040
+	TRUST_ATTRIBUTE_USES_RC4_ENCRYPTION                      TrustAttributes = 0x00000080
+	TRUST_ATTRIBUTE_CROSS_ORGANIZATION_NO_TGT_DELEGATION     TrustAttributes = 0x00000200
+	TRUST_ATTRIBUTE_PIM_TRUST                                TrustAttributes = 0x00000400
+	TRUST_ATTRIBUTE_CROSS_ORGANIZATION_ENABLE_TGT_DELEGATION TrustAttributes = 0x00000800
+	TRUST_ATTRIBUTE_DISABLE_AUTH_TARGET_VALIDATION           TrustAttributes = 0x00001000
+)
+
+var TrustAttributesMap = map[TrustAttributes]string{
+	TRUST_ATTRIBUTE_NON_TRANSITIVE:                           "NON_TRANSITIVE",
+	TRUST_ATTRIBUTE_UPLEVEL_ONLY:                             "UPLEVEL_ONLY",
+	TRUST_ATTRIBUTE_QUARANTINED_DOMAIN:                       "QUARANTINED_DOMAIN",
+	TRUST_ATTRIBUTE_FOREST_TRANSITIVE:                        "FOREST_TRANSITIVE",
+	TRUST_ATTRIBUTE_CROSS_ORGANIZATION:                       "CROSS_ORGANIZATION",
+	TRUST_ATTRIBUTE_WITHIN_FOREST:                            "WITHIN_FOREST",
+	TRUST_ATTRIBUTE_TREAT_AS_EXTERNAL:                        "TREAT_AS_EXTERNAL",
+	TRUST_ATTRIBUTE_USES_RC4_ENCRYPTION:                      "USES_RC4_ENCRYPTION",
+	TRUST_ATTRIBUTE_CROSS_ORGANIZATION_NO_TGT_DELEGATION:     "CROSS_ORGANIZATION_NO_TGT_DELEGATION",
+	TRUST_ATTRIBUTE_PIM_TRUST:                                "PIM_TRUST",
+	TRUST_ATTRIBUTE_CROSS_ORGANIZATION_ENABLE_TGT_DELEGATION: "CROSS_ORGANIZATION_ENABLE_TGT_DELEGATION",
+	TRUST_ATTRIBUTE_DISABLE_AUTH_TARGET_VALIDATION:           "DISABLE_AUTH_TARGET_VALIDATION",
+}
+
+// String returns the names of the flags set in the trustAttributes value, sorted and separated by "|".
+func (trustAttributes TrustAttributes) String() string {
+	flagsString := []string{}
+	for flag, val := range TrustAttributesMap {
+		if trustAttributes&flag != 0 {
+			flagsString = append(flagsString, val)
+		}
+	}
+
+	sort.Strings(flagsString)
+
+	return strings.Join(flagsString, "|")
+}
@@ -0,0 +1,336 @@
+package ldap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// LDAP_MATCHING_RULE_IN_CHAIN is the OID of the matching rule walking the chain of ancestry of the objects up to
+// the root, which resolves nested group memberships on the server side.
+// Src: https://learn.microsoft.com/en-us/windows/win32/adsi/search-filter-syntax
+const LDAP_MATCHING_RULE_IN_CHAIN = "1.2.840.113556.1.4.1941"
+
+// membershipAttributes are the attributes read for the groups and members of a membership.
+var membershipAttributes = []string{"distinguishedName", "name", "objectClass", "objectSid"}
+
+// MembershipPrincipal is a group or a member resolved while walking group memberships.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the principal, or an empty string for a primary group which
+//     cannot be read.
+//   - Name: The name of the principal.
+//   - SID: The SID of the principal, or nil if it has no objectSid.
+//   - ObjectClass: The classes of the principal.
+//   - Primary: True if the membership goes through the primary group of a principal, set in its primaryGroupID
+//     attribute instead of the member attribute of the group.
+//   - Foreign: True if the principal is a foreign security principal, standing for a principal of a trusted domain.
+type MembershipPrincipal struct {
+	DistinguishedName string
+	Name              string
+	SID               *sid.SID
+	ObjectClass       []string
+	Primary           bool
+	Foreign           bool
+}
+
+// newMembershipPrincipal creates a MembershipPrincipal from an LDAP entry read with membershipAttributes.
+func newMembershipPrincipal(entry *Entry, primary bool) *MembershipPrincipal {
+	principal := &MembershipPrincipal{
+		DistinguishedName: entry.DN,
+		Name:              entry.GetAttributeValue("name"),
+		ObjectClass:       entry.GetAttributeValues("objectClass"),
+		Primary:           primary,
+	}
+	if value := entry.GetAttributeValue("distinguishedName"); len(value) != 0 {
+		principal.DistinguishedName = value
+	}
+	if data := entry.GetRawAttributeValue("objectSid"); len(data) != 0 {
+		principal.SID, _ = sid.FromBytes(data)
+	}
+	for _, objectClass := range principal.ObjectClass {
+		if strings.EqualFold(objectClass, "foreignSecurityPrincipal") {
+			principal.Foreign = true
+		}
+	}
+	return principal
+}
+
+// IsGroup checks if the principal is a group.
+//
+// Returns:
+//   - True if "group" is one of the classes of the principal, false otherwise.
+func (principal *MembershipPrincipal) IsGroup() bool {
+	for _, objectClass := range principal.ObjectClass {
+		if strings.EqualFold(objectClass, "group") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRecursiveGroups retrieves all the groups an object is a member of, directly or through nested groups.
+//
+// The groups are resolved on the server with the LDAP_MATCHING_RULE_IN_CHAIN matching rule on the member
+// attribute. The primary group of the object, set in its primaryGroupID attribute, and the groups containing it
+// are added with the Primary field set, as the member attribute of a group does not list the principals for which
+// it is the primary group.
+//
+// Parameters:
+//   - distinguishedName: The distinguished name of the object, such as a user or a computer.
+//
+// Returns:
+//   - A slice of pointers to the MembershipPrincipal of the groups, sorted by distinguished name. A primary
+//     group which cannot be read is returned with an empty distinguished name and the name of its RID.
+//   - An error if the object cannot be read or if an LDAP query fails.
+//
+// Example:
+//
+//	groups, err := ldapSession.GetRecursiveGroups("CN=alice,CN=Users,DC=lab,DC=local")
+//	for _, group := range groups {
+//	    fmt.Println(group.Name, group.SID.String())
+//	}
+func (ldapSession *Session) GetRecursiveGroups(distinguishedName string) ([]*MembershipPrincipal, error) {
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", []string{"objectSid", "primaryGroupID"})
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("object %s not found", distinguishedName)
+	}
+	object := ldapResults[0]
+
+	groups := make(map[string]*MembershipPrincipal)
+	if err := ldapSession.getGroupsInChain(distinguishedName, false, groups); err != nil {
+		return nil, err
+	}
+
+	primaryGroupID, err := strconv.ParseUint(object.GetAttributeValue("primaryGroupID"), 10, 32)
+	objectSID, sidErr := sid.FromBytes(object.GetRawAttributeValue("objectSid"))
+	if err == nil && primaryGroupID != 0 && sidErr == nil {
+		primaryGroup, err := ldapSession.getPrimaryGroup(objectSID, uint32(primaryGroupID))
+		if err != nil {
+			return nil, err
+		}
+		groups[strings.ToLower(primaryGroup.DistinguishedName)] = primaryGroup
+		if len(primaryGroup.DistinguishedName) != 0 {
+			if err := ldapSession.getGroupsInChain(primaryGroup.DistinguishedName, true, groups); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sortMembershipPrincipals(groups), nil
+}
+
+// GetTokenGroups retrieves the SIDs of all the groups of an object from its constructed tokenGroups attribute.
+//
+// The SIDs are computed by the domain controller as for the access token of the object, with the nested groups,
+// the primary group and the SID history of the groups. The universal groups of other domains are only included
+// when the domain controller is a global catalog.
+//
+// Parameters:
+//   - distinguishedName: The distinguished name of the object, such as a user or a computer.
+//
+// Returns:
+//   - A slice of pointers to the SIDs of the groups, in the order returned by the server.
+//   - An error if the object cannot be read or if a SID is malformed.
+func (ldapSession *Session) GetTokenGroups(distinguishedName string) ([]*sid.SID, error) {
+	return ldapSession.getConstructedSIDs(distinguishedName, "tokenGroups")
+}
+
+// GetTokenGroupsGlobalAndUniversal retrieves the SIDs of the global and universal groups of an object from its
+// constructed tokenGroupsGlobalAndUniversal attribute, which are the groups included in the tickets issued for the
+// object to the other domains of the forest.
+//
+// Parameters:
+//   - distinguishedName: The distinguished name of the object, such as a user or a computer.
+//
+// Returns:
+//   - A slice of pointers to the SIDs of the groups, in the order returned by the server.
+//   - An error if the object cannot be read or if a SID is malformed.
+func (ldapSession *Session) GetTokenGroupsGlobalAndUniversal(distinguishedName string) ([]*sid.SID, error) {
+	return ldapSession.getConstructedSIDs(distinguishedName, "tokenGroupsGlobalAndUniversal")
+}
+
+// GetForeignSecurityPrincipalGroups retrieves the groups of the domain which principals of trusted domains are
+// members of, directly or through nested groups.
+//
+// A principal of a trusted domain is added to a group of the domain through a foreignSecurityPrincipal object
+// named after its SID, in the ForeignSecurityPrincipals container. To get the groups of a user of a trusted domain,
+// pass the SID of the user with the SIDs of its token groups read in its own domain.
+//
+// Parameters:
+//   - SIDs: A slice of strings holding the SIDs of the principals of the trusted domains.
+//
+// Returns:
+//   - A slice of pointers to the MembershipPrincipal of the groups, sorted by distinguished name.
+//   - An error if a SID is malformed or if an LDAP query fails.
+func (ldapSession *Session) GetForeignSecurityPrincipalGroups(SIDs []string) ([]*MembershipPrincipal, error) {
+	groups := make(map[string]*MembershipPrincipal)
+	for _, SID := range SIDs {
+		foreignSID, err := sid.FromString(SID)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SID %s: %w", SID, err)
+		}
+
+		query := fmt.Sprintf("(&(objectClass=foreignSecurityPrincipal)(objectSid=%s))", foreignSID.String())
+		ldapResults, err := ldapSession.QueryWholeSubtree("defaultNamingContext", query, []string{"distinguishedName"})
+		if err != nil {
+			return nil, fmt.Errorf("error querying LDAP: %w", err)
+		}
+		for _, entry := range ldapResults {
+			if err := ldapSession.getGroupsInChain(entry.DN, false, groups); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sortMembershipPrincipals(groups), nil
+}
+
+// GetRecursiveGroupMembers retrieves all the members of a group, directly or through nested groups.
+//
+// The members are resolved on the server with the LDAP_MATCHING_RULE_IN_CHAIN matching rule on the memberOf
+// attribute, and include the nested groups themselves. The principals whose primary group is the group or one of
+// its nested groups are added with the Primary field set. The principals of trusted domains are returned as
+// foreign security principals with the Foreign field set.
+//
+// Parameters:
+//   - distinguishedName: The distinguished name of the group.
+//
+// Returns:
+//   - A slice of pointers to the MembershipPrincipal of the members, sorted by distinguished name.
+//   - An error if the group cannot be read or if an LDAP query fails.
+func (ldapSession *Session) GetRecursiveGroupMembers(distinguishedName string) ([]*MembershipPrincipal, error) {
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", membershipAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("group %s not found", distinguishedName)
+	}
+	group := newMembershipPrincipal(ldapResults[0], false)
+
+	query := fmt.Sprintf("(memberOf:%s:=%s)", LDAP_MATCHING_RULE_IN_CHAIN, goldapv3.EscapeFilter(distinguishedName))
+	ldapResults, err = ldapSession.QueryWholeSubtree("defaultNamingContext", query, membershipAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	members := make(map[string]*MembershipPrincipal)
+	groups := []*MembershipPrincipal{group}
+	for _, entry := range ldapResults {
+		member := newMembershipPrincipal(entry, false)
+		members[strings.ToLower(member.DistinguishedName)] = member
+		if member.IsGroup() {
+			groups = append(groups, member)
+		}
+	}
+
+	// The primaryGroupID attribute holds the RID of the primary group in the domain of the principal
+	for _, group := range groups {
+		if group.SID == nil || !group.SID.IsDomainAccountSID() {
+			continue
+		}
+		query := fmt.Sprintf("(primaryGroupID=%d)", group.SID.GetRID())
+		ldapResults, err := ldapSession.QueryWholeSubtree("defaultNamingContext", query, membershipAttributes)
+		if err != nil {
+			return nil, fmt.Errorf("error querying LDAP: %w", err)
+		}
+		for _, entry := range ldapResults {
+			member := newMembershipPrincipal(entry, true)
+			if member.SID == nil || !member.SID.IsInDomain(group.SID.GetDomainSID()) {
+				continue
+			}
+			if _, ok := members[strings.ToLower(member.DistinguishedName)]; !ok {
+				members[strings.ToLower(member.DistinguishedName)] = member
+			}
+		}
+	}
+
+	return sortMembershipPrincipals(members), nil
+}
+
+// getGroupsInChain adds the groups containing an object, directly or through nested groups, to a map keyed by
+// lowercase distinguished names.
+func (ldapSession *Session) getGroupsInChain(distinguishedName string, primary bool, groups map[string]*MembershipPrincipal) error {
+	query := fmt.Sprintf("(&(objectClass=group)(member:%s:=%s))", LDAP_MATCHING_RULE_IN_CHAIN, goldapv3.EscapeFilter(distinguishedName))
+	ldapResults, err := ldapSession.QueryWholeSubtree("defaultNamingContext", query, membershipAttributes)
+	if err != nil {
+		return fmt.Errorf("error querying LDAP: %w", err)
+	}
+	for _, entry := range ldapResults {
+		group := newMembershipPrincipal(entry, primary)
+		if _, ok := groups[strings.ToLower(group.DistinguishedName)]; !ok {
+			groups[strings.ToLower(group.DistinguishedName)] = group
+		}
+	}
+	return nil
+}
+
+// getPrimaryGroup reads the primary group of an object from its RID, relative to the domain of the object. A group
+// which cannot be found is returned with an empty distinguished name and the name of the predefined RID, if any.
+func (ldapSession *Session) getPrimaryGroup(objectSID *sid.SID, primaryGroupID uint32) (*MembershipPrincipal, error) {
+	primaryGroupSID := sid.FromDomainSIDAndRID(objectSID.GetDomainSID(), primaryGroupID)
+
+	query := fmt.Sprintf("(objectSid=%s)", primaryGroupSID.String())
+	ldapResults, err := ldapSession.QueryWholeSubtree("defaultNamingContext", query, membershipAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) != 0 {
+		return newMembershipPrincipal(ldapResults[0], true), nil
+	}
+
+	return &MembershipPrincipal{
+		Name:        ldap_attributes.GetDomainRIDName(int(primaryGroupID)),
+		SID:         primaryGroupSID,
+		ObjectClass: []string{"top", "group"},
+		Primary:     true,
+	}, nil
+}
+
+// getConstructedSIDs reads a constructed attribute holding binary SIDs, which is only returned by base searches.
+func (ldapSession *Session) getConstructedSIDs(distinguishedName string, attribute string) ([]*sid.SID, error) {
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", []string{attribute})
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("object %s not found", distinguishedName)
+	}
+	return parseSIDValues(ldapResults[0].GetRawAttributeValues(attribute))
+}
+
+// parseSIDValues parses the binary SIDs of a multi-valued attribute.
+func parseSIDValues(values [][]byte) ([]*sid.SID, error) {
+	SIDs := []*sid.SID{}
+	for _, value := range values {
+		parsedSID, err := sid.FromBytes(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SID: %w", err)
+		}
+		SIDs = append(SIDs, parsedSID)
+	}
+	return SIDs, nil
+}
+
+// sortMembershipPrincipals returns the principals of a map sorted by distinguished name.
+func sortMembershipPrincipals(principals map[string]*MembershipPrincipal) []*MembershipPrincipal {
+	keys := make([]string, 0, len(principals))
+	for key := range principals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*MembershipPrincipal, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, principals[key])
+	}
+	return sorted
+}
@@ -0,0 +1,63 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestNewMembershipPrincipal(t *testing.T) {
+	foreignSID, _ := sid.FromString("S-1-5-21-4-5-6-1105")
+	entry := ldap.NewEntry("CN=S-1-5-21-4-5-6-1105,CN=ForeignSecurityPrincipals,DC=lab,DC=local", map[string][]string{
+		"name":        {"S-1-5-21-4-5-6-1105"},
+		"objectClass": {"top", "foreignSecurityPrincipal"},
+		"objectSid":   {string(foreignSID.ToBytes())},
+	})
+
+	principal := newMembershipPrincipal(entry, false)
+	if !principal.Foreign || principal.IsGroup() || principal.Primary {
+		t.Errorf("Unexpected flags for a foreign security principal: %+v", principal)
+	}
+	if principal.SID == nil || !principal.SID.Equal(foreignSID) {
+		t.Errorf("Expected SID %s, got %v", foreignSID.String(), principal.SID)
+	}
+
+	entry = ldap.NewEntry("CN=Domain Users,CN=Users,DC=lab,DC=local", map[string][]string{
+		"objectClass": {"top", "group"},
+	})
+	principal = newMembershipPrincipal(entry, true)
+	if principal.Foreign || !principal.IsGroup() || !principal.Primary || principal.SID != nil {
+		t.Errorf("Unexpected flags for a primary group: %+v", principal)
+	}
+}
+
+func TestParseSIDValues(t *testing.T) {
+	first, _ := sid.FromString("S-1-5-21-1-2-3-513")
+	second, _ := sid.FromString("S-1-5-32-545")
+
+	SIDs, err := parseSIDValues([][]byte{first.ToBytes(), second.ToBytes()})
+	if err != nil {
+		t.Fatalf("parseSIDValues error = %v", err)
+	}
+	if len(SIDs) != 2 || !SIDs[0].Equal(first) || !SIDs[1].Equal(second) {
+		t.Errorf("Unexpected SIDs %v", SIDs)
+	}
+
+	if _, err := parseSIDValues([][]byte{{0x01}}); err == nil {
+		t.Errorf("Expected an error for a malformed SID")
+	}
+}
+
+func TestSortMembershipPrincipals(t *testing.T) {
+	principals := map[string]*MembershipPrincipal{
+		"cn=b,dc=lab,dc=local": {DistinguishedName: "CN=b,DC=lab,DC=local"},
+		"cn=a,dc=lab,dc=local": {DistinguishedName: "CN=a,DC=lab,DC=local"},
+		"":                     {Name: "Domain Users"},
+	}
+
+	sorted := sortMembershipPrincipals(principals)
+	if len(sorted) != 3 || sorted[0].Name != "Domain Users" || sorted[1].DistinguishedName != "CN=a,DC=lab,DC=local" || sorted[2].DistinguishedName != "CN=b,DC=lab,DC=local" {
+		t.Errorf("Unexpected order %v", sorted)
+	}
+}
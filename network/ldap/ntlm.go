@@ -0,0 +1,239 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm"
+	nettls "github.com/TheManticoreProject/Manticore/network/tls"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// NTLMBindMechanism is the LDAP bind operation carrying an NTLM authentication.
+type NTLMBindMechanism int
+
+const (
+	// NTLM_BIND_DEFAULT uses a simple bind when a password is given, and a GSS-SPNEGO bind to pass the hash
+	NTLM_BIND_DEFAULT NTLMBindMechanism = iota
+	// NTLM_BIND_GSS_SPNEGO uses a SASL bind of mechanism GSS-SPNEGO, signing and sealing the subsequent LDAP
+	// messages unless the connection uses LDAPS
+	NTLM_BIND_GSS_SPNEGO
+	// NTLM_BIND_SICILY uses the Sicily bind of Active Directory, which does not protect the subsequent LDAP messages
+	NTLM_BIND_SICILY
+)
+
+// SASL mechanism negotiating Kerberos or NTLM. Active Directory accepts raw NTLMSSP messages as its tokens.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/1b17c8b2-b7d3-43d5-b06c-e3f44e89e5a5
+const saslMechanismGSSSPNEGO = "GSS-SPNEGO"
+
+// Authentication choices of the Sicily bind request
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/8b9dbfb2-5b6a-497a-a533-7e709cb9a982
+const (
+	sicilyNegotiate ber.Tag = 10
+	sicilyResponse  ber.Tag = 11
+)
+
+// Context-specific tag of the serverSaslCreds field of a bind response
+const bindResponseServerSaslCreds ber.Tag = 7
+
+// SetNTLMBindMechanism sets the bind operation used to authenticate with NTLM when Kerberos is not used.
+//
+// Parameters:
+//   - mechanism: The NTLMBindMechanism to use. NTLM_BIND_GSS_SPNEGO is required by the domain controllers
+//     enforcing LDAP signing on connections without TLS.
+func (s *Session) SetNTLMBindMechanism(mechanism NTLMBindMechanism) {
+	s.ntlmBindMechanism = mechanism
+}
+
+// useNTLM checks whether the session authenticates with an NTLM bind rather than a simple bind.
+func (s *Session) useNTLM() bool {
+	if s.ntlmBindMechanism != NTLM_BIND_DEFAULT {
+		return true
+	}
+	return len(s.credentials.GetPassword()) == 0 && s.credentials.CanPassTheHash()
+}
+
+// newNTLMClient creates the NTLM client authenticating with the credentials of the session.
+func (s *Session) newNTLMClient() (*ntlm.Client, error) {
+	return ntlm.NewClientWithCredentials(s.credentials)
+}
+
+// connectWithNTLM connects to the LDAP server and authenticates with an NTLM bind, before handing the
+// connection to the LDAP client. The bind is performed on the raw connection so that, once a security layer
+// is negotiated, the LDAP client only sees the unwrapped messages.
+func (s *Session) connectWithNTLM() (*ldap.Conn, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(s.host, strconv.Itoa(s.port)), ldap.DefaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to LDAP server: %s", err)
+	}
+	var tlsConn *tls.Conn
+	if s.useldaps {
+		tlsConn = tls.Client(conn, s.getTLSConfig())
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error connecting to LDAPS server: %s", err)
+		}
+		conn = tlsConn
+	} else if s.usestarttls {
+		if tlsConn, err = startTLS(conn, s.getTLSConfig()); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	client, err := s.newNTLMClient()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if tlsConn != nil {
+		// Bind the authentication to the TLS connection for the domain controllers enforcing channel binding
+		applicationData, err := nettls.GetTLSServerEndPoint(tlsConn.ConnectionState())
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		client.ChannelBindings = nettls.GetChannelBindingsHash(applicationData)
+	}
+	mechanism := s.ntlmBindMechanism
+	if mechanism == NTLM_BIND_DEFAULT {
+		mechanism = NTLM_BIND_GSS_SPNEGO
+	}
+	// Active Directory refuses SASL security layers inside TLS, and the Sicily bind does not provide one
+	if tlsConn != nil || mechanism == NTLM_BIND_SICILY {
+		client.NegotiateFlags &^= ntlm.NTLMSSP_NEGOTIATE_SIGN | ntlm.NTLMSSP_NEGOTIATE_SEAL
+	}
+
+	boundConn, err := ntlmBind(conn, client, mechanism)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error binding with NTLM: %w", err)
+	}
+
+	ldapConnection := ldap.NewConn(boundConn, tlsConn != nil)
+	ldapConnection.Start()
+	return ldapConnection, nil
+}
+
+// ntlmBind authenticates on a connection with an NTLM bind.
+//
+// Parameters:
+//   - conn: The net.Conn connected to the LDAP server, on which no LDAP message was exchanged yet.
+//   - client: The ntlm.Client holding the credentials and the requested NTLM flags.
+//   - mechanism: The NTLMBindMechanism to use, either NTLM_BIND_GSS_SPNEGO or NTLM_BIND_SICILY.
+//
+// Returns:
+//   - The net.Conn to use for the subsequent LDAP messages, which wraps them in SASL buffers if signing or
+//     sealing was negotiated.
+//   - An error if the bind fails.
+func ntlmBind(conn net.Conn, client *ntlm.Client, mechanism NTLMBindMechanism) (net.Conn, error) {
+	if err := conn.SetDeadline(time.Now().Add(ldap.DefaultTimeout)); err != nil {
+		return nil, err
+	}
+
+	negotiateMessage, err := client.Negotiate()
+	if err != nil {
+		return nil, fmt.Errorf("error creating NEGOTIATE message: %w", err)
+	}
+
+	if mechanism == NTLM_BIND_SICILY {
+		// The CHALLENGE message is returned in the matchedDN of a successful bind response
+		negotiate := ber.NewString(ber.ClassContext, ber.TypePrimitive, sicilyNegotiate, string(negotiateMessage), "Sicily Negotiate")
+		response, err := exchangeBindRequest(conn, 1, "", negotiate)
+		if err != nil {
+			return nil, err
+		}
+		authenticateMessage, err := client.Authenticate(response.Children[1].Children[1].ByteValue)
+		if err != nil {
+			return nil, fmt.Errorf("error processing CHALLENGE message: %w", err)
+		}
+
+		authenticate := ber.NewString(ber.ClassContext, ber.TypePrimitive, sicilyResponse, string(authenticateMessage), "Sicily Response")
+		if _, err = exchangeBindRequest(conn, 2, "", authenticate); err != nil {
+			return nil, err
+		}
+	} else {
+		response, err := exchangeBindRequest(conn, 1, "", newSASLCredentials(saslMechanismGSSSPNEGO, negotiateMessage))
+		if !ldap.IsErrorWithCode(err, ldap.LDAPResultSaslBindInProgress) {
+			if err == nil {
+				return nil, fmt.Errorf("server completed the bind without challenge")
+			}
+			return nil, err
+		}
+		var challengeMessage []byte
+		for _, child := range response.Children[1].Children[3:] {
+			if child.ClassType == ber.ClassContext && child.Tag == bindResponseServerSaslCreds {
+				challengeMessage = child.Data.Bytes()
+			}
+		}
+		authenticateMessage, err := client.Authenticate(challengeMessage)
+		if err != nil {
+			return nil, fmt.Errorf("error processing CHALLENGE message: %w", err)
+		}
+
+		if _, err = exchangeBindRequest(conn, 2, "", newSASLCredentials(saslMechanismGSSSPNEGO, authenticateMessage)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	if client.IsSigning() || client.IsSealing() {
+		return newSASLConn(conn, &ntlmSecurityLayer{client: client}), nil
+	}
+	return conn, nil
+}
+
+// newSASLCredentials creates the SaslCredentials authentication choice of a bind request.
+func newSASLCredentials(mechanism string, credentials []byte) *ber.Packet {
+	authentication := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "SASL Credentials")
+	authentication.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, mechanism, "SASL Mechanism"))
+	authentication.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(credentials), "Credentials"))
+	return authentication
+}
+
+// exchangeBindRequest sends a bind request on a raw connection and reads the bind response.
+//
+// Parameters:
+//   - conn: The net.Conn connected to the LDAP server.
+//   - messageID: The identifier of the LDAP message.
+//   - name: A string representing the name of the bind request.
+//   - authentication: The ber.Packet holding the authentication choice of the bind request.
+//
+// Returns:
+//   - The ber.Packet of the LDAP message holding the bind response.
+//   - An ldap.Error holding the result code if the bind did not succeed, or another error if the response is
+//     malformed. The packet is returned along with the ldap.Error.
+func exchangeBindRequest(conn net.Conn, messageID int64, name string, authentication *ber.Packet) (*ber.Packet, error) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationBindRequest, nil, "Bind Request")
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "User Name"))
+	request.AppendChild(authentication)
+	envelope.AppendChild(request)
+
+	if _, err := conn.Write(envelope.Bytes()); err != nil {
+		return nil, fmt.Errorf("error sending bind request: %w", err)
+	}
+	packet, err := ber.ReadPacket(conn)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bind response: %w", err)
+	}
+
+	if len(packet.Children) < 2 {
+		return nil, fmt.Errorf("malformed bind response")
+	}
+	response := packet.Children[1]
+	if response.ClassType != ber.ClassApplication || response.Tag != ldap.ApplicationBindResponse || len(response.Children) < 3 {
+		return nil, fmt.Errorf("unexpected response to bind request")
+	}
+	return packet, ldap.GetLDAPError(packet)
+}
@@ -0,0 +1,213 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// newTestChallengeMessage creates an NTLM CHALLENGE message accepting the flags of the client.
+func newTestChallengeMessage() []byte {
+	data := append([]byte{}, ntlm.NTLM_SIGNATURE...)
+	data = binary.LittleEndian.AppendUint32(data, ntlm.NTLM_CHALLENGE)
+	data = append(data, 0, 0, 0, 0, 56, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint32(data, ntlm.DefaultClientNegotiateFlags)
+	data = append(data, 1, 2, 3, 4, 5, 6, 7, 8)
+	data = append(data, make([]byte, 8)...)
+	data = append(data, 4, 0, 4, 0, 56, 0, 0, 0)
+	data = append(data, 10, 0, 0x63, 0x45, 0, 0, 0, 15)
+	return append(data, 0, 0, 0, 0)
+}
+
+// newTestBindResponse creates an LDAP message holding a bind response.
+func newTestBindResponse(messageID int64, resultCode int64, matchedDN string, serverSaslCreds []byte) []byte {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationBindResponse, nil, "Bind Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, resultCode, "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, matchedDN, "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	if serverSaslCreds != nil {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, bindResponseServerSaslCreds, string(serverSaslCreds), "Server SASL Credentials"))
+	}
+	envelope.AppendChild(response)
+	return envelope.Bytes()
+}
+
+// serveTestNTLMBind answers the two bind requests of an NTLM bind, returning the authentication choices received.
+func serveTestNTLMBind(t *testing.T, conn net.Conn, sicily bool, finalResultCode int64) []*ber.Packet {
+	t.Helper()
+
+	authentications := []*ber.Packet{}
+	for messageID := int64(1); messageID <= 2; messageID++ {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			t.Errorf("ReadPacket failed: %v", err)
+			return authentications
+		}
+		if id, ok := packet.Children[0].Value.(int64); !ok || id != messageID {
+			t.Errorf("Expected message ID %d, got %v", messageID, packet.Children[0].Value)
+		}
+		authentications = append(authentications, packet.Children[1].Children[2])
+
+		var response []byte
+		switch {
+		case messageID == 2:
+			response = newTestBindResponse(messageID, finalResultCode, "", nil)
+		case sicily:
+			response = newTestBindResponse(messageID, ldap.LDAPResultSuccess, string(newTestChallengeMessage()), nil)
+		default:
+			response = newTestBindResponse(messageID, ldap.LDAPResultSaslBindInProgress, "", newTestChallengeMessage())
+		}
+		if _, err := conn.Write(response); err != nil {
+			t.Errorf("Write failed: %v", err)
+		}
+	}
+	return authentications
+}
+
+func TestNTLMBindGSSSPNEGO(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	done := make(chan []*ber.Packet)
+	go func() { done <- serveTestNTLMBind(t, serverConn, false, ldap.LDAPResultSuccess) }()
+
+	client := ntlm.NewClientWithPassword("LAB", "alice", "Password123!", "")
+	boundConn, err := ntlmBind(clientConn, client, NTLM_BIND_GSS_SPNEGO)
+	if err != nil {
+		t.Fatalf("ntlmBind failed: %v", err)
+	}
+	authentications := <-done
+
+	for i, messageType := range []uint32{ntlm.NTLM_NEGOTIATE, ntlm.NTLM_AUTHENTICATE} {
+		authentication := authentications[i]
+		if authentication.ClassType != ber.ClassContext || authentication.Tag != 3 || len(authentication.Children) != 2 {
+			t.Fatalf("Expected SASL credentials, got tag %d", authentication.Tag)
+		}
+		if mechanism := authentication.Children[0].Value; mechanism != saslMechanismGSSSPNEGO {
+			t.Errorf("Expected mechanism %s, got %v", saslMechanismGSSSPNEGO, mechanism)
+		}
+		token := authentication.Children[1].ByteValue
+		if !bytes.HasPrefix(token, ntlm.NTLM_SIGNATURE) || binary.LittleEndian.Uint32(token[8:12]) != messageType {
+			t.Errorf("Expected NTLM message of type %d, got %x", messageType, token)
+		}
+	}
+
+	if _, ok := boundConn.(*saslConn); !ok {
+		t.Errorf("Expected the connection to be wrapped with the security layer, got %T", boundConn)
+	}
+	if !client.IsSealing() {
+		t.Error("Expected sealing to be negotiated")
+	}
+}
+
+func TestNTLMBindSicily(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	done := make(chan []*ber.Packet)
+	go func() { done <- serveTestNTLMBind(t, serverConn, true, ldap.LDAPResultSuccess) }()
+
+	client := ntlm.NewClientWithPassword("LAB", "alice", "Password123!", "")
+	client.NegotiateFlags &^= ntlm.NTLMSSP_NEGOTIATE_SIGN | ntlm.NTLMSSP_NEGOTIATE_SEAL
+	boundConn, err := ntlmBind(clientConn, client, NTLM_BIND_SICILY)
+	if err != nil {
+		t.Fatalf("ntlmBind failed: %v", err)
+	}
+	authentications := <-done
+
+	for i, tag := range []ber.Tag{sicilyNegotiate, sicilyResponse} {
+		if authentications[i].ClassType != ber.ClassContext || authentications[i].Tag != tag {
+			t.Errorf("Expected Sicily authentication of tag %d, got %d", tag, authentications[i].Tag)
+		}
+		if !bytes.HasPrefix(authentications[i].Data.Bytes(), ntlm.NTLM_SIGNATURE) {
+			t.Errorf("Expected NTLM message, got %x", authentications[i].Data.Bytes())
+		}
+	}
+	if boundConn != clientConn {
+		t.Errorf("Expected the connection not to be wrapped, got %T", boundConn)
+	}
+}
+
+func TestNTLMBindInvalidCredentials(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	go serveTestNTLMBind(t, serverConn, false, ldap.LDAPResultInvalidCredentials)
+
+	client := ntlm.NewClientWithPassword("LAB", "alice", "wrong", "")
+	_, err := ntlmBind(clientConn, client, NTLM_BIND_GSS_SPNEGO)
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("Expected invalid credentials error, got %v", err)
+	}
+}
+
+// xorSecurityLayer is a security layer inverting the bits of the data and prefixing it with a marker.
+type xorSecurityLayer struct{}
+
+func (xorSecurityLayer) Wrap(data []byte) ([]byte, error) {
+	wrapped := []byte{0xaa}
+	for _, b := range data {
+		wrapped = append(wrapped, ^b)
+	}
+	return wrapped, nil
+}
+
+func (xorSecurityLayer) Unwrap(data []byte) ([]byte, error) {
+	unwrapped := []byte{}
+	for _, b := range data[1:] {
+		unwrapped = append(unwrapped, ^b)
+	}
+	return unwrapped, nil
+}
+
+func TestSASLConn(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	conn := newSASLConn(clientConn, xorSecurityLayer{})
+	go func() {
+		if _, err := conn.Write([]byte("request")); err != nil {
+			t.Errorf("Write failed: %v", err)
+		}
+	}()
+
+	buffer := make([]byte, 12)
+	if _, err := io.ReadFull(serverConn, buffer); err != nil {
+		t.Fatalf("ReadFull failed: %v", err)
+	}
+	expected, _ := xorSecurityLayer{}.Wrap([]byte("request"))
+	if binary.BigEndian.Uint32(buffer[:4]) != 8 || !bytes.Equal(buffer[4:], expected) {
+		t.Errorf("Unexpected SASL buffer %x", buffer)
+	}
+
+	// Two messages in a single buffer, read in small chunks
+	go func() {
+		wrapped, _ := xorSecurityLayer{}.Wrap([]byte("response1response2"))
+		serverConn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(wrapped))), wrapped...))
+	}()
+	received := []byte{}
+	for len(received) < 18 {
+		chunk := make([]byte, 5)
+		n, err := conn.Read(chunk)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		received = append(received, chunk[:n]...)
+	}
+	if string(received) != "response1response2" {
+		t.Errorf("Expected response1response2, got %q", received)
+	}
+}
@@ -0,0 +1,287 @@
+package objects
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/network/ldap/schema"
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// CertificateTemplateAttributes are the attributes read for certificate templates.
+var CertificateTemplateAttributes = append(append([]string{}, ObjectAttributes...),
+	"displayName", "msPKI-Template-Schema-Version", "pKIExtendedKeyUsage", "msPKI-Certificate-Application-Policy",
+	"msPKI-Certificate-Name-Flag", "msPKI-Enrollment-Flag", "msPKI-Private-Key-Flag", "msPKI-RA-Signature",
+	"msPKI-RA-Application-Policies", "msPKI-RA-Policies", "msPKI-Certificate-Policy", "pKIExpirationPeriod",
+	"pKIOverlapPeriod", "nTSecurityDescriptor",
+)
+
+// Extended key usages allowing to authenticate to the domain with a certificate through PKINIT or Schannel
+var authenticationExtendedKeyUsages = []string{
+	ldap_attributes.EKU_CLIENT_AUTHENTICATION,
+	ldap_attributes.EKU_PKINIT_CLIENT_AUTHENTICATION,
+	ldap_attributes.EKU_SMART_CARD_LOGON,
+	ldap_attributes.EKU_ANY,
+}
+
+// CertificateTemplate is a certificate template of Active Directory Certificate Services, stored in the
+// Certificate Templates container of the configuration naming context.
+type CertificateTemplate struct {
+	Object
+
+	// DisplayName is the display name of the certificate template
+	DisplayName string
+	// SchemaVersion is the version of the template, from 1 to 4
+	SchemaVersion int64
+	// ExtendedKeyUsages are the OIDs of the pKIExtendedKeyUsage attribute
+	ExtendedKeyUsages []string
+	// ApplicationPolicies are the OIDs of the application policies of the issued certificates, which replace the
+	// extended key usages from schema version 2
+	ApplicationPolicies []string
+	// CertificateNameFlag holds the MSPKI_CERTIFICATE_NAME_FLAG_* flags of the subject of the issued certificates
+	CertificateNameFlag ldap_attributes.MSPKICertificateNameFlag
+	// EnrollmentFlag holds the MSPKI_ENROLLMENT_FLAG_* flags of the enrollment
+	EnrollmentFlag ldap_attributes.MSPKIEnrollmentFlag
+	// PrivateKeyFlag holds the flags of the private key of the issued certificates
+	PrivateKeyFlag uint32
+	// RASignatures is the number of enrollment agent signatures required to issue a certificate
+	RASignatures int64
+	// RAApplicationPolicies are the application policies required in the certificates of the enrollment agents
+	RAApplicationPolicies []string
+	// RAIssuancePolicies are the issuance policies required in the certificates of the enrollment agents
+	RAIssuancePolicies []string
+	// IssuancePolicies are the OIDs of the issuance policies of the issued certificates
+	IssuancePolicies []string
+	// ValidityPeriod is the validity period of the issued certificates
+	ValidityPeriod time.Duration
+	// RenewalPeriod is the period before the expiration of a certificate during which it is renewed
+	RenewalPeriod time.Duration
+	// SecurityDescriptor is the security descriptor of the template, holding the enrollment rights, or nil
+	SecurityDescriptor *security.SecurityDescriptor
+}
+
+// NewCertificateTemplateFromEntry creates a CertificateTemplate from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the certificate template.
+//   - entry: The LDAP entry, read with CertificateTemplateAttributes.
+//
+// Returns:
+//   - A pointer to the CertificateTemplate.
+func NewCertificateTemplateFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *CertificateTemplate {
+	template := &CertificateTemplate{}
+	template.Object.fromEntry(ldapSession, entry)
+	template.DisplayName = entry.GetAttributeValue("displayName")
+	template.SchemaVersion = getIntegerAttribute(entry, "msPKI-Template-Schema-Version")
+	template.ExtendedKeyUsages = entry.GetAttributeValues("pKIExtendedKeyUsage")
+	template.ApplicationPolicies = entry.GetAttributeValues("msPKI-Certificate-Application-Policy")
+	template.CertificateNameFlag = ldap_attributes.MSPKICertificateNameFlag(getIntegerAttribute(entry, "msPKI-Certificate-Name-Flag"))
+	template.EnrollmentFlag = ldap_attributes.MSPKIEnrollmentFlag(getIntegerAttribute(entry, "msPKI-Enrollment-Flag"))
+	template.PrivateKeyFlag = uint32(getIntegerAttribute(entry, "msPKI-Private-Key-Flag"))
+	template.RASignatures = getIntegerAttribute(entry, "msPKI-RA-Signature")
+	template.RAApplicationPolicies = entry.GetAttributeValues("msPKI-RA-Application-Policies")
+	template.RAIssuancePolicies = entry.GetAttributeValues("msPKI-RA-Policies")
+	template.IssuancePolicies = entry.GetAttributeValues("msPKI-Certificate-Policy")
+	template.ValidityPeriod = getPeriodAttribute(entry, "pKIExpirationPeriod")
+	template.RenewalPeriod = getPeriodAttribute(entry, "pKIOverlapPeriod")
+	if data := entry.GetRawAttributeValue("nTSecurityDescriptor"); len(data) != 0 {
+		securityDescriptor := &security.SecurityDescriptor{}
+		if err := securityDescriptor.FromBytes(data); err == nil {
+			template.SecurityDescriptor = securityDescriptor
+		}
+	}
+	return template
+}
+
+// GetExtendedKeyUsages returns the extended key usages of the certificates issued from the template.
+//
+// Returns:
+//   - The application policies for the templates of schema version 2 and above which define them, the
+//     pKIExtendedKeyUsage attribute otherwise. An empty slice means that the certificates can be used for any purpose.
+func (template *CertificateTemplate) GetExtendedKeyUsages() []string {
+	if template.SchemaVersion >= 2 && len(template.ApplicationPolicies) != 0 {
+		return template.ApplicationPolicies
+	}
+	return template.ExtendedKeyUsages
+}
+
+// HasExtendedKeyUsage checks if the certificates issued from the template can be used for a purpose.
+//
+// Parameters:
+//   - oid: The OID of the extended key usage, one of the ldap_attributes.EKU_* constants.
+//
+// Returns:
+//   - True if the template has the extended key usage, the Any Purpose extended key usage or no extended key
+//     usage at all, false otherwise.
+func (template *CertificateTemplate) HasExtendedKeyUsage(oid string) bool {
+	extendedKeyUsages := template.GetExtendedKeyUsages()
+	if len(extendedKeyUsages) == 0 {
+		return true
+	}
+	for _, value := range extendedKeyUsages {
+		if value == oid || value == ldap_attributes.EKU_ANY {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAuthentication checks if the certificates issued from the template can be used to authenticate to the
+// domain, with the Client Authentication, PKINIT Client Authentication, Smart Card Logon or Any Purpose extended
+// key usages, or without extended key usage.
+//
+// Returns:
+//   - True if the certificates allow authentication, false otherwise.
+func (template *CertificateTemplate) AllowsAuthentication() bool {
+	for _, oid := range authenticationExtendedKeyUsages {
+		if template.HasExtendedKeyUsage(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAnyPurpose checks if the certificates issued from the template can be used for any purpose, with the
+// Any Purpose extended key usage or without extended key usage.
+//
+// Returns:
+//   - True if the certificates can be used for any purpose, false otherwise.
+func (template *CertificateTemplate) AllowsAnyPurpose() bool {
+	return template.HasExtendedKeyUsage(ldap_attributes.EKU_ANY)
+}
+
+// IsEnrollmentAgentTemplate checks if the certificates issued from the template allow to request certificates on
+// behalf of other principals.
+//
+// Returns:
+//   - True if the template has the Certificate Request Agent extended key usage, false otherwise. The templates
+//     allowing any purpose are not considered enrollment agent templates.
+func (template *CertificateTemplate) IsEnrollmentAgentTemplate() bool {
+	for _, value := range template.GetExtendedKeyUsages() {
+		if value == ldap_attributes.EKU_CERTIFICATE_REQUEST_AGENT {
+			return true
+		}
+	}
+	return false
+}
+
+// EnrolleeSuppliesSubject checks if the enrollee can choose the subject of the issued certificates.
+//
+// Returns:
+//   - True if the MSPKI_CERTIFICATE_NAME_FLAG_ENROLLEE_SUPPLIES_SUBJECT flag is set, false otherwise.
+func (template *CertificateTemplate) EnrolleeSuppliesSubject() bool {
+	return template.CertificateNameFlag&ldap_attributes.MSPKI_CERTIFICATE_NAME_FLAG_ENROLLEE_SUPPLIES_SUBJECT != 0
+}
+
+// RequiresManagerApproval checks if the requests are pending until a certificate manager approves them.
+//
+// Returns:
+//   - True if the MSPKI_ENROLLMENT_FLAG_PEND_ALL_REQUESTS flag is set, false otherwise.
+func (template *CertificateTemplate) RequiresManagerApproval() bool {
+	return template.EnrollmentFlag&ldap_attributes.MSPKI_ENROLLMENT_FLAG_PEND_ALL_REQUESTS != 0
+}
+
+// RequiresAuthorizedSignatures checks if the requests must be signed by enrollment agents. The signatures are
+// only enforced from schema version 2.
+//
+// Returns:
+//   - True if at least one enrollment agent signature is required, false otherwise.
+func (template *CertificateTemplate) RequiresAuthorizedSignatures() bool {
+	return template.SchemaVersion >= 2 && template.RASignatures > 0
+}
+
+// HasNoSecurityExtension checks if the issued certificates lack the szOID_NTDS_CA_SECURITY_EXT extension holding
+// the SID of the enrollee, which makes their mapping to accounts rely on the subject alternative names.
+//
+// Returns:
+//   - True if the MSPKI_ENROLLMENT_FLAG_NO_SECURITY_EXTENSION flag is set, false otherwise.
+func (template *CertificateTemplate) HasNoSecurityExtension() bool {
+	return template.EnrollmentFlag&ldap_attributes.MSPKI_ENROLLMENT_FLAG_NO_SECURITY_EXTENSION != 0
+}
+
+// CanEnroll checks if a principal is granted the Certificate-Enrollment or Certificate-AutoEnrollment extended
+// rights on the template.
+//
+// Parameters:
+//   - SIDs: The SIDs of the principal and of all the groups it is a transitive member of.
+//
+// Returns:
+//   - True if the security descriptor of the template grants the enrollment to the principal, false otherwise or
+//     if the security descriptor was not read.
+func (template *CertificateTemplate) CanEnroll(SIDs []*sid.SID) bool {
+	if template.SecurityDescriptor == nil {
+		return false
+	}
+	return grantsEnrollment(template.SecurityDescriptor.GetEffectiveRights(SIDs))
+}
+
+// CanEnrollThrough checks if a principal is granted the enrollment on the template through one of its SIDs, given
+// the deny ACEs of all its SIDs.
+//
+// Parameters:
+//   - SIDs: The SIDs of the principal and of all the groups it is a transitive member of.
+//   - trusteeSID: The SID of the principal or of one of its groups.
+//
+// Returns:
+//   - True if the security descriptor of the template grants the enrollment through the trustee SID, false
+//     otherwise or if the security descriptor was not read.
+func (template *CertificateTemplate) CanEnrollThrough(SIDs []*sid.SID, trusteeSID *sid.SID) bool {
+	if template.SecurityDescriptor == nil {
+		return false
+	}
+	return grantsEnrollment(template.SecurityDescriptor.GetTrusteeRights(SIDs, trusteeSID))
+}
+
+// grantsEnrollment checks if rights hold the Certificate-Enrollment or Certificate-AutoEnrollment extended right.
+func grantsEnrollment(rights *security.EffectiveRights) bool {
+	for _, extendedRight := range []string{schema.EXTENDED_RIGHT_CERTIFICATE_ENROLLMENT, schema.EXTENDED_RIGHT_CERTIFICATE_AUTOENROLLMENT} {
+		objectType, _ := guid.FromString(extendedRight)
+		if rights.HasRight(security.ADS_RIGHT_DS_CONTROL_ACCESS, objectType) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEnrollmentPrincipals lists the principals granted the enrollment on the template by the allow ACEs of its
+// security descriptor. Deny ACEs are not taken into account.
+//
+// Returns:
+//   - A slice of pointers to the SIDs of the principals, in the order of the ACEs, empty if the security
+//     descriptor was not read.
+func (template *CertificateTemplate) GetEnrollmentPrincipals() []*sid.SID {
+	principals := []*sid.SID{}
+	if template.SecurityDescriptor == nil || template.SecurityDescriptor.DACL == nil {
+		return principals
+	}
+
+	seen := make(map[string]bool)
+	for _, ace := range template.SecurityDescriptor.DACL.ACEs {
+		if ace.SID == nil || !ace.IsAllowed() || ace.IsInheritOnly() || seen[ace.SID.String()] {
+			continue
+		}
+		if template.CanEnroll([]*sid.SID{ace.SID}) {
+			seen[ace.SID.String()] = true
+			principals = append(principals, ace.SID)
+		}
+	}
+	return principals
+}
+
+// getPeriodAttribute decodes a period stored as a negative number of 100-nanosecond intervals in 8 little-endian
+// bytes, such as pKIExpirationPeriod, returning 0 if it is absent or malformed.
+func getPeriodAttribute(entry *ldap.Entry, attribute string) time.Duration {
+	data := entry.GetRawAttributeValue(attribute)
+	if len(data) != 8 {
+		return 0
+	}
+	value := int64(binary.LittleEndian.Uint64(data))
+	if value < 0 {
+		value = -value
+	}
+	return time.Duration(value) * 100
+}
@@ -1,21 +1,55 @@
 package objects
 
-import "fmt"
+import (
+	"fmt"
 
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ComputerAttributes are the attributes read for computers.
+var ComputerAttributes = append(append([]string{}, UserAttributes...),
+	"dnsHostname", "operatingSystem", "operatingSystemVersion",
+)
+
+// Computer is a computer account, which is also a user account.
 type Computer struct {
-	// LdapSession is the LDAP session object
-	LdapSession LdapSessionInterface
-	// DistinguishedName is the distinguished name of the computer
-	DistinguishedName string
+	User
+
 	// DNSHostname is the DNS hostname of the computer
 	DNSHostname []string
+	// OperatingSystem is the name of the operating system of the computer
+	OperatingSystem string
+	// OperatingSystemVersion is the version of the operating system of the computer
+	OperatingSystemVersion string
+}
+
+// NewComputerFromEntry creates a Computer from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the computer.
+//   - entry: The LDAP entry, read with ComputerAttributes.
+//
+// Returns:
+//   - A pointer to the Computer.
+func NewComputerFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *Computer {
+	computer := &Computer{}
+	computer.fromEntry(ldapSession, entry)
+	return computer
+}
+
+// fromEntry fills the attributes of a computer from an LDAP entry.
+func (computer *Computer) fromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) {
+	computer.User.fromEntry(ldapSession, entry)
+	computer.DNSHostname = entry.GetEqualFoldAttributeValues("dnsHostname")
+	computer.OperatingSystem = entry.GetAttributeValue("operatingSystem")
+	computer.OperatingSystemVersion = entry.GetAttributeValue("operatingSystemVersion")
 }
 
 // GetAllComputers retrieves all computer objects from the LDAP directory.
 //
 // This function performs an LDAP search to find all objects with the objectClass "computer"
-// within the domain's distinguished name. It retrieves the distinguished name and DNS hostname
-// attributes for each computer object and constructs a map of Computer objects.
+// within the domain's distinguished name. It retrieves the attributes of ComputerAttributes
+// for each computer object and constructs a map of Computer objects.
 //
 // Returns:
 //   - A map where the keys are the distinguished names of the computer objects and the values are
@@ -29,11 +63,9 @@ type Computer struct {
 //	    fmt.Printf("Computer DN: %s, DNS Hostname: %v\n", dn, computer.DNSHostname)
 //	}
 func (domain *Domain) GetAllComputers() (map[string]*Computer, error) {
-	attributes := []string{"distinguishedName", "dnsHostname"}
-
 	query := "(objectClass=computer)"
 
-	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, attributes)
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, ComputerAttributes)
 	if err != nil {
 		return nil, fmt.Errorf("error querying LDAP: %w", err)
 	}
@@ -42,12 +74,9 @@ func (domain *Domain) GetAllComputers() (map[string]*Computer, error) {
 
 	if len(ldapResults) != 0 {
 		for _, entry := range ldapResults {
-			computer := &Computer{
-				DistinguishedName: entry.GetAttributeValue("distinguishedName"),
-				DNSHostname:       entry.GetEqualFoldAttributeValues("dnsHostname"),
-			}
+			computer := NewComputerFromEntry(domain.LdapSession, entry)
 
-			computersMap[entry.GetAttributeValue("distinguishedName")] = computer
+			computersMap[computer.DistinguishedName] = computer
 		}
 	}
 
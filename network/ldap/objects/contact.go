@@ -0,0 +1,72 @@
+package objects
+
+import (
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ContactAttributes are the attributes read for contacts.
+var ContactAttributes = append(append([]string{}, ObjectAttributes...),
+	"displayName", "mail", "memberOf",
+)
+
+// Contact is a contact, a person without account in the domain.
+type Contact struct {
+	Object
+
+	// DisplayName is the display name of the contact
+	DisplayName string
+	// Mail is the email address of the contact
+	Mail string
+	// MemberOf are the distinguished names of the groups the contact is a direct member of
+	MemberOf []string
+}
+
+// NewContactFromEntry creates a Contact from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the contact.
+//   - entry: The LDAP entry, read with ContactAttributes.
+//
+// Returns:
+//   - A pointer to the Contact.
+func NewContactFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *Contact {
+	contact := &Contact{}
+	contact.Object.fromEntry(ldapSession, entry)
+	contact.DisplayName = entry.GetAttributeValue("displayName")
+	contact.Mail = entry.GetAttributeValue("mail")
+	contact.MemberOf = entry.GetAttributeValues("memberOf")
+	return contact
+}
+
+// GetAllContacts retrieves all contact objects from the LDAP directory.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the contacts and the values are pointers to Contact objects.
+//   - An error if the LDAP query fails.
+func (domain *Domain) GetAllContacts() (map[string]*Contact, error) {
+	query := "(objectClass=contact)"
+
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, ContactAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	contactsMap := make(map[string]*Contact)
+	for _, entry := range ldapResults {
+		contact := NewContactFromEntry(domain.LdapSession, entry)
+		contactsMap[contact.DistinguishedName] = contact
+	}
+
+	return contactsMap, nil
+}
+
+// GetGroups retrieves the groups the contact is a direct member of, from its memberOf attribute.
+//
+// Returns:
+//   - A slice of pointers to the Group objects. Groups which cannot be read are skipped.
+//   - An error if the LDAP query fails.
+func (contact *Contact) GetGroups() ([]*Group, error) {
+	return getGroups(contact.LdapSession, contact.MemberOf)
+}
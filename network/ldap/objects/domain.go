@@ -3,6 +3,8 @@ package objects
 import (
 	"fmt"
 	"strconv"
+
+	"github.com/TheManticoreProject/Manticore/windows/sid"
 )
 
 type Domain struct {
@@ -81,3 +83,82 @@ func (domain *Domain) IsDomainAtLeast(functionalityLevel int) (bool, error) {
 
 	return false, err
 }
+
+// GetMachineAccountQuota retrieves the number of computer accounts each user can create in the domain, from the
+// ms-DS-MachineAccountQuota attribute of the domain object.
+//
+// Returns:
+//   - The machine account quota, 10 by default. Administrators and principals granted the right to create
+//     computer objects are not limited by it.
+//   - An error if the LDAP query fails or if the attribute is not readable.
+func (domain *Domain) GetMachineAccountQuota() (int64, error) {
+	attributes := []string{"ms-DS-MachineAccountQuota"}
+
+	results, err := domain.LdapSession.QueryBaseObject(domain.DistinguishedName, "(objectClass=domain)", attributes)
+	if err != nil {
+		return 0, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(results) == 0 {
+		return 0, fmt.Errorf("no domain object found with distinguished name %s", domain.DistinguishedName)
+	}
+
+	quota, err := strconv.ParseInt(results[0].GetAttributeValue("ms-DS-MachineAccountQuota"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ms-DS-MachineAccountQuota: %w", err)
+	}
+
+	return quota, nil
+}
+
+// GetComputersCreatedBy retrieves the computer accounts counted in the machine account quota of a principal,
+// whose mS-DS-CreatorSID attribute holds its SID.
+//
+// Parameters:
+//   - SID: A string representing the SID of the principal, such as "S-1-5-21-1-2-3-1104".
+//
+// Returns:
+//   - A slice of strings containing the distinguished names of the computer accounts.
+//   - An error if the SID is invalid or if the LDAP query fails.
+func (domain *Domain) GetComputersCreatedBy(SID string) ([]string, error) {
+	creatorSID, err := sid.FromString(SID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SID %s: %w", SID, err)
+	}
+
+	query := fmt.Sprintf("(&(objectClass=computer)(mS-DS-CreatorSID=%s))", creatorSID.String())
+
+	results, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, []string{"distinguishedName"})
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	distinguishedNames := []string{}
+	for _, entry := range results {
+		distinguishedNames = append(distinguishedNames, entry.GetAttributeValue("distinguishedName"))
+	}
+
+	return distinguishedNames, nil
+}
+
+// GetRemainingMachineAccountQuota computes the number of computer accounts a principal can still create with
+// its machine account quota.
+//
+// Parameters:
+//   - SID: A string representing the SID of the principal.
+//
+// Returns:
+//   - The number of computer accounts the principal can still create, 0 if the quota is exhausted.
+//   - An error if the SID is invalid or if the LDAP queries fail.
+func (domain *Domain) GetRemainingMachineAccountQuota(SID string) (int64, error) {
+	quota, err := domain.GetMachineAccountQuota()
+	if err != nil {
+		return 0, err
+	}
+
+	computers, err := domain.GetComputersCreatedBy(SID)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(quota-int64(len(computers)), 0), nil
+}
@@ -0,0 +1,85 @@
+package objects
+
+import (
+	"crypto/x509"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// EnrollmentServiceAttributes are the attributes read for enrollment services.
+var EnrollmentServiceAttributes = append(append([]string{}, ObjectAttributes...),
+	"displayName", "dNSHostName", "cACertificate", "certificateTemplates", "flags", "nTSecurityDescriptor",
+)
+
+// EnrollmentService is an enterprise certificate authority of Active Directory Certificate Services, stored as a
+// pKIEnrollmentService object in the Enrollment Services container of the configuration naming context. Its name
+// is the name of the certificate authority.
+type EnrollmentService struct {
+	Object
+
+	// DisplayName is the display name of the certificate authority
+	DisplayName string
+	// DNSHostName is the DNS name of the server hosting the certificate authority
+	DNSHostName string
+	// CACertificate is the certificate of the certificate authority, or nil
+	CACertificate *x509.Certificate
+	// CertificateTemplates are the names of the templates published by the certificate authority
+	CertificateTemplates []string
+	// Flags holds the flags of the enrollment service
+	Flags uint32
+	// SecurityDescriptor is the security descriptor of the pKIEnrollmentService object, or nil. The permissions
+	// of the certificate authority itself are stored on the server hosting it.
+	SecurityDescriptor *security.SecurityDescriptor
+}
+
+// NewEnrollmentServiceFromEntry creates an EnrollmentService from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the enrollment service.
+//   - entry: The LDAP entry, read with EnrollmentServiceAttributes.
+//
+// Returns:
+//   - A pointer to the EnrollmentService.
+func NewEnrollmentServiceFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *EnrollmentService {
+	enrollmentService := &EnrollmentService{}
+	enrollmentService.Object.fromEntry(ldapSession, entry)
+	enrollmentService.DisplayName = entry.GetAttributeValue("displayName")
+	enrollmentService.DNSHostName = entry.GetAttributeValue("dNSHostName")
+	if data := entry.GetRawAttributeValue("cACertificate"); len(data) != 0 {
+		if certificate, err := x509.ParseCertificate(data); err == nil {
+			enrollmentService.CACertificate = certificate
+		}
+	}
+	enrollmentService.CertificateTemplates = entry.GetAttributeValues("certificateTemplates")
+	enrollmentService.Flags = uint32(getIntegerAttribute(entry, "flags"))
+	if data := entry.GetRawAttributeValue("nTSecurityDescriptor"); len(data) != 0 {
+		securityDescriptor := &security.SecurityDescriptor{}
+		if err := securityDescriptor.FromBytes(data); err == nil {
+			enrollmentService.SecurityDescriptor = securityDescriptor
+		}
+	}
+	return enrollmentService
+}
+
+// PublishesCertificateTemplate checks if the certificate authority issues certificates from a template.
+//
+// Parameters:
+//   - name: The name of the certificate template, case insensitive.
+//
+// Returns:
+//   - True if the template is in the certificateTemplates attribute of the enrollment service, false otherwise.
+func (enrollmentService *EnrollmentService) PublishesCertificateTemplate(name string) bool {
+	return isCertificateTemplateName(enrollmentService.CertificateTemplates, name)
+}
+
+// isCertificateTemplateName checks if a name is one of the names of templates published by a certificate authority.
+func isCertificateTemplateName(names []string, name string) bool {
+	for _, value := range names {
+		if strings.EqualFold(value, name) {
+			return true
+		}
+	}
+	return false
+}
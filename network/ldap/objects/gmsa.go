@@ -0,0 +1,67 @@
+package objects
+
+import (
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// GroupManagedServiceAccountAttributes are the attributes read for group managed service accounts.
+var GroupManagedServiceAccountAttributes = append(append([]string{}, ComputerAttributes...),
+	"msDS-ManagedPasswordInterval", "msDS-GroupMSAMembership",
+)
+
+// GroupManagedServiceAccount is a group managed service account (gMSA), which is also a computer account.
+type GroupManagedServiceAccount struct {
+	Computer
+
+	// ManagedPasswordInterval is the number of days between two password changes
+	ManagedPasswordInterval int64
+	// GroupMSAMembership is the security descriptor of the principals allowed to retrieve the password, or nil
+	GroupMSAMembership *security.SecurityDescriptor
+}
+
+// NewGroupManagedServiceAccountFromEntry creates a GroupManagedServiceAccount from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the account.
+//   - entry: The LDAP entry, read with GroupManagedServiceAccountAttributes.
+//
+// Returns:
+//   - A pointer to the GroupManagedServiceAccount.
+func NewGroupManagedServiceAccountFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *GroupManagedServiceAccount {
+	gmsa := &GroupManagedServiceAccount{}
+	gmsa.Computer.fromEntry(ldapSession, entry)
+	gmsa.ManagedPasswordInterval = getIntegerAttribute(entry, "msDS-ManagedPasswordInterval")
+	if data := entry.GetRawAttributeValue("msDS-GroupMSAMembership"); len(data) != 0 {
+		securityDescriptor := &security.SecurityDescriptor{}
+		if err := securityDescriptor.FromBytes(data); err == nil {
+			gmsa.GroupMSAMembership = securityDescriptor
+		}
+	}
+	return gmsa
+}
+
+// GetAllGroupManagedServiceAccounts retrieves all group managed service accounts from the LDAP directory.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the accounts and the values are pointers to
+//     GroupManagedServiceAccount objects.
+//   - An error if the LDAP query fails.
+func (domain *Domain) GetAllGroupManagedServiceAccounts() (map[string]*GroupManagedServiceAccount, error) {
+	query := "(objectClass=msDS-GroupManagedServiceAccount)"
+
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, GroupManagedServiceAccountAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	gmsasMap := make(map[string]*GroupManagedServiceAccount)
+	for _, entry := range ldapResults {
+		gmsa := NewGroupManagedServiceAccountFromEntry(domain.LdapSession, entry)
+		gmsasMap[gmsa.DistinguishedName] = gmsa
+	}
+
+	return gmsasMap, nil
+}
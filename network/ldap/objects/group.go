@@ -0,0 +1,104 @@
+package objects
+
+import (
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// GroupAttributes are the attributes read for groups.
+var GroupAttributes = append(append([]string{}, ObjectAttributes...),
+	"sAMAccountName", "groupType", "sAMAccountType", "member", "memberOf", "adminCount", "managedBy",
+)
+
+type Group struct {
+	Object
+
+	// SamAccountName is the sAMAccountName of the group
+	SamAccountName string
+	// GroupType holds the scope and the type of the group
+	GroupType ldap_attributes.GroupType
+	// SAMAccountType is the type of the account
+	SAMAccountType ldap_attributes.SAMAccountType
+	// Members are the distinguished names of the direct members of the group
+	Members []string
+	// MemberOf are the distinguished names of the groups the group is a direct member of
+	MemberOf []string
+	// AdminCount is true if the group is or was protected by AdminSDHolder
+	AdminCount bool
+	// ManagedBy is the distinguished name of the manager of the group
+	ManagedBy string
+}
+
+// NewGroupFromEntry creates a Group from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the group.
+//   - entry: The LDAP entry, read with GroupAttributes.
+//
+// Returns:
+//   - A pointer to the Group.
+func NewGroupFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *Group {
+	group := &Group{}
+	group.Object.fromEntry(ldapSession, entry)
+	group.SamAccountName = entry.GetAttributeValue("sAMAccountName")
+	group.GroupType = ldap_attributes.GroupType(getIntegerAttribute(entry, "groupType"))
+	group.SAMAccountType = ldap_attributes.SAMAccountType(getIntegerAttribute(entry, "sAMAccountType"))
+	group.Members = entry.GetAttributeValues("member")
+	group.MemberOf = entry.GetAttributeValues("memberOf")
+	group.AdminCount = getBooleanAttribute(entry, "adminCount")
+	group.ManagedBy = entry.GetAttributeValue("managedBy")
+	return group
+}
+
+// GetAllGroups retrieves all group objects from the LDAP directory.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the groups and the values are pointers to Group objects.
+//   - An error if the LDAP query fails.
+func (domain *Domain) GetAllGroups() (map[string]*Group, error) {
+	query := "(objectClass=group)"
+
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, GroupAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	groupsMap := make(map[string]*Group)
+	for _, entry := range ldapResults {
+		group := NewGroupFromEntry(domain.LdapSession, entry)
+		groupsMap[group.DistinguishedName] = group
+	}
+
+	return groupsMap, nil
+}
+
+// GetMembers retrieves the direct members of the group, from its member attribute. The members whose primary
+// group is the group are not included.
+//
+// Returns:
+//   - A slice of pointers to the Object of the members, whose ObjectClass tells their type. Members which cannot
+//     be read are skipped.
+//   - An error if the LDAP query fails.
+func (group *Group) GetMembers() ([]*Object, error) {
+	entries, err := getObjectEntries(group.LdapSession, group.Members, ObjectAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []*Object{}
+	for _, entry := range entries {
+		members = append(members, NewObjectFromEntry(group.LdapSession, entry))
+	}
+	return members, nil
+}
+
+// GetGroups retrieves the groups the group is a direct member of, from its memberOf attribute.
+//
+// Returns:
+//   - A slice of pointers to the Group objects. Groups which cannot be read are skipped.
+//   - An error if the LDAP query fails.
+func (group *Group) GetGroups() ([]*Group, error) {
+	return getGroups(group.LdapSession, group.MemberOf)
+}
@@ -0,0 +1,80 @@
+package objects
+
+import (
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Flags of the group policy containers
+// Src: [MS-GPOL] 2.2.1 Group Policy Container
+const (
+	GPC_FLAG_USER_DISABLED    uint32 = 0x00000001
+	GPC_FLAG_MACHINE_DISABLED uint32 = 0x00000002
+)
+
+// GroupPolicyContainerAttributes are the attributes read for group policy containers.
+var GroupPolicyContainerAttributes = append(append([]string{}, ObjectAttributes...),
+	"displayName", "gPCFileSysPath", "versionNumber", "flags", "gPCMachineExtensionNames", "gPCUserExtensionNames",
+)
+
+// GroupPolicyContainer is the directory part of a group policy object, whose name is the GUID of the policy.
+type GroupPolicyContainer struct {
+	Object
+
+	// DisplayName is the display name of the group policy
+	DisplayName string
+	// FileSysPath is the UNC path of the group policy template in SYSVOL
+	FileSysPath string
+	// VersionNumber holds the user version in its high 16 bits and the computer version in its low 16 bits
+	VersionNumber uint32
+	// Flags holds the GPC_FLAG_* flags of the group policy
+	Flags uint32
+	// MachineExtensionNames lists the client-side extensions of the computer settings
+	MachineExtensionNames string
+	// UserExtensionNames lists the client-side extensions of the user settings
+	UserExtensionNames string
+}
+
+// NewGroupPolicyContainerFromEntry creates a GroupPolicyContainer from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the group policy container.
+//   - entry: The LDAP entry, read with GroupPolicyContainerAttributes.
+//
+// Returns:
+//   - A pointer to the GroupPolicyContainer.
+func NewGroupPolicyContainerFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *GroupPolicyContainer {
+	gpc := &GroupPolicyContainer{}
+	gpc.Object.fromEntry(ldapSession, entry)
+	gpc.DisplayName = entry.GetAttributeValue("displayName")
+	gpc.FileSysPath = entry.GetAttributeValue("gPCFileSysPath")
+	gpc.VersionNumber = uint32(getIntegerAttribute(entry, "versionNumber"))
+	gpc.Flags = uint32(getIntegerAttribute(entry, "flags"))
+	gpc.MachineExtensionNames = entry.GetAttributeValue("gPCMachineExtensionNames")
+	gpc.UserExtensionNames = entry.GetAttributeValue("gPCUserExtensionNames")
+	return gpc
+}
+
+// GetAllGroupPolicyContainers retrieves all group policy containers from the LDAP directory.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the group policy containers and the values are
+//     pointers to GroupPolicyContainer objects.
+//   - An error if the LDAP query fails.
+func (domain *Domain) GetAllGroupPolicyContainers() (map[string]*GroupPolicyContainer, error) {
+	query := "(objectClass=groupPolicyContainer)"
+
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, GroupPolicyContainerAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	gpcsMap := make(map[string]*GroupPolicyContainer)
+	for _, entry := range ldapResults {
+		gpc := NewGroupPolicyContainerFromEntry(domain.LdapSession, entry)
+		gpcsMap[gpc.DistinguishedName] = gpc
+	}
+
+	return gpcsMap, nil
+}
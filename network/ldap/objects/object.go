@@ -0,0 +1,181 @@
+package objects
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ObjectAttributes are the attributes read for every typed object.
+var ObjectAttributes = []string{"distinguishedName", "name", "objectClass", "objectGUID", "objectSid", "description", "whenCreated", "whenChanged"}
+
+// Object holds the attributes shared by all the objects of the directory. It is embedded in the typed objects.
+//
+// Fields:
+//   - LdapSession: The LDAP session used to resolve the relationships of the object.
+//   - DistinguishedName: The distinguished name of the object.
+//   - Name: The relative distinguished name value of the object.
+//   - ObjectClass: The classes of the object, from the most generic to the most specific.
+//   - ObjectGUID: The GUID of the object.
+//   - ObjectSID: The SID of the object, or nil if it is not a security principal.
+//   - Description: The description of the object.
+//   - WhenCreated: The creation time of the object.
+//   - WhenChanged: The time of the last change of the object.
+type Object struct {
+	LdapSession       LdapSessionInterface
+	DistinguishedName string
+	Name              string
+	ObjectClass       []string
+	ObjectGUID        *guid.GUID
+	ObjectSID         *sid.SID
+	Description       string
+	WhenCreated       time.Time
+	WhenChanged       time.Time
+}
+
+// NewObjectFromEntry creates an Object from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the object.
+//   - entry: The LDAP entry, read with ObjectAttributes.
+//
+// Returns:
+//   - A pointer to the Object.
+func NewObjectFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *Object {
+	object := &Object{}
+	object.fromEntry(ldapSession, entry)
+	return object
+}
+
+// fromEntry fills the shared attributes of an object from an LDAP entry.
+func (object *Object) fromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) {
+	object.LdapSession = ldapSession
+	object.DistinguishedName = entry.DN
+	if value := entry.GetAttributeValue("distinguishedName"); len(value) != 0 {
+		object.DistinguishedName = value
+	}
+	object.Name = entry.GetAttributeValue("name")
+	object.ObjectClass = entry.GetAttributeValues("objectClass")
+	object.ObjectGUID = getGUIDAttribute(entry, "objectGUID")
+	object.ObjectSID = getSIDAttribute(entry, "objectSid")
+	object.Description = entry.GetAttributeValue("description")
+	object.WhenCreated = getGeneralizedTimeAttribute(entry, "whenCreated")
+	object.WhenChanged = getGeneralizedTimeAttribute(entry, "whenChanged")
+}
+
+// HasObjectClass checks if the object is an instance of a class.
+//
+// Parameters:
+//   - className: The LDAP display name of the class, such as "user" or "group".
+//
+// Returns:
+//   - True if the class is one of the classes of the object, false otherwise.
+func (object *Object) HasObjectClass(className string) bool {
+	for _, value := range object.ObjectClass {
+		if strings.EqualFold(value, className) {
+			return true
+		}
+	}
+	return false
+}
+
+// getObjectEntries reads objects by distinguished name, skipping the ones which do not exist.
+func getObjectEntries(ldapSession LdapSessionInterface, distinguishedNames []string, attributes []string) ([]*ldap.Entry, error) {
+	if ldapSession == nil {
+		return nil, fmt.Errorf("object has no LDAP session")
+	}
+
+	entries := []*ldap.Entry{}
+	for _, distinguishedName := range distinguishedNames {
+		results, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", attributes)
+		if err != nil {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+				continue
+			}
+			return nil, fmt.Errorf("error querying LDAP: %w", err)
+		}
+		entries = append(entries, results...)
+	}
+	return entries, nil
+}
+
+// getGroups resolves the groups of a memberOf attribute.
+func getGroups(ldapSession LdapSessionInterface, memberOf []string) ([]*Group, error) {
+	entries, err := getObjectEntries(ldapSession, memberOf, GroupAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []*Group{}
+	for _, entry := range entries {
+		groups = append(groups, NewGroupFromEntry(ldapSession, entry))
+	}
+	return groups, nil
+}
+
+// getGUIDAttribute decodes a binary GUID attribute, returning nil if it is absent or malformed.
+func getGUIDAttribute(entry *ldap.Entry, attribute string) *guid.GUID {
+	data := entry.GetRawAttributeValue(attribute)
+	if len(data) != 16 {
+		return nil
+	}
+	g := &guid.GUID{}
+	g.FromRawBytes(data)
+	return g
+}
+
+// getSIDAttribute decodes a binary SID attribute, returning nil if it is absent or malformed.
+func getSIDAttribute(entry *ldap.Entry, attribute string) *sid.SID {
+	data := entry.GetRawAttributeValue(attribute)
+	if len(data) == 0 {
+		return nil
+	}
+	s, err := sid.FromBytes(data)
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// getIntegerAttribute decodes an integer attribute, returning 0 if it is absent or malformed.
+func getIntegerAttribute(entry *ldap.Entry, attribute string) int64 {
+	value, err := strconv.ParseInt(entry.GetAttributeValue(attribute), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// getBooleanAttribute decodes a boolean attribute, also accepting the integers used by attributes such as adminCount.
+func getBooleanAttribute(entry *ldap.Entry, attribute string) bool {
+	if strings.EqualFold(entry.GetAttributeValue(attribute), "TRUE") {
+		return true
+	}
+	return getIntegerAttribute(entry, attribute) != 0
+}
+
+// getFileTimeAttribute decodes an attribute holding a number of 100-nanosecond intervals since January 1, 1601,
+// returning the zero time if it is absent, malformed, 0 or the "never" value 0x7FFFFFFFFFFFFFFF.
+func getFileTimeAttribute(entry *ldap.Entry, attribute string) time.Time {
+	value, err := ldap_attributes.ParseFileTime(entry.GetRawAttributeValue(attribute))
+	if err != nil {
+		return time.Time{}
+	}
+	return value
+}
+
+// getGeneralizedTimeAttribute decodes a generalized time attribute, returning the zero time if it is absent or
+// malformed.
+func getGeneralizedTimeAttribute(entry *ldap.Entry, attribute string) time.Time {
+	value, err := ldap_attributes.ParseGeneralizedTime(entry.GetRawAttributeValue(attribute))
+	if err != nil {
+		return time.Time{}
+	}
+	return value
+}
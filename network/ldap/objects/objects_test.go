@@ -0,0 +1,146 @@
+package objects_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/network/ldap/objects"
+	"github.com/TheManticoreProject/Manticore/windows/security"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestNewComputerFromEntry(t *testing.T) {
+	objectSID, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	entry := ldap.NewEntry("CN=WS01,CN=Computers,DC=lab,DC=local", map[string][]string{
+		"objectClass":        {"top", "person", "organizationalPerson", "user", "computer"},
+		"objectSid":          {string(objectSID.ToBytes())},
+		"objectGUID":         {string([]byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x34, 0x12, 0x12, 0x34, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc})},
+		"whenCreated":        {"20240102030405.0Z"},
+		"sAMAccountName":     {"WS01$"},
+		"userAccountControl": {"4098"},
+		"primaryGroupID":     {"515"},
+		"pwdLastSet":         {"133485408000000000"},
+		"accountExpires":     {"9223372036854775807"},
+		"adminCount":         {"1"},
+		"dNSHostName":        {"ws01.lab.local"},
+	})
+
+	computer := objects.NewComputerFromEntry(nil, entry)
+	if computer.DistinguishedName != entry.DN || computer.SamAccountName != "WS01$" || !computer.HasObjectClass("Computer") {
+		t.Errorf("Unexpected computer %s (%s)", computer.DistinguishedName, computer.SamAccountName)
+	}
+	if computer.ObjectSID == nil || computer.ObjectSID.String() != "S-1-5-21-1-2-3-1104" {
+		t.Errorf("Unexpected SID %v", computer.ObjectSID)
+	}
+	if computer.ObjectGUID == nil || computer.ObjectGUID.ToFormatD() != "12345678-1234-1234-1234-123456789abc" {
+		t.Errorf("Unexpected GUID %v", computer.ObjectGUID)
+	}
+	if computer.IsEnabled() || computer.UserAccountControl&ldap_attributes.UAF_WORKSTATION_TRUST_ACCOUNT == 0 || computer.PrimaryGroupID != 515 {
+		t.Errorf("Unexpected userAccountControl %s or primaryGroupID %d", computer.UserAccountControl, computer.PrimaryGroupID)
+	}
+	if expected := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !computer.WhenCreated.Equal(expected) {
+		t.Errorf("WhenCreated = %s; want %s", computer.WhenCreated, expected)
+	}
+	if expected := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !computer.PwdLastSet.Equal(expected) {
+		t.Errorf("PwdLastSet = %s; want %s", computer.PwdLastSet, expected)
+	}
+	if !computer.AccountExpires.IsZero() || !computer.LastLogonTimestamp.IsZero() {
+		t.Errorf("Expected no expiration and no logon, got %s and %s", computer.AccountExpires, computer.LastLogonTimestamp)
+	}
+	if !computer.AdminCount || len(computer.DNSHostname) != 1 || computer.DNSHostname[0] != "ws01.lab.local" {
+		t.Errorf("Unexpected adminCount %v or DNS hostname %v", computer.AdminCount, computer.DNSHostname)
+	}
+}
+
+func TestNewGroupFromEntry(t *testing.T) {
+	entry := ldap.NewEntry("CN=Domain Admins,CN=Users,DC=lab,DC=local", map[string][]string{
+		"groupType": {"-2147483646"},
+		"member":    {"CN=Administrator,CN=Users,DC=lab,DC=local"},
+	})
+
+	group := objects.NewGroupFromEntry(nil, entry)
+	if !group.GroupType.IsSecurityGroup() || group.GroupType.Scope() != "Global" {
+		t.Errorf("Unexpected groupType %s", group.GroupType)
+	}
+	if len(group.Members) != 1 {
+		t.Errorf("Unexpected members %v", group.Members)
+	}
+	if _, err := group.GetMembers(); err == nil {
+		t.Error("GetMembers should fail without LDAP session")
+	}
+}
+
+func TestParseGPLink(t *testing.T) {
+	value := "[LDAP://cn={6AC1786C-016F-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=lab,DC=local;2][ldap://cn={31B2F340-016D-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=lab,DC=local;1][invalid]"
+
+	links := objects.ParseGPLink(value)
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 links, got %d", len(links))
+	}
+	if links[0].DistinguishedName != "cn={6AC1786C-016F-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=lab,DC=local" || links[0].Options != objects.GPLINK_OPTION_ENFORCED {
+		t.Errorf("Unexpected first link %+v", links[0])
+	}
+	if links[1].DistinguishedName != "cn={31B2F340-016D-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=lab,DC=local" || links[1].Options != objects.GPLINK_OPTION_DISABLED {
+		t.Errorf("Unexpected second link %+v", links[1])
+	}
+	if len(objects.ParseGPLink("")) != 0 {
+		t.Error("Expected no link for an empty gPLink")
+	}
+}
+
+func TestNewCertificateTemplateFromEntry(t *testing.T) {
+	domainSID, _ := sid.FromString("S-1-5-21-1-2-3")
+	sd, err := security.ParseSDDL("O:DAD:(OA;;CR;0e10c968-78fb-11d2-90d4-00c04f79dc55;;DU)(A;;RPLC;;;AU)", domainSID)
+	if err != nil {
+		t.Fatalf("ParseSDDL() error = %v", err)
+	}
+	data, err := sd.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes() error = %v", err)
+	}
+
+	// 1 year and 6 weeks, as negative numbers of 100-nanosecond intervals
+	expirationInterval, overlapInterval := -int64(365*24*time.Hour/100), -int64(6*7*24*time.Hour/100)
+	expiration := binary.LittleEndian.AppendUint64(nil, uint64(expirationInterval))
+	overlap := binary.LittleEndian.AppendUint64(nil, uint64(overlapInterval))
+
+	entry := ldap.NewEntry("CN=VulnTemplate,CN=Certificate Templates,CN=Public Key Services,CN=Services,CN=Configuration,DC=lab,DC=local", map[string][]string{
+		"name":                                 {"VulnTemplate"},
+		"msPKI-Template-Schema-Version":        {"2"},
+		"pKIExtendedKeyUsage":                  {ldap_attributes.EKU_SERVER_AUTHENTICATION},
+		"msPKI-Certificate-Application-Policy": {ldap_attributes.EKU_CLIENT_AUTHENTICATION},
+		"msPKI-Certificate-Name-Flag":          {"1"},
+		"msPKI-Enrollment-Flag":                {"524288"},
+		"msPKI-RA-Signature":                   {"0"},
+		"pKIExpirationPeriod":                  {string(expiration)},
+		"pKIOverlapPeriod":                     {string(overlap)},
+		"nTSecurityDescriptor":                 {string(data)},
+	})
+
+	template := objects.NewCertificateTemplateFromEntry(nil, entry)
+	if template.Name != "VulnTemplate" || template.SchemaVersion != 2 {
+		t.Errorf("Unexpected template %s (schema version %d)", template.Name, template.SchemaVersion)
+	}
+	if template.ValidityPeriod != 365*24*time.Hour || template.RenewalPeriod != 6*7*24*time.Hour {
+		t.Errorf("Unexpected periods %s and %s", template.ValidityPeriod, template.RenewalPeriod)
+	}
+	if !template.AllowsAuthentication() || template.AllowsAnyPurpose() || template.IsEnrollmentAgentTemplate() {
+		t.Errorf("Unexpected extended key usages %v", template.GetExtendedKeyUsages())
+	}
+	if !template.EnrolleeSuppliesSubject() || !template.HasNoSecurityExtension() || template.RequiresManagerApproval() || template.RequiresAuthorizedSignatures() {
+		t.Errorf("Unexpected flags %d and %d", template.CertificateNameFlag, template.EnrollmentFlag)
+	}
+
+	domainUsers, _ := sid.FromString("S-1-5-21-1-2-3-513")
+	authenticatedUsers, _ := sid.FromString("S-1-5-11")
+	if !template.CanEnroll([]*sid.SID{domainUsers}) || template.CanEnroll([]*sid.SID{authenticatedUsers}) {
+		t.Errorf("Unexpected enrollment rights")
+	}
+	principals := template.GetEnrollmentPrincipals()
+	if len(principals) != 1 || !principals[0].Equal(domainUsers) {
+		t.Errorf("GetEnrollmentPrincipals() = %v; want [%s]", principals, domainUsers.String())
+	}
+}
@@ -0,0 +1,152 @@
+package objects
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Options of a link to a group policy in a gPLink attribute
+// Src: [MS-GPOL] 2.2.2 Domain SOM Search
+const (
+	GPLINK_OPTION_DISABLED uint32 = 0x00000001
+	GPLINK_OPTION_ENFORCED uint32 = 0x00000002
+)
+
+// Value of the gPOptions attribute blocking the inheritance of the group policies of the parents
+const GPOPTIONS_BLOCK_INHERITANCE uint32 = 0x00000001
+
+// OrganizationalUnitAttributes are the attributes read for organizational units.
+var OrganizationalUnitAttributes = append(append([]string{}, ObjectAttributes...),
+	"gPLink", "gPOptions", "managedBy",
+)
+
+// GPLink is a link from a container to a group policy, as stored in the gPLink attribute.
+//
+// Fields:
+//   - DistinguishedName: The distinguished name of the group policy container.
+//   - Options: The options of the link, GPLINK_OPTION_DISABLED and GPLINK_OPTION_ENFORCED.
+type GPLink struct {
+	DistinguishedName string
+	Options           uint32
+}
+
+type OrganizationalUnit struct {
+	Object
+
+	// GPLinks are the links to the group policies applied to the organizational unit, in the order of the gPLink attribute
+	GPLinks []*GPLink
+	// GPOptions holds the options of the group policies of the organizational unit
+	GPOptions uint32
+	// ManagedBy is the distinguished name of the manager of the organizational unit
+	ManagedBy string
+}
+
+// NewOrganizationalUnitFromEntry creates an OrganizationalUnit from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the organizational unit.
+//   - entry: The LDAP entry, read with OrganizationalUnitAttributes.
+//
+// Returns:
+//   - A pointer to the OrganizationalUnit.
+func NewOrganizationalUnitFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *OrganizationalUnit {
+	ou := &OrganizationalUnit{}
+	ou.Object.fromEntry(ldapSession, entry)
+	ou.GPLinks = ParseGPLink(entry.GetAttributeValue("gPLink"))
+	ou.GPOptions = uint32(getIntegerAttribute(entry, "gPOptions"))
+	ou.ManagedBy = entry.GetAttributeValue("managedBy")
+	return ou
+}
+
+// ParseGPLink parses the value of a gPLink attribute, such as
+// "[LDAP://cn={31B2F340-016D-11D2-945F-00C04FB984F9},cn=policies,cn=system,DC=lab,DC=local;0]".
+//
+// Parameters:
+//   - value: The value of the gPLink attribute.
+//
+// Returns:
+//   - A slice of pointers to the GPLink, skipping the malformed links.
+func ParseGPLink(value string) []*GPLink {
+	links := []*GPLink{}
+	for _, link := range strings.Split(value, "]") {
+		link = strings.TrimPrefix(strings.TrimSpace(link), "[")
+		if len(link) == 0 {
+			continue
+		}
+
+		separator := strings.LastIndex(link, ";")
+		if separator == -1 {
+			continue
+		}
+		options, err := strconv.ParseUint(link[separator+1:], 10, 32)
+		if err != nil {
+			continue
+		}
+		distinguishedName := link[:separator]
+		if len(distinguishedName) >= len("LDAP://") && strings.EqualFold(distinguishedName[:len("LDAP://")], "LDAP://") {
+			distinguishedName = distinguishedName[len("LDAP://"):]
+		}
+
+		links = append(links, &GPLink{DistinguishedName: distinguishedName, Options: uint32(options)})
+	}
+	return links
+}
+
+// IsInheritanceBlocked checks if the organizational unit blocks the inheritance of the group policies of its parents.
+//
+// Returns:
+//   - True if the GPOPTIONS_BLOCK_INHERITANCE flag is set in the gPOptions attribute, false otherwise.
+func (ou *OrganizationalUnit) IsInheritanceBlocked() bool {
+	return ou.GPOptions&GPOPTIONS_BLOCK_INHERITANCE != 0
+}
+
+// GetAllOrganizationalUnits retrieves all organizational units from the LDAP directory.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the organizational units and the values are pointers
+//     to OrganizationalUnit objects.
+//   - An error if the LDAP query fails.
+func (domain *Domain) GetAllOrganizationalUnits() (map[string]*OrganizationalUnit, error) {
+	query := "(objectClass=organizationalUnit)"
+
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, OrganizationalUnitAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	ousMap := make(map[string]*OrganizationalUnit)
+	for _, entry := range ldapResults {
+		ou := NewOrganizationalUnitFromEntry(domain.LdapSession, entry)
+		ousMap[ou.DistinguishedName] = ou
+	}
+
+	return ousMap, nil
+}
+
+// GetGroupPolicyContainers retrieves the group policies linked to the organizational unit, including the
+// disabled links.
+//
+// Returns:
+//   - A slice of pointers to the GroupPolicyContainer objects, in the order of the gPLink attribute. Group
+//     policies which cannot be read are skipped.
+//   - An error if the LDAP query fails.
+func (ou *OrganizationalUnit) GetGroupPolicyContainers() ([]*GroupPolicyContainer, error) {
+	distinguishedNames := []string{}
+	for _, link := range ou.GPLinks {
+		distinguishedNames = append(distinguishedNames, link.DistinguishedName)
+	}
+
+	entries, err := getObjectEntries(ou.LdapSession, distinguishedNames, GroupPolicyContainerAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	gpcs := []*GroupPolicyContainer{}
+	for _, entry := range entries {
+		gpcs = append(gpcs, NewGroupPolicyContainerFromEntry(ou.LdapSession, entry))
+	}
+	return gpcs, nil
+}
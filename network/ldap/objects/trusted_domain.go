@@ -0,0 +1,83 @@
+package objects
+
+import (
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// TrustedDomainAttributes are the attributes read for trusted domains.
+var TrustedDomainAttributes = append(append([]string{}, ObjectAttributes...),
+	"trustPartner", "flatName", "trustDirection", "trustType", "trustAttributes", "securityIdentifier",
+)
+
+// TrustedDomain is a trust relationship of the domain, stored in the System container.
+type TrustedDomain struct {
+	Object
+
+	// TrustPartner is the DNS name of the trusted domain
+	TrustPartner string
+	// FlatName is the NetBIOS name of the trusted domain
+	FlatName string
+	// TrustDirection is the direction of the trust
+	TrustDirection ldap_attributes.TrustDirection
+	// TrustType is the type of the trust
+	TrustType ldap_attributes.TrustType
+	// TrustAttributes holds the flags of the trust
+	TrustAttributes ldap_attributes.TrustAttributes
+	// SecurityIdentifier is the SID of the trusted domain, or nil
+	SecurityIdentifier *sid.SID
+}
+
+// NewTrustedDomainFromEntry creates a TrustedDomain from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the trusted domain.
+//   - entry: The LDAP entry, read with TrustedDomainAttributes.
+//
+// Returns:
+//   - A pointer to the TrustedDomain.
+func NewTrustedDomainFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *TrustedDomain {
+	trustedDomain := &TrustedDomain{}
+	trustedDomain.Object.fromEntry(ldapSession, entry)
+	trustedDomain.TrustPartner = entry.GetAttributeValue("trustPartner")
+	trustedDomain.FlatName = entry.GetAttributeValue("flatName")
+	trustedDomain.TrustDirection = ldap_attributes.TrustDirection(getIntegerAttribute(entry, "trustDirection"))
+	trustedDomain.TrustType = ldap_attributes.TrustType(getIntegerAttribute(entry, "trustType"))
+	trustedDomain.TrustAttributes = ldap_attributes.TrustAttributes(getIntegerAttribute(entry, "trustAttributes"))
+	trustedDomain.SecurityIdentifier = getSIDAttribute(entry, "securityIdentifier")
+	return trustedDomain
+}
+
+// IsTransitive checks if the trust is transitive.
+//
+// Returns:
+//   - True if the NON_TRANSITIVE flag is not set in the trustAttributes attribute, false otherwise.
+func (trustedDomain *TrustedDomain) IsTransitive() bool {
+	return trustedDomain.TrustAttributes&ldap_attributes.TRUST_ATTRIBUTE_NON_TRANSITIVE == 0
+}
+
+// GetAllTrustedDomains retrieves all the trust relationships of the domain from the LDAP directory.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the trusted domain objects and the values are pointers
+//     to TrustedDomain objects.
+//   - An error if the LDAP query fails.
+func (domain *Domain) GetAllTrustedDomains() (map[string]*TrustedDomain, error) {
+	query := "(objectClass=trustedDomain)"
+
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, TrustedDomainAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	trustedDomainsMap := make(map[string]*TrustedDomain)
+	for _, entry := range ldapResults {
+		trustedDomain := NewTrustedDomainFromEntry(domain.LdapSession, entry)
+		trustedDomainsMap[trustedDomain.DistinguishedName] = trustedDomain
+	}
+
+	return trustedDomainsMap, nil
+}
@@ -1,10 +1,152 @@
 package objects
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// UserAttributes are the attributes read for users.
+var UserAttributes = append(append([]string{}, ObjectAttributes...),
+	"sAMAccountName", "userPrincipalName", "displayName", "mail", "userAccountControl", "sAMAccountType",
+	"primaryGroupID", "servicePrincipalName", "memberOf", "adminCount", "pwdLastSet", "lastLogonTimestamp",
+	"accountExpires", "msDS-SupportedEncryptionTypes", "msDS-AllowedToDelegateTo",
+)
+
 type User struct {
-	// LdapSession is the LDAP session object
-	LdapSession LdapSessionInterface
-	// DistinguishedName is the distinguished name of the user
-	DistinguishedName string
+	Object
+
 	// sAMAccountName is the sAMAccountName of the user
 	SamAccountName string
+	// UserPrincipalName is the user principal name of the user
+	UserPrincipalName string
+	// DisplayName is the display name of the user
+	DisplayName string
+	// Mail is the email address of the user
+	Mail string
+	// UserAccountControl holds the account flags of the user
+	UserAccountControl ldap_attributes.UserAccountControl
+	// SAMAccountType is the type of the account
+	SAMAccountType ldap_attributes.SAMAccountType
+	// PrimaryGroupID is the RID of the primary group of the user
+	PrimaryGroupID uint32
+	// ServicePrincipalNames are the service principal names of the user
+	ServicePrincipalNames []string
+	// MemberOf are the distinguished names of the groups the user is a direct member of
+	MemberOf []string
+	// AdminCount is true if the user is or was protected by AdminSDHolder
+	AdminCount bool
+	// PwdLastSet is the time of the last password change, zero if the password must be changed at next logon
+	PwdLastSet time.Time
+	// LastLogonTimestamp is the time of the last logon, replicated with a delay of up to 14 days
+	LastLogonTimestamp time.Time
+	// AccountExpires is the expiration time of the account, zero if it never expires
+	AccountExpires time.Time
+	// SupportedEncryptionTypes holds the Kerberos encryption types supported by the account
+	SupportedEncryptionTypes ldap_attributes.SupportedEncryptionTypes
+	// AllowedToDelegateTo are the service principal names of the constrained delegation of the user
+	AllowedToDelegateTo []string
+}
+
+// NewUserFromEntry creates a User from an LDAP entry.
+//
+// Parameters:
+//   - ldapSession: The LDAP session used to resolve the relationships of the user.
+//   - entry: The LDAP entry, read with UserAttributes.
+//
+// Returns:
+//   - A pointer to the User.
+func NewUserFromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) *User {
+	user := &User{}
+	user.fromEntry(ldapSession, entry)
+	return user
+}
+
+// fromEntry fills the attributes of a user from an LDAP entry.
+func (user *User) fromEntry(ldapSession LdapSessionInterface, entry *ldap.Entry) {
+	user.Object.fromEntry(ldapSession, entry)
+	user.SamAccountName = entry.GetAttributeValue("sAMAccountName")
+	user.UserPrincipalName = entry.GetAttributeValue("userPrincipalName")
+	user.DisplayName = entry.GetAttributeValue("displayName")
+	user.Mail = entry.GetAttributeValue("mail")
+	user.UserAccountControl = ldap_attributes.UserAccountControl(getIntegerAttribute(entry, "userAccountControl"))
+	user.SAMAccountType = ldap_attributes.SAMAccountType(getIntegerAttribute(entry, "sAMAccountType"))
+	user.PrimaryGroupID = uint32(getIntegerAttribute(entry, "primaryGroupID"))
+	user.ServicePrincipalNames = entry.GetAttributeValues("servicePrincipalName")
+	user.MemberOf = entry.GetAttributeValues("memberOf")
+	user.AdminCount = getBooleanAttribute(entry, "adminCount")
+	user.PwdLastSet = getFileTimeAttribute(entry, "pwdLastSet")
+	user.LastLogonTimestamp = getFileTimeAttribute(entry, "lastLogonTimestamp")
+	user.AccountExpires = getFileTimeAttribute(entry, "accountExpires")
+	user.SupportedEncryptionTypes = ldap_attributes.SupportedEncryptionTypes(getIntegerAttribute(entry, "msDS-SupportedEncryptionTypes"))
+	user.AllowedToDelegateTo = entry.GetAttributeValues("msDS-AllowedToDelegateTo")
+}
+
+// IsEnabled checks if the account of the user is enabled.
+//
+// Returns:
+//   - True if the ACCOUNT_DISABLED flag is not set in the userAccountControl attribute, false otherwise.
+func (user *User) IsEnabled() bool {
+	return user.UserAccountControl&ldap_attributes.UAF_ACCOUNT_DISABLED == 0
+}
+
+// GetAllUsers retrieves all user objects from the LDAP directory, excluding computers.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the users and the values are pointers to User objects.
+//   - An error if the LDAP query fails.
+func (domain *Domain) GetAllUsers() (map[string]*User, error) {
+	query := "(&(objectCategory=person)(objectClass=user))"
+
+	ldapResults, err := domain.LdapSession.QueryWholeSubtree(domain.DistinguishedName, query, UserAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	usersMap := make(map[string]*User)
+	for _, entry := range ldapResults {
+		user := NewUserFromEntry(domain.LdapSession, entry)
+		usersMap[user.DistinguishedName] = user
+	}
+
+	return usersMap, nil
+}
+
+// GetGroups retrieves the groups the user is a direct member of, from its memberOf attribute. The primary group
+// is not included, see GetPrimaryGroup.
+//
+// Returns:
+//   - A slice of pointers to the Group objects. Groups which cannot be read are skipped.
+//   - An error if the LDAP query fails.
+func (user *User) GetGroups() ([]*Group, error) {
+	return getGroups(user.LdapSession, user.MemberOf)
+}
+
+// GetPrimaryGroup retrieves the primary group of the user, from its primaryGroupID attribute.
+//
+// Returns:
+//   - A pointer to the Group object, or nil if it cannot be found.
+//   - An error if the user has no SID or if the LDAP query fails.
+func (user *User) GetPrimaryGroup() (*Group, error) {
+	if user.ObjectSID == nil {
+		return nil, fmt.Errorf("user %s has no SID", user.DistinguishedName)
+	}
+	if user.LdapSession == nil {
+		return nil, fmt.Errorf("object has no LDAP session")
+	}
+
+	groupSID := sid.FromDomainSIDAndRID(user.ObjectSID.GetDomainSID(), user.PrimaryGroupID)
+	query := fmt.Sprintf("(objectSid=%s)", groupSID.String())
+	ldapResults, err := user.LdapSession.QueryWholeSubtree("defaultNamingContext", query, GroupAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, nil
+	}
+
+	return NewGroupFromEntry(user.LdapSession, ldapResults[0]), nil
 }
@@ -1,10 +1,8 @@
 package ldap
 
 import (
+	"context"
 	"fmt"
-	"strings"
-
-	"github.com/TheManticoreProject/Manticore/logger"
 
 	"github.com/go-ldap/ldap/v3"
 )
@@ -31,67 +29,37 @@ import (
 // If the search is successful, it returns the search results. If an error occurs, it logs a warning
 // and returns an empty slice.
 func (ldapSession *Session) Query(searchBase string, query string, attributes []string, scope int) ([]*ldap.Entry, error) {
-	debug := false
-
-	// Parsing parameters
-	if len(searchBase) == 0 {
-		searchBase = "defaultNamingContext"
-	}
-	rootDSE, err := ldapSession.GetRootDSE()
-	if err != nil {
-		return nil, fmt.Errorf("error fetching RootDSE: %w", err)
-	}
-	if strings.ToLower(searchBase) == "defaultnamingcontext" {
-		if debug {
-			logger.Debug(fmt.Sprintf("Using defaultNamingContext %s ...\n", rootDSE.GetAttributeValue("defaultNamingContext")))
-		}
-		searchBase = rootDSE.GetAttributeValue("defaultNamingContext")
-	} else if strings.ToLower(searchBase) == "configurationnamingcontext" {
-		if debug {
-			logger.Debug(fmt.Sprintf("Using configurationNamingContext %s ...\n", rootDSE.GetAttributeValue("configurationNamingContext")))
-		}
-		searchBase = rootDSE.GetAttributeValue("configurationNamingContext")
-	} else if strings.ToLower(searchBase) == "schemanamingcontext" {
-		if debug {
-			logger.Debug(fmt.Sprintf("Using schemaNamingContext CN=Schema,%s ...\n", rootDSE.GetAttributeValue("configurationNamingContext")))
-		}
-		searchBase = fmt.Sprintf("CN=Schema,%s", rootDSE.GetAttributeValue("configurationNamingContext"))
-	}
-
-	if (scope != ldap.ScopeBaseObject) && (scope != ldap.ScopeSingleLevel) && (scope != ldap.ScopeWholeSubtree) {
-		scope = ldap.ScopeWholeSubtree
-	}
-
-	// Specify LDAP search parameters
-	// https://pkg.go.dev/gopkg.in/ldap.v3#NewSearchRequest
-	searchRequest := ldap.NewSearchRequest(
-		// Base DN
-		searchBase,
-		// Scope
-		scope,
-		// DerefAliases
-		ldap.NeverDerefAliases,
-		// SizeLimit
-		0,
-		// TimeLimit
-		0,
-		// TypesOnly
-		false,
-		// Search filter
-		query,
-		// Attributes to retrieve
-		attributes,
-		// Controls
-		nil,
-	)
+	return ldapSession.QueryWithControls(searchBase, query, attributes, scope, nil)
+}
 
-	// Perform LDAP search
-	searchResult, err := ldapSession.connection.SearchWithPaging(searchRequest, 1000)
+// QueryWithControls performs an LDAP search operation like Query, sending additional controls with the search request.
+//
+// Parameters:
+//   - searchBase: A string representing the base DN from which the search should start. The special values accepted
+//     by Query are also accepted.
+//   - query: A string representing the LDAP search filter.
+//   - attributes: A slice of strings specifying the attributes to retrieve from the search results.
+//   - scope: An integer representing the scope of the search.
+//   - controls: A slice of controls to send with the search request, such as the SD_FLAGS control. The paging
+//     control is added automatically.
+//
+// Returns:
+//   - A slice of pointers to ldap.Entry objects representing the search results.
+//   - An error if the RootDSE cannot be fetched or if the search fails.
+func (ldapSession *Session) QueryWithControls(searchBase string, query string, attributes []string, scope int, controls []Control) ([]*ldap.Entry, error) {
+	iterator := ldapSession.Search(context.Background(), &SearchOptions{
+		SearchBase: searchBase,
+		Filter:     query,
+		Attributes: attributes,
+		Scope:      scope,
+		Controls:   controls,
+	})
+	entries, err := iterator.Collect()
 	if err != nil {
-		return nil, fmt.Errorf("error searching LDAP: %w", err)
+		return nil, err
 	}
 
-	return searchResult.Entries, nil
+	return entries, nil
 }
 
 // QueryBaseObject performs an LDAP query with a scope of Base Object.
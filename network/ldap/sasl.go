@@ -0,0 +1,120 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm"
+)
+
+// Maximum size of a SASL buffer accepted from the server
+const maxSASLBufferSize = 0x1000000
+
+// saslSecurityLayer protects the LDAP messages exchanged after a SASL bind negotiating integrity or confidentiality.
+type saslSecurityLayer interface {
+	Wrap(data []byte) ([]byte, error)
+	Unwrap(data []byte) ([]byte, error)
+}
+
+// saslConn is a connection exchanging the LDAP messages in SASL buffers, each made of its 32-bit big-endian
+// length followed by the data wrapped by the security layer.
+// Src: https://www.rfc-editor.org/rfc/rfc4422#section-3.7
+type saslConn struct {
+	net.Conn
+	layer      saslSecurityLayer
+	readBuffer []byte
+	writeMutex sync.Mutex
+}
+
+// newSASLConn wraps a connection on which a SASL bind negotiated a security layer.
+func newSASLConn(conn net.Conn, layer saslSecurityLayer) *saslConn {
+	return &saslConn{Conn: conn, layer: layer}
+}
+
+// Read reads the unwrapped data of the SASL buffers received from the server.
+func (c *saslConn) Read(p []byte) (int, error) {
+	for len(c.readBuffer) == 0 {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(c.Conn, header); err != nil {
+			return 0, err
+		}
+		length := binary.BigEndian.Uint32(header)
+		if length > maxSASLBufferSize {
+			return 0, fmt.Errorf("SASL buffer of %d bytes exceeds the maximum size", length)
+		}
+		buffer := make([]byte, length)
+		if _, err := io.ReadFull(c.Conn, buffer); err != nil {
+			return 0, err
+		}
+
+		data, err := c.layer.Unwrap(buffer)
+		if err != nil {
+			return 0, fmt.Errorf("error unwrapping SASL buffer: %w", err)
+		}
+		c.readBuffer = data
+	}
+
+	n := copy(p, c.readBuffer)
+	c.readBuffer = c.readBuffer[n:]
+	return n, nil
+}
+
+// Write wraps data in a SASL buffer sent to the server.
+func (c *saslConn) Write(p []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	wrapped, err := c.layer.Wrap(p)
+	if err != nil {
+		return 0, fmt.Errorf("error wrapping SASL buffer: %w", err)
+	}
+	buffer := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(wrapped)), uint32(len(wrapped)))
+	if _, err := c.Conn.Write(append(buffer, wrapped...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ntlmSecurityLayer signs, and seals if negotiated, the LDAP messages with NTLM session security. Each
+// wrapped buffer holds the NTLMSSP_MESSAGE_SIGNATURE followed by the message.
+type ntlmSecurityLayer struct {
+	client *ntlm.Client
+}
+
+// Wrap signs or seals data sent to the server.
+func (l *ntlmSecurityLayer) Wrap(data []byte) ([]byte, error) {
+	if l.client.IsSealing() {
+		sealed, signature, err := l.client.Seal(data)
+		if err != nil {
+			return nil, err
+		}
+		return append(signature, sealed...), nil
+	}
+
+	signature, err := l.client.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(signature, data...), nil
+}
+
+// Unwrap verifies or unseals data received from the server.
+func (l *ntlmSecurityLayer) Unwrap(data []byte) ([]byte, error) {
+	if len(data) < ntlm.NTLMSSP_MESSAGE_SIGNATURE_SIZE {
+		return nil, errors.New("buffer is shorter than a signature")
+	}
+	signature := data[:ntlm.NTLMSSP_MESSAGE_SIGNATURE_SIZE]
+	message := data[ntlm.NTLMSSP_MESSAGE_SIGNATURE_SIZE:]
+
+	if l.client.IsSealing() {
+		return l.client.Unseal(message, signature)
+	}
+	if err := l.client.Verify(message, signature); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
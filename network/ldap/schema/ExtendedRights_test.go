@@ -0,0 +1,21 @@
+package schema
+
+import (
+	"testing"
+)
+
+func Test_ExtendedRightDisplayNameToGUID_In_GUIDToExtendedRightDisplayName(t *testing.T) {
+	for extendedRightDisplayName, extendedRightGUID := range ExtendedRightDisplayNameToGUID {
+		if _, exists := GUIDToExtendedRightDisplayName[extendedRightGUID]; !exists {
+			t.Errorf("Key %s from ExtendedRightDisplayNameToGUID not found in GUIDToExtendedRightDisplayName", extendedRightDisplayName)
+		}
+	}
+}
+
+func Test_GUIDToExtendedRightDisplayName_In_ExtendedRightDisplayNameToGUID(t *testing.T) {
+	for extendedRightGUID, extendedRightDisplayName := range GUIDToExtendedRightDisplayName {
+		if _, exists := ExtendedRightDisplayNameToGUID[extendedRightDisplayName]; !exists {
+			t.Errorf("Key %s from GUIDToExtendedRightDisplayName not found in ExtendedRightDisplayNameToGUID", extendedRightGUID)
+		}
+	}
+}
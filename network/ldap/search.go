@@ -0,0 +1,330 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// Default number of entries requested per page by a search
+const DEFAULT_SEARCH_PAGE_SIZE uint32 = 1000
+
+// SearchOptions holds the parameters of a streaming search.
+//
+// Fields:
+//   - SearchBase: The base DN of the search. The special values "defaultNamingContext", "configurationNamingContext"
+//     and "schemaNamingContext" are replaced with the corresponding values from the Root DSE. An empty value
+//     searches the defaultNamingContext.
+//   - Filter: The LDAP search filter. An empty value matches all the objects.
+//   - Attributes: The attributes to retrieve for each entry.
+//   - Scope: The scope of the search, ScopeBaseObject, ScopeSingleLevel or ScopeWholeSubtree.
+//   - PageSize: The number of entries requested per page, DEFAULT_SEARCH_PAGE_SIZE if 0. Ignored if a paging control
+//     is given in Controls.
+//   - SizeLimit: The maximum number of entries returned by the search, unlimited if 0. The search stops without
+//     error when the limit is reached.
+//   - TimeLimit: The maximum time in seconds allowed to the server to process each page, unlimited if 0.
+//   - Controls: Additional controls to send with the search request, such as the SD_FLAGS control.
+//   - BufferSize: The number of entries buffered while they are not consumed. Entries are not buffered if 0.
+type SearchOptions struct {
+	SearchBase string
+	Filter     string
+	Attributes []string
+	Scope      int
+	PageSize   uint32
+	SizeLimit  int
+	TimeLimit  int
+	Controls   []Control
+	BufferSize int
+}
+
+// SearchIterator iterates over the entries of a paged search, fetching the pages from the server as the entries
+// are consumed, so that the memory used does not depend on the number of entries.
+//
+// Example:
+//
+//	iterator := ldapSession.Search(ctx, &SearchOptions{Filter: "(objectClass=user)", Attributes: []string{"sAMAccountName"}})
+//	defer iterator.Close()
+//	for iterator.Next() {
+//	    fmt.Println(iterator.Entry().GetAttributeValue("sAMAccountName"))
+//	}
+//	if err := iterator.Err(); err != nil {
+//	    return err
+//	}
+type SearchIterator struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	session    *Session
+	request    *goldapv3.SearchRequest
+	paging     *goldapv3.ControlPaging
+	sizeLimit  int
+	bufferSize int
+
+	response  goldapv3.Response
+	cookie    []byte
+	entry     *Entry
+	referrals []string
+	count     int
+	done      bool
+	err       error
+}
+
+// Search starts a streaming search. The first page is requested by the first call to Next.
+//
+// Parameters:
+//   - ctx: A context whose cancellation stops the search.
+//   - options: A pointer to the parameters of the search.
+//
+// Returns:
+//   - A pointer to a SearchIterator over the entries of the search. Errors, including the ones encountered while
+//     preparing the search, are returned by its Err method.
+func (ldapSession *Session) Search(ctx context.Context, options *SearchOptions) *SearchIterator {
+	iteratorCtx, cancel := context.WithCancel(ctx)
+	iterator := &SearchIterator{
+		ctx:        iteratorCtx,
+		cancel:     cancel,
+		session:    ldapSession,
+		sizeLimit:  options.SizeLimit,
+		bufferSize: options.BufferSize,
+		referrals:  []string{},
+	}
+
+	searchBase, err := ldapSession.resolveSearchBase(options.SearchBase)
+	if err != nil {
+		iterator.fail(err)
+		return iterator
+	}
+
+	filter := options.Filter
+	if len(filter) == 0 {
+		filter = "(objectClass=*)"
+	}
+	scope := options.Scope
+	if (scope != ScopeBaseObject) && (scope != ScopeSingleLevel) && (scope != ScopeWholeSubtree) {
+		scope = ScopeWholeSubtree
+	}
+
+	// The controls are copied as the paging control is added to them
+	controls := append([]Control{}, options.Controls...)
+	if index := slices.IndexFunc(controls, func(control Control) bool {
+		return control.GetControlType() == goldapv3.ControlTypePaging
+	}); index != -1 {
+		paging, ok := controls[index].(*goldapv3.ControlPaging)
+		if !ok {
+			iterator.fail(fmt.Errorf("expected paging control to be of type *ControlPaging, got %T", controls[index]))
+			return iterator
+		}
+		// The cookie and the page size are updated during the search, so the control of the caller is copied too
+		copied := *paging
+		iterator.paging = &copied
+		controls[index] = iterator.paging
+	} else {
+		pageSize := options.PageSize
+		if pageSize == 0 {
+			pageSize = DEFAULT_SEARCH_PAGE_SIZE
+		}
+		iterator.paging = goldapv3.NewControlPaging(pageSize)
+		controls = append(controls, iterator.paging)
+	}
+
+	iterator.request = goldapv3.NewSearchRequest(
+		searchBase,
+		scope,
+		goldapv3.NeverDerefAliases,
+		// The size limit is enforced by the iterator, as the server would end the search with an error
+		0,
+		options.TimeLimit,
+		false,
+		filter,
+		options.Attributes,
+		controls,
+	)
+
+	return iterator
+}
+
+// Next advances the iterator to the next entry, requesting the next page from the server when needed.
+//
+// Returns:
+//   - True if an entry is available through Entry, false when the search is complete, has failed or was stopped.
+func (it *SearchIterator) Next() bool {
+	for !it.done {
+		if err := it.ctx.Err(); err != nil {
+			it.fail(err)
+			return false
+		}
+		if it.sizeLimit > 0 && it.count >= it.sizeLimit {
+			it.Close()
+			return false
+		}
+
+		if it.response == nil {
+			it.response = it.session.connection.SearchAsync(it.ctx, it.request, it.bufferSize)
+		}
+
+		if it.response.Next() {
+			if entry := it.response.Entry(); entry != nil {
+				it.entry = entry
+				it.count++
+				return true
+			}
+			it.readResult()
+			continue
+		}
+
+		// The current page is complete, or failed
+		if err := it.response.Err(); err != nil {
+			it.fail(fmt.Errorf("error searching LDAP: %w", err))
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.fail(err)
+			return false
+		}
+		it.response = nil
+		if len(it.cookie) == 0 {
+			it.finish()
+			return false
+		}
+		it.paging.SetCookie(it.cookie)
+		it.cookie = nil
+	}
+	return false
+}
+
+// Entry returns the current entry of the iterator.
+//
+// Returns:
+//   - A pointer to the entry read by the last successful call to Next.
+func (it *SearchIterator) Entry() *Entry {
+	return it.entry
+}
+
+// Referrals returns the referrals received so far.
+//
+// Returns:
+//   - A slice of strings containing the LDAP URLs of the search result references.
+func (it *SearchIterator) Referrals() []string {
+	return it.referrals
+}
+
+// Count returns the number of entries read so far.
+//
+// Returns:
+//   - The number of entries returned by Next.
+func (it *SearchIterator) Count() int {
+	return it.count
+}
+
+// Err returns the error which stopped the search.
+//
+// Returns:
+//   - The error of the search, the error of the context if it was cancelled, or nil if the search completed or
+//     was closed.
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+// Close stops the search. If pages remain, the rest of the current page is skipped and the paged search is
+// abandoned on the server. It is safe to call Close several times and after the search is complete.
+func (it *SearchIterator) Close() {
+	if it.done {
+		return
+	}
+
+	// Read the end of the current page to get the cookie of the paged search
+	pending := false
+	if it.response != nil {
+		it.cookie = nil
+		for it.response.Next() {
+			it.readResult()
+		}
+		pending = len(it.cookie) != 0 && it.response.Err() == nil
+		if pending {
+			it.paging.SetCookie(it.cookie)
+		}
+		it.response = nil
+	} else if it.paging != nil {
+		pending = len(it.paging.Cookie) != 0
+	}
+	it.finish()
+
+	// Abandon the paged search on the server, as done by SearchWithPaging
+	if pending {
+		it.paging.PagingSize = 0
+		_, _ = it.session.connection.Search(it.request)
+	}
+}
+
+// Collect reads all the remaining entries of the iterator and closes it.
+//
+// Returns:
+//   - A slice of pointers to the entries read.
+//   - An error if the search failed.
+func (it *SearchIterator) Collect() ([]*Entry, error) {
+	defer it.Close()
+	entries := []*Entry{}
+	for it.Next() {
+		entries = append(entries, it.Entry())
+	}
+	return entries, it.Err()
+}
+
+// readResult records the referral or the paging cookie of a result of the page which is not an entry.
+func (it *SearchIterator) readResult() {
+	if referral := it.response.Referral(); len(referral) != 0 {
+		it.referrals = append(it.referrals, referral)
+	}
+	if control := goldapv3.FindControl(it.response.Controls(), goldapv3.ControlTypePaging); control != nil {
+		if paging, ok := control.(*goldapv3.ControlPaging); ok {
+			it.cookie = paging.Cookie
+		}
+	}
+}
+
+// fail stops the iterator with an error.
+func (it *SearchIterator) fail(err error) {
+	it.err = err
+	it.finish()
+}
+
+// finish stops the iterator, waiting for the pending page to be released.
+func (it *SearchIterator) finish() {
+	it.done = true
+	it.entry = nil
+	it.cancel()
+	if it.response != nil {
+		// The search goroutine may be blocked sending an entry, drain it until it notices the cancellation
+		for it.response.Next() {
+		}
+		it.response = nil
+	}
+}
+
+// resolveSearchBase replaces the special search bases with their values from the Root DSE.
+func (ldapSession *Session) resolveSearchBase(searchBase string) (string, error) {
+	if len(searchBase) == 0 {
+		searchBase = "defaultNamingContext"
+	}
+
+	switch strings.ToLower(searchBase) {
+	case "defaultnamingcontext", "configurationnamingcontext", "schemanamingcontext":
+	default:
+		return searchBase, nil
+	}
+
+	rootDSE, err := ldapSession.GetRootDSE()
+	if err != nil {
+		return "", fmt.Errorf("error fetching RootDSE: %w", err)
+	}
+	switch strings.ToLower(searchBase) {
+	case "defaultnamingcontext":
+		return rootDSE.GetAttributeValue("defaultNamingContext"), nil
+	case "configurationnamingcontext":
+		return rootDSE.GetAttributeValue("configurationNamingContext"), nil
+	default:
+		return fmt.Sprintf("CN=Schema,%s", rootDSE.GetAttributeValue("configurationNamingContext")), nil
+	}
+}
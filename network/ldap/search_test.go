@@ -0,0 +1,244 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// testSearchRequest holds the parameters of a search request received by the test server.
+type testSearchRequest struct {
+	baseDN   string
+	pageSize uint32
+	cookie   string
+}
+
+// testSearchServer serves paged searches over a fixed number of entries, answering the Root DSE searches.
+type testSearchServer struct {
+	entries  int
+	mutex    sync.Mutex
+	requests []testSearchRequest
+}
+
+// newTestSearchResult creates an LDAP message holding a search result entry or a search result done.
+func newTestSearchResult(messageID int64, result *ber.Packet, controls ...ldap.Control) []byte {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	envelope.AppendChild(result)
+	if len(controls) != 0 {
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			packet.AppendChild(control.Encode())
+		}
+		envelope.AppendChild(packet)
+	}
+	return envelope.Bytes()
+}
+
+// newTestSearchResultEntry creates a search result entry with a single attribute.
+func newTestSearchResultEntry(dn string, attribute string, value string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	partialAttribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Partial Attribute")
+	partialAttribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute, "Type"))
+	values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+	partialAttribute.AppendChild(values)
+	attributes.AppendChild(partialAttribute)
+	entry.AppendChild(attributes)
+	return entry
+}
+
+// newTestSearchResultDone creates a successful search result done.
+func newTestSearchResultDone() *ber.Packet {
+	done := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultDone, nil, "Search Result Done")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(ldap.LDAPResultSuccess), "Result Code"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return done
+}
+
+// serve answers the requests received on a connection until it is closed.
+func (server *testSearchServer) serve(conn net.Conn) {
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		if packet.Children[1].Tag != ldap.ApplicationSearchRequest {
+			continue
+		}
+
+		request := testSearchRequest{baseDN: packet.Children[1].Children[0].Value.(string)}
+		if len(packet.Children) == 3 {
+			for _, child := range packet.Children[2].Children {
+				control, err := ldap.DecodeControl(child)
+				if err != nil {
+					continue
+				}
+				if paging, ok := control.(*ldap.ControlPaging); ok {
+					request.pageSize = paging.PagingSize
+					request.cookie = string(paging.Cookie)
+				}
+			}
+		}
+		server.mutex.Lock()
+		server.requests = append(server.requests, request)
+		server.mutex.Unlock()
+
+		responses := [][]byte{}
+		if len(request.baseDN) == 0 {
+			responses = append(responses, newTestSearchResult(messageID, newTestSearchResultEntry("", "defaultNamingContext", "DC=lab,DC=local")))
+			responses = append(responses, newTestSearchResult(messageID, newTestSearchResultDone()))
+		} else {
+			// The cookie holds the index of the first entry of the page
+			start, _ := strconv.Atoi(request.cookie)
+			end := server.entries
+			if request.pageSize == 0 {
+				end = start
+			} else if start+int(request.pageSize) < end {
+				end = start + int(request.pageSize)
+			}
+			for k := start; k < end; k++ {
+				dn := fmt.Sprintf("CN=user%d,%s", k, request.baseDN)
+				responses = append(responses, newTestSearchResult(messageID, newTestSearchResultEntry(dn, "sAMAccountName", fmt.Sprintf("user%d", k))))
+			}
+			paging := ldap.NewControlPaging(0)
+			if request.pageSize != 0 && end < server.entries {
+				paging.SetCookie([]byte(strconv.Itoa(end)))
+			}
+			responses = append(responses, newTestSearchResult(messageID, newTestSearchResultDone(), paging))
+		}
+		for _, response := range responses {
+			if _, err := conn.Write(response); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// getRequests returns the search requests received so far.
+func (server *testSearchServer) getRequests() []testSearchRequest {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]testSearchRequest{}, server.requests...)
+}
+
+// newTestSearchSession creates a session connected to a test server serving a number of entries.
+func newTestSearchSession(t *testing.T, entries int) (*Session, *testSearchServer) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	server := &testSearchServer{entries: entries}
+	go server.serve(serverConn)
+
+	connection := ldap.NewConn(clientConn, false)
+	connection.Start()
+	t.Cleanup(func() {
+		connection.Close()
+		serverConn.Close()
+	})
+
+	return &Session{connection: connection}, server
+}
+
+func TestSearchPaging(t *testing.T) {
+	session, server := newTestSearchSession(t, 5)
+
+	iterator := session.Search(context.Background(), &SearchOptions{Attributes: []string{"sAMAccountName"}, PageSize: 2})
+	entries, err := iterator.Collect()
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %d", len(entries))
+	}
+	for k, entry := range entries {
+		if value := entry.GetAttributeValue("sAMAccountName"); value != fmt.Sprintf("user%d", k) {
+			t.Errorf("Expected entry %d to be user%d, got %s", k, k, value)
+		}
+	}
+
+	// The Root DSE is fetched once, then the three pages are requested with the cookies of the previous pages
+	expected := []testSearchRequest{{"", 0, ""}, {"DC=lab,DC=local", 2, ""}, {"DC=lab,DC=local", 2, "2"}, {"DC=lab,DC=local", 2, "4"}}
+	requests := server.getRequests()
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+
+	if _, err := session.Query("defaultNamingContext", "(objectClass=user)", nil, ScopeWholeSubtree); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if requests := server.getRequests(); len(requests) != 5 || requests[4].baseDN != "DC=lab,DC=local" || requests[4].pageSize != DEFAULT_SEARCH_PAGE_SIZE {
+		t.Errorf("Expected a single request without fetching the Root DSE again, got %v", requests)
+	}
+}
+
+func TestSearchSizeLimit(t *testing.T) {
+	session, server := newTestSearchSession(t, 5)
+
+	iterator := session.Search(context.Background(), &SearchOptions{SearchBase: "DC=lab,DC=local", PageSize: 2, SizeLimit: 3})
+	entries, err := iterator.Collect()
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	// The paged search is abandoned with a page size of 0 and the cookie of the last page
+	requests := server.getRequests()
+	if last := requests[len(requests)-1]; last.pageSize != 0 || last.cookie != "4" {
+		t.Errorf("Expected the paged search to be abandoned, got %v", requests)
+	}
+}
+
+func TestSearchCallerPagingControl(t *testing.T) {
+	session, server := newTestSearchSession(t, 5)
+
+	paging := ldap.NewControlPaging(2)
+	iterator := session.Search(context.Background(), &SearchOptions{SearchBase: "DC=lab,DC=local", Controls: []Control{paging}, SizeLimit: 3})
+	if _, err := iterator.Collect(); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	requests := server.getRequests()
+	if last := requests[len(requests)-1]; last.pageSize != 0 || last.cookie != "4" {
+		t.Errorf("Expected the paged search to be abandoned, got %v", requests)
+	}
+	// The search works on a copy of the paging control of the caller
+	if paging.PagingSize != 2 || len(paging.Cookie) != 0 {
+		t.Errorf("Expected the paging control of the caller to be unchanged, got size %d and cookie %q", paging.PagingSize, paging.Cookie)
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	session, _ := newTestSearchSession(t, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	iterator := session.Search(ctx, &SearchOptions{SearchBase: "DC=lab,DC=local", PageSize: 2})
+	defer iterator.Close()
+
+	if !iterator.Next() {
+		t.Fatalf("Expected an entry, got error %v", iterator.Err())
+	}
+	cancel()
+	if iterator.Next() {
+		t.Error("Expected the search to stop after the cancellation")
+	}
+	if !errors.Is(iterator.Err(), context.Canceled) {
+		t.Errorf("Expected error %v, got %v", context.Canceled, iterator.Err())
+	}
+	if iterator.Count() != 1 {
+		t.Errorf("Expected 1 entry, got %d", iterator.Count())
+	}
+}
@@ -0,0 +1,109 @@
+package ldap
+
+import (
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/windows/security"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// Attribute holding the security descriptor of an object
+const ATTRIBUTE_NT_SECURITY_DESCRIPTOR = "nTSecurityDescriptor"
+
+// NewSDFlagsControl creates a LDAP_SERVER_SD_FLAGS_OID control, selecting the parts of the nTSecurityDescriptor
+// attribute returned by a search or written by a modify operation.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/3888c2b7-35b9-45b7-afeb-b772aa932dd0
+//
+// Parameters:
+//   - flags: A combination of the security information flags, such as security.OWNER_SECURITY_INFORMATION and
+//     security.DACL_SECURITY_INFORMATION.
+//
+// Returns:
+//   - A goldapv3.Control holding the BER encoded SDFlagsRequestValue.
+func NewSDFlagsControl(flags uint32) goldapv3.Control {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SDFlagsRequestValue")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(flags), "Flags"))
+
+	return &goldapv3.ControlString{
+		ControlType:  LDAP_SERVER_SD_FLAGS_OID,
+		Criticality:  true,
+		ControlValue: string(value.Bytes()),
+	}
+}
+
+// GetSecurityDescriptor retrieves the owner, group and DACL of the security descriptor of an object.
+//
+// The SACL is not requested, as reading it requires the SeSecurityPrivilege.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the object.
+//
+// Returns:
+//   - A pointer to the parsed security descriptor of the object.
+//   - An error if the LDAP query fails, if the object is not found or if the security descriptor cannot be parsed.
+func (ldapSession *Session) GetSecurityDescriptor(distinguishedName string) (*security.SecurityDescriptor, error) {
+	flags := security.OWNER_SECURITY_INFORMATION | security.GROUP_SECURITY_INFORMATION | security.DACL_SECURITY_INFORMATION
+	controls := []Control{NewSDFlagsControl(flags)}
+	attributes := []string{ATTRIBUTE_NT_SECURITY_DESCRIPTOR}
+
+	ldapResults, err := ldapSession.QueryWithControls(distinguishedName, "(objectClass=*)", attributes, ScopeBaseObject, controls)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("no object found with distinguished name %s", distinguishedName)
+	}
+
+	data := ldapResults[0].GetRawAttributeValue(ATTRIBUTE_NT_SECURITY_DESCRIPTOR)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no security descriptor returned for %s", distinguishedName)
+	}
+
+	securityDescriptor := &security.SecurityDescriptor{}
+	if err := securityDescriptor.FromBytes(data); err != nil {
+		return nil, fmt.Errorf("error parsing security descriptor of %s: %w", distinguishedName, err)
+	}
+
+	return securityDescriptor, nil
+}
+
+// GetSecurityDescriptors retrieves the owner, group and DACL of the security descriptors of all the objects
+// matching a query in the subtree of a search base.
+//
+// Parameters:
+//   - searchBase: A string representing the base DN of the search. The special values accepted by Query are
+//     also accepted.
+//   - query: A string representing the LDAP search filter.
+//
+// Returns:
+//   - A map where the keys are the distinguished names of the objects and the values are their parsed
+//     security descriptors. Objects without a readable security descriptor are omitted.
+//   - An error if the LDAP query fails or if a security descriptor cannot be parsed.
+func (ldapSession *Session) GetSecurityDescriptors(searchBase string, query string) (map[string]*security.SecurityDescriptor, error) {
+	flags := security.OWNER_SECURITY_INFORMATION | security.GROUP_SECURITY_INFORMATION | security.DACL_SECURITY_INFORMATION
+	controls := []Control{NewSDFlagsControl(flags)}
+	attributes := []string{"distinguishedName", ATTRIBUTE_NT_SECURITY_DESCRIPTOR}
+
+	ldapResults, err := ldapSession.QueryWithControls(searchBase, query, attributes, ScopeWholeSubtree, controls)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+
+	securityDescriptors := make(map[string]*security.SecurityDescriptor)
+	for _, entry := range ldapResults {
+		data := entry.GetRawAttributeValue(ATTRIBUTE_NT_SECURITY_DESCRIPTOR)
+		if len(data) == 0 {
+			continue
+		}
+
+		securityDescriptor := &security.SecurityDescriptor{}
+		if err := securityDescriptor.FromBytes(data); err != nil {
+			return nil, fmt.Errorf("error parsing security descriptor of %s: %w", entry.DN, err)
+		}
+		securityDescriptors[entry.DN] = securityDescriptor
+	}
+
+	return securityDescriptors, nil
+}
@@ -3,13 +3,16 @@ package ldap
 import (
 	"crypto/tls"
 	"fmt"
+	"sync"
 
 	"github.com/TheManticoreProject/Manticore/windows/credentials"
 
 	"github.com/TheManticoreProject/Manticore/network/kerberos"
+	nettls "github.com/TheManticoreProject/Manticore/network/tls"
 	"github.com/go-ldap/ldap/v3"
 	"github.com/go-ldap/ldap/v3/gssapi"
 	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
 )
 
 // Session represents an LDAP session with configuration and connection details.
@@ -25,6 +28,12 @@ import (
 //	debug (bool): A flag indicating whether to enable debug mode.
 //	useldaps (bool): A flag indicating whether to use LDAPS (LDAP over SSL).
 //	usekerberos (bool): A flag indicating whether to use Kerberos for authentication.
+//	ntlmBindMechanism (NTLMBindMechanism): The bind operation used to authenticate with NTLM.
+//	tlsConfig (*tls.Config): The TLS configuration used by LDAPS and StartTLS connections.
+//	usestarttls (bool): A flag indicating whether to upgrade the connection to TLS with StartTLS.
+//	rootDSE (*ldap.Entry): The Root DSE of the server, cached for the lifetime of the connection.
+//	attributeSyntaxes (map[string]string): The attributeSyntax of the attributes read from the schema, by lowercase name.
+//	attributeSyntaxesMutex (sync.Mutex): Guards attributeSyntaxes, as attributes may be decoded concurrently.
 //
 // Example:
 //
@@ -45,8 +54,16 @@ type Session struct {
 	// Credentials
 	credentials *credentials.Credentials
 	// Config
-	useldaps    bool
-	usekerberos bool
+	useldaps          bool
+	usekerberos       bool
+	krb5Conf          *config.Config
+	ntlmBindMechanism NTLMBindMechanism
+	tlsConfig         *tls.Config
+	usestarttls       bool
+	// Cache
+	rootDSE                *ldap.Entry
+	attributeSyntaxes      map[string]string
+	attributeSyntaxesMutex sync.Mutex
 }
 
 // InitSession initializes the LDAP session with the provided configuration and credentials.
@@ -98,6 +115,29 @@ func (s *Session) InitSession(host string, port int, credentials *credentials.Cr
 	return nil
 }
 
+// SetKerberosConfig sets the Kerberos configuration used for the Kerberos authentication of the session.
+//
+// Parameters:
+//
+//	krb5Conf (*config.Config): The Kerberos configuration, usually built with kerberos.NewRealmConfig or loaded
+//	from a krb5.conf file with kerberos.NewRealmConfigFromFile. If nil, the LDAP server is used as the KDC of the
+//	realm of the credentials.
+//
+// Note:
+//
+//	This is needed when the KDCs are not the LDAP server, or when referrals to other realms must be followed.
+func (s *Session) SetKerberosConfig(krb5Conf *config.Config) {
+	s.krb5Conf = krb5Conf
+}
+
+// getKerberosConfig returns the service principal name of the LDAP server and the Kerberos configuration of the session.
+func (s *Session) getKerberosConfig() (string, *config.Config, error) {
+	if s.krb5Conf != nil {
+		return fmt.Sprintf("ldap/%s", s.host), s.krb5Conf, nil
+	}
+	return kerberos.NewKerberosConfig(s.host, s.credentials.GetDomain())
+}
+
 // Connect establishes a connection to the LDAP server. It supports both regular LDAP and LDAPS connections,
 // and can optionally use Kerberos for authentication.
 //
@@ -123,23 +163,38 @@ func (s *Session) InitSession(host string, port int, credentials *credentials.Cr
 // Note:
 //
 //	The function uses the configuration set in the Session struct to determine the connection parameters.
-//	If useldaps is true, it will attempt to establish an LDAPS connection. If usekerberos is true, it will
-//	use Kerberos for authentication.
+//	If useldaps is true, it will attempt to establish an LDAPS connection, and if usestarttls is true, the
+//	LDAP connection is upgraded with StartTLS. Both use the TLS configuration set with SetTLSConfig. If
+//	usekerberos is true, it will use Kerberos for authentication. Otherwise, an NTLM bind is used to pass the
+//	hash or when set with SetNTLMBindMechanism, a SASL EXTERNAL bind is used with a TLS client certificate,
+//	and a simple bind is used with a password. Kerberos and NTLM binds over TLS carry channel bindings.
 func (s *Session) Connect() (bool, error) {
 	// Set up LDAP connection
 	var ldapConnection *ldap.Conn
 	var err error
 
+	// The Root DSE and the schema are fetched again on the new connection
+	s.rootDSE = nil
+	s.attributeSyntaxesMutex.Lock()
+	s.attributeSyntaxes = nil
+	s.attributeSyntaxesMutex.Unlock()
+
+	// Use NTLM authentication, which binds before the LDAP client takes over the connection
+	if !s.usekerberos && s.useNTLM() {
+		ldapConnection, err = s.connectWithNTLM()
+		if err != nil {
+			return false, err
+		}
+		s.connection = ldapConnection
+		return true, nil
+	}
+
 	// Connect to remote server
 	if s.useldaps {
 		// LDAPS connection
 		ldapConnection, err = ldap.DialURL(
 			fmt.Sprintf("ldaps://%s:%d", s.host, s.port),
-			ldap.DialWithTLSConfig(
-				&tls.Config{
-					InsecureSkipVerify: true,
-				},
-			),
+			ldap.DialWithTLSConfig(s.getTLSConfig()),
 		)
 		if err != nil {
 			return false, fmt.Errorf("error connecting to LDAPS server: %s", err)
@@ -152,11 +207,23 @@ func (s *Session) Connect() (bool, error) {
 		if err != nil {
 			return false, fmt.Errorf("error connecting to LDAP server: %s", err)
 		}
+
+		if s.usestarttls {
+			err = ldapConnection.StartTLS(s.getTLSConfig())
+			if err != nil {
+				ldapConnection.Close()
+				return false, fmt.Errorf("error starting TLS: %w", err)
+			}
+		}
 	}
 
 	// Use Kerberos
 	if s.usekerberos {
-		servicePrincipalName, krb5Conf := kerberos.KerberosInit(s.host, s.credentials.GetDomain())
+		servicePrincipalName, krb5Conf, err := s.getKerberosConfig()
+		if err != nil {
+			ldapConnection.Close()
+			return false, err
+		}
 
 		// Initialize kerberos client
 		// Inspired from: https://github.com/go-ldap/ldap/blob/06d50d1ad03bcd323e48f2fe174d95ceb31b8b90/v3/gssapi/client.go#L51
@@ -174,28 +241,41 @@ func (s *Session) Connect() (bool, error) {
 		}
 		defer kerberosClient.Close()
 
+		var gssapiClient ldap.GSSAPIClient = &kerberosClient
+		if s.usesTLS() {
+			// Bind the authentication to the TLS connection for the domain controllers enforcing channel binding
+			state, _ := ldapConnection.TLSConnectionState()
+			applicationData, err := nettls.GetTLSServerEndPoint(state)
+			if err != nil {
+				ldapConnection.Close()
+				return false, err
+			}
+			gssapiClient = &channelBindingGSSAPIClient{
+				Client:              &kerberosClient,
+				channelBindingsHash: nettls.GetChannelBindingsHash(applicationData),
+			}
+		}
+
 		err = ldapConnection.GSSAPIBindRequest(
-			&kerberosClient,
+			gssapiClient,
 			&ldap.GSSAPIBindRequest{
 				ServicePrincipalName: servicePrincipalName,
 				AuthZID:              "",
 			},
 		)
 		if err != nil {
+			ldapConnection.Close()
 			return false, fmt.Errorf("error binding with Kerberos: %w", err)
 		}
+	} else if s.useExternalBind() {
+		// Use the TLS client certificate
+		err = ldapConnection.ExternalBind()
+		if err != nil {
+			return false, fmt.Errorf("error binding with client certificate: %w", err)
+		}
 	} else {
-		// Use NTLM authentification or null auth
-		if s.credentials.CanPassTheHash() {
-			// Bind with Pass the NT Hash
-			if len(s.credentials.GetPassword()) > 0 {
-				// Binding with credentials
-				err = ldapConnection.Bind(fmt.Sprintf("%s@%s", s.credentials.GetUsername(), s.credentials.GetDomain()), s.credentials.GetPassword())
-				if err != nil {
-					return false, fmt.Errorf("error binding with Pass the NT Hash: %w", err)
-				}
-			}
-		} else if len(s.credentials.GetPassword()) > 0 {
+		// Use simple authentication or null auth
+		if len(s.credentials.GetPassword()) > 0 {
 			// Binding with credentials
 			err = ldapConnection.Bind(fmt.Sprintf("%s@%s", s.credentials.GetUsername(), s.credentials.GetDomain()), s.credentials.GetPassword())
 			if err != nil {
@@ -256,4 +336,8 @@ func (s *Session) ReConnect() (bool, error) {
 //	This function assumes that the Session struct has a valid connection object.
 func (s *Session) Close() {
 	s.connection.Close()
+	s.rootDSE = nil
+	s.attributeSyntaxesMutex.Lock()
+	s.attributeSyntaxes = nil
+	s.attributeSyntaxesMutex.Unlock()
 }
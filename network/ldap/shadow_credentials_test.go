@@ -0,0 +1,77 @@
+package ldap
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	keycredentiallink "github.com/TheManticoreProject/Manticore/windows/keycredential"
+	"github.com/TheManticoreProject/Manticore/windows/keycredential/crypto"
+)
+
+func TestParseShadowCredentials(t *testing.T) {
+	certificate, err := crypto.NewX509Certificate("alice", SHADOW_CREDENTIAL_KEY_SIZE, time.Now(), time.Now().AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatalf("NewX509Certificate error = %v", err)
+	}
+	deviceID := guid.NewGUID()
+
+	dnWithBinary, err := keycredentiallink.NewKeyCredentialFromCertificate(certificate, *deviceID).ToDNWithBinary("CN=alice,CN=Users,DC=lab,DC=local")
+	if err != nil {
+		t.Fatalf("ToDNWithBinary error = %v", err)
+	}
+
+	shadowCredentials, err := ParseShadowCredentials([]string{dnWithBinary.ToString()})
+	if err != nil {
+		t.Fatalf("ParseShadowCredentials error = %v", err)
+	}
+	if len(shadowCredentials) != 1 {
+		t.Fatalf("Expected 1 shadow credential, got %d", len(shadowCredentials))
+	}
+	if !shadowCredentials[0].KeyCredential.DeviceId.Equal(deviceID) {
+		t.Errorf("Expected device ID %s, got %s", deviceID.ToFormatD(), shadowCredentials[0].KeyCredential.DeviceId.ToFormatD())
+	}
+	if shadowCredentials[0].Value != dnWithBinary.ToString() {
+		t.Errorf("Expected the raw value to be kept")
+	}
+	if err := shadowCredentials[0].ExportPEM("", ""); err == nil {
+		t.Errorf("Expected an error exporting a shadow credential without certificate")
+	}
+
+	if _, err := ParseShadowCredentials([]string{"B:4:zz:CN=alice"}); err == nil {
+		t.Errorf("Expected an error for a malformed value")
+	}
+}
+
+func TestShadowCredentialExportPFX(t *testing.T) {
+	certificate, err := crypto.NewX509Certificate("alice", SHADOW_CREDENTIAL_KEY_SIZE, time.Now(), time.Now().AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatalf("NewX509Certificate error = %v", err)
+	}
+	shadowCredential := &ShadowCredential{
+		KeyCredential: keycredentiallink.NewKeyCredentialFromCertificate(certificate, *guid.NewGUID()),
+		Certificate:   certificate,
+	}
+
+	pathToFile := filepath.Join(t.TempDir(), "pfx", "alice.pfx")
+	if err := shadowCredential.ExportPFX(pathToFile, "P@ssw0rd"); err != nil {
+		t.Fatalf("ExportPFX error = %v", err)
+	}
+
+	imported, err := crypto.LoadX509CertificateFromPFX(pathToFile, "P@ssw0rd")
+	if err != nil {
+		t.Fatalf("LoadX509CertificateFromPFX error = %v", err)
+	}
+	if !imported.GetRSAPrivateKey().Equal(certificate.GetRSAPrivateKey()) {
+		t.Errorf("Imported private key does not match the exported one")
+	}
+	if !bytes.Equal(imported.GetCertificate().Raw, certificate.GetCertificate().Raw) {
+		t.Errorf("Imported certificate does not match the exported one")
+	}
+
+	if _, err := crypto.LoadX509CertificateFromPFX(pathToFile, "wrong"); err == nil {
+		t.Errorf("Expected an error with a wrong password")
+	}
+}
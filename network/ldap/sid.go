@@ -1,77 +1,45 @@
 package ldap
 
 import (
-	"encoding/binary"
 	"fmt"
-	"strings"
+
+	"github.com/TheManticoreProject/Manticore/windows/sid"
 )
 
 // ParseSIDFromBytes parses raw bytes representing an SID and returns the SID string
 //
-// This function takes raw bytes representing an SID and parses them to extract the revision level,
-// identifier authority, sub-authorities, and relative identifier. It then constructs and returns the SID string
-// in the format "S-<revisionLevel>-<identifierAuthority>-<subAuthorities>-<relativeIdentifier>".
+// This function is a shortcut to sid.FromBytes for the attributes holding a binary SID, such as objectSid,
+// whose value is stored as a string.
 //
 // Parameters:
 //   - sidBytes ([]byte): The raw bytes representing the SID.
 //
 // Returns:
-//   - string: The parsed SID string. If the input is not a valid SID or if an error occurs, the function returns an empty string.
+//   - string: The parsed SID string. If the input is not a valid SID, the function returns an empty string.
 func ParseSIDFromBytes(sidBytes []byte) string {
-	debug := false
-
-	// Ensure the SID has a valid format
-	if len(sidBytes) < 8 || sidBytes[0] != 1 {
+	parsedSID, err := sid.FromBytes(sidBytes)
+	if err != nil {
 		return ""
 	}
+	return parsedSID.String()
+}
 
-	// Extract revisionLevel
-	revisionLevel := int(sidBytes[0])
-	if debug {
-		fmt.Printf("revisionLevel       = 0x%02x\n", revisionLevel)
-		fmt.Println(sidBytes[1:])
-	}
-	// Extract subAuthorityCount
-	subAuthorityCount := int(sidBytes[1])
-	if debug {
-		fmt.Printf("subAuthorityCount   = 0x%02x\n", subAuthorityCount)
-		fmt.Println(sidBytes[2:])
-	}
-
-	// Extract identifierAuthority
-	identifierAuthority := uint64(sidBytes[2+0]) << 40
-	identifierAuthority |= uint64(sidBytes[2+1]) << 32
-	identifierAuthority |= uint64(sidBytes[2+2]) << 24
-	identifierAuthority |= uint64(sidBytes[2+3]) << 16
-	identifierAuthority |= uint64(sidBytes[2+4]) << 8
-	identifierAuthority |= uint64(sidBytes[2+5])
-	if debug {
-		fmt.Printf("identifierAuthority = 0x%08x\n", identifierAuthority)
-		fmt.Println(sidBytes[8:])
-	}
-
-	// Extract subAuthorities
-	subAuthorities := make([]string, 0)
-	for k := 0; k < subAuthorityCount-1; k++ {
-		subAuthority := binary.LittleEndian.Uint32(sidBytes[8+(4*k):])
-		subAuthorities = append(subAuthorities, fmt.Sprintf("%d", subAuthority))
-		if debug {
-			fmt.Printf("subAuthority        = 0x%08x\n", subAuthority)
-			fmt.Println(sidBytes[8+(4*k):])
-		}
-	}
-
-	// Parse the relativeIdentifier
-	relativeIdentifier := binary.LittleEndian.Uint32(sidBytes[8+((subAuthorityCount-1)*4):])
-	if debug {
-		fmt.Printf("relativeIdentifier  = 0x%08x\n", relativeIdentifier)
-		fmt.Println(sidBytes[8+((subAuthorityCount-1)*4):])
+// ConvertSIDToBytes converts a SID string to its binary representation
+//
+// This function is a shortcut to sid.FromString followed by the ToBytes method of sid.SID.
+//
+// Parameters:
+//   - SID (string): The SID string to convert.
+//
+// Returns:
+//   - []byte: The binary representation of the SID, as stored in the objectSid attribute.
+//   - error: An error if the SID string is not valid.
+func ConvertSIDToBytes(SID string) ([]byte, error) {
+	parsedSID, err := sid.FromString(SID)
+	if err != nil {
+		return nil, err
 	}
-
-	// Construct the parsed SID
-	parsedSID := fmt.Sprintf("S-%d-%d-%s-%d", revisionLevel, identifierAuthority, strings.Join(subAuthorities, "-"), relativeIdentifier)
-
-	return parsedSID
+	return parsedSID.ToBytes(), nil
 }
 
 // LookupSID retrieves the name of the object associated with the given SID from the LDAP directory.
@@ -0,0 +1,135 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// OID of the StartTLS extended operation
+// Src: https://www.rfc-editor.org/rfc/rfc4511#section-4.14.1
+const OID_STARTTLS = "1.3.6.1.4.1.1466.20037"
+
+// SetTLSConfig sets the TLS configuration used by LDAPS and StartTLS connections.
+//
+// Parameters:
+//   - tlsConfig: The TLS configuration, usually built with NewTLSConfig. If nil, the certificate of the server
+//     is not verified. Its client certificates are used to authenticate with SASL EXTERNAL when the credentials
+//     of the session hold neither a password nor an NT hash.
+func (s *Session) SetTLSConfig(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+}
+
+// SetStartTLS sets whether a connection without LDAPS is upgraded to TLS with the StartTLS extended operation
+// before binding.
+//
+// Parameters:
+//   - usestarttls: A flag indicating whether to use StartTLS.
+func (s *Session) SetStartTLS(usestarttls bool) {
+	s.usestarttls = usestarttls
+}
+
+// NewTLSConfig creates a TLS configuration verifying the certificate of the LDAP server.
+//
+// Parameters:
+//   - serverName: A string representing the name the certificate of the server must be valid for, which pins
+//     the server when connecting to it by IP address. If empty, the host of the session is used.
+//   - rootCAs: A pointer to the pool of the CA certificates trusted to issue the certificate of the server, usually
+//     loaded with LoadCACertificates. If nil, the CA certificates of the system are trusted.
+//   - clientCertificates: A slice of the certificates, with their private keys, presented to the server for
+//     Schannel authentication. It may be empty.
+//
+// Returns:
+//   - A pointer to the tls.Config.
+func NewTLSConfig(serverName string, rootCAs *x509.CertPool, clientCertificates []tls.Certificate) *tls.Config {
+	return &tls.Config{
+		ServerName:   serverName,
+		RootCAs:      rootCAs,
+		Certificates: clientCertificates,
+	}
+}
+
+// LoadCACertificates loads a pool of CA certificates from a PEM file.
+//
+// Parameters:
+//   - pathToFile: A string representing the path to the PEM file holding the CA certificates.
+//
+// Returns:
+//   - A pointer to the x509.CertPool.
+//   - An error if the file cannot be read or holds no certificate.
+func LoadCACertificates(pathToFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(pathToFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", pathToFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", pathToFile)
+	}
+	return pool, nil
+}
+
+// usesTLS checks whether the connection of the session is protected by TLS.
+func (s *Session) usesTLS() bool {
+	return s.useldaps || s.usestarttls
+}
+
+// getTLSConfig returns a copy of the TLS configuration of the session, verifying the certificate of the server
+// against its host unless another server name is set.
+func (s *Session) getTLSConfig() *tls.Config {
+	if s.tlsConfig == nil {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	tlsConfig := s.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = s.host
+	}
+	return tlsConfig
+}
+
+// useExternalBind checks whether the session authenticates with its TLS client certificate.
+func (s *Session) useExternalBind() bool {
+	if !s.usesTLS() || s.tlsConfig == nil || len(s.tlsConfig.Certificates) == 0 {
+		return false
+	}
+	return len(s.credentials.GetPassword()) == 0 && !s.credentials.CanPassTheHash()
+}
+
+// startTLS upgrades a raw connection to TLS with the StartTLS extended operation.
+//
+// Parameters:
+//   - conn: The net.Conn connected to the LDAP server, on which no LDAP message was exchanged yet.
+//   - tlsConfig: The TLS configuration of the connection.
+//
+// Returns:
+//   - A pointer to the tls.Conn, once the TLS handshake completed.
+//   - An error if the server refuses StartTLS or if the handshake fails.
+func startTLS(conn net.Conn, tlsConfig *tls.Config) (*tls.Conn, error) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationExtendedRequest, nil, "Start TLS")
+	request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, OID_STARTTLS, "TLS Extended Command"))
+	envelope.AppendChild(request)
+
+	if _, err := conn.Write(envelope.Bytes()); err != nil {
+		return nil, fmt.Errorf("error sending StartTLS request: %w", err)
+	}
+	packet, err := ber.ReadPacket(conn)
+	if err != nil {
+		return nil, fmt.Errorf("error reading StartTLS response: %w", err)
+	}
+	if err := ldap.GetLDAPError(packet); err != nil {
+		return nil, fmt.Errorf("error starting TLS: %w", err)
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("error during TLS handshake: %w", err)
+	}
+	return tlsConn, nil
+}
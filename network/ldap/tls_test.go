@@ -0,0 +1,162 @@
+package ldap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// newTestCertificate creates a self-signed certificate for dc01.lab.local.
+func newTestCertificate(t *testing.T, curve elliptic.Curve, signatureAlgorithm x509.SignatureAlgorithm) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dc01.lab.local"},
+		DNSNames:              []string{"dc01.lab.local"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		SignatureAlgorithm:    signatureAlgorithm,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: certificate}
+}
+
+func TestGetTLSConfig(t *testing.T) {
+	creds, err := credentials.NewCredentials("LAB", "alice", "", "")
+	if err != nil {
+		t.Fatalf("NewCredentials failed: %v", err)
+	}
+	session := &Session{host: "10.0.0.1", credentials: creds}
+
+	if tlsConfig := session.getTLSConfig(); !tlsConfig.InsecureSkipVerify {
+		t.Error("Expected the certificate not to be verified without a TLS configuration")
+	}
+
+	session.SetTLSConfig(NewTLSConfig("", nil, nil))
+	if serverName := session.getTLSConfig().ServerName; serverName != "10.0.0.1" {
+		t.Errorf("Expected the server name to default to the host, got %s", serverName)
+	}
+	session.SetTLSConfig(NewTLSConfig("dc01.lab.local", nil, nil))
+	if serverName := session.getTLSConfig().ServerName; serverName != "dc01.lab.local" {
+		t.Errorf("Expected the pinned server name, got %s", serverName)
+	}
+
+	if session.useExternalBind() {
+		t.Error("Expected no EXTERNAL bind without TLS or client certificate")
+	}
+	certificate := newTestCertificate(t, elliptic.P256(), x509.ECDSAWithSHA256)
+	session.SetTLSConfig(NewTLSConfig("", nil, []tls.Certificate{certificate}))
+	session.SetStartTLS(true)
+	if !session.useExternalBind() {
+		t.Error("Expected an EXTERNAL bind with a client certificate over StartTLS")
+	}
+}
+
+func TestLoadCACertificates(t *testing.T) {
+	certificate := newTestCertificate(t, elliptic.P256(), x509.ECDSAWithSHA256)
+	directory := t.TempDir()
+
+	pathToFile := filepath.Join(directory, "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+	if err := os.WriteFile(pathToFile, data, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	pool, err := LoadCACertificates(pathToFile)
+	if err != nil {
+		t.Fatalf("LoadCACertificates failed: %v", err)
+	}
+	if _, err := certificate.Leaf.Verify(x509.VerifyOptions{Roots: pool, DNSName: "dc01.lab.local"}); err != nil {
+		t.Errorf("Expected the certificate to be trusted: %v", err)
+	}
+
+	emptyFile := filepath.Join(directory, "empty.pem")
+	if err := os.WriteFile(emptyFile, []byte("no certificate"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := LoadCACertificates(emptyFile); err == nil {
+		t.Error("Expected an error for a file without certificate")
+	}
+}
+
+// serveTestStartTLS answers a StartTLS request and completes the TLS handshake with the certificate.
+func serveTestStartTLS(t *testing.T, conn net.Conn, certificate tls.Certificate) {
+	t.Helper()
+
+	packet, err := ber.ReadPacket(conn)
+	if err != nil {
+		t.Errorf("ReadPacket failed: %v", err)
+		return
+	}
+	request := packet.Children[1]
+	if request.Tag != ldap.ApplicationExtendedRequest || string(request.Children[0].Data.Bytes()) != OID_STARTTLS {
+		t.Errorf("Expected StartTLS request, got tag %d", request.Tag)
+	}
+
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(ldap.LDAPResultSuccess), "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	envelope.AppendChild(response)
+	if _, err := conn.Write(envelope.Bytes()); err != nil {
+		t.Errorf("Write failed: %v", err)
+		return
+	}
+
+	tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Errorf("Handshake failed: %v", err)
+	}
+}
+
+func TestStartTLS(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	certificate := newTestCertificate(t, elliptic.P256(), x509.ECDSAWithSHA256)
+	go serveTestStartTLS(t, serverConn, certificate)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate.Leaf)
+	tlsConn, err := startTLS(clientConn, NewTLSConfig("dc01.lab.local", pool, nil))
+	if err != nil {
+		t.Fatalf("startTLS failed: %v", err)
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) != 1 || !state.PeerCertificates[0].Equal(certificate.Leaf) {
+		t.Error("Expected the certificate of the server")
+	}
+}
@@ -0,0 +1,549 @@
+package samr
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/crypto/rc4"
+	"github.com/TheManticoreProject/Manticore/network/dcerpc"
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// SAMRInterface is the SAM Remote Protocol interface, registered on a dynamic TCP port of the domain controllers.
+// Src: [MS-SAMR] 1.9 Standards Assignments
+var SAMRInterface = dcerpc.SyntaxID{UUID: "12345778-1234-abcd-ef00-0123456789ac", MajorVersion: 1}
+
+// Operation numbers of the methods of the interface
+// Src: [MS-SAMR] 3.1.5 Message Processing Events and Sequencing Rules
+const (
+	opnumSamrConnect                     = 0
+	opnumSamrCloseHandle                 = 1
+	opnumSamrLookupDomainInSamServer     = 5
+	opnumSamrEnumerateDomainsInSamServer = 6
+	opnumSamrOpenDomain                  = 7
+	opnumSamrOpenUser                    = 34
+	opnumSamrCreateUser2InDomain         = 50
+	opnumSamrSetInformationUser2         = 58
+)
+
+// Status codes returned by the methods
+const (
+	STATUS_SUCCESS       uint32 = 0x00000000
+	STATUS_MORE_ENTRIES  uint32 = 0x00000105
+	STATUS_ACCESS_DENIED uint32 = 0xC0000022
+	STATUS_USER_EXISTS   uint32 = 0xC0000063
+)
+
+// Access rights requested on the server, domain and user objects
+// Src: [MS-SAMR] 2.2.1 ACCESS_MASK Values
+const (
+	MAXIMUM_ALLOWED              uint32 = 0x02000000
+	SAM_SERVER_CONNECT           uint32 = 0x00000001
+	SAM_SERVER_ENUMERATE_DOMAINS uint32 = 0x00000010
+	SAM_SERVER_LOOKUP_DOMAIN     uint32 = 0x00000020
+	DOMAIN_CREATE_USER           uint32 = 0x00000010
+	DOMAIN_LOOKUP                uint32 = 0x00000200
+	USER_FORCE_PASSWORD_CHANGE   uint32 = 0x00000040
+)
+
+// Account control flag of the computer accounts, distinct from the userAccountControl flags of LDAP
+// Src: [MS-SAMR] 2.2.1.12 USER_ACCOUNT Codes
+const USER_WORKSTATION_TRUST_ACCOUNT uint32 = 0x00000080
+
+// Information classes of SamrSetInformationUser2
+// Src: [MS-SAMR] 2.2.6.28 USER_INFORMATION_CLASS Values
+const (
+	USER_CONTROL_INFORMATION       uint16 = 16
+	USER_INTERNAL5_INFORMATION_NEW uint16 = 26
+)
+
+// Size of the context handles of the server, domain and user objects
+const contextHandleSize = 20
+
+// Name of the built-in domain of the SAM server, skipped when looking for the account domain
+const builtinDomainName = "Builtin"
+
+// StatusError is the error returned when a method fails with an NTSTATUS code.
+type StatusError struct {
+	Method string
+	Status uint32
+}
+
+// Error returns the name of the method and its status code.
+//
+// Returns:
+// - A string describing the error.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s failed with status 0x%08x", e.Method, e.Status)
+}
+
+// Client calls the methods of the SAM Remote Protocol on a domain controller, over an RPC connection
+// authenticated with NTLM and encrypted.
+type Client struct {
+	// DomainSID is the SID of the domain opened with OpenDomain
+	DomainSID *sid.SID
+
+	rpcClient    *dcerpc.Client
+	sessionKey   []byte
+	serverHandle []byte
+	domainHandle []byte
+}
+
+// NewClient connects to the SAM Remote Protocol interface of a domain controller, on the TCP port given by its
+// endpoint mapper, and connects to its SAM server.
+//
+// Parameters:
+// - host: A string representing the hostname or the IP address of the domain controller.
+// - creds: A pointer to the credentials, holding a password or an NT hash.
+//
+// Returns:
+// - A pointer to the Client, which must be closed after use.
+// - An error if the endpoint cannot be resolved, if the authentication fails or if SamrConnect fails.
+func NewClient(host string, creds *credentials.Credentials) (*Client, error) {
+	ntlmClient, err := ntlm.NewClientWithCredentials(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := dcerpc.MapTCPEndpoint(host, SAMRInterface)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving the SAMR endpoint: %w", err)
+	}
+	rpcClient, err := dcerpc.Dial(host, port)
+	if err != nil {
+		return nil, err
+	}
+	if err := rpcClient.BindWithNTLM(SAMRInterface, ntlmClient, dcerpc.RPC_C_AUTHN_LEVEL_PKT_PRIVACY); err != nil {
+		rpcClient.Close()
+		return nil, fmt.Errorf("error binding to the SAMR interface: %w", err)
+	}
+
+	client := &Client{rpcClient: rpcClient, sessionKey: ntlmClient.ExportedSessionKey}
+
+	w := dcerpc.NewNDRWriter()
+	w.WriteNullPointer()
+	w.WriteUint32(SAM_SERVER_CONNECT | SAM_SERVER_ENUMERATE_DOMAINS | SAM_SERVER_LOOKUP_DOMAIN)
+	r, err := client.call("SamrConnect", opnumSamrConnect, w.Bytes())
+	if err == nil {
+		client.serverHandle, err = readContextHandle(r)
+	}
+	if err == nil {
+		err = readStatus("SamrConnect", r)
+	}
+	if err != nil {
+		rpcClient.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close closes the handles of the domain and of the server, then the connection to the domain controller.
+//
+// Returns:
+// - An error if the connection cannot be closed.
+func (c *Client) Close() error {
+	for _, handle := range [][]byte{c.domainHandle, c.serverHandle} {
+		if handle != nil {
+			c.closeHandle(handle)
+		}
+	}
+	return c.rpcClient.Close()
+}
+
+// EnumerateDomains lists the domains hosted by the SAM server, which are the account domain and the built-in
+// domain on a domain controller.
+//
+// Returns:
+// - A slice of strings containing the names of the domains.
+// - An error if SamrEnumerateDomainsInSamServer fails.
+func (c *Client) EnumerateDomains() ([]string, error) {
+	names := []string{}
+	enumerationContext := uint32(0)
+	for {
+		w := dcerpc.NewNDRWriter()
+		w.WriteBytes(c.serverHandle)
+		w.WriteUint32(enumerationContext)
+		w.WriteUint32(0xffffffff)
+		r, err := c.call("SamrEnumerateDomainsInSamServer", opnumSamrEnumerateDomainsInSamServer, w.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []string
+		var status uint32
+		enumerationContext, entries, status, err = parseEnumerateDomainsResponse(r)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, entries...)
+		if status == STATUS_SUCCESS {
+			return names, nil
+		}
+		if status != STATUS_MORE_ENTRIES {
+			return nil, &StatusError{Method: "SamrEnumerateDomainsInSamServer", Status: status}
+		}
+	}
+}
+
+// OpenDomain opens a domain of the SAM server, required to create accounts in it.
+//
+// Parameters:
+// - domainName: A string representing the NetBIOS name of the domain. If empty, the first domain which is not
+// the built-in domain is opened.
+//
+// Returns:
+// - An error if no domain is found, if the domain SID cannot be looked up or if SamrOpenDomain fails.
+func (c *Client) OpenDomain(domainName string) error {
+	if len(domainName) == 0 {
+		names, err := c.EnumerateDomains()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if !strings.EqualFold(name, builtinDomainName) {
+				domainName = name
+				break
+			}
+		}
+		if len(domainName) == 0 {
+			return fmt.Errorf("no account domain found on the SAM server")
+		}
+	}
+
+	w := dcerpc.NewNDRWriter()
+	w.WriteBytes(c.serverHandle)
+	writeUnicodeString(w, domainName)
+	r, err := c.call("SamrLookupDomainInSamServer", opnumSamrLookupDomainInSamServer, w.Bytes())
+	if err != nil {
+		return err
+	}
+	domainSID, err := parseLookupDomainResponse(r)
+	if err != nil {
+		return err
+	}
+
+	w = dcerpc.NewNDRWriter()
+	w.WriteBytes(c.serverHandle)
+	w.WriteUint32(DOMAIN_LOOKUP | DOMAIN_CREATE_USER)
+	writeSID(w, domainSID)
+	r, err = c.call("SamrOpenDomain", opnumSamrOpenDomain, w.Bytes())
+	if err != nil {
+		return err
+	}
+	domainHandle, err := readContextHandle(r)
+	if err != nil {
+		return err
+	}
+	if err := readStatus("SamrOpenDomain", r); err != nil {
+		return err
+	}
+
+	if c.domainHandle != nil {
+		c.closeHandle(c.domainHandle)
+	}
+	c.domainHandle = domainHandle
+	c.DomainSID = domainSID
+	return nil
+}
+
+// CreateComputer creates an enabled computer account in the opened domain, as done when joining a computer to
+// the domain. The account is created in the default container of the computers.
+//
+// Parameters:
+// - name: A string representing the sAMAccountName of the computer, "$" being appended if missing.
+// - password: A string representing the password of the computer account.
+//
+// Returns:
+// - The RID of the created account.
+// - An error if no domain is opened or if a method fails, such as with STATUS_USER_EXISTS.
+func (c *Client) CreateComputer(name string, password string) (uint32, error) {
+	if c.domainHandle == nil {
+		return 0, fmt.Errorf("no domain opened")
+	}
+	if !strings.HasSuffix(name, "$") {
+		name += "$"
+	}
+
+	w := dcerpc.NewNDRWriter()
+	w.WriteBytes(c.domainHandle)
+	writeUnicodeString(w, name)
+	w.WriteUint32(USER_WORKSTATION_TRUST_ACCOUNT)
+	w.WriteUint32(USER_FORCE_PASSWORD_CHANGE)
+	r, err := c.call("SamrCreateUser2InDomain", opnumSamrCreateUser2InDomain, w.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	userHandle, err := readContextHandle(r)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.ReadUint32(); err != nil {
+		return 0, err
+	}
+	RID, err := r.ReadUint32()
+	if err != nil {
+		return 0, err
+	}
+	if err := readStatus("SamrCreateUser2InDomain", r); err != nil {
+		return 0, err
+	}
+
+	encryptedPassword, err := encryptPasswordNew(password, c.sessionKey)
+	if err != nil {
+		c.closeHandle(userHandle)
+		return RID, err
+	}
+	err = c.setInformationUser(userHandle, USER_INTERNAL5_INFORMATION_NEW, append(encryptedPassword, 0))
+	c.closeHandle(userHandle)
+	if err != nil {
+		return RID, fmt.Errorf("error setting the password of %s: %w", name, err)
+	}
+
+	// The handle returned by SamrCreateUser2InDomain only allows to set the password, while the account remains
+	// disabled until its control flags are written through a new handle
+	w = dcerpc.NewNDRWriter()
+	w.WriteBytes(c.domainHandle)
+	w.WriteUint32(MAXIMUM_ALLOWED)
+	w.WriteUint32(RID)
+	r, err = c.call("SamrOpenUser", opnumSamrOpenUser, w.Bytes())
+	if err != nil {
+		return RID, err
+	}
+	if userHandle, err = readContextHandle(r); err != nil {
+		return RID, err
+	}
+	if err := readStatus("SamrOpenUser", r); err != nil {
+		return RID, err
+	}
+	err = c.setInformationUser(userHandle, USER_CONTROL_INFORMATION, binary.LittleEndian.AppendUint32(nil, USER_WORKSTATION_TRUST_ACCOUNT))
+	c.closeHandle(userHandle)
+	if err != nil {
+		return RID, fmt.Errorf("error enabling %s: %w", name, err)
+	}
+
+	return RID, nil
+}
+
+// setInformationUser calls SamrSetInformationUser2 with the marshalled structure of an information class.
+func (c *Client) setInformationUser(userHandle []byte, informationClass uint16, information []byte) error {
+	r, err := c.call("SamrSetInformationUser2", opnumSamrSetInformationUser2, marshalSetInformationUser2(userHandle, informationClass, information))
+	if err != nil {
+		return err
+	}
+	return readStatus("SamrSetInformationUser2", r)
+}
+
+// closeHandle closes a context handle, ignoring the errors as the handles are also released with the connection.
+func (c *Client) closeHandle(handle []byte) {
+	c.rpcClient.Call(opnumSamrCloseHandle, handle)
+}
+
+// call calls a method and returns a reader of its output parameters.
+func (c *Client) call(method string, opnum uint16, stub []byte) (*dcerpc.NDRReader, error) {
+	response, err := c.rpcClient.Call(opnum, stub)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", method, err)
+	}
+	return dcerpc.NewNDRReader(response), nil
+}
+
+// marshalSetInformationUser2 encodes the input parameters of SamrSetInformationUser2. The SAMPR_USER_INFO_BUFFER
+// union is preceded by its discriminant, the information class.
+func marshalSetInformationUser2(userHandle []byte, informationClass uint16, information []byte) []byte {
+	w := dcerpc.NewNDRWriter()
+	w.WriteBytes(userHandle)
+	w.WriteUint16(informationClass)
+	w.WriteUint16(informationClass)
+	w.WriteBytes(information)
+	return w.Bytes()
+}
+
+// encryptPasswordNew builds a SAMPR_ENCRYPTED_USER_PASSWORD_NEW, holding the password at the end of a 512-byte
+// random buffer followed by its length, encrypted with RC4 keyed with MD5(salt, session key), then the salt.
+// Src: [MS-SAMR] 2.2.6.22 SAMPR_ENCRYPTED_USER_PASSWORD_NEW
+func encryptPasswordNew(password string, sessionKey []byte) ([]byte, error) {
+	encodedPassword := utf16.EncodeUTF16LE(password)
+	if len(encodedPassword) > 512 {
+		return nil, fmt.Errorf("password must be at most 256 characters")
+	}
+
+	buffer := make([]byte, 512, 532)
+	if _, err := rand.Read(buffer[:512-len(encodedPassword)]); err != nil {
+		return nil, fmt.Errorf("error generating password buffer: %w", err)
+	}
+	copy(buffer[512-len(encodedPassword):], encodedPassword)
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(encodedPassword)))
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %w", err)
+	}
+	key := md5.Sum(append(append([]byte{}, salt...), sessionKey...))
+	cipher, err := rc4.NewRC4WithKey(key[:])
+	if err != nil {
+		return nil, err
+	}
+	cipher.XORKeyStream(buffer, buffer)
+
+	return append(buffer, salt...), nil
+}
+
+// writeUnicodeString writes a RPC_UNICODE_STRING, immediately followed by its deferred buffer, as marshalled for
+// a parameter holding no other pointer.
+func writeUnicodeString(w *dcerpc.NDRWriter, value string) {
+	encoded := utf16.EncodeUTF16LE(value)
+	w.WriteUint16(uint16(len(encoded)))
+	w.WriteUint16(uint16(len(encoded)))
+	w.WritePointer()
+	w.WriteUint32(uint32(len(encoded) / 2))
+	w.WriteUint32(0)
+	w.WriteUint32(uint32(len(encoded) / 2))
+	w.WriteBytes(encoded)
+}
+
+// writeSID writes a RPC_SID, whose conformance is the number of sub-authorities.
+func writeSID(w *dcerpc.NDRWriter, value *sid.SID) {
+	data := value.ToBytes()
+	w.WriteUint32(uint32(data[1]))
+	w.WriteBytes(data)
+}
+
+// readContextHandle reads a context handle.
+func readContextHandle(r *dcerpc.NDRReader) ([]byte, error) {
+	r.Align(4)
+	handle, err := r.ReadBytes(contextHandleSize)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, handle...), nil
+}
+
+// readStatus reads the NTSTATUS returned by a method.
+func readStatus(method string, r *dcerpc.NDRReader) error {
+	status, err := r.ReadUint32()
+	if err != nil {
+		return err
+	}
+	if status != STATUS_SUCCESS {
+		return &StatusError{Method: method, Status: status}
+	}
+	return nil
+}
+
+// parseLookupDomainResponse decodes the output parameters of SamrLookupDomainInSamServer.
+func parseLookupDomainResponse(r *dcerpc.NDRReader) (*sid.SID, error) {
+	pointer, err := r.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	var domainSID *sid.SID
+	if pointer != 0 {
+		count, err := r.ReadUint32()
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.ReadBytes(8 + 4*int(count))
+		if err != nil {
+			return nil, err
+		}
+		if domainSID, err = sid.FromBytes(data); err != nil {
+			return nil, fmt.Errorf("invalid domain SID: %w", err)
+		}
+	}
+	if err := readStatus("SamrLookupDomainInSamServer", r); err != nil {
+		return nil, err
+	}
+	if domainSID == nil {
+		return nil, fmt.Errorf("SamrLookupDomainInSamServer returned no SID")
+	}
+	return domainSID, nil
+}
+
+// parseEnumerateDomainsResponse decodes the output parameters of SamrEnumerateDomainsInSamServer, returning the
+// enumeration context, the names of the domains and the status, which is STATUS_MORE_ENTRIES if the
+// enumeration must be continued.
+func parseEnumerateDomainsResponse(r *dcerpc.NDRReader) (uint32, []string, uint32, error) {
+	enumerationContext, err := r.ReadUint32()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	names := []string{}
+	pointer, err := r.ReadUint32()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if pointer != 0 {
+		// SAMPR_ENUMERATION_BUFFER
+		entriesRead, err := r.ReadUint32()
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		if pointer, err = r.ReadUint32(); err != nil {
+			return 0, nil, 0, err
+		}
+		if pointer != 0 {
+			if _, err := r.ReadUint32(); err != nil {
+				return 0, nil, 0, err
+			}
+			// SAMPR_RID_ENUMERATION entries, whose name buffers are deferred after the array
+			hasName := make([]bool, entriesRead)
+			for i := range hasName {
+				if _, err := r.ReadUint32(); err != nil {
+					return 0, nil, 0, err
+				}
+				if _, err := r.ReadUint32(); err != nil {
+					return 0, nil, 0, err
+				}
+				namePointer, err := r.ReadUint32()
+				if err != nil {
+					return 0, nil, 0, err
+				}
+				hasName[i] = namePointer != 0
+			}
+			for _, present := range hasName {
+				if !present {
+					continue
+				}
+				name, err := readDeferredUnicodeString(r)
+				if err != nil {
+					return 0, nil, 0, err
+				}
+				names = append(names, name)
+			}
+		}
+	}
+
+	if _, err := r.ReadUint32(); err != nil {
+		return 0, nil, 0, err
+	}
+	status, err := r.ReadUint32()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	return enumerationContext, names, status, nil
+}
+
+// readDeferredUnicodeString reads the conformant varying buffer of a RPC_UNICODE_STRING.
+func readDeferredUnicodeString(r *dcerpc.NDRReader) (string, error) {
+	counts := make([]uint32, 3)
+	for i := range counts {
+		count, err := r.ReadUint32()
+		if err != nil {
+			return "", err
+		}
+		counts[i] = count
+	}
+	data, err := r.ReadBytes(int(counts[2]) * 2)
+	if err != nil {
+		return "", err
+	}
+	return utf16.DecodeUTF16LE(data), nil
+}
@@ -0,0 +1,116 @@
+package samr
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/crypto/rc4"
+	"github.com/TheManticoreProject/Manticore/network/dcerpc"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+func TestEncryptPasswordNew(t *testing.T) {
+	sessionKey := bytes.Repeat([]byte{0x42}, 16)
+
+	encrypted, err := encryptPasswordNew("Sup3rS3cr3t!", sessionKey)
+	if err != nil {
+		t.Fatalf("encryptPasswordNew error = %v", err)
+	}
+	if len(encrypted) != 532 {
+		t.Fatalf("Expected 532 bytes, got %d", len(encrypted))
+	}
+
+	salt := encrypted[516:]
+	key := md5.Sum(append(append([]byte{}, salt...), sessionKey...))
+	cipher, err := rc4.NewRC4WithKey(key[:])
+	if err != nil {
+		t.Fatalf("NewRC4WithKey error = %v", err)
+	}
+	decrypted := make([]byte, 516)
+	cipher.XORKeyStream(decrypted, encrypted[:516])
+
+	length := int(binary.LittleEndian.Uint32(decrypted[512:]))
+	if length != 24 {
+		t.Fatalf("Expected password length 24, got %d", length)
+	}
+	if utf16.DecodeUTF16LE(decrypted[512-length:512]) != "Sup3rS3cr3t!" {
+		t.Errorf("Unexpected decrypted password")
+	}
+
+	if _, err := encryptPasswordNew(string(bytes.Repeat([]byte{'a'}, 257)), sessionKey); err == nil {
+		t.Errorf("Expected an error for a password longer than 256 characters")
+	}
+}
+
+func TestMarshalSetInformationUser2(t *testing.T) {
+	handle := bytes.Repeat([]byte{0xaa}, contextHandleSize)
+
+	stub := marshalSetInformationUser2(handle, USER_CONTROL_INFORMATION, binary.LittleEndian.AppendUint32(nil, USER_WORKSTATION_TRUST_ACCOUNT))
+	expected := append(append([]byte{}, handle...), 16, 0, 16, 0, 0x80, 0, 0, 0)
+	if !bytes.Equal(stub, expected) {
+		t.Errorf("Expected %x, got %x", expected, stub)
+	}
+}
+
+func TestParseLookupDomainResponse(t *testing.T) {
+	domainSID, _ := sid.FromString("S-1-5-21-1-2-3")
+
+	w := dcerpc.NewNDRWriter()
+	w.WritePointer()
+	writeSID(w, domainSID)
+	w.WriteUint32(STATUS_SUCCESS)
+
+	parsed, err := parseLookupDomainResponse(dcerpc.NewNDRReader(w.Bytes()))
+	if err != nil {
+		t.Fatalf("parseLookupDomainResponse error = %v", err)
+	}
+	if parsed.String() != "S-1-5-21-1-2-3" {
+		t.Errorf("Expected S-1-5-21-1-2-3, got %s", parsed.String())
+	}
+
+	w = dcerpc.NewNDRWriter()
+	w.WriteNullPointer()
+	w.WriteUint32(0xC00000DF)
+	if _, err := parseLookupDomainResponse(dcerpc.NewNDRReader(w.Bytes())); err == nil {
+		t.Errorf("Expected an error for a failed lookup")
+	}
+}
+
+func TestParseEnumerateDomainsResponse(t *testing.T) {
+	names := []string{"LAB", "Builtin"}
+
+	w := dcerpc.NewNDRWriter()
+	w.WriteUint32(2)
+	w.WritePointer()
+	w.WriteUint32(uint32(len(names)))
+	w.WritePointer()
+	w.WriteUint32(uint32(len(names)))
+	for _, name := range names {
+		w.WriteUint32(0)
+		w.WriteUint16(uint16(len(name) * 2))
+		w.WriteUint16(uint16(len(name) * 2))
+		w.WritePointer()
+	}
+	for _, name := range names {
+		w.WriteUint32(uint32(len(name)))
+		w.WriteUint32(0)
+		w.WriteUint32(uint32(len(name)))
+		w.WriteBytes(utf16.EncodeUTF16LE(name))
+	}
+	w.WriteUint32(uint32(len(names)))
+	w.WriteUint32(STATUS_SUCCESS)
+
+	enumerationContext, parsed, status, err := parseEnumerateDomainsResponse(dcerpc.NewNDRReader(w.Bytes()))
+	if err != nil {
+		t.Fatalf("parseEnumerateDomainsResponse error = %v", err)
+	}
+	if enumerationContext != 2 || status != STATUS_SUCCESS {
+		t.Errorf("Unexpected enumeration context %d and status 0x%08x", enumerationContext, status)
+	}
+	if len(parsed) != 2 || parsed[0] != "LAB" || parsed[1] != "Builtin" {
+		t.Errorf("Expected %v, got %v", names, parsed)
+	}
+}
@@ -0,0 +1,360 @@
+package ntlm
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/crypto/nt"
+	"github.com/TheManticoreProject/Manticore/crypto/rc4"
+	"github.com/TheManticoreProject/Manticore/network/smb/smb_v10/spnego/ntlm/version"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+)
+
+// MsvAvFlags value indicating that the AUTHENTICATE message carries a MIC
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/83f5e789-660d-4781-8491-5f8c6641f75e
+const MSV_AV_FLAGS_MIC_PRESENT uint32 = 0x00000002
+
+// DefaultClientNegotiateFlags are the flags requested by a Client, negotiating NTLMv2 with 128-bit signing
+// and sealing of the messages of the session.
+const DefaultClientNegotiateFlags = NTLMSSP_NEGOTIATE_UNICODE |
+	NTLMSSP_REQUEST_TARGET |
+	NTLMSSP_NEGOTIATE_SIGN |
+	NTLMSSP_NEGOTIATE_SEAL |
+	NTLMSSP_NEGOTIATE_NTLM |
+	NTLMSSP_NEGOTIATE_ALWAYS_SIGN |
+	NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY |
+	NTLMSSP_NEGOTIATE_TARGET_INFO |
+	NTLMSSP_NEGOTIATE_VERSION |
+	NTLMSSP_NEGOTIATE_128 |
+	NTLMSSP_NEGOTIATE_KEY_EXCH |
+	NTLMSSP_NEGOTIATE_56
+
+// Client performs the client side of an NTLMv2 authentication and, once authenticated, protects the
+// messages of the session with the keys derived from the exported session key.
+type Client struct {
+	Domain      string
+	Username    string
+	Workstation string
+
+	// NTHash is the NT hash of the password of the user, which is all NTLMv2 needs
+	NTHash []byte
+
+	// NegotiateFlags holds the flags requested by the client, then the flags negotiated with the server
+	NegotiateFlags uint32
+
+	// ChannelBindings holds the MD5 hash of the gss_channel_bindings_struct binding the authentication to
+	// the outer channel (such as the TLS connection), sent in the MsvAvChannelBindings of the target info
+	ChannelBindings []byte
+
+	// ExportedSessionKey is the session key the signing and sealing keys are derived from
+	ExportedSessionKey []byte
+
+	negotiateMessage []byte
+	outbound         *sessionSecurity
+	inbound          *sessionSecurity
+}
+
+// NewClientWithPassword creates an NTLM client authenticating with a password.
+//
+// Parameters:
+// - domain: A string representing the domain of the user.
+// - username: A string representing the name of the user.
+// - password: A string representing the password of the user.
+// - workstation: A string representing the name of the client workstation, which may be empty.
+//
+// Returns:
+// - A pointer to the Client, requesting the DefaultClientNegotiateFlags.
+func NewClientWithPassword(domain, username, password, workstation string) *Client {
+	ntHash := nt.NTHash(password)
+	return NewClientWithNTHash(domain, username, ntHash[:], workstation)
+}
+
+// NewClientWithNTHash creates an NTLM client authenticating with the NT hash of the password (pass-the-hash).
+//
+// Parameters:
+// - domain: A string representing the domain of the user.
+// - username: A string representing the name of the user.
+// - ntHash: A byte slice containing the 16 bytes of the NT hash of the password of the user.
+// - workstation: A string representing the name of the client workstation, which may be empty.
+//
+// Returns:
+// - A pointer to the Client, requesting the DefaultClientNegotiateFlags.
+func NewClientWithNTHash(domain, username string, ntHash []byte, workstation string) *Client {
+	return &Client{
+		Domain:         domain,
+		Username:       username,
+		Workstation:    workstation,
+		NTHash:         ntHash,
+		NegotiateFlags: DefaultClientNegotiateFlags,
+	}
+}
+
+// NewClientWithCredentials creates an NTLM client authenticating with the password of the credentials or, failing
+// that, with their NT hash.
+//
+// Parameters:
+// - creds: A pointer to the credentials, holding a password or an NT hash.
+//
+// Returns:
+// - A pointer to the Client, requesting the DefaultClientNegotiateFlags.
+// - An error if the credentials hold neither a password nor a valid NT hash.
+func NewClientWithCredentials(creds *credentials.Credentials) (*Client, error) {
+	if len(creds.GetPassword()) != 0 {
+		return NewClientWithPassword(creds.GetDomain(), creds.GetUsername(), creds.GetPassword(), ""), nil
+	}
+	if creds.CanPassTheHash() {
+		ntHash, err := hex.DecodeString(creds.GetNTHash())
+		if err != nil {
+			return nil, fmt.Errorf("invalid NT hash: %w", err)
+		}
+		return NewClientWithNTHash(creds.GetDomain(), creds.GetUsername(), ntHash, ""), nil
+	}
+	return nil, fmt.Errorf("NTLM authentication requires a password or an NT hash")
+}
+
+// Negotiate creates the NEGOTIATE message starting the authentication, requesting the NegotiateFlags of the client.
+//
+// Returns:
+// - A byte slice containing the NEGOTIATE message.
+// - An error if the message cannot be created.
+func (c *Client) Negotiate() ([]byte, error) {
+	data := append([]byte{}, NTLM_SIGNATURE...)
+	data = binary.LittleEndian.AppendUint32(data, NTLM_NEGOTIATE)
+	data = binary.LittleEndian.AppendUint32(data, c.NegotiateFlags)
+
+	// Empty domain and workstation fields, pointing after the version
+	for i := 0; i < 2; i++ {
+		data = binary.LittleEndian.AppendUint16(data, 0)
+		data = binary.LittleEndian.AppendUint16(data, 0)
+		data = binary.LittleEndian.AppendUint32(data, 40)
+	}
+
+	v := version.DefaultVersion()
+	versionBytes, err := v.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, versionBytes...)
+
+	c.negotiateMessage = data
+	return data, nil
+}
+
+// Authenticate processes the CHALLENGE message of the server and creates the AUTHENTICATE message completing
+// the authentication. The NTLMv2 response is computed from the NT hash of the client, a random session key
+// is exchanged if NTLMSSP_NEGOTIATE_KEY_EXCH is negotiated, and a MIC is added if the server sent a timestamp.
+// Once the message is created, the client is ready to sign and seal the messages of the session.
+//
+// Parameters:
+// - challengeMessage: A byte slice containing the CHALLENGE message sent by the server.
+//
+// Returns:
+// - A byte slice containing the AUTHENTICATE message.
+// - An error if the challenge is malformed or if the negotiated flags are not supported.
+func (c *Client) Authenticate(challengeMessage []byte) ([]byte, error) {
+	if c.negotiateMessage == nil {
+		return nil, errors.New("NEGOTIATE message has not been created")
+	}
+	if len(c.NTHash) != 16 {
+		return nil, fmt.Errorf("NT hash must be 16 bytes, got %d", len(c.NTHash))
+	}
+
+	challenge, err := ParseChallengeMessage(challengeMessage)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CHALLENGE message: %w", err)
+	}
+
+	flags := c.NegotiateFlags & challenge.NegotiateFlags
+	if flags&NTLMSSP_NEGOTIATE_UNICODE == 0 {
+		return nil, errors.New("server did not negotiate Unicode")
+	}
+	if flags&(NTLMSSP_NEGOTIATE_SIGN|NTLMSSP_NEGOTIATE_SEAL) != 0 && flags&NTLMSSP_NEGOTIATE_EXTENDED_SESSIONSECURITY == 0 {
+		return nil, errors.New("server did not negotiate extended session security, which signing and sealing require")
+	}
+
+	targetInfo, timestamp, err := prepareTargetInfo(challenge.TargetInfo, c.ChannelBindings)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing target info: %w", err)
+	}
+	useMIC := timestamp != nil
+	if timestamp == nil {
+		timestamp = binary.LittleEndian.AppendUint64(nil, uint64(time.Now().UnixNano()/100+116444736000000000))
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+
+	// NTLMv2 response
+	// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/5e550938-91d4-459f-b67d-75d70009e3f3
+	responseKeyNT := ntowfv2WithNTHash(c.NTHash, c.Username, c.Domain)
+	blob := createNTLMv2BlobWithTimestamp(timestamp, clientChallenge, targetInfo)
+	ntProofStr := calculateNTLMv2Proof(responseKeyNT, challenge.ServerChallenge[:], blob)
+	ntResponse := append(append([]byte{}, ntProofStr...), blob...)
+
+	lmResponse := make([]byte, 24)
+	if !useMIC {
+		lmProof := calculateNTLMv2Proof(responseKeyNT, challenge.ServerChallenge[:], clientChallenge)
+		lmResponse = append(append([]byte{}, lmProof...), clientChallenge...)
+	}
+
+	// With NTLMv2, the key exchange key is the session base key
+	keyExchangeKey := hmacMD5(responseKeyNT, ntProofStr)
+	exportedSessionKey := keyExchangeKey
+	encryptedRandomSessionKey := []byte{}
+	if flags&NTLMSSP_NEGOTIATE_KEY_EXCH != 0 {
+		exportedSessionKey = make([]byte, 16)
+		if _, err := rand.Read(exportedSessionKey); err != nil {
+			return nil, err
+		}
+		if encryptedRandomSessionKey, err = rc4Encrypt(keyExchangeKey, exportedSessionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := marshalAuthenticateMessage(flags, lmResponse, ntResponse, c.Domain, c.Username, c.Workstation, encryptedRandomSessionKey)
+	if err != nil {
+		return nil, err
+	}
+	if useMIC {
+		mic := hmacMD5(exportedSessionKey, c.negotiateMessage, challengeMessage, data)
+		copy(data[72:88], mic)
+	}
+
+	c.NegotiateFlags = flags
+	c.ExportedSessionKey = exportedSessionKey
+	if err := c.initializeSessionSecurity(); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// prepareTargetInfo adds the MsvAvFlags indicating a MIC to the target info of the server when it holds a
+// timestamp, along with the channel bindings if any, and returns the timestamp.
+func prepareTargetInfo(targetInfo []byte, channelBindings []byte) ([]byte, []byte, error) {
+	var timestamp []byte
+	pairs := []AvPair{}
+	for offset := 0; offset < len(targetInfo); {
+		if offset+4 > len(targetInfo) {
+			return nil, nil, errors.New("target info truncated")
+		}
+		pair := AvPair{
+			AvID:  binary.LittleEndian.Uint16(targetInfo[offset : offset+2]),
+			AvLen: binary.LittleEndian.Uint16(targetInfo[offset+2 : offset+4]),
+		}
+		offset += 4
+		if offset+int(pair.AvLen) > len(targetInfo) {
+			return nil, nil, errors.New("target info value truncated")
+		}
+		pair.AvData = targetInfo[offset : offset+int(pair.AvLen)]
+		offset += int(pair.AvLen)
+
+		if pair.AvID == MsvAvEOL {
+			break
+		}
+		if pair.AvID == MsvAvTimestamp {
+			timestamp = pair.AvData
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if timestamp != nil {
+		found := false
+		for i, pair := range pairs {
+			if pair.AvID == MsvAvFlags && pair.AvLen == 4 {
+				value := binary.LittleEndian.Uint32(pair.AvData) | MSV_AV_FLAGS_MIC_PRESENT
+				pairs[i].AvData = binary.LittleEndian.AppendUint32(nil, value)
+				found = true
+			}
+		}
+		if !found {
+			pairs = append(pairs, AvPair{AvID: MsvAvFlags, AvLen: 4, AvData: binary.LittleEndian.AppendUint32(nil, MSV_AV_FLAGS_MIC_PRESENT)})
+		}
+	}
+
+	if channelBindings != nil {
+		pairs = append(pairs, AvPair{AvID: MsvAvChannelBindings, AvLen: uint16(len(channelBindings)), AvData: channelBindings})
+	}
+
+	data := []byte{}
+	for _, pair := range append(pairs, AvPair{AvID: MsvAvEOL}) {
+		data = binary.LittleEndian.AppendUint16(data, pair.AvID)
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(pair.AvData)))
+		data = append(data, pair.AvData...)
+	}
+	return data, timestamp, nil
+}
+
+// marshalAuthenticateMessage creates an AUTHENTICATE message with Unicode fields and a zero MIC.
+func marshalAuthenticateMessage(flags uint32, lmResponse, ntResponse []byte, domain, username, workstation string, encryptedRandomSessionKey []byte) ([]byte, error) {
+	fields := [][]byte{
+		lmResponse,
+		ntResponse,
+		utf16.EncodeUTF16LE(domain),
+		utf16.EncodeUTF16LE(username),
+		utf16.EncodeUTF16LE(strings.ToUpper(workstation)),
+		encryptedRandomSessionKey,
+	}
+
+	data := append([]byte{}, NTLM_SIGNATURE...)
+	data = binary.LittleEndian.AppendUint32(data, NTLM_AUTHENTICATE)
+
+	// Header of 88 bytes, including the version and the MIC
+	offset := 88
+	for _, field := range fields {
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(field)))
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(field)))
+		data = binary.LittleEndian.AppendUint32(data, uint32(offset))
+		offset += len(field)
+	}
+	data = binary.LittleEndian.AppendUint32(data, flags)
+
+	v := version.DefaultVersion()
+	versionBytes, err := v.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, versionBytes...)
+	data = append(data, make([]byte, 16)...)
+
+	for _, field := range fields {
+		data = append(data, field...)
+	}
+	return data, nil
+}
+
+// ntowfv2WithNTHash calculates the NTLMv2 response key of a user from the NT hash of its password.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/5e550938-91d4-459f-b67d-75d70009e3f3
+func ntowfv2WithNTHash(ntHash []byte, username, domain string) []byte {
+	return hmacMD5(ntHash, utf16.EncodeUTF16LE(strings.ToUpper(username)+domain))
+}
+
+// hmacMD5 calculates the HMAC-MD5 of the concatenation of byte slices.
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// rc4Encrypt encrypts data with a new RC4 cipher initialized with a key.
+func rc4Encrypt(key, data []byte) ([]byte, error) {
+	cipher, err := rc4.NewRC4WithKey(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(data))
+	cipher.XORKeyStream(encrypted, data)
+	return encrypted, nil
+}
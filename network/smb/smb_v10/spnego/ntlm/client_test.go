@@ -0,0 +1,313 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/crypto/nt"
+	"github.com/TheManticoreProject/Manticore/utils/encoding/utf16"
+	"github.com/TheManticoreProject/Manticore/windows/credentials"
+)
+
+// Test vectors of the NTLMv2 authentication
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/7795bd0e-fd5e-43ec-bd9c-994704d8ee26
+const testVectorFlags uint32 = 0xe28a8233
+
+var (
+	testVectorServerChallenge    = mustDecodeHex("0123456789abcdef")
+	testVectorClientChallenge    = mustDecodeHex("aaaaaaaaaaaaaaaa")
+	testVectorRandomSessionKey   = mustDecodeHex("55555555555555555555555555555555")
+	testVectorTargetInfo         = mustDecodeHex("02000c0044006f006d00610069006e0001000c005300650072007600650072000000000000000000")
+	testVectorResponseKeyNT      = mustDecodeHex("0c868a403bfd7a93a3001ef22ef02e3f")
+	testVectorNTProofStr         = mustDecodeHex("68cd0ab851e51c96aabc927bebef6a1c")
+	testVectorSessionBaseKey     = mustDecodeHex("8de40ccadbc14a82f15cb0ad0de95ca3")
+	testVectorEncryptedKey       = mustDecodeHex("c5dad2544fc9799094ce1ce90bc9d03e")
+	testVectorLMv2Response       = mustDecodeHex("86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa")
+	testVectorSealingKey         = mustDecodeHex("59f600973cc4960a25480a7c196e4c58")
+	testVectorSigningKey         = mustDecodeHex("4788dc861b4782f35d43fd98fe1a2d39")
+	testVectorSealedMessage      = mustDecodeHex("54e50165bf1936dc996020c1811b0f06fb5f")
+	testVectorMessageSignature   = mustDecodeHex("010000007fb38ec5c55d497600000000")
+	testVectorPlaintextMessage   = utf16.EncodeUTF16LE("Plaintext")
+	testVectorNTHashOfPassword   = mustDecodeHex("a4f49c406510bdcab6824ee7c30fd852")
+	testVectorTimestampOfTheBlob = make([]byte, 8)
+)
+
+func mustDecodeHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestNTLMv2TestVectors(t *testing.T) {
+	client := NewClientWithPassword("Domain", "User", "Password", "COMPUTER")
+	if !bytes.Equal(client.NTHash, testVectorNTHashOfPassword) {
+		t.Errorf("Expected NT hash %x, got %x", testVectorNTHashOfPassword, client.NTHash)
+	}
+
+	responseKeyNT := ntowfv2WithNTHash(client.NTHash, client.Username, client.Domain)
+	if !bytes.Equal(responseKeyNT, testVectorResponseKeyNT) {
+		t.Errorf("Expected NTOWFv2 %x, got %x", testVectorResponseKeyNT, responseKeyNT)
+	}
+
+	blob := createNTLMv2BlobWithTimestamp(testVectorTimestampOfTheBlob, testVectorClientChallenge, testVectorTargetInfo[:len(testVectorTargetInfo)-4])
+	ntProofStr := calculateNTLMv2Proof(responseKeyNT, testVectorServerChallenge, blob)
+	if !bytes.Equal(ntProofStr, testVectorNTProofStr) {
+		t.Errorf("Expected NTProofStr %x, got %x", testVectorNTProofStr, ntProofStr)
+	}
+
+	lmProof := calculateNTLMv2Proof(responseKeyNT, testVectorServerChallenge, testVectorClientChallenge)
+	if lmResponse := append(lmProof, testVectorClientChallenge...); !bytes.Equal(lmResponse, testVectorLMv2Response) {
+		t.Errorf("Expected LMv2 response %x, got %x", testVectorLMv2Response, lmResponse)
+	}
+
+	sessionBaseKey := hmacMD5(responseKeyNT, ntProofStr)
+	if !bytes.Equal(sessionBaseKey, testVectorSessionBaseKey) {
+		t.Errorf("Expected session base key %x, got %x", testVectorSessionBaseKey, sessionBaseKey)
+	}
+
+	encryptedKey, err := rc4Encrypt(sessionBaseKey, testVectorRandomSessionKey)
+	if err != nil {
+		t.Fatalf("rc4Encrypt failed: %v", err)
+	}
+	if !bytes.Equal(encryptedKey, testVectorEncryptedKey) {
+		t.Errorf("Expected encrypted session key %x, got %x", testVectorEncryptedKey, encryptedKey)
+	}
+}
+
+func TestSealTestVector(t *testing.T) {
+	client := &Client{NegotiateFlags: testVectorFlags, ExportedSessionKey: testVectorRandomSessionKey}
+	if err := client.initializeSessionSecurity(); err != nil {
+		t.Fatalf("initializeSessionSecurity failed: %v", err)
+	}
+	if !bytes.Equal(client.outbound.sealingKey, testVectorSealingKey) {
+		t.Errorf("Expected sealing key %x, got %x", testVectorSealingKey, client.outbound.sealingKey)
+	}
+	if !bytes.Equal(client.outbound.signingKey, testVectorSigningKey) {
+		t.Errorf("Expected signing key %x, got %x", testVectorSigningKey, client.outbound.signingKey)
+	}
+
+	sealed, signature, err := client.Seal(testVectorPlaintextMessage)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !bytes.Equal(sealed, testVectorSealedMessage) {
+		t.Errorf("Expected sealed message %x, got %x", testVectorSealedMessage, sealed)
+	}
+	if !bytes.Equal(signature, testVectorMessageSignature) {
+		t.Errorf("Expected signature %x, got %x", testVectorMessageSignature, signature)
+	}
+}
+
+// newTestChallengeMessage creates a CHALLENGE message with the flags and target info of the test vectors,
+// along with a timestamp.
+func newTestChallengeMessage() []byte {
+	targetInfo := append([]byte{}, testVectorTargetInfo[:len(testVectorTargetInfo)-8]...)
+	targetInfo = binary.LittleEndian.AppendUint16(targetInfo, MsvAvTimestamp)
+	targetInfo = binary.LittleEndian.AppendUint16(targetInfo, 8)
+	targetInfo = binary.LittleEndian.AppendUint64(targetInfo, 133000000000000000)
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+
+	data := append([]byte{}, NTLM_SIGNATURE...)
+	data = binary.LittleEndian.AppendUint32(data, NTLM_CHALLENGE)
+	data = append(data, 0, 0, 0, 0, 56, 0, 0, 0)
+	data = binary.LittleEndian.AppendUint32(data, testVectorFlags)
+	data = append(data, testVectorServerChallenge...)
+	data = append(data, make([]byte, 8)...)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(targetInfo)))
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(targetInfo)))
+	data = binary.LittleEndian.AppendUint32(data, 56)
+	data = append(data, 6, 1, 0xb1, 0x1d, 0, 0, 0, 15)
+	return append(data, targetInfo...)
+}
+
+// getAuthenticateMessageField returns a field of an AUTHENTICATE message, by its index in the header.
+func getAuthenticateMessageField(data []byte, index int) []byte {
+	length := binary.LittleEndian.Uint16(data[12+8*index:])
+	offset := binary.LittleEndian.Uint32(data[16+8*index:])
+	return data[offset : offset+uint32(length)]
+}
+
+func TestClientAuthenticateAndSeal(t *testing.T) {
+	client := NewClientWithNTHash("Domain", "User", testVectorNTHashOfPassword, "COMPUTER")
+	client.ChannelBindings = mustDecodeHex("65861e65d1d3e1cbab5e3b3a0d1e0b21")
+	negotiateMessage, err := client.Negotiate()
+	if err != nil {
+		t.Fatalf("Negotiate failed: %v", err)
+	}
+	if flags := binary.LittleEndian.Uint32(negotiateMessage[12:16]); flags != DefaultClientNegotiateFlags {
+		t.Errorf("Expected NEGOTIATE flags %08x, got %08x", DefaultClientNegotiateFlags, flags)
+	}
+
+	challengeMessage := newTestChallengeMessage()
+	authenticateMessage, err := client.Authenticate(challengeMessage)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if !client.IsSigning() || !client.IsSealing() {
+		t.Fatalf("Expected signing and sealing to be negotiated, got flags %08x", client.NegotiateFlags)
+	}
+
+	// Verify the message the way the server does
+	if lmResponse := getAuthenticateMessageField(authenticateMessage, 0); !bytes.Equal(lmResponse, make([]byte, 24)) {
+		t.Errorf("Expected an empty LMv2 response with a MIC, got %x", lmResponse)
+	}
+	ntResponse := getAuthenticateMessageField(authenticateMessage, 1)
+	if !bytes.Equal(ntProof(ntResponse), ntResponse[:16]) {
+		t.Fatal("Invalid NTProofStr")
+	}
+	targetInfo, err := ParseTargetInfo(ntResponse[44:])
+	if err != nil {
+		t.Fatalf("ParseTargetInfo failed: %v", err)
+	}
+	if flags := targetInfo[MsvAvFlags]; len(flags) != 4 || binary.LittleEndian.Uint32(flags)&MSV_AV_FLAGS_MIC_PRESENT == 0 {
+		t.Errorf("Expected MsvAvFlags with a MIC, got %x", flags)
+	}
+	if channelBindings := targetInfo[MsvAvChannelBindings]; !bytes.Equal(channelBindings, client.ChannelBindings) {
+		t.Errorf("Expected MsvAvChannelBindings %x, got %x", client.ChannelBindings, channelBindings)
+	}
+	if domain := getAuthenticateMessageField(authenticateMessage, 2); !bytes.Equal(domain, utf16.EncodeUTF16LE("Domain")) {
+		t.Errorf("Unexpected domain %x", domain)
+	}
+
+	sessionBaseKey := hmacMD5(testVectorResponseKeyNT, ntResponse[:16])
+	exportedSessionKey, err := rc4Encrypt(sessionBaseKey, getAuthenticateMessageField(authenticateMessage, 5))
+	if err != nil {
+		t.Fatalf("rc4Encrypt failed: %v", err)
+	}
+	if !bytes.Equal(exportedSessionKey, client.ExportedSessionKey) {
+		t.Fatalf("Expected exported session key %x, got %x", client.ExportedSessionKey, exportedSessionKey)
+	}
+
+	withoutMIC := append([]byte{}, authenticateMessage...)
+	copy(withoutMIC[72:88], make([]byte, 16))
+	if mic := hmacMD5(exportedSessionKey, negotiateMessage, challengeMessage, withoutMIC); !bytes.Equal(mic, authenticateMessage[72:88]) {
+		t.Errorf("Invalid MIC %x, expected %x", authenticateMessage[72:88], mic)
+	}
+
+	// The server protects its messages with the keys of the other direction
+	server := &Client{NegotiateFlags: client.NegotiateFlags}
+	if server.outbound, err = newSessionSecurity(server.NegotiateFlags, exportedSessionKey, serverSigningKeyMagic, serverSealingKeyMagic); err != nil {
+		t.Fatalf("newSessionSecurity failed: %v", err)
+	}
+	if server.inbound, err = newSessionSecurity(server.NegotiateFlags, exportedSessionKey, clientSigningKeyMagic, clientSealingKeyMagic); err != nil {
+		t.Fatalf("newSessionSecurity failed: %v", err)
+	}
+
+	for i, message := range []string{"first request", "second request", ""} {
+		sealed, signature, err := client.Seal([]byte(message))
+		if err != nil {
+			t.Fatalf("Seal failed: %v", err)
+		}
+		unsealed, err := server.Unseal(sealed, signature)
+		if err != nil {
+			t.Fatalf("Unseal of message %d failed: %v", i, err)
+		}
+		if string(unsealed) != message {
+			t.Errorf("Expected %q, got %q", message, unsealed)
+		}
+		if binary.LittleEndian.Uint32(signature[12:]) != uint32(i) {
+			t.Errorf("Expected sequence number %d, got %x", i, signature[12:])
+		}
+
+		sealed, signature, err = server.Seal([]byte(message))
+		if err != nil {
+			t.Fatalf("Seal failed: %v", err)
+		}
+		if unsealed, err = client.Unseal(sealed, signature); err != nil || string(unsealed) != message {
+			t.Errorf("Unseal of the reply %d failed: %q, %v", i, unsealed, err)
+		}
+	}
+
+	signature, err := client.Sign([]byte("signed"))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if err := server.Verify([]byte("tampered"), signature); err == nil {
+		t.Error("Expected Verify to fail on a tampered message")
+	}
+}
+
+func TestClientSealRange(t *testing.T) {
+	client := NewClientWithNTHash("Domain", "User", testVectorNTHashOfPassword, "COMPUTER")
+	if _, err := client.Negotiate(); err != nil {
+		t.Fatalf("Negotiate failed: %v", err)
+	}
+	if _, err := client.Authenticate(newTestChallengeMessage()); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+
+	// The server protects its messages with the keys of the other direction
+	var err error
+	server := &Client{NegotiateFlags: client.NegotiateFlags}
+	if server.inbound, err = newSessionSecurity(server.NegotiateFlags, client.ExportedSessionKey, clientSigningKeyMagic, clientSealingKeyMagic); err != nil {
+		t.Fatalf("newSessionSecurity failed: %v", err)
+	}
+
+	// DCE/RPC encrypts the stub data only but signs the whole PDU
+	pdu := []byte("header|stub data|trailer")
+	signature, err := client.SealRange(pdu, 7, 9)
+	if err != nil {
+		t.Fatalf("SealRange failed: %v", err)
+	}
+	if string(pdu[:7]) != "header|" || string(pdu[7:16]) == "stub data" || string(pdu[16:]) != "|trailer" {
+		t.Errorf("Unexpected sealed PDU %q", pdu)
+	}
+	if err := server.UnsealRange(pdu, 7, 9, signature); err != nil || string(pdu) != "header|stub data|trailer" {
+		t.Errorf("UnsealRange failed: %q, %v", pdu, err)
+	}
+	if _, err := client.SealRange(pdu, 20, 9); err == nil {
+		t.Error("Expected SealRange to fail on a range out of the message")
+	}
+}
+
+func TestClientAuthenticateErrors(t *testing.T) {
+	client := NewClientWithPassword("Domain", "User", "Password", "")
+	if _, err := client.Authenticate(newTestChallengeMessage()); err == nil {
+		t.Error("Expected Authenticate to fail without NEGOTIATE message")
+	}
+	if _, err := client.Sign([]byte("message")); err == nil {
+		t.Error("Expected Sign to fail before authentication")
+	}
+
+	if _, err := client.Negotiate(); err != nil {
+		t.Fatalf("Negotiate failed: %v", err)
+	}
+	if _, err := client.Authenticate(newTestChallengeMessage()[:40]); err == nil {
+		t.Error("Expected Authenticate to fail on a truncated challenge")
+	}
+}
+
+func TestNewClientWithCredentials(t *testing.T) {
+	creds, _ := credentials.NewCredentials("Domain", "User", "Password", "")
+	client, err := NewClientWithCredentials(creds)
+	if err != nil {
+		t.Fatalf("NewClientWithCredentials failed: %v", err)
+	}
+	ntHash := nt.NTHash("Password")
+	if !bytes.Equal(client.NTHash, ntHash[:]) {
+		t.Errorf("Expected the NT hash of the password, got %x", client.NTHash)
+	}
+
+	creds, _ = credentials.NewCredentials("Domain", "User", "", ":a4f49c406510bdcab6824ee7c30fd852")
+	client, err = NewClientWithCredentials(creds)
+	if err != nil {
+		t.Fatalf("NewClientWithCredentials failed: %v", err)
+	}
+	if hex.EncodeToString(client.NTHash) != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("Expected the NT hash of the credentials, got %x", client.NTHash)
+	}
+
+	creds, _ = credentials.NewCredentials("Domain", "User", "", "")
+	if _, err := NewClientWithCredentials(creds); err == nil {
+		t.Error("Expected an error without password nor NT hash")
+	}
+}
+
+// ntProof recomputes the NTProofStr of an NTLMv2 response of the test vector user.
+func ntProof(ntResponse []byte) []byte {
+	return calculateNTLMv2Proof(testVectorResponseKeyNT, testVectorServerChallenge, ntResponse[16:])
+}
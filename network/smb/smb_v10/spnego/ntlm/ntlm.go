@@ -478,15 +478,6 @@ func calculateNTLMv2Response(challenge *ChallengeMessage, username, password, do
 
 // createNTLMv2Blob creates the NTLMv2 blob
 func createNTLMv2Blob(clientChallenge, targetInfo []byte) []byte {
-	data := []byte{}
-
-	// Blob signature
-	data = append(data, 0x01)
-	data = append(data, 0x01)
-
-	// Reserved
-	data = append(data, []byte{0, 0, 0, 0, 0, 0}...)
-
 	// Timestamp (Windows file time format)
 	now := time.Now()
 	// Convert to Windows file time (100ns intervals since Jan 1, 1601)
@@ -499,7 +490,23 @@ func createNTLMv2Blob(clientChallenge, targetInfo []byte) []byte {
 
 	buf := make([]byte, 8)
 	binary.LittleEndian.PutUint64(buf, uint64(windowsTime))
-	data = append(data, buf...)
+
+	return createNTLMv2BlobWithTimestamp(buf, clientChallenge, targetInfo)
+}
+
+// createNTLMv2BlobWithTimestamp creates the NTLMv2 blob with a given timestamp
+func createNTLMv2BlobWithTimestamp(timestamp, clientChallenge, targetInfo []byte) []byte {
+	data := []byte{}
+
+	// Blob signature
+	data = append(data, 0x01)
+	data = append(data, 0x01)
+
+	// Reserved
+	data = append(data, []byte{0, 0, 0, 0, 0, 0}...)
+
+	// Timestamp
+	data = append(data, timestamp...)
 
 	// Client challenge
 	data = append(data, clientChallenge...)
@@ -0,0 +1,230 @@
+package ntlm
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/crypto/rc4"
+)
+
+// Size of the NTLMSSP_MESSAGE_SIGNATURE structure
+const NTLMSSP_MESSAGE_SIGNATURE_SIZE = 16
+
+// Magic constants of the derivation of the signing and sealing keys
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/524cdccb-563e-4793-92b0-7bc321fce096
+const (
+	clientSigningKeyMagic = "session key to client-to-server signing key magic constant\x00"
+	serverSigningKeyMagic = "session key to server-to-client signing key magic constant\x00"
+	clientSealingKeyMagic = "session key to client-to-server sealing key magic constant\x00"
+	serverSealingKeyMagic = "session key to server-to-client sealing key magic constant\x00"
+)
+
+// sessionSecurity holds the state protecting the messages sent in one direction of a session with
+// extended session security.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/d1c86e81-eb66-47fd-8a6f-970050121347
+type sessionSecurity struct {
+	flags          uint32
+	signingKey     []byte
+	sealingKey     []byte
+	sealingHandle  *rc4.RC4
+	sequenceNumber uint32
+}
+
+// newSessionSecurity derives the signing and sealing keys of one direction of a session from the exported session key.
+func newSessionSecurity(flags uint32, exportedSessionKey []byte, signingKeyMagic, sealingKeyMagic string) (*sessionSecurity, error) {
+	// The sealing key is weakened unless 128-bit encryption is negotiated
+	sealingKeyBase := exportedSessionKey
+	if flags&NTLMSSP_NEGOTIATE_128 == 0 {
+		if flags&NTLMSSP_NEGOTIATE_56 != 0 {
+			sealingKeyBase = exportedSessionKey[:7]
+		} else {
+			sealingKeyBase = exportedSessionKey[:5]
+		}
+	}
+
+	signingKey := md5.Sum(append(append([]byte{}, exportedSessionKey...), signingKeyMagic...))
+	sealingKey := md5.Sum(append(append([]byte{}, sealingKeyBase...), sealingKeyMagic...))
+
+	sealingHandle, err := rc4.NewRC4WithKey(sealingKey[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionSecurity{
+		flags:         flags,
+		signingKey:    signingKey[:],
+		sealingKey:    sealingKey[:],
+		sealingHandle: sealingHandle,
+	}, nil
+}
+
+// mac computes the NTLMSSP_MESSAGE_SIGNATURE of a message and increments the sequence number.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp/a92716d5-d164-4960-9e15-300f4eef44a8
+func (s *sessionSecurity) mac(message []byte) []byte {
+	sequenceNumber := binary.LittleEndian.AppendUint32(nil, s.sequenceNumber)
+	s.sequenceNumber++
+
+	checksum := hmacMD5(s.signingKey, sequenceNumber, message)[:8]
+	if s.flags&NTLMSSP_NEGOTIATE_KEY_EXCH != 0 {
+		s.sealingHandle.XORKeyStream(checksum, checksum)
+	}
+
+	signature := binary.LittleEndian.AppendUint32(nil, 1)
+	signature = append(signature, checksum...)
+	return append(signature, sequenceNumber...)
+}
+
+// initializeSessionSecurity derives the keys protecting the messages sent and received by the client.
+func (c *Client) initializeSessionSecurity() error {
+	var err error
+	if c.outbound, err = newSessionSecurity(c.NegotiateFlags, c.ExportedSessionKey, clientSigningKeyMagic, clientSealingKeyMagic); err != nil {
+		return err
+	}
+	if c.inbound, err = newSessionSecurity(c.NegotiateFlags, c.ExportedSessionKey, serverSigningKeyMagic, serverSealingKeyMagic); err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsSigning checks whether the messages of the session are signed.
+//
+// Returns:
+// - A boolean indicating whether NTLMSSP_NEGOTIATE_SIGN was negotiated.
+func (c *Client) IsSigning() bool {
+	return c.NegotiateFlags&NTLMSSP_NEGOTIATE_SIGN != 0
+}
+
+// IsSealing checks whether the messages of the session are encrypted.
+//
+// Returns:
+// - A boolean indicating whether NTLMSSP_NEGOTIATE_SEAL was negotiated.
+func (c *Client) IsSealing() bool {
+	return c.NegotiateFlags&NTLMSSP_NEGOTIATE_SEAL != 0
+}
+
+// Sign computes the signature of a message sent to the server.
+//
+// Parameters:
+// - message: A byte slice containing the message to sign.
+//
+// Returns:
+// - A byte slice containing the 16 bytes of the NTLMSSP_MESSAGE_SIGNATURE.
+// - An error if the authentication has not completed.
+func (c *Client) Sign(message []byte) ([]byte, error) {
+	if c.outbound == nil {
+		return nil, errors.New("NTLM authentication has not completed")
+	}
+	return c.outbound.mac(message), nil
+}
+
+// Verify checks the signature of a message received from the server.
+//
+// Parameters:
+// - message: A byte slice containing the message received.
+// - signature: A byte slice containing the NTLMSSP_MESSAGE_SIGNATURE received with the message.
+//
+// Returns:
+// - An error if the authentication has not completed or if the signature is invalid, otherwise nil.
+func (c *Client) Verify(message, signature []byte) error {
+	if c.inbound == nil {
+		return errors.New("NTLM authentication has not completed")
+	}
+	if len(signature) != NTLMSSP_MESSAGE_SIGNATURE_SIZE {
+		return fmt.Errorf("signature must be %d bytes, got %d", NTLMSSP_MESSAGE_SIGNATURE_SIZE, len(signature))
+	}
+	if !hmac.Equal(c.inbound.mac(message), signature) {
+		return errors.New("invalid message signature")
+	}
+	return nil
+}
+
+// Seal encrypts a message sent to the server and computes its signature.
+//
+// Parameters:
+// - message: A byte slice containing the message to encrypt.
+//
+// Returns:
+// - A byte slice containing the encrypted message.
+// - A byte slice containing the 16 bytes of the NTLMSSP_MESSAGE_SIGNATURE.
+// - An error if the authentication has not completed.
+func (c *Client) Seal(message []byte) ([]byte, []byte, error) {
+	if c.outbound == nil {
+		return nil, nil, errors.New("NTLM authentication has not completed")
+	}
+	sealed := make([]byte, len(message))
+	c.outbound.sealingHandle.XORKeyStream(sealed, message)
+	return sealed, c.outbound.mac(message), nil
+}
+
+// Unseal decrypts a message received from the server and checks its signature.
+//
+// Parameters:
+// - sealed: A byte slice containing the encrypted message.
+// - signature: A byte slice containing the NTLMSSP_MESSAGE_SIGNATURE received with the message.
+//
+// Returns:
+// - A byte slice containing the decrypted message.
+// - An error if the authentication has not completed or if the signature is invalid.
+func (c *Client) Unseal(sealed, signature []byte) ([]byte, error) {
+	if c.inbound == nil {
+		return nil, errors.New("NTLM authentication has not completed")
+	}
+	message := make([]byte, len(sealed))
+	c.inbound.sealingHandle.XORKeyStream(message, sealed)
+	if err := c.Verify(message, signature); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// SealRange encrypts a part of a message sent to the server and computes the signature of the whole message,
+// as done by DCE/RPC which encrypts the stub data of a PDU but signs its headers as well.
+// Src: [MS-RPCE] 2.2.2.11 sec_trailer Structure
+//
+// Parameters:
+// - message: A byte slice containing the message, whose part is encrypted in place.
+// - offset: The offset of the part to encrypt.
+// - length: The length of the part to encrypt.
+//
+// Returns:
+// - A byte slice containing the 16 bytes of the NTLMSSP_MESSAGE_SIGNATURE of the plaintext message.
+// - An error if the authentication has not completed or if the part is out of the message.
+func (c *Client) SealRange(message []byte, offset, length int) ([]byte, error) {
+	if c.outbound == nil {
+		return nil, errors.New("NTLM authentication has not completed")
+	}
+	if offset < 0 || length < 0 || offset+length > len(message) {
+		return nil, fmt.Errorf("range [%d:%d] is out of the message of %d bytes", offset, offset+length, len(message))
+	}
+	plaintext := append([]byte{}, message...)
+	part := message[offset : offset+length]
+	c.outbound.sealingHandle.XORKeyStream(part, part)
+	return c.outbound.mac(plaintext), nil
+}
+
+// UnsealRange decrypts a part of a message received from the server and checks the signature of the whole
+// message, as sealed by SealRange.
+//
+// Parameters:
+// - message: A byte slice containing the message, whose part is decrypted in place.
+// - offset: The offset of the part to decrypt.
+// - length: The length of the part to decrypt.
+// - signature: A byte slice containing the NTLMSSP_MESSAGE_SIGNATURE received with the message.
+//
+// Returns:
+// - An error if the authentication has not completed, if the part is out of the message or if the signature
+// is invalid, otherwise nil.
+func (c *Client) UnsealRange(message []byte, offset, length int, signature []byte) error {
+	if c.inbound == nil {
+		return errors.New("NTLM authentication has not completed")
+	}
+	if offset < 0 || length < 0 || offset+length > len(message) {
+		return fmt.Errorf("range [%d:%d] is out of the message of %d bytes", offset, offset+length, len(message))
+	}
+	part := message[offset : offset+length]
+	c.inbound.sealingHandle.XORKeyStream(part, part)
+	return c.Verify(message, signature)
+}
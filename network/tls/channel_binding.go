@@ -0,0 +1,63 @@
+// Package tls implements the helpers shared by the protocols authenticating over TLS connections.
+package tls
+
+import (
+	"crypto"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+)
+
+// Prefix of the application data of the tls-server-end-point channel bindings
+// Src: https://www.rfc-editor.org/rfc/rfc5929#section-4
+const tlsServerEndPointPrefix = "tls-server-end-point:"
+
+// GetTLSServerEndPoint computes the application data of the tls-server-end-point channel bindings of a TLS
+// connection, which binds an authentication to the certificate of the server.
+//
+// Parameters:
+//   - state: The tls.ConnectionState of the connection.
+//
+// Returns:
+//   - A byte slice containing "tls-server-end-point:" followed by the hash of the certificate of the server. The
+//     hash function is the one of the signature of the certificate, or SHA-256 if it is MD5 or SHA-1.
+//   - An error if the server presented no certificate.
+func GetTLSServerEndPoint(state tls.ConnectionState) ([]byte, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("server presented no certificate")
+	}
+	certificate := state.PeerCertificates[0]
+
+	hash := crypto.SHA256
+	switch certificate.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		hash = crypto.SHA384
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write(certificate.Raw)
+
+	return h.Sum([]byte(tlsServerEndPointPrefix)), nil
+}
+
+// GetChannelBindingsHash computes the MD5 hash of the gss_channel_bindings_struct holding channel bindings
+// without addresses, as carried by the MsvAvChannelBindings of NTLM and the authenticator checksum of Kerberos.
+//
+// Parameters:
+//   - applicationData: A byte slice containing the application data of the channel bindings, usually
+//     computed with GetTLSServerEndPoint.
+//
+// Returns:
+//   - A byte slice containing the 16 bytes of the hash.
+func GetChannelBindingsHash(applicationData []byte) []byte {
+	// Initiator and acceptor address types and lengths, all zero
+	data := make([]byte, 16)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(applicationData)))
+	data = append(data, applicationData...)
+
+	hash := md5.Sum(data)
+	return hash[:]
+}
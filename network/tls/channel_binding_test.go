@@ -0,0 +1,88 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestCertificate creates a self-signed certificate for dc01.lab.local.
+func newTestCertificate(t *testing.T, curve elliptic.Curve, signatureAlgorithm x509.SignatureAlgorithm) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(1),
+		Subject:            pkix.Name{CommonName: "dc01.lab.local"},
+		DNSNames:           []string{"dc01.lab.local"},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(time.Hour),
+		SignatureAlgorithm: signatureAlgorithm,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return certificate
+}
+
+func TestGetTLSServerEndPoint(t *testing.T) {
+	sha256Certificate := newTestCertificate(t, elliptic.P256(), x509.ECDSAWithSHA256)
+	sha384Certificate := newTestCertificate(t, elliptic.P384(), x509.ECDSAWithSHA384)
+	sha256Hash := sha256.Sum256(sha256Certificate.Raw)
+	sha384Hash := sha512.Sum384(sha384Certificate.Raw)
+
+	tests := []struct {
+		certificate *x509.Certificate
+		hash        []byte
+	}{
+		{sha256Certificate, sha256Hash[:]},
+		{sha384Certificate, sha384Hash[:]},
+	}
+	for _, test := range tests {
+		state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{test.certificate}}
+		applicationData, err := GetTLSServerEndPoint(state)
+		if err != nil {
+			t.Fatalf("GetTLSServerEndPoint failed: %v", err)
+		}
+		expected := append([]byte("tls-server-end-point:"), test.hash...)
+		if !bytes.Equal(applicationData, expected) {
+			t.Errorf("Expected %x, got %x", expected, applicationData)
+		}
+	}
+
+	if _, err := GetTLSServerEndPoint(tls.ConnectionState{}); err == nil {
+		t.Error("Expected an error without a certificate")
+	}
+}
+
+func TestGetChannelBindingsHash(t *testing.T) {
+	applicationData := []byte("tls-server-end-point:abc")
+
+	structure := make([]byte, 16)
+	structure = binary.LittleEndian.AppendUint32(structure, uint32(len(applicationData)))
+	structure = append(structure, applicationData...)
+	expected := md5.Sum(structure)
+
+	if hash := GetChannelBindingsHash(applicationData); !bytes.Equal(hash, expected[:]) {
+		t.Errorf("Expected %x, got %x", expected, hash)
+	}
+}
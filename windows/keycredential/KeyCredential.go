@@ -120,6 +120,30 @@ func NewKeyCredential(
 	return kc
 }
 
+// NewKeyCredentialFromCertificate creates a version 2 key credential holding the public key of a certificate, as
+// written to the msDS-KeyCredentialLink attribute of an account to authenticate with the certificate through PKINIT.
+//
+// Parameters:
+// - certificate: A pointer to an X509Certificate object holding the RSA key of the key credential.
+// - DeviceId: A GUID object representing the device ID of the key credential.
+//
+// Returns:
+// - A pointer to a KeyCredential object, created and last used at the current time.
+func NewKeyCredentialFromCertificate(certificate *crypto.X509Certificate, DeviceId guid.GUID) *KeyCredential {
+	keyMaterial := certificate.GetRSAKeyMaterial()
+	version := key.KeyCredentialVersion{Value: key.KeyCredentialVersion_2}
+	now := utils.NewDateTime(0)
+
+	return NewKeyCredential(
+		version,
+		utils.ComputeKeyIdentifier(keyMaterial.ToBytes(), version),
+		keyMaterial,
+		DeviceId,
+		now,
+		now,
+	)
+}
+
 // ParseDNWithBinary parses the provided DNWithBinary object into the KeyCredential structure.
 //
 // Parameters:
@@ -363,6 +387,27 @@ func (kc *KeyCredential) ToBytes() ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// ToDNWithBinary wraps the raw bytes of the KeyCredential structure in a DNWithBinary object, which is the format
+// of the values of the msDS-KeyCredentialLink attribute.
+//
+// Parameters:
+// - owner: A string representing the distinguished name of the account holding the key credential.
+//
+// Returns:
+// - A DNWithBinary object holding the key credential.
+// - An error if the conversion fails.
+func (kc *KeyCredential) ToDNWithBinary(owner string) (DNWithBinary, error) {
+	rawBytes, err := kc.ToBytes()
+	if err != nil {
+		return DNWithBinary{}, err
+	}
+
+	return DNWithBinary{
+		DistinguishedName: owner,
+		BinaryData:        rawBytes,
+	}, nil
+}
+
 // Describe prints a detailed description of the KeyCredential structure.
 //
 // Parameters:
@@ -0,0 +1,53 @@
+package keycredentiallink
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/keycredential/crypto"
+	"github.com/TheManticoreProject/Manticore/windows/keycredential/key"
+)
+
+func TestNewKeyCredentialFromCertificate(t *testing.T) {
+	certificate, err := crypto.NewX509Certificate("alice", 2048, time.Now(), time.Now().AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatalf("NewX509Certificate() error = %v", err)
+	}
+	deviceId := guid.NewGUID()
+
+	kc := NewKeyCredentialFromCertificate(certificate, *deviceId)
+	dnWithBinary, err := kc.ToDNWithBinary("CN=alice,CN=Users,DC=lab,DC=local")
+	if err != nil {
+		t.Fatalf("ToDNWithBinary() error = %v", err)
+	}
+
+	parsed := DNWithBinary{}
+	if err := parsed.Parse([]byte(dnWithBinary.ToString())); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if parsed.DistinguishedName != "CN=alice,CN=Users,DC=lab,DC=local" {
+		t.Errorf("Expected DistinguishedName = CN=alice,CN=Users,DC=lab,DC=local, got %v", parsed.DistinguishedName)
+	}
+
+	parsedKc := &KeyCredential{}
+	if err := parsedKc.ParseDNWithBinary(parsed); err != nil {
+		t.Fatalf("ParseDNWithBinary() error = %v", err)
+	}
+	if parsedKc.Version.Value != key.KeyCredentialVersion_2 {
+		t.Errorf("Expected Version = %v, got %v", key.KeyCredentialVersion_2, parsedKc.Version.Value)
+	}
+	if parsedKc.Identifier != kc.Identifier {
+		t.Errorf("Expected Identifier = %v, got %v", kc.Identifier, parsedKc.Identifier)
+	}
+	if !parsedKc.DeviceId.Equal(deviceId) {
+		t.Errorf("Expected DeviceId = %v, got %v", deviceId.ToFormatD(), parsedKc.DeviceId.ToFormatD())
+	}
+	if !bytes.Equal(parsedKc.RawKeyMaterial.Modulus, certificate.GetRSAPublicKey().N.Bytes()) {
+		t.Errorf("Key material does not hold the modulus of the certificate")
+	}
+	if !parsedKc.CheckIntegrity() {
+		t.Errorf("Expected a valid KeyHash")
+	}
+}
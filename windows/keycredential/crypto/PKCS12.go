@@ -0,0 +1,326 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"unicode/utf16"
+)
+
+// Number of iterations of the key derivation function used to encrypt and authenticate PFX data
+const PKCS12_ITERATIONS = 2048
+
+// Object identifiers of the PKCS#12 structures
+// Src: https://www.rfc-editor.org/rfc/rfc7292
+var (
+	oidDataContentType            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidPBEWithSHAAnd3KeyTripleDES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPKCS8ShroudedKeyBag        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidFriendlyName               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidX509Certificate            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidSHA1                       = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+// Purposes of the keys derived with the PKCS#12 key derivation function
+const (
+	pkcs12KeyID    byte = 1
+	pkcs12IVID     byte = 2
+	pkcs12MACID    byte = 3
+	pkcs12SHA1V         = 64
+	pkcs12SaltSize      = 8
+)
+
+type pkcs12AlgorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs12PBEParameters struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type pkcs12EncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkcs12AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo pkcs12EncryptedContentInfo
+}
+
+type pkcs12EncryptedPrivateKeyInfo struct {
+	AlgorithmIdentifier pkcs12AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pkcs12Attribute struct {
+	Id    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type pkcs12SafeBag struct {
+	Id         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12CertBag struct {
+	Id   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkcs12AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12MACData struct {
+	Mac        pkcs12DigestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MACData `asn1:"optional"`
+}
+
+// encodePKCS12 encodes a certificate and its private key in a password protected PFX structure. The private key
+// and the certificate are encrypted with pbeWithSHAAnd3-KeyTripleDES-CBC and the PFX is authenticated with an
+// HMAC-SHA1, which are supported by Windows, OpenSSL and golang.org/x/crypto/pkcs12.
+func encodePKCS12(certificate *x509.Certificate, privateKey any, password string, friendlyName string) ([]byte, error) {
+	encodedPassword := bmpStringZeroTerminated(password)
+
+	// The local key ID links the private key to its certificate
+	localKeyID := sha1.Sum(certificate.Raw)
+	attributes, err := pkcs12BagAttributes(localKeyID[:], friendlyName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificate, in an encrypted data content
+	certBag, err := asn1.Marshal(pkcs12CertBag{Id: oidX509Certificate, Data: certificate.Raw})
+	if err != nil {
+		return nil, err
+	}
+	certSafeContents, err := asn1.Marshal([]pkcs12SafeBag{{
+		Id:         oidCertBag,
+		Value:      pkcs12Explicit(certBag),
+		Attributes: attributes,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	algorithm, encryptedCertificate, err := pkcs12Encrypt(certSafeContents, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+	encryptedData, err := asn1.Marshal(pkcs12EncryptedData{
+		Version: 0,
+		EncryptedContentInfo: pkcs12EncryptedContentInfo{
+			ContentType:                oidDataContentType,
+			ContentEncryptionAlgorithm: algorithm,
+			EncryptedContent:           encryptedCertificate,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Private key, in a shrouded key bag of a data content
+	pkcs8Key, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, encryptedKey, err := pkcs12Encrypt(pkcs8Key, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+	keyBag, err := asn1.Marshal(pkcs12EncryptedPrivateKeyInfo{AlgorithmIdentifier: algorithm, EncryptedData: encryptedKey})
+	if err != nil {
+		return nil, err
+	}
+	keySafeContents, err := asn1.Marshal([]pkcs12SafeBag{{
+		Id:         oidPKCS8ShroudedKeyBag,
+		Value:      pkcs12Explicit(keyBag),
+		Attributes: attributes,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	keyData, err := asn1.Marshal(keySafeContents)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticatedSafe, err := asn1.Marshal([]pkcs12ContentInfo{
+		{ContentType: oidEncryptedDataContentType, Content: pkcs12Explicit(encryptedData)},
+		{ContentType: oidDataContentType, Content: pkcs12Explicit(keyData)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	authSafeData, err := asn1.Marshal(authenticatedSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	// The MAC covers the content of the authenticated safe
+	macSalt := make([]byte, pkcs12SaltSize)
+	if _, err := rand.Read(macSalt); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha1.New, pkcs12DeriveKey(encodedPassword, macSalt, PKCS12_ITERATIONS, pkcs12MACID, sha1.Size))
+	mac.Write(authenticatedSafe)
+
+	return asn1.Marshal(pkcs12PFX{
+		Version:  3,
+		AuthSafe: pkcs12ContentInfo{ContentType: oidDataContentType, Content: pkcs12Explicit(authSafeData)},
+		MacData: pkcs12MACData{
+			Mac: pkcs12DigestInfo{
+				Algorithm: pkcs12AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    macSalt,
+			Iterations: PKCS12_ITERATIONS,
+		},
+	})
+}
+
+// pkcs12Explicit wraps an encoded value in an explicit [0] tag. The tags of the struct fields are not applied to
+// asn1.RawValue fields, so the explicit tags of the ContentInfo and SafeBag structures are built here.
+func pkcs12Explicit(encoded []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: encoded}
+}
+
+// pkcs12BagAttributes builds the localKeyId and friendlyName attributes of the safe bags.
+func pkcs12BagAttributes(localKeyID []byte, friendlyName string) ([]pkcs12Attribute, error) {
+	encodedLocalKeyID, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	attributes := []pkcs12Attribute{{Id: oidLocalKeyID, Value: asn1.RawValue{Tag: asn1.TagSet, Class: asn1.ClassUniversal, IsCompound: true, Bytes: encodedLocalKeyID}}}
+
+	if len(friendlyName) != 0 {
+		encodedFriendlyName, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Class: asn1.ClassUniversal, Bytes: bmpString(friendlyName)})
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, pkcs12Attribute{Id: oidFriendlyName, Value: asn1.RawValue{Tag: asn1.TagSet, Class: asn1.ClassUniversal, IsCompound: true, Bytes: encodedFriendlyName}})
+	}
+	return attributes, nil
+}
+
+// pkcs12Encrypt encrypts data with pbeWithSHAAnd3-KeyTripleDES-CBC and a random salt.
+func pkcs12Encrypt(data []byte, encodedPassword []byte) (pkcs12AlgorithmIdentifier, []byte, error) {
+	salt := make([]byte, pkcs12SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return pkcs12AlgorithmIdentifier{}, nil, err
+	}
+	parameters, err := asn1.Marshal(pkcs12PBEParameters{Salt: salt, Iterations: PKCS12_ITERATIONS})
+	if err != nil {
+		return pkcs12AlgorithmIdentifier{}, nil, err
+	}
+
+	key := pkcs12DeriveKey(encodedPassword, salt, PKCS12_ITERATIONS, pkcs12KeyID, 24)
+	iv := pkcs12DeriveKey(encodedPassword, salt, PKCS12_ITERATIONS, pkcs12IVID, des.BlockSize)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return pkcs12AlgorithmIdentifier{}, nil, err
+	}
+
+	// PKCS#7 padding, always adding at least one byte
+	padding := des.BlockSize - len(data)%des.BlockSize
+	encrypted := make([]byte, len(data)+padding)
+	copy(encrypted, data)
+	for k := len(data); k < len(encrypted); k++ {
+		encrypted[k] = byte(padding)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	return pkcs12AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3KeyTripleDES, Parameters: asn1.RawValue{FullBytes: parameters}}, encrypted, nil
+}
+
+// pkcs12DeriveKey derives a key from a password with the SHA-1 based key derivation function of PKCS#12.
+// Src: https://www.rfc-editor.org/rfc/rfc7292#appendix-B.2
+func pkcs12DeriveKey(encodedPassword []byte, salt []byte, iterations int, id byte, size int) []byte {
+	// Concatenates copies of a value to fill a multiple of v bytes
+	fill := func(value []byte) []byte {
+		if len(value) == 0 {
+			return nil
+		}
+		length := pkcs12SHA1V * ((len(value) + pkcs12SHA1V - 1) / pkcs12SHA1V)
+		filled := make([]byte, length)
+		for k := range filled {
+			filled[k] = value[k%len(value)]
+		}
+		return filled
+	}
+
+	D := make([]byte, pkcs12SHA1V)
+	for k := range D {
+		D[k] = id
+	}
+	I := append(fill(salt), fill(encodedPassword)...)
+
+	one := big.NewInt(1)
+	derived := []byte{}
+	for len(derived) < size {
+		hash := sha1.Sum(append(append([]byte{}, D...), I...))
+		A := hash[:]
+		for k := 1; k < iterations; k++ {
+			hash = sha1.Sum(A)
+			A = hash[:]
+		}
+		derived = append(derived, A...)
+
+		// Each block of I is replaced by (I_j + B + 1) mod 2^(v*8), where B is made of copies of A
+		B := new(big.Int).SetBytes(fill(A)[:pkcs12SHA1V])
+		B.Add(B, one)
+		for j := 0; j < len(I); j += pkcs12SHA1V {
+			Ij := new(big.Int).SetBytes(I[j : j+pkcs12SHA1V])
+			Ij.Add(Ij, B)
+			sum := Ij.Bytes()
+			if len(sum) > pkcs12SHA1V {
+				sum = sum[len(sum)-pkcs12SHA1V:]
+			}
+			block := I[j : j+pkcs12SHA1V]
+			for k := range block {
+				block[k] = 0
+			}
+			copy(block[pkcs12SHA1V-len(sum):], sum)
+		}
+	}
+	return derived[:size]
+}
+
+// bmpString encodes a string in UTF-16 big-endian, the encoding of the ASN.1 BMPString type.
+func bmpString(value string) []byte {
+	encoded := []byte{}
+	for _, r := range utf16.Encode([]rune(value)) {
+		encoded = append(encoded, byte(r>>8), byte(r))
+	}
+	return encoded
+}
+
+// bmpStringZeroTerminated encodes a password as done by PKCS#12, in a zero terminated BMPString.
+func bmpStringZeroTerminated(value string) []byte {
+	return append(bmpString(value), 0, 0)
+}
@@ -99,8 +99,8 @@ func (rk *RSAKeyMaterial) ToBytes() []byte {
 	b_keySize := make([]byte, 4)
 	binary.LittleEndian.PutUint32(b_keySize, rk.KeySize)
 
-	b_exponent := make([]byte, 4)
-	binary.BigEndian.PutUint32(b_exponent, rk.Exponent)
+	// The exponent is stored in big-endian format without leading zeros, as exported by BCryptExportKey
+	b_exponent := new(big.Int).SetUint64(uint64(rk.Exponent)).Bytes()
 	b_exponentSize := make([]byte, 4)
 	binary.LittleEndian.PutUint32(b_exponentSize, uint32(len(b_exponent)))
 
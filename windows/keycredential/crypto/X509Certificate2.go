@@ -11,6 +11,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // X509Certificate represents an X.509 certificate along with its associated RSA private key and public key material.
@@ -23,6 +25,7 @@ import (
 // Methods:
 // - NewX509Certificate: Creates a new X.509 certificate with the specified subject, key size, and validity period.
 // - ExportPFX: Exports the certificate and private key to a PFX file with the specified password.
+// - ExportPFXBytes: Encodes the certificate and private key in PFX data protected by the specified password.
 //
 // Note:
 // The X509Certificate struct is used to manage X.509 certificates, including the generation of new certificates and the export of certificates and private keys to PFX files.
@@ -99,6 +102,172 @@ func NewX509Certificate(subject string, keySize int, notBefore, notAfter time.Ti
 	}, nil
 }
 
+// Import ====================================================================================
+
+// NewX509CertificateFromPFXBytes loads an X.509 certificate and its RSA private key from the content of a PFX (PKCS#12) file.
+//
+// Parameters:
+// - pfxData: A byte slice containing the PFX data.
+// - password: A string representing the password protecting the PFX data.
+//
+// Returns:
+// - A pointer to an X509Certificate object containing the certificate and associated RSA private key.
+// - An error if the PFX data cannot be decrypted or does not contain an RSA private key and its certificate.
+//
+// Note:
+// The PFX data may contain other certificates, such as the certificate chain of the issuing CA. The certificate
+// returned is the one whose public key matches the private key. Both the legacy PKCS#12 encryption schemes
+// (3DES and RC2 with SHA-1) and the PBES2 scheme with AES used by default by current versions of Windows are
+// supported.
+func NewX509CertificateFromPFXBytes(pfxData []byte, password string) (*X509Certificate, error) {
+	blocks, err := pkcs12.ToPEM(pfxData, password)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding PFX data: %w", err)
+	}
+	return newX509CertificateFromPEMBlocks(blocks, "PFX data")
+}
+
+// NewX509CertificateFromKeyPair wraps a certificate issued for an RSA private key, such as a certificate issued by a
+// certificate authority for a certificate signing request.
+//
+// Parameters:
+// - certificate: A pointer to the x509.Certificate.
+// - privateKey: A pointer to the rsa.PrivateKey of the certificate.
+//
+// Returns:
+// - A pointer to an X509Certificate object containing the certificate and associated RSA private key.
+// - An error if the public key of the certificate does not match the private key.
+func NewX509CertificateFromKeyPair(certificate *x509.Certificate, privateKey *rsa.PrivateKey) (*X509Certificate, error) {
+	if certificate == nil || privateKey == nil {
+		return nil, fmt.Errorf("certificate and private key are required")
+	}
+	if publicKey, ok := certificate.PublicKey.(*rsa.PublicKey); !ok || !publicKey.Equal(&privateKey.PublicKey) {
+		return nil, fmt.Errorf("public key of the certificate does not match the private key")
+	}
+	return &X509Certificate{
+		key:         privateKey,
+		certificate: certificate,
+	}, nil
+}
+
+// LoadX509CertificateFromPFX loads an X.509 certificate and its RSA private key from a PFX (PKCS#12) file.
+//
+// Parameters:
+// - pathToFile: A string representing the path to the PFX file.
+// - password: A string representing the password protecting the PFX file.
+//
+// Returns:
+// - A pointer to an X509Certificate object containing the certificate and associated RSA private key.
+// - An error if the file cannot be read or decoded.
+func LoadX509CertificateFromPFX(pathToFile, password string) (*X509Certificate, error) {
+	pfxData, err := os.ReadFile(pathToFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading PFX file: %w", err)
+	}
+	return NewX509CertificateFromPFXBytes(pfxData, password)
+}
+
+// NewX509CertificateFromPEMBytes loads an X.509 certificate and its RSA private key from PEM data.
+//
+// Parameters:
+// - pemData: A byte slice containing the PEM blocks of the certificate and of the private key, in any order. The
+// private key can be in the PKCS#1 ("RSA PRIVATE KEY") or PKCS#8 ("PRIVATE KEY") format.
+//
+// Returns:
+// - A pointer to an X509Certificate object containing the certificate and associated RSA private key.
+// - An error if the PEM data does not contain an RSA private key and its certificate.
+func NewX509CertificateFromPEMBytes(pemData []byte) (*X509Certificate, error) {
+	blocks := []*pem.Block{}
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	return newX509CertificateFromPEMBlocks(blocks, "PEM data")
+}
+
+// LoadX509CertificateFromPEM loads an X.509 certificate and its RSA private key from PEM files.
+//
+// Parameters:
+// - pathToCertificate: A string representing the path to the PEM file of the certificate.
+// - pathToPrivateKey: A string representing the path to the PEM file of the private key. It can be the same file
+// as the certificate.
+//
+// Returns:
+// - A pointer to an X509Certificate object containing the certificate and associated RSA private key.
+// - An error if the files cannot be read or decoded.
+func LoadX509CertificateFromPEM(pathToCertificate, pathToPrivateKey string) (*X509Certificate, error) {
+	certificateData, err := os.ReadFile(pathToCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate file: %w", err)
+	}
+	pemData := certificateData
+	if pathToPrivateKey != pathToCertificate {
+		privateKeyData, err := os.ReadFile(pathToPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("error reading private key file: %w", err)
+		}
+		pemData = append(append(pemData, '\n'), privateKeyData...)
+	}
+	return NewX509CertificateFromPEMBytes(pemData)
+}
+
+// newX509CertificateFromPEMBlocks finds an RSA private key and its certificate in PEM blocks, ignoring the other
+// certificates such as the certificate chain of the issuing CA.
+func newX509CertificateFromPEMBlocks(blocks []*pem.Block, source string) (*X509Certificate, error) {
+	var rsaKey *rsa.PrivateKey
+	certificates := []*x509.Certificate{}
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing certificate from %s: %w", source, err)
+			}
+			certificates = append(certificates, cert)
+		case "PRIVATE KEY", "RSA PRIVATE KEY":
+			key, err := parseRSAPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing private key from %s: %w", source, err)
+			}
+			rsaKey = key
+		}
+	}
+	if rsaKey == nil {
+		return nil, fmt.Errorf("%s does not contain an RSA private key", source)
+	}
+
+	for _, cert := range certificates {
+		if publicKey, ok := cert.PublicKey.(*rsa.PublicKey); ok && publicKey.Equal(&rsaKey.PublicKey) {
+			return &X509Certificate{
+				key:         rsaKey,
+				certificate: cert,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s does not contain the certificate of its private key", source)
+}
+
+// parseRSAPrivateKey parses a DER encoded RSA private key in the PKCS#1 or PKCS#8 format.
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
 // Export ====================================================================================
 
 // ExportPFX exports the certificate and private key to a PFX file with the specified password.
@@ -109,8 +278,76 @@ func NewX509Certificate(subject string, keySize int, notBefore, notAfter time.Ti
 //
 // Returns:
 // - An error if the export fails, otherwise nil.
+//
+// Note:
+// The common name of the certificate subject is used as the friendly name of the certificate and private key.
 func (x *X509Certificate) ExportPFX(pathToFile, password string) error {
-	return fmt.Errorf("ExportPFX not implemented")
+	pfxData, err := x.ExportPFXBytes(password, x.certificate.Subject.CommonName)
+	if err != nil {
+		return err
+	}
+
+	if len(pathToFile) != 0 {
+		dir := filepath.Dir(pathToFile)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.WriteFile(pathToFile, pfxData, 0600)
+}
+
+// ExportPFXBytes encodes the certificate and private key in a PFX (PKCS#12) structure protected by the specified password.
+//
+// Parameters:
+// - password: A string representing the password protecting the PFX data.
+// - friendlyName: A string representing the friendly name of the certificate and private key, omitted if empty.
+//
+// Returns:
+// - A byte slice containing the DER encoded PFX data.
+// - An error if the encoding fails.
+//
+// Note:
+// The certificate and private key are encrypted with pbeWithSHAAnd3-KeyTripleDES-CBC and the PFX data is
+// authenticated with an HMAC-SHA1, which can be imported by Windows, OpenSSL and NewX509CertificateFromPFXBytes.
+func (x *X509Certificate) ExportPFXBytes(password string, friendlyName string) ([]byte, error) {
+	pfxData, err := encodePKCS12(x.certificate, x.key, password, friendlyName)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding PFX data: %w", err)
+	}
+	return pfxData, nil
+}
+
+// ExportCertificatePEM exports the certificate to a PEM file.
+//
+// Parameters:
+// - pathToFile: A string representing the path to the file where the certificate will be exported.
+//
+// Returns:
+// - An error if the export fails, otherwise nil.
+func (x *X509Certificate) ExportCertificatePEM(pathToFile string) error {
+	if len(pathToFile) != 0 {
+		dir := filepath.Dir(pathToFile)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return err
+			}
+		}
+	}
+
+	certOut, err := os.Create(pathToFile)
+	if err != nil {
+		return err
+	}
+	defer certOut.Close()
+
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: x.certificate.Raw}); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // ExportRSAPublicKeyPEM exports the public key to a PEM file.
@@ -179,26 +416,48 @@ func (x *X509Certificate) ExportRSAPrivateKeyPEM(pathToFile string) error {
 	return nil
 }
 
-// ExportRSAPublicKeyDER exports the public key to a DER file.
-//
-// Parameters:
-// - pathToFile: A string representing the path to the file where the public key will be exported.
+// ExportRSAPublicKeyDER exports the public key in the DER encoded PKIX SubjectPublicKeyInfo format.
 //
 // Returns:
-// - An error if the export fails, otherwise nil.
+// - A byte slice containing the DER encoded public key.
+// - An error if the export fails.
 func (x *X509Certificate) ExportRSAPublicKeyDER() ([]byte, error) {
-	return nil, fmt.Errorf("ExportRSAPublicKeyDER not implemented")
+	return x509.MarshalPKIXPublicKey(&x.key.PublicKey)
 }
 
-// ExportRSAPublicKeyBCrypt exports the public key to a BCrypt file.
+// ExportRSAPublicKeyPKCS1DER exports the public key in the DER encoded PKCS#1 RSAPublicKey format.
 //
-// Parameters:
-// - pathToFile: A string representing the path to the file where the public key will be exported.
+// Returns:
+// - A byte slice containing the DER encoded public key.
+func (x *X509Certificate) ExportRSAPublicKeyPKCS1DER() []byte {
+	return x509.MarshalPKCS1PublicKey(&x.key.PublicKey)
+}
+
+// ExportRSAPrivateKeyDER exports the private key in the DER encoded PKCS#1 RSAPrivateKey format.
 //
 // Returns:
-// - An error if the export fails, otherwise nil.
+// - A byte slice containing the DER encoded private key.
+func (x *X509Certificate) ExportRSAPrivateKeyDER() []byte {
+	return x509.MarshalPKCS1PrivateKey(x.key)
+}
+
+// ExportCertificateDER exports the certificate in the DER format.
+//
+// Returns:
+// - A byte slice containing the DER encoded certificate.
+func (x *X509Certificate) ExportCertificateDER() []byte {
+	return x.certificate.Raw
+}
+
+// ExportRSAPublicKeyBCrypt exports the public key as a BCRYPT_RSAKEY_BLOB of type BCRYPT_RSAPUBLIC_BLOB, which is
+// the format of the key material of the key credentials.
+//
+// Returns:
+// - A byte slice containing the BCRYPT_RSAKEY_BLOB, which can be parsed with RSAKeyMaterial.FromBytes.
+// - An error if the export fails.
 func (x *X509Certificate) ExportRSAPublicKeyBCrypt() ([]byte, error) {
-	return nil, fmt.Errorf("ExportRSAPublicKeyBCrypt not implemented")
+	keyMaterial := x.GetRSAKeyMaterial()
+	return keyMaterial.ToBytes(), nil
 }
 
 // ExportRSAPublicKey returns the public key material of the certificate.
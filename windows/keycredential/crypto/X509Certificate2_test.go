@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+func newTestX509Certificate(t *testing.T) *X509Certificate {
+	t.Helper()
+	cert, err := NewX509Certificate("alice", 2048, time.Now(), time.Now().AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatalf("NewX509Certificate() error = %v", err)
+	}
+	return cert
+}
+
+func TestX509Certificate_ExportPFXBytes(t *testing.T) {
+	cert := newTestX509Certificate(t)
+
+	pfxData, err := cert.ExportPFXBytes("P@ssw0rd", "alice")
+	if err != nil {
+		t.Fatalf("ExportPFXBytes() error = %v", err)
+	}
+
+	imported, err := NewX509CertificateFromPFXBytes(pfxData, "P@ssw0rd")
+	if err != nil {
+		t.Fatalf("NewX509CertificateFromPFXBytes() error = %v", err)
+	}
+	if !imported.GetRSAPrivateKey().Equal(cert.GetRSAPrivateKey()) {
+		t.Errorf("Imported private key does not match the exported one")
+	}
+	if !bytes.Equal(imported.GetCertificate().Raw, cert.GetCertificate().Raw) {
+		t.Errorf("Imported certificate does not match the exported one")
+	}
+
+	blocks, err := pkcs12.ToPEM(pfxData, "P@ssw0rd")
+	if err != nil {
+		t.Fatalf("pkcs12.ToPEM() error = %v", err)
+	}
+	for _, block := range blocks {
+		if block.Headers["friendlyName"] != "alice" {
+			t.Errorf("Expected friendlyName = alice for the %s block, got %q", block.Type, block.Headers["friendlyName"])
+		}
+		if len(block.Headers["localKeyId"]) == 0 {
+			t.Errorf("Expected a localKeyId for the %s block", block.Type)
+		}
+	}
+
+	if _, err := NewX509CertificateFromPFXBytes(pfxData, "wrong"); err == nil {
+		t.Errorf("Expected an error with a wrong password")
+	}
+}
+
+func TestX509Certificate_ExportPEM(t *testing.T) {
+	cert := newTestX509Certificate(t)
+	dir := t.TempDir()
+	pathToCertificate := filepath.Join(dir, "alice.crt")
+	pathToPrivateKey := filepath.Join(dir, "keys", "alice.key")
+
+	if err := cert.ExportCertificatePEM(pathToCertificate); err != nil {
+		t.Fatalf("ExportCertificatePEM() error = %v", err)
+	}
+	if err := cert.ExportRSAPrivateKeyPEM(pathToPrivateKey); err != nil {
+		t.Fatalf("ExportRSAPrivateKeyPEM() error = %v", err)
+	}
+
+	imported, err := LoadX509CertificateFromPEM(pathToCertificate, pathToPrivateKey)
+	if err != nil {
+		t.Fatalf("LoadX509CertificateFromPEM() error = %v", err)
+	}
+	if !imported.GetRSAPrivateKey().Equal(cert.GetRSAPrivateKey()) {
+		t.Errorf("Imported private key does not match the exported one")
+	}
+
+	// PKCS#8 private keys are accepted too
+	pkcs8Key, _ := x509.MarshalPKCS8PrivateKey(cert.GetRSAPrivateKey())
+	pemData := append(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Key}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.ExportCertificateDER()})...)
+	if _, err := NewX509CertificateFromPEMBytes(pemData); err != nil {
+		t.Errorf("NewX509CertificateFromPEMBytes() error = %v", err)
+	}
+	if _, err := NewX509CertificateFromPEMBytes(pemData[:len(pemData)/2]); err == nil {
+		t.Errorf("Expected an error without certificate")
+	}
+}
+
+func TestX509Certificate_ExportDER(t *testing.T) {
+	cert := newTestX509Certificate(t)
+
+	pkixDER, err := cert.ExportRSAPublicKeyDER()
+	if err != nil {
+		t.Fatalf("ExportRSAPublicKeyDER() error = %v", err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(pkixDER)
+	if err != nil || !cert.GetRSAPublicKey().Equal(publicKey) {
+		t.Errorf("ParsePKIXPublicKey() = %v, %v", publicKey, err)
+	}
+
+	pkcs1PublicKey, err := x509.ParsePKCS1PublicKey(cert.ExportRSAPublicKeyPKCS1DER())
+	if err != nil || !cert.GetRSAPublicKey().Equal(pkcs1PublicKey) {
+		t.Errorf("ParsePKCS1PublicKey() = %v, %v", pkcs1PublicKey, err)
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(cert.ExportRSAPrivateKeyDER())
+	if err != nil || !cert.GetRSAPrivateKey().Equal(privateKey) {
+		t.Errorf("ParsePKCS1PrivateKey() error = %v", err)
+	}
+}
+
+func TestX509Certificate_ExportRSAPublicKeyBCrypt(t *testing.T) {
+	cert := newTestX509Certificate(t)
+
+	blob, err := cert.ExportRSAPublicKeyBCrypt()
+	if err != nil {
+		t.Fatalf("ExportRSAPublicKeyBCrypt() error = %v", err)
+	}
+	// BCRYPT_RSAKEY_BLOB header: magic, bit length, cbPublicExp, cbModulus, cbPrime1, cbPrime2
+	expectedHeader := []byte{'R', 'S', 'A', '1', 0x00, 0x08, 0, 0, 3, 0, 0, 0, 0x00, 0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(blob[:24], expectedHeader) {
+		t.Errorf("Unexpected header %x", blob[:24])
+	}
+
+	keyMaterial := RSAKeyMaterial{}
+	if err := keyMaterial.FromBytes(blob); err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+	if keyMaterial.Exponent != 65537 || keyMaterial.KeySize != 2048 || !bytes.Equal(keyMaterial.Modulus, cert.GetRSAPublicKey().N.Bytes()) {
+		t.Errorf("Unexpected key material %s", keyMaterial.String())
+	}
+}
+
+func TestNewX509CertificateFromKeyPair(t *testing.T) {
+	cert := newTestX509Certificate(t)
+	other := newTestX509Certificate(t)
+
+	imported, err := NewX509CertificateFromKeyPair(cert.GetCertificate(), cert.GetRSAPrivateKey())
+	if err != nil {
+		t.Fatalf("NewX509CertificateFromKeyPair() error = %v", err)
+	}
+	if imported.GetCertificate() != cert.GetCertificate() || imported.GetRSAPrivateKey() != cert.GetRSAPrivateKey() {
+		t.Errorf("Unexpected key pair")
+	}
+
+	if _, err := NewX509CertificateFromKeyPair(cert.GetCertificate(), other.GetRSAPrivateKey()); err == nil {
+		t.Errorf("Expected an error with the private key of another certificate")
+	}
+}
@@ -0,0 +1,207 @@
+package security
+
+import (
+	"github.com/TheManticoreProject/Manticore/network/ldap/schema"
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Rights implicitly granted to the owner of an object
+const ownerImplicitRights = READ_CONTROL | WRITE_DAC
+
+// EffectiveRights represents the rights granted to a principal by a security descriptor.
+//
+// Fields:
+//   - Mask: The rights granted on the whole object.
+//   - ObjectRights: The rights granted on specific properties, property sets, extended rights or validated writes,
+//     indexed by the lowercase string representation of their GUID.
+type EffectiveRights struct {
+	Mask         AccessMask
+	ObjectRights map[string]AccessMask
+
+	// Rights denied on specific object types before being granted on the whole object
+	deniedObjectRights map[string]AccessMask
+}
+
+// GetEffectiveRights computes the rights granted by the DACL of the security descriptor to a principal.
+//
+// The ACEs are evaluated in order like the access check of Windows: the first ACE allowing or denying a right
+// decides for it. Inherit-only ACEs and ACEs of other principals are ignored. Generic rights are mapped to
+// the Active Directory specific rights. The owner is granted READ_CONTROL and WRITE_DAC unless the DACL holds
+// ACEs for OWNER RIGHTS.
+//
+// Parameters:
+//   - SIDs: The SIDs of the principal, including the SIDs of all the groups it is a transitive member of.
+//
+// Returns:
+//   - A pointer to the effective rights of the principal. A missing (NULL) DACL grants all the rights.
+func (sd *SecurityDescriptor) GetEffectiveRights(SIDs []*sid.SID) *EffectiveRights {
+	return sd.getEffectiveRights(SIDs, SIDs)
+}
+
+// GetTrusteeRights computes the rights granted to a principal through one of its SIDs. The ACEs are evaluated
+// like GetEffectiveRights, but only the allow ACEs of the trustee SID grant rights, while the deny ACEs of all
+// the SIDs of the principal still apply. A right granted in the effective rights of the principal is granted to
+// at least one of its SIDs.
+//
+// Parameters:
+//   - SIDs: The SIDs of the principal, including the SIDs of all the groups it is a transitive member of.
+//   - trusteeSID: The SID of the principal or of one of its groups granting the rights.
+//
+// Returns:
+//   - A pointer to the rights granted through the trustee SID. A missing (NULL) DACL grants all the rights.
+func (sd *SecurityDescriptor) GetTrusteeRights(SIDs []*sid.SID, trusteeSID *sid.SID) *EffectiveRights {
+	return sd.getEffectiveRights(SIDs, []*sid.SID{trusteeSID})
+}
+
+// getEffectiveRights computes the rights granted to a principal by the allow ACEs of some of its SIDs, after the
+// deny ACEs of all its SIDs.
+func (sd *SecurityDescriptor) getEffectiveRights(SIDs []*sid.SID, allowedSIDs []*sid.SID) *EffectiveRights {
+	rights := &EffectiveRights{Mask: 0, ObjectRights: map[string]AccessMask{}, deniedObjectRights: map[string]AccessMask{}}
+
+	if sd.DACL == nil {
+		rights.Mask = ADS_RIGHT_GENERIC_ALL | ACCESS_SYSTEM_SECURITY
+		return rights
+	}
+
+	isOwner := sd.Owner != nil && containsSID(SIDs, sd.Owner)
+	ownerRights, _ := sid.FromString(sid.SID_OWNER_RIGHTS)
+	hasOwnerRightsACE := false
+	for _, ace := range sd.DACL.ACEs {
+		if ace.SID != nil && ace.SID.Equal(ownerRights) && !ace.IsInheritOnly() {
+			hasOwnerRightsACE = true
+		}
+	}
+
+	// The rights of the owner are granted through the SID of the owner
+	grantsOwnerRights := isOwner && containsSID(allowedSIDs, sd.Owner)
+
+	deniedMask := AccessMask(0)
+	if grantsOwnerRights && !hasOwnerRightsACE {
+		rights.Mask |= ownerImplicitRights
+	}
+
+	for _, ace := range sd.DACL.ACEs {
+		if ace.IsInheritOnly() || ace.SID == nil || (!ace.IsAllowed() && !ace.IsDenied()) {
+			continue
+		}
+		if !containsSID(SIDs, ace.SID) && !(isOwner && ace.SID.Equal(ownerRights)) {
+			continue
+		}
+		if ace.IsAllowed() && !containsSID(allowedSIDs, ace.SID) && !(grantsOwnerRights && ace.SID.Equal(ownerRights)) {
+			continue
+		}
+
+		mask := mapGenericRights(ace.Mask)
+		if ace.ObjectType == nil {
+			if ace.IsAllowed() {
+				rights.Mask |= mask &^ deniedMask
+			} else {
+				deniedMask |= mask &^ rights.Mask
+			}
+			continue
+		}
+
+		objectType := ace.ObjectType.ToFormatD()
+		if ace.IsAllowed() {
+			rights.ObjectRights[objectType] |= mask &^ (deniedMask | rights.deniedObjectRights[objectType])
+		} else {
+			rights.deniedObjectRights[objectType] |= mask &^ (rights.Mask | rights.ObjectRights[objectType])
+		}
+	}
+
+	for objectType, mask := range rights.ObjectRights {
+		if mask == 0 {
+			delete(rights.ObjectRights, objectType)
+		}
+	}
+
+	return rights
+}
+
+// HasRight checks if the effective rights grant a right on the whole object or on an object type.
+//
+// Parameters:
+//   - mask: The access mask of the right.
+//   - objectType: A pointer to the GUID of the property, extended right or validated write, or nil for
+//     the whole object.
+//
+// Returns:
+//   - True if all the bits of the mask are granted on the object type, false otherwise.
+func (r *EffectiveRights) HasRight(mask AccessMask, objectType *guid.GUID) bool {
+	if objectType == nil {
+		return r.Mask.Has(mask)
+	}
+	return r.grantedOn(objectType.ToFormatD(), r.deniedObjectRights[objectType.ToFormatD()]).Has(mask)
+}
+
+// HasPropertyRight checks if the effective rights grant a right on an attribute, either on the whole object,
+// on the attribute itself or on one of the property sets containing the attribute.
+//
+// Parameters:
+//   - mask: The access mask of the right, typically ADS_RIGHT_DS_READ_PROP or ADS_RIGHT_DS_WRITE_PROP.
+//   - attribute: A pointer to the schemaIDGUID of the attribute.
+//
+// Returns:
+//   - True if all the bits of the mask are granted on the attribute, false otherwise.
+func (r *EffectiveRights) HasPropertyRight(mask AccessMask, attribute *guid.GUID) bool {
+	if attribute == nil {
+		return r.Mask.Has(mask)
+	}
+	value := attribute.ToFormatD()
+
+	propertySets := []string{}
+	if name, ok := schema.GUIDToSchemaAttributeDisplayName[value]; ok {
+		for propertySet, attributes := range schema.PropertySetToAttributeDisplayNames {
+			for _, member := range attributes {
+				if member == name {
+					propertySets = append(propertySets, propertySet)
+					break
+				}
+			}
+		}
+	}
+
+	denied := r.deniedObjectRights[value]
+	for _, propertySet := range propertySets {
+		denied |= r.deniedObjectRights[propertySet]
+	}
+
+	granted := r.grantedOn(value, denied)
+	for _, propertySet := range propertySets {
+		granted |= r.ObjectRights[propertySet] &^ denied
+	}
+	return granted.Has(mask)
+}
+
+// grantedOn returns the rights granted on an object type, given the rights denied on it.
+func (r *EffectiveRights) grantedOn(objectType string, denied AccessMask) AccessMask {
+	return r.ObjectRights[objectType] | (r.Mask &^ denied)
+}
+
+// mapGenericRights maps the generic rights of an access mask to the Active Directory specific rights.
+func mapGenericRights(mask AccessMask) AccessMask {
+	if mask&GENERIC_READ != 0 {
+		mask |= ADS_RIGHT_GENERIC_READ
+	}
+	if mask&GENERIC_WRITE != 0 {
+		mask |= ADS_RIGHT_GENERIC_WRITE
+	}
+	if mask&GENERIC_EXECUTE != 0 {
+		mask |= ADS_RIGHT_GENERIC_EXECUTE
+	}
+	if mask&GENERIC_ALL != 0 {
+		mask |= ADS_RIGHT_GENERIC_ALL
+	}
+	return mask &^ (GENERIC_READ | GENERIC_WRITE | GENERIC_EXECUTE | GENERIC_ALL)
+}
+
+// containsSID checks if a SID is in a slice of SIDs.
+func containsSID(SIDs []*sid.SID, value *sid.SID) bool {
+	for _, s := range SIDs {
+		if s.Equal(value) {
+			return true
+		}
+	}
+	return false
+}
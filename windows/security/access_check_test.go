@@ -0,0 +1,93 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+func TestGetEffectiveRights(t *testing.T) {
+	user, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	group, _ := sid.FromString("S-1-5-21-1-2-3-1105")
+	member, _ := guid.FromString("bf9679c0-0de6-11d0-a285-00aa003049e2")
+	keyCredentialLink, _ := guid.FromString("5b47d60f-6090-40b2-9f37-2a4de88f3063")
+	allowedToAct, _ := guid.FromString("3f78c3e5-f79a-46bd-a0b8-9d18116ddc79")
+	forceChangePassword, _ := guid.FromString("00299570-246d-11d0-a768-00aa006e0529")
+
+	tests := []struct {
+		name      string
+		sddl      string
+		mask      AccessMask
+		attribute *guid.GUID
+		expected  bool
+	}{
+		{"Generic all through a group", "D:(A;;GA;;;S-1-5-21-1-2-3-1105)", ADS_RIGHT_GENERIC_ALL, nil, true},
+		{"Other principal", "D:(A;;GA;;;S-1-5-21-1-2-3-1106)", ADS_RIGHT_GENERIC_ALL, nil, false},
+		{"Inherit only", "D:(A;CIIO;GA;;;S-1-5-21-1-2-3-1104)", ADS_RIGHT_GENERIC_ALL, nil, false},
+		{"Deny before allow", "D:(D;;WD;;;S-1-5-21-1-2-3-1105)(A;;GA;;;S-1-5-21-1-2-3-1104)", WRITE_DAC, nil, false},
+		{"Allow before deny", "D:(A;;GA;;;S-1-5-21-1-2-3-1104)(D;;WD;;;S-1-5-21-1-2-3-1105)", WRITE_DAC, nil, true},
+		{"Implicit rights of the owner", "O:S-1-5-21-1-2-3-1104D:", WRITE_DAC, nil, true},
+		{"Owner rights override", "O:S-1-5-21-1-2-3-1104D:(A;;RC;;;OW)", WRITE_DAC, nil, false},
+		{"Write on an attribute", "D:(OA;;WP;5b47d60f-6090-40b2-9f37-2a4de88f3063;;S-1-5-21-1-2-3-1104)", ADS_RIGHT_DS_WRITE_PROP, keyCredentialLink, true},
+		{"Write on another attribute", "D:(OA;;WP;5b47d60f-6090-40b2-9f37-2a4de88f3063;;S-1-5-21-1-2-3-1104)", ADS_RIGHT_DS_WRITE_PROP, member, false},
+		{"Write on a property set", "D:(OA;;WP;4c164200-20c0-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1104)", ADS_RIGHT_DS_WRITE_PROP, allowedToAct, true},
+		{"Denied attribute of an allowed property set", "D:(OD;;WP;3f78c3e5-f79a-46bd-a0b8-9d18116ddc79;;S-1-5-21-1-2-3-1104)(OA;;WP;4c164200-20c0-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1104)", ADS_RIGHT_DS_WRITE_PROP, allowedToAct, false},
+		{"Denied attribute of an allowed object", "D:(OD;;WP;bf9679c0-0de6-11d0-a285-00aa003049e2;;S-1-5-21-1-2-3-1104)(A;;WP;;;S-1-5-21-1-2-3-1104)", ADS_RIGHT_DS_WRITE_PROP, member, false},
+		{"Extended right", "D:(OA;;CR;00299570-246d-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1105)", ADS_RIGHT_DS_CONTROL_ACCESS, forceChangePassword, true},
+		{"All extended rights", "D:(A;;CR;;;S-1-5-21-1-2-3-1105)", ADS_RIGHT_DS_CONTROL_ACCESS, forceChangePassword, true},
+		{"Generic write mapping", "D:(A;;GW;;;S-1-5-21-1-2-3-1104)", ADS_RIGHT_DS_WRITE_PROP, member, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd, err := ParseSDDL(tt.sddl, nil)
+			if err != nil {
+				t.Fatalf("ParseSDDL(%s) error = %v", tt.sddl, err)
+			}
+			rights := sd.GetEffectiveRights([]*sid.SID{user, group})
+			if result := rights.HasPropertyRight(tt.mask, tt.attribute); result != tt.expected {
+				t.Errorf("HasPropertyRight(%s, %v) = %v; want %v", tt.mask, tt.attribute, result, tt.expected)
+			}
+		})
+	}
+
+	if rights := (&SecurityDescriptor{}).GetEffectiveRights([]*sid.SID{user}); !rights.HasRight(ADS_RIGHT_GENERIC_ALL, nil) {
+		t.Error("A NULL DACL should grant all the rights")
+	}
+}
+
+func TestGetTrusteeRights(t *testing.T) {
+	user, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	group, _ := sid.FromString("S-1-5-21-1-2-3-1105")
+	otherGroup, _ := sid.FromString("S-1-5-21-1-2-3-1106")
+	SIDs := []*sid.SID{user, group, otherGroup}
+
+	tests := []struct {
+		name     string
+		sddl     string
+		trustee  *sid.SID
+		expected bool
+	}{
+		{"Allowed to the trustee", "D:(A;;WD;;;S-1-5-21-1-2-3-1105)", group, true},
+		{"Allowed to another SID", "D:(A;;WD;;;S-1-5-21-1-2-3-1105)", user, false},
+		{"Denied to another SID first", "D:(D;;WD;;;S-1-5-21-1-2-3-1106)(A;;WD;;;S-1-5-21-1-2-3-1104)", user, false},
+		{"Allowed to the trustee first", "D:(A;;WD;;;S-1-5-21-1-2-3-1104)(D;;WD;;;S-1-5-21-1-2-3-1106)", user, true},
+		{"Implicit rights of the owner", "O:S-1-5-21-1-2-3-1104D:", user, true},
+		{"Implicit rights of another owner", "O:S-1-5-21-1-2-3-1104D:", group, false},
+		{"Owner rights granted to the owner", "O:S-1-5-21-1-2-3-1105D:(A;;WD;;;OW)", group, true},
+		{"Owner rights granted to another owner", "O:S-1-5-21-1-2-3-1105D:(A;;WD;;;OW)", user, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd, err := ParseSDDL(tt.sddl, nil)
+			if err != nil {
+				t.Fatalf("ParseSDDL(%s) error = %v", tt.sddl, err)
+			}
+			if got := sd.GetTrusteeRights(SIDs, tt.trustee).HasRight(WRITE_DAC, nil); got != tt.expected {
+				t.Errorf("GetTrusteeRights(%s).HasRight(WRITE_DAC) = %v; want %v", tt.trustee, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AccessMask is the set of rights granted, denied or audited by an ACE.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/7a53f60e-e730-4dfe-bbe9-b21b62eb790b
+type AccessMask uint32
+
+// Generic and standard rights
+const (
+	GENERIC_READ           AccessMask = 0x80000000
+	GENERIC_WRITE          AccessMask = 0x40000000
+	GENERIC_EXECUTE        AccessMask = 0x20000000
+	GENERIC_ALL            AccessMask = 0x10000000
+	MAXIMUM_ALLOWED        AccessMask = 0x02000000
+	ACCESS_SYSTEM_SECURITY AccessMask = 0x01000000
+	SYNCHRONIZE            AccessMask = 0x00100000
+	WRITE_OWNER            AccessMask = 0x00080000
+	WRITE_DAC              AccessMask = 0x00040000
+	READ_CONTROL           AccessMask = 0x00020000
+	DELETE                 AccessMask = 0x00010000
+)
+
+// Rights specific to Active Directory objects
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/990fb975-ab31-4bc1-8b75-5da132cd4584
+const (
+	ADS_RIGHT_DS_CREATE_CHILD   AccessMask = 0x00000001
+	ADS_RIGHT_DS_DELETE_CHILD   AccessMask = 0x00000002
+	ADS_RIGHT_ACTRL_DS_LIST     AccessMask = 0x00000004
+	ADS_RIGHT_DS_SELF           AccessMask = 0x00000008
+	ADS_RIGHT_DS_READ_PROP      AccessMask = 0x00000010
+	ADS_RIGHT_DS_WRITE_PROP     AccessMask = 0x00000020
+	ADS_RIGHT_DS_DELETE_TREE    AccessMask = 0x00000040
+	ADS_RIGHT_DS_LIST_OBJECT    AccessMask = 0x00000080
+	ADS_RIGHT_DS_CONTROL_ACCESS AccessMask = 0x00000100
+
+	// Rights mapped from the generic rights on Active Directory objects
+	ADS_RIGHT_GENERIC_READ    AccessMask = READ_CONTROL | ADS_RIGHT_ACTRL_DS_LIST | ADS_RIGHT_DS_READ_PROP | ADS_RIGHT_DS_LIST_OBJECT
+	ADS_RIGHT_GENERIC_WRITE   AccessMask = READ_CONTROL | ADS_RIGHT_DS_SELF | ADS_RIGHT_DS_WRITE_PROP
+	ADS_RIGHT_GENERIC_EXECUTE AccessMask = READ_CONTROL | ADS_RIGHT_ACTRL_DS_LIST
+	ADS_RIGHT_GENERIC_ALL     AccessMask = 0x000f01ff
+)
+
+// Rights specific to files
+// Src: https://learn.microsoft.com/en-us/windows/win32/fileio/file-access-rights-constants
+const (
+	FILE_ALL_ACCESS      AccessMask = 0x001f01ff
+	FILE_GENERIC_READ    AccessMask = 0x00120089
+	FILE_GENERIC_WRITE   AccessMask = 0x00120116
+	FILE_GENERIC_EXECUTE AccessMask = 0x001200a0
+)
+
+// Rights specific to registry keys
+// Src: https://learn.microsoft.com/en-us/windows/win32/sysinfo/registry-key-security-and-access-rights
+const (
+	KEY_ALL_ACCESS AccessMask = 0x000f003f
+	KEY_READ       AccessMask = 0x00020019
+	KEY_WRITE      AccessMask = 0x00020006
+	KEY_EXECUTE    AccessMask = 0x00020019
+)
+
+// Rights of mandatory label ACEs
+const (
+	SYSTEM_MANDATORY_LABEL_NO_WRITE_UP   AccessMask = 0x00000001
+	SYSTEM_MANDATORY_LABEL_NO_READ_UP    AccessMask = 0x00000002
+	SYSTEM_MANDATORY_LABEL_NO_EXECUTE_UP AccessMask = 0x00000004
+)
+
+// accessMaskNames lists the names of the single rights, from the most to the least significant bit
+var accessMaskNames = []struct {
+	mask AccessMask
+	name string
+}{
+	{GENERIC_READ, "GenericRead"},
+	{GENERIC_WRITE, "GenericWrite"},
+	{GENERIC_EXECUTE, "GenericExecute"},
+	{GENERIC_ALL, "GenericAll"},
+	{MAXIMUM_ALLOWED, "MaximumAllowed"},
+	{ACCESS_SYSTEM_SECURITY, "AccessSystemSecurity"},
+	{SYNCHRONIZE, "Synchronize"},
+	{WRITE_OWNER, "WriteOwner"},
+	{WRITE_DAC, "WriteDacl"},
+	{READ_CONTROL, "ReadControl"},
+	{DELETE, "Delete"},
+	{ADS_RIGHT_DS_CONTROL_ACCESS, "ControlAccess"},
+	{ADS_RIGHT_DS_LIST_OBJECT, "ListObject"},
+	{ADS_RIGHT_DS_DELETE_TREE, "DeleteTree"},
+	{ADS_RIGHT_DS_WRITE_PROP, "WriteProperty"},
+	{ADS_RIGHT_DS_READ_PROP, "ReadProperty"},
+	{ADS_RIGHT_DS_SELF, "Self"},
+	{ADS_RIGHT_ACTRL_DS_LIST, "ListChildren"},
+	{ADS_RIGHT_DS_DELETE_CHILD, "DeleteChild"},
+	{ADS_RIGHT_DS_CREATE_CHILD, "CreateChild"},
+}
+
+// Has checks if the access mask holds all the given rights.
+//
+// Parameters:
+//   - rights: The AccessMask holding the rights to check.
+//
+// Returns:
+//   - True if every right of rights is in the access mask, false otherwise.
+func (m AccessMask) Has(rights AccessMask) bool {
+	return m&rights == rights
+}
+
+// Names returns the names of the rights of the access mask, as used by Active Directory.
+//
+// Returns:
+//   - A slice of strings containing the name of each right, or "GenericAll" alone when the access mask holds all
+//     the rights of Active Directory objects. Unknown bits are returned in hexadecimal.
+func (m AccessMask) Names() []string {
+	names := []string{}
+	if m.Has(ADS_RIGHT_GENERIC_ALL) {
+		names = append(names, "GenericAll")
+		m &^= ADS_RIGHT_GENERIC_ALL
+	}
+	for _, right := range accessMaskNames {
+		if m&right.mask != 0 {
+			names = append(names, right.name)
+			m &^= right.mask
+		}
+	}
+	if m != 0 {
+		names = append(names, fmt.Sprintf("0x%08x", uint32(m)))
+	}
+	return names
+}
+
+// String returns the names of the rights of the access mask separated by "|".
+//
+// Returns:
+//   - A string containing the rights of the access mask.
+func (m AccessMask) String() string {
+	return strings.Join(m.Names(), "|")
+}
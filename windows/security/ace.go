@@ -0,0 +1,245 @@
+package security
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// ACE types
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/628ebb1d-c509-4ea0-a10f-77ef97ca4586
+const (
+	ACCESS_ALLOWED_ACE_TYPE                 uint8 = 0x00
+	ACCESS_DENIED_ACE_TYPE                  uint8 = 0x01
+	SYSTEM_AUDIT_ACE_TYPE                   uint8 = 0x02
+	SYSTEM_ALARM_ACE_TYPE                   uint8 = 0x03
+	ACCESS_ALLOWED_COMPOUND_ACE_TYPE        uint8 = 0x04
+	ACCESS_ALLOWED_OBJECT_ACE_TYPE          uint8 = 0x05
+	ACCESS_DENIED_OBJECT_ACE_TYPE           uint8 = 0x06
+	SYSTEM_AUDIT_OBJECT_ACE_TYPE            uint8 = 0x07
+	SYSTEM_ALARM_OBJECT_ACE_TYPE            uint8 = 0x08
+	ACCESS_ALLOWED_CALLBACK_ACE_TYPE        uint8 = 0x09
+	ACCESS_DENIED_CALLBACK_ACE_TYPE         uint8 = 0x0a
+	ACCESS_ALLOWED_CALLBACK_OBJECT_ACE_TYPE uint8 = 0x0b
+	ACCESS_DENIED_CALLBACK_OBJECT_ACE_TYPE  uint8 = 0x0c
+	SYSTEM_AUDIT_CALLBACK_ACE_TYPE          uint8 = 0x0d
+	SYSTEM_ALARM_CALLBACK_ACE_TYPE          uint8 = 0x0e
+	SYSTEM_AUDIT_CALLBACK_OBJECT_ACE_TYPE   uint8 = 0x0f
+	SYSTEM_ALARM_CALLBACK_OBJECT_ACE_TYPE   uint8 = 0x10
+	SYSTEM_MANDATORY_LABEL_ACE_TYPE         uint8 = 0x11
+	SYSTEM_RESOURCE_ATTRIBUTE_ACE_TYPE      uint8 = 0x12
+	SYSTEM_SCOPED_POLICY_ID_ACE_TYPE        uint8 = 0x13
+	SYSTEM_PROCESS_TRUST_LABEL_ACE_TYPE     uint8 = 0x14
+	SYSTEM_ACCESS_FILTER_ACE_TYPE           uint8 = 0x15
+)
+
+// ACE flags
+const (
+	OBJECT_INHERIT_ACE         uint8 = 0x01
+	CONTAINER_INHERIT_ACE      uint8 = 0x02
+	NO_PROPAGATE_INHERIT_ACE   uint8 = 0x04
+	INHERIT_ONLY_ACE           uint8 = 0x08
+	INHERITED_ACE              uint8 = 0x10
+	CRITICAL_ACE_FLAG          uint8 = 0x20
+	SUCCESSFUL_ACCESS_ACE_FLAG uint8 = 0x40
+	FAILED_ACCESS_ACE_FLAG     uint8 = 0x80
+)
+
+// Flags of object ACEs indicating which GUIDs are present
+const (
+	ACE_OBJECT_TYPE_PRESENT           uint32 = 0x00000001
+	ACE_INHERITED_OBJECT_TYPE_PRESENT uint32 = 0x00000002
+)
+
+// Size of the ACE_HEADER
+const ACE_HEADER_SIZE = 4
+
+// ACE represents an access control entry of any type.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/d06e5a81-176e-46c6-9cf7-9137aad3cc0b
+//
+// Fields:
+//   - Type: The type of the ACE, one of the *_ACE_TYPE constants.
+//   - Flags: The inheritance and audit flags of the ACE.
+//   - Mask: The rights granted, denied or audited by the ACE.
+//   - ObjectType: For object ACEs, the GUID of the property, property set, extended right, validated write or child
+//     class the ACE applies to, or nil.
+//   - InheritedObjectType: For object ACEs, the GUID of the class of the objects inheriting the ACE, or nil.
+//   - SID: The SID of the trustee of the ACE. It is nil for compound ACEs, whose body is kept in ApplicationData.
+//   - ApplicationData: The bytes following the SID, holding the condition of callback ACEs or the attribute of
+//     resource attribute ACEs.
+type ACE struct {
+	Type                uint8
+	Flags               uint8
+	Mask                AccessMask
+	ObjectType          *guid.GUID
+	InheritedObjectType *guid.GUID
+	SID                 *sid.SID
+	ApplicationData     []byte
+}
+
+// IsObjectACE checks if the ACE is of an object ACE type, holding ObjectType and InheritedObjectType.
+//
+// Returns:
+//   - True if the ACE is an object ACE, false otherwise.
+func (a *ACE) IsObjectACE() bool {
+	switch a.Type {
+	case ACCESS_ALLOWED_OBJECT_ACE_TYPE, ACCESS_DENIED_OBJECT_ACE_TYPE,
+		SYSTEM_AUDIT_OBJECT_ACE_TYPE, SYSTEM_ALARM_OBJECT_ACE_TYPE,
+		ACCESS_ALLOWED_CALLBACK_OBJECT_ACE_TYPE, ACCESS_DENIED_CALLBACK_OBJECT_ACE_TYPE,
+		SYSTEM_AUDIT_CALLBACK_OBJECT_ACE_TYPE, SYSTEM_ALARM_CALLBACK_OBJECT_ACE_TYPE:
+		return true
+	}
+	return false
+}
+
+// IsAllowed checks if the ACE grants rights.
+//
+// Returns:
+//   - True if the ACE is an access allowed ACE of any kind, false otherwise.
+func (a *ACE) IsAllowed() bool {
+	switch a.Type {
+	case ACCESS_ALLOWED_ACE_TYPE, ACCESS_ALLOWED_COMPOUND_ACE_TYPE, ACCESS_ALLOWED_OBJECT_ACE_TYPE,
+		ACCESS_ALLOWED_CALLBACK_ACE_TYPE, ACCESS_ALLOWED_CALLBACK_OBJECT_ACE_TYPE:
+		return true
+	}
+	return false
+}
+
+// IsDenied checks if the ACE denies rights.
+//
+// Returns:
+//   - True if the ACE is an access denied ACE of any kind, false otherwise.
+func (a *ACE) IsDenied() bool {
+	switch a.Type {
+	case ACCESS_DENIED_ACE_TYPE, ACCESS_DENIED_OBJECT_ACE_TYPE,
+		ACCESS_DENIED_CALLBACK_ACE_TYPE, ACCESS_DENIED_CALLBACK_OBJECT_ACE_TYPE:
+		return true
+	}
+	return false
+}
+
+// IsInheritOnly checks if the ACE only applies to the objects inheriting it.
+//
+// Returns:
+//   - True if the INHERIT_ONLY_ACE flag is set, false otherwise.
+func (a *ACE) IsInheritOnly() bool {
+	return a.Flags&INHERIT_ONLY_ACE != 0
+}
+
+// IsInherited checks if the ACE was inherited from a parent object.
+//
+// Returns:
+//   - True if the INHERITED_ACE flag is set, false otherwise.
+func (a *ACE) IsInherited() bool {
+	return a.Flags&INHERITED_ACE != 0
+}
+
+// FromBytes parses an ACE from its binary representation.
+//
+// Parameters:
+//   - data: A byte slice starting with the ACE. Bytes after the size given by the ACE header are ignored.
+//
+// Returns:
+//   - An error if the ACE is malformed.
+func (a *ACE) FromBytes(data []byte) error {
+	if len(data) < ACE_HEADER_SIZE+4 {
+		return fmt.Errorf("ACE is too short (%d bytes)", len(data))
+	}
+	a.Type = data[0]
+	a.Flags = data[1]
+	size := int(binary.LittleEndian.Uint16(data[2:4]))
+	if size < ACE_HEADER_SIZE+4 || size > len(data) {
+		return fmt.Errorf("ACE has an invalid size %d", size)
+	}
+	body := data[ACE_HEADER_SIZE:size]
+
+	a.Mask = AccessMask(binary.LittleEndian.Uint32(body[0:4]))
+	offset := 4
+	a.ObjectType = nil
+	a.InheritedObjectType = nil
+	a.SID = nil
+	a.ApplicationData = nil
+
+	if a.Type == ACCESS_ALLOWED_COMPOUND_ACE_TYPE {
+		a.ApplicationData = append([]byte{}, body[offset:]...)
+		return nil
+	}
+
+	if a.IsObjectACE() {
+		if len(body) < offset+4 {
+			return fmt.Errorf("object ACE is too short (%d bytes)", size)
+		}
+		objectFlags := binary.LittleEndian.Uint32(body[offset : offset+4])
+		offset += 4
+		if objectFlags&ACE_OBJECT_TYPE_PRESENT != 0 {
+			if len(body) < offset+16 {
+				return fmt.Errorf("object ACE is too short for its object type (%d bytes)", size)
+			}
+			a.ObjectType = &guid.GUID{}
+			a.ObjectType.FromRawBytes(body[offset : offset+16])
+			offset += 16
+		}
+		if objectFlags&ACE_INHERITED_OBJECT_TYPE_PRESENT != 0 {
+			if len(body) < offset+16 {
+				return fmt.Errorf("object ACE is too short for its inherited object type (%d bytes)", size)
+			}
+			a.InheritedObjectType = &guid.GUID{}
+			a.InheritedObjectType.FromRawBytes(body[offset : offset+16])
+			offset += 16
+		}
+	}
+
+	a.SID = &sid.SID{}
+	if err := a.SID.FromBytes(body[offset:]); err != nil {
+		return fmt.Errorf("error parsing SID of ACE: %w", err)
+	}
+	offset += a.SID.Size()
+	if offset < len(body) {
+		a.ApplicationData = append([]byte{}, body[offset:]...)
+	}
+
+	return nil
+}
+
+// ToBytes returns the binary representation of the ACE.
+//
+// Returns:
+//   - A byte slice containing the ACE.
+//   - An error if the ACE has no SID or does not fit in the 16-bit size of its header.
+func (a *ACE) ToBytes() ([]byte, error) {
+	body := binary.LittleEndian.AppendUint32(nil, uint32(a.Mask))
+
+	if a.Type != ACCESS_ALLOWED_COMPOUND_ACE_TYPE {
+		if a.IsObjectACE() {
+			objectFlags := uint32(0)
+			if a.ObjectType != nil {
+				objectFlags |= ACE_OBJECT_TYPE_PRESENT
+			}
+			if a.InheritedObjectType != nil {
+				objectFlags |= ACE_INHERITED_OBJECT_TYPE_PRESENT
+			}
+			body = binary.LittleEndian.AppendUint32(body, objectFlags)
+			if a.ObjectType != nil {
+				body = append(body, a.ObjectType.ToBytes()...)
+			}
+			if a.InheritedObjectType != nil {
+				body = append(body, a.InheritedObjectType.ToBytes()...)
+			}
+		}
+		if a.SID == nil {
+			return nil, fmt.Errorf("ACE of type 0x%02x has no SID", a.Type)
+		}
+		body = append(body, a.SID.ToBytes()...)
+	}
+	body = append(body, a.ApplicationData...)
+
+	size := ACE_HEADER_SIZE + len(body)
+	if size > 0xffff {
+		return nil, fmt.Errorf("ACE is too large (%d bytes)", size)
+	}
+	data := []byte{a.Type, a.Flags}
+	data = binary.LittleEndian.AppendUint16(data, uint16(size))
+	return append(data, body...), nil
+}
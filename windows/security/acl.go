@@ -0,0 +1,106 @@
+package security
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ACL revisions. ACL_REVISION_DS is required for the ACLs holding object ACEs.
+const (
+	ACL_REVISION    uint8 = 2
+	ACL_REVISION_DS uint8 = 4
+)
+
+// Size of the ACL header
+const ACL_HEADER_SIZE = 8
+
+// ACL represents an access control list.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/20233ed8-a6c6-4097-aafa-dd545ed24428
+//
+// Fields:
+//   - Revision: The revision of the ACL, ACL_REVISION or ACL_REVISION_DS.
+//   - ACEs: The access control entries of the ACL, in order.
+type ACL struct {
+	Revision uint8
+	ACEs     []*ACE
+}
+
+// NewACL creates an empty ACL.
+//
+// Returns:
+//   - A pointer to the ACL, of revision ACL_REVISION.
+func NewACL() *ACL {
+	return &ACL{Revision: ACL_REVISION, ACEs: []*ACE{}}
+}
+
+// AddACE appends an ACE to the ACL, raising its revision to ACL_REVISION_DS for object ACEs.
+//
+// Parameters:
+//   - ace: A pointer to the ACE to append.
+func (l *ACL) AddACE(ace *ACE) {
+	if ace.IsObjectACE() && l.Revision < ACL_REVISION_DS {
+		l.Revision = ACL_REVISION_DS
+	}
+	l.ACEs = append(l.ACEs, ace)
+}
+
+// FromBytes parses an ACL from its binary representation.
+//
+// Parameters:
+//   - data: A byte slice starting with the ACL. Bytes after the size given by the ACL header are ignored.
+//
+// Returns:
+//   - An error if the ACL or one of its ACEs is malformed.
+func (l *ACL) FromBytes(data []byte) error {
+	if len(data) < ACL_HEADER_SIZE {
+		return fmt.Errorf("ACL is too short (%d bytes)", len(data))
+	}
+	l.Revision = data[0]
+	size := int(binary.LittleEndian.Uint16(data[2:4]))
+	if size < ACL_HEADER_SIZE || size > len(data) {
+		return fmt.Errorf("ACL has an invalid size %d", size)
+	}
+	aceCount := int(binary.LittleEndian.Uint16(data[4:6]))
+
+	l.ACEs = make([]*ACE, 0, aceCount)
+	offset := ACL_HEADER_SIZE
+	for k := 0; k < aceCount; k++ {
+		if offset+ACE_HEADER_SIZE > size {
+			return fmt.Errorf("ACE %d is out of bounds", k)
+		}
+		ace := &ACE{}
+		if err := ace.FromBytes(data[offset:size]); err != nil {
+			return fmt.Errorf("error parsing ACE %d: %w", k, err)
+		}
+		l.ACEs = append(l.ACEs, ace)
+		offset += int(binary.LittleEndian.Uint16(data[offset+2 : offset+4]))
+	}
+
+	return nil
+}
+
+// ToBytes returns the binary representation of the ACL.
+//
+// Returns:
+//   - A byte slice containing the ACL.
+//   - An error if one of the ACEs cannot be serialized or if the ACL is too large.
+func (l *ACL) ToBytes() ([]byte, error) {
+	aces := []byte{}
+	for k, ace := range l.ACEs {
+		data, err := ace.ToBytes()
+		if err != nil {
+			return nil, fmt.Errorf("error serializing ACE %d: %w", k, err)
+		}
+		aces = append(aces, data...)
+	}
+
+	size := ACL_HEADER_SIZE + len(aces)
+	if size > 0xffff || len(l.ACEs) > 0xffff {
+		return nil, fmt.Errorf("ACL is too large (%d bytes)", size)
+	}
+	data := []byte{l.Revision, 0x00}
+	data = binary.LittleEndian.AppendUint16(data, uint16(size))
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(l.ACEs)))
+	data = binary.LittleEndian.AppendUint16(data, 0)
+	return append(data, aces...), nil
+}
@@ -0,0 +1,120 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/network/ldap/schema"
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+)
+
+// LookupGUIDName returns the name of the extended right, validated write, property set, attribute or class
+// identified by a GUID of an object ACE.
+//
+// Parameters:
+//   - g: A pointer to the GUID to look up.
+//
+// Returns:
+//   - A string containing the display name of the GUID, such as "user-force-change-password", or an empty string
+//     if the GUID is unknown.
+func LookupGUIDName(g *guid.GUID) string {
+	if g == nil {
+		return ""
+	}
+	value := g.ToFormatD()
+	if name, ok := schema.GUIDToExtendedRightDisplayName[value]; ok {
+		return name
+	}
+	if name, ok := schema.GUIDToPropertySet[value]; ok {
+		return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, "PROPERTY_SET_")), "_", "-")
+	}
+	if name, ok := schema.GUIDToSchemaAttributeDisplayName[value]; ok {
+		return name
+	}
+	return ""
+}
+
+// ObjectTypeName returns the name of the object type of the ACE.
+//
+// Returns:
+//   - A string containing the display name of the object type, its GUID if the name is unknown, or an empty string
+//     if the ACE has no object type.
+func (a *ACE) ObjectTypeName() string {
+	return formatGUIDName(a.ObjectType)
+}
+
+// InheritedObjectTypeName returns the name of the inherited object type of the ACE.
+//
+// Returns:
+//   - A string containing the display name of the inherited object type, its GUID if the name is unknown, or an
+//     empty string if the ACE has no inherited object type.
+func (a *ACE) InheritedObjectTypeName() string {
+	return formatGUIDName(a.InheritedObjectType)
+}
+
+// formatGUIDName returns the display name of a GUID, or the GUID itself if it is unknown.
+func formatGUIDName(g *guid.GUID) string {
+	if g == nil {
+		return ""
+	}
+	if name := LookupGUIDName(g); name != "" {
+		return name
+	}
+	return g.ToFormatD()
+}
+
+// Describe prints a detailed description of the ACE.
+//
+// Parameters:
+//   - indent: An integer value specifying the indentation level for the output.
+func (a *ACE) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<ACE type 0x%02x>\n", indentPrompt, a.Type)
+	fmt.Printf("%s │ \x1b[93mFlags\x1b[0m: 0x%02x\n", indentPrompt, a.Flags)
+	fmt.Printf("%s │ \x1b[93mMask\x1b[0m: %s (0x%08x)\n", indentPrompt, a.Mask.String(), uint32(a.Mask))
+	if a.ObjectType != nil {
+		fmt.Printf("%s │ \x1b[93mObjectType\x1b[0m: %s\n", indentPrompt, a.ObjectTypeName())
+	}
+	if a.InheritedObjectType != nil {
+		fmt.Printf("%s │ \x1b[93mInheritedObjectType\x1b[0m: %s\n", indentPrompt, a.InheritedObjectTypeName())
+	}
+	if a.SID != nil {
+		if name := a.SID.Name(); name != "" {
+			fmt.Printf("%s │ \x1b[93mSID\x1b[0m: %s (%s)\n", indentPrompt, a.SID.String(), name)
+		} else {
+			fmt.Printf("%s │ \x1b[93mSID\x1b[0m: %s\n", indentPrompt, a.SID.String())
+		}
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
+
+// Describe prints a detailed description of the security descriptor.
+//
+// Parameters:
+//   - indent: An integer value specifying the indentation level for the output.
+func (sd *SecurityDescriptor) Describe(indent int) {
+	indentPrompt := strings.Repeat(" │ ", indent)
+
+	fmt.Printf("%s<SecurityDescriptor>\n", indentPrompt)
+	fmt.Printf("%s │ \x1b[93mControl\x1b[0m: 0x%04x\n", indentPrompt, sd.Control)
+	if sd.Owner != nil {
+		fmt.Printf("%s │ \x1b[93mOwner\x1b[0m: %s\n", indentPrompt, sd.Owner.String())
+	}
+	if sd.Group != nil {
+		fmt.Printf("%s │ \x1b[93mGroup\x1b[0m: %s\n", indentPrompt, sd.Group.String())
+	}
+	for _, acl := range []struct {
+		name string
+		acl  *ACL
+	}{{"SACL", sd.SACL}, {"DACL", sd.DACL}} {
+		if acl.acl == nil {
+			continue
+		}
+		fmt.Printf("%s │ \x1b[93m%s\x1b[0m: %d ACEs\n", indentPrompt, acl.name, len(acl.acl.ACEs))
+		for _, ace := range acl.acl.ACEs {
+			ace.Describe(indent + 1)
+		}
+	}
+	fmt.Printf("%s └───\n", indentPrompt)
+}
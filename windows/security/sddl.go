@@ -0,0 +1,528 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// SDDL aliases of the well-known SIDs
+// Src: https://learn.microsoft.com/en-us/windows/win32/secauthz/sid-strings
+var sddlSIDAliases = map[string]string{
+	"AA": "S-1-5-32-579",
+	"AC": "S-1-15-2-1",
+	"AN": "S-1-5-7",
+	"AO": "S-1-5-32-548",
+	"AS": "S-1-18-1",
+	"AU": "S-1-5-11",
+	"BA": "S-1-5-32-544",
+	"BG": "S-1-5-32-546",
+	"BO": "S-1-5-32-551",
+	"BU": "S-1-5-32-545",
+	"CD": "S-1-5-32-574",
+	"CG": "S-1-3-1",
+	"CO": "S-1-3-0",
+	"CY": "S-1-5-32-569",
+	"ED": "S-1-5-9",
+	"ER": "S-1-5-32-573",
+	"ES": "S-1-5-32-576",
+	"HA": "S-1-5-32-578",
+	"HI": "S-1-16-12288",
+	"IS": "S-1-5-32-568",
+	"IU": "S-1-5-4",
+	"LS": "S-1-5-19",
+	"LU": "S-1-5-32-559",
+	"LW": "S-1-16-4096",
+	"ME": "S-1-16-8192",
+	"MP": "S-1-16-8448",
+	"MS": "S-1-5-32-577",
+	"MU": "S-1-5-32-558",
+	"NO": "S-1-5-32-556",
+	"NS": "S-1-5-20",
+	"NU": "S-1-5-2",
+	"OW": "S-1-3-4",
+	"PO": "S-1-5-32-550",
+	"PS": "S-1-5-10",
+	"PU": "S-1-5-32-547",
+	"RA": "S-1-5-32-575",
+	"RC": "S-1-5-12",
+	"RD": "S-1-5-32-555",
+	"RE": "S-1-5-32-552",
+	"RM": "S-1-5-32-580",
+	"RU": "S-1-5-32-554",
+	"SI": "S-1-16-16384",
+	"SO": "S-1-5-32-549",
+	"SS": "S-1-18-2",
+	"SU": "S-1-5-6",
+	"SY": "S-1-5-18",
+	"WD": "S-1-1-0",
+	"WR": "S-1-5-33",
+}
+
+// SDDL aliases of the SIDs relative to the domain, by RID. The aliases relative to the forest root domain are
+// resolved against the same domain.
+var sddlDomainRelativeAliases = map[string]uint32{
+	"AP": 525,
+	"CA": 517,
+	"CN": 522,
+	"DA": 512,
+	"DC": 515,
+	"DD": 516,
+	"DG": 514,
+	"DU": 513,
+	"EA": 519,
+	"EK": 527,
+	"KA": 526,
+	"LA": 500,
+	"LG": 501,
+	"PA": 520,
+	"RO": 498,
+	"RS": 553,
+	"SA": 518,
+}
+
+// SDDL strings of the ACE types
+var sddlACETypes = map[string]uint8{
+	"A":  ACCESS_ALLOWED_ACE_TYPE,
+	"D":  ACCESS_DENIED_ACE_TYPE,
+	"AU": SYSTEM_AUDIT_ACE_TYPE,
+	"AL": SYSTEM_ALARM_ACE_TYPE,
+	"OA": ACCESS_ALLOWED_OBJECT_ACE_TYPE,
+	"OD": ACCESS_DENIED_OBJECT_ACE_TYPE,
+	"OU": SYSTEM_AUDIT_OBJECT_ACE_TYPE,
+	"OL": SYSTEM_ALARM_OBJECT_ACE_TYPE,
+	"XA": ACCESS_ALLOWED_CALLBACK_ACE_TYPE,
+	"XD": ACCESS_DENIED_CALLBACK_ACE_TYPE,
+	"ZA": ACCESS_ALLOWED_CALLBACK_OBJECT_ACE_TYPE,
+	"XU": SYSTEM_AUDIT_CALLBACK_ACE_TYPE,
+	"ML": SYSTEM_MANDATORY_LABEL_ACE_TYPE,
+	"RA": SYSTEM_RESOURCE_ATTRIBUTE_ACE_TYPE,
+	"SP": SYSTEM_SCOPED_POLICY_ID_ACE_TYPE,
+	"TL": SYSTEM_PROCESS_TRUST_LABEL_ACE_TYPE,
+	"FL": SYSTEM_ACCESS_FILTER_ACE_TYPE,
+}
+
+// SDDL strings of the ACE flags, in the order they are formatted
+var sddlACEFlags = []struct {
+	name string
+	flag uint8
+}{
+	{"OI", OBJECT_INHERIT_ACE},
+	{"CI", CONTAINER_INHERIT_ACE},
+	{"NP", NO_PROPAGATE_INHERIT_ACE},
+	{"IO", INHERIT_ONLY_ACE},
+	{"ID", INHERITED_ACE},
+	{"CR", CRITICAL_ACE_FLAG},
+	{"SA", SUCCESSFUL_ACCESS_ACE_FLAG},
+	{"FA", FAILED_ACCESS_ACE_FLAG},
+}
+
+// SDDL strings of the rights made of several bits, only formatted when matching the whole access mask
+var sddlCombinedRights = []struct {
+	name string
+	mask AccessMask
+}{
+	{"FA", FILE_ALL_ACCESS},
+	{"FR", FILE_GENERIC_READ},
+	{"FW", FILE_GENERIC_WRITE},
+	{"FX", FILE_GENERIC_EXECUTE},
+	{"KA", KEY_ALL_ACCESS},
+	{"KR", KEY_READ},
+	{"KW", KEY_WRITE},
+	{"KX", KEY_EXECUTE},
+}
+
+// SDDL strings of the single rights, in the order they are formatted
+var sddlRights = []struct {
+	name string
+	mask AccessMask
+}{
+	{"GA", GENERIC_ALL},
+	{"GR", GENERIC_READ},
+	{"GW", GENERIC_WRITE},
+	{"GX", GENERIC_EXECUTE},
+	{"CC", ADS_RIGHT_DS_CREATE_CHILD},
+	{"DC", ADS_RIGHT_DS_DELETE_CHILD},
+	{"LC", ADS_RIGHT_ACTRL_DS_LIST},
+	{"SW", ADS_RIGHT_DS_SELF},
+	{"RP", ADS_RIGHT_DS_READ_PROP},
+	{"WP", ADS_RIGHT_DS_WRITE_PROP},
+	{"DT", ADS_RIGHT_DS_DELETE_TREE},
+	{"LO", ADS_RIGHT_DS_LIST_OBJECT},
+	{"CR", ADS_RIGHT_DS_CONTROL_ACCESS},
+	{"SD", DELETE},
+	{"RC", READ_CONTROL},
+	{"WD", WRITE_DAC},
+	{"WO", WRITE_OWNER},
+	{"AS", ACCESS_SYSTEM_SECURITY},
+	{"MA", MAXIMUM_ALLOWED},
+}
+
+// SDDL strings of the rights of mandatory label ACEs
+var sddlMandatoryLabelRights = []struct {
+	name string
+	mask AccessMask
+}{
+	{"NW", SYSTEM_MANDATORY_LABEL_NO_WRITE_UP},
+	{"NR", SYSTEM_MANDATORY_LABEL_NO_READ_UP},
+	{"NX", SYSTEM_MANDATORY_LABEL_NO_EXECUTE_UP},
+}
+
+// ParseSDDL parses a security descriptor from its Security Descriptor Definition Language representation.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/2918391b-75b9-4eeb-83f0-7fdc04a5c6c9
+//
+// Parameters:
+//   - sddl: A string containing the security descriptor, such as "O:BAG:SYD:PAI(A;;FA;;;SY)".
+//   - domainSID: A pointer to the SID of the domain, used to resolve the aliases relative to the domain such as DA.
+//     It may be nil when the string holds no such alias.
+//
+// Returns:
+//   - A pointer to the parsed SecurityDescriptor.
+//   - An error if the string is not valid SDDL, or if it holds conditional expressions or resource attributes,
+//     which are not supported.
+func ParseSDDL(sddl string, domainSID *sid.SID) (*SecurityDescriptor, error) {
+	sd := NewSecurityDescriptor()
+	sddl = strings.TrimSpace(sddl)
+
+	for len(sddl) != 0 {
+		if len(sddl) < 2 || sddl[1] != ':' {
+			return nil, fmt.Errorf("invalid SDDL component %q", sddl)
+		}
+		component := sddl[0]
+		// Each component extends up to the next component, outside of the ACEs
+		end := len(sddl)
+		depth := 0
+		for k := 2; k < len(sddl); k++ {
+			if sddl[k] == '(' {
+				depth++
+			} else if sddl[k] == ')' {
+				depth--
+			} else if depth == 0 && k+1 < len(sddl) && sddl[k+1] == ':' && strings.IndexByte("OGDS", sddl[k]) != -1 {
+				end = k
+				break
+			}
+		}
+		value := sddl[2:end]
+		sddl = sddl[end:]
+
+		var err error
+		switch component {
+		case 'O':
+			sd.Owner, err = parseSDDLSID(value, domainSID)
+		case 'G':
+			sd.Group, err = parseSDDLSID(value, domainSID)
+		case 'D':
+			var flags uint16
+			flags, sd.DACL, err = parseSDDLACL(value, domainSID, SE_DACL_PROTECTED, SE_DACL_AUTO_INHERIT_REQ, SE_DACL_AUTO_INHERITED)
+			sd.Control |= SE_DACL_PRESENT | flags
+		case 'S':
+			var flags uint16
+			flags, sd.SACL, err = parseSDDLACL(value, domainSID, SE_SACL_PROTECTED, SE_SACL_AUTO_INHERIT_REQ, SE_SACL_AUTO_INHERITED)
+			sd.Control |= SE_SACL_PRESENT | flags
+		default:
+			err = fmt.Errorf("unknown component %c", component)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SDDL: %w", err)
+		}
+	}
+
+	return sd, nil
+}
+
+// ToSDDL returns the Security Descriptor Definition Language representation of the security descriptor.
+//
+// Parameters:
+//   - domainSID: A pointer to the SID of the domain, used to format the SIDs relative to the domain with their
+//     alias such as DA. If nil, these SIDs are formatted in full.
+//
+// Returns:
+//   - A string containing the security descriptor in SDDL.
+//   - An error if an ACE has a type without SDDL representation, or holds a conditional expression or a resource
+//     attribute, which are not supported.
+func (sd *SecurityDescriptor) ToSDDL(domainSID *sid.SID) (string, error) {
+	var builder strings.Builder
+
+	if sd.Owner != nil {
+		builder.WriteString("O:" + formatSDDLSID(sd.Owner, domainSID))
+	}
+	if sd.Group != nil {
+		builder.WriteString("G:" + formatSDDLSID(sd.Group, domainSID))
+	}
+	if sd.Control&SE_DACL_PRESENT != 0 || sd.DACL != nil {
+		acl, err := formatSDDLACL(sd.DACL, sd.Control, domainSID, SE_DACL_PROTECTED, SE_DACL_AUTO_INHERIT_REQ, SE_DACL_AUTO_INHERITED)
+		if err != nil {
+			return "", fmt.Errorf("error formatting DACL: %w", err)
+		}
+		builder.WriteString("D:" + acl)
+	}
+	if sd.Control&SE_SACL_PRESENT != 0 || sd.SACL != nil {
+		acl, err := formatSDDLACL(sd.SACL, sd.Control, domainSID, SE_SACL_PROTECTED, SE_SACL_AUTO_INHERIT_REQ, SE_SACL_AUTO_INHERITED)
+		if err != nil {
+			return "", fmt.Errorf("error formatting SACL: %w", err)
+		}
+		builder.WriteString("S:" + acl)
+	}
+
+	return builder.String(), nil
+}
+
+// parseSDDLSID parses a SID given as a string or as an SDDL alias.
+func parseSDDLSID(value string, domainSID *sid.SID) (*sid.SID, error) {
+	if aliasSID, ok := sddlSIDAliases[strings.ToUpper(value)]; ok {
+		return sid.FromString(aliasSID)
+	}
+	if rid, ok := sddlDomainRelativeAliases[strings.ToUpper(value)]; ok {
+		if domainSID == nil {
+			return nil, fmt.Errorf("alias %s requires the SID of the domain", value)
+		}
+		return sid.FromDomainSIDAndRID(domainSID, rid), nil
+	}
+	return sid.FromString(value)
+}
+
+// formatSDDLSID formats a SID with its SDDL alias if it has one.
+func formatSDDLSID(value *sid.SID, domainSID *sid.SID) string {
+	sidString := value.String()
+	for alias, aliasSID := range sddlSIDAliases {
+		if aliasSID == sidString {
+			return alias
+		}
+	}
+	if value.IsInDomain(domainSID) {
+		for alias, aliasRID := range sddlDomainRelativeAliases {
+			if aliasRID == value.GetRID() {
+				return alias
+			}
+		}
+	}
+	return sidString
+}
+
+// parseSDDLACL parses the flags and the ACEs of an ACL, returning a nil ACL for NO_ACCESS_CONTROL.
+func parseSDDLACL(value string, domainSID *sid.SID, protected, autoInheritReq, autoInherited uint16) (uint16, *ACL, error) {
+	flags := uint16(0)
+	acl := NewACL()
+
+	for len(value) != 0 && value[0] != '(' {
+		switch {
+		case strings.HasPrefix(value, "NO_ACCESS_CONTROL"):
+			acl = nil
+			value = value[len("NO_ACCESS_CONTROL"):]
+		case strings.HasPrefix(value, "AR"):
+			flags |= autoInheritReq
+			value = value[2:]
+		case strings.HasPrefix(value, "AI"):
+			flags |= autoInherited
+			value = value[2:]
+		case strings.HasPrefix(value, "P"):
+			flags |= protected
+			value = value[1:]
+		default:
+			return 0, nil, fmt.Errorf("invalid ACL flags %q", value)
+		}
+	}
+
+	for len(value) != 0 {
+		end := strings.IndexByte(value, ')')
+		if value[0] != '(' || end == -1 {
+			return 0, nil, fmt.Errorf("invalid ACE %q", value)
+		}
+		if acl == nil {
+			return 0, nil, fmt.Errorf("ACE in an ACL without access control")
+		}
+		ace, err := parseSDDLACE(value[1:end], domainSID)
+		if err != nil {
+			return 0, nil, err
+		}
+		acl.AddACE(ace)
+		value = value[end+1:]
+	}
+
+	return flags, acl, nil
+}
+
+// formatSDDLACL formats the flags and the ACEs of an ACL.
+func formatSDDLACL(acl *ACL, control uint16, domainSID *sid.SID, protected, autoInheritReq, autoInherited uint16) (string, error) {
+	var builder strings.Builder
+	if control&protected != 0 {
+		builder.WriteString("P")
+	}
+	if control&autoInheritReq != 0 {
+		builder.WriteString("AR")
+	}
+	if control&autoInherited != 0 {
+		builder.WriteString("AI")
+	}
+	if acl == nil {
+		builder.WriteString("NO_ACCESS_CONTROL")
+		return builder.String(), nil
+	}
+
+	for k, ace := range acl.ACEs {
+		aceString, err := formatSDDLACE(ace, domainSID)
+		if err != nil {
+			return "", fmt.Errorf("error formatting ACE %d: %w", k, err)
+		}
+		builder.WriteString("(" + aceString + ")")
+	}
+	return builder.String(), nil
+}
+
+// parseSDDLACE parses an ACE string, without its parentheses.
+func parseSDDLACE(value string, domainSID *sid.SID) (*ACE, error) {
+	fields := strings.Split(value, ";")
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("invalid ACE %q: conditional expressions and resource attributes are not supported", value)
+	}
+
+	ace := &ACE{}
+	aceType, ok := sddlACETypes[strings.ToUpper(fields[0])]
+	if !ok {
+		return nil, fmt.Errorf("invalid ACE type %q", fields[0])
+	}
+	ace.Type = aceType
+
+	flags := strings.ToUpper(fields[1])
+	for len(flags) != 0 {
+		found := false
+		for _, flag := range sddlACEFlags {
+			if strings.HasPrefix(flags, flag.name) {
+				ace.Flags |= flag.flag
+				flags = flags[len(flag.name):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid ACE flags %q", fields[1])
+		}
+	}
+
+	mask, err := parseSDDLRights(fields[2], ace.Type == SYSTEM_MANDATORY_LABEL_ACE_TYPE)
+	if err != nil {
+		return nil, err
+	}
+	ace.Mask = mask
+
+	if fields[3] != "" || fields[4] != "" {
+		if !ace.IsObjectACE() {
+			return nil, fmt.Errorf("ACE of type %s cannot have object types", fields[0])
+		}
+		if fields[3] != "" {
+			if ace.ObjectType, err = guid.FromString(fields[3]); err != nil {
+				return nil, fmt.Errorf("invalid object type %q: %w", fields[3], err)
+			}
+		}
+		if fields[4] != "" {
+			if ace.InheritedObjectType, err = guid.FromString(fields[4]); err != nil {
+				return nil, fmt.Errorf("invalid inherited object type %q: %w", fields[4], err)
+			}
+		}
+	}
+
+	if ace.SID, err = parseSDDLSID(fields[5], domainSID); err != nil {
+		return nil, err
+	}
+	return ace, nil
+}
+
+// formatSDDLACE formats an ACE string, without its parentheses.
+func formatSDDLACE(ace *ACE, domainSID *sid.SID) (string, error) {
+	aceType := ""
+	for name, value := range sddlACETypes {
+		if value == ace.Type {
+			aceType = name
+		}
+	}
+	if aceType == "" {
+		return "", fmt.Errorf("ACE type 0x%02x has no SDDL representation", ace.Type)
+	}
+	if len(ace.ApplicationData) != 0 || ace.SID == nil {
+		return "", fmt.Errorf("conditional expressions and resource attributes are not supported")
+	}
+
+	flags := ""
+	for _, flag := range sddlACEFlags {
+		if ace.Flags&flag.flag != 0 {
+			flags += flag.name
+		}
+	}
+
+	objectType, inheritedObjectType := "", ""
+	if ace.ObjectType != nil {
+		objectType = ace.ObjectType.ToFormatD()
+	}
+	if ace.InheritedObjectType != nil {
+		inheritedObjectType = ace.InheritedObjectType.ToFormatD()
+	}
+
+	rights := formatSDDLRights(ace.Mask, ace.Type == SYSTEM_MANDATORY_LABEL_ACE_TYPE)
+	return strings.Join([]string{aceType, flags, rights, objectType, inheritedObjectType, formatSDDLSID(ace.SID, domainSID)}, ";"), nil
+}
+
+// parseSDDLRights parses the rights of an ACE, given as a number or as a sequence of SDDL right strings.
+func parseSDDLRights(value string, mandatoryLabel bool) (AccessMask, error) {
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") || (len(value) != 0 && value[0] >= '0' && value[0] <= '9') {
+		mask, err := strconv.ParseUint(value, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid rights %q", value)
+		}
+		return AccessMask(mask), nil
+	}
+
+	rights := sddlRights
+	if mandatoryLabel {
+		rights = sddlMandatoryLabelRights
+	}
+	mask := AccessMask(0)
+	value = strings.ToUpper(value)
+	for k := 0; k+2 <= len(value); k += 2 {
+		found := false
+		for _, right := range append(rights, sddlCombinedRights...) {
+			if value[k:k+2] == right.name {
+				mask |= right.mask
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid right %q", value[k:k+2])
+		}
+	}
+	if len(value)%2 != 0 {
+		return 0, fmt.Errorf("invalid rights %q", value)
+	}
+	return mask, nil
+}
+
+// formatSDDLRights formats the rights of an ACE with SDDL right strings, or in hexadecimal when some bits have
+// no right string.
+func formatSDDLRights(mask AccessMask, mandatoryLabel bool) string {
+	rights := sddlRights
+	if mandatoryLabel {
+		rights = sddlMandatoryLabelRights
+	} else {
+		for _, right := range sddlCombinedRights {
+			if mask == right.mask {
+				return right.name
+			}
+		}
+	}
+
+	formatted := ""
+	remaining := mask
+	for _, right := range rights {
+		if remaining&right.mask != 0 {
+			formatted += right.name
+			remaining &^= right.mask
+		}
+	}
+	if remaining != 0 {
+		return fmt.Sprintf("0x%x", uint32(mask))
+	}
+	return formatted
+}
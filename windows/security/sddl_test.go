@@ -0,0 +1,111 @@
+package security
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+func TestParseSDDLBinary(t *testing.T) {
+	// Same security descriptor as written by Windows in msDS-AllowedToActOnBehalfOfOtherIdentity
+	expected := "010004801400000000000000000000002400000001020000000000052000000020020000" +
+		"02002c000100000000002400ff010f00010500000000000515000000010000000200000003000000" + "50040000"
+
+	sd, err := ParseSDDL("O:BAD:(A;;0xf01ff;;;S-1-5-21-1-2-3-1104)", nil)
+	if err != nil {
+		t.Fatalf("ParseSDDL() error = %v", err)
+	}
+	data, err := sd.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes() error = %v", err)
+	}
+	if hex.EncodeToString(data) != expected {
+		t.Errorf("ToBytes() = %x; want %s", data, expected)
+	}
+}
+
+func TestSDDLRoundTrip(t *testing.T) {
+	domainSID, _ := sid.FromString("S-1-5-21-1-2-3")
+
+	tests := []string{
+		"O:BAG:SYD:PAI(A;OICI;FA;;;SY)(A;OICIID;FA;;;BA)(A;;0x1200a9;;;BU)",
+		"O:DAG:DAD:AI(A;;CCDCLCSWRPWPDTLOCRSDRCWDWO;;;DA)(OA;;CR;00299570-246d-11d0-a768-00aa006e0529;;S-1-5-21-1-2-3-1104)" +
+			"(OA;CIIOID;RP;4c164200-20c0-11d0-a768-00aa006e0529;bf967aba-0de6-11d0-a285-00aa003049e2;RU)(OD;;WP;;bf967aba-0de6-11d0-a285-00aa003049e2;WD)" +
+			"S:AI(AU;CISAFA;WDWO;;;WD)(OU;CIIOIDSA;WP;f30e3bbe-9ff0-11d1-b603-0000f80367c1;bf967aa5-0de6-11d0-a285-00aa003049e2;WD)",
+		"D:NO_ACCESS_CONTROL",
+		"D:",
+		"S:(ML;;NWNR;;;HI)",
+		"O:S-1-5-21-4-5-6-500D:(D;;GA;;;AN)(A;;GRGW;;;EA)",
+	}
+	for _, test := range tests {
+		sd, err := ParseSDDL(test, domainSID)
+		if err != nil {
+			t.Fatalf("ParseSDDL(%s) error = %v", test, err)
+		}
+		data, err := sd.ToBytes()
+		if err != nil {
+			t.Fatalf("ToBytes(%s) error = %v", test, err)
+		}
+		parsed := &SecurityDescriptor{}
+		if err := parsed.FromBytes(data); err != nil {
+			t.Fatalf("FromBytes(%s) error = %v", test, err)
+		}
+		reserialized, _ := parsed.ToBytes()
+		if !bytes.Equal(reserialized, data) {
+			t.Errorf("Binary round trip of %s gave %x; want %x", test, reserialized, data)
+		}
+
+		sddl, err := parsed.ToSDDL(domainSID)
+		if err != nil {
+			t.Fatalf("ToSDDL(%s) error = %v", test, err)
+		}
+		if sddl != test {
+			t.Errorf("ToSDDL() = %s; want %s", sddl, test)
+		}
+	}
+}
+
+func TestSDDLDomainRelativeAliases(t *testing.T) {
+	domainSID, _ := sid.FromString("S-1-5-21-1-2-3")
+
+	sd, err := ParseSDDL("O:DAD:(A;;GA;;;EA)", domainSID)
+	if err != nil {
+		t.Fatalf("ParseSDDL() error = %v", err)
+	}
+	if sd.Owner.String() != "S-1-5-21-1-2-3-512" || sd.DACL.ACEs[0].SID.String() != "S-1-5-21-1-2-3-519" {
+		t.Errorf("Unexpected SIDs %s and %s", sd.Owner, sd.DACL.ACEs[0].SID)
+	}
+
+	sddl, err := sd.ToSDDL(nil)
+	if err != nil {
+		t.Fatalf("ToSDDL() error = %v", err)
+	}
+	if sddl != "O:S-1-5-21-1-2-3-512D:(A;;GA;;;S-1-5-21-1-2-3-519)" {
+		t.Errorf("ToSDDL() without domain SID = %s", sddl)
+	}
+
+	if _, err := ParseSDDL("O:DA", nil); err == nil {
+		t.Error("ParseSDDL() of a domain relative alias without domain SID should fail")
+	}
+}
+
+func TestParseSDDLErrors(t *testing.T) {
+	tests := []string{
+		"X:BA",
+		"O:NOTASID",
+		"D:(A;;FA;;;SY",
+		"D:(Q;;FA;;;SY)",
+		"D:(A;ZZ;FA;;;SY)",
+		"D:(A;;QQ;;;SY)",
+		"D:(A;;FA;00299570-246d-11d0-a768-00aa006e0529;;SY)",
+		"D:(XA;;FX;;;WD;(@User.Title==\"PM\"))",
+		"D:NO_ACCESS_CONTROL(A;;FA;;;SY)",
+	}
+	for _, test := range tests {
+		if _, err := ParseSDDL(test, nil); err == nil {
+			t.Errorf("ParseSDDL(%s) should fail", test)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package security
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+// Security descriptor control flags
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/7d4dac05-9cef-4563-a058-f108abecce1d
+const (
+	SE_OWNER_DEFAULTED       uint16 = 0x0001
+	SE_GROUP_DEFAULTED       uint16 = 0x0002
+	SE_DACL_PRESENT          uint16 = 0x0004
+	SE_DACL_DEFAULTED        uint16 = 0x0008
+	SE_SACL_PRESENT          uint16 = 0x0010
+	SE_SACL_DEFAULTED        uint16 = 0x0020
+	SE_DACL_TRUSTED          uint16 = 0x0040
+	SE_SERVER_SECURITY       uint16 = 0x0080
+	SE_DACL_AUTO_INHERIT_REQ uint16 = 0x0100
+	SE_SACL_AUTO_INHERIT_REQ uint16 = 0x0200
+	SE_DACL_AUTO_INHERITED   uint16 = 0x0400
+	SE_SACL_AUTO_INHERITED   uint16 = 0x0800
+	SE_DACL_PROTECTED        uint16 = 0x1000
+	SE_SACL_PROTECTED        uint16 = 0x2000
+	SE_RM_CONTROL_VALID      uint16 = 0x4000
+	SE_SELF_RELATIVE         uint16 = 0x8000
+)
+
+// Security information flags, selecting the parts of a security descriptor to read or write
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/23e75ca3-98fd-4396-84e5-86cd9d40d343
+const (
+	OWNER_SECURITY_INFORMATION uint32 = 0x00000001
+	GROUP_SECURITY_INFORMATION uint32 = 0x00000002
+	DACL_SECURITY_INFORMATION  uint32 = 0x00000004
+	SACL_SECURITY_INFORMATION  uint32 = 0x00000008
+	LABEL_SECURITY_INFORMATION uint32 = 0x00000010
+)
+
+// Size of the header of a self-relative security descriptor
+const SECURITY_DESCRIPTOR_HEADER_SIZE = 20
+
+// SecurityDescriptor represents a self-relative security descriptor, as stored in the nTSecurityDescriptor and
+// msDS-AllowedToActOnBehalfOfOtherIdentity attributes or in the security of files.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/2918391b-75b9-4eeb-83f0-7fdc04a5c6c9
+//
+// Fields:
+//   - Revision: The revision of the security descriptor, always 1.
+//   - Sbz1: The resource manager control bits, valid when SE_RM_CONTROL_VALID is set.
+//   - Control: The control flags of the security descriptor.
+//   - Owner: The SID of the owner, or nil.
+//   - Group: The SID of the primary group, or nil.
+//   - SACL: The system ACL, or nil.
+//   - DACL: The discretionary ACL, or nil. A nil DACL with SE_DACL_PRESENT set grants full access to everyone.
+type SecurityDescriptor struct {
+	Revision uint8
+	Sbz1     uint8
+	Control  uint16
+	Owner    *sid.SID
+	Group    *sid.SID
+	SACL     *ACL
+	DACL     *ACL
+}
+
+// NewSecurityDescriptor creates an empty self-relative security descriptor.
+//
+// Returns:
+//   - A pointer to the SecurityDescriptor, without owner, group or ACL.
+func NewSecurityDescriptor() *SecurityDescriptor {
+	return &SecurityDescriptor{Revision: 1, Control: SE_SELF_RELATIVE}
+}
+
+// SetDACL sets the discretionary ACL of the security descriptor and marks it as present.
+//
+// Parameters:
+//   - dacl: A pointer to the ACL, or nil for a NULL DACL.
+func (sd *SecurityDescriptor) SetDACL(dacl *ACL) {
+	sd.DACL = dacl
+	sd.Control |= SE_DACL_PRESENT
+}
+
+// SetSACL sets the system ACL of the security descriptor and marks it as present.
+//
+// Parameters:
+//   - sacl: A pointer to the ACL, or nil for a NULL SACL.
+func (sd *SecurityDescriptor) SetSACL(sacl *ACL) {
+	sd.SACL = sacl
+	sd.Control |= SE_SACL_PRESENT
+}
+
+// FromBytes parses a self-relative security descriptor from its binary representation.
+//
+// Parameters:
+//   - data: A byte slice containing the security descriptor.
+//
+// Returns:
+//   - An error if the security descriptor is not self-relative or if one of its components is malformed.
+func (sd *SecurityDescriptor) FromBytes(data []byte) error {
+	if len(data) < SECURITY_DESCRIPTOR_HEADER_SIZE {
+		return fmt.Errorf("security descriptor is too short (%d bytes)", len(data))
+	}
+	sd.Revision = data[0]
+	sd.Sbz1 = data[1]
+	sd.Control = binary.LittleEndian.Uint16(data[2:4])
+	if sd.Revision != 1 {
+		return fmt.Errorf("unsupported security descriptor revision %d", sd.Revision)
+	}
+	if sd.Control&SE_SELF_RELATIVE == 0 {
+		return fmt.Errorf("security descriptor is not self-relative")
+	}
+
+	offsetOwner := int(binary.LittleEndian.Uint32(data[4:8]))
+	offsetGroup := int(binary.LittleEndian.Uint32(data[8:12]))
+	offsetSACL := int(binary.LittleEndian.Uint32(data[12:16]))
+	offsetDACL := int(binary.LittleEndian.Uint32(data[16:20]))
+	// An ACL is only meaningful when flagged as present, and a present ACL without offset is a NULL ACL
+	if sd.Control&SE_SACL_PRESENT == 0 {
+		offsetSACL = 0
+	}
+	if sd.Control&SE_DACL_PRESENT == 0 {
+		offsetDACL = 0
+	}
+
+	var err error
+	if sd.Owner, err = parseSIDAt(data, offsetOwner); err != nil {
+		return fmt.Errorf("error parsing owner: %w", err)
+	}
+	if sd.Group, err = parseSIDAt(data, offsetGroup); err != nil {
+		return fmt.Errorf("error parsing group: %w", err)
+	}
+	if sd.SACL, err = parseACLAt(data, offsetSACL); err != nil {
+		return fmt.Errorf("error parsing SACL: %w", err)
+	}
+	if sd.DACL, err = parseACLAt(data, offsetDACL); err != nil {
+		return fmt.Errorf("error parsing DACL: %w", err)
+	}
+
+	return nil
+}
+
+// ToBytes returns the binary representation of the security descriptor. The owner, the group, the SACL and
+// the DACL follow the header in this order.
+//
+// Returns:
+//   - A byte slice containing the self-relative security descriptor.
+//   - An error if one of the ACLs cannot be serialized.
+func (sd *SecurityDescriptor) ToBytes() ([]byte, error) {
+	control := sd.Control | SE_SELF_RELATIVE
+	if sd.SACL != nil {
+		control |= SE_SACL_PRESENT
+	}
+	if sd.DACL != nil {
+		control |= SE_DACL_PRESENT
+	}
+
+	body := []byte{}
+	offsets := make([]uint32, 4)
+	if sd.Owner != nil {
+		offsets[0] = uint32(SECURITY_DESCRIPTOR_HEADER_SIZE + len(body))
+		body = append(body, sd.Owner.ToBytes()...)
+	}
+	if sd.Group != nil {
+		offsets[1] = uint32(SECURITY_DESCRIPTOR_HEADER_SIZE + len(body))
+		body = append(body, sd.Group.ToBytes()...)
+	}
+	for k, acl := range []*ACL{sd.SACL, sd.DACL} {
+		if acl == nil {
+			continue
+		}
+		data, err := acl.ToBytes()
+		if err != nil {
+			return nil, err
+		}
+		offsets[2+k] = uint32(SECURITY_DESCRIPTOR_HEADER_SIZE + len(body))
+		body = append(body, data...)
+	}
+
+	revision := sd.Revision
+	if revision == 0 {
+		revision = 1
+	}
+	data := []byte{revision, sd.Sbz1}
+	data = binary.LittleEndian.AppendUint16(data, control)
+	for _, offset := range offsets {
+		data = binary.LittleEndian.AppendUint32(data, offset)
+	}
+	return append(data, body...), nil
+}
+
+// parseSIDAt parses the SID at an offset of a self-relative security descriptor, returning nil for offset 0.
+func parseSIDAt(data []byte, offset int) (*sid.SID, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	if offset < SECURITY_DESCRIPTOR_HEADER_SIZE || offset >= len(data) {
+		return nil, fmt.Errorf("offset %d is out of bounds", offset)
+	}
+	return sid.FromBytes(data[offset:])
+}
+
+// parseACLAt parses the ACL at an offset of a self-relative security descriptor, returning nil for offset 0.
+func parseACLAt(data []byte, offset int) (*ACL, error) {
+	if offset == 0 {
+		return nil, nil
+	}
+	if offset < SECURITY_DESCRIPTOR_HEADER_SIZE || offset >= len(data) {
+		return nil, fmt.Errorf("offset %d is out of bounds", offset)
+	}
+	acl := &ACL{}
+	if err := acl.FromBytes(data[offset:]); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
@@ -0,0 +1,126 @@
+package security
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/TheManticoreProject/Manticore/windows/guid"
+	"github.com/TheManticoreProject/Manticore/windows/sid"
+)
+
+func TestSecurityDescriptorFromBytes(t *testing.T) {
+	// Security descriptor of msDS-AllowedToActOnBehalfOfOtherIdentity, as written by Windows
+	data, _ := hex.DecodeString("010004801400000000000000000000002400000001020000000000052000000020020000" +
+		"02002c000100000000002400ff010f00010500000000000515000000010000000200000003000000" + "50040000")
+
+	sd := &SecurityDescriptor{}
+	if err := sd.FromBytes(data); err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+	if sd.Owner.String() != "S-1-5-32-544" || sd.Group != nil || sd.SACL != nil {
+		t.Errorf("Unexpected owner %v, group %v or SACL %v", sd.Owner, sd.Group, sd.SACL)
+	}
+	if sd.DACL == nil || len(sd.DACL.ACEs) != 1 {
+		t.Fatalf("Expected a DACL with one ACE, got %v", sd.DACL)
+	}
+	ace := sd.DACL.ACEs[0]
+	if ace.Type != ACCESS_ALLOWED_ACE_TYPE || ace.Mask != ADS_RIGHT_GENERIC_ALL || ace.SID.String() != "S-1-5-21-1-2-3-1104" {
+		t.Errorf("Unexpected ACE type %d, mask %s, SID %s", ace.Type, ace.Mask, ace.SID)
+	}
+
+	serialized, err := sd.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes() error = %v", err)
+	}
+	if !bytes.Equal(serialized, data) {
+		t.Errorf("ToBytes() = %x; want %x", serialized, data)
+	}
+
+	for _, truncated := range [][]byte{data[:10], data[:40], data[:60]} {
+		if err := (&SecurityDescriptor{}).FromBytes(truncated); err == nil {
+			t.Errorf("FromBytes() with %d bytes should fail", len(truncated))
+		}
+	}
+}
+
+func TestSecurityDescriptorObjectACEs(t *testing.T) {
+	trustee, _ := sid.FromString("S-1-5-21-1-2-3-1104")
+	forceChangePassword, _ := guid.FromString("00299570-246d-11d0-a768-00aa006e0529")
+	userClass, _ := guid.FromString("bf967aba-0de6-11d0-a285-00aa003049e2")
+
+	sacl := NewACL()
+	sacl.AddACE(&ACE{Type: SYSTEM_AUDIT_ACE_TYPE, Flags: SUCCESSFUL_ACCESS_ACE_FLAG | FAILED_ACCESS_ACE_FLAG, Mask: WRITE_DAC, SID: trustee})
+	dacl := NewACL()
+	dacl.AddACE(&ACE{Type: ACCESS_ALLOWED_OBJECT_ACE_TYPE, Flags: CONTAINER_INHERIT_ACE, Mask: ADS_RIGHT_DS_CONTROL_ACCESS, ObjectType: forceChangePassword, InheritedObjectType: userClass, SID: trustee})
+	dacl.AddACE(&ACE{Type: ACCESS_DENIED_OBJECT_ACE_TYPE, Mask: ADS_RIGHT_DS_WRITE_PROP, InheritedObjectType: userClass, SID: trustee})
+	dacl.AddACE(&ACE{Type: ACCESS_ALLOWED_CALLBACK_ACE_TYPE, Mask: READ_CONTROL, SID: trustee, ApplicationData: []byte("artx\x00\x00\x00\x00")})
+	if dacl.Revision != ACL_REVISION_DS {
+		t.Errorf("Expected the DACL revision to be raised to %d, got %d", ACL_REVISION_DS, dacl.Revision)
+	}
+
+	sd := NewSecurityDescriptor()
+	sd.Owner = trustee
+	sd.Group = trustee
+	sd.SetSACL(sacl)
+	sd.SetDACL(dacl)
+	data, err := sd.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes() error = %v", err)
+	}
+
+	parsed := &SecurityDescriptor{}
+	if err := parsed.FromBytes(data); err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+	reserialized, err := parsed.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes() error = %v", err)
+	}
+	if !bytes.Equal(reserialized, data) {
+		t.Errorf("Round trip gave %x; want %x", reserialized, data)
+	}
+
+	aces := parsed.DACL.ACEs
+	if len(aces) != 3 || len(parsed.SACL.ACEs) != 1 {
+		t.Fatalf("Expected 3 ACEs in the DACL and 1 in the SACL, got %d and %d", len(aces), len(parsed.SACL.ACEs))
+	}
+	if aces[0].ObjectTypeName() != "user-force-change-password" || aces[0].InheritedObjectTypeName() != "user" {
+		t.Errorf("Unexpected object type names %s and %s", aces[0].ObjectTypeName(), aces[0].InheritedObjectTypeName())
+	}
+	if aces[1].ObjectType != nil || !aces[1].InheritedObjectType.Equal(userClass) || !aces[1].IsDenied() {
+		t.Errorf("Unexpected object types of the denied ACE")
+	}
+	if string(aces[2].ApplicationData) != "artx\x00\x00\x00\x00" {
+		t.Errorf("Unexpected application data %x", aces[2].ApplicationData)
+	}
+}
+
+func TestLookupGUIDName(t *testing.T) {
+	tests := map[string]string{
+		"1131f6ad-9c07-11d1-f79f-00c04fc2dcd2": "ds-replication-get-changes-all",
+		"4c164200-20c0-11d0-a768-00aa006e0529": "account-restrictions",
+		"5b47d60f-6090-40b2-9f37-2a4de88f3063": "ms-ds-key-credential-link",
+		"01234567-89ab-cdef-0123-456789abcdef": "",
+	}
+	for value, expected := range tests {
+		g, _ := guid.FromString(value)
+		if name := LookupGUIDName(g); name != expected {
+			t.Errorf("LookupGUIDName(%s) = %q; want %q", value, name, expected)
+		}
+	}
+}
+
+func TestAccessMaskString(t *testing.T) {
+	tests := map[AccessMask]string{
+		ADS_RIGHT_GENERIC_ALL:                            "GenericAll",
+		WRITE_DAC | WRITE_OWNER:                          "WriteOwner|WriteDacl",
+		ADS_RIGHT_DS_CONTROL_ACCESS | 0x00000200:         "ControlAccess|0x00000200",
+		ADS_RIGHT_DS_WRITE_PROP | ADS_RIGHT_DS_READ_PROP: "WriteProperty|ReadProperty",
+	}
+	for mask, expected := range tests {
+		if mask.String() != expected {
+			t.Errorf("AccessMask(0x%08x).String() = %s; want %s", uint32(mask), mask.String(), expected)
+		}
+	}
+}
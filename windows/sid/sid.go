@@ -0,0 +1,311 @@
+package sid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Identifier authorities of the SIDs
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/c6ce4275-3d90-4890-ab3a-514745e4637e
+const (
+	SECURITY_NULL_SID_AUTHORITY         uint64 = 0
+	SECURITY_WORLD_SID_AUTHORITY        uint64 = 1
+	SECURITY_LOCAL_SID_AUTHORITY        uint64 = 2
+	SECURITY_CREATOR_SID_AUTHORITY      uint64 = 3
+	SECURITY_NON_UNIQUE_AUTHORITY       uint64 = 4
+	SECURITY_NT_AUTHORITY               uint64 = 5
+	SECURITY_RESOURCE_MANAGER_AUTHORITY uint64 = 9
+	SECURITY_APP_PACKAGE_AUTHORITY      uint64 = 15
+	SECURITY_MANDATORY_LABEL_AUTHORITY  uint64 = 16
+	SECURITY_AUTHENTICATION_AUTHORITY   uint64 = 18
+)
+
+// First sub authority of the SIDs of the Windows domains and of the local accounts of the machines (S-1-5-21)
+const SECURITY_NT_NON_UNIQUE uint32 = 21
+
+// First sub authority of the SIDs of the BUILTIN domain (S-1-5-32)
+const SECURITY_BUILTIN_DOMAIN_RID uint32 = 32
+
+// Maximum number of sub authorities of a SID
+const SID_MAX_SUB_AUTHORITIES = 15
+
+// SID represents a security identifier.
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/f992ad60-0fe4-4b87-9fed-beb478836861
+//
+// Fields:
+//   - Revision: The revision of the SID, always 1.
+//   - IdentifierAuthority: The 48-bit authority that issued the SID.
+//   - SubAuthorities: The sub authorities of the SID, the last one being the relative identifier.
+type SID struct {
+	Revision            uint8
+	IdentifierAuthority uint64
+	SubAuthorities      []uint32
+}
+
+// FromString parses a SID from its string representation.
+//
+// Parameters:
+//   - data: A string containing the SID in the format S-1-<IdentifierAuthority>-<SubAuthority>...
+//
+// Returns:
+//   - A pointer to the parsed SID.
+//   - An error if the string is not a valid SID.
+func FromString(data string) (*SID, error) {
+	parts := strings.Split(strings.TrimSpace(data), "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") {
+		return nil, fmt.Errorf("invalid SID %s", data)
+	}
+	if len(parts)-3 > SID_MAX_SUB_AUTHORITIES {
+		return nil, fmt.Errorf("invalid SID %s: too many sub authorities", data)
+	}
+
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil || revision != 1 {
+		return nil, fmt.Errorf("invalid SID %s: unsupported revision", data)
+	}
+	// The identifier authority may also be given in hexadecimal when it does not fit on 32 bits
+	identifierAuthority, err := strconv.ParseUint(parts[2], 0, 48)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SID %s: invalid identifier authority", data)
+	}
+
+	sid := &SID{Revision: uint8(revision), IdentifierAuthority: identifierAuthority, SubAuthorities: []uint32{}}
+	for _, part := range parts[3:] {
+		subAuthority, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SID %s: invalid sub authority %s", data, part)
+		}
+		sid.SubAuthorities = append(sid.SubAuthorities, uint32(subAuthority))
+	}
+
+	return sid, nil
+}
+
+// FromBytes parses a SID from its binary representation, as stored in the objectSid attribute.
+//
+// Parameters:
+//   - data: A byte slice starting with the SID. Bytes after the SID are ignored.
+//
+// Returns:
+//   - A pointer to the parsed SID.
+//   - An error if the data is too short or the revision is not supported.
+func FromBytes(data []byte) (*SID, error) {
+	sid := &SID{}
+	if err := sid.FromBytes(data); err != nil {
+		return nil, err
+	}
+	return sid, nil
+}
+
+// FromDomainSIDAndRID builds the SID of an account of a domain from the SID of the domain and the relative
+// identifier of the account.
+//
+// Parameters:
+//   - domainSID: A pointer to the SID of the domain, such as S-1-5-21-1-2-3 or S-1-5-32 for the BUILTIN domain.
+//   - RID: The relative identifier of the account in the domain.
+//
+// Returns:
+//   - A pointer to a new SID made of the domain SID followed by the RID.
+func FromDomainSIDAndRID(domainSID *SID, RID uint32) *SID {
+	subAuthorities := make([]uint32, 0, len(domainSID.SubAuthorities)+1)
+	subAuthorities = append(subAuthorities, domainSID.SubAuthorities...)
+	return &SID{
+		Revision:            domainSID.Revision,
+		IdentifierAuthority: domainSID.IdentifierAuthority,
+		SubAuthorities:      append(subAuthorities, RID),
+	}
+}
+
+// FromBytes parses a SID from its binary representation.
+//
+// Parameters:
+//   - data: A byte slice starting with the SID. Bytes after the SID are ignored.
+//
+// Returns:
+//   - An error if the data is too short or the revision is not supported.
+func (s *SID) FromBytes(data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("SID is too short (%d bytes)", len(data))
+	}
+	if data[0] != 1 {
+		return fmt.Errorf("unsupported SID revision %d", data[0])
+	}
+	subAuthorityCount := int(data[1])
+	if subAuthorityCount > SID_MAX_SUB_AUTHORITIES {
+		return fmt.Errorf("SID has too many sub authorities (%d)", subAuthorityCount)
+	}
+	if len(data) < 8+4*subAuthorityCount {
+		return fmt.Errorf("SID is too short for %d sub authorities (%d bytes)", subAuthorityCount, len(data))
+	}
+
+	s.Revision = data[0]
+	s.IdentifierAuthority = 0
+	for k := 0; k < 6; k++ {
+		s.IdentifierAuthority = s.IdentifierAuthority<<8 | uint64(data[2+k])
+	}
+	s.SubAuthorities = make([]uint32, subAuthorityCount)
+	for k := range s.SubAuthorities {
+		s.SubAuthorities[k] = binary.LittleEndian.Uint32(data[8+4*k:])
+	}
+
+	return nil
+}
+
+// ToBytes returns the binary representation of the SID.
+//
+// Returns:
+//   - A byte slice containing the SID.
+func (s *SID) ToBytes() []byte {
+	data := make([]byte, 8, s.Size())
+	data[0] = s.Revision
+	data[1] = uint8(len(s.SubAuthorities))
+	for k := 0; k < 6; k++ {
+		data[2+k] = byte(s.IdentifierAuthority >> (8 * (5 - k)))
+	}
+	for _, subAuthority := range s.SubAuthorities {
+		data = binary.LittleEndian.AppendUint32(data, subAuthority)
+	}
+	return data
+}
+
+// Size returns the size of the binary representation of the SID.
+//
+// Returns:
+//   - The size in bytes of the SID.
+func (s *SID) Size() int {
+	return 8 + 4*len(s.SubAuthorities)
+}
+
+// String returns the string representation of the SID.
+//
+// Returns:
+//   - A string containing the SID in the format S-1-<IdentifierAuthority>-<SubAuthority>...
+func (s *SID) String() string {
+	var builder strings.Builder
+	if s.IdentifierAuthority >= 1<<32 {
+		fmt.Fprintf(&builder, "S-%d-0x%012X", s.Revision, s.IdentifierAuthority)
+	} else {
+		fmt.Fprintf(&builder, "S-%d-%d", s.Revision, s.IdentifierAuthority)
+	}
+	for _, subAuthority := range s.SubAuthorities {
+		fmt.Fprintf(&builder, "-%d", subAuthority)
+	}
+	return builder.String()
+}
+
+// Equal checks if two SIDs are equal.
+//
+// Parameters:
+//   - other: A pointer to the SID to compare with.
+//
+// Returns:
+//   - True if both SIDs have the same revision, identifier authority and sub authorities, false otherwise.
+func (s *SID) Equal(other *SID) bool {
+	if other == nil || s.Revision != other.Revision || s.IdentifierAuthority != other.IdentifierAuthority {
+		return false
+	}
+	if len(s.SubAuthorities) != len(other.SubAuthorities) {
+		return false
+	}
+	for k := range s.SubAuthorities {
+		if s.SubAuthorities[k] != other.SubAuthorities[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare compares two SIDs, ordering them by revision, identifier authority, number of sub authorities and
+// finally sub authorities, like the RtlCompareSid function does for equality.
+//
+// Parameters:
+//   - other: A pointer to the SID to compare with.
+//
+// Returns:
+//   - -1 if the SID is lower than the other SID, 0 if both SIDs are equal and +1 otherwise.
+func (s *SID) Compare(other *SID) int {
+	switch {
+	case s.Revision != other.Revision:
+		return compareUint(uint64(s.Revision), uint64(other.Revision))
+	case s.IdentifierAuthority != other.IdentifierAuthority:
+		return compareUint(s.IdentifierAuthority, other.IdentifierAuthority)
+	case len(s.SubAuthorities) != len(other.SubAuthorities):
+		return compareUint(uint64(len(s.SubAuthorities)), uint64(len(other.SubAuthorities)))
+	}
+	for k := range s.SubAuthorities {
+		if s.SubAuthorities[k] != other.SubAuthorities[k] {
+			return compareUint(uint64(s.SubAuthorities[k]), uint64(other.SubAuthorities[k]))
+		}
+	}
+	return 0
+}
+
+// Copy returns a deep copy of the SID.
+//
+// Returns:
+//   - A pointer to a new SID equal to the SID.
+func (s *SID) Copy() *SID {
+	return &SID{Revision: s.Revision, IdentifierAuthority: s.IdentifierAuthority, SubAuthorities: append([]uint32{}, s.SubAuthorities...)}
+}
+
+// GetRID returns the relative identifier of the SID, its last sub authority.
+//
+// Returns:
+//   - The relative identifier of the SID, or 0 if the SID has no sub authority.
+func (s *SID) GetRID() uint32 {
+	if len(s.SubAuthorities) == 0 {
+		return 0
+	}
+	return s.SubAuthorities[len(s.SubAuthorities)-1]
+}
+
+// GetDomainSID returns the SID of the domain of the SID, made of all its sub authorities but the last one.
+//
+// Returns:
+//   - A pointer to a new SID holding the domain SID, or nil if the SID has no sub authority.
+func (s *SID) GetDomainSID() *SID {
+	if len(s.SubAuthorities) == 0 {
+		return nil
+	}
+	return &SID{Revision: s.Revision, IdentifierAuthority: s.IdentifierAuthority, SubAuthorities: append([]uint32{}, s.SubAuthorities[:len(s.SubAuthorities)-1]...)}
+}
+
+// IsInDomain checks if the SID is the SID of an account of a domain.
+//
+// Parameters:
+//   - domainSID: A pointer to the SID of the domain.
+//
+// Returns:
+//   - True if the SID is made of the domain SID followed by a RID, false otherwise.
+func (s *SID) IsInDomain(domainSID *SID) bool {
+	if domainSID == nil || len(s.SubAuthorities) != len(domainSID.SubAuthorities)+1 {
+		return false
+	}
+	return s.GetDomainSID().Equal(domainSID)
+}
+
+// IsDomainSID checks if the SID is the SID of a Windows domain, of the form S-1-5-21-X-Y-Z.
+//
+// Returns:
+//   - True if the SID is a domain SID, false otherwise.
+func (s *SID) IsDomainSID() bool {
+	return s.IdentifierAuthority == SECURITY_NT_AUTHORITY && len(s.SubAuthorities) == 4 && s.SubAuthorities[0] == SECURITY_NT_NON_UNIQUE
+}
+
+// IsDomainAccountSID checks if the SID is the SID of an account of a Windows domain, of the form S-1-5-21-X-Y-Z-RID.
+//
+// Returns:
+//   - True if the SID is the SID of a domain account, false otherwise.
+func (s *SID) IsDomainAccountSID() bool {
+	return s.IdentifierAuthority == SECURITY_NT_AUTHORITY && len(s.SubAuthorities) == 5 && s.SubAuthorities[0] == SECURITY_NT_NON_UNIQUE
+}
+
+// compareUint compares two unsigned integers.
+func compareUint(a, b uint64) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,108 @@
+package sid
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSIDRoundTrip(t *testing.T) {
+	tests := []string{"S-1-1-0", "S-1-5-32-544", "S-1-5-21-2000478354-688448229-1599263404-1104", "S-1-5", "S-1-0x123456789ABC-1"}
+	for _, test := range tests {
+		sid, err := FromString(test)
+		if err != nil {
+			t.Fatalf("FromString(%s) error = %v", test, err)
+		}
+		parsed, err := FromBytes(sid.ToBytes())
+		if err != nil {
+			t.Fatalf("FromBytes(%s) error = %v", test, err)
+		}
+		if parsed.String() != test || !parsed.Equal(sid) || parsed.Compare(sid) != 0 {
+			t.Errorf("Round trip of %s gave %s", test, parsed.String())
+		}
+	}
+
+	for _, test := range []string{"", "S-2-5-32", "S-1-5-a", "X-1-5-32", "S-1-5-1-2-3-4-5-6-7-8-9-10-11-12-13-14-15-16"} {
+		if _, err := FromString(test); err == nil {
+			t.Errorf("FromString(%q) should fail", test)
+		}
+	}
+	for _, test := range [][]byte{{0x01, 0x02, 0, 0, 0, 0, 0, 5, 0x20, 0, 0, 0}, {0x02, 0x00, 0, 0, 0, 0, 0, 5}, {0x01}} {
+		if _, err := FromBytes(test); err == nil {
+			t.Errorf("FromBytes(%x) should fail", test)
+		}
+	}
+}
+
+func TestSIDToBytes(t *testing.T) {
+	sid, _ := FromString("S-1-5-21-1-2-3-1104")
+	expected := []byte{0x01, 0x05, 0, 0, 0, 0, 0, 0x05, 0x15, 0, 0, 0, 0x01, 0, 0, 0, 0x02, 0, 0, 0, 0x03, 0, 0, 0, 0x50, 0x04, 0, 0}
+	if !bytes.Equal(sid.ToBytes(), expected) {
+		t.Errorf("ToBytes() = %x; want %x", sid.ToBytes(), expected)
+	}
+	if sid.Size() != len(expected) {
+		t.Errorf("Size() = %d; want %d", sid.Size(), len(expected))
+	}
+}
+
+func TestSIDDomainAndRID(t *testing.T) {
+	domainSID, _ := FromString("S-1-5-21-1-2-3")
+	sid := FromDomainSIDAndRID(domainSID, DOMAIN_GROUP_RID_ADMINS)
+	if sid.String() != "S-1-5-21-1-2-3-512" {
+		t.Errorf("FromDomainSIDAndRID() = %s", sid)
+	}
+	if len(domainSID.SubAuthorities) != 4 {
+		t.Errorf("FromDomainSIDAndRID() modified the domain SID to %s", domainSID)
+	}
+	if sid.GetRID() != 512 || !sid.GetDomainSID().Equal(domainSID) || !sid.IsInDomain(domainSID) {
+		t.Errorf("Unexpected RID %d or domain SID %s", sid.GetRID(), sid.GetDomainSID())
+	}
+	if !domainSID.IsDomainSID() || domainSID.IsDomainAccountSID() || !sid.IsDomainAccountSID() || sid.IsDomainSID() {
+		t.Error("Unexpected domain SID classification")
+	}
+
+	builtin, _ := FromString(SID_BUILTIN)
+	if FromDomainSIDAndRID(builtin, 544).String() != SID_BUILTIN_ADMINISTRATORS {
+		t.Errorf("FromDomainSIDAndRID(BUILTIN, 544) = %s", FromDomainSIDAndRID(builtin, 544))
+	}
+	if sid.IsInDomain(builtin) {
+		t.Error("IsInDomain(BUILTIN) should be false")
+	}
+}
+
+func TestSIDCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"S-1-5-21-1-2-3-500", "S-1-5-21-1-2-3-500", 0},
+		{"S-1-5-21-1-2-3-500", "S-1-5-21-1-2-3-1104", -1},
+		{"S-1-5-32-544", "S-1-1-0", 1},
+		{"S-1-5-32", "S-1-5-32-544", -1},
+	}
+	for _, tt := range tests {
+		a, _ := FromString(tt.a)
+		b, _ := FromString(tt.b)
+		if result := a.Compare(b); result != tt.expected {
+			t.Errorf("Compare(%s, %s) = %d; want %d", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
+
+func TestLookupWellKnownSIDName(t *testing.T) {
+	tests := map[string]string{
+		"S-1-1-0":              "Everyone",
+		"S-1-5-18":             "NT AUTHORITY\\SYSTEM",
+		"S-1-5-32-544":         "BUILTIN\\Administrators",
+		"S-1-5-21-1-2-3-512":   "Domain Admins",
+		"S-1-5-21-1-2-3-502":   "krbtgt",
+		"S-1-5-21-1-2-3-1104":  "",
+		"S-1-5-21-1-2-512":     "",
+		"S-1-5-21-1-2-3-4-512": "",
+	}
+	for value, expected := range tests {
+		sid, _ := FromString(value)
+		if name := sid.Name(); name != expected {
+			t.Errorf("Name(%s) = %q; want %q", value, name, expected)
+		}
+	}
+}
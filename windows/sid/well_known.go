@@ -0,0 +1,255 @@
+package sid
+
+// Well-known SIDs
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/81d92bba-d22b-4a8c-908a-554ab29148ab
+const (
+	SID_NULL                                = "S-1-0-0"
+	SID_EVERYONE                            = "S-1-1-0"
+	SID_LOCAL                               = "S-1-2-0"
+	SID_CONSOLE_LOGON                       = "S-1-2-1"
+	SID_CREATOR_OWNER                       = "S-1-3-0"
+	SID_CREATOR_GROUP                       = "S-1-3-1"
+	SID_CREATOR_OWNER_SERVER                = "S-1-3-2"
+	SID_CREATOR_GROUP_SERVER                = "S-1-3-3"
+	SID_OWNER_RIGHTS                        = "S-1-3-4"
+	SID_NT_AUTHORITY                        = "S-1-5"
+	SID_DIALUP                              = "S-1-5-1"
+	SID_NETWORK                             = "S-1-5-2"
+	SID_BATCH                               = "S-1-5-3"
+	SID_INTERACTIVE                         = "S-1-5-4"
+	SID_SERVICE                             = "S-1-5-6"
+	SID_ANONYMOUS                           = "S-1-5-7"
+	SID_PROXY                               = "S-1-5-8"
+	SID_ENTERPRISE_DOMAIN_CONTROLLERS       = "S-1-5-9"
+	SID_PRINCIPAL_SELF                      = "S-1-5-10"
+	SID_AUTHENTICATED_USERS                 = "S-1-5-11"
+	SID_RESTRICTED_CODE                     = "S-1-5-12"
+	SID_TERMINAL_SERVER_USER                = "S-1-5-13"
+	SID_REMOTE_INTERACTIVE_LOGON            = "S-1-5-14"
+	SID_THIS_ORGANIZATION                   = "S-1-5-15"
+	SID_IUSR                                = "S-1-5-17"
+	SID_LOCAL_SYSTEM                        = "S-1-5-18"
+	SID_LOCAL_SERVICE                       = "S-1-5-19"
+	SID_NETWORK_SERVICE                     = "S-1-5-20"
+	SID_ENTERPRISE_READONLY_CONTROLLERS     = "S-1-5-22"
+	SID_NTLM_AUTHENTICATION                 = "S-1-5-64-10"
+	SID_SCHANNEL_AUTHENTICATION             = "S-1-5-64-14"
+	SID_DIGEST_AUTHENTICATION               = "S-1-5-64-21"
+	SID_NT_SERVICE                          = "S-1-5-80"
+	SID_ALL_SERVICES                        = "S-1-5-80-0"
+	SID_OTHER_ORGANIZATION                  = "S-1-5-1000"
+	SID_ALL_APP_PACKAGES                    = "S-1-15-2-1"
+	SID_ML_UNTRUSTED                        = "S-1-16-0"
+	SID_ML_LOW                              = "S-1-16-4096"
+	SID_ML_MEDIUM                           = "S-1-16-8192"
+	SID_ML_MEDIUM_PLUS                      = "S-1-16-8448"
+	SID_ML_HIGH                             = "S-1-16-12288"
+	SID_ML_SYSTEM                           = "S-1-16-16384"
+	SID_ML_PROTECTED_PROCESS                = "S-1-16-20480"
+	SID_AUTHENTICATION_AUTHORITY_ASSERTED   = "S-1-18-1"
+	SID_SERVICE_ASSERTED                    = "S-1-18-2"
+	SID_FRESH_PUBLIC_KEY_IDENTITY           = "S-1-18-3"
+	SID_KEY_TRUST_IDENTITY                  = "S-1-18-4"
+	SID_KEY_PROPERTY_MFA                    = "S-1-18-5"
+	SID_KEY_PROPERTY_ATTESTATION            = "S-1-18-6"
+	SID_BUILTIN                             = "S-1-5-32"
+	SID_BUILTIN_ADMINISTRATORS              = "S-1-5-32-544"
+	SID_BUILTIN_USERS                       = "S-1-5-32-545"
+	SID_BUILTIN_GUESTS                      = "S-1-5-32-546"
+	SID_BUILTIN_POWER_USERS                 = "S-1-5-32-547"
+	SID_BUILTIN_ACCOUNT_OPERATORS           = "S-1-5-32-548"
+	SID_BUILTIN_SERVER_OPERATORS            = "S-1-5-32-549"
+	SID_BUILTIN_PRINT_OPERATORS             = "S-1-5-32-550"
+	SID_BUILTIN_BACKUP_OPERATORS            = "S-1-5-32-551"
+	SID_BUILTIN_REPLICATOR                  = "S-1-5-32-552"
+	SID_BUILTIN_PRE_WINDOWS_2000_COMPATIBLE = "S-1-5-32-554"
+	SID_BUILTIN_REMOTE_DESKTOP_USERS        = "S-1-5-32-555"
+	SID_BUILTIN_NETWORK_CONFIGURATION_OPS   = "S-1-5-32-556"
+	SID_BUILTIN_INCOMING_FOREST_TRUST_BUILD = "S-1-5-32-557"
+	SID_BUILTIN_PERFORMANCE_MONITOR_USERS   = "S-1-5-32-558"
+	SID_BUILTIN_PERFORMANCE_LOG_USERS       = "S-1-5-32-559"
+	SID_BUILTIN_AUTHORIZATION_ACCESS        = "S-1-5-32-560"
+	SID_BUILTIN_TERMINAL_SERVER_LICENSE     = "S-1-5-32-561"
+	SID_BUILTIN_DISTRIBUTED_COM_USERS       = "S-1-5-32-562"
+	SID_BUILTIN_IIS_IUSRS                   = "S-1-5-32-568"
+	SID_BUILTIN_CRYPTOGRAPHIC_OPERATORS     = "S-1-5-32-569"
+	SID_BUILTIN_EVENT_LOG_READERS           = "S-1-5-32-573"
+	SID_BUILTIN_CERTSVC_DCOM_ACCESS         = "S-1-5-32-574"
+	SID_BUILTIN_RDS_REMOTE_ACCESS_SERVERS   = "S-1-5-32-575"
+	SID_BUILTIN_RDS_ENDPOINT_SERVERS        = "S-1-5-32-576"
+	SID_BUILTIN_RDS_MANAGEMENT_SERVERS      = "S-1-5-32-577"
+	SID_BUILTIN_HYPER_V_ADMINISTRATORS      = "S-1-5-32-578"
+	SID_BUILTIN_ACCESS_CONTROL_ASSISTANCE   = "S-1-5-32-579"
+	SID_BUILTIN_REMOTE_MANAGEMENT_USERS     = "S-1-5-32-580"
+	SID_BUILTIN_STORAGE_REPLICA_ADMINS      = "S-1-5-32-582"
+)
+
+// Relative identifiers of the well-known accounts and groups of the domains
+// Src: https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/565a6584-3061-4ede-a531-f5c53826504b
+const (
+	DOMAIN_GROUP_RID_ENTERPRISE_READONLY_CONTROLLERS uint32 = 498
+	DOMAIN_USER_RID_ADMIN                            uint32 = 500
+	DOMAIN_USER_RID_GUEST                            uint32 = 501
+	DOMAIN_USER_RID_KRBTGT                           uint32 = 502
+	DOMAIN_USER_RID_DEFAULT_ACCOUNT                  uint32 = 503
+	DOMAIN_GROUP_RID_ADMINS                          uint32 = 512
+	DOMAIN_GROUP_RID_USERS                           uint32 = 513
+	DOMAIN_GROUP_RID_GUESTS                          uint32 = 514
+	DOMAIN_GROUP_RID_COMPUTERS                       uint32 = 515
+	DOMAIN_GROUP_RID_CONTROLLERS                     uint32 = 516
+	DOMAIN_GROUP_RID_CERT_PUBLISHERS                 uint32 = 517
+	DOMAIN_GROUP_RID_SCHEMA_ADMINS                   uint32 = 518
+	DOMAIN_GROUP_RID_ENTERPRISE_ADMINS               uint32 = 519
+	DOMAIN_GROUP_RID_POLICY_CREATOR_OWNERS           uint32 = 520
+	DOMAIN_GROUP_RID_READONLY_CONTROLLERS            uint32 = 521
+	DOMAIN_GROUP_RID_CLONEABLE_CONTROLLERS           uint32 = 522
+	DOMAIN_GROUP_RID_CDC_RESERVED                    uint32 = 524
+	DOMAIN_GROUP_RID_PROTECTED_USERS                 uint32 = 525
+	DOMAIN_GROUP_RID_KEY_ADMINS                      uint32 = 526
+	DOMAIN_GROUP_RID_ENTERPRISE_KEY_ADMINS           uint32 = 527
+	DOMAIN_ALIAS_RID_RAS_SERVERS                     uint32 = 553
+	DOMAIN_ALIAS_RID_ALLOWED_RODC_REPLICATION        uint32 = 571
+	DOMAIN_ALIAS_RID_DENIED_RODC_REPLICATION         uint32 = 572
+	DOMAIN_ALIAS_RID_CERTSVC_DCOM_ACCESS             uint32 = 574
+)
+
+// WellKnownSIDs maps the well-known SIDs, which are the same on every machine and domain, to their names.
+var WellKnownSIDs = map[string]string{
+	SID_NULL:                                "NULL SID",
+	SID_EVERYONE:                            "Everyone",
+	SID_LOCAL:                               "LOCAL",
+	SID_CONSOLE_LOGON:                       "CONSOLE LOGON",
+	SID_CREATOR_OWNER:                       "CREATOR OWNER",
+	SID_CREATOR_GROUP:                       "CREATOR GROUP",
+	SID_CREATOR_OWNER_SERVER:                "CREATOR OWNER SERVER",
+	SID_CREATOR_GROUP_SERVER:                "CREATOR GROUP SERVER",
+	SID_OWNER_RIGHTS:                        "OWNER RIGHTS",
+	SID_NT_AUTHORITY:                        "NT AUTHORITY",
+	SID_DIALUP:                              "NT AUTHORITY\\DIALUP",
+	SID_NETWORK:                             "NT AUTHORITY\\NETWORK",
+	SID_BATCH:                               "NT AUTHORITY\\BATCH",
+	SID_INTERACTIVE:                         "NT AUTHORITY\\INTERACTIVE",
+	SID_SERVICE:                             "NT AUTHORITY\\SERVICE",
+	SID_ANONYMOUS:                           "NT AUTHORITY\\ANONYMOUS LOGON",
+	SID_PROXY:                               "NT AUTHORITY\\PROXY",
+	SID_ENTERPRISE_DOMAIN_CONTROLLERS:       "NT AUTHORITY\\ENTERPRISE DOMAIN CONTROLLERS",
+	SID_PRINCIPAL_SELF:                      "NT AUTHORITY\\SELF",
+	SID_AUTHENTICATED_USERS:                 "NT AUTHORITY\\Authenticated Users",
+	SID_RESTRICTED_CODE:                     "NT AUTHORITY\\RESTRICTED",
+	SID_TERMINAL_SERVER_USER:                "NT AUTHORITY\\TERMINAL SERVER USER",
+	SID_REMOTE_INTERACTIVE_LOGON:            "NT AUTHORITY\\REMOTE INTERACTIVE LOGON",
+	SID_THIS_ORGANIZATION:                   "NT AUTHORITY\\This Organization",
+	SID_IUSR:                                "NT AUTHORITY\\IUSR",
+	SID_LOCAL_SYSTEM:                        "NT AUTHORITY\\SYSTEM",
+	SID_LOCAL_SERVICE:                       "NT AUTHORITY\\LOCAL SERVICE",
+	SID_NETWORK_SERVICE:                     "NT AUTHORITY\\NETWORK SERVICE",
+	SID_ENTERPRISE_READONLY_CONTROLLERS:     "NT AUTHORITY\\ENTERPRISE READ-ONLY DOMAIN CONTROLLERS BETA",
+	SID_NTLM_AUTHENTICATION:                 "NT AUTHORITY\\NTLM Authentication",
+	SID_SCHANNEL_AUTHENTICATION:             "NT AUTHORITY\\SChannel Authentication",
+	SID_DIGEST_AUTHENTICATION:               "NT AUTHORITY\\Digest Authentication",
+	SID_NT_SERVICE:                          "NT SERVICE",
+	SID_ALL_SERVICES:                        "NT SERVICE\\ALL SERVICES",
+	SID_OTHER_ORGANIZATION:                  "NT AUTHORITY\\Other Organization",
+	SID_ALL_APP_PACKAGES:                    "APPLICATION PACKAGE AUTHORITY\\ALL APPLICATION PACKAGES",
+	SID_ML_UNTRUSTED:                        "Mandatory Label\\Untrusted Mandatory Level",
+	SID_ML_LOW:                              "Mandatory Label\\Low Mandatory Level",
+	SID_ML_MEDIUM:                           "Mandatory Label\\Medium Mandatory Level",
+	SID_ML_MEDIUM_PLUS:                      "Mandatory Label\\Medium Plus Mandatory Level",
+	SID_ML_HIGH:                             "Mandatory Label\\High Mandatory Level",
+	SID_ML_SYSTEM:                           "Mandatory Label\\System Mandatory Level",
+	SID_ML_PROTECTED_PROCESS:                "Mandatory Label\\Protected Process Mandatory Level",
+	SID_AUTHENTICATION_AUTHORITY_ASSERTED:   "Authentication authority asserted identity",
+	SID_SERVICE_ASSERTED:                    "Service asserted identity",
+	SID_FRESH_PUBLIC_KEY_IDENTITY:           "Fresh public key identity",
+	SID_KEY_TRUST_IDENTITY:                  "Key trust identity",
+	SID_KEY_PROPERTY_MFA:                    "Key property multi-factor authentication",
+	SID_KEY_PROPERTY_ATTESTATION:            "Key property attestation",
+	SID_BUILTIN:                             "BUILTIN",
+	SID_BUILTIN_ADMINISTRATORS:              "BUILTIN\\Administrators",
+	SID_BUILTIN_USERS:                       "BUILTIN\\Users",
+	SID_BUILTIN_GUESTS:                      "BUILTIN\\Guests",
+	SID_BUILTIN_POWER_USERS:                 "BUILTIN\\Power Users",
+	SID_BUILTIN_ACCOUNT_OPERATORS:           "BUILTIN\\Account Operators",
+	SID_BUILTIN_SERVER_OPERATORS:            "BUILTIN\\Server Operators",
+	SID_BUILTIN_PRINT_OPERATORS:             "BUILTIN\\Print Operators",
+	SID_BUILTIN_BACKUP_OPERATORS:            "BUILTIN\\Backup Operators",
+	SID_BUILTIN_REPLICATOR:                  "BUILTIN\\Replicator",
+	SID_BUILTIN_PRE_WINDOWS_2000_COMPATIBLE: "BUILTIN\\Pre-Windows 2000 Compatible Access",
+	SID_BUILTIN_REMOTE_DESKTOP_USERS:        "BUILTIN\\Remote Desktop Users",
+	SID_BUILTIN_NETWORK_CONFIGURATION_OPS:   "BUILTIN\\Network Configuration Operators",
+	SID_BUILTIN_INCOMING_FOREST_TRUST_BUILD: "BUILTIN\\Incoming Forest Trust Builders",
+	SID_BUILTIN_PERFORMANCE_MONITOR_USERS:   "BUILTIN\\Performance Monitor Users",
+	SID_BUILTIN_PERFORMANCE_LOG_USERS:       "BUILTIN\\Performance Log Users",
+	SID_BUILTIN_AUTHORIZATION_ACCESS:        "BUILTIN\\Windows Authorization Access Group",
+	SID_BUILTIN_TERMINAL_SERVER_LICENSE:     "BUILTIN\\Terminal Server License Servers",
+	SID_BUILTIN_DISTRIBUTED_COM_USERS:       "BUILTIN\\Distributed COM Users",
+	SID_BUILTIN_IIS_IUSRS:                   "BUILTIN\\IIS_IUSRS",
+	SID_BUILTIN_CRYPTOGRAPHIC_OPERATORS:     "BUILTIN\\Cryptographic Operators",
+	SID_BUILTIN_EVENT_LOG_READERS:           "BUILTIN\\Event Log Readers",
+	SID_BUILTIN_CERTSVC_DCOM_ACCESS:         "BUILTIN\\Certificate Service DCOM Access",
+	SID_BUILTIN_RDS_REMOTE_ACCESS_SERVERS:   "BUILTIN\\RDS Remote Access Servers",
+	SID_BUILTIN_RDS_ENDPOINT_SERVERS:        "BUILTIN\\RDS Endpoint Servers",
+	SID_BUILTIN_RDS_MANAGEMENT_SERVERS:      "BUILTIN\\RDS Management Servers",
+	SID_BUILTIN_HYPER_V_ADMINISTRATORS:      "BUILTIN\\Hyper-V Administrators",
+	SID_BUILTIN_ACCESS_CONTROL_ASSISTANCE:   "BUILTIN\\Access Control Assistance Operators",
+	SID_BUILTIN_REMOTE_MANAGEMENT_USERS:     "BUILTIN\\Remote Management Users",
+	SID_BUILTIN_STORAGE_REPLICA_ADMINS:      "BUILTIN\\Storage Replica Administrators",
+}
+
+// WellKnownDomainRIDs maps the relative identifiers of the well-known accounts and groups of the domains
+// to their names.
+var WellKnownDomainRIDs = map[uint32]string{
+	DOMAIN_GROUP_RID_ENTERPRISE_READONLY_CONTROLLERS: "Enterprise Read-only Domain Controllers",
+	DOMAIN_USER_RID_ADMIN:                            "Administrator",
+	DOMAIN_USER_RID_GUEST:                            "Guest",
+	DOMAIN_USER_RID_KRBTGT:                           "krbtgt",
+	DOMAIN_USER_RID_DEFAULT_ACCOUNT:                  "DefaultAccount",
+	DOMAIN_GROUP_RID_ADMINS:                          "Domain Admins",
+	DOMAIN_GROUP_RID_USERS:                           "Domain Users",
+	DOMAIN_GROUP_RID_GUESTS:                          "Domain Guests",
+	DOMAIN_GROUP_RID_COMPUTERS:                       "Domain Computers",
+	DOMAIN_GROUP_RID_CONTROLLERS:                     "Domain Controllers",
+	DOMAIN_GROUP_RID_CERT_PUBLISHERS:                 "Cert Publishers",
+	DOMAIN_GROUP_RID_SCHEMA_ADMINS:                   "Schema Admins",
+	DOMAIN_GROUP_RID_ENTERPRISE_ADMINS:               "Enterprise Admins",
+	DOMAIN_GROUP_RID_POLICY_CREATOR_OWNERS:           "Group Policy Creator Owners",
+	DOMAIN_GROUP_RID_READONLY_CONTROLLERS:            "Read-only Domain Controllers",
+	DOMAIN_GROUP_RID_CLONEABLE_CONTROLLERS:           "Cloneable Domain Controllers",
+	DOMAIN_GROUP_RID_CDC_RESERVED:                    "CDC Reserved",
+	DOMAIN_GROUP_RID_PROTECTED_USERS:                 "Protected Users",
+	DOMAIN_GROUP_RID_KEY_ADMINS:                      "Key Admins",
+	DOMAIN_GROUP_RID_ENTERPRISE_KEY_ADMINS:           "Enterprise Key Admins",
+	DOMAIN_ALIAS_RID_RAS_SERVERS:                     "RAS and IAS Servers",
+	DOMAIN_ALIAS_RID_ALLOWED_RODC_REPLICATION:        "Allowed RODC Password Replication Group",
+	DOMAIN_ALIAS_RID_DENIED_RODC_REPLICATION:         "Denied RODC Password Replication Group",
+	DOMAIN_ALIAS_RID_CERTSVC_DCOM_ACCESS:             "Certificate Service DCOM Access",
+}
+
+// LookupWellKnownSIDName returns the name of a well-known SID or of a well-known account of a domain.
+//
+// Parameters:
+//   - s: A pointer to the SID to look up.
+//
+// Returns:
+//   - A string containing the name of the SID, such as "BUILTIN\Administrators" or "Domain Admins", or an empty
+//     string if the SID is not well-known.
+func LookupWellKnownSIDName(s *SID) string {
+	if s == nil {
+		return ""
+	}
+	if name, ok := WellKnownSIDs[s.String()]; ok {
+		return name
+	}
+	if s.IsDomainAccountSID() {
+		return WellKnownDomainRIDs[s.GetRID()]
+	}
+	return ""
+}
+
+// Name returns the name of the SID from the catalog of well-known SIDs.
+//
+// Returns:
+//   - A string containing the name of the SID, or an empty string if the SID is not well-known.
+func (s *SID) Name() string {
+	return LookupWellKnownSIDName(s)
+}